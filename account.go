@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// dropboxAccount is the subset of users/get_current_account we use.
+type dropboxAccount struct {
+	AccountID string `json:"account_id"`
+	Email     string `json:"email"`
+}
+
+// CurrentAccount returns the account the client's token belongs to.
+func (c *DropboxClient) CurrentAccount() (dropboxAccount, error) {
+	var acct dropboxAccount
+	body, err := c.rpc("/2/users/get_current_account", nil)
+	if err != nil {
+		return acct, err
+	}
+	if err := json.Unmarshal(body, &acct); err != nil {
+		return acct, fmt.Errorf("parsing get_current_account response: %w", err)
+	}
+	return acct, nil
+}
+
+// activeCredential returns the credential resolveToken will use: a direct
+// DROPBOX_TOKEN or the refresh token (which may itself come from the env).
+func activeCredential(cfg *Config) string {
+	if token := os.Getenv("DROPBOX_TOKEN"); token != "" {
+		return token
+	}
+	return cfg.RefreshToken
+}
+
+// verifyAccount checks that the client's credentials belong to the account
+// recorded by `dropbox-appender auth`. To avoid an API call on every run it
+// is only checked when the credential in use differs from the one that was
+// authorized, or when force is set. Configs without a recorded account are
+// not checked unless forced.
+func verifyAccount(client *DropboxClient, cfg *Config, force bool) error {
+	if cfg.AccountID == "" {
+		if force {
+			return fmt.Errorf("no account_id recorded in the config, run: dropbox-appender auth")
+		}
+		return nil
+	}
+	if !force && checksum([]byte(activeCredential(cfg))) == cfg.AccountCredential {
+		return nil
+	}
+	acct, err := client.CurrentAccount()
+	if err != nil {
+		return fmt.Errorf("verifying account: %w", err)
+	}
+	if acct.AccountID != cfg.AccountID {
+		return fmt.Errorf("refusing to write: credentials belong to %s (%s), but this config was authorized for %s",
+			acct.Email, acct.AccountID, cfg.AccountID)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func accountServer(t *testing.T, accountID string, calls *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2/users/get_current_account" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		*calls++
+		w.Write([]byte(`{"account_id":"` + accountID + `","email":"someone@example.com"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVerifyAccount(t *testing.T) {
+	t.Setenv("DROPBOX_TOKEN", "")
+	authorized := checksum([]byte("refresh-a"))
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		force     bool
+		serverID  string
+		wantCalls int
+		wantErr   string
+	}{
+		{"no account recorded", Config{RefreshToken: "refresh-a"}, false, "dbid:a", 0, ""},
+		{"no account recorded, forced", Config{RefreshToken: "refresh-a"}, true, "dbid:a", 0, "no account_id"},
+		{"same credential skips check", Config{RefreshToken: "refresh-a", AccountID: "dbid:a", AccountCredential: authorized}, false, "dbid:b", 0, ""},
+		{"changed credential, same account", Config{RefreshToken: "refresh-b", AccountID: "dbid:a", AccountCredential: authorized}, false, "dbid:a", 1, ""},
+		{"changed credential, other account", Config{RefreshToken: "refresh-b", AccountID: "dbid:a", AccountCredential: authorized}, false, "dbid:b", 1, "refusing to write"},
+		{"forced, other account", Config{RefreshToken: "refresh-a", AccountID: "dbid:a", AccountCredential: authorized}, true, "dbid:b", 1, "refusing to write"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := accountServer(t, tt.serverID, &calls)
+			client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+			err := verifyAccount(client, &tt.cfg, tt.force)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("get_current_account calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestVerifyAccount_EnvToken(t *testing.T) {
+	t.Setenv("DROPBOX_TOKEN", "short-lived")
+	calls := 0
+	srv := accountServer(t, "dbid:a", &calls)
+	client := &DropboxClient{Token: "short-lived", BaseURL: srv.URL}
+	cfg := &Config{RefreshToken: "refresh-a", AccountID: "dbid:a", AccountCredential: checksum([]byte("refresh-a"))}
+	if err := verifyAccount(client, cfg, false); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 when DROPBOX_TOKEN overrides the authorized credential", calls)
+	}
+}
+
+func TestConnectClient_VerifiesOnlyWrites(t *testing.T) {
+	t.Setenv("DROPBOX_TOKEN", "short-lived")
+	calls := 0
+	srv := accountServer(t, "dbid:b", &calls)
+	cfg := &Config{AccountID: "dbid:a", AccountCredential: checksum([]byte("refresh-a")),
+		Endpoints: map[string]string{"api": srv.URL}}
+
+	if _, err := connectClient(cfg, false); err != nil || calls != 0 {
+		t.Fatalf("read: err = %v, calls = %d; want no check", err, calls)
+	}
+	if _, err := connectClient(cfg, true); err == nil || !strings.Contains(err.Error(), "refusing to write") {
+		t.Errorf("write: expected the other account to be refused, got %v", err)
+	}
+}
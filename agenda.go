@@ -0,0 +1,455 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// agendaMarker starts every agenda block so a day's agenda is only added
+// once.
+const agendaMarker = "<!-- agenda -->"
+
+// maxICSBytes bounds how much of a calendar feed is read.
+const maxICSBytes = 16 << 20
+
+// calendarEvent is one VEVENT of an ICS feed.
+type calendarEvent struct {
+	Summary  string
+	Location string
+	Start    time.Time
+	End      time.Time
+	AllDay   bool
+	Rule     *recurrence // nil for one-off events
+	ExDates  []time.Time // start times of cancelled occurrences
+}
+
+// recurrence is the subset of an RRULE that agenda expands: FREQ with
+// INTERVAL, COUNT, UNTIL and, for weekly rules, BYDAY.
+type recurrence struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+// agendaItem is an event occurring on the agenda's day.
+type agendaItem struct {
+	Start, End time.Time
+	AllDay     bool
+	Summary    string
+	Location   string
+}
+
+// unfoldICS splits an ICS document into content lines, joining folded
+// continuation lines (RFC 5545 §3.1).
+func unfoldICS(data string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// icsProperty splits a content line into its upper-cased name, parameters
+// and value.
+func icsProperty(line string) (name string, params map[string]string, value string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	params = map[string]string{}
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// icsText unescapes an ICS TEXT value.
+func icsText(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// icsTime parses a DATE or DATE-TIME value. Times ending in Z are UTC, a
+// TZID parameter names their zone, and floating times are local.
+func icsTime(params map[string]string, value string) (t time.Time, allDay bool, err error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, lerr := time.LoadLocation(tzid); lerr == nil {
+			loc = l
+		}
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// parseRRule parses the parts of an RRULE agenda understands. Rules it
+// can't expand faithfully (e.g. monthly BYDAY) return nil, so the event
+// only shows on its first date.
+func parseRRule(value string) *recurrence {
+	r := &recurrence{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(v)
+		case "INTERVAL":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				r.Interval = n
+			}
+		case "COUNT":
+			r.Count, _ = strconv.Atoi(v)
+		case "UNTIL":
+			until, allDay, err := icsTime(nil, v)
+			if err != nil {
+				return nil
+			}
+			if allDay {
+				until = until.AddDate(0, 0, 1).Add(-time.Second) // the whole day
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				wd, ok := icsWeekdays[strings.ToUpper(d)]
+				if !ok {
+					return nil // ordinal days such as 1MO
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "WKST":
+		default:
+			return nil
+		}
+	}
+	switch r.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil
+	}
+	if len(r.ByDay) > 0 && r.Freq != "WEEKLY" {
+		return nil
+	}
+	return r
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseICS returns the events of an ICS document. Cancelled events are left
+// out, as are the properties of components nested in events (alarms).
+func parseICS(data string) ([]calendarEvent, error) {
+	var events []calendarEvent
+	var ev *calendarEvent
+	var cancelled, hasEnd bool
+	nested := 0
+	for i, line := range unfoldICS(data) {
+		name, params, value := icsProperty(line)
+		var err error
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			ev, cancelled, hasEnd = &calendarEvent{}, false, false
+		case ev == nil:
+		case name == "BEGIN":
+			nested++
+		case name == "END" && nested > 0:
+			nested--
+		case nested > 0:
+		case name == "END" && value == "VEVENT":
+			if !cancelled && !ev.Start.IsZero() {
+				if !hasEnd {
+					ev.End = ev.Start
+					if ev.AllDay {
+						ev.End = ev.Start.AddDate(0, 0, 1)
+					}
+				}
+				events = append(events, *ev)
+			}
+			ev = nil
+		case name == "SUMMARY":
+			ev.Summary = icsText(value)
+		case name == "LOCATION":
+			ev.Location = icsText(value)
+		case name == "STATUS":
+			cancelled = strings.EqualFold(value, "CANCELLED")
+		case name == "DTSTART":
+			ev.Start, ev.AllDay, err = icsTime(params, value)
+		case name == "DTEND":
+			ev.End, _, err = icsTime(params, value)
+			hasEnd = true
+		case name == "RRULE":
+			ev.Rule = parseRRule(value)
+		case name == "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, _, err := icsTime(params, v); err == nil {
+					ev.ExDates = append(ev.ExDates, t)
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", i+1, name, err)
+		}
+	}
+	return events, nil
+}
+
+// civilDays returns the number of calendar days from a to b.
+func civilDays(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+// matches reports whether the rule of an event starting at start has an
+// occurrence on the date of day, ignoring COUNT and UNTIL.
+func (r *recurrence) matches(start, day time.Time) bool {
+	days := civilDays(start, day)
+	if days < 0 {
+		return false
+	}
+	switch r.Freq {
+	case "DAILY":
+		return days%r.Interval == 0
+	case "WEEKLY":
+		byDay := r.ByDay
+		if len(byDay) == 0 {
+			byDay = []time.Weekday{start.Weekday()}
+		}
+		found := false
+		for _, wd := range byDay {
+			found = found || wd == day.Weekday()
+		}
+		// Weeks start on Monday (the RFC 5545 default WKST).
+		monday := func(t time.Time) time.Time { return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7)) }
+		return found && (civilDays(monday(start), monday(day))/7)%r.Interval == 0
+	case "MONTHLY":
+		months := (day.Year()-start.Year())*12 + int(day.Month()-start.Month())
+		return day.Day() == start.Day() && months%r.Interval == 0
+	case "YEARLY":
+		return day.Month() == start.Month() && day.Day() == start.Day() && (day.Year()-start.Year())%r.Interval == 0
+	}
+	return false
+}
+
+// occurrenceOn returns the occurrence of ev overlapping the date of day,
+// in day's location.
+func (ev calendarEvent) occurrenceOn(day time.Time) (agendaItem, bool) {
+	loc := day.Location()
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	item := agendaItem{AllDay: ev.AllDay, Summary: ev.Summary, Location: ev.Location}
+
+	start, end := ev.Start, ev.End
+	if ev.AllDay {
+		// All-day dates are calendar dates wherever you are.
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+		end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+	}
+	if ev.Rule != nil {
+		evDay := ev.Start
+		if !ev.AllDay {
+			evDay = dayStart.In(ev.Start.Location())
+		}
+		// The occurrence on this date in the event's own zone.
+		cand := time.Date(evDay.Year(), evDay.Month(), evDay.Day(), ev.Start.Hour(), ev.Start.Minute(), ev.Start.Second(), 0, ev.Start.Location())
+		if ev.AllDay {
+			cand = dayStart
+		}
+		if !ev.Rule.matches(ev.Start, cand) || (!ev.Rule.Until.IsZero() && cand.After(ev.Rule.Until)) {
+			return item, false
+		}
+		if ev.Rule.Count > 0 {
+			n := 0
+			for d := ev.Start; civilDays(d, cand) >= 0; d = d.AddDate(0, 0, 1) {
+				if ev.Rule.matches(ev.Start, d) {
+					n++
+				}
+			}
+			if n > ev.Rule.Count {
+				return item, false
+			}
+		}
+		for _, ex := range ev.ExDates {
+			if civilDays(ex, cand) == 0 {
+				return item, false
+			}
+		}
+		start, end = cand, cand.Add(ev.End.Sub(ev.Start))
+	}
+	if !(start.Before(dayEnd) && (end.After(dayStart) || end.Equal(start) && !start.Before(dayStart))) {
+		return item, false
+	}
+	item.Start, item.End = start.In(loc), end.In(loc)
+	return item, true
+}
+
+// agendaFor returns the events of day, all-day events first and the rest
+// by start time.
+func agendaFor(events []calendarEvent, day time.Time) []agendaItem {
+	var items []agendaItem
+	for _, ev := range events {
+		if item, ok := ev.occurrenceOn(day); ok {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].AllDay != items[j].AllDay {
+			return items[i].AllDay
+		}
+		return items[i].Start.Before(items[j].Start)
+	})
+	return items
+}
+
+// formatAgenda renders items as an agenda block prefixed by agendaMarker.
+func formatAgenda(items []agendaItem) string {
+	var b strings.Builder
+	b.WriteString(agendaMarker + "\n## Agenda\n")
+	for _, it := range items {
+		when := "All day"
+		if !it.AllDay {
+			when = it.Start.Format("15:04")
+			if it.End.After(it.Start) {
+				when += "–" + it.End.Format("15:04")
+			}
+		}
+		fmt.Fprintf(&b, "- %s %s", when, it.Summary)
+		if it.Location != "" {
+			fmt.Fprintf(&b, " (%s)", it.Location)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// readICS reads a calendar feed from a file or an http(s) or webcal URL.
+func readICS(httpClient *http.Client, src string) (string, error) {
+	if strings.HasPrefix(src, "webcal://") {
+		src = "https://" + strings.TrimPrefix(src, "webcal://")
+	}
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		data, err := os.ReadFile(src)
+		return string(data), err
+	}
+	resp, err := httpClient.Get(src)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("fetching calendar: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxICSBytes))
+	return string(data), err
+}
+
+// appendAgenda appends the agenda block for day to the note at path. Notes
+// that already have one are left alone, as are days without events; the
+// returned count is the number of events written.
+func appendAgenda(client *DropboxClient, path string, events []calendarEvent, day time.Time, opts appendOptions) (int, error) {
+	items := agendaFor(events, day)
+	if len(items) == 0 {
+		return 0, nil
+	}
+	content, err := client.Download(path)
+	if err != nil {
+		return 0, fmt.Errorf("downloading journal: %w", err)
+	}
+	if strings.Contains(content, agendaMarker) {
+		return 0, nil
+	}
+	if _, err := appendToJournal(client, path, formatAgenda(items), opts); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// runAgenda implements the `dropbox-appender agenda` subcommand, appending
+// the day's events from an ICS calendar to its note. It returns the process
+// exit code.
+func runAgenda(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("agenda", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	ics := fs.String("ics", "", "calendar to read: an .ics file or an http(s)/webcal URL")
+	date := fs.String("date", "", "use the events and note of this date (YYYY-MM-DD) instead of today")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *ics == "" {
+		fmt.Fprintln(stderr, "usage: dropbox-appender agenda -ics <file-or-url> [-date YYYY-MM-DD]")
+		return 2
+	}
+	now := clock.Now()
+	if *date != "" {
+		d, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+			return 2
+		}
+		now = d
+	}
+
+	data, err := readICS(&http.Client{Timeout: 30 * time.Second}, *ics)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	events, err := parseICS(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: parsing %s: %v\n", *ics, err)
+		return 1
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+
+	n, err := appendAgenda(client, path, events, now, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if n == 0 {
+		fmt.Fprintf(stdout, "No agenda added to %s (no events, or already added)\n", path)
+		return 0
+	}
+	fmt.Fprintf(stdout, "Appended agenda with %d events to %s\n", n, path)
+	return 0
+}
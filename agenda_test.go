@@ -0,0 +1,159 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Design review\\, round 2\r\n" +
+	"LOCATION:Room 4\r\n" +
+	"DTSTART:20250115T140000\r\n" +
+	"DTEND:20250115T150000\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"SUMMARY:alarm text\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Company holi\r\n" +
+	" day\r\n" +
+	"DTSTART;VALUE=DATE:20250115\r\n" +
+	"DTEND;VALUE=DATE:20250116\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Standup\r\n" +
+	"DTSTART:20250106T093000\r\n" +
+	"DTEND:20250106T094500\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR\r\n" +
+	"EXDATE:20250113T093000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Cancelled lunch\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART:20250115T120000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Tomorrow\r\n" +
+	"DTSTART:20250116T090000\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	events, err := parseICS(testICS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4 (cancelled one dropped): %+v", len(events), events)
+	}
+	if events[0].Summary != "Design review, round 2" || events[0].Location != "Room 4" {
+		t.Errorf("first event = %+v", events[0])
+	}
+	if events[1].Summary != "Company holiday" || !events[1].AllDay {
+		t.Errorf("folded all-day event = %+v", events[1])
+	}
+	if events[2].Rule == nil || len(events[2].Rule.ByDay) != 3 || len(events[2].ExDates) != 1 {
+		t.Errorf("recurring event = %+v", events[2])
+	}
+	if _, err := parseICS("BEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\n"); err == nil {
+		t.Error("expected an error for a bad DTSTART")
+	}
+}
+
+func TestAgendaFor(t *testing.T) {
+	events, _ := parseICS(testICS)
+	got := formatAgenda(agendaFor(events, time.Date(2025, 1, 15, 7, 0, 0, 0, time.Local)))
+	want := agendaMarker + "\n## Agenda\n" +
+		"- All day Company holiday\n" +
+		"- 09:30–09:45 Standup\n" +
+		"- 14:00–15:00 Design review, round 2 (Room 4)\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Monday the 13th is excluded; Tuesday has no standup.
+	for _, d := range []int{13, 14} {
+		if items := agendaFor(events, time.Date(2025, 1, d, 0, 0, 0, 0, time.Local)); len(items) != 0 {
+			t.Errorf("Jan %d: got %+v, want nothing", d, items)
+		}
+	}
+}
+
+func TestRecurrenceMatches(t *testing.T) {
+	start := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC) // a Monday
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		rule string
+		day  time.Time
+		want bool
+	}{
+		{"FREQ=DAILY", day(1, 9), true},
+		{"FREQ=DAILY;INTERVAL=2", day(1, 9), false},
+		{"FREQ=DAILY", day(1, 5), false}, // before the start
+		{"FREQ=WEEKLY", day(1, 13), true},
+		{"FREQ=WEEKLY;INTERVAL=2", day(1, 13), false},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", day(1, 21), true},
+		{"FREQ=MONTHLY", day(3, 6), true},
+		{"FREQ=MONTHLY", day(3, 7), false},
+		{"FREQ=YEARLY", time.Date(2027, 1, 6, 0, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		r := parseRRule(tt.rule)
+		if r == nil {
+			t.Fatalf("%s: not parsed", tt.rule)
+		}
+		if got := r.matches(start, tt.day); got != tt.want {
+			t.Errorf("%s on %s = %v, want %v", tt.rule, tt.day.Format("2006-01-02"), got, tt.want)
+		}
+	}
+	if parseRRule("FREQ=MONTHLY;BYDAY=1MO") != nil {
+		t.Error("ordinal BYDAY should not be expanded")
+	}
+}
+
+func TestOccurrenceOn_CountAndUntil(t *testing.T) {
+	ev := calendarEvent{
+		Summary: "Course",
+		Start:   time.Date(2025, 1, 6, 18, 0, 0, 0, time.Local),
+		End:     time.Date(2025, 1, 6, 19, 0, 0, 0, time.Local),
+		Rule:    parseRRule("FREQ=DAILY;COUNT=3"),
+	}
+	for d, want := range map[int]bool{6: true, 8: true, 9: false} {
+		if _, ok := ev.occurrenceOn(time.Date(2025, 1, d, 0, 0, 0, 0, time.Local)); ok != want {
+			t.Errorf("COUNT=3 on Jan %d = %v, want %v", d, ok, want)
+		}
+	}
+	ev.Rule = parseRRule("FREQ=DAILY;UNTIL=20250110")
+	for d, want := range map[int]bool{10: true, 11: false} {
+		if _, ok := ev.occurrenceOn(time.Date(2025, 1, d, 0, 0, 0, 0, time.Local)); ok != want {
+			t.Errorf("UNTIL on Jan %d = %v, want %v", d, ok, want)
+		}
+	}
+}
+
+func TestAppendAgenda(t *testing.T) {
+	files := map[string]string{"/Journal/20250115.md": "### 07:00:00\nwoke up\n"}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	events, _ := parseICS(testICS)
+	day := time.Date(2025, 1, 15, 7, 0, 0, 0, time.Local)
+
+	n, err := appendAgenda(client, "/Journal/20250115.md", events, day, appendOptions{})
+	if err != nil || n != 3 {
+		t.Fatalf("appendAgenda = %d, %v", n, err)
+	}
+	first := files["/Journal/20250115.md"]
+	if !strings.Contains(first, "woke up\n") || !strings.Contains(first, "## Agenda\n- All day Company holiday") {
+		t.Errorf("note = %q", first)
+	}
+	if n, err := appendAgenda(client, "/Journal/20250115.md", events, day, appendOptions{}); err != nil || n != 0 {
+		t.Errorf("second run = %d, %v; want nothing added", n, err)
+	}
+	if files["/Journal/20250115.md"] != first {
+		t.Errorf("second run changed the note: %q", files["/Journal/20250115.md"])
+	}
+}
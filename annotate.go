@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// annotationTemplateName names the file properties template holding
+	// annotations; it is created in the user's account on first use.
+	annotationTemplateName = "dropbox-appender annotations"
+	// maxAnnotations is how many annotations a file can carry: Dropbox
+	// allows 32 fields per property template.
+	maxAnnotations = 32
+	// maxAnnotationBytes is the Dropbox limit on a property value.
+	maxAnnotationBytes = 1024
+)
+
+// annotationField returns the name of the nth (1-based) annotation field.
+func annotationField(n int) string {
+	return fmt.Sprintf("entry_%02d", n)
+}
+
+// annotationTemplate returns the ID of the annotations template, creating
+// it on first use.
+func (c *DropboxClient) annotationTemplate() (string, error) {
+	fields := make([]templateField, maxAnnotations)
+	for i := range fields {
+		fields[i] = templateField{annotationField(i + 1), "An annotation added by dropbox-appender"}
+	}
+	return c.PropertyTemplate(annotationTemplateName, "Entries recorded alongside a file instead of in it", fields)
+}
+
+// Annotations returns the annotation fields of the file at path, in order.
+func (c *DropboxClient) Annotations(path, templateID string) ([]propertyField, error) {
+	body, err := c.rpc("/2/files/get_metadata", map[string]interface{}{
+		"path": path,
+		"include_property_groups": map[string]interface{}{
+			".tag":        "filter_some",
+			"filter_some": []string{templateID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var meta struct {
+		PropertyGroups []struct {
+			TemplateID string          `json:"template_id"`
+			Fields     []propertyField `json:"fields"`
+		} `json:"property_groups"`
+	}
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, fmt.Errorf("parsing get_metadata response: %w", err)
+	}
+	var fields []propertyField
+	for _, g := range meta.PropertyGroups {
+		if g.TemplateID != templateID {
+			continue
+		}
+		for _, f := range g.Fields {
+			if f.Value != "" {
+				fields = append(fields, f)
+			}
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields, nil
+}
+
+// formatAnnotation returns the value stored for an annotation written at now.
+func formatAnnotation(now time.Time, text string) (string, error) {
+	value := now.Format("2006-01-02 15:04:05") + " " + strings.TrimSpace(text)
+	if len(value) > maxAnnotationBytes {
+		return "", fmt.Errorf("annotation is %d bytes; Dropbox allows %d", len(value), maxAnnotationBytes)
+	}
+	return value, nil
+}
+
+// runAnnotate implements `dropbox-appender annotate "text"`, which records an
+// entry as a property of a Dropbox file instead of editing it, so the file
+// stays untouched — useful for shared documents. -list prints the
+// annotations instead. It returns the process exit code.
+func runAnnotate(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("annotate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	filePath := fs.String("path", "", "annotate this Dropbox file (default: today's note)")
+	date := fs.String("date", "", "annotate the note for this date (YYYY-MM-DD)")
+	list := fs.Bool("list", false, "print the file's annotations")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	text := strings.Join(fs.Args(), " ")
+	if !*list && strings.TrimSpace(text) == "" {
+		fmt.Fprintln(stderr, `usage: dropbox-appender annotate [-path PATH | -date YYYY-MM-DD] "text" | -list`)
+		return 2
+	}
+	now := clock.Now()
+	day := now
+	if *date != "" {
+		d, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+			return 2
+		}
+		day = d
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	p := *filePath
+	if p == "" {
+		if p, err = journalPath(cfg, "", day); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+	}
+	client, err := connectClient(cfg, !*list)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if *list {
+		return runAnnotateWithClient(stdout, stderr, client, p, "")
+	}
+	value, err := formatAnnotation(now, expandShortcodes(text, cfg.Shortcodes))
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	return runAnnotateWithClient(stdout, stderr, client, p, value)
+}
+
+// runAnnotateWithClient adds value as the next annotation of the file at
+// p, or lists its annotations when value is empty.
+func runAnnotateWithClient(stdout, stderr io.Writer, client *DropboxClient, p, value string) int {
+	templateID, err := client.annotationTemplate()
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	fields, err := client.Annotations(p, templateID)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: reading annotations of %s: %v\n", p, err)
+		return 1
+	}
+	if value == "" {
+		for _, f := range fields {
+			fmt.Fprintln(stdout, f.Value)
+		}
+		return 0
+	}
+
+	used := map[string]bool{}
+	for _, f := range fields {
+		used[f.Name] = true
+	}
+	name := ""
+	for n := 1; n <= maxAnnotations && name == ""; n++ {
+		if !used[annotationField(n)] {
+			name = annotationField(n)
+		}
+	}
+	if name == "" {
+		fmt.Fprintf(stderr, "error: %s already has %d annotations, the most Dropbox allows\n", p, maxAnnotations)
+		return 1
+	}
+	if err := client.SetProperties(p, templateID, append(fields, propertyField{name, value})); err != nil {
+		fmt.Fprintf(stderr, "error: annotating %s: %v\n", p, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Annotated %s\n", p)
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// propertiesServer fakes the file properties endpoints for one account,
+// which starts without templates. Templates get IDs ptid:1, ptid:2, ...
+func propertiesServer(t *testing.T, props map[string][]propertyField) *httptest.Server {
+	var templates []string // names, by ID number
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg struct {
+			Path           string `json:"path"`
+			TemplateID     string `json:"template_id"`
+			Name           string `json:"name"`
+			PropertyGroups []struct {
+				Fields []propertyField `json:"fields"`
+			} `json:"property_groups"`
+		}
+		json.NewDecoder(r.Body).Decode(&arg)
+		switch r.URL.Path {
+		case "/2/file_properties/templates/list_for_user":
+			ids := []string{}
+			for i := range templates {
+				ids = append(ids, fmt.Sprintf("ptid:%d", i+1))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"template_ids": ids})
+		case "/2/file_properties/templates/get_for_user":
+			var n int
+			fmt.Sscanf(arg.TemplateID, "ptid:%d", &n)
+			json.NewEncoder(w).Encode(map[string]string{"name": templates[n-1]})
+		case "/2/file_properties/templates/add_for_user":
+			templates = append(templates, arg.Name)
+			fmt.Fprintf(w, `{"template_id":"ptid:%d"}`, len(templates))
+		case "/2/files/get_metadata":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"property_groups": []interface{}{map[string]interface{}{"template_id": "ptid:1", "fields": props[arg.Path]}},
+			})
+		case "/2/file_properties/properties/overlay_file":
+			props[arg.Path] = arg.PropertyGroups[0].Fields
+			w.Write([]byte(`null`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestRunAnnotateWithClient(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	props := map[string][]propertyField{}
+	srv := propertiesServer(t, props)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+
+	var stdout, stderr bytes.Buffer
+	for _, text := range []string{"reviewed section 2", "approved"} {
+		value, _ := formatAnnotation(now, text)
+		if code := runAnnotateWithClient(&stdout, &stderr, client, "/Shared/spec.md", value); code != 0 {
+			t.Fatalf("exit %d: %s", code, stderr.String())
+		}
+	}
+	want := []propertyField{
+		{"entry_01", "2025-01-15 14:30:45 reviewed section 2"},
+		{"entry_02", "2025-01-15 14:30:45 approved"},
+	}
+	if got := props["/Shared/spec.md"]; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("properties = %+v", got)
+	}
+
+	stdout.Reset()
+	if code := runAnnotateWithClient(&stdout, &stderr, client, "/Shared/spec.md", ""); code != 0 {
+		t.Fatalf("list: exit %d", code)
+	}
+	if stdout.String() != want[0].Value+"\n"+want[1].Value+"\n" {
+		t.Errorf("list = %q", stdout.String())
+	}
+}
+
+func TestFormatAnnotation_TooLong(t *testing.T) {
+	if _, err := formatAnnotation(time.Now(), strings.Repeat("x", maxAnnotationBytes)); err == nil {
+		t.Error("expected an error for an annotation over the size limit")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultAPICacheTTL is how long listing responses are reused by default.
+const defaultAPICacheTTL = 10 * time.Minute
+
+// cachedEndpoints are the read-only RPC endpoints whose responses are
+// cached. Listing a large journal folder takes many requests, and reindex
+// and setup walk it on every run.
+var cachedEndpoints = map[string]bool{
+	"/2/files/list_folder":          true,
+	"/2/files/list_folder/continue": true,
+	"/2/files/get_metadata":         true,
+}
+
+// writeEndpoints are the RPC endpoints that change files, after which
+// cached listings are out of date.
+var writeEndpoints = map[string]bool{
+	"/2/files/delete_v2": true,
+	"/2/files/move_v2":   true,
+	"/2/files/restore":   true,
+
+	"/2/file_properties/properties/overlay_file": true,
+}
+
+// apiCache stores RPC responses on disk for TTL, one file per request.
+// Every write this tool makes clears it (see invalidateAPICache); changes
+// made elsewhere show up once entries expire.
+type apiCache struct {
+	Dir   string
+	TTL   time.Duration
+	Scope string // identifies the account, so accounts never share entries
+}
+
+// cachedResponse is the on-disk form of a cache entry.
+type cachedResponse struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// apiCacheDir returns the directory of the API response cache.
+func apiCacheDir() string {
+	return filepath.Join(defaultCacheDir(), "api")
+}
+
+// newAPICache returns the cache configured in cfg, or nil when it is
+// disabled with an api_cache_ttl of 0.
+func newAPICache(cfg *Config) (*apiCache, error) {
+	ttl := defaultAPICacheTTL
+	if cfg.APICacheTTL != "" {
+		d, err := time.ParseDuration(cfg.APICacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		ttl = d
+	}
+	if ttl <= 0 {
+		return nil, nil
+	}
+	return &apiCache{Dir: apiCacheDir(), TTL: ttl, Scope: checksum([]byte(activeCredential(cfg)))}, nil
+}
+
+// file returns the cache file for a request.
+func (c *apiCache) file(endpoint string, payload []byte) string {
+	sum := sha256.Sum256([]byte(c.Scope + "\x00" + endpoint + "\x00" + string(payload)))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// get returns the cached response to a request if it is younger than TTL.
+func (c *apiCache) get(endpoint string, payload []byte) ([]byte, bool) {
+	data, err := os.ReadFile(c.file(endpoint, payload))
+	if err != nil {
+		return nil, false
+	}
+	var r cachedResponse
+	if json.Unmarshal(data, &r) != nil {
+		return nil, false
+	}
+	if age := time.Since(r.FetchedAt); age < 0 || age > c.TTL {
+		return nil, false
+	}
+	return r.Body, true
+}
+
+// put stores the response to a request. Failures only cost a refetch, so
+// they are ignored.
+func (c *apiCache) put(endpoint string, payload, body []byte) {
+	if !json.Valid(body) {
+		return
+	}
+	data, err := json.Marshal(cachedResponse{FetchedAt: time.Now(), Body: body})
+	if err != nil {
+		return
+	}
+	os.MkdirAll(c.Dir, 0700)
+	writeFileAtomic(c.file(endpoint, payload), data, 0600)
+}
+
+// invalidateAPICache drops every cached response after this tool changes a
+// file, whether or not the client making the change uses the cache.
+func invalidateAPICache() {
+	os.RemoveAll(apiCacheDir())
+}
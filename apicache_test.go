@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPICache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	lists := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/files/list_folder":
+			lists++
+			w.Write([]byte(`{"entries":[{".tag":"file","name":"20250115.md","path_display":"/Journal/20250115.md"}],"has_more":false}`))
+		case "/2/files/upload":
+			w.Write([]byte(`{"rev":"1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	cache := &apiCache{Dir: apiCacheDir(), TTL: time.Minute, Scope: "a"}
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL, Cache: cache}
+
+	list := func() {
+		t.Helper()
+		entries, err := client.ListFolder("/Journal", false, 0)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("ListFolder = %v, %v", entries, err)
+		}
+	}
+	list()
+	list()
+	if lists != 1 {
+		t.Errorf("second listing made a request: %d requests", lists)
+	}
+
+	// Another account doesn't see the entry.
+	client.Cache = &apiCache{Dir: apiCacheDir(), TTL: time.Minute, Scope: "b"}
+	list()
+	if lists != 2 {
+		t.Errorf("listing for another account was served from the cache")
+	}
+
+	// A write by this tool drops cached listings.
+	client.Cache = cache
+	if err := client.Upload("/Journal/20250116.md", "x"); err != nil {
+		t.Fatal(err)
+	}
+	list()
+	if lists != 3 {
+		t.Errorf("listing after an upload was served from the cache")
+	}
+
+	// Expired entries are refetched.
+	cache.TTL = -time.Second
+	list()
+	if lists != 4 {
+		t.Errorf("expired listing was served from the cache")
+	}
+}
+
+func TestNewAPICache(t *testing.T) {
+	if c, err := newAPICache(&Config{}); err != nil || c == nil || c.TTL != defaultAPICacheTTL {
+		t.Errorf("default = %+v, %v", c, err)
+	}
+	if c, err := newAPICache(&Config{APICacheTTL: "0"}); err != nil || c != nil {
+		t.Errorf("0 = %+v, %v", c, err)
+	}
+	if _, err := newAPICache(&Config{APICacheTTL: "soon"}); err == nil {
+		t.Error("expected an error for an invalid TTL")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// appFolder is the name of the app folder the token is scoped to, set from
+// the app_folder config by configureAppFolder; empty for Full Dropbox apps.
+var appFolder string
+
+// configureAppFolder applies the app_folder setting.
+func configureAppFolder(cfg *Config) {
+	appFolder = appFolderName(cfg.AppFolder)
+}
+
+// appFolderName returns the folder name of an app_folder setting, which may
+// be the name or the path, e.g. "Journal" or "/Apps/Journal".
+func appFolderName(s string) string {
+	name := strings.Trim(s, "/")
+	if len(name) > len("Apps/") && strings.EqualFold(name[:len("Apps/")], "Apps/") {
+		name = name[len("Apps/"):]
+	}
+	return name
+}
+
+// appFolderRoot returns where the app folder is in the user's Dropbox.
+func appFolderRoot() string {
+	return "/Apps/" + appFolder
+}
+
+// scopeToAppFolder turns a path as the user sees it in Dropbox into one the
+// API accepts: for an app folder app, paths are relative to the app folder,
+// so a leading /Apps/<name> is dropped. Other paths under /Apps can't be
+// reached and are refused rather than silently created inside the app
+// folder. For Full Dropbox apps p is returned as it is.
+func scopeToAppFolder(p string) (string, error) {
+	return scopePath(appFolder, p)
+}
+
+// scopePath is scopeToAppFolder for the app folder named folder.
+func scopePath(folder, p string) (string, error) {
+	if folder == "" {
+		return p, nil
+	}
+	root := "/Apps/" + folder
+	lower := strings.ToLower(p)
+	switch {
+	case lower == strings.ToLower(root):
+		return "/", nil
+	case strings.HasPrefix(lower, strings.ToLower(root)+"/"):
+		return p[len(root):], nil
+	case strings.HasPrefix(lower, "/apps/"):
+		return "", fmt.Errorf("%s is outside the app folder %s this app is limited to", p, root)
+	}
+	return p, nil
+}
+
+// dropboxPath returns where the file at the API path p is in the user's
+// Dropbox, e.g. for links to the Dropbox website.
+func dropboxPath(p string) string {
+	if appFolder == "" {
+		return p
+	}
+	return appFolderRoot() + p
+}
+
+// appsFolderVisible reports whether the token can see the /Apps folder, which
+// Full Dropbox apps can whenever the account has used an app folder app, and
+// app folder apps never can.
+func appsFolderVisible(client *DropboxClient) (bool, error) {
+	_, err := client.rpc("/2/files/get_metadata", map[string]string{"path": "/Apps"})
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// askAppFolder runs after `auth`: when the new token can't see /Apps, the
+// app may be limited to an app folder, so the user is asked for its name.
+// It returns the name, empty for Full Dropbox or when it can't tell.
+func askAppFolder(scanner *bufio.Scanner, stdout io.Writer, client *DropboxClient) string {
+	visible, err := appsFolderVisible(client)
+	if err != nil || visible {
+		return ""
+	}
+	fmt.Fprintln(stdout, "\nThis token can't see an /Apps folder, so the app may have \"App folder\" access.")
+	fmt.Fprint(stdout, "Name of its folder in Dropbox/Apps (empty for Full Dropbox): ")
+	if !scanner.Scan() {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(scanner.Text()), "/")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withAppFolder(t *testing.T, name string) {
+	saved := appFolder
+	appFolder = name
+	t.Cleanup(func() { appFolder = saved })
+}
+
+func TestScopeToAppFolder(t *testing.T) {
+	withAppFolder(t, "Journal")
+	for in, want := range map[string]string{
+		"/Apps/Journal/Daily/20250115.md": "/Daily/20250115.md",
+		"/apps/journal/Daily/20250115.md": "/Daily/20250115.md",
+		"/Apps/Journal":                   "/",
+		"/Daily/20250115.md":              "/Daily/20250115.md",
+	} {
+		if got, err := scopeToAppFolder(in); err != nil || got != want {
+			t.Errorf("scopeToAppFolder(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := scopeToAppFolder("/Apps/Other/note.md"); err == nil {
+		t.Error("expected an error for another app's folder")
+	}
+	if got := dropboxPath("/Daily/20250115.md"); got != "/Apps/Journal/Daily/20250115.md" {
+		t.Errorf("dropboxPath = %q", got)
+	}
+
+	p, err := renderPathTemplate("/Apps/Journal/{{.Year}}/{{.Date}}.md", time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC))
+	if err != nil || p != "/2025/20250115.md" {
+		t.Errorf("renderPathTemplate = %q, %v", p, err)
+	}
+}
+
+func TestScopeToAppFolder_FullDropbox(t *testing.T) {
+	withAppFolder(t, "")
+	if got, err := scopeToAppFolder("/Apps/Obsidian/Journal/note.md"); err != nil || got != "/Apps/Obsidian/Journal/note.md" {
+		t.Errorf("got %q, %v; want the path unchanged", got, err)
+	}
+}
+
+func TestAppFolderName(t *testing.T) {
+	for _, in := range []string{"Journal", "/Apps/Journal", "Apps/Journal/", "/apps/Journal"} {
+		if got := appFolderName(in); got != "Journal" {
+			t.Errorf("appFolderName(%q) = %q", in, got)
+		}
+	}
+}
+
+func TestAskAppFolder(t *testing.T) {
+	appsExist := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if appsExist {
+			w.Write([]byte(`{".tag": "folder", "name": "Apps"}`))
+			return
+		}
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+	}))
+	defer server.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: server.URL}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(strings.NewReader("/Journal/\n"))
+	if got := askAppFolder(scanner, &out, client); got != "Journal" {
+		t.Errorf("got %q, want Journal", got)
+	}
+	if !strings.Contains(out.String(), "App folder") {
+		t.Errorf("expected a prompt, got %q", out.String())
+	}
+
+	appsExist = true
+	out.Reset()
+	if got := askAppFolder(bufio.NewScanner(strings.NewReader("x\n")), &out, client); got != "" || out.Len() != 0 {
+		t.Errorf("expected no question for a Full Dropbox app, got %q and %q", got, out.String())
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+)
+
+// exitInterrupted is the exit code after a SIGINT/SIGTERM that arrived while
+// a write was in progress and was held until it finished.
+const exitInterrupted = 130
+
+// tempUploadPath returns a hidden sibling of p used to stage an atomic
+// upload, e.g. /Notes/.Note20250115.md.tmp-1736951445000000000.
+func tempUploadPath(p string, now time.Time) string {
+	return path.Join(path.Dir(p), fmt.Sprintf(".%s.tmp-%d", path.Base(p), now.UnixNano()))
+}
+
+// uploadAtomic writes data to p without ever leaving a truncated file: the
+// full content is first uploaded to a temporary sibling and then moved over
+// p. Dropbox's move_v2 can't overwrite, so on conflict the old file is
+// deleted and the move retried, leaving p briefly absent; if that second
+// move fails the deleted revision is restored. Either way the staged copy
+// is left in place and its path is included in the error.
+func uploadAtomic(client *DropboxClient, p string, data []byte) (string, error) {
+	tmp := tempUploadPath(p, time.Now())
+	if _, err := client.upload(tmp, data, "overwrite"); err != nil {
+		return "", err
+	}
+
+	rev, err := client.Move(tmp, p)
+	if errors.Is(err, ErrConflict) {
+		var old string
+		if old, err = client.DeleteRev(p); err == nil {
+			rev, err = client.Move(tmp, p)
+			if err != nil && old != "" {
+				if _, rerr := client.Restore(p, old); rerr != nil {
+					err = fmt.Errorf("%w; restoring %s to %s also failed: %v", err, p, old, rerr)
+				}
+			}
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("moving %s into place (new content kept there): %w", tmp, err)
+	}
+	return rev, nil
+}
+
+// holdSignals runs fn with SIGINT and SIGTERM held: a signal arriving
+// meanwhile is reported on stderr and fn is allowed to finish, so an append
+// is never cut off half way. It reports whether a signal was received.
+func holdSignals(stderr io.Writer, fn func()) bool {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(ch)
+
+	done := make(chan struct{})
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case sig := <-ch:
+			fmt.Fprintf(stderr, "received %v, finishing the current write before exiting\n", sig)
+			<-done
+			interrupted <- true
+		case <-done:
+			interrupted <- false
+		}
+	}()
+	fn()
+	close(done)
+	return <-interrupted
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// atomicServer is an in-memory Dropbox supporting upload, move_v2 (which
+// refuses to overwrite, like the real API), delete_v2 and restore of a
+// deleted file. It records the sequence of operations.
+func atomicServer(files map[string]string, ops *[]string) *httptest.Server {
+	return httptest.NewServer(atomicHandler(files, ops))
+}
+
+func atomicHandler(files map[string]string, ops *[]string) http.HandlerFunc {
+	deleted := map[string]string{}
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var arg struct {
+			Path     string `json:"path"`
+			Rev      string `json:"rev"`
+			FromPath string `json:"from_path"`
+			ToPath   string `json:"to_path"`
+		}
+		if h := r.Header.Get("Dropbox-API-Arg"); h != "" {
+			json.Unmarshal([]byte(h), &arg)
+		} else {
+			json.Unmarshal(body, &arg)
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/2/files/download"):
+			content, ok := files[arg.Path]
+			if !ok {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+				return
+			}
+			w.Write([]byte(content))
+		case strings.HasSuffix(r.URL.Path, "/2/files/upload"):
+			*ops = append(*ops, "upload "+arg.Path)
+			files[arg.Path] = string(body)
+			w.Write([]byte(`{"rev":"r1"}`))
+		case strings.HasSuffix(r.URL.Path, "/2/files/move_v2"):
+			*ops = append(*ops, "move "+arg.FromPath+" "+arg.ToPath)
+			if _, exists := files[arg.ToPath]; exists {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "to/conflict/file/.."}`))
+				return
+			}
+			files[arg.ToPath] = files[arg.FromPath]
+			delete(files, arg.FromPath)
+			w.Write([]byte(`{"metadata":{"rev":"r2"}}`))
+		case strings.HasSuffix(r.URL.Path, "/2/files/delete_v2"):
+			*ops = append(*ops, "delete "+arg.Path)
+			deleted["old"+arg.Path] = files[arg.Path]
+			delete(files, arg.Path)
+			w.Write([]byte(`{"metadata":{"rev":"old` + arg.Path + `"}}`))
+		case strings.HasSuffix(r.URL.Path, "/2/files/restore"):
+			*ops = append(*ops, "restore "+arg.Path+" "+arg.Rev)
+			files[arg.Path] = deleted[arg.Rev]
+			w.Write([]byte(`{"rev":"r3"}`))
+		}
+	}
+}
+
+func TestTempUploadPath(t *testing.T) {
+	got := tempUploadPath("/Notes/Note20250115.md", time.Unix(0, 42))
+	if got != "/Notes/.Note20250115.md.tmp-42" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestAppendToJournal_Atomic(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 09:00:00\nmorning\n"}
+	var ops []string
+	server := atomicServer(files, &ops)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", appendOptions{Atomic: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files[path] != "### 09:00:00\nmorning\n\n### 14:30:45\nafternoon\n" {
+		t.Errorf("unexpected content: %q", files[path])
+	}
+	if len(files) != 1 {
+		t.Errorf("temporary file left behind: %v", files)
+	}
+	if len(ops) != 4 || !strings.HasPrefix(ops[0], "upload /Notes/Journal/2025/01/.Note20250115.md.tmp-") ||
+		!strings.HasPrefix(ops[2], "delete "+path) {
+		t.Errorf("unexpected operations: %v", ops)
+	}
+}
+
+func TestAppendToJournal_AtomicRestoresOnFailedMove(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 09:00:00\nmorning\n"}
+	var ops []string
+	handler := atomicHandler(files, &ops)
+	moves := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/2/files/move_v2") {
+			if moves++; moves == 2 {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "from_lookup/not_found/."}`))
+				return
+			}
+		}
+		handler(w, r)
+	}))
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	_, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", appendOptions{Atomic: true})
+	if err == nil || !strings.Contains(err.Error(), ".Note20250115.md.tmp-") {
+		t.Fatalf("expected an error naming the staged copy, got %v", err)
+	}
+	if files[path] != "### 09:00:00\nmorning\n" {
+		t.Errorf("old content not restored: %q (ops %v)", files[path], ops)
+	}
+	if last := ops[len(ops)-1]; last != "restore "+path+" old"+path {
+		t.Errorf("expected a restore, got %v", ops)
+	}
+}
+
+func TestAppendToJournal_AtomicNewNote(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{}
+	var ops []string
+	server := atomicServer(files, &ops)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nfirst\n", appendOptions{Atomic: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files[path] != "### 14:30:45\nfirst\n" || len(ops) != 2 {
+		t.Errorf("expected upload and a single move, got %v (%v)", ops, files)
+	}
+}
+
+func TestHoldSignals(t *testing.T) {
+	var stderr bytes.Buffer
+	interrupted := holdSignals(&stderr, func() {
+		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		time.Sleep(50 * time.Millisecond)
+	})
+	if !interrupted || !strings.Contains(stderr.String(), "finishing the current write") {
+		t.Errorf("expected the signal to be held, got %v (stderr=%q)", interrupted, stderr.String())
+	}
+	if holdSignals(&stderr, func() {}) {
+		t.Error("expected no interruption")
+	}
+}
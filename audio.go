@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// audioAttachmentPath returns the Dropbox path for an attached voice memo:
+// audio-YYYYMMDD-HHMMSS plus the file's own extension, in folder.
+func audioAttachmentPath(folder, file string, now time.Time) string {
+	return fmt.Sprintf("%s/audio-%s%s", folder, now.Format("20060102-150405"), strings.ToLower(filepath.Ext(file)))
+}
+
+// relativeLink returns the path of target relative to the folder holding the
+// note at from, for use in markdown links.
+func relativeLink(from, target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(from)), filepath.FromSlash(target))
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
+
+// transcribe runs cmdline through sh to turn the audio file into text and
+// returns its trimmed stdout. The file is available as $AUDIO; a command
+// that doesn't use it gets the path as its last argument.
+func transcribe(cmdline, file string) (string, error) {
+	return runFileCommand("transcribe", cmdline, "AUDIO", file)
+}
+
+// runFileCommand runs cmdline through sh with file in the environment
+// variable env, appending "$env" as the last argument when the command
+// doesn't mention it, and returns its trimmed stdout. what names the
+// command in errors.
+func runFileCommand(what, cmdline, env, file string) (string, error) {
+	if !strings.Contains(cmdline, "$"+env) {
+		cmdline += ` "$` + env + `"`
+	}
+	cmd := exec.Command("sh", "-c", cmdline)
+	cmd.Env = append(os.Environ(), env+"="+file)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s command failed: %w: %s", what, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// attachAudio uploads the audio file as an attachment in folder and returns
+// the entry body for it: a link to the recording, preceded by text and
+// followed by the transcript when transcribeCmd is set.
+func attachAudio(client *DropboxClient, notePath, folder, file, transcribeCmd, text string, now time.Time) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading audio: %w", err)
+	}
+	var transcript string
+	if transcribeCmd != "" {
+		if transcript, err = transcribe(transcribeCmd, file); err != nil {
+			return "", err
+		}
+	}
+
+	attPath := audioAttachmentPath(folder, file, now)
+	if err := client.UploadBytes(attPath, data); err != nil {
+		return "", fmt.Errorf("uploading audio: %w", err)
+	}
+
+	var parts []string
+	if text != "" {
+		parts = append(parts, text)
+	}
+	parts = append(parts, fmt.Sprintf("🎙️ [%s](%s)", path.Base(attPath), relativeLink(notePath, attPath)))
+	if transcript != "" {
+		parts = append(parts, transcript)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAudioAttachmentPath(t *testing.T) {
+	got := audioAttachmentPath("/Notes/attachments", "/tmp/memo.M4A", time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC))
+	if got != "/Notes/attachments/audio-20250115-143045.m4a" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRelativeLink(t *testing.T) {
+	got := relativeLink("/Notes/Journal/2025/01/Note20250115.md", "/Notes/attachments/a.m4a")
+	if got != "../../../attachments/a.m4a" {
+		t.Errorf("got %q", got)
+	}
+	if got := relativeLink("/Daily/2025-01-15.md", "/Daily/a.m4a"); got != "a.m4a" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestTranscribe(t *testing.T) {
+	requireShell(t)
+	got, err := transcribe("echo transcript of", "/tmp/memo.m4a")
+	if err != nil || got != "transcript of /tmp/memo.m4a" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	got, err = transcribe(`basename "$AUDIO" .m4a`, "/tmp/memo.m4a")
+	if err != nil || got != "memo" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := transcribe("echo oops >&2; exit 1", "x"); err == nil {
+		t.Error("expected error from failing command")
+	}
+}
+
+func TestAttachAudio(t *testing.T) {
+	requireShell(t)
+	file := filepath.Join(t.TempDir(), "memo.m4a")
+	os.WriteFile(file, []byte("AUDIO"), 0600)
+
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+	body, err := attachAudio(client, "/Notes/Journal/2025/01/Note20250115.md", "/Notes/attachments", file, "echo hello world #", "Standup", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Standup\n\n🎙️ [audio-20250115-143045.m4a](../../../attachments/audio-20250115-143045.m4a)\n\nhello world"
+	if body != want {
+		t.Errorf("got %q, want %q", body, want)
+	}
+	if files["/Notes/attachments/audio-20250115-143045.m4a"] != "AUDIO" {
+		t.Errorf("audio not uploaded: %v", files)
+	}
+}
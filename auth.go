@@ -2,10 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
+	"os"
 	"strings"
 )
 
@@ -16,28 +17,38 @@ type tokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	TokenType    string `json:"token_type"`
+	AccountID    string `json:"account_id"`
 }
 
-// authorizeURL returns the Dropbox OAuth2 authorization URL.
-func authorizeURL(appKey string) string {
+// authorizeURL returns the Dropbox OAuth2 authorization URL. Without a
+// redirectURI Dropbox shows the code for the user to paste.
+func authorizeURL(appKey, redirectURI, state string) string {
 	params := url.Values{
 		"client_id":         {appKey},
 		"response_type":     {"code"},
 		"token_access_type": {"offline"},
 	}
+	if redirectURI != "" {
+		params.Set("redirect_uri", redirectURI)
+		params.Set("state", state)
+	}
 	return "https://www.dropbox.com/oauth2/authorize?" + params.Encode()
 }
 
 // exchangeCode exchanges an authorization code for access + refresh tokens.
-func exchangeCode(tokenURL, appKey, appSecret, code string) (*tokenResponse, error) {
+// redirectURI must be the one the code was issued for, if any.
+func exchangeCode(tokenURL, appKey, appSecret, code, redirectURI string) (*tokenResponse, error) {
 	data := url.Values{
 		"grant_type":    {"authorization_code"},
 		"code":          {code},
 		"client_id":     {appKey},
 		"client_secret": {appSecret},
 	}
+	if redirectURI != "" {
+		data.Set("redirect_uri", redirectURI)
+	}
 
-	resp, err := http.PostForm(tokenURL, data)
+	resp, err := httpClient.PostForm(tokenURL, data)
 	if err != nil {
 		return nil, fmt.Errorf("token request: %w", err)
 	}
@@ -59,8 +70,9 @@ func exchangeCode(tokenURL, appKey, appSecret, code string) (*tokenResponse, err
 	return &result, nil
 }
 
-// refreshAccessToken uses a refresh token to get a fresh short-lived access token.
-func refreshAccessToken(tokenURL, appKey, appSecret, refreshToken string) (string, error) {
+// refreshAccessToken uses a refresh token to get a fresh short-lived access
+// token. The response carries a new refresh token when Dropbox rotates it.
+func refreshAccessToken(tokenURL, appKey, appSecret, refreshToken string) (*tokenResponse, error) {
 	data := url.Values{
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {refreshToken},
@@ -68,24 +80,86 @@ func refreshAccessToken(tokenURL, appKey, appSecret, refreshToken string) (strin
 		"client_secret": {appSecret},
 	}
 
-	resp, err := http.PostForm(tokenURL, data)
+	resp, err := httpClient.PostForm(tokenURL, data)
 	if err != nil {
-		return "", fmt.Errorf("refresh request: %w", err)
+		return nil, fmt.Errorf("refresh request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("reading response: %w", err)
+		return nil, fmt.Errorf("reading response: %w", err)
 	}
 
+	if resp.StatusCode == 400 || resp.StatusCode == 401 {
+		var oauthErr struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(body, &oauthErr)
+		if oauthErr.Error == "invalid_grant" {
+			return nil, fmt.Errorf("%w: the refresh token was revoked, or was already used up after Dropbox rotated it (invalid_grant)", ErrAuth)
+		}
+		return nil, fmt.Errorf("%w: token refresh failed (status %d): %s", ErrAuth, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("token refresh failed (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		// An APIError lets the fallback tell a server error from a rejection.
+		return nil, fmt.Errorf("token refresh failed: %w", &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))})
 	}
 
 	var result tokenResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("parsing response: %w", err)
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return &result, nil
+}
+
+// refreshToken exchanges cfg's refresh token for an access token at
+// tokenURL. When Dropbox rotates the refresh token, the new one is saved to
+// the config file straight away, since the old one may be single-use. A
+// rejected token is retried once if the config file holds a different one,
+// which happens when another run rotated it after cfg was loaded.
+func refreshToken(cfg *Config, tokenURL string) (string, error) {
+	result, err := refreshAccessToken(tokenURL, cfg.AppKey, cfg.AppSecret, cfg.RefreshToken)
+	if errors.Is(err, ErrAuth) && cfg.path != "" && os.Getenv("DROPBOX_REFRESH_TOKEN") == "" {
+		if onDisk, lerr := readConfigFile(cfg.path); lerr == nil && onDisk.RefreshToken != "" && onDisk.RefreshToken != cfg.RefreshToken {
+			cfg.RefreshToken, cfg.AccountCredential = onDisk.RefreshToken, onDisk.AccountCredential
+			result, err = refreshAccessToken(tokenURL, cfg.AppKey, cfg.AppSecret, cfg.RefreshToken)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if result.RefreshToken != "" && result.RefreshToken != cfg.RefreshToken {
+		if err := saveRotatedToken(cfg, result.RefreshToken); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: Dropbox issued a new refresh token but it could not be saved: %v\n", err)
+			fmt.Fprintln(os.Stderr, "  The current one may stop working; if the next run fails, run: dropbox-appender auth")
+		}
 	}
 	return result.AccessToken, nil
 }
+
+// saveRotatedToken records a rotated refresh token in cfg and in its config
+// file. The file is re-read rather than cfg written back, so env var
+// overrides never end up on disk. A token supplied by DROPBOX_REFRESH_TOKEN
+// can't be updated, so the user is told to.
+func saveRotatedToken(cfg *Config, token string) error {
+	// The old token's account was verified; the rotated one belongs to it.
+	rotatedFromVerified := cfg.AccountCredential == checksum([]byte(cfg.RefreshToken))
+	cfg.RefreshToken = token
+	if rotatedFromVerified {
+		cfg.AccountCredential = checksum([]byte(token))
+	}
+	if os.Getenv("DROPBOX_REFRESH_TOKEN") != "" {
+		return errors.New("it came from DROPBOX_REFRESH_TOKEN; update the variable with the token from a new `dropbox-appender auth`")
+	}
+	if cfg.path == "" {
+		return nil
+	}
+	return updateConfigFile(cfg.path, func(onDisk *Config) error {
+		onDisk.RefreshToken = token
+		if rotatedFromVerified {
+			onDisk.AccountCredential = cfg.AccountCredential
+		}
+		return nil
+	})
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestAuthorizeURL(t *testing.T) {
-	u := authorizeURL("myappkey")
+	u := authorizeURL("myappkey", "", "")
 	parsed, err := url.Parse(u)
 	if err != nil {
 		t.Fatalf("invalid URL: %v", err)
@@ -51,7 +56,7 @@ func TestExchangeCode_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	result, err := exchangeCode(server.URL, "app_key", "app_secret", "test_code")
+	result, err := exchangeCode(server.URL, "app_key", "app_secret", "test_code", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -70,7 +75,7 @@ func TestExchangeCode_Error(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_, err := exchangeCode(server.URL, "key", "secret", "bad_code")
+	_, err := exchangeCode(server.URL, "key", "secret", "bad_code", "")
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -90,12 +95,12 @@ func TestRefreshAccessToken_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	token, err := refreshAccessToken(server.URL, "key", "secret", "my_refresh")
+	result, err := refreshAccessToken(server.URL, "key", "secret", "my_refresh")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if token != "fresh_token" {
-		t.Errorf("expected fresh_token, got %s", token)
+	if result.AccessToken != "fresh_token" {
+		t.Errorf("expected fresh_token, got %s", result.AccessToken)
 	}
 }
 
@@ -111,3 +116,85 @@ func TestRefreshAccessToken_Error(t *testing.T) {
 		t.Fatal("expected error")
 	}
 }
+
+// rotatingTokenServer accepts each refresh token once and answers with a
+// new one, like Dropbox with refresh token rotation enabled.
+func rotatingTokenServer(t *testing.T, valid map[string]bool) *httptest.Server {
+	t.Helper()
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		old := r.FormValue("refresh_token")
+		if !valid[old] {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error": "invalid_grant", "error_description": "refresh token is invalid"}`))
+			return
+		}
+		delete(valid, old)
+		n++
+		next := fmt.Sprintf("refresh-%d", n+1)
+		valid[next] = true
+		fmt.Fprintf(w, `{"access_token": "access-%d", "refresh_token": %q}`, n, next)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRefreshToken_SavesRotatedToken(t *testing.T) {
+	t.Setenv("DROPBOX_REFRESH_TOKEN", "")
+	t.Setenv("DROPBOX_APP_SECRET", "from-env")
+	srv := rotatingTokenServer(t, map[string]bool{"refresh-1": true})
+	path := filepath.Join(t.TempDir(), "config.json")
+	saveConfig(path, &Config{AppKey: "key", RefreshToken: "refresh-1", AccountCredential: checksum([]byte("refresh-1"))})
+
+	cfg, err := loadConfig(path, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := refreshToken(cfg, srv.URL)
+	if err != nil || token != "access-1" {
+		t.Fatalf("refreshToken = %q, %v", token, err)
+	}
+
+	saved, _ := readConfigFile(path)
+	if saved.RefreshToken != "refresh-2" {
+		t.Errorf("saved refresh token = %q, want refresh-2", saved.RefreshToken)
+	}
+	if saved.AccountCredential != checksum([]byte("refresh-2")) {
+		t.Error("account credential not updated for the rotated token")
+	}
+	if saved.AppSecret != "" {
+		t.Errorf("env override written to the config: app_secret = %q", saved.AppSecret)
+	}
+
+	// The next run uses the rotated token.
+	cfg, _ = loadConfig(path, io.Discard)
+	if token, err := refreshToken(cfg, srv.URL); err != nil || token != "access-2" {
+		t.Errorf("second refresh = %q, %v", token, err)
+	}
+}
+
+func TestRefreshToken_PicksUpTokenRotatedByAnotherRun(t *testing.T) {
+	t.Setenv("DROPBOX_REFRESH_TOKEN", "")
+	srv := rotatingTokenServer(t, map[string]bool{"refresh-1": true})
+	path := filepath.Join(t.TempDir(), "config.json")
+	saveConfig(path, &Config{AppKey: "key", AppSecret: "secret", RefreshToken: "refresh-1"})
+
+	stale, _ := loadConfig(path, io.Discard)
+	other, _ := loadConfig(path, io.Discard)
+	if _, err := refreshToken(other, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	// stale still holds the used-up refresh-1; the file has refresh-2.
+	if token, err := refreshToken(stale, srv.URL); err != nil || token != "access-2" {
+		t.Errorf("refreshToken = %q, %v; want a retry with the saved token", token, err)
+	}
+}
+
+func TestRefreshAccessToken_InvalidGrant(t *testing.T) {
+	srv := rotatingTokenServer(t, map[string]bool{})
+	_, err := refreshAccessToken(srv.URL, "key", "secret", "used-up")
+	if !errors.Is(err, ErrAuth) || !strings.Contains(err.Error(), "already used up") {
+		t.Errorf("err = %v, want an ErrAuth explaining invalid_grant", err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// authRedirectTimeout is how long auth waits for the browser to come back
+// to the loopback server.
+const authRedirectTimeout = 5 * time.Minute
+
+// authFlow is how `auth` gets the authorization code: pasted by the user,
+// or received by a loopback server Dropbox redirects the browser to.
+type authFlow struct {
+	// Listen is the address the redirect is received on, and RedirectURI
+	// the URI Dropbox sends the browser to, registered for the app in the
+	// App Console. Both are empty when the code is pasted.
+	Listen      string
+	RedirectURI string
+	// NoBrowser only prints the authorization URL.
+	NoBrowser bool
+}
+
+// newAuthFlow checks the auth flags and fills in what they imply: -listen
+// alone redirects to http://localhost:PORT/callback, and -redirect-uri
+// alone listens on 127.0.0.1 at the URI's port, as when a port is forwarded
+// over SSH.
+func newAuthFlow(listen, redirectURI string, noBrowser bool) (authFlow, error) {
+	f := authFlow{Listen: listen, RedirectURI: redirectURI, NoBrowser: noBrowser}
+	if listen != "" {
+		_, port, err := net.SplitHostPort(listen)
+		if err != nil {
+			return f, fmt.Errorf("invalid -listen %q: expected HOST:PORT", listen)
+		}
+		if redirectURI == "" {
+			f.RedirectURI = "http://localhost:" + port + "/callback"
+		}
+	}
+	if redirectURI != "" {
+		u, err := url.Parse(redirectURI)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return f, fmt.Errorf("invalid -redirect-uri %q: expected an http(s) URL", redirectURI)
+		}
+		if listen == "" {
+			if u.Port() == "" {
+				return f, fmt.Errorf("-redirect-uri %q has no port to listen on; pass -listen too", redirectURI)
+			}
+			f.Listen = "127.0.0.1:" + u.Port()
+		}
+	}
+	return f, nil
+}
+
+// authorize shows the authorization URL, opening it in a browser unless
+// NoBrowser, and returns the code Dropbox issues.
+func (f authFlow) authorize(out io.Writer, in *bufio.Scanner, appKey string) (string, error) {
+	var ln net.Listener
+	var state string
+	if f.RedirectURI != "" {
+		var err error
+		if ln, err = net.Listen("tcp", f.Listen); err != nil {
+			return "", fmt.Errorf("listening for the redirect: %w", err)
+		}
+		defer ln.Close()
+		b := make([]byte, 16)
+		rand.Read(b)
+		state = hex.EncodeToString(b)
+	}
+
+	u := authorizeURL(appKey, f.RedirectURI, state)
+	fmt.Fprintln(out, tr("1. Open this URL in your browser:"))
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "  ", u)
+	fmt.Fprintln(out)
+	if !f.NoBrowser {
+		openBrowser(u) // the URL is printed in case this fails
+	}
+
+	if ln == nil {
+		fmt.Fprint(out, tr("2. Enter the authorization code: "))
+		in.Scan()
+		code := strings.TrimSpace(in.Text())
+		if code == "" {
+			return "", errors.New(tr("no code entered"))
+		}
+		return code, nil
+	}
+	fmt.Fprintf(out, "2. Approve access; waiting for the redirect to %s on %s...\n", f.RedirectURI, ln.Addr())
+	return receiveCode(ln, state, authRedirectTimeout)
+}
+
+// receiveCode serves the redirect on ln and returns its authorization code
+// once a request with the expected state arrives.
+func receiveCode(ln net.Listener, state string, timeout time.Duration) (string, error) {
+	type result struct {
+		code string
+		err  error
+	}
+	done := make(chan result, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") == "" {
+			http.NotFound(w, r) // e.g. /favicon.ico
+			return
+		}
+		if q.Get("state") != state {
+			http.Error(w, "state mismatch: start again with dropbox-appender auth", http.StatusBadRequest)
+			return
+		}
+		res := result{code: q.Get("code")}
+		if e := q.Get("error"); e != "" || res.code == "" {
+			res.err = fmt.Errorf("authorization failed: %s %s", e, q.Get("error_description"))
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "dropbox-appender is authorized. You can close this tab.")
+		}
+		select {
+		case done <- res:
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	select {
+	case res := <-done:
+		return res.code, res.err
+	case <-time.After(timeout):
+		return "", fmt.Errorf("no redirect arrived within %v", timeout)
+	}
+}
+
+// openBrowser opens u in the default browser, without waiting for it.
+func openBrowser(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
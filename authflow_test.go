@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAuthFlow(t *testing.T) {
+	tests := []struct {
+		listen, redirect      string
+		wantListen, wantRedir string
+		wantErr               bool
+	}{
+		{"", "", "", "", false},
+		{"127.0.0.1:53682", "", "127.0.0.1:53682", "http://localhost:53682/callback", false},
+		{"", "http://localhost:8080/cb", "127.0.0.1:8080", "http://localhost:8080/cb", false},
+		{"0.0.0.0:9000", "https://dev.example.com/auth", "0.0.0.0:9000", "https://dev.example.com/auth", false},
+		{"", "https://dev.example.com/auth", "", "", true},
+		{"53682", "", "", "", true},
+		{"", "localhost:8080", "", "", true},
+	}
+	for _, tt := range tests {
+		f, err := newAuthFlow(tt.listen, tt.redirect, false)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q %q: err = %v", tt.listen, tt.redirect, err)
+			continue
+		}
+		if !tt.wantErr && (f.Listen != tt.wantListen || f.RedirectURI != tt.wantRedir) {
+			t.Errorf("%q %q: got %q %q", tt.listen, tt.redirect, f.Listen, f.RedirectURI)
+		}
+	}
+}
+
+func TestAuthorize_PastedCode(t *testing.T) {
+	var out bytes.Buffer
+	f := authFlow{NoBrowser: true}
+	code, err := f.authorize(&out, bufio.NewScanner(strings.NewReader(" abc123 \n")), "key")
+	if err != nil || code != "abc123" {
+		t.Errorf("got %q, %v", code, err)
+	}
+	if !strings.Contains(out.String(), "https://www.dropbox.com/oauth2/authorize?") || strings.Contains(out.String(), "redirect_uri") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
+
+func TestReceiveCode(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	base := "http://" + ln.Addr().String() + "/callback?"
+
+	done := make(chan struct{})
+	var code string
+	var recvErr error
+	go func() {
+		code, recvErr = receiveCode(ln, "s3cret", 5*time.Second)
+		close(done)
+	}()
+
+	for _, q := range []url.Values{{}, {"state": {"forged"}, "code": {"evil"}}} {
+		resp, err := http.Get(base + q.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 400 {
+			t.Errorf("%v: status %d", q, resp.StatusCode)
+		}
+	}
+	resp, err := http.Get(base + url.Values{"state": {"s3cret"}, "code": {"good"}}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	<-done
+	if recvErr != nil || code != "good" {
+		t.Errorf("got %q, %v", code, recvErr)
+	}
+}
+
+func TestReceiveCode_Denied(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer ln.Close()
+	go http.Get("http://" + ln.Addr().String() + "/?state=s&error=access_denied")
+	if _, err := receiveCode(ln, "s", 5*time.Second); err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Errorf("err = %v", err)
+	}
+}
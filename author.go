@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultAuthor returns $USER@hostname, the author used when attribution is
+// enabled without a name.
+func defaultAuthor() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("USERNAME")
+	}
+	if user == "" {
+		user = "unknown"
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return user
+	}
+	return user + "@" + host
+}
+
+// attributeEntry appends " — author" to a formatted entry's "### HH:MM:SS"
+// header so entries in a shared journal record who wrote them. Entries
+// without a header (e.g. -no-timestamp) are returned unchanged.
+func attributeEntry(entry, author string) string {
+	author = strings.Join(strings.Fields(author), " ")
+	if !strings.HasPrefix(entry, "### ") || author == "" {
+		return entry
+	}
+	header, rest, _ := strings.Cut(entry, "\n")
+	return header + " — " + author + "\n" + rest
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttributeEntry(t *testing.T) {
+	got := attributeEntry("### 14:30:45\nHad a great meeting\n", "alice@laptop")
+	if got != "### 14:30:45 — alice@laptop\nHad a great meeting\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := attributeEntry("plain\n", "alice"); got != "plain\n" {
+		t.Errorf("entries without a header should be unchanged, got %q", got)
+	}
+}
+
+func TestDefaultAuthor(t *testing.T) {
+	t.Setenv("USER", "alice")
+	if got := defaultAuthor(); !strings.HasPrefix(got, "alice") {
+		t.Errorf("expected author to start with $USER, got %q", got)
+	}
+}
+
+func TestParseEntries_Author(t *testing.T) {
+	content := attributeEntry(numberEntry("### 14:30:45\nshared note\n", 2, "note-2"), "bob@desk") +
+		"\n### 15:00:00\nmine\n"
+	entries := parseEntries(content)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.Number != 2 || e.Time != "14:30:45" || e.Author != "bob@desk" || e.ID != "note-2" || e.Text != "shared note" {
+		t.Errorf("unexpected attributed entry: %+v", e)
+	}
+	if entries[1].Author != "" {
+		t.Errorf("expected unattributed entry, got %+v", entries[1])
+	}
+}
+
+func TestAppendOptionsFromConfig_Author(t *testing.T) {
+	t.Setenv("USER", "alice")
+	empty, named := "", "bob"
+	for _, c := range []struct {
+		author *string
+		want   string
+	}{{nil, ""}, {&named, "bob"}} {
+		opts, err := appendOptionsFromConfig(&Config{Author: c.author})
+		if err != nil || opts.Author != c.want {
+			t.Errorf("author %v: got %q, %v; want %q", c.author, opts.Author, err, c.want)
+		}
+	}
+	opts, _ := appendOptionsFromConfig(&Config{Author: &empty})
+	if !strings.HasPrefix(opts.Author, "alice") {
+		t.Errorf("empty author should default to $USER@hostname, got %q", opts.Author)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxFinishBatch is how many files one files/upload_session/finish_batch
+// call may commit.
+const maxFinishBatch = 1000
+
+// minBatchNotes is how many notes a flush writes before their uploads are
+// batched. A batch takes a request more than uploading each note, which
+// only pays off once separate writes start to be throttled.
+const minBatchNotes = 5
+
+// batchPollInterval is how often the status of a batch commit is checked,
+// and batchPollTimeout how long it is waited for.
+var (
+	batchPollInterval = 500 * time.Millisecond
+	batchPollTimeout  = 2 * time.Minute
+)
+
+// uploadBatch collects the uploads of a bulk operation, such as flushing
+// the queue, to commit them together. Dropbox serializes writes within an
+// account, so committing many files in one finish_batch call is much less
+// likely to hit too_many_write_operations than uploading them one by one.
+type uploadBatch struct {
+	files []batchFile
+}
+
+// batchFile is an upload waiting in a batch. done is called with the
+// result once the batch is committed and returns the error to report.
+type batchFile struct {
+	path string
+	data []byte
+	done func(rev string, err error) error
+}
+
+// add queues data to be written to path, overwriting it.
+func (b *uploadBatch) add(path string, data []byte, done func(rev string, err error) error) {
+	b.files = append(b.files, batchFile{path, data, done})
+}
+
+// commit uploads the queued files, each in its own upload session, and
+// commits them with files/upload_session/finish_batch, waiting for the
+// job to complete. Each file's done is called with its outcome; commit
+// returns the errors they returned by path. The batch is empty afterwards.
+func (b *uploadBatch) commit(client *DropboxClient) map[string]error {
+	files := b.files
+	b.files = nil
+	errs := map[string]error{}
+	for len(files) > 0 {
+		chunk := files[:min(len(files), maxFinishBatch)]
+		files = files[len(chunk):]
+
+		revs, err := client.uploadBatch(chunk)
+		for i, f := range chunk {
+			fileErr := err
+			if fileErr == nil {
+				fileErr = revs[i].err
+			}
+			if err := f.done(revs[i].rev, fileErr); err != nil {
+				errs[f.path] = err
+			}
+		}
+	}
+	return errs
+}
+
+// batchResult is the outcome of one file of a batch commit.
+type batchResult struct {
+	rev string
+	err error
+}
+
+// uploadBatch writes files with one upload session each and commits them
+// in a single finish_batch call. It returns a result for each file; the
+// error is for the batch as a whole.
+func (c *DropboxClient) uploadBatch(files []batchFile) ([]batchResult, error) {
+	results := make([]batchResult, len(files))
+	type commitEntry struct {
+		Cursor map[string]interface{} `json:"cursor"`
+		Commit map[string]interface{} `json:"commit"`
+	}
+	var entries []commitEntry
+	var index []int // entries[i] commits files[index[i]]
+	for i, f := range files {
+		session, err := c.startUploadSession(f.data)
+		if err != nil {
+			results[i].err = fmt.Errorf("upload request: %w", err)
+			continue
+		}
+		entries = append(entries, commitEntry{
+			Cursor: map[string]interface{}{"session_id": session, "offset": len(f.data)},
+			Commit: map[string]interface{}{"path": f.path, "mode": "overwrite", "mute": c.muted(f.path)},
+		})
+		index = append(index, i)
+	}
+	if len(entries) == 0 {
+		return results, nil
+	}
+	defer invalidateAPICache()
+
+	body, err := c.rpc("/2/files/upload_session/finish_batch", map[string]interface{}{"entries": entries})
+	if err != nil {
+		return results, err
+	}
+	status, err := parseBatchStatus(body)
+	if err != nil {
+		return results, err
+	}
+	job := status.AsyncJobID
+	deadline := time.Now().Add(batchPollTimeout)
+	for status.Tag != "complete" {
+		switch {
+		case status.Tag != "async_job_id" && status.Tag != "in_progress":
+			return results, fmt.Errorf("finish_batch: %s", status.Tag)
+		case job == "":
+			return results, errors.New("finish_batch: no job to wait for")
+		case time.Now().After(deadline):
+			return results, errors.New("finish_batch: timed out waiting for the job to complete")
+		}
+		time.Sleep(batchPollInterval)
+		body, err = c.rpc("/2/files/upload_session/finish_batch/check", map[string]string{"async_job_id": job})
+		if err != nil {
+			return results, err
+		}
+		if status, err = parseBatchStatus(body); err != nil {
+			return results, err
+		}
+	}
+	if len(status.Entries) != len(entries) {
+		return results, fmt.Errorf("finish_batch: %d results for %d files", len(status.Entries), len(entries))
+	}
+	for i, e := range status.Entries {
+		r := &results[index[i]]
+		if e.Tag == "success" {
+			r.rev = e.Rev
+			continue
+		}
+		r.err = batchFailure(e.Failure)
+	}
+	return results, nil
+}
+
+// batchStatus is the response of finish_batch and finish_batch/check.
+type batchStatus struct {
+	Tag        string `json:".tag"`
+	AsyncJobID string `json:"async_job_id"`
+	Entries    []struct {
+		Tag     string          `json:".tag"`
+		Rev     string          `json:"rev"`
+		Failure json.RawMessage `json:"failure"`
+	} `json:"entries"`
+}
+
+// parseBatchStatus parses a finish_batch or finish_batch/check response.
+func parseBatchStatus(body []byte) (batchStatus, error) {
+	var s batchStatus
+	if err := json.Unmarshal(body, &s); err != nil {
+		return s, fmt.Errorf("parsing finish_batch response: %w", err)
+	}
+	return s, nil
+}
+
+// batchFailure turns the failure of a batch entry into an error, with the
+// nested tags joined like an error_summary (e.g. path/insufficient_space)
+// so ErrConflict and the other sentinel errors match it.
+func batchFailure(failure json.RawMessage) error {
+	var tags []string
+	for {
+		var v map[string]json.RawMessage
+		if json.Unmarshal(failure, &v) != nil {
+			break
+		}
+		var tag string
+		if json.Unmarshal(v[".tag"], &tag) != nil || tag == "" {
+			break
+		}
+		tags = append(tags, tag)
+		failure = v[tag]
+	}
+	summary := strings.Join(tags, "/") + "/"
+	return newAPIError(&http.Response{StatusCode: http.StatusConflict, Header: http.Header{}},
+		[]byte(fmt.Sprintf(`{"error_summary": %q}`, summary)))
+}
+
+// startUploadSession uploads data in a new upload session, closed so it
+// can be committed with finish_batch, and returns the session ID.
+func (c *DropboxClient) startUploadSession(data []byte) (string, error) {
+	resp, body, err := c.content("/2/files/upload_session/start", map[string]bool{"close": true}, data, nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", newAPIError(resp, body)
+	}
+	var result struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.SessionID == "" {
+		return "", fmt.Errorf("upload_session/start returned no session ID")
+	}
+	return result.SessionID, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// batchServer is an in-memory Dropbox that commits uploads with
+// finish_batch as an async job, refusing paths in full.
+func batchServer(t *testing.T, files map[string]string, full map[string]bool, calls map[string]int) *httptest.Server {
+	sessions := map[string]string{}
+	var pending []byte
+	checks := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.TrimPrefix(r.URL.Path, "/2/files/")
+		calls[endpoint]++
+		switch endpoint {
+		case "download":
+			var arg struct {
+				Path string `json:"path"`
+			}
+			json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+			content, ok := files[arg.Path]
+			if !ok {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+				return
+			}
+			w.Write([]byte(content))
+		case "upload_session/start":
+			if r.Header.Get("Dropbox-API-Arg") != `{"close":true}` {
+				t.Errorf("session not closed: %s", r.Header.Get("Dropbox-API-Arg"))
+			}
+			body, _ := io.ReadAll(r.Body)
+			id := fmt.Sprintf("s%d", len(sessions))
+			sessions[id] = string(body)
+			fmt.Fprintf(w, `{"session_id": %q}`, id)
+		case "upload_session/finish_batch":
+			pending, _ = io.ReadAll(r.Body)
+			w.Write([]byte(`{".tag": "async_job_id", "async_job_id": "job1"}`))
+		case "upload_session/finish_batch/check":
+			if checks++; checks == 1 {
+				w.Write([]byte(`{".tag": "in_progress"}`))
+				return
+			}
+			var req struct {
+				Entries []struct {
+					Cursor struct {
+						SessionID string `json:"session_id"`
+						Offset    int    `json:"offset"`
+					} `json:"cursor"`
+					Commit struct {
+						Path string `json:"path"`
+						Mode string `json:"mode"`
+					} `json:"commit"`
+				} `json:"entries"`
+			}
+			json.Unmarshal(pending, &req)
+			var results []string
+			for i, e := range req.Entries {
+				data := sessions[e.Cursor.SessionID]
+				if e.Cursor.Offset != len(data) || e.Commit.Mode != "overwrite" {
+					t.Errorf("bad commit %+v", e)
+				}
+				if full[e.Commit.Path] {
+					results = append(results, `{".tag": "failure", "failure": {".tag": "path", "path": {".tag": "insufficient_space"}}}`)
+					continue
+				}
+				files[e.Commit.Path] = data
+				results = append(results, fmt.Sprintf(`{".tag": "success", "rev": "r%d"}`, i))
+			}
+			fmt.Fprintf(w, `{".tag": "complete", "entries": [%s]}`, strings.Join(results, ","))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			w.WriteHeader(404)
+		}
+	}))
+}
+
+func TestFlushQueue_CommitsManyNotesInABatch(t *testing.T) {
+	batchPollInterval = 0
+	defer func() { batchPollInterval = 500 * time.Millisecond }()
+
+	files := map[string]string{"/J/20250110.md": "### 08:00:00\nexisting\n"}
+	full := map[string]bool{"/J/20250112.md": true}
+	calls := map[string]int{}
+	server := batchServer(t, files, full, calls)
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	for day := 10; day <= 14; day++ {
+		enqueueEntry(dir, fmt.Sprintf("/J/202501%d.md", day), fmt.Sprintf("### 09:00:00\nday %d\n", day), now.Add(time.Duration(day)))
+	}
+
+	client := &DropboxClient{Token: "tok", BaseURL: server.URL}
+	var stderr bytes.Buffer
+	n, err := flushQueue(client, dir, appendOptions{}, &stderr)
+	if err != nil || n != 4 {
+		t.Fatalf("expected 4 entries flushed, got %d, %v: %s", n, err, stderr.String())
+	}
+	if calls["upload_session/finish_batch"] != 1 || calls["upload_session/start"] != 5 || calls["upload"] != 0 {
+		t.Errorf("expected 5 sessions committed in one batch, got %v", calls)
+	}
+	if want := "### 08:00:00\nexisting\n\n### 09:00:00\nday 10\n"; files["/J/20250110.md"] != want {
+		t.Errorf("got %q, want %q", files["/J/20250110.md"], want)
+	}
+	if files["/J/20250114.md"] != "### 09:00:00\nday 14\n" {
+		t.Errorf("got %q", files["/J/20250114.md"])
+	}
+	if !strings.Contains(stderr.String(), "warning: flushing 1 entries to /J/20250112.md: uploading journal: ") {
+		t.Errorf("expected the failed note to be reported, got %q", stderr.String())
+	}
+	queue, _ := loadQueue(dir, io.Discard)
+	if len(queue) != 1 || queue[0].Path != "/J/20250112.md" {
+		t.Errorf("expected only the failed entry to stay queued, got %+v", queue)
+	}
+}
+
+func TestBatchFailure(t *testing.T) {
+	err := batchFailure(json.RawMessage(`{".tag": "path", "path": {".tag": "conflict", "conflict": {".tag": "file"}}}`))
+	if !strings.Contains(err.Error(), "path/conflict/file/") {
+		t.Errorf("got %v", err)
+	}
+	if err := batchFailure(json.RawMessage(`{".tag": "path", "path": {".tag": "insufficient_space"}}`)); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("expected an insufficient space error, got %v", err)
+	}
+}
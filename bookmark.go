@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// linksHeading is the section bookmarks are collected under.
+const linksHeading = "## Links"
+
+// defaultBookmarkTemplate renders one bookmark as a list item.
+const defaultBookmarkTemplate = `- [{{.Title}}]({{.URL}}){{if .Description}} — {{.Description}}{{end}}{{if .Comment}}` + "\n" + `  {{.Comment}}{{end}}`
+
+// maxPageBytes bounds how much of a page is read looking for metadata.
+const maxPageBytes = 1 << 20
+
+var (
+	titlePattern   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaTagPattern = regexp.MustCompile(`(?is)<(meta|link)\s[^>]*>`)
+	attrPattern    = regexp.MustCompile(`(?s)([\w:-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	spacePattern   = regexp.MustCompile(`\s+`)
+)
+
+// bookmark is the metadata of a page, available to the bookmark template.
+type bookmark struct {
+	URL         string // canonical URL when the page declares one
+	Title       string
+	Description string
+	Comment     string
+	Time        string // HH:MM
+}
+
+// tagAttrs returns the lower-cased attributes of a single HTML tag.
+func tagAttrs(tag string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range attrPattern.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(strings.Trim(m[2], `"'`))
+	}
+	return attrs
+}
+
+// cleanText collapses whitespace in HTML text.
+func cleanText(s string) string {
+	return strings.TrimSpace(spacePattern.ReplaceAllString(html.UnescapeString(s), " "))
+}
+
+// parsePageMetadata extracts the title, description and canonical URL from
+// an HTML page. Open Graph properties win over <title> and <meta
+// name="description">; rawURL is kept when no canonical URL is declared.
+func parsePageMetadata(rawURL, page string) bookmark {
+	b := bookmark{URL: rawURL}
+	if m := titlePattern.FindStringSubmatch(page); m != nil {
+		b.Title = cleanText(m[1])
+	}
+	var ogTitle, ogDesc, ogURL, canonical string
+	for _, m := range metaTagPattern.FindAllStringSubmatch(page, -1) {
+		attrs := tagAttrs(m[0])
+		if strings.EqualFold(m[1], "link") {
+			if strings.EqualFold(attrs["rel"], "canonical") {
+				canonical = attrs["href"]
+			}
+			continue
+		}
+		content := cleanText(attrs["content"])
+		switch strings.ToLower(attrs["property"] + attrs["name"]) {
+		case "og:title":
+			ogTitle = content
+		case "og:description":
+			ogDesc = content
+		case "og:url":
+			ogURL = content
+		case "description":
+			if b.Description == "" {
+				b.Description = content
+			}
+		}
+	}
+	if ogTitle != "" {
+		b.Title = ogTitle
+	}
+	if ogDesc != "" {
+		b.Description = ogDesc
+	}
+	for _, u := range []string{canonical, ogURL} {
+		if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+			b.URL = u
+			break
+		}
+	}
+	if b.Title == "" {
+		b.Title = b.URL
+	}
+	return b
+}
+
+// fetchBookmark downloads rawURL and extracts its metadata.
+func fetchBookmark(httpClient *http.Client, rawURL string) (bookmark, error) {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return bookmark{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return bookmark{}, fmt.Errorf("fetching %s: status %d", rawURL, resp.StatusCode)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return bookmark{}, err
+	}
+	return parsePageMetadata(rawURL, string(page)), nil
+}
+
+// formatBookmark renders b with the text/template tmpl (the default when
+// empty).
+func formatBookmark(tmpl string, b bookmark) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultBookmarkTemplate
+	}
+	t, err := template.New("bookmark").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parsing bookmark template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, b); err != nil {
+		return "", fmt.Errorf("rendering bookmark template: %w", err)
+	}
+	return strings.TrimRight(buf.String(), "\n") + "\n", nil
+}
+
+// insertInSection adds item at the end of the section headed by heading,
+// i.e. before the next heading of the same or a higher level. The section is
+// appended to content if it doesn't exist yet.
+func insertInSection(content, heading, item string) string {
+	lines := strings.SplitAfter(content, "\n")
+	level := strings.Index(heading, " ")
+	start := -1
+	for i, line := range lines {
+		if strings.TrimRight(line, "\r\n") == heading {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return appendContent(content, heading+"\n"+item)
+	}
+
+	end := len(lines)
+	for i := start + 1; i < len(lines); i++ {
+		hashes := len(lines[i]) - len(strings.TrimLeft(lines[i], "#"))
+		if hashes > 0 && hashes <= level && strings.HasPrefix(lines[i][hashes:], " ") {
+			end = i
+			break
+		}
+	}
+	// Keep blank lines that separate the section from the next heading.
+	insert := end
+	for insert > start+1 && strings.TrimSpace(lines[insert-1]) == "" {
+		insert--
+	}
+	before := strings.Join(lines[:insert], "")
+	if !strings.HasSuffix(before, "\n") {
+		before += "\n"
+	}
+	return before + item + strings.Join(lines[insert:], "")
+}
+
+// runBookmark implements the `dropbox-appender bookmark <url> [comment]`
+// subcommand. It returns the process exit code.
+func runBookmark(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("bookmark", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprintln(stderr, "usage: dropbox-appender bookmark <url> [comment]")
+		return 2
+	}
+
+	cfg, client, ok := loadClient(stderr, true)
+	if !ok {
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	now := clock.Now()
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	return runBookmarkWithClient(stdout, stderr, client, httpClient, path, cfg.BookmarkTemplate,
+		fs.Arg(0), strings.Join(fs.Args()[1:], " "), now, opts)
+}
+
+// runBookmarkWithClient is the testable core of the bookmark subcommand. A
+// page that can't be fetched is still bookmarked, titled by its URL. The
+// upload is rev-protected and followed by the same bookkeeping as an
+// append, per opts.
+func runBookmarkWithClient(stdout, stderr io.Writer, client *DropboxClient, httpClient *http.Client,
+	path, tmpl, rawURL, comment string, now time.Time, opts appendOptions) int {
+
+	b, err := fetchBookmark(httpClient, rawURL)
+	if err != nil {
+		fmt.Fprintf(stderr, "warning: %v\n", err)
+		b = bookmark{URL: rawURL, Title: rawURL}
+	}
+	b.Comment = comment
+	b.Time = now.Format("15:04")
+
+	item, err := formatBookmark(tmpl, b)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	existing, rev, err := client.DownloadRev(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading journal: %v\n", err)
+		return 1
+	}
+	updated := insertInSection(existing, linksHeading, item)
+	newRev, err := client.UploadRev(path, updated, rev)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: uploading journal: %v\n", err)
+		return 1
+	}
+	wroteNote(client, path, path, updated, updated, newRev, opts)
+
+	fmt.Fprintf(stdout, "Bookmarked %s in %s\n", b.Title, path)
+	return 0
+}
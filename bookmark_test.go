@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const bookmarkPage = `<!doctype html>
+<html><head>
+<title>
+  Fallback &amp; Title
+</title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="Go &amp; Dropbox">
+<link rel="canonical" href="https://example.com/post">
+</head><body>hi</body></html>`
+
+func TestParsePageMetadata(t *testing.T) {
+	b := parsePageMetadata("https://example.com/post?utm=x", bookmarkPage)
+	if b.Title != "Go & Dropbox" || b.Description != "Plain description" || b.URL != "https://example.com/post" {
+		t.Errorf("unexpected metadata: %+v", b)
+	}
+
+	b = parsePageMetadata("https://example.com/", "<p>no head</p>")
+	if b.Title != "https://example.com/" || b.URL != "https://example.com/" {
+		t.Errorf("expected URL fallbacks, got %+v", b)
+	}
+}
+
+func TestFormatBookmark(t *testing.T) {
+	got, err := formatBookmark("", bookmark{URL: "https://example.com", Title: "Example", Description: "A site", Comment: "read later"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "- [Example](https://example.com) — A site\n  read later\n" {
+		t.Errorf("got %q", got)
+	}
+	got, _ = formatBookmark("* {{.Time}} <{{.URL}}>", bookmark{URL: "https://example.com", Time: "14:30"})
+	if got != "* 14:30 <https://example.com>\n" {
+		t.Errorf("custom template: got %q", got)
+	}
+}
+
+func TestInsertInSection(t *testing.T) {
+	cases := []struct {
+		content, want string
+	}{
+		{"", "## Links\n- b\n"},
+		{"### 09:00:00\nmorning\n", "### 09:00:00\nmorning\n\n## Links\n- b\n"},
+		{"## Links\n- a\n", "## Links\n- a\n- b\n"},
+		{"## Links\n- a\n\n## Later\ntext\n", "## Links\n- a\n- b\n\n## Later\ntext\n"},
+		{"## Links\n- a\n### 10:00:00\nsub\n", "## Links\n- a\n### 10:00:00\nsub\n- b\n"},
+		{"## Links\n- a", "## Links\n- a\n- b\n"},
+	}
+	for _, c := range cases {
+		if got := insertInSection(c.content, linksHeading, "- b\n"); got != c.want {
+			t.Errorf("insertInSection(%q):\n got %q\nwant %q", c.content, got, c.want)
+		}
+	}
+}
+
+func TestRunBookmarkWithClient(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(404)
+			return
+		}
+		io.WriteString(w, bookmarkPage)
+	}))
+	defer page.Close()
+
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 09:00:00\nmorning\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	opts := appendOptions{IndexPath: filepath.Join(t.TempDir(), "index.json")}
+	var stdout, stderr bytes.Buffer
+	if code := runBookmarkWithClient(&stdout, &stderr, client, page.Client(), path, "", page.URL+"/post", "worth a read", now, opts); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if code := runBookmarkWithClient(&stdout, &stderr, client, page.Client(), path, "", page.URL+"/missing", "", now, opts); code != 0 {
+		t.Fatalf("expected exit code 0 for an unreachable page, got %d", code)
+	}
+	want := "### 09:00:00\nmorning\n\n## Links\n" +
+		"- [Go & Dropbox](https://example.com/post) — Plain description\n  worth a read\n" +
+		"- [" + page.URL + "/missing](" + page.URL + "/missing)\n"
+	if files[path] != want {
+		t.Errorf("unexpected note:\n got %q\nwant %q", files[path], want)
+	}
+	if !strings.Contains(stderr.String(), "status 404") {
+		t.Errorf("expected a fetch warning, got %q", stderr.String())
+	}
+	idx, err := loadIndex(opts.IndexPath)
+	if err != nil || idx.Notes[path] == nil {
+		t.Fatalf("expected the bookmarked note in the index, got %v", err)
+	}
+	if e := idx.Notes[path].Entries; len(e) != 1 || !strings.Contains(e[0].Text, "/missing") {
+		t.Errorf("index not updated: %+v", e)
+	}
+}
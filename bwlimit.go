@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bwlimitFlag is the -bwlimit value given on the command line; it
+// overrides the bwlimit config.
+var bwlimitFlag string
+
+// bandwidth limits the request and response bodies of every Dropbox and
+// OAuth request; nil leaves them unlimited. configureHTTP sets it.
+var bandwidth *rateLimiter
+
+// parseBandwidth parses a rate in bytes per second such as "200k" or
+// "1M"; "" and "0" mean unlimited.
+func parseBandwidth(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := parseSize(strings.TrimSuffix(strings.TrimSuffix(s, "/s"), "ps"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth limit %q: expected bytes per second, e.g. 200k", s)
+	}
+	return n, nil
+}
+
+// rateLimiter is a token bucket shared by all transfers of a process, so
+// concurrent uploads and downloads together stay under the limit.
+type rateLimiter struct {
+	rate  float64 // bytes per second
+	burst float64
+	now   func() time.Time
+	sleep func(time.Duration)
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter allows bytesPerSec on average, with bursts of up to a
+// quarter second's worth (at least 4 KB, so reads aren't split too finely).
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	burst := max(float64(bytesPerSec)/4, 4096)
+	return &rateLimiter{rate: float64(bytesPerSec), burst: burst, now: time.Now, sleep: time.Sleep,
+		tokens: burst, last: time.Now()}
+}
+
+// chunk returns how many of n bytes may be transferred in one go.
+func (l *rateLimiter) chunk(n int) int {
+	return min(n, int(l.burst))
+}
+
+// wait blocks until n bytes may be transferred.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	t := l.now()
+	l.tokens = min(l.tokens+t.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = t
+	l.tokens -= float64(n)
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if d > 0 {
+		l.sleep(d)
+	}
+}
+
+// limitedReader reads from r no faster than l allows.
+type limitedReader struct {
+	r io.ReadCloser
+	l *rateLimiter
+}
+
+func (r limitedReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b[:r.l.chunk(len(b))])
+	if n > 0 {
+		r.l.wait(n)
+	}
+	return n, err
+}
+
+func (r limitedReader) Close() error { return r.r.Close() }
+
+// bwLimitTransport applies bandwidth to the bodies of the requests it
+// carries.
+type bwLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t bwLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := bandwidth
+	if l == nil {
+		return t.base.RoundTrip(req)
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = limitedReader{req.Body, l}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.Body != nil {
+		resp.Body = limitedReader{resp.Body, l}
+	}
+	return resp, err
+}
+
+// configureBandwidth sets bandwidth from -bwlimit or the bwlimit config.
+func configureBandwidth(cfg *Config) error {
+	limit := cfg.BWLimit
+	if bwlimitFlag != "" {
+		limit = bwlimitFlag
+	}
+	rate, err := parseBandwidth(limit)
+	if err != nil {
+		return err
+	}
+	bandwidth = nil
+	if rate > 0 {
+		bandwidth = newRateLimiter(rate)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLimiter is a rateLimiter on a fake clock that advances as it sleeps.
+func fakeLimiter(bytesPerSec int64, slept *time.Duration) *rateLimiter {
+	l := newRateLimiter(bytesPerSec)
+	t0 := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	l.last = t0
+	l.now = func() time.Time { return t0.Add(*slept) }
+	l.sleep = func(d time.Duration) { *slept += d }
+	return l
+}
+
+func TestParseBandwidth(t *testing.T) {
+	for in, want := range map[string]int64{"": 0, "0": 0, "200k": 200 << 10, "1MB/s": 1 << 20, "512kps": 512 << 10} {
+		if got, err := parseBandwidth(in); err != nil || got != want {
+			t.Errorf("parseBandwidth(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := parseBandwidth("fast"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	var slept time.Duration
+	l := fakeLimiter(100<<10, &slept) // 100 KB/s, 25 KB burst
+	r := limitedReader{io.NopCloser(strings.NewReader(strings.Repeat("x", 525<<10))), l}
+	n, err := io.Copy(io.Discard, r)
+	if err != nil || n != 525<<10 {
+		t.Fatalf("copied %d, %v", n, err)
+	}
+	// The burst goes out at once; the other 500 KB take 5 seconds.
+	if slept < 4900*time.Millisecond || slept > 5100*time.Millisecond {
+		t.Errorf("slept %v, want about 5s", slept)
+	}
+}
+
+func TestBWLimitTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(strings.Repeat("y", 64<<10)))
+	}))
+	defer srv.Close()
+
+	var slept time.Duration
+	bandwidth = fakeLimiter(32<<10, &slept)
+	defer func() { bandwidth = nil }()
+
+	client := &http.Client{Transport: bwLimitTransport{http.DefaultTransport}}
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader(strings.Repeat("x", 32<<10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	// 96 KB at 32 KB/s, less the 8 KB burst: 2.75s.
+	if slept < 2700*time.Millisecond || slept > 2800*time.Millisecond {
+		t.Errorf("slept %v, want about 2.75s", slept)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// datedLine matches a -by-date input line: a date, an optional time, a
+// colon and the entry text, e.g. "2025-01-12 14:30: fixed the bug".
+var datedLine = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(?:[ T](\d{1,2}:\d{2}(?::\d{2})?))?:\s*(.*)$`)
+
+// datedEntry is one entry of -by-date input.
+type datedEntry struct {
+	When    time.Time
+	HasTime bool
+	Text    string
+}
+
+// parseDatedLines splits -by-date input into entries. Lines that don't
+// start with a date continue the previous entry, so an entry can span
+// several lines; blank lines are kept inside entries but trimmed at their
+// ends. Times are interpreted in loc.
+func parseDatedLines(input string, loc *time.Location) ([]datedEntry, error) {
+	var entries []datedEntry
+	for i, line := range strings.Split(input, "\n") {
+		m := datedLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			if len(entries) == 0 {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				return nil, fmt.Errorf("line %d: expected YYYY-MM-DD: text, got %q", i+1, line)
+			}
+			last := &entries[len(entries)-1]
+			last.Text += "\n" + line
+			continue
+		}
+		value, layout := m[1], "2006-01-02"
+		if m[2] != "" {
+			clock := m[2]
+			if len(clock) == 4 || len(clock) == 7 {
+				clock = "0" + clock // 9:30 → 09:30
+			}
+			value += " " + clock
+			layout += " 15:04"
+			if len(clock) == 8 {
+				layout += ":05"
+			}
+		}
+		when, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		entries = append(entries, datedEntry{When: when, HasTime: m[2] != "", Text: m[3]})
+	}
+	for i := range entries {
+		entries[i].Text = strings.TrimSpace(entries[i].Text)
+	}
+	return entries, nil
+}
+
+// appendByDate routes each dated entry to its day's note, appending all of
+// a note's entries in one download/upload cycle. Notes are written in date
+// order and entries keep their input order within a note. transform
+// prepares entry text (shortcodes, wrapping). Entries without a time have
+// no ### header, since there is no time to put in it. The notes are
+// written in a transaction: when one fails, those already written are
+// rolled back, so the import can simply be run again. It returns the
+// process exit code.
+func appendByDate(stdout, stderr io.Writer, client *DropboxClient, cfg *Config, pathTemplate string,
+	entries []datedEntry, opts appendOptions, transform func(string) string) int {
+
+	if len(entries) == 0 {
+		fmt.Fprintln(stderr, "error: no dated lines in the input")
+		return 1
+	}
+	type note struct {
+		path    string
+		first   time.Time
+		entries []string
+	}
+	notes := map[string]*note{}
+	for _, e := range entries {
+		p, err := journalPath(cfg, pathTemplate, e.When)
+		if err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		n, ok := notes[p]
+		if !ok {
+			n = &note{path: p, first: e.When}
+			notes[p] = n
+		}
+		n.entries = append(n.entries, formatEntry(e.When, transform(e.Text), !e.HasTime))
+	}
+	ordered := make([]*note, 0, len(notes))
+	for _, n := range notes {
+		ordered = append(ordered, n)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].first.Before(ordered[j].first) })
+
+	if pathTemplate != "" {
+		opts.PathTemplate = pathTemplate
+	}
+	txn := beginTransaction(client, opts.IndexPath, opts.CachePath)
+	var done []string
+	for _, n := range ordered {
+		written, err := appendEntries(client, n.path, n.entries, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: appending %d entries to %s: %v\n", len(n.entries), n.path, err)
+			restored, err := txn.rollback()
+			if len(restored) > 0 {
+				fmt.Fprintf(stderr, "Rolled back %s\n", strings.Join(restored, ", "))
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "warning: rolling back: %v\n", err)
+			}
+			return 1
+		}
+		done = append(done, fmt.Sprintf("Appended %d entries to %s\n", len(n.entries), written))
+	}
+	txn.commit()
+	for _, line := range done {
+		fmt.Fprint(stdout, line)
+	}
+	return 0
+}
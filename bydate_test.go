@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDatedLines(t *testing.T) {
+	input := "\n2025-01-12: fixed bug\n  with a second line\n2025-01-13 9:30: demo\n2025-01-12T14:05:30: follow-up\n"
+	entries, err := parseDatedLines(input, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []datedEntry{
+		{time.Date(2025, 1, 12, 0, 0, 0, 0, time.UTC), false, "fixed bug\n  with a second line"},
+		{time.Date(2025, 1, 13, 9, 30, 0, 0, time.UTC), true, "demo"},
+		{time.Date(2025, 1, 12, 14, 5, 30, 0, time.UTC), true, "follow-up"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %+v", entries)
+	}
+	for i := range want {
+		if !entries[i].When.Equal(want[i].When) || entries[i].HasTime != want[i].HasTime || entries[i].Text != want[i].Text {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	if _, err := parseDatedLines("no date here\n2025-01-12: x", time.UTC); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("err = %v, want a line 1 error", err)
+	}
+	if _, err := parseDatedLines("2025-02-30: x", time.UTC); err == nil {
+		t.Error("expected an error for February 30")
+	}
+}
+
+func TestAppendByDate(t *testing.T) {
+	files := map[string]string{"/J/20250112.md": "### 08:00:00\nearlier\n"}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	cfg := &Config{PathTemplate: "/J/{{.Date}}.md"}
+
+	entries, _ := parseDatedLines("2025-01-13 10:00: demo\n2025-01-12: fixed :bug:\n2025-01-12 17:00: wrap-up", time.Local)
+	var stdout, stderr bytes.Buffer
+	expand := func(s string) string { return strings.ReplaceAll(s, ":bug:", "🐛") }
+	if code := appendByDate(&stdout, &stderr, client, cfg, "", entries, appendOptions{}, expand); code != 0 {
+		t.Fatalf("code = %d: %s", code, stderr.String())
+	}
+
+	if want := "### 08:00:00\nearlier\n\nfixed 🐛\n\n### 17:00:00\nwrap-up\n"; files["/J/20250112.md"] != want {
+		t.Errorf("12th = %q, want %q", files["/J/20250112.md"], want)
+	}
+	if want := "### 10:00:00\ndemo\n"; files["/J/20250113.md"] != want {
+		t.Errorf("13th = %q, want %q", files["/J/20250113.md"], want)
+	}
+	// The 12th is written first, in one cycle, though the 13th came first.
+	if want := "Appended 2 entries to /J/20250112.md\nAppended 1 entries to /J/20250113.md\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// captureTimeout bounds each -capture-env command.
+const captureTimeout = 10 * time.Second
+
+// defaultCaptureAllow are the commands -capture-env runs without further
+// configuration: read-only commands that describe the environment.
+var defaultCaptureAllow = []string{
+	"go version",
+	"go env",
+	"git status -s",
+	"git status",
+	"git log -1 --oneline",
+	"git rev-parse HEAD",
+	"git diff --stat",
+	"uname -a",
+	"node --version",
+	"python3 --version",
+	"rustc --version",
+	"docker version",
+	"kubectl config current-context",
+}
+
+// normalizeCommand collapses the whitespace in a command line so "git  status"
+// matches "git status" in the allowlist.
+func normalizeCommand(cmdline string) string {
+	return strings.Join(strings.Fields(cmdline), " ")
+}
+
+// parseCaptureCommands splits a -capture-env value at commas and checks each
+// command against the default allowlist and the capture_env_allow setting.
+// Only whole command lines match, so allowing "git status" doesn't allow
+// "git push".
+func parseCaptureCommands(list string, allow []string) ([]string, error) {
+	allowed := map[string]bool{}
+	for _, c := range append(append([]string{}, defaultCaptureAllow...), allow...) {
+		allowed[normalizeCommand(c)] = true
+	}
+	var cmds []string
+	for _, c := range strings.Split(list, ",") {
+		c = normalizeCommand(c)
+		if c == "" {
+			continue
+		}
+		if !allowed[c] {
+			return nil, fmt.Errorf("-capture-env: %q isn't allowed; add it to capture_env_allow in the config", c)
+		}
+		cmds = append(cmds, c)
+	}
+	return cmds, nil
+}
+
+// captureCommand runs cmdline, without a shell, and returns its combined
+// output. A command that fails still yields its output, followed by how it
+// failed, since that is often what the log is for.
+func captureCommand(cmdline string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), captureTimeout)
+	defer cancel()
+	args := strings.Fields(cmdline)
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	text := strings.TrimRight(string(out), "\n")
+	if err != nil {
+		if text != "" {
+			text += "\n"
+		}
+		text += fmt.Sprintf("(%v)", err)
+	}
+	return text
+}
+
+// formatCapture renders a command and its output as a fenced console block.
+// The fence is made longer than any backtick run in the output.
+func formatCapture(cmdline, output string) string {
+	fence := "```"
+	for strings.Contains(output, fence) {
+		fence += "`"
+	}
+	var b strings.Builder
+	b.WriteString(fence + "console\n$ " + cmdline + "\n")
+	if output != "" {
+		b.WriteString(output + "\n")
+	}
+	b.WriteString(fence + "\n")
+	return b.String()
+}
+
+// captureEnv runs cmds and returns their blocks, to append to an entry.
+func captureEnv(cmds []string) string {
+	blocks := make([]string, len(cmds))
+	for i, c := range cmds {
+		blocks[i] = formatCapture(c, captureCommand(c))
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// withCapture appends the captured blocks to text, separated by a blank line.
+func withCapture(text, captured string) string {
+	if captured == "" {
+		return text
+	}
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return captured
+	}
+	return text + "\n\n" + captured
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCaptureCommands(t *testing.T) {
+	cmds, err := parseCaptureCommands("go version, git  status -s,,echo hi", []string{"echo hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cmds, "|") != "go version|git status -s|echo hi" {
+		t.Errorf("commands = %q", cmds)
+	}
+	if _, err := parseCaptureCommands("git push", nil); err == nil {
+		t.Error("expected an error for a command that isn't allowed")
+	}
+	if cmds, err := parseCaptureCommands("", nil); err != nil || len(cmds) != 0 {
+		t.Errorf("empty = %q, %v", cmds, err)
+	}
+}
+
+func TestFormatCapture(t *testing.T) {
+	got := formatCapture("go version", "go version go1.25.0 linux/amd64")
+	want := "```console\n$ go version\ngo version go1.25.0 linux/amd64\n```\n"
+	if got != want {
+		t.Errorf("formatCapture = %q", got)
+	}
+	if got := formatCapture("cat README.md", "```go\nx\n```"); !strings.HasPrefix(got, "````console\n") || !strings.HasSuffix(got, "\n````\n") {
+		t.Errorf("fence not lengthened: %q", got)
+	}
+}
+
+func TestCaptureCommand(t *testing.T) {
+	if got := captureCommand("echo hello"); got != "hello" {
+		t.Errorf("echo = %q", got)
+	}
+	if got := captureCommand("false"); got != "(exit status 1)" {
+		t.Errorf("false = %q", got)
+	}
+	if got := withCapture("flaky test again\n", "```console\n$ echo\n\n```\n"); got != "flaky test again\n\n```console\n$ echo\n\n```\n" {
+		t.Errorf("withCapture = %q", got)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// applyClientCert makes sharedTransport present the certificate configured
+// in cfg (PEM files) to servers asking for one, such as a corporate gateway
+// in front of the Dropbox API. Proxy settings from the environment still
+// apply.
+func applyClientCert(cfg *Config) error {
+	if cfg.TLSClientCert == "" && cfg.TLSClientKey == "" {
+		return nil
+	}
+	if cfg.TLSClientCert == "" || cfg.TLSClientKey == "" {
+		return fmt.Errorf("tls_client_cert and tls_client_key must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(expandHome(cfg.TLSClientCert), expandHome(cfg.TLSClientKey))
+	if err != nil {
+		return fmt.Errorf("loading tls_client_cert/tls_client_key: %w", err)
+	}
+	sharedTransport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and key to dir and
+// returns their paths and the parsed certificate.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "journal-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile, keyFile = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile, cert
+}
+
+// useFreshTransport swaps in a new sharedTransport for the test.
+func useFreshTransport(t *testing.T) {
+	t.Helper()
+	saved := sharedTransport
+	sharedTransport = newSharedTransport()
+	t.Cleanup(func() { sharedTransport = saved })
+}
+
+func TestApplyClientCert(t *testing.T) {
+	useFreshTransport(t)
+	certFile, keyFile, cert := writeClientCert(t, t.TempDir())
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // the refused handshake is expected
+	srv.StartTLS()
+	defer srv.Close()
+	sharedTransport.TLSClientConfig.RootCAs = srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	client := &http.Client{Transport: sharedTransport}
+
+	// Without the certificate the gateway refuses the connection.
+	if err := applyClientCert(&Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := client.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the handshake to fail without a client certificate")
+	}
+
+	if err := applyClientCert(&Config{TLSClientCert: certFile, TLSClientKey: keyFile}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+}
+
+func TestApplyClientCert_Invalid(t *testing.T) {
+	useFreshTransport(t)
+	certFile, _, _ := writeClientCert(t, t.TempDir())
+	if err := applyClientCert(&Config{TLSClientCert: certFile}); err == nil {
+		t.Error("expected an error with only tls_client_cert set")
+	}
+	if err := applyClientCert(&Config{TLSClientCert: certFile, TLSClientKey: filepath.Join(t.TempDir(), "missing.key")}); err == nil {
+		t.Error("expected an error for a missing key file")
+	}
+	if len(sharedTransport.TLSClientConfig.Certificates) != 0 {
+		t.Error("a failed load should not install a certificate")
+	}
+}
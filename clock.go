@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock supplies the current time. Commands take a Clock instead of calling
+// time.Now so backfills (-now) and tests can pin the time used for both the
+// note path and the entry header.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reports the real wall-clock time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// fixedClock always reports the same instant.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+// nowLayouts are the formats accepted by -now, interpreted in local time.
+var nowLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseNow parses a -now value such as "2025-01-15T14:30:00". Values without
+// a zone are taken as local time; a bare date means midnight.
+func parseNow(s string) (time.Time, error) {
+	for _, layout := range nowLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid -now %q: expected e.g. 2025-01-15T14:30:00", s)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNow(t *testing.T) {
+	cases := map[string]time.Time{
+		"2025-01-15T14:30:00":  time.Date(2025, 1, 15, 14, 30, 0, 0, time.Local),
+		"2025-01-15T14:30":     time.Date(2025, 1, 15, 14, 30, 0, 0, time.Local),
+		"2025-01-15 09:05:07":  time.Date(2025, 1, 15, 9, 5, 7, 0, time.Local),
+		"2025-01-15":           time.Date(2025, 1, 15, 0, 0, 0, 0, time.Local),
+		"2025-01-15T14:30:00Z": time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC),
+	}
+	for in, want := range cases {
+		got, err := parseNow(in)
+		if err != nil {
+			t.Errorf("parseNow(%q): unexpected error: %v", in, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("parseNow(%q) = %v, want %v", in, got, want)
+		}
+	}
+	if _, err := parseNow("yesterday"); err == nil {
+		t.Error("expected error for unparseable time")
+	}
+}
+
+func TestFixedClock(t *testing.T) {
+	want := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	if got := fixedClock(want).Now(); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
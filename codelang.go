@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	goSignals = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^package \w+\s*$`),
+		regexp.MustCompile(`(?m)^import (?:\(|")`),
+		regexp.MustCompile(`(?m)^func (?:\([^)]*\) )?\w+\(`),
+		regexp.MustCompile(`(?m)^\s*if err != nil \{`),
+		regexp.MustCompile(`\w+ := `),
+		regexp.MustCompile(`(?m)^type \w+ (?:struct|interface) \{`),
+	}
+	diffHunk  = regexp.MustCompile(`(?m)^@@ -\d+(?:,\d+)? \+\d+(?:,\d+)? @@`)
+	diffFiles = regexp.MustCompile(`(?m)^--- \S.*\n\+\+\+ \S`)
+	// sqlStatements match the start of the common statements, up to a
+	// word that rarely follows the keyword in prose.
+	sqlStatements = []*regexp.Regexp{
+		regexp.MustCompile(`(?is)^select\s+(?:distinct\s+)?[\w.()*]+(?:\s+as\s+\w+)?(?:\s*,\s*[\w.()*]+(?:\s+as\s+\w+)?)*\s+from\s+[\w."]`),
+		regexp.MustCompile(`(?is)^insert\s+into\s+\S+.*?\b(?:values|select)\b`),
+		regexp.MustCompile(`(?is)^update\s+\S+\s+set\s+\w+\s*=`),
+		regexp.MustCompile(`(?is)^delete\s+from\s+\w+\s*(?:;|$|where\b)`),
+		regexp.MustCompile(`(?is)^(?:create|alter|drop)\s+(?:unique\s+)?(?:table|index|view)\s+(?:if\s+(?:not\s+)?exists\s+)?[\w."]+\s*(?:\(|;|$|add\b|drop\b|rename\b|on\b|as\b)`),
+		regexp.MustCompile(`(?is)^with\s+\w+\s+as\s*\(`),
+	}
+)
+
+// detectLanguage returns the language of text when it looks like code, as
+// a fence info string (go, json, diff or sql), or "" when it doesn't. The
+// heuristics err towards prose: a sentence mentioning "select" or a lone
+// ":=" isn't code.
+func detectLanguage(text string) string {
+	trimmed := strings.TrimSpace(text)
+	switch {
+	case trimmed == "":
+		return ""
+	case (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)):
+		return "json"
+	case strings.HasPrefix(trimmed, "diff --git ") || diffHunk.MatchString(trimmed) || diffFiles.MatchString(trimmed):
+		return "diff"
+	}
+	for _, re := range sqlStatements {
+		if re.MatchString(trimmed) {
+			return "sql"
+		}
+	}
+	signals := 0
+	for _, re := range goSignals {
+		if re.MatchString(trimmed) {
+			signals++
+		}
+	}
+	if signals >= 2 || goSignals[0].MatchString(trimmed) {
+		return "go"
+	}
+	return ""
+}
+
+// validCodeLang checks a -lang or code_lang value: auto, none or a fence
+// info string such as python.
+func validCodeLang(lang string) error {
+	if strings.ContainsAny(lang, " \t\n`~") {
+		return fmt.Errorf("invalid language %q: expected auto, none or a name such as go", lang)
+	}
+	return nil
+}
+
+// fenceCode wraps text in a fenced code block tagged with lang. With lang
+// "auto" only text detectLanguage recognizes is fenced; "" and "none" leave
+// text as it is, as does text that already is a single fenced block. The
+// fence is made longer than any backtick run in text.
+func fenceCode(text, lang string) string {
+	if lang == "auto" {
+		lang = detectLanguage(text)
+	}
+	body := strings.Trim(text, "\n")
+	if lang == "" || lang == "none" || body == "" || wrapFence.MatchString(firstLine(body)) {
+		return text
+	}
+	fence := "```"
+	for strings.Contains(body, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + body + "\n" + fence
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	for _, tc := range []struct{ text, want string }{
+		{`{"id": 3, "tags": ["a", "b"]}`, "json"},
+		{"[1, 2, 3]\n", "json"},
+		{"[ ] not json", ""},
+		{"--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,4 @@\n package main\n+// hi\n", "diff"},
+		{"diff --git a/x b/x\nindex 1..2\n", "diff"},
+		{"SELECT id, name FROM users WHERE active;", "sql"},
+		{"create table notes (id int primary key);", "sql"},
+		{"update on the release: shipped", ""},
+		{"Select a venue for the offsite", ""},
+		{"Select a venue from the list", ""},
+		{"select count(*) as n, u.name\nfrom users u group by u.name", "sql"},
+		{"package main\n\nfunc main() {}\n", "go"},
+		{"func add(a, b int) int {\n\tsum := a + b\n\treturn sum\n}", "go"},
+		{"if err != nil {\n\treturn err\n}\nx := f()", "go"},
+		{"Set x := 3 in the notes", ""},
+		{"Had a great meeting with the team", ""},
+	} {
+		if got := detectLanguage(tc.text); got != tc.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestFenceCode(t *testing.T) {
+	for _, tc := range []struct{ text, lang, want string }{
+		{`{"a": 1}`, "auto", "```json\n{\"a\": 1}\n```"},
+		{"just words", "auto", "just words"},
+		{"print('hi')", "python", "```python\nprint('hi')\n```"},
+		{"print('hi')", "none", "print('hi')"},
+		{"print('hi')", "", "print('hi')"},
+		{"```go\nx := 1\n```", "go", "```go\nx := 1\n```"},
+		{"s := \"```\"\nif err != nil {\n}", "auto", "````go\ns := \"```\"\nif err != nil {\n}\n````"},
+	} {
+		if got := fenceCode(tc.text, tc.lang); got != tc.want {
+			t.Errorf("fenceCode(%q, %q) = %q, want %q", tc.text, tc.lang, got, tc.want)
+		}
+	}
+	if err := validCodeLang("c++"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validCodeLang("two words"); err == nil {
+		t.Error("expected an error for a language with a space")
+	}
+}
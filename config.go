@@ -1,16 +1,139 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-// Config holds OAuth credentials.
+// Config holds OAuth credentials and optional client settings.
 type Config struct {
-	AppKey       string `json:"app_key"`
-	AppSecret    string `json:"app_secret"`
-	RefreshToken string `json:"refresh_token"`
+	AppKey           string            `json:"app_key"`
+	AppSecret        string            `json:"app_secret"`
+	RefreshToken     string            `json:"refresh_token"`
+	PathTemplate     string            `json:"path_template,omitempty"`
+	MaxNoteSize      string            `json:"max_note_size,omitempty"`
+	Shortcodes       map[string]string `json:"shortcodes,omitempty"`
+	Forms            map[string]string `json:"forms,omitempty"`
+	NumberEntries    bool              `json:"number_entries,omitempty"`
+	EntryIDs         bool              `json:"entry_ids,omitempty"`
+	BookmarkTemplate string            `json:"bookmark_template,omitempty"`
+	Separator        string            `json:"separator,omitempty"`
+	Footer           string            `json:"footer,omitempty"`
+	Wrap             int               `json:"wrap,omitempty"`
+	ShowTail         int               `json:"show_tail,omitempty"`
+	LineEndings      string            `json:"line_endings,omitempty"`
+	MergeTool        string            `json:"merge_tool,omitempty"`
+	TranscribeCmd    string            `json:"transcribe_cmd,omitempty"`
+	OCRCmd           string            `json:"ocr_cmd,omitempty"`
+	Snapshots        int               `json:"snapshots,omitempty"`
+	// CodeLang fences entries as code blocks: "auto" when they look like
+	// go, json, diff or sql, or a language name to fence every entry with
+	// it. Empty or "none" leaves entries as they are.
+	CodeLang string `json:"code_lang,omitempty"`
+	// SpaceWarning warns before appending when the account is at least this
+	// percent full; 0 disables the check.
+	SpaceWarning float64 `json:"space_warning,omitempty"`
+	// Language selects the message catalog, e.g. "de"; empty follows
+	// LC_ALL, LC_MESSAGES or LANG.
+	Language string `json:"language,omitempty"`
+	// SlackSigningSecret verifies requests to `serve`'s /slack endpoint,
+	// which is disabled without it.
+	SlackSigningSecret string `json:"slack_signing_secret,omitempty"`
+	// TelegramBotToken and TelegramChats configure the telegram subcommand:
+	// only messages from the listed chat IDs are appended.
+	TelegramBotToken string  `json:"telegram_bot_token,omitempty"`
+	TelegramChats    []int64 `json:"telegram_chats,omitempty"`
+	// MaxDownloadSize, e.g. "20MB", refuses to download larger notes in
+	// full; commands that only need the end of a note fetch just that.
+	MaxDownloadSize string `json:"max_download_size,omitempty"`
+	// CaptureEnvAllow lists command lines -capture-env may run besides the
+	// built-in ones.
+	CaptureEnvAllow []string `json:"capture_env_allow,omitempty"`
+	// Endpoints overrides Dropbox API base URLs, per endpoint or per host
+	// ("api", "content"), e.g. to go through a proxy.
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+	// APICacheTTL, e.g. "10m", is how long folder listings are reused
+	// (default 10m); "0" disables the cache.
+	APICacheTTL string `json:"api_cache_ttl,omitempty"`
+	// BWLimit, e.g. "200k", caps transfers at that many bytes per second;
+	// empty or "0" leaves them unlimited.
+	BWLimit string `json:"bwlimit,omitempty"`
+	// TargetFormat is "paper-md" for notes imported into Dropbox Paper.
+	TargetFormat string `json:"target_format,omitempty"`
+	// TLSClientCert and TLSClientKey are PEM files presented to servers
+	// requesting a client certificate, e.g. a corporate API gateway.
+	TLSClientCert string `json:"tls_client_cert,omitempty"`
+	TLSClientKey  string `json:"tls_client_key,omitempty"`
+	// TLSSessionCache keeps TLS session tickets between runs.
+	TLSSessionCache bool `json:"tls_session_cache,omitempty"`
+	// AccountID and AccountCredential (a hash of the refresh token) are
+	// recorded by auth so writes can be checked against the right account.
+	AccountID         string `json:"account_id,omitempty"`
+	AccountCredential string `json:"account_credential,omitempty"`
+	// Author attributes entries for shared journals: absent disables
+	// attribution, "" uses $USER@hostname.
+	Author    *string          `json:"author,omitempty"`
+	Retry     *RetryConfig     `json:"retry,omitempty"`
+	GitMirror *GitMirrorConfig `json:"git_mirror,omitempty"`
+	Fallback  *FallbackConfig  `json:"fallback,omitempty"`
+	Plugins   []PluginConfig   `json:"plugins,omitempty"`
+	// DaySummary makes the daemon append a summary of each day.
+	DaySummary *DaySummaryConfig `json:"day_summary,omitempty"`
+	// NoteProperties stamps notes with entry_count, last_append_at and
+	// appender_version file properties on each append.
+	NoteProperties bool `json:"note_properties,omitempty"`
+	// Digest holds the defaults for the digest subcommand.
+	Digest *DigestConfig `json:"digest,omitempty"`
+	// Notify lets uploads show up as notifications in the Dropbox apps,
+	// e.g. for a shared family log; NotifyPaths only for paths matching
+	// these glob patterns. Uploads are muted otherwise.
+	Notify      bool     `json:"notify,omitempty"`
+	NotifyPaths []string `json:"notify_paths,omitempty"`
+	// WAL keeps a local log of every entry appended, for reconcile;
+	// absent enables it, false disables it.
+	WAL *bool `json:"wal,omitempty"`
+	// AppFolder is the name of the folder in Dropbox/Apps an app with "App
+	// folder" access is limited to; paths under /Apps/<name> are made
+	// relative to it. Empty for Full Dropbox apps.
+	AppFolder string `json:"app_folder,omitempty"`
+	// ReadOnly refuses every write to Dropbox, like -read-only.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// Image shrinks image attachments before upload.
+	Image *ImageConfig `json:"image,omitempty"`
+	// Fold collapses entries longer than Fold.Lines lines.
+	Fold *FoldConfig `json:"fold,omitempty"`
+	// Obsidian makes `open` open notes in this Obsidian vault instead of
+	// the Dropbox website.
+	Obsidian *ObsidianConfig `json:"obsidian,omitempty"`
+	// Sections groups each day's entries under time-of-day headings such
+	// as "## Morning".
+	Sections []TimeSection `json:"sections,omitempty"`
+	// DayLinks starts new daily notes with links to the previous and next
+	// day's notes.
+	DayLinks *DayLinksConfig `json:"day_links,omitempty"`
+	// Theme styles status lines on a terminal.
+	Theme *ThemeConfig `json:"theme,omitempty"`
+	// Profiles are named sets of config keys overriding those above, e.g.
+	// a path_template for the work journal. One is selected with -profile
+	// or by the first matching ProfileRules entry.
+	Profiles     map[string]json.RawMessage `json:"profiles,omitempty"`
+	ProfileRules []ProfileRule              `json:"profile_rules,omitempty"`
+	// Flags are default flags for appending, e.g. ["-author", "alice"],
+	// mostly useful in a profile. Flags on the command line win.
+	Flags []string `json:"flags,omitempty"`
+
+	// path is the file the config was loaded from, for saving a rotated
+	// refresh token.
+	path string
+	// profile is the name of the profile applied, if any.
+	profile string
 }
 
 // defaultConfigPath returns ~/.config/dropbox-appender/config.json.
@@ -19,13 +142,79 @@ func defaultConfigPath() string {
 	return filepath.Join(home, ".config", "dropbox-appender", "config.json")
 }
 
-// loadConfig reads config from file, then applies env var overrides.
-func loadConfig(path string) (*Config, error) {
+// backupPath returns the path of the last known-good copy of the config.
+func backupPath(path string) string {
+	return path + ".bak"
+}
+
+// checksumPath returns the sidecar holding the SHA-256 of the config as last
+// written by saveConfig.
+func checksumPath(path string) string {
+	return path + ".sha256"
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrConfigModified reports a config file that no longer matches the
+// checksum saveConfig recorded, i.e. it was changed outside this tool.
+var ErrConfigModified = errors.New("modified outside dropbox-appender")
+
+// loadConfig reads config from file, then applies env var overrides and the
+// HTTP settings (see configureHTTP). It doesn't change any files; warnings
+// go to stderr.
+//
+// A config file that isn't valid JSON (e.g. truncated by a crash) is read
+// from its .bak copy instead, when that one is intact, until `config
+// restore` puts the backup back. A file that parses but no longer matches
+// the checksum saveConfig recorded was changed outside this tool and is
+// refused until `config accept` records the new checksum. Unknown keys,
+// usually typos, are reported with their position and otherwise ignored;
+// `dropbox-appender config validate` checks the file strictly. An encrypted
+// file is decrypted first (see unlockConfig).
+func loadConfig(path string, stderr io.Writer) (*Config, error) {
 	cfg := &Config{}
 
-	data, err := os.ReadFile(path)
+	raw, err := os.ReadFile(path)
 	if err == nil {
-		json.Unmarshal(data, cfg)
+		data, err := unlockConfig(path, raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			problem := jsonErrorProblem(data, err)
+			if cfg, err = readBackupConfig(path, problem); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(stderr, "warning: %s is corrupt (%s); using its backup until `dropbox-appender config restore` puts it back\n", path, problem)
+		} else if !checksumMatches(path, raw) {
+			return nil, fmt.Errorf("%s was %w; if you made the changes, run `dropbox-appender config accept`", path, ErrConfigModified)
+		}
+		if json.Valid(data) {
+			_, unknown := configKeys(data)
+			for _, p := range unknown {
+				fmt.Fprintf(stderr, "warning: %s:%s (ignored)\n", path, p)
+			}
+		}
+	}
+
+	if err := finishConfig(cfg); err != nil {
+		return nil, err
+	}
+	cfg.path = path
+
+	return cfg, nil
+}
+
+// finishConfig applies to a parsed config what every config gets, whether
+// read from a file or passed in by a library caller: the active profile,
+// env var overrides, and the HTTP, language, theme, read-only and app
+// folder settings.
+func finishConfig(cfg *Config) error {
+	if err := applyProfile(cfg, currentEnv()); err != nil {
+		return err
 	}
 
 	// Env vars override file values
@@ -38,18 +227,161 @@ func loadConfig(path string) (*Config, error) {
 	if v := os.Getenv("DROPBOX_REFRESH_TOKEN"); v != "" {
 		cfg.RefreshToken = v
 	}
+	if v := os.Getenv("SLACK_SIGNING_SECRET"); v != "" {
+		cfg.SlackSigningSecret = v
+	}
+	if v := os.Getenv("TELEGRAM_BOT_TOKEN"); v != "" {
+		cfg.TelegramBotToken = v
+	}
 
+	if err := configureHTTP(cfg); err != nil {
+		return err
+	}
+	setLanguage(cfg.Language)
+	setTheme(cfg.Theme)
+	configureReadOnly(cfg)
+	configureAppFolder(cfg)
+	return nil
+}
+
+// readConfigFile reads the config file as saved, without env var overrides
+// or backup recovery.
+func readConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = unlockConfig(path, data); err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, jsonErrorProblem(data, err))
+	}
 	return cfg, nil
 }
 
-// saveConfig writes config to file, creating directories as needed.
+// checksumMatches reports whether raw, the config file at path, is what
+// saveConfig last wrote there. A file without a checksum sidecar matches.
+func checksumMatches(path string, raw []byte) bool {
+	sum, err := os.ReadFile(checksumPath(path))
+	return err != nil || strings.TrimSpace(string(sum)) == checksum(raw)
+}
+
+// readBackupConfig reads the .bak copy of a corrupt config file. problem
+// locates what is wrong with the file, for the messages.
+func readBackupConfig(path string, problem configProblem) (*Config, error) {
+	data, err := os.ReadFile(backupPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s is corrupt (%s) and no backup is available", path, problem)
+	}
+	plain, err := unlockConfig(backupPath(path), data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is corrupt (%s) and its backup can't be read: %w", path, problem, err)
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(plain, cfg); err != nil {
+		return nil, fmt.Errorf("%s (%s) and its backup are both corrupt", path, problem)
+	}
+	return cfg, nil
+}
+
+// updateConfigFile applies update to the config file at path as it is on
+// disk, without the profile and environment overrides loadConfig merges in,
+// and saves it. A missing file starts out empty.
+func updateConfigFile(path string, update func(*Config) error) error {
+	cfg, err := readConfigFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		cfg, err = &Config{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := update(cfg); err != nil {
+		return err
+	}
+	return saveConfig(path, cfg)
+}
+
+// setProfileKey sets key to value in the profile called name, keeping its
+// other keys.
+func (c *Config) setProfileKey(name, key string, value any) error {
+	keys := map[string]json.RawMessage{}
+	if raw, ok := c.Profiles[name]; ok {
+		if err := json.Unmarshal(raw, &keys); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	keys[key] = data
+	if c.Profiles == nil {
+		c.Profiles = map[string]json.RawMessage{}
+	}
+	c.Profiles[name], err = json.Marshal(keys)
+	return err
+}
+
+// saveConfig writes config to file, creating directories as needed. An
+// encrypted file stays encrypted with the same passphrase.
 func saveConfig(path string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if prev, err := os.ReadFile(path); err == nil && isEncryptedConfig(prev) {
+		if _, err := unlockConfig(path, prev); err != nil {
+			return err
+		}
+		if data, err = encryptConfig(data, configPassphrase); err != nil {
+			return err
+		}
+	}
+	return writeConfigData(path, data)
+}
+
+// writeConfigData replaces the config file with data. The previous file is
+// kept as a .bak copy if it was valid, and every file is replaced
+// atomically so a crash never leaves a partial write behind.
+func writeConfigData(path string, data []byte) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
 		return err
 	}
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	if prev, err := os.ReadFile(path); err == nil && json.Valid(prev) {
+		if err := writeFileAtomic(backupPath(path), prev, 0600); err != nil {
+			return fmt.Errorf("backing up config: %w", err)
+		}
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return err
+	}
+	return writeFileAtomic(checksumPath(path), []byte(checksum(data)+"\n"), 0600)
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames
+// it over path, so readers see either the old or the new content.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0600)
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
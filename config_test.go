@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -11,7 +15,7 @@ func TestLoadConfig_FromFile(t *testing.T) {
 	configPath := filepath.Join(dir, "config.json")
 	os.WriteFile(configPath, []byte(`{"app_key":"key1","app_secret":"secret1","refresh_token":"refresh1"}`), 0600)
 
-	cfg, err := loadConfig(configPath)
+	cfg, err := loadConfig(configPath, io.Discard)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -21,7 +25,7 @@ func TestLoadConfig_FromFile(t *testing.T) {
 }
 
 func TestLoadConfig_FileNotFound(t *testing.T) {
-	cfg, err := loadConfig("/nonexistent/config.json")
+	cfg, err := loadConfig("/nonexistent/config.json", io.Discard)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -39,7 +43,7 @@ func TestLoadConfig_EnvOverrides(t *testing.T) {
 	t.Setenv("DROPBOX_APP_SECRET", "env_secret")
 	t.Setenv("DROPBOX_REFRESH_TOKEN", "env_refresh")
 
-	cfg, err := loadConfig(configPath)
+	cfg, err := loadConfig(configPath, io.Discard)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -55,7 +59,7 @@ func TestLoadConfig_PartialEnvOverride(t *testing.T) {
 
 	t.Setenv("DROPBOX_APP_KEY", "env_key")
 
-	cfg, err := loadConfig(configPath)
+	cfg, err := loadConfig(configPath, io.Discard)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -77,7 +81,7 @@ func TestSaveConfig(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	loaded, err := loadConfig(configPath)
+	loaded, err := loadConfig(configPath, io.Discard)
 	if err != nil {
 		t.Fatalf("unexpected error loading: %v", err)
 	}
@@ -85,3 +89,102 @@ func TestSaveConfig(t *testing.T) {
 		t.Errorf("round-trip failed: %+v", loaded)
 	}
 }
+
+func TestSaveConfig_AtomicWithBackupAndChecksum(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+
+	if err := saveConfig(configPath, &Config{AppKey: "first"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := saveConfig(configPath, &Config{AppKey: "second"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bak, err := os.ReadFile(configPath + ".bak")
+	if err != nil || !strings.Contains(string(bak), "first") {
+		t.Errorf("expected previous config in .bak, got %q (err=%v)", bak, err)
+	}
+	data, _ := os.ReadFile(configPath)
+	sum, _ := os.ReadFile(configPath + ".sha256")
+	if strings.TrimSpace(string(sum)) != checksum(data) {
+		t.Errorf("checksum sidecar does not match config")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temp file left behind: %s", e.Name())
+		}
+	}
+	info, _ := os.Stat(configPath)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected 0600 permissions, got %v", info.Mode().Perm())
+	}
+}
+
+func TestLoadConfig_RecoversFromBackup(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	saveConfig(configPath, &Config{AppKey: "good", RefreshToken: "r"})
+	saveConfig(configPath, &Config{AppKey: "good", RefreshToken: "r2"})
+
+	// Simulate a write truncated by a crash.
+	os.WriteFile(configPath, []byte(`{"app_key":"go`), 0600)
+
+	var stderr bytes.Buffer
+	cfg, err := loadConfig(configPath, &stderr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.AppKey != "good" || cfg.RefreshToken != "r" {
+		t.Errorf("expected config from backup, got %+v", cfg)
+	}
+	if !strings.Contains(stderr.String(), "is corrupt") {
+		t.Errorf("expected a warning, got %q", stderr.String())
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != `{"app_key":"go` {
+		t.Errorf("loading should leave the file alone, got %q", data)
+	}
+
+	var stdout bytes.Buffer
+	if code := runConfig([]string{"restore", configPath}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("restore failed: %s", stderr.String())
+	}
+	stderr.Reset()
+	if cfg, err := loadConfig(configPath, &stderr); err != nil || cfg.RefreshToken != "r" || stderr.Len() != 0 {
+		t.Errorf("got %+v, %v, %q after restore", cfg, err, stderr.String())
+	}
+}
+
+func TestLoadConfig_CorruptWithoutBackup(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	os.WriteFile(configPath, []byte(`{not json`), 0600)
+
+	if _, err := loadConfig(configPath, io.Discard); err == nil {
+		t.Fatal("expected error for corrupt config without backup")
+	}
+}
+
+func TestLoadConfig_ModifiedOutsideTool(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	saveConfig(configPath, &Config{AppKey: "saved"})
+	os.WriteFile(configPath, []byte(`{"app_key":"edited"}`), 0600)
+
+	for i := 0; i < 2; i++ {
+		if _, err := loadConfig(configPath, io.Discard); !errors.Is(err, ErrConfigModified) {
+			t.Fatalf("load %d: expected the edit to be refused, got %v", i, err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runConfig([]string{"accept", configPath}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("accept failed: %s", stderr.String())
+	}
+	cfg, err := loadConfig(configPath, io.Discard)
+	if err != nil || cfg.AppKey != "edited" {
+		t.Errorf("got %+v, %v after accepting the edit", cfg, err)
+	}
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// configProblem is one problem found in a config file. Line and Col are
+// 1-based; zero means the position is unknown.
+type configProblem struct {
+	Line, Col int
+	Key       string // dotted path of the offending key, e.g. retry.budget
+	Msg       string
+}
+
+func (p configProblem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d: ", p.Line, p.Col)
+	}
+	if p.Key != "" {
+		fmt.Fprintf(&b, "%s: ", p.Key)
+	}
+	b.WriteString(p.Msg)
+	return b.String()
+}
+
+// lineCol converts a byte offset in data to a 1-based line and column.
+func lineCol(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// jsonErrorProblem locates a syntax or type error from encoding/json.
+func jsonErrorProblem(data []byte, err error) configProblem {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		// Offset is just past the offending character.
+		line, col := lineCol(data, max(syntax.Offset-1, 0))
+		return configProblem{Line: line, Col: col, Msg: "invalid JSON: " + syntax.Error()}
+	case errors.As(err, &typ):
+		line, col := lineCol(data, typ.Offset)
+		return configProblem{Line: line, Col: col, Key: typ.Field, Msg: fmt.Sprintf("expected %s, got JSON %s", typ.Type, typ.Value)}
+	}
+	return configProblem{Msg: err.Error()}
+}
+
+// configKeys walks the JSON in data alongside the Config type, returning
+// the position of every key by its dotted path, and a problem for each key
+// that Config doesn't have. Values of the wrong type are left to
+// json.Unmarshal to report.
+func configKeys(data []byte) (map[string]configProblem, []configProblem) {
+	w := &keyWalker{data: data, dec: json.NewDecoder(bytes.NewReader(data)), keys: map[string]configProblem{}}
+	w.value(reflect.TypeOf(Config{}), "")
+	return w.keys, w.unknown
+}
+
+// profilesType is the type of Config.Profiles, whose values are checked as
+// configs of their own.
+var profilesType = reflect.TypeOf(map[string]json.RawMessage{})
+
+type keyWalker struct {
+	data    []byte
+	dec     *json.Decoder
+	keys    map[string]configProblem
+	unknown []configProblem
+	err     error
+}
+
+// value consumes one JSON value, checking object keys against t.
+func (w *keyWalker) value(t reflect.Type, path string) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	tok, err := w.dec.Token()
+	if err != nil {
+		w.err = err
+		return
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return
+	}
+	switch delim {
+	case '[':
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for i := 0; w.err == nil && w.dec.More(); i++ {
+			w.value(elem, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case '{':
+		for w.err == nil && w.dec.More() {
+			keyTok, err := w.dec.Token()
+			if err != nil {
+				w.err = err
+				return
+			}
+			key := keyTok.(string)
+			end := w.dec.InputOffset()
+			line, col := lineCol(w.data, end-int64(len(key))-2)
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			w.keys[child] = configProblem{Line: line, Col: col, Key: child}
+
+			var ft reflect.Type
+			switch {
+			case t == nil:
+			case t == profilesType:
+				ft = reflect.TypeOf(Config{}) // a profile holds config keys
+			case t.Kind() == reflect.Map:
+				ft = t.Elem()
+			case t.Kind() == reflect.Struct:
+				f, ok := jsonField(t, key)
+				if !ok {
+					w.unknown = append(w.unknown, configProblem{Line: line, Col: col, Key: child, Msg: "unknown key" + suggestKey(t, key)})
+				}
+				ft = f
+			}
+			w.value(ft, child)
+		}
+	}
+	if w.err == nil {
+		_, w.err = w.dec.Token() // the closing delimiter
+	}
+}
+
+// jsonField returns the type of the struct field encoded as key, matching
+// case-insensitively as encoding/json does.
+func jsonField(t reflect.Type, key string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.EqualFold(name, key) {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+// suggestKey returns a "did you mean" hint for a misspelt key: a field
+// whose name differs only in separators or by up to two edits.
+func suggestKey(t reflect.Type, key string) string {
+	norm := func(s string) string { return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s)) }
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if norm(name) == norm(key) || editDistance(name, key) <= 2 {
+			return fmt.Sprintf(" (did you mean %q?)", name)
+		}
+	}
+	return ""
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// validateConfig checks a config file strictly: JSON syntax, unknown keys,
+// value types, and the settings other commands would only reject when
+// they use them (sizes, durations, templates, plugin and fallback
+// settings). Problems are returned in file order where known.
+func validateConfig(data []byte) []configProblem {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		p := jsonErrorProblem(data, err)
+		// A type error's offset is past the value; point at its key.
+		keys, _ := configKeys(data)
+		if key, ok := keys[p.Key]; ok && p.Key != "" {
+			p.Line, p.Col = key.Line, key.Col
+		}
+		return []configProblem{p}
+	}
+	keys, problems := configKeys(data)
+	// at reports err at key, or at its nearest parent with a known position
+	// (array elements such as plugins[0] have none of their own).
+	at := func(key string, err error) {
+		p, parent := configProblem{}, key
+		for parent != "" {
+			var ok bool
+			if p, ok = keys[parent]; ok {
+				break
+			}
+			parent = parent[:max(strings.LastIndexAny(parent, ".["), 0)]
+		}
+		p.Key, p.Msg = key, err.Error()
+		problems = append(problems, p)
+	}
+
+	if _, err := retryPolicy(cfg); err != nil {
+		at("retry", err)
+	}
+	if cfg.MaxNoteSize != "" {
+		if _, err := parseSize(cfg.MaxNoteSize); err != nil {
+			at("max_note_size", err)
+		}
+	}
+	if cfg.MaxDownloadSize != "" {
+		if _, err := parseSize(cfg.MaxDownloadSize); err != nil {
+			at("max_download_size", err)
+		}
+	}
+	if c := cfg.Image; c != nil {
+		if err := c.validate(); err != nil {
+			at("image", err)
+		}
+	}
+	if cfg.ShowTail < 0 {
+		at("show_tail", errors.New("must not be negative"))
+	}
+	if err := validateSections(cfg.Sections); err != nil {
+		at("sections", err)
+	}
+	if d := cfg.DayLinks; d != nil && d.Lookback < 0 {
+		at("day_links.lookback", errors.New("must not be negative"))
+	}
+	if o := cfg.Obsidian; o != nil && o.Vault == "" {
+		at("obsidian.vault", errors.New("is required"))
+	}
+	if err := validCodeLang(cfg.CodeLang); err != nil {
+		at("code_lang", err)
+	}
+	if f := cfg.Fold; f != nil {
+		if f.Lines < 0 {
+			at("fold.lines", errors.New("must not be negative"))
+		}
+		if err := validFoldSyntax(f.Syntax); err != nil {
+			at("fold.syntax", err)
+		}
+	}
+	if _, err := parseBandwidth(cfg.BWLimit); err != nil {
+		at("bwlimit", err)
+	}
+	if _, err := newAPICache(cfg); err != nil {
+		at("api_cache_ttl", err)
+	}
+	for key, base := range cfg.Endpoints {
+		if err := checkEndpointOverride(key, base); err != nil {
+			at("endpoints."+key, err)
+		}
+	}
+	for name, raw := range cfg.Profiles {
+		if err := json.Unmarshal(raw, &Config{}); err != nil {
+			at("profiles."+name, err)
+		}
+	}
+	for i, r := range cfg.ProfileRules {
+		key := fmt.Sprintf("profile_rules[%d]", i)
+		if _, ok := cfg.Profiles[r.Profile]; !ok {
+			at(key, fmt.Errorf("unknown profile %q", r.Profile))
+		}
+		if r.Hostname == "" && r.SSID == "" && r.Interface == "" {
+			at(key, errors.New("needs hostname, ssid or interface"))
+		}
+		for _, pattern := range []string{r.Hostname, r.SSID, r.Interface} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				at(key, fmt.Errorf("bad pattern %q", pattern))
+			}
+		}
+	}
+	for i, pattern := range cfg.NotifyPaths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			at(fmt.Sprintf("notify_paths[%d]", i), fmt.Errorf("bad pattern %q", pattern))
+		}
+	}
+	if cfg.Theme != nil {
+		if err := cfg.Theme.validate(); err != nil {
+			at("theme", err)
+		}
+	}
+	if _, err := separatorText(cfg.Separator); err != nil {
+		at("separator", err)
+	}
+	if _, err := lineEnding(cfg.LineEndings, ""); err != nil {
+		at("line_endings", err)
+	}
+	if err := validTargetFormat(cfg.TargetFormat); err != nil {
+		at("target_format", err)
+	}
+	if cfg.PathTemplate != "" {
+		p, err := renderPathTemplate(cfg.PathTemplate, time.Now())
+		if err == nil {
+			_, err = scopePath(appFolderName(cfg.AppFolder), p)
+		}
+		if err != nil {
+			at("path_template", err)
+		}
+	}
+	if cfg.BookmarkTemplate != "" {
+		if _, err := formatBookmark(cfg.BookmarkTemplate, bookmark{}); err != nil {
+			at("bookmark_template", err)
+		}
+	}
+	if cfg.GitMirror != nil {
+		if cfg.GitMirror.Repo == "" {
+			at("git_mirror", errors.New("repo is required"))
+		}
+		if _, err := template.New("message").Parse(cfg.GitMirror.Message); err != nil {
+			at("git_mirror.message", err)
+		}
+	}
+	if cfg.Fallback != nil {
+		switch cfg.Fallback.Type {
+		case "file", "ntfy", "gist":
+		default:
+			at("fallback.type", fmt.Errorf("unknown type %q: expected file, ntfy or gist", cfg.Fallback.Type))
+		}
+	}
+	if _, err := newDayEndScheduler(cfg); err != nil {
+		at("day_summary", err)
+	}
+	for i, p := range cfg.Plugins {
+		key := fmt.Sprintf("plugins[%d]", i)
+		if p.Module == "" {
+			at(key, errors.New("module is required"))
+		}
+		if p.Timeout != "" {
+			if _, err := time.ParseDuration(p.Timeout); err != nil {
+				at(key+".timeout", err)
+			}
+		}
+	}
+	if cfg.SpaceWarning < 0 || cfg.SpaceWarning > 100 {
+		at("space_warning", errors.New("must be a percentage between 0 and 100"))
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		li, lj := problems[i].Line, problems[j].Line
+		return li != 0 && (lj == 0 || li < lj)
+	})
+	return problems
+}
+
+// configCommands are the actions of the config subcommand.
+var configCommands = []string{"validate", "encrypt", "decrypt", "profile", "accept", "restore"}
+
+// runConfig implements the `dropbox-appender config` subcommand: validate
+// checks the config file (or the file given) and reports every problem
+// with its line and column, encrypt and decrypt protect the file with a
+// passphrase or remove it again, accept trusts a file edited by hand and
+// restore replaces a corrupt file with its backup. It returns the process
+// exit code.
+func runConfig(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || !slices.Contains(configCommands, args[0]) {
+		fmt.Fprintf(stderr, "usage: dropbox-appender config %s [file]\n", strings.Join(configCommands, "|"))
+		return 2
+	}
+	fs := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	path := defaultConfigPath()
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	switch args[0] {
+	case "encrypt":
+		return runConfigEncrypt(stdout, stderr, path)
+	case "decrypt":
+		return runConfigDecrypt(stdout, stderr, path)
+	case "profile":
+		return runConfigProfile(stdout, stderr, path, currentEnv())
+	case "accept":
+		return runConfigAccept(stdout, stderr, path)
+	case "restore":
+		return runConfigRestore(stdout, stderr, path)
+	}
+	return runConfigValidate(stdout, stderr, path)
+}
+
+// runConfigAccept records the checksum of a config file changed outside
+// this tool, after checking it strictly, so it is loaded again.
+func runConfigAccept(stdout, stderr io.Writer, path string) int {
+	if code := runConfigValidate(io.Discard, stderr, path); code != 0 {
+		return code
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = writeFileAtomic(checksumPath(path), []byte(checksum(data)+"\n"), 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s: accepted\n", path)
+	return 0
+}
+
+// runConfigRestore replaces the config file with its .bak copy.
+func runConfigRestore(stdout, stderr io.Writer, path string) int {
+	data, err := os.ReadFile(backupPath(path))
+	if err == nil {
+		var plain []byte
+		if plain, err = unlockConfig(backupPath(path), data); err == nil && !json.Valid(plain) {
+			err = fmt.Errorf("%s is corrupt too", backupPath(path))
+		}
+	}
+	if err == nil {
+		err = writeFileAtomic(path, data, 0600)
+	}
+	if err == nil {
+		err = writeFileAtomic(checksumPath(path), []byte(checksum(data)+"\n"), 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s: restored from %s\n", path, backupPath(path))
+	return 0
+}
+
+// runConfigValidate is the testable core of `config validate`.
+func runConfigValidate(stdout, stderr io.Writer, path string) int {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		// Positions in an encrypted file refer to its decrypted JSON.
+		data, err = unlockConfig(path, data)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	problems := validateConfig(data)
+	for _, p := range problems {
+		if p.Line > 0 {
+			fmt.Fprintf(stderr, "%s:%s\n", path, p)
+		} else {
+			fmt.Fprintf(stderr, "%s: %s\n", path, p)
+		}
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s: OK\n", path)
+	return 0
+}
+
+// runConfigProfile prints the profile the config at path selects on this
+// machine, and why.
+func runConfigProfile(stdout, stderr io.Writer, path string, env machineEnv) int {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	name, reason := selectProfile(selectedProfile, cfg.ProfileRules, env)
+	if name == "" {
+		fmt.Fprintln(stdout, "no profile")
+		return 0
+	}
+	if _, ok := cfg.Profiles[name]; !ok {
+		fmt.Fprintf(stderr, "error: unknown profile %q (%s)\n", name, reason)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s (%s)\n", name, reason)
+	return 0
+}
+
+// runConfigEncrypt encrypts the config file at path with a new passphrase,
+// taken from DROPBOX_APPENDER_PASSPHRASE or asked for twice.
+func runConfigEncrypt(stdout, stderr io.Writer, path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if isEncryptedConfig(data) {
+		fmt.Fprintf(stderr, "error: %s is already encrypted\n", path)
+		return 1
+	}
+	if !json.Valid(data) {
+		fmt.Fprintf(stderr, "error: %s is not valid JSON; run `dropbox-appender config validate` first\n", path)
+		return 1
+	}
+	pass := os.Getenv(passphraseEnv)
+	if pass == "" {
+		if pass, err = readPassphrase("New passphrase: "); err == nil {
+			var again string
+			if again, err = readPassphrase("Repeat passphrase: "); err == nil && again != pass {
+				err = errors.New("the passphrases don't match")
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+	}
+	if pass == "" {
+		fmt.Fprintln(stderr, "error: the passphrase is empty")
+		return 1
+	}
+	sealed, err := encryptConfig(data, pass)
+	if err == nil {
+		err = writeConfigData(path, sealed)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error saving config: %v\n"), err)
+		return 1
+	}
+	// The backup holds the plain text; don't leave it behind.
+	os.Remove(backupPath(path))
+	fmt.Fprintf(stdout, "Encrypted %s; set %s for unattended runs\n", path, passphraseEnv)
+	return 0
+}
+
+// runConfigDecrypt turns the encrypted config file at path back into plain
+// JSON.
+func runConfigDecrypt(stdout, stderr io.Writer, path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if !isEncryptedConfig(data) {
+		fmt.Fprintf(stderr, "error: %s is not encrypted\n", path)
+		return 1
+	}
+	plain, err := unlockConfig(path, data)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if err := writeConfigData(path, plain); err != nil {
+		fmt.Fprintf(stderr, tr("error saving config: %v\n"), err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Decrypted %s\n", path)
+	return 0
+}
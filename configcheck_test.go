@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{"valid", `{"app_key": "k", "retry": {"budget": "10s"}, "plugins": [{"module": "a.wasm"}]}`, nil},
+		{"syntax error", "{\n  \"app_key\": \"k\",\n  \"wrap\": 80,\n}", []string{"4:1: invalid JSON"}},
+		{"wrong type", "{\n  \"wrap\": \"80\"\n}", []string{"2:3: wrap: expected int"}},
+		{"unknown keys", "{\n  \"app_key\": \"k\",\n  \"seperator\": \"rule\",\n  \"retry\": {\"budgett\": \"1s\"}\n}",
+			[]string{`3:3: seperator: unknown key (did you mean "separator"?)`, `4:13: retry.budgett: unknown key (did you mean "budget"?)`}},
+		{"map values are not keys", `{"shortcodes": {"anything": "goes"}}`, nil},
+		{"bad values", "{\n  \"max_note_size\": \"lots\",\n  \"plugins\": [{\"timeout\": \"soon\"}]\n}",
+			[]string{`2:3: max_note_size: invalid size "lots"`, "3:3: plugins[0]: module is required", "3:16: plugins[0].timeout:"}},
+		{"bad template", `{"path_template": "/J/{{.Nope}}.md"}`, []string{"1:2: path_template:"}},
+		{"outside the app folder", `{"app_folder": "Journal", "path_template": "/Apps/Other/{{.Date}}.md"}`,
+			[]string{"1:27: path_template: /Apps/Other/"}},
+		{"profiles", "{\n  \"profiles\": {\"work\": {\"wrapp\": 72}},\n  \"profile_rules\": [{\"profile\": \"home\"}]\n}",
+			[]string{`2:25: profiles.work.wrapp: unknown key (did you mean "wrap"?)`, `3:3: profile_rules[0]: unknown profile "home"`, "3:3: profile_rules[0]: needs hostname"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := validateConfig([]byte(tt.json))
+			if len(problems) != len(tt.want) {
+				t.Fatalf("problems = %v, want %d", problems, len(tt.want))
+			}
+			for i, p := range problems {
+				if !strings.HasPrefix(p.String(), tt.want[i]) {
+					t.Errorf("problem %d = %q, want prefix %q", i, p, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(good, []byte(`{"app_key": "k"}`), 0600)
+	os.WriteFile(bad, []byte("{\n  \"apk_key\": \"k\"\n}"), 0600)
+
+	var stdout, stderr bytes.Buffer
+	if code := runConfigValidate(&stdout, &stderr, good); code != 0 || !strings.Contains(stdout.String(), "OK") {
+		t.Errorf("good config: code %d, %q %q", code, stdout.String(), stderr.String())
+	}
+	stderr.Reset()
+	if code := runConfigValidate(&stdout, &stderr, bad); code != 1 {
+		t.Errorf("bad config: code %d, want 1", code)
+	}
+	if want := bad + `:2:3: apk_key: unknown key (did you mean "app_key"?)`; !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+}
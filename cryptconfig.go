@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// passphraseEnv names the env var holding the config passphrase, for
+// non-interactive runs.
+const passphraseEnv = "DROPBOX_APPENDER_PASSPHRASE"
+
+// encryptedConfigFormat marks an encrypted config file.
+const encryptedConfigFormat = "dropbox-appender-aes-gcm-v1"
+
+// configKDFIterations is the PBKDF2-SHA256 work factor for new files. The
+// count is stored in each file, so it can be raised without breaking
+// existing ones. (scrypt would be stronger per CPU second, but isn't in the
+// standard library.)
+const configKDFIterations = 600000
+
+// maxConfigKDFIterations bounds the work factor read from a file, so a
+// tampered header can't make every run spin for hours before the
+// passphrase is even checked.
+const maxConfigKDFIterations = 10000000
+
+// encryptedConfig is the on-disk form of an encrypted config file. It is
+// JSON so backups and checksums work on it as on a plain config.
+type encryptedConfig struct {
+	Format     string `json:"encrypted"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// configPassphrase is the passphrase that unlocked the config file, kept so
+// saveConfig can encrypt the file again without asking twice.
+var configPassphrase string
+
+// readPassphrase asks the user for the config passphrase; replaced in tests.
+var readPassphrase = promptPassphrase
+
+// errBadPassphrase is returned when a config file can't be decrypted.
+var errBadPassphrase = errors.New("wrong passphrase, or the file is damaged")
+
+// isEncryptedConfig reports whether data is an encrypted config file.
+func isEncryptedConfig(data []byte) bool {
+	var env struct {
+		Format string `json:"encrypted"`
+	}
+	return json.Unmarshal(data, &env) == nil && env.Format == encryptedConfigFormat
+}
+
+// configKey derives the AES-256 key for passphrase and salt.
+func configKey(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptConfig seals plain, a JSON config, with passphrase.
+func encryptConfig(plain []byte, passphrase string) ([]byte, error) {
+	env := encryptedConfig{Format: encryptedConfigFormat, KDF: "pbkdf2-sha256", Iterations: configKDFIterations, Salt: make([]byte, 16)}
+	rand.Read(env.Salt)
+	gcm, err := configKey(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	rand.Read(env.Nonce)
+	// The header is authenticated too, so its fields can't be swapped.
+	env.Data = gcm.Seal(nil, env.Nonce, plain, []byte(env.Format))
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// decryptConfig opens an encrypted config file with passphrase.
+func decryptConfig(data []byte, passphrase string) ([]byte, error) {
+	var env encryptedConfig
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	if env.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported key derivation %q", env.KDF)
+	}
+	if env.Iterations <= 0 || env.Iterations > maxConfigKDFIterations {
+		return nil, fmt.Errorf("key derivation iterations %d out of range (1 to %d)", env.Iterations, maxConfigKDFIterations)
+	}
+	gcm, err := configKey(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, errBadPassphrase
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Data, []byte(env.Format))
+	if err != nil {
+		return nil, errBadPassphrase
+	}
+	return plain, nil
+}
+
+// unlockConfig returns the JSON inside data when it is an encrypted config
+// file, asking for the passphrase unless DROPBOX_APPENDER_PASSPHRASE is set
+// or it was already entered; plain configs are returned unchanged.
+func unlockConfig(path string, data []byte) ([]byte, error) {
+	if !isEncryptedConfig(data) {
+		return data, nil
+	}
+	pass := configPassphrase
+	if pass == "" {
+		pass = os.Getenv(passphraseEnv)
+	}
+	if pass == "" {
+		var err error
+		if pass, err = readPassphrase(fmt.Sprintf("Passphrase for %s: ", path)); err != nil {
+			return nil, fmt.Errorf("%s is encrypted: %w", path, err)
+		}
+	}
+	plain, err := decryptConfig(data, pass)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", path, err)
+	}
+	configPassphrase = pass
+	return plain, nil
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing
+// it. stdin is left alone, since it usually carries the entry.
+func promptPassphrase(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for the passphrase; set %s", passphraseEnv)
+	}
+	defer tty.Close()
+	fmt.Fprint(tty, prompt)
+	if stty("-echo", tty) == nil {
+		defer func() {
+			stty("echo", tty)
+			fmt.Fprintln(tty)
+		}()
+	}
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// stty changes a terminal setting of tty.
+func stty(setting string, tty *os.File) error {
+	cmd := exec.Command("stty", setting)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
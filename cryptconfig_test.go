@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubPassphrase answers passphrase prompts with answers in turn, and
+// forgets the passphrase of earlier tests.
+func stubPassphrase(t *testing.T, answers ...string) *int {
+	t.Helper()
+	asked := 0
+	old := readPassphrase
+	readPassphrase = func(string) (string, error) {
+		if asked >= len(answers) {
+			return "", errors.New("no terminal")
+		}
+		asked++
+		return answers[asked-1], nil
+	}
+	configPassphrase = ""
+	t.Cleanup(func() {
+		readPassphrase = old
+		configPassphrase = ""
+	})
+	return &asked
+}
+
+func TestEncryptDecryptConfig(t *testing.T) {
+	plain := []byte(`{"refresh_token":"secret"}`)
+	sealed, err := encryptConfig(plain, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedConfig(sealed) || bytes.Contains(sealed, []byte("secret")) {
+		t.Fatalf("not encrypted: %s", sealed)
+	}
+	got, err := decryptConfig(sealed, "hunter2")
+	if err != nil || string(got) != string(plain) {
+		t.Fatalf("decryptConfig = %q, %v", got, err)
+	}
+	if _, err := decryptConfig(sealed, "hunter3"); !errors.Is(err, errBadPassphrase) {
+		t.Errorf("wrong passphrase: err = %v", err)
+	}
+	if isEncryptedConfig(plain) {
+		t.Error("plain config reported as encrypted")
+	}
+}
+
+func TestDecryptConfig_CapsIterations(t *testing.T) {
+	sealed, err := encryptConfig([]byte(`{}`), "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env encryptedConfig
+	json.Unmarshal(sealed, &env)
+	env.Iterations = maxConfigKDFIterations + 1
+	tampered, _ := json.Marshal(env)
+	if _, err := decryptConfig(tampered, "hunter2"); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("expected the iteration count to be refused, got %v", err)
+	}
+}
+
+func TestLoadConfig_Encrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	sealed, _ := encryptConfig([]byte(`{"app_key":"key1","refresh_token":"refresh1"}`), "hunter2")
+	os.WriteFile(path, sealed, 0600)
+
+	t.Run("env", func(t *testing.T) {
+		asked := stubPassphrase(t)
+		t.Setenv(passphraseEnv, "hunter2")
+		cfg, err := loadConfig(path, io.Discard)
+		if err != nil || cfg.RefreshToken != "refresh1" {
+			t.Fatalf("loadConfig = %+v, %v", cfg, err)
+		}
+		if *asked != 0 {
+			t.Error("prompted despite the env var")
+		}
+	})
+	t.Run("prompt", func(t *testing.T) {
+		stubPassphrase(t, "hunter2")
+		cfg, err := loadConfig(path, io.Discard)
+		if err != nil || cfg.AppKey != "key1" {
+			t.Fatalf("loadConfig = %+v, %v", cfg, err)
+		}
+	})
+	t.Run("wrong passphrase", func(t *testing.T) {
+		stubPassphrase(t, "nope")
+		if _, err := loadConfig(path, io.Discard); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
+
+func TestSaveConfig_KeepsEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	sealed, _ := encryptConfig([]byte(`{"refresh_token":"old"}`), "hunter2")
+	os.WriteFile(path, sealed, 0600)
+	asked := stubPassphrase(t, "hunter2")
+
+	cfg, err := loadConfig(path, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.RefreshToken = "new"
+	if err := saveConfig(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if *asked != 1 {
+		t.Errorf("asked for the passphrase %d times, want 1", *asked)
+	}
+	data, _ := os.ReadFile(path)
+	if !isEncryptedConfig(data) {
+		t.Fatalf("saved config is not encrypted: %s", data)
+	}
+	configPassphrase = ""
+	t.Setenv(passphraseEnv, "hunter2")
+	if cfg, err := loadConfig(path, io.Discard); err != nil || cfg.RefreshToken != "new" {
+		t.Errorf("reloaded = %+v, %v", cfg, err)
+	}
+}
+
+func TestRunConfigEncryptDecrypt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"refresh_token":"secret"}`), 0600)
+
+	stubPassphrase(t, "a", "b")
+	var stdout, stderr bytes.Buffer
+	if code := runConfigEncrypt(&stdout, &stderr, path); code != 1 || !strings.Contains(stderr.String(), "don't match") {
+		t.Fatalf("mismatched passphrases: exit %d, stderr %q", code, stderr.String())
+	}
+
+	stubPassphrase(t, "hunter2", "hunter2")
+	stderr.Reset()
+	if code := runConfigEncrypt(&stdout, &stderr, path); code != 0 {
+		t.Fatalf("encrypt: exit %d: %s", code, stderr.String())
+	}
+	data, _ := os.ReadFile(path)
+	if !isEncryptedConfig(data) {
+		t.Fatalf("not encrypted: %s", data)
+	}
+	if _, err := os.Stat(backupPath(path)); err == nil {
+		t.Error("plain-text backup left behind")
+	}
+
+	stubPassphrase(t, "hunter2")
+	if code := runConfigDecrypt(&stdout, &stderr, path); code != 0 {
+		t.Fatalf("decrypt: exit %d: %s", code, stderr.String())
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != `{"refresh_token":"secret"}` {
+		t.Errorf("decrypted = %s", data)
+	}
+}
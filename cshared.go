@@ -0,0 +1,43 @@
+//go:build cshared
+
+// Build with:
+//
+//	go build -tags cshared -buildmode=c-shared -o libdropboxappender.so .
+//
+// which also writes libdropboxappender.h declaring the exports below.
+
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+// AppendEntry appends text to today's note using configJSON, a config
+// document in the same format as config.json (or empty to read the user's
+// config file). It returns NULL on success or an error message that the
+// caller must release with FreeString.
+//
+//export AppendEntry
+func AppendEntry(configJSON, text *C.char) *C.char {
+	cfg, err := configFromJSON(C.GoString(configJSON))
+	if err == nil {
+		_, err = appendText(cfg, C.GoString(text), time.Now())
+	}
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// FreeString releases a string returned by AppendEntry.
+//
+//export FreeString
+func FreeString(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
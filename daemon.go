@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultDaemonInterval is how often the daemon flushes the queue.
+const defaultDaemonInterval = 30 * time.Second
+
+// queuedEntry is an entry captured with `-queue`, waiting for the daemon to
+// append it to its note.
+type queuedEntry struct {
+	Path     string    `json:"path"`
+	Entry    string    `json:"entry"`
+	QueuedAt time.Time `json:"queued_at"`
+	queuedOptions
+}
+
+// queuedOptions are the append options given for a queued entry on the
+// command line, applied on top of the config when it's flushed.
+type queuedOptions struct {
+	Author   string `json:"author,omitempty"`
+	Number   bool   `json:"number,omitempty"`
+	EntryIDs bool   `json:"entry_ids,omitempty"`
+	Atomic   bool   `json:"atomic,omitempty"`
+}
+
+// apply returns opts with q's options added.
+func (q queuedOptions) apply(opts appendOptions) appendOptions {
+	if q.Author != "" {
+		opts.Author = q.Author
+	}
+	opts.Number = opts.Number || q.Number
+	opts.EntryIDs = opts.EntryIDs || q.EntryIDs
+	opts.Atomic = opts.Atomic || q.Atomic
+	return opts
+}
+
+// queuedFile is a queued entry and the file holding it.
+type queuedFile struct {
+	Name string
+	queuedEntry
+}
+
+// defaultQueueDir returns the directory holding queued entries.
+func defaultQueueDir() string {
+	return filepath.Join(defaultDataDir(), "queue")
+}
+
+// enqueueEntry stores entry for path in dir, with no options of its own.
+func enqueueEntry(dir, path, entry string, now time.Time) error {
+	return enqueue(dir, queuedEntry{Path: path, Entry: entry, QueuedAt: now})
+}
+
+// enqueue stores q in dir. File names sort in capture order, so entries are
+// appended in the order they were written.
+func enqueue(dir string, q queuedEntry) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%020d-%d.json", q.QueuedAt.UnixNano(), os.Getpid())
+	return writeFileAtomic(filepath.Join(dir, name), data, 0600)
+}
+
+// loadQueue returns the queued entries in dir in capture order. A missing
+// directory is an empty queue; unreadable files are reported and skipped.
+func loadQueue(dir string, stderr io.Writer) ([]queuedFile, error) {
+	des, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var queue []queuedFile
+	for _, de := range des {
+		if de.IsDir() || strings.HasPrefix(de.Name(), ".") || filepath.Ext(de.Name()) != ".json" {
+			continue
+		}
+		q := queuedFile{Name: de.Name()}
+		data, err := os.ReadFile(filepath.Join(dir, de.Name()))
+		if err == nil {
+			err = json.Unmarshal(data, &q.queuedEntry)
+		}
+		if err != nil || q.Path == "" {
+			fmt.Fprintf(stderr, "warning: skipping queued entry %s: %v\n", de.Name(), err)
+			continue
+		}
+		queue = append(queue, q)
+	}
+	sort.Slice(queue, func(i, j int) bool { return queue[i].Name < queue[j].Name })
+	return queue, nil
+}
+
+// flushQueue appends every queued entry in dir, grouping them by note and
+// options so each note is usually downloaded and uploaded once. Entries are removed once their
+// note has been written; a note that fails keeps its entries for the next
+// flush. Many notes are committed in one batch (see uploadBatch). It
+// returns how many entries were appended.
+func flushQueue(client *DropboxClient, dir string, opts appendOptions, stderr io.Writer) (int, error) {
+	queue, err := loadQueue(dir, stderr)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []queueGroup
+	groups := map[queueGroup][]queuedFile{}
+	paths := map[string]bool{}
+	for _, q := range queue {
+		k := queueGroup{q.Path, q.queuedOptions}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], q)
+		paths[q.Path] = true
+	}
+
+	// With many notes to write, their uploads are committed together,
+	// unless a note has entries with different options: its second append
+	// must see the first.
+	var uploads *uploadBatch
+	if len(keys) >= minBatchNotes && len(paths) == len(keys) {
+		uploads = &uploadBatch{}
+		opts.Uploads = uploads
+	}
+	flushed := 0
+	done := func(k queueGroup, written string) {
+		for _, q := range groups[k] {
+			os.Remove(filepath.Join(dir, q.Name))
+		}
+		flushed += len(groups[k])
+		fmt.Fprintf(stderr, "Appended %d queued entries to %s\n", len(groups[k]), written)
+	}
+	parts := map[queueGroup]string{}
+	for _, k := range keys {
+		var entries []string
+		for _, q := range groups[k] {
+			entries = append(entries, q.Entry)
+		}
+		written, err := appendEntries(client, k.Path, entries, k.apply(opts))
+		if err != nil {
+			fmt.Fprintf(stderr, "warning: flushing %d entries to %s: %v\n", len(entries), k.Path, err)
+			continue
+		}
+		if uploads == nil {
+			done(k, written)
+			continue
+		}
+		parts[k] = written
+	}
+	if uploads != nil {
+		errs := uploads.commit(client)
+		for _, k := range keys {
+			written, ok := parts[k]
+			if !ok {
+				continue
+			}
+			if err := errs[written]; err != nil {
+				fmt.Fprintf(stderr, "warning: flushing %d entries to %s: %v\n", len(groups[k]), k.Path, err)
+				continue
+			}
+			done(k, written)
+		}
+	}
+	return flushed, nil
+}
+
+// queueGroup is the note and options shared by queued entries appended
+// together.
+type queueGroup struct {
+	Path string
+	queuedOptions
+}
+
+// runDaemon implements the `dropbox-appender daemon` subcommand, flushing
+// entries captured with `-queue` on an interval until interrupted. It
+// returns the process exit code.
+func runDaemon(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	interval := fs.Duration("interval", defaultDaemonInterval, "how often to flush queued entries")
+	once := fs.Bool("once", false, "flush the queue once and exit")
+	gcInterval := fs.Duration("gc-interval", 0, "also remove expired entries (see -expires) at most this often, e.g. 6h; 0 disables")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if refuseReadOnly(stderr, "daemon") {
+		return 1
+	}
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+
+	dayEnd, err := newDayEndScheduler(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+
+	var stop chan os.Signal
+	if !*once {
+		stop = make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(stop)
+	}
+	var gc *gcScheduler
+	if *gcInterval > 0 {
+		gc = &gcScheduler{cfg: cfg, opts: opts, interval: *gcInterval}
+	}
+	return runDaemonWithClient(stderr, client, defaultQueueDir(), opts, *interval, stop, dayEnd, gc)
+}
+
+// runDaemonWithClient is the testable core of the daemon subcommand. It
+// flushes immediately, then every interval until stop fires, flushing once
+// more before returning. A nil stop flushes once. After each flush, dayEnd
+// (when not nil) appends the day summary once it is due, and gc (when not
+// nil) removes expired entries.
+func runDaemonWithClient(stderr io.Writer, client *DropboxClient, dir string,
+	opts appendOptions, interval time.Duration, stop <-chan os.Signal, dayEnd *dayEndScheduler, gc *gcScheduler) int {
+
+	flush := func() bool {
+		recoverInflight(client, opts.InflightDir, opts, stderr)
+		if _, err := flushQueue(client, dir, opts, stderr); err != nil {
+			fmt.Fprintf(stderr, "error: reading queue: %v\n", err)
+			return false
+		}
+		if dayEnd != nil {
+			dayEnd.run(client, time.Now(), opts, stderr)
+		}
+		if gc != nil {
+			gc.run(client, time.Now(), stderr)
+		}
+		return true
+	}
+	if !flush() {
+		return 1
+	}
+	if stop == nil {
+		return 0
+	}
+	done := make(chan struct{})
+	defer close(done)
+	serviceReady(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			flush()
+		case <-stop:
+			sdNotify("STOPPING=1")
+			if !flush() {
+				return 1
+			}
+			return 0
+		}
+	}
+}
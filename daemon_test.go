@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnqueueAndLoadQueue(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	enqueueEntry(dir, "/b.md", "second\n", now.Add(time.Second))
+	enqueueEntry(dir, "/a.md", "first\n", now)
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600)
+
+	var stderr bytes.Buffer
+	queue, err := loadQueue(dir, &stderr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queue) != 2 || queue[0].Path != "/a.md" || queue[1].Entry != "second\n" {
+		t.Errorf("expected entries in capture order, got %+v", queue)
+	}
+	if !strings.Contains(stderr.String(), "broken.json") {
+		t.Errorf("expected a warning for the broken file, got %q", stderr.String())
+	}
+
+	if queue, err := loadQueue(filepath.Join(t.TempDir(), "missing"), &stderr); err != nil || queue != nil {
+		t.Errorf("missing queue dir should be empty, got %v, %v", queue, err)
+	}
+}
+
+func TestFlushQueue_BatchesPerNote(t *testing.T) {
+	a, b := "/Notes/Journal/2025/01/Note20250114.md", "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{b: "### 08:00:00\nexisting\n"}
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg struct {
+			Path string `json:"path"`
+		}
+		json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+		calls[r.URL.Path+" "+arg.Path]++
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/download"):
+			content, ok := files[arg.Path]
+			if !ok {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+				return
+			}
+			w.Write([]byte(content))
+		case strings.HasSuffix(r.URL.Path, "/upload"):
+			body, _ := io.ReadAll(r.Body)
+			files[arg.Path] = string(body)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	enqueueEntry(dir, b, "### 09:00:00\none\n", now)
+	enqueueEntry(dir, a, "### 23:59:00\nlate\n", now.Add(time.Second))
+	enqueueEntry(dir, b, "### 09:00:05\ntwo\n", now.Add(2*time.Second))
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	n, err := flushQueue(client, dir, appendOptions{Number: true}, io.Discard)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 entries flushed, got %d, %v", n, err)
+	}
+	if calls["/2/files/upload "+b] != 1 || calls["/2/files/download "+b] != 1 {
+		t.Errorf("expected one download/upload for %s, got %v", b, calls)
+	}
+	want := "### 08:00:00\nexisting\n\n### 2. 09:00:00\n<a id=\"note20250115-2\"></a>\none\n\n" +
+		"### 3. 09:00:05\n<a id=\"note20250115-3\"></a>\ntwo\n"
+	if files[b] != want {
+		t.Errorf("unexpected content:\n got %q\nwant %q", files[b], want)
+	}
+	if queue, _ := loadQueue(dir, io.Discard); len(queue) != 0 {
+		t.Errorf("expected an empty queue, got %+v", queue)
+	}
+}
+
+func TestFlushQueue_AppliesQueuedOptions(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: ""}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	enqueueEntry(dir, path, "### 09:00:00\nplain\n", now)
+	enqueue(dir, queuedEntry{Path: path, Entry: "### 09:00:05\nsigned\n", QueuedAt: now.Add(time.Second),
+		queuedOptions: queuedOptions{Author: "alice"}})
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if n, err := flushQueue(client, dir, appendOptions{}, io.Discard); err != nil || n != 2 {
+		t.Fatalf("expected 2 entries flushed, got %d, %v", n, err)
+	}
+	want := "### 09:00:00\nplain\n\n" + attributeEntry("### 09:00:05\nsigned\n", "alice")
+	if files[path] != want {
+		t.Errorf("unexpected content:\n got %q\nwant %q", files[path], want)
+	}
+}
+
+func TestFlushQueue_KeepsFailedEntries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	enqueueEntry(dir, "/a.md", "x\n", time.Now())
+	var stderr bytes.Buffer
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if code := runDaemonWithClient(&stderr, client, dir, appendOptions{}, time.Second, nil, nil, nil); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if queue, _ := loadQueue(dir, io.Discard); len(queue) != 1 {
+		t.Errorf("failed entry should stay queued, got %+v", queue)
+	}
+	if !strings.Contains(stderr.String(), "warning: flushing 1 entries to /a.md") {
+		t.Errorf("expected a warning, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// heatmapShades are the cells of the heatmap, from no entries to the most.
+var heatmapShades = []string{"·", "░", "▒", "▓", "█"}
+
+const ansiReverse = "\x1b[7m"
+
+// dashboard is the state of the `dashboard` view: the per-day aggregates
+// from the local index and the day selected in the calendar.
+type dashboard struct {
+	days  map[string]*dayAggregate
+	today time.Time // midnight
+	sel   time.Time // midnight, never after today
+	weeks int       // columns of the heatmap
+}
+
+// newDashboard returns a dashboard of days with today selected.
+func newDashboard(days map[string]*dayAggregate, now time.Time, weeks int) *dashboard {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return &dashboard{days: days, today: today, sel: today, weeks: weeks}
+}
+
+// weekOf returns the Monday of t's week.
+func weekOf(t time.Time) time.Time {
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
+
+// window returns the Monday of the first week shown. It ends with the
+// current week, unless the selected day is further back, which is then in
+// the first column.
+func (d *dashboard) window() time.Time {
+	start := weekOf(d.today).AddDate(0, 0, -7*(d.weeks-1))
+	if d.sel.Before(start) {
+		start = weekOf(d.sel)
+	}
+	return start
+}
+
+// entries returns the number of entries written on t.
+func (d *dashboard) entries(t time.Time) int {
+	if day := d.days[t.Format("2006-01-02")]; day != nil {
+		return day.Entries
+	}
+	return 0
+}
+
+// shade returns the heatmap level of n entries, relative to the busiest
+// day shown.
+func shade(n, busiest int) int {
+	if n <= 0 || busiest <= 0 {
+		return 0
+	}
+	return min((4*n+busiest-1)/busiest, 4)
+}
+
+// render writes the heatmap, weeks as columns and weekdays as rows like
+// GitHub's contribution graph, the streaks and the selected day. With
+// color the selected cell is in reverse video, else it's shown as @.
+func (d *dashboard) render(w io.Writer, color bool) {
+	start := d.window()
+	busiest, entries, active := 0, 0, 0
+	for t := start; t.Before(start.AddDate(0, 0, 7*d.weeks)); t = t.AddDate(0, 0, 1) {
+		if n := d.entries(t); n > 0 && !t.After(d.today) {
+			busiest = max(busiest, n)
+			entries += n
+			active++
+		}
+	}
+
+	// Month names above the first week starting in that month.
+	months := []byte(strings.Repeat(" ", 4+2*d.weeks))
+	free := 0
+	for c := 0; c < d.weeks; c++ {
+		col := start.AddDate(0, 0, 7*c)
+		pos := 4 + 2*c
+		if (c == 0 || col.Day() <= 7) && pos >= free && pos+3 <= len(months) {
+			copy(months[pos:], col.Format("Jan"))
+			free = pos + 4
+		}
+	}
+	fmt.Fprintln(w, strings.TrimRight(string(months), " "))
+
+	for row := 0; row < 7; row++ {
+		var b strings.Builder
+		b.WriteString(start.AddDate(0, 0, row).Format("Mon") + " ")
+		for c := 0; c < d.weeks; c++ {
+			t := start.AddDate(0, 0, 7*c+row)
+			cell := heatmapShades[shade(d.entries(t), busiest)]
+			switch {
+			case t.After(d.today):
+				cell = " "
+			case t.Equal(d.sel) && color:
+				cell = ansiReverse + cell + ansiReset
+			case t.Equal(d.sel):
+				cell = "@"
+			}
+			b.WriteString(cell + " ")
+		}
+		fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+	}
+	fmt.Fprintf(w, "    less %s more\n\n", strings.Join(heatmapShades, " "))
+
+	s := summarizeStats(d.days, d.today)
+	fmt.Fprintf(w, "Streak: %d days (longest %d) · %d entries on %d days in these %d weeks\n",
+		s.CurrentStreak, s.LongestStreak, entries, active, d.weeks)
+	fmt.Fprintf(w, "%s: %s\n", d.sel.Format("Monday 2006-01-02"), describeDay(d.days[d.sel.Format("2006-01-02")]))
+}
+
+// describeDay summarizes a day's entries for the dashboard.
+func describeDay(day *dayAggregate) string {
+	if day == nil || day.Entries == 0 {
+		return "no entries"
+	}
+	s := fmt.Sprintf("%d entries, %d words", day.Entries, day.Words)
+	if day.Entries == 1 {
+		s = fmt.Sprintf("1 entry, %d words", day.Words)
+	}
+	var tags []string
+	for t := range day.Tags {
+		tags = append(tags, "#"+t)
+	}
+	sort.Strings(tags)
+	if len(tags) > 0 {
+		s += ", " + strings.Join(tags, " ")
+	}
+	return s
+}
+
+// dashboardHelp lists the navigator's commands.
+const dashboardHelp = "h/l week, k/j day, [/] month, t today, YYYY-MM-DD jump, o open, q quit"
+
+// move applies a navigator command: a date to jump to, or a run of keys
+// such as "hhk". It returns "open" or "quit" for those commands.
+func (d *dashboard) move(cmd string) (string, error) {
+	cmd = strings.TrimSpace(cmd)
+	switch cmd {
+	case "":
+		return "", nil
+	case "o", "open":
+		return "open", nil
+	case "q", "quit":
+		return "quit", nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", cmd, d.today.Location()); err == nil {
+		d.sel = t
+	} else {
+		sel := d.sel
+		for _, k := range cmd {
+			switch k {
+			case 'h':
+				sel = sel.AddDate(0, 0, -7)
+			case 'l':
+				sel = sel.AddDate(0, 0, 7)
+			case 'k':
+				sel = sel.AddDate(0, 0, -1)
+			case 'j':
+				sel = sel.AddDate(0, 0, 1)
+			case '[':
+				sel = sel.AddDate(0, -1, 0)
+			case ']':
+				sel = sel.AddDate(0, 1, 0)
+			case 't':
+				sel = d.today
+			default:
+				return "", fmt.Errorf("unknown command %q (%s)", cmd, dashboardHelp)
+			}
+		}
+		d.sel = sel
+	}
+	if d.sel.After(d.today) {
+		d.sel = d.today
+	}
+	return "", nil
+}
+
+// runDashboard implements the `dashboard` subcommand: a heatmap of the
+// journal's activity from the local index with a calendar to open a day's
+// note from.
+func runDashboard(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	weeks := fs.Int("weeks", 53, "number of weeks to show")
+	app := fs.String("app", "", "open notes in dropbox or obsidian (see open)")
+	once := fs.Bool("once", false, "print the dashboard and exit")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *weeks < 1 {
+		fmt.Fprintf(stderr, "invalid -weeks %d: must be at least 1\n", *weeks)
+		return 2
+	}
+
+	idx, err := loadIndex(defaultIndexPath())
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if len(idx.Notes) == 0 {
+		fmt.Fprintln(stderr, "local index is empty, run: dropbox-appender reindex")
+	}
+	d := newDashboard(cachedDays(idx, defaultStatsCachePath(), dateFilter{}, stderr), clock.Now(), *weeks)
+	if *once {
+		d.render(stdout, false)
+		return 0
+	}
+	open := func(day string) {
+		openArgs := []string{"-date", day}
+		if *app != "" {
+			openArgs = append(openArgs, "-app", *app)
+		}
+		runOpen(openArgs, stdin, stdout, stderr, clock)
+	}
+	runDashboardLoop(d, stdin, stdout, stderr, isTerminal(stdout) && colorEnabled(), open)
+	return 0
+}
+
+// runDashboardLoop shows the dashboard and reads navigator commands, one
+// per line, until q or the end of stdin. On a terminal the screen is
+// redrawn after each move; open is called with the day to open.
+func runDashboardLoop(d *dashboard, stdin io.Reader, stdout, stderr io.Writer, terminal bool, open func(day string)) {
+	scanner := bufio.NewScanner(stdin)
+	redraw := true
+	for {
+		if redraw {
+			if terminal {
+				fmt.Fprint(stdout, "\x1b[H\x1b[2J")
+			}
+			d.render(stdout, terminal)
+			fmt.Fprintln(stdout, dashboardHelp)
+		}
+		fmt.Fprint(stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			return
+		}
+		action, err := d.move(scanner.Text())
+		redraw = err == nil
+		switch {
+		case err != nil:
+			fmt.Fprintln(stderr, err)
+		case action == "quit":
+			return
+		case action == "open":
+			open(d.sel.Format("2006-01-02"))
+			redraw = false // keep the URL on screen
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dashboardDays() map[string]*dayAggregate {
+	return map[string]*dayAggregate{
+		"2025-01-06": {Entries: 1, Words: 12},
+		"2025-01-13": {Entries: 4, Words: 60},
+		"2025-01-14": {Entries: 2, Words: 25, Tags: map[string]int{"work": 2, "home": 1}},
+	}
+}
+
+func TestDashboardRender(t *testing.T) {
+	d := newDashboard(dashboardDays(), time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC), 2)
+	var out bytes.Buffer
+	d.render(&out, false)
+	want := `    Jan
+Mon ░ █
+Tue · ▒
+Wed · @
+Thu ·
+Fri ·
+Sat ·
+Sun ·
+    less · ░ ▒ ▓ █ more
+
+Streak: 2 days (longest 2) · 7 entries on 3 days in these 2 weeks
+Wednesday 2025-01-15: no entries
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	d.move("k")
+	out.Reset()
+	d.render(&out, true)
+	if !strings.Contains(out.String(), "Tue · "+ansiReverse+"▒"+ansiReset+"\n") {
+		t.Errorf("expected the selected cell in reverse video:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Tuesday 2025-01-14: 2 entries, 25 words, #home #work\n") {
+		t.Errorf("expected the selected day's summary:\n%s", out.String())
+	}
+}
+
+func TestDashboardMove(t *testing.T) {
+	d := newDashboard(dashboardDays(), time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC), 2)
+	for _, tc := range []struct{ cmd, want string }{
+		{"h", "2025-01-08"},
+		{"hhk", "2024-12-24"},
+		{"]", "2025-01-15"}, // not past today
+		{"2024-03-01", "2024-03-01"},
+		{"jl", "2024-03-09"},
+		{"[", "2024-02-09"},
+		{"t", "2025-01-15"},
+	} {
+		if _, err := d.move(tc.cmd); err != nil {
+			t.Fatalf("move %q: %v", tc.cmd, err)
+		}
+		if got := d.sel.Format("2006-01-02"); got != tc.want {
+			t.Errorf("after %q: selected %s, want %s", tc.cmd, got, tc.want)
+		}
+	}
+	if _, err := d.move("x"); err == nil {
+		t.Error("expected an error for an unknown command")
+	}
+	if d.sel.Format("2006-01-02") != "2025-01-15" {
+		t.Error("an unknown command shouldn't move the selection")
+	}
+
+	// The window scrolls back to show the selected day.
+	d.move("2024-06-05")
+	if got := d.window().Format("2006-01-02"); got != "2024-06-03" {
+		t.Errorf("window starts %s, want 2024-06-03", got)
+	}
+}
+
+func TestDashboardLoop(t *testing.T) {
+	d := newDashboard(dashboardDays(), time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC), 2)
+	var opened []string
+	var stdout, stderr bytes.Buffer
+	runDashboardLoop(d, strings.NewReader("hk\nbogus\no\nq\nj\n"), &stdout, &stderr, false,
+		func(day string) { opened = append(opened, day) })
+	if len(opened) != 1 || opened[0] != "2025-01-07" {
+		t.Errorf("opened %v, want [2025-01-07]", opened)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "bogus"`) {
+		t.Errorf("expected an error for the bad command, got %q", stderr.String())
+	}
+	if strings.Contains(stdout.String(), "\x1b[") {
+		t.Error("expected no escape sequences off a terminal")
+	}
+}
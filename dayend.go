@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// daySummaryMarker starts every summary block so a day is only
+	// summarised once.
+	daySummaryMarker = "<!-- day-summary -->"
+	// defaultDaySummaryTemplate renders the summary block.
+	defaultDaySummaryTemplate = "## Day summary\n" +
+		"- Entries: {{.Entries}}\n" +
+		"- Words: {{.Words}}\n" +
+		"- First: {{.First}}, last: {{.Last}}"
+	// defaultDaySummaryAt is when the daemon summarises the day.
+	defaultDaySummaryAt = "23:55"
+)
+
+// DaySummaryConfig is the "day_summary" config section. Setting it makes
+// the daemon append a summary of each day at At (HH:MM, local time).
+type DaySummaryConfig struct {
+	Template string `json:"template,omitempty"`
+	At       string `json:"at,omitempty"`
+}
+
+// dayStats are the fields available to the day summary template.
+type dayStats struct {
+	Date    string // YYYY-MM-DD
+	Entries int
+	Words   int
+	First   string // time of the first entry
+	Last    string // time of the last entry
+}
+
+// computeDayStats summarises the timestamped entries of a note.
+func computeDayStats(content string, date time.Time) dayStats {
+	s := dayStats{Date: date.Format("2006-01-02")}
+	entries := parseEntries(content)
+	s.Entries = len(entries)
+	for _, e := range entries {
+		s.Words += len(strings.Fields(e.Text))
+	}
+	if len(entries) > 0 {
+		s.First, s.Last = entries[0].Time, entries[len(entries)-1].Time
+	}
+	return s
+}
+
+// formatDaySummary renders s with tmpl (the default when empty), prefixed
+// by daySummaryMarker.
+func formatDaySummary(tmpl string, s dayStats) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultDaySummaryTemplate
+	}
+	t, err := template.New("day_summary").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid day summary template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, s); err != nil {
+		return "", fmt.Errorf("day summary template: %w", err)
+	}
+	return daySummaryMarker + "\n" + strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+// appendDaySummary appends the summary block to the note at path for date.
+// Notes that are empty or already summarised are left alone; the returned
+// bool reports whether a summary was written.
+func appendDaySummary(client *DropboxClient, path, tmpl string, date time.Time, opts appendOptions) (bool, error) {
+	content, err := client.Download(path)
+	if err != nil {
+		return false, fmt.Errorf("downloading journal: %w", err)
+	}
+	if strings.Contains(content, daySummaryMarker) {
+		return false, nil
+	}
+	s := computeDayStats(content, date)
+	if s.Entries == 0 {
+		return false, nil
+	}
+	block, err := formatDaySummary(tmpl, s)
+	if err != nil {
+		return false, err
+	}
+	if _, err := appendToJournal(client, path, block, opts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// dayEndScheduler lets the daemon summarise each day once, after At.
+type dayEndScheduler struct {
+	cfg  *Config
+	at   string // HH:MM
+	last string // date last handled
+}
+
+// newDayEndScheduler returns the scheduler configured in cfg, or nil when
+// day summaries aren't configured.
+func newDayEndScheduler(cfg *Config) (*dayEndScheduler, error) {
+	if cfg.DaySummary == nil {
+		return nil, nil
+	}
+	at := cfg.DaySummary.At
+	if at == "" {
+		at = defaultDaySummaryAt
+	}
+	if _, err := time.Parse("15:04", at); err != nil {
+		return nil, fmt.Errorf("invalid day_summary at %q: expected HH:MM", at)
+	}
+	if _, err := formatDaySummary(cfg.DaySummary.Template, dayStats{}); err != nil {
+		return nil, err
+	}
+	return &dayEndScheduler{cfg: cfg, at: at}, nil
+}
+
+// run appends today's summary once now is past the configured time.
+func (s *dayEndScheduler) run(client *DropboxClient, now time.Time, opts appendOptions, stderr io.Writer) {
+	date := now.Format("2006-01-02")
+	if s.last == date || now.Format("15:04") < s.at {
+		return
+	}
+	path, err := journalPath(s.cfg, "", now)
+	if err == nil {
+		_, err = appendDaySummary(client, path, s.cfg.DaySummary.Template, now, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "warning: day summary: %v\n", err)
+		return
+	}
+	s.last = date
+}
+
+// runDayEnd implements the `dropbox-appender dayend` subcommand, appending
+// the summary of a day's entries to its note. It returns the process exit
+// code.
+func runDayEnd(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("dayend", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	date := fs.String("date", "", "summarise this date (YYYY-MM-DD) instead of today")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	now := clock.Now()
+	if *date != "" {
+		d, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+			return 2
+		}
+		now = d
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	var tmpl string
+	if cfg.DaySummary != nil {
+		tmpl = cfg.DaySummary.Template
+	}
+
+	written, err := appendDaySummary(client, path, tmpl, now, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if !written {
+		fmt.Fprintf(stdout, "Nothing to summarise in %s (no entries, or already summarised)\n", path)
+		return 0
+	}
+	fmt.Fprintf(stdout, "Appended day summary to %s\n", path)
+	return 0
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dayNote = "### 09:00:00\nmorning run #health\n\n### 13:15:00\nlunch with ana\n\n### 22:40:00\nread two chapters tonight\n"
+
+func TestComputeDayStats(t *testing.T) {
+	s := computeDayStats(dayNote, time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC))
+	want := dayStats{Date: "2025-01-15", Entries: 3, Words: 10, First: "09:00:00", Last: "22:40:00"}
+	if s != want {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+}
+
+func TestFormatDaySummary(t *testing.T) {
+	s := dayStats{Date: "2025-01-15", Entries: 3, Words: 10, First: "09:00:00", Last: "22:40:00"}
+	got, err := formatDaySummary("", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := daySummaryMarker + "\n## Day summary\n- Entries: 3\n- Words: 10\n- First: 09:00:00, last: 22:40:00\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, _ := formatDaySummary("{{.Date}}: {{.Entries}} entries", s); !strings.HasSuffix(got, "\n2025-01-15: 3 entries\n") {
+		t.Errorf("custom template got %q", got)
+	}
+	if _, err := formatDaySummary("{{.Nope}}", s); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestAppendDaySummary(t *testing.T) {
+	path := "/Journal/2025-01-15.md"
+	files := map[string]string{path: dayNote}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	date := time.Date(2025, 1, 15, 23, 55, 0, 0, time.UTC)
+
+	written, err := appendDaySummary(client, path, "", date, appendOptions{})
+	if err != nil || !written {
+		t.Fatalf("written=%v err=%v", written, err)
+	}
+	if !strings.HasSuffix(files[path], "\n\n"+daySummaryMarker+"\n## Day summary\n- Entries: 3\n- Words: 10\n- First: 09:00:00, last: 22:40:00\n") {
+		t.Errorf("note = %q", files[path])
+	}
+
+	// Running again, or on a note without entries, writes nothing.
+	before := files[path]
+	if written, err := appendDaySummary(client, path, "", date, appendOptions{}); err != nil || written {
+		t.Errorf("second run: written=%v err=%v", written, err)
+	}
+	if files[path] != before {
+		t.Error("note changed on the second run")
+	}
+	if written, err := appendDaySummary(client, "/Journal/2025-01-16.md", "", date, appendOptions{}); err != nil || written {
+		t.Errorf("empty note: written=%v err=%v", written, err)
+	}
+}
+
+func TestDayEndScheduler(t *testing.T) {
+	files := map[string]string{"/J/2025-01-15.md": dayNote}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	cfg := &Config{PathTemplate: "/J/{{.Year}}-{{.Month}}-{{.Day}}.md", DaySummary: &DaySummaryConfig{At: "23:00"}}
+	s, err := newDayEndScheduler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	s.run(client, time.Date(2025, 1, 15, 22, 59, 0, 0, time.Local), appendOptions{}, &stderr)
+	if strings.Contains(files["/J/2025-01-15.md"], daySummaryMarker) {
+		t.Fatal("summarised before the configured time")
+	}
+	s.run(client, time.Date(2025, 1, 15, 23, 1, 0, 0, time.Local), appendOptions{}, &stderr)
+	if !strings.Contains(files["/J/2025-01-15.md"], daySummaryMarker) {
+		t.Fatalf("not summarised after the configured time: %s", stderr.String())
+	}
+	if s.last != "2025-01-15" {
+		t.Errorf("last = %q", s.last)
+	}
+
+	if s, err := newDayEndScheduler(&Config{}); s != nil || err != nil {
+		t.Errorf("unconfigured: %v, %v", s, err)
+	}
+	if _, err := newDayEndScheduler(&Config{DaySummary: &DaySummaryConfig{At: "late"}}); err == nil {
+		t.Error("expected an error for an invalid at")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultDayLinksLookback is how many days back the previous note is looked
+// for when day_links.lookback isn't set.
+const defaultDayLinksLookback = 31
+
+// DayLinksConfig starts each new daily note with links to the previous
+// note and the next day's, like Obsidian's daily note navigation.
+type DayLinksConfig struct {
+	// Lookback is how many days back to look for the previous note, which
+	// skips days without one.
+	Lookback int `json:"lookback,omitempty"`
+	// PatchPrevious also points the previous note's forward link at the new
+	// note when they aren't consecutive days.
+	PatchPrevious bool `json:"patch_previous,omitempty"`
+}
+
+// dayNav matches a navigation line: "← [prev](...) | [next](...) →", or
+// either half on its own.
+var dayNav = regexp.MustCompile(`^(?:← (\[[^\]]*\]\([^)]*\)))?(?: \| )?(?:(\[[^\]]*\]\([^)]*\)) →)?$`)
+
+// dayLink returns a markdown link from the note at from to the note at to,
+// relative so it works in the Obsidian vault as well as in Dropbox.
+func dayLink(from, to string) string {
+	name := path.Base(to)
+	rel := (&url.URL{Path: relativeLink(from, to)}).EscapedPath()
+	return fmt.Sprintf("[%s](%s)", strings.TrimSuffix(name, path.Ext(name)), rel)
+}
+
+// formatDayNav renders a navigation line from the previous and next links,
+// either of which may be empty.
+func formatDayNav(prev, next string) string {
+	var parts []string
+	if prev != "" {
+		parts = append(parts, "← "+prev)
+	}
+	if next != "" {
+		parts = append(parts, next+" →")
+	}
+	return strings.Join(parts, " | ")
+}
+
+// noteDay returns the day whose note the path template renders as p: the
+// date read back through the template, or else today.
+func noteDay(tmpl, p string, now time.Time) (time.Time, bool) {
+	candidates := []time.Time{now}
+	if d, ok := templateDate(tmpl, p); ok {
+		candidates = []time.Time{time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, now.Location())}
+	}
+	for _, d := range candidates {
+		if rendered, err := renderPathTemplate(tmpl, d); err == nil && rendered == p {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// previousNote returns the path, content and revision of the most recent
+// note before day within lookback days; an empty path when there is none.
+func previousNote(client *DropboxClient, tmpl string, day time.Time, lookback int) (string, string, string, error) {
+	for i := 1; i <= lookback; i++ {
+		p, err := renderPathTemplate(tmpl, day.AddDate(0, 0, -i))
+		if err != nil {
+			return "", "", "", err
+		}
+		content, rev, err := client.DownloadRev(p)
+		if err != nil {
+			return "", "", "", fmt.Errorf("downloading %s: %w", p, err)
+		}
+		if content != "" {
+			return p, content, rev, nil
+		}
+	}
+	return "", "", "", nil
+}
+
+// dayLinks is the navigation added to a new daily note, and what is needed
+// to patch the previous one afterwards.
+type dayLinks struct {
+	nav                 string
+	prev, prevContent   string
+	prevRev, nextOfPrev string
+}
+
+// newDayLinks builds the navigation line for the new note at p, or returns
+// false when p isn't a daily note of the path template.
+func newDayLinks(client *DropboxClient, p string, opts appendOptions) (dayLinks, bool, error) {
+	day, ok := noteDay(opts.PathTemplate, p, opts.now())
+	if !ok {
+		return dayLinks{}, false, nil
+	}
+	lookback := opts.DayLinks.Lookback
+	if lookback == 0 {
+		lookback = defaultDayLinksLookback
+	}
+	next, err := renderPathTemplate(opts.PathTemplate, day.AddDate(0, 0, 1))
+	if err != nil {
+		return dayLinks{}, false, err
+	}
+	prev, content, rev, err := previousNote(client, opts.PathTemplate, day, lookback)
+	if err != nil {
+		return dayLinks{}, false, err
+	}
+	n := dayLinks{prev: prev, prevContent: content, prevRev: rev}
+	if prev != "" {
+		n.nav = formatDayNav(dayLink(p, prev), dayLink(p, next))
+		n.nextOfPrev = dayLink(prev, p)
+	} else {
+		n.nav = formatDayNav("", dayLink(p, next))
+	}
+	return n, true, nil
+}
+
+// patchPrevious points the previous note's forward link at the new note:
+// its navigation line is rewritten, or one is added at its end when it has
+// none. A note that changed since it was read is left alone.
+func (n dayLinks) patchPrevious(client *DropboxClient, opts appendOptions) error {
+	if n.prev == "" {
+		return nil
+	}
+	content := toLF(n.prevContent)
+	lines := strings.Split(content, "\n")
+	found := false
+	for i, line := range lines {
+		m := dayNav.FindStringSubmatch(line)
+		if m == nil || line == "" {
+			continue
+		}
+		if m[2] == n.nextOfPrev {
+			return nil
+		}
+		lines[i] = formatDayNav(m[1], n.nextOfPrev)
+		found = true
+		break
+	}
+	if found {
+		content = strings.Join(lines, "\n")
+	} else {
+		content = appendWithSeparator(content, formatDayNav("", n.nextOfPrev)+"\n", "\n")
+	}
+	eol, err := lineEnding(opts.LineEndings, n.prevContent)
+	if err != nil {
+		return err
+	}
+	data := withLineEnding(content, eol)
+	rev, err := client.UploadRev(n.prev, data, n.prevRev)
+	if err != nil {
+		return fmt.Errorf("updating %s: %w", n.prev, err)
+	}
+	wroteNote(client, n.prev, n.prev, content, data, rev, opts)
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendEntries_DayLinksSkipMissingDays(t *testing.T) {
+	prev := "/Notes/Journal/2025/01/Note20250113.md"
+	files := map[string]string{
+		prev: "← [Note20250112](Note20250112.md) | [Note20250114](Note20250114.md) →\n\n### 09:00:00\nmonday\n",
+	}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	base := "/Notes/Journal/2025/01/Note20250115.md"
+	opts := appendOptions{DayLinks: &DayLinksConfig{Lookback: 7, PatchPrevious: true}}
+	if _, err := appendEntries(client, base, []string{"### 14:30:45\nnew entry\n"}, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "← [Note20250113](Note20250113.md) | [Note20250116](Note20250116.md) →\n\n### 14:30:45\nnew entry\n"
+	if files[base] != want {
+		t.Errorf("new note:\n got %q\nwant %q", files[base], want)
+	}
+	if !strings.HasPrefix(files[prev], "← [Note20250112](Note20250112.md) | [Note20250115](Note20250115.md) →\n") {
+		t.Errorf("expected the previous note's forward link to be patched, got %q", files[prev])
+	}
+}
+
+func TestAppendEntries_DayLinksAcrossFolders(t *testing.T) {
+	prev := "/Notes/Journal/2025/01/Note20250131.md"
+	files := map[string]string{prev: "### 09:00:00\nold note without links\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	base := "/Notes/Journal/2025/02/Note20250201.md"
+	opts := appendOptions{DayLinks: &DayLinksConfig{PatchPrevious: true}}
+	if _, err := appendEntries(client, base, []string{"### 08:00:00\nfebruary\n"}, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(files[base], "← [Note20250131](../01/Note20250131.md) | [Note20250202](Note20250202.md) →\n") {
+		t.Errorf("unexpected navigation in the new note: %q", files[base])
+	}
+	if !strings.HasSuffix(files[prev], "old note without links\n\n[Note20250201](../02/Note20250201.md) →\n") {
+		t.Errorf("expected a forward link added to the previous note, got %q", files[prev])
+	}
+}
+
+func TestAppendEntries_DayLinksOnlyForNewNotes(t *testing.T) {
+	base := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{base: "### 09:00:00\nfirst\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	opts := appendOptions{DayLinks: &DayLinksConfig{}}
+	if _, err := appendEntries(client, base, []string{"### 10:00:00\nsecond\n"}, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(files[base], "→") {
+		t.Errorf("expected no navigation in an existing note, got %q", files[base])
+	}
+}
+
+func TestNoteDay(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	tmpl := "/Journal/{{.Year}}/{{.Month}}/{{.Day}}.md"
+	if d, ok := noteDay(tmpl, "/Journal/2025/03/10.md", now); !ok || d.Format("2006-01-02") != "2025-03-10" {
+		t.Errorf("expected today for a path without a full date, got %v %v", d, ok)
+	}
+	if _, ok := noteDay(tmpl, "/Journal/inbox.md", now); ok {
+		t.Error("expected a path the template doesn't render not to be a daily note")
+	}
+	if d, ok := noteDay("", "/Notes/Journal/2024/12/Note20241231.md", now); !ok || d.Format("2006-01-02") != "2024-12-31" {
+		t.Errorf("expected the date from the file name, got %v %v", d, ok)
+	}
+}
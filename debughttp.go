@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDebugBody bounds how much of each request and response body is logged.
+const maxDebugBody = 4096
+
+var (
+	// secretFormFields matches credentials in form-encoded OAuth bodies.
+	secretFormFields = regexp.MustCompile(`((?:^|&)(?:refresh_token|client_secret|code|access_token)=)[^&]*`)
+	// secretJSONFields matches credentials in JSON token responses.
+	secretJSONFields = regexp.MustCompile(`("(?:access_token|refresh_token|id_token)"\s*:\s*")[^"]*`)
+)
+
+// debugTransport logs every HTTP exchange to w with credentials redacted.
+type debugTransport struct {
+	base http.RoundTripper
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+// redactBody hides tokens and secrets in a request or response body and
+// truncates it to maxDebugBody.
+func redactBody(body []byte) string {
+	s := secretFormFields.ReplaceAllString(string(body), "${1}[REDACTED]")
+	s = secretJSONFields.ReplaceAllString(s, "${1}[REDACTED]")
+	if len(s) > maxDebugBody {
+		s = fmt.Sprintf("%s... [%d bytes total]", s[:maxDebugBody], len(body))
+	}
+	return s
+}
+
+// writeHeaders logs h in sorted order, hiding Authorization values.
+func writeHeaders(w io.Writer, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			if strings.EqualFold(k, "Authorization") {
+				scheme, _, _ := strings.Cut(v, " ")
+				v = scheme + " [REDACTED]"
+			}
+			fmt.Fprintf(w, "%s: %s\n", k, v)
+		}
+	}
+}
+
+// drain reads and replaces body so it can still be consumed afterwards.
+func drain(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	if body == nil || body == http.NoBody {
+		return nil, body, nil
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	return data, io.NopCloser(bytes.NewReader(data)), err
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, body, err := drain(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = body
+
+	start := time.Now()
+	resp, rtErr := t.base.RoundTrip(req)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "> %s %s\n", req.Method, req.URL)
+	writeHeaders(&buf, req.Header)
+	if len(reqBody) > 0 {
+		fmt.Fprintf(&buf, "\n%s\n", redactBody(reqBody))
+	}
+	if rtErr != nil {
+		fmt.Fprintf(&buf, "< error after %v: %v\n\n", time.Since(start).Round(time.Millisecond), rtErr)
+	} else {
+		var respBody []byte
+		respBody, resp.Body, err = drain(resp.Body)
+		fmt.Fprintf(&buf, "< %s (%v)\n", resp.Status, time.Since(start).Round(time.Millisecond))
+		writeHeaders(&buf, resp.Header)
+		if len(respBody) > 0 {
+			fmt.Fprintf(&buf, "\n%s\n", redactBody(respBody))
+		}
+		buf.WriteString("\n")
+	}
+
+	t.mu.Lock()
+	t.w.Write(buf.Bytes())
+	t.mu.Unlock()
+	if rtErr != nil {
+		return nil, rtErr
+	}
+	return resp, err
+}
+
+// enableHTTPDebug routes all HTTP traffic, Dropbox and OAuth alike, through
+// a debugTransport writing to stderr or, unless dest is "-", to the file
+// dest. The returned function closes the log file.
+func enableHTTPDebug(dest string, stderr io.Writer) (func(), error) {
+	w, closeFn := stderr, func() {}
+	if dest != "-" {
+		f, err := os.OpenFile(dest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		w, closeFn = f, func() { f.Close() }
+	}
+	wrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		return &debugTransport{base: base, w: w}
+	})
+	return closeFn, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	form := "grant_type=refresh_token&refresh_token=secret1&client_id=key&client_secret=secret2"
+	got := redactBody([]byte(form))
+	if strings.Contains(got, "secret1") || strings.Contains(got, "secret2") || !strings.Contains(got, "client_id=key") {
+		t.Errorf("form not redacted: %q", got)
+	}
+	got = redactBody([]byte(`{"access_token": "secret3", "expires_in": 14400}`))
+	if got != `{"access_token": "[REDACTED]", "expires_in": 14400}` {
+		t.Errorf("JSON not redacted: %q", got)
+	}
+	if got := redactBody(bytes.Repeat([]byte("x"), maxDebugBody+10)); !strings.HasSuffix(got, "[4106 bytes total]") {
+		t.Errorf("expected truncation, got suffix %q", got[len(got)-30:])
+	}
+}
+
+func TestDebugTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "sl.abc", "token_type": "bearer"}`))
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	client := &http.Client{Transport: &debugTransport{base: http.DefaultTransport, w: &log}}
+	req, _ := http.NewRequest("POST", server.URL+"/oauth2/token",
+		strings.NewReader(url.Values{"refresh_token": {"rt-secret"}}.Encode()))
+	req.Header.Set("Authorization", "Bearer tok-secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+	if !strings.Contains(body.String(), "sl.abc") {
+		t.Errorf("caller should still see the real body, got %q", body.String())
+	}
+
+	out := log.String()
+	for _, want := range []string{"> POST " + server.URL + "/oauth2/token", "Authorization: Bearer [REDACTED]",
+		"refresh_token=[REDACTED]", "< 200 OK", `"access_token": "[REDACTED]"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in log:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"tok-secret", "rt-secret", "sl.abc"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log leaks %q:\n%s", secret, out)
+		}
+	}
+}
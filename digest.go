@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDigestDays is how many days a digest covers.
+	defaultDigestDays = 7
+	// defaultSendmail is the sendmail used when no SMTP server is set.
+	defaultSendmail = "/usr/sbin/sendmail"
+	// smtpPasswordEnv holds the SMTP password, which is kept out of the
+	// config and the command line.
+	smtpPasswordEnv = "DROPBOX_APPENDER_SMTP_PASSWORD"
+)
+
+// DigestConfig is the "digest" config section, the defaults for the digest
+// subcommand's flags.
+type DigestConfig struct {
+	Email    string `json:"email,omitempty"`
+	From     string `json:"from,omitempty"`
+	SMTP     string `json:"smtp,omitempty"` // host:port
+	SMTPUser string `json:"smtp_user,omitempty"`
+	Sendmail string `json:"sendmail,omitempty"`
+}
+
+var (
+	mdLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBold   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	mdHeader = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdItem   = regexp.MustCompile(`^\s*[-*]\s+(.*)$`)
+)
+
+// digestSubject names the week the digest covers.
+func digestSubject(notes []pastNote) string {
+	first, last := notes[0].Date, notes[len(notes)-1].Date
+	return fmt.Sprintf("Week in review: %s – %s", first.Format("Jan 2"), last.Format("Jan 2, 2006"))
+}
+
+// digestMarkdown joins the notes under a heading per day, oldest first,
+// demoting their own headings so the day stays on top.
+func digestMarkdown(notes []pastNote) string {
+	var b strings.Builder
+	for i, n := range notes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %s\n\n", n.Date.Format("Monday, January 2"))
+		for _, line := range strings.Split(strings.TrimSpace(toLF(n.Content)), "\n") {
+			if strings.HasPrefix(line, "#") {
+				line = "#" + line
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// mdInline renders the inline Markdown of a line (links, bold, code) as
+// HTML, escaping everything else.
+func mdInline(line string) string {
+	s := html.EscapeString(line)
+	s = mdCode.ReplaceAllString(s, "<code>$1</code>")
+	s = mdBold.ReplaceAllString(s, "<strong>$1</strong>")
+	return mdLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+}
+
+// markdownHTML renders the Markdown the journal uses — headings, lists,
+// quotes, code fences and paragraphs — as HTML for mail clients. It is not
+// a full CommonMark renderer.
+func markdownHTML(md string) string {
+	var b strings.Builder
+	var para []string
+	inList, inCode := false, false
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + strings.Join(para, "<br>\n") + "</p>\n")
+			para = nil
+		}
+		if inList {
+			b.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flush()
+			if inCode {
+				b.WriteString("</code></pre>\n")
+			} else {
+				b.WriteString("<pre><code>")
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			b.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+		if m := mdHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			fmt.Fprintf(&b, "<h%d>%s</h%[1]d>\n", len(m[1]), mdInline(m[2]))
+			continue
+		}
+		if m := mdItem.FindStringSubmatch(line); m != nil {
+			if len(para) > 0 {
+				flush()
+			}
+			if !inList {
+				b.WriteString("<ul>\n")
+				inList = true
+			}
+			b.WriteString("<li>" + mdInline(m[1]) + "</li>\n")
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if inList {
+			flush()
+		}
+		if strings.HasPrefix(line, ">") {
+			line = "<blockquote>" + mdInline(strings.TrimSpace(strings.TrimPrefix(line, ">"))) + "</blockquote>"
+			para = append(para, line)
+			continue
+		}
+		para = append(para, mdInline(line))
+	}
+	if inCode {
+		b.WriteString("</code></pre>\n")
+	}
+	flush()
+	return b.String()
+}
+
+// digestMessage builds the email: a multipart/alternative message with the
+// Markdown as plain text and its HTML rendering.
+func digestMessage(from, to, subject, md string, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	parts := []struct{ typ, text string }{
+		{"text/plain", md},
+		{"text/html", "<!DOCTYPE html>\n<html><body>\n" + markdownHTML(md) + "</body></html>\n"},
+	}
+	for _, p := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		io.WriteString(qp, p.text)
+		qp.Close()
+	}
+	mw.Close()
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// sendMail delivers msg through the SMTP server at addr (host:port),
+// authenticating as user when set, or else by piping it to sendmail.
+func sendMail(addr, user, sendmail, from, to string, msg []byte) error {
+	if addr == "" {
+		cmd := exec.Command(sendmail, "-t", "-i")
+		cmd.Stdin = bytes.NewReader(msg)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %w: %s", sendmail, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	var auth smtp.Auth
+	if user != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP server %q: %w", addr, err)
+		}
+		auth = smtp.PlainAuth("", user, os.Getenv(smtpPasswordEnv), host)
+	}
+	return smtp.SendMail(addr, auth, addressOnly(from), []string{addressOnly(to)}, msg)
+}
+
+// addressOnly strips the display name from an address such as
+// "Ann <ann@example.com>".
+func addressOnly(addr string) string {
+	if i := strings.LastIndex(addr, "<"); i >= 0 {
+		return strings.TrimSuffix(addr[i+1:], ">")
+	}
+	return addr
+}
+
+// runDigest implements `dropbox-appender digest`, which emails the past
+// week's entries as a "week in review" through an SMTP server or the local
+// sendmail. It returns the process exit code.
+func runDigest(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	email := fs.String("email", "", "send the digest to this address (overrides config)")
+	from := fs.String("from", "", "sender address (overrides config; default $USER@hostname)")
+	smtpAddr := fs.String("smtp", "", "SMTP server as host:port; without it the message is piped to sendmail (overrides config)")
+	smtpUser := fs.String("smtp-user", "", "SMTP user; the password is read from $"+smtpPasswordEnv+" (overrides config)")
+	sendmail := fs.String("sendmail", "", "sendmail binary (overrides config; default "+defaultSendmail+")")
+	days := fs.Int("days", defaultDigestDays, "how many days, up to today, the digest covers")
+	dryRun := fs.Bool("dry-run", false, "print the message instead of sending it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *days < 1 {
+		fmt.Fprintln(stderr, "-days must be at least 1")
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	var dc DigestConfig
+	if cfg.Digest != nil {
+		dc = *cfg.Digest
+	}
+	for dst, flagValue := range map[*string]string{
+		&dc.Email: *email, &dc.From: *from, &dc.SMTP: *smtpAddr, &dc.SMTPUser: *smtpUser, &dc.Sendmail: *sendmail,
+	} {
+		if flagValue != "" {
+			*dst = flagValue
+		}
+	}
+	if dc.From == "" {
+		dc.From = defaultAuthor()
+	}
+	if dc.Sendmail == "" {
+		dc.Sendmail = defaultSendmail
+	}
+	if dc.Email == "" && !*dryRun {
+		fmt.Fprintln(stderr, "usage: dropbox-appender digest -email ADDRESS [-smtp HOST:PORT] [-days N]")
+		return 2
+	}
+
+	client, err := connectClient(cfg, false)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	return runDigestWithClient(stdout, stderr, client, cfg, dc, clock.Now(), *days, *dryRun)
+}
+
+// errEmptyDigest is returned when there is nothing to send.
+var errEmptyDigest = errors.New("no entries in the digest period")
+
+// collectDigest downloads the notes of the days days up to now, oldest
+// first, skipping empty ones.
+func collectDigest(client *DropboxClient, cfg *Config, now time.Time, days int) ([]pastNote, error) {
+	var notes []pastNote
+	for i := days - 1; i >= 0; i-- {
+		d := now.AddDate(0, 0, -i)
+		p, err := journalPath(cfg, "", d)
+		if err != nil {
+			return nil, err
+		}
+		content, err := client.Download(p)
+		if err != nil {
+			return nil, fmt.Errorf("downloading %s: %w", p, err)
+		}
+		if strings.TrimSpace(content) != "" {
+			notes = append(notes, pastNote{Date: d, Content: content})
+		}
+	}
+	if len(notes) == 0 {
+		return nil, errEmptyDigest
+	}
+	return notes, nil
+}
+
+// runDigestWithClient assembles the digest and sends it as dc describes,
+// or prints it with dryRun.
+func runDigestWithClient(stdout, stderr io.Writer, client *DropboxClient, cfg *Config, dc DigestConfig,
+	now time.Time, days int, dryRun bool) int {
+
+	notes, err := collectDigest(client, cfg, now, days)
+	if errors.Is(err, errEmptyDigest) {
+		fmt.Fprintf(stderr, "No entries in the last %d days; nothing to send\n", days)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	subject := digestSubject(notes)
+	msg, err := digestMessage(dc.From, dc.Email, subject, digestMarkdown(notes), now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if dryRun {
+		stdout.Write(msg)
+		return 0
+	}
+	if err := sendMail(dc.SMTP, dc.SMTPUser, dc.Sendmail, dc.From, dc.Email, msg); err != nil {
+		fmt.Fprintf(stderr, "error: sending digest: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Sent %q (%d days) to %s\n", subject, len(notes), dc.Email)
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkdownHTML(t *testing.T) {
+	md := "## Monday, January 13\n\n#### 09:00:00\nshipped **v2** <finally>\n- [x] tests\n- see [docs](https://example.com)\n\n```\na < b\n```\n> quoted\n"
+	want := "<h2>Monday, January 13</h2>\n" +
+		"<h4>09:00:00</h4>\n" +
+		"<p>shipped <strong>v2</strong> &lt;finally&gt;</p>\n" +
+		"<ul>\n<li>[x] tests</li>\n<li>see <a href=\"https://example.com\">docs</a></li>\n</ul>\n" +
+		"<pre><code>a &lt; b\n</code></pre>\n" +
+		"<p><blockquote>quoted</blockquote></p>\n"
+	if got := markdownHTML(md); got != want {
+		t.Errorf("markdownHTML =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRunDigestWithClient(t *testing.T) {
+	files := map[string]string{
+		"/Journal/20250113.md": "### 09:00:00\nplanning\n",
+		"/Journal/20250115.md": "### 17:00:00\nshipped\n",
+		"/Journal/20250101.md": "### 10:00:00\ntoo old\n",
+	}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	cfg := &Config{PathTemplate: "/Journal/{{.Date}}.md"}
+	now := time.Date(2025, 1, 15, 18, 0, 0, 0, time.Local)
+
+	// A sendmail stand-in that keeps the message.
+	dir := t.TempDir()
+	sent := filepath.Join(dir, "sent.eml")
+	sendmail := filepath.Join(dir, "sendmail")
+	os.WriteFile(sendmail, []byte("#!/bin/sh\ncat > "+sent+"\n"), 0755)
+	dc := DigestConfig{Email: "me@example.com", From: "journal@example.com", Sendmail: sendmail}
+
+	var stdout, stderr bytes.Buffer
+	if code := runDigestWithClient(&stdout, &stderr, client, cfg, dc, now, 7, false); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	msg, err := os.ReadFile(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"To: me@example.com\r\n",
+		"Subject: =?utf-8?q?Week_in_review:_Jan_13_=E2=80=93_Jan_15,_2025?=\r\n",
+		"Content-Type: text/plain; charset=utf-8",
+		"## Monday, January 13\r\n\r\n#### 09:00:00\r\nplanning\r\n\r\n## Wednesday, January 15",
+		"<h2>Monday, January 13</h2>",
+	} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(string(msg), "too old") {
+		t.Error("message includes a note from before the period")
+	}
+
+	stdout.Reset()
+	if code := runDigestWithClient(&stdout, &stderr, client, cfg, dc, now.AddDate(0, 1, 0), 7, false); code != 0 {
+		t.Errorf("empty week: exit %d", code)
+	}
+}
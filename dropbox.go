@@ -2,66 +2,391 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"unicode/utf16"
 )
 
-const defaultBaseURL = "https://content.dropboxapi.com"
+const (
+	defaultBaseURL    = "https://content.dropboxapi.com"
+	defaultAPIBaseURL = "https://api.dropboxapi.com"
+)
 
 // DropboxClient talks to the Dropbox content API.
 type DropboxClient struct {
 	Token   string
-	BaseURL string // override for testing
+	BaseURL string      // override for testing
+	Retry   RetryPolicy // zero value disables retries
+	// Endpoints overrides where requests go: keys are an endpoint such as
+	// "/2/files/list_folder", or "api" or "content" for every endpoint on
+	// that host, and values are base URLs.
+	Endpoints map[string]string
+	// MaxDownload refuses full downloads of files larger than this many
+	// bytes (0 for no limit); only the first MaxDownload+1 bytes are
+	// transferred before giving up.
+	MaxDownload int64
+	// Cache, if set, serves listing and metadata requests from disk.
+	Cache *apiCache
+	// Progress, if set, receives a progress bar for large uploads.
+	Progress io.Writer
+	// Notify lets uploads notify the account's desktop and mobile apps;
+	// NotifyPaths does so only for paths matching one of these patterns.
+	// Otherwise uploads are muted.
+	Notify      bool
+	NotifyPaths []string
+	// Txn, if set, records the files written so they can be rolled back.
+	Txn *transaction
+	// Refresh, if set, gets a new access token when Dropbox rejects the
+	// current one, e.g. after it expired in a long-running daemon or
+	// server. The rejected request is then sent once more.
+	Refresh func() (string, error)
 }
 
-func (c *DropboxClient) baseURL() string {
+// tokenMu guards DropboxClient.Token once a client is in use, as requests
+// running concurrently may refresh it.
+var tokenMu sync.Mutex
+
+// endpointHost is the Dropbox host an endpoint is served from: RPC-style
+// endpoints (JSON in, JSON out) are on api.dropboxapi.com, and endpoints
+// that transfer file contents on content.dropboxapi.com.
+type endpointHost string
+
+const (
+	apiHost     endpointHost = "api"
+	contentHost endpointHost = "content"
+)
+
+// endpointURL returns the URL for endpoint on host. An override for the
+// endpoint itself wins, then BaseURL (tests point it at a single fake
+// server that handles both hosts), then an override for the host.
+func (c *DropboxClient) endpointURL(endpoint string, host endpointHost) string {
+	if base, ok := c.Endpoints[endpoint]; ok {
+		return strings.TrimSuffix(base, "/") + endpoint
+	}
 	if c.BaseURL != "" {
-		return c.BaseURL
+		return c.BaseURL + endpoint
+	}
+	if base, ok := c.Endpoints[string(host)]; ok {
+		return strings.TrimSuffix(base, "/") + endpoint
 	}
-	return defaultBaseURL
+	if host == contentHost {
+		return defaultBaseURL + endpoint
+	}
+	return defaultAPIBaseURL + endpoint
 }
 
-// Download fetches a file from Dropbox. Returns empty string if file doesn't exist.
-func (c *DropboxClient) Download(path string) (string, error) {
-	arg, _ := json.Marshal(map[string]string{"path": path})
+// checkEndpointOverride reports whether key and base are a valid entry of
+// the endpoints setting.
+func checkEndpointOverride(key, base string) error {
+	if key != string(apiHost) && key != string(contentHost) && !strings.HasPrefix(key, "/2/") {
+		return fmt.Errorf("unknown endpoint %q: expected api, content or a path such as /2/files/upload", key)
+	}
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base URL %q", base)
+	}
+	return nil
+}
+
+// authorize adds the access token to req.
+func (c *DropboxClient) authorize(req *http.Request) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+}
 
-	req, err := http.NewRequest("POST", c.baseURL()+"/2/files/download", nil)
+// refreshToken replaces the access token that req was sent with, unless a
+// concurrent request already did so. It reports whether req can be sent
+// again with a new token.
+func (c *DropboxClient) refreshToken(req *http.Request) bool {
+	if c.Refresh == nil {
+		return false
+	}
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	if req.Header.Get("Authorization") != "Bearer "+c.Token {
+		return true
+	}
+	token, err := c.Refresh()
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		return false
+	}
+	c.Token = token
+	return true
+}
+
+// headerArg encodes v as JSON for the Dropbox-API-Arg header. HTTP headers
+// must be ASCII, so as Dropbox requires, every character above 0x7E is
+// written as a \uXXXX escape (a surrogate pair outside the BMP), e.g. the ü
+// in /Notizen/Tagebücher.
+func headerArg(v interface{}) string {
+	data, _ := json.Marshal(v)
+	var b strings.Builder
+	for _, r := range string(data) {
+		if r < 0x7f {
+			b.WriteRune(r)
+			continue
+		}
+		if r > 0xffff {
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, "\\u%04x\\u%04x", r1, r2)
+			continue
+		}
+		fmt.Fprintf(&b, "\\u%04x", r)
+	}
+	return b.String()
+}
+
+// FolderEntry is a file or folder returned by files/list_folder.
+type FolderEntry struct {
+	Tag            string `json:".tag"`
+	Name           string `json:"name"`
+	PathDisplay    string `json:"path_display"`
+	Rev            string `json:"rev"`
+	Size           int64  `json:"size"`
+	ServerModified string `json:"server_modified"`
+}
+
+// rpc calls an RPC-style endpoint with a JSON argument and returns the
+// response body. Non-200 responses are reported with their error summary.
+func (c *DropboxClient) rpc(endpoint string, arg interface{}) ([]byte, error) {
+	if err := checkEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	payload, _ := json.Marshal(arg)
+	if c.Cache != nil && cachedEndpoints[endpoint] {
+		if body, ok := c.Cache.get(endpoint, payload); ok {
+			return body, nil
+		}
+	}
+	if writeEndpoints[endpoint] {
+		defer invalidateAPICache()
 	}
-	req.Header.Set("Authorization", "Bearer "+c.Token)
-	req.Header.Set("Dropbox-API-Arg", string(arg))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, body, err := c.do(true, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", c.endpointURL(endpoint, apiHost), bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		c.authorize(req)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("download request: %w", err)
+		return nil, fmt.Errorf("%s request: %w", endpoint, err)
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, newAPIError(resp, body)
+	}
+	if c.Cache != nil && cachedEndpoints[endpoint] {
+		c.Cache.put(endpoint, payload, body)
+	}
+	return body, nil
+}
+
+// ListFolder returns the entries under path, following pagination until
+// limit entries have been collected (0 means no limit). A missing folder
+// yields no entries and no error.
+func (c *DropboxClient) ListFolder(path string, recursive bool, limit int) ([]FolderEntry, error) {
+	var page struct {
+		Entries []FolderEntry `json:"entries"`
+		Cursor  string        `json:"cursor"`
+		HasMore bool          `json:"has_more"`
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.rpc("/2/files/list_folder", map[string]interface{}{
+		"path":      path,
+		"recursive": recursive,
+	})
 	if err != nil {
-		return "", fmt.Errorf("reading response: %w", err)
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
 	}
 
-	if resp.StatusCode == 409 {
-		var apiErr struct {
-			ErrorSummary string `json:"error_summary"`
+	var entries []FolderEntry
+	for {
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("parsing list_folder response: %w", err)
 		}
-		json.Unmarshal(body, &apiErr)
-		if strings.Contains(apiErr.ErrorSummary, "not_found") {
-			return "", nil
+		entries = append(entries, page.Entries...)
+		if !page.HasMore || (limit > 0 && len(entries) >= limit) {
+			break
 		}
-		return "", fmt.Errorf("dropbox API error: %s", apiErr.ErrorSummary)
+		body, err = c.rpc("/2/files/list_folder/continue", map[string]string{"cursor": page.Cursor})
+		if err != nil {
+			return nil, err
+		}
+		page.Entries = nil
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
 	}
+	return entries, nil
+}
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("dropbox API error (status %d): %s", resp.StatusCode, string(body))
+// Move renames from to to with files/move_v2 and returns the moved file's
+// rev. Dropbox refuses to move onto an existing file (to/conflict).
+func (c *DropboxClient) Move(from, to string) (string, error) {
+	if c.Txn != nil {
+		if err := c.Txn.before(to); err != nil {
+			return "", err
+		}
 	}
+	body, err := c.rpc("/2/files/move_v2", map[string]interface{}{
+		"from_path": from,
+		"to_path":   to,
+	})
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Metadata struct {
+			Rev string `json:"rev"`
+		} `json:"metadata"`
+	}
+	json.Unmarshal(body, &result)
+	if c.Txn != nil {
+		c.Txn.wrote(from, "")
+		c.Txn.wrote(to, result.Metadata.Rev)
+	}
+	return result.Metadata.Rev, nil
+}
+
+// Delete removes path with files/delete_v2. A missing file is not an error.
+func (c *DropboxClient) Delete(path string) error {
+	_, err := c.DeleteRev(path)
+	return err
+}
 
-	return string(body), nil
+// DeleteRev is like Delete but also returns the rev of the deleted file,
+// which files/restore can bring back. It's empty if there was no file.
+func (c *DropboxClient) DeleteRev(path string) (string, error) {
+	if c.Txn != nil {
+		if err := c.Txn.before(path); err != nil {
+			return "", err
+		}
+	}
+	body, err := c.rpc("/2/files/delete_v2", map[string]string{"path": path})
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Metadata struct {
+			Rev string `json:"rev"`
+		} `json:"metadata"`
+	}
+	json.Unmarshal(body, &result)
+	if c.Txn != nil {
+		c.Txn.wrote(path, "")
+	}
+	return result.Metadata.Rev, nil
+}
+
+// Download fetches a file from Dropbox. Returns empty string if file doesn't exist.
+// Paper documents are exported as Markdown.
+func (c *DropboxClient) Download(path string) (string, error) {
+	if isPaperDoc(path) {
+		return c.Export(path)
+	}
+	content, _, err := c.DownloadRev(path)
+	return content, err
+}
+
+// DownloadRev is like Download but also returns the file's revision, taken
+// from the Dropbox-API-Result response header. The revision is empty when the
+// file doesn't exist.
+func (c *DropboxClient) DownloadRev(path string) (string, string, error) {
+	var byteRange string
+	if c.MaxDownload > 0 {
+		byteRange = fmt.Sprintf("bytes=0-%d", c.MaxDownload)
+	}
+	resp, body, err := c.download(path, byteRange)
+	if err == nil && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// An empty file has no byte 0.
+		resp, body, err = c.download(path, "")
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("download request: %w", err)
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		if total := contentRangeTotal(resp); total > c.MaxDownload {
+			return "", "", fmt.Errorf("%s is %s, more than max_download_size (%s)", path, formatBytes(total), formatBytes(c.MaxDownload))
+		}
+	}
+
+	if resp.StatusCode != 200 && resp.StatusCode != http.StatusPartialContent {
+		apiErr := newAPIError(resp, body)
+		if errors.Is(apiErr, ErrNotFound) {
+			return "", "", nil
+		}
+		return "", "", apiErr
+	}
+
+	var meta struct {
+		Rev string `json:"rev"`
+	}
+	json.Unmarshal([]byte(resp.Header.Get("Dropbox-API-Result")), &meta)
+
+	return string(body), meta.Rev, nil
+}
+
+// content calls a content-style endpoint: arg goes in the Dropbox-API-Arg
+// header and data, if not nil, is the request body. prepare, if not nil,
+// adjusts each attempt's request before it is sent.
+func (c *DropboxClient) content(endpoint string, arg interface{}, data []byte, prepare func(*http.Request)) (*http.Response, []byte, error) {
+	return c.contentOnce(endpoint, arg, data, prepare, true)
+}
+
+// contentOnce is content, but unless replay is set a request that may have
+// reached Dropbox isn't sent again (see do).
+func (c *DropboxClient) contentOnce(endpoint string, arg interface{}, data []byte, prepare func(*http.Request), replay bool) (*http.Response, []byte, error) {
+	if err := checkEndpoint(endpoint); err != nil {
+		return nil, nil, err
+	}
+	header := headerArg(arg)
+	return c.do(replay, func() (*http.Request, error) {
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+		req, err := http.NewRequest("POST", c.endpointURL(endpoint, contentHost), body)
+		if err != nil {
+			return nil, err
+		}
+		c.authorize(req)
+		req.Header.Set("Dropbox-API-Arg", header)
+		if data != nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+		if prepare != nil {
+			prepare(req)
+		}
+		return req, nil
+	})
+}
+
+// download performs a files/download call, asking only for byteRange (an
+// HTTP Range value) when it is not empty.
+func (c *DropboxClient) download(path, byteRange string) (*http.Response, []byte, error) {
+	return c.content("/2/files/download", map[string]string{"path": path}, nil, func(req *http.Request) {
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+		}
+	})
 }
 
 // Upload writes content to a file in Dropbox, overwriting if it exists.
@@ -73,30 +398,132 @@ func (c *DropboxClient) Upload(path string, content string) error {
 // Use this instead of Upload for binary content (e.g. images) so the payload
 // is not corrupted by string handling.
 func (c *DropboxClient) UploadBytes(path string, data []byte) error {
-	arg, _ := json.Marshal(map[string]interface{}{
-		"path": path,
-		"mode": "overwrite",
-		"mute": true,
-	})
+	_, err := c.upload(path, data, "overwrite")
+	return err
+}
 
-	req, err := http.NewRequest("POST", c.baseURL()+"/2/files/upload", bytes.NewReader(data))
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+// UploadRev writes content only if the remote file is still at rev, and
+// returns the new revision. An empty rev means the file must not exist yet.
+// If the file changed in the meantime, ErrConflict is returned and nothing
+// is written.
+func (c *DropboxClient) UploadRev(path string, content string, rev string) (string, error) {
+	var mode interface{} = "add"
+	if rev != "" {
+		mode = map[string]string{".tag": "update", "update": rev}
+	}
+	return c.upload(path, []byte(content), mode)
+}
+
+// muted reports whether an upload to p should be muted, i.e. not show up
+// as a notification in the Dropbox apps.
+func (c *DropboxClient) muted(p string) bool {
+	if c.Notify {
+		return false
+	}
+	for _, pattern := range c.NotifyPaths {
+		if globMatch(pattern, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// upload performs a files/upload call with the given write mode and returns
+// the revision of the written file.
+func (c *DropboxClient) upload(path string, data []byte, mode interface{}) (string, error) {
+	arg := map[string]interface{}{
+		"path": path,
+		"mode": mode,
+		"mute": c.muted(path),
+	}
+	defer invalidateAPICache()
+	if c.Txn != nil {
+		if err := c.Txn.before(path); err != nil {
+			return "", err
+		}
+	}
+	var p *progress
+	if c.Progress != nil && len(data) >= progressMinUpload {
+		p = newProgress(c.Progress, "Uploading "+path, 1, int64(len(data)))
 	}
-	req.Header.Set("Authorization", "Bearer "+c.Token)
-	req.Header.Set("Dropbox-API-Arg", string(arg))
-	req.Header.Set("Content-Type", "application/octet-stream")
 
-	resp, err := http.DefaultClient.Do(req)
+	if data == nil {
+		data = []byte{}
+	}
+	// Overwriting is safe to repeat, but an "add" or "update" that Dropbox
+	// carried out before the connection dropped would fail as a conflict
+	// when sent again.
+	overwrite := mode == "overwrite"
+	resp, body, err := c.contentOnce("/2/files/upload", arg, data, func(req *http.Request) {
+		if p != nil {
+			p.resetBytes()
+			req.Body = io.NopCloser(progressReader{bytes.NewReader(data), p})
+		}
+	}, overwrite)
+	if !overwrite && (err != nil || resp.StatusCode == 409 && errors.Is(newAPIError(resp, body), ErrConflict)) {
+		// The response was lost, or a retry after a server error found the
+		// write already done: the file's content tells.
+		if rev, ok := c.uploaded(path, data); ok {
+			if c.Txn != nil {
+				c.Txn.wrote(path, rev)
+			}
+			return rev, nil
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("upload request: %w", err)
+		return "", fmt.Errorf("upload request: %w", err)
+	}
+	if p != nil {
+		p.add(1, 0)
+		p.done()
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("dropbox API error (status %d): %s", resp.StatusCode, string(body))
+		apiErr := newAPIError(resp, body)
+		if errors.Is(apiErr, ErrConflict) {
+			return "", ErrConflict
+		}
+		return "", apiErr
 	}
 
-	return nil
+	var meta struct {
+		Rev string `json:"rev"`
+	}
+	json.Unmarshal(body, &meta)
+	if c.Txn != nil {
+		c.Txn.wrote(path, meta.Rev)
+	}
+	return meta.Rev, nil
+}
+
+// uploaded reports whether the file at p holds exactly data, and returns
+// its revision, bypassing the API cache.
+func (c *DropboxClient) uploaded(p string, data []byte) (string, bool) {
+	fresh := *c
+	fresh.Cache = nil
+	body, err := fresh.rpc("/2/files/get_metadata", map[string]string{"path": p})
+	if err != nil {
+		return "", false
+	}
+	var meta struct {
+		Rev         string `json:"rev"`
+		ContentHash string `json:"content_hash"`
+	}
+	json.Unmarshal(body, &meta)
+	return meta.Rev, meta.Rev != "" && meta.ContentHash == contentHash(data)
+}
+
+// contentHash computes Dropbox's content_hash of data: the SHA-256 of the
+// concatenated SHA-256 hashes of its 4 MB blocks.
+func contentHash(data []byte) string {
+	const blockSize = 4 << 20
+	var blocks []byte
+	for len(data) > 0 {
+		n := min(len(data), blockSize)
+		sum := sha256.Sum256(data[:n])
+		blocks = append(blocks, sum[:]...)
+		data = data[n:]
+	}
+	sum := sha256.Sum256(blocks)
+	return hex.EncodeToString(sum[:])
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -111,6 +112,24 @@ func TestUpload_Success(t *testing.T) {
 	}
 }
 
+func TestDropboxClient_Muted(t *testing.T) {
+	tests := []struct {
+		client DropboxClient
+		path   string
+		want   bool
+	}{
+		{DropboxClient{}, "/Journal/20250115.md", true},
+		{DropboxClient{Notify: true}, "/Journal/20250115.md", false},
+		{DropboxClient{NotifyPaths: []string{"/family/*.md"}}, "/Family/20250115.md", false},
+		{DropboxClient{NotifyPaths: []string{"/Family/*.md"}}, "/Journal/20250115.md", true},
+	}
+	for _, tt := range tests {
+		if got := tt.client.muted(tt.path); got != tt.want {
+			t.Errorf("%+v muted(%s) = %v, want %v", tt.client, tt.path, got, tt.want)
+		}
+	}
+}
+
 func TestUpload_APIError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
@@ -205,3 +224,110 @@ func TestIntegration_NewFile(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, uploadedContent)
 	}
 }
+
+func TestHeaderArg_EscapesNonASCII(t *testing.T) {
+	arg := headerArg(map[string]string{"path": "/Notizen/Tagebücher/日記 📓.md"})
+	want := `{"path":"/Notizen/Tageb\u00fccher/\u65e5\u8a18 \ud83d\udcd3.md"}`
+	if arg != want {
+		t.Errorf("got %s, want %s", arg, want)
+	}
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(arg), &decoded); err != nil || decoded["path"] != "/Notizen/Tagebücher/日記 📓.md" {
+		t.Errorf("round trip failed: %v, %q", err, decoded["path"])
+	}
+}
+
+func TestAppendToJournal_NonASCIIPathTemplate(t *testing.T) {
+	var rawArgs []string
+	files := map[string]string{}
+	inner := rolloverServer(files)
+	defer inner.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawArgs = append(rawArgs, r.Header.Get("Dropbox-API-Arg"))
+		inner.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+	path, err := renderPathTemplate("/Notizen/Tagebücher/{{.Year}}/Eintrag {{.Date}}.md", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nHallo\n", appendOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files["/Notizen/Tagebücher/2025/Eintrag 20250115.md"] != "### 14:30:45\nHallo\n" {
+		t.Errorf("note not written at the localized path: %v", files)
+	}
+	for _, a := range rawArgs {
+		for _, r := range a {
+			if r > 0x7e {
+				t.Fatalf("non-ASCII byte in Dropbox-API-Arg: %q", a)
+			}
+		}
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	c := &DropboxClient{}
+	if got := c.endpointURL("/2/files/list_folder", apiHost); got != "https://api.dropboxapi.com/2/files/list_folder" {
+		t.Errorf("rpc = %s", got)
+	}
+	if got := c.endpointURL("/2/files/upload", contentHost); got != "https://content.dropboxapi.com/2/files/upload" {
+		t.Errorf("content = %s", got)
+	}
+
+	c.Endpoints = map[string]string{"content": "https://proxy.example/", "/2/files/download": "http://localhost:8080"}
+	if got := c.endpointURL("/2/files/upload", contentHost); got != "https://proxy.example/2/files/upload" {
+		t.Errorf("host override = %s", got)
+	}
+	if got := c.endpointURL("/2/files/download", contentHost); got != "http://localhost:8080/2/files/download" {
+		t.Errorf("endpoint override = %s", got)
+	}
+	if got := c.endpointURL("/2/files/list_folder", apiHost); got != "https://api.dropboxapi.com/2/files/list_folder" {
+		t.Errorf("api with a content override = %s", got)
+	}
+
+	if err := checkEndpointOverride("uploads", "https://proxy.example"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+	if err := checkEndpointOverride("api", "proxy.example"); err == nil {
+		t.Error("expected an error for a URL without a scheme")
+	}
+}
+
+func TestDownload_RefreshesExpiredToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error_summary": "expired_access_token/"}`))
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	refreshes := 0
+	client := &DropboxClient{Token: "expired", BaseURL: server.URL, Refresh: func() (string, error) {
+		refreshes++
+		return "fresh", nil
+	}}
+	content, err := client.Download("/a.md")
+	if err != nil || content != "content" {
+		t.Fatalf("got %q %v, want the content after refreshing", content, err)
+	}
+	if refreshes != 1 || client.Token != "fresh" {
+		t.Errorf("expected one refresh to the fresh token, got %d and %q", refreshes, client.Token)
+	}
+
+	// A token that is rejected again isn't refreshed in a loop.
+	client.Refresh = func() (string, error) { refreshes++; return "still-bad", nil }
+	client.Token = "expired"
+	if _, err := client.Download("/a.md"); !errors.Is(err, ErrAuth) {
+		t.Errorf("expected ErrAuth, got %v", err)
+	}
+	if refreshes != 2 {
+		t.Errorf("expected a single further refresh, got %d", refreshes-1)
+	}
+}
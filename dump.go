@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// dumpRecord is one entry as written by dump. The fields are a stable
+// schema for pandas, SQLite's json functions and the like.
+type dumpRecord struct {
+	Date      string   `json:"date"` // 2006-01-02
+	Time      string   `json:"time"`
+	Timestamp string   `json:"timestamp"` // 2006-01-02T15:04:05, local time
+	Number    int      `json:"number,omitempty"`
+	ID        string   `json:"id,omitempty"`
+	Author    string   `json:"author,omitempty"`
+	Tags      []string `json:"tags"`
+	Text      string   `json:"text"`
+	Path      string   `json:"path"`
+}
+
+// newDumpRecord converts an indexed entry.
+func newDumpRecord(e indexEntry) dumpRecord {
+	t := e.Time
+	if len(t) == len("15:04") {
+		t += ":00"
+	}
+	tags := e.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return dumpRecord{Date: e.Date, Time: e.Time, Timestamp: e.Date + "T" + t, Number: e.Number, ID: e.ID,
+		Author: e.Author, Tags: tags, Text: e.Text, Path: e.Path}
+}
+
+// writeDump writes entries as JSON Lines, one record per line, or as a
+// single JSON array.
+func writeDump(w io.Writer, entries []indexEntry, output string) error {
+	records := make([]dumpRecord, len(entries))
+	for i, e := range entries {
+		records[i] = newDumpRecord(e)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if output == "json" {
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runDump implements the `dropbox-appender dump` subcommand: the entries of
+// the local index as structured records. It returns the process exit code.
+func runDump(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", "jsonl", "output format: jsonl or json")
+	tag := fs.String("tag", "", "only entries with this #tag")
+	author := fs.String("author", "", "only entries by this author")
+	dates := addDateFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *output != "jsonl" && *output != "json" {
+		fmt.Fprintf(stderr, "invalid -output %q: expected jsonl or json\n", *output)
+		return 2
+	}
+
+	entries, ok := loadIndexedEntries(stderr)
+	if !ok {
+		return 1
+	}
+	filter := entryFilter{Tag: *tag, Author: *author, Dates: *dates}
+	if err := writeDump(stdout, searchEntries(entries, fs.Args(), filter), *output); err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	return 0
+}
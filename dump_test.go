@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDump(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeDump(&buf, queryEntries[:2], "jsonl"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines:\n%s", len(lines), buf.String())
+	}
+	var r dumpRecord
+	if err := json.Unmarshal([]byte(lines[0]), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Timestamp != "2025-01-13T09:00:00" || r.Tags[0] != "work" || !strings.HasPrefix(r.Text, "planning") {
+		t.Errorf("record = %+v", r)
+	}
+
+	buf.Reset()
+	var records []dumpRecord
+	if err := writeDump(&buf, nil, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil || records == nil {
+		t.Errorf("empty json dump = %q, %v", buf.String(), err)
+	}
+}
+
+func TestRunDump(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	idx, _ := loadIndex(defaultIndexPath())
+	idx.update("/Journal/20250115.md", "### 1. 09:00\n<a id=\"20250115-1\"></a>\nstandup #work\n\n### 2. 14:30:45 — alice\n<a id=\"20250115-2\"></a>\nlunch\n", "r1",
+		time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC))
+	idx.update("/Journal/20250116.md", "### 08:00:00\nlater\n", "r2", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC))
+	if err := idx.save(defaultIndexPath()); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runDump([]string{"-from", "2025-01-15", "-to", "2025-01-15"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	want := `{"date":"2025-01-15","time":"09:00","timestamp":"2025-01-15T09:00:00","number":1,"id":"20250115-1","tags":["work"],"text":"standup #work","path":"/Journal/20250115.md"}
+{"date":"2025-01-15","time":"14:30:45","timestamp":"2025-01-15T14:30:45","number":2,"id":"20250115-2","author":"alice","tags":[],"text":"lunch","path":"/Journal/20250115.md"}
+`
+	if stdout.String() != want {
+		t.Errorf("dump:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+
+	if code := runDump([]string{"-output", "csv"}, nil, io.Discard, &stderr); code != 2 {
+		t.Errorf("-output csv: exit %d", code)
+	}
+}
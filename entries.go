@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+)
+
+// journalEntry is one timestamped entry parsed from a note.
+type journalEntry struct {
+	Number int    // 0 when the entry isn't numbered
+	Time   string // HH:MM:SS (or HH:MM)
+	ID     string // anchor ID of numbered entries
+	Author string // empty when the entry isn't attributed
+	Text   string // body without header or anchor, trimmed
+}
+
+// tagPattern matches #tags in entry text. Markdown headings ("# Title") don't
+// match because a tag must start with a letter straight after the #.
+var tagPattern = regexp.MustCompile(`(?:^|[\s(])#([A-Za-z][\w/-]*)`)
+
+// taskPattern matches markdown task list items and captures the check mark
+// and the task text.
+var taskPattern = regexp.MustCompile(`(?m)^\s*[-*] \[([ xX])\] (.+)$`)
+
+// parseEntries splits note content into its timestamped entries. Content
+// before the first entry header isn't part of any entry and is skipped; see
+// parseJournal.
+func parseEntries(content string) []journalEntry {
+	return parseJournal(content).entries()
+}
+
+// extractTags returns the distinct #tags in text, in order of appearance.
+func extractTags(text string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, m := range tagPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			tags = append(tags, m[1])
+		}
+	}
+	return tags
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEntries(t *testing.T) {
+	content := "# Wednesday\n\n### 09:00:00\nmorning #work\n\n" +
+		"### 2. 14:30:45\n<a id=\"note20250115-2\"></a>\nafternoon\nsecond line\n"
+	entries := parseEntries(content)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[0].Time != "09:00:00" || entries[0].Number != 0 || entries[0].Text != "morning #work" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Number != 2 || entries[1].ID != "note20250115-2" || entries[1].Text != "afternoon\nsecond line" {
+		t.Errorf("unexpected second entry: %+v", entries[1])
+	}
+}
+
+func TestParseEntries_Empty(t *testing.T) {
+	if entries := parseEntries("just some text\n"); len(entries) != 0 {
+		t.Errorf("expected no entries, got %+v", entries)
+	}
+}
+
+func TestExtractTags(t *testing.T) {
+	got := extractTags("#work call with (#acme) about #work/q1, not a#tag or # heading")
+	want := "work,acme,work/q1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by DropboxClient methods. Callers can test for them with
+// errors.Is (and errors.As for *ErrRateLimited) instead of matching on the
+// error text; API failures are *APIError values wrapping one of these when
+// the response is recognised.
+var (
+	// ErrNotFound means the path doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the remote file changed since it was downloaded
+	// (an UploadRev revision mismatch) or a move target already exists.
+	ErrConflict = errors.New("remote file changed since it was downloaded")
+	// ErrAuth means the access or refresh token was rejected; running
+	// `dropbox-appender auth` again fixes it.
+	ErrAuth = errors.New("authentication failed")
+	// ErrInsufficientSpace means the account is out of storage.
+	ErrInsufficientSpace = errors.New("insufficient space in Dropbox")
+	// ErrReadOnly means a write was refused because -read-only or the
+	// read_only config is set.
+	ErrReadOnly = errors.New("refused in read-only mode")
+)
+
+// ErrRateLimited is returned when Dropbox is still throttling a request once
+// retries are exhausted. RetryAfter is the wait Dropbox asked for, or zero
+// if it didn't say.
+type ErrRateLimited struct {
+	RetryAfter time.Duration
+}
+
+func (e *ErrRateLimited) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited, retry after %v", e.RetryAfter)
+	}
+	return "rate limited"
+}
+
+// APIError is an unsuccessful Dropbox API response.
+type APIError struct {
+	StatusCode int
+	Summary    string // Dropbox's error_summary, e.g. "path/not_found/.."
+	Body       string // the raw body when it carried no error_summary
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	if e.Summary != "" {
+		return "dropbox API error: " + e.Summary
+	}
+	return fmt.Sprintf("dropbox API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// Unwrap returns ErrNotFound, ErrConflict, ErrAuth, ErrInsufficientSpace or
+// *ErrRateLimited when the response is one of those, and nil otherwise.
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// newAPIError classifies the failed response resp with body.
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	var parsed struct {
+		ErrorSummary string `json:"error_summary"`
+		Error        struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &parsed)
+	e := &APIError{StatusCode: resp.StatusCode, Summary: parsed.ErrorSummary}
+	if e.Summary == "" {
+		e.Body = string(body)
+	}
+
+	switch {
+	case resp.StatusCode == 401:
+		e.kind = ErrAuth
+	case resp.StatusCode == 429 || strings.Contains(e.Summary, "too_many_"):
+		rl := &ErrRateLimited{RetryAfter: time.Duration(parsed.Error.RetryAfter) * time.Second}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			rl.RetryAfter = time.Duration(secs) * time.Second
+		}
+		e.kind = rl
+	case resp.StatusCode == 409 && strings.Contains(e.Summary, "insufficient_space"):
+		e.kind = ErrInsufficientSpace
+	case resp.StatusCode == 409 && strings.Contains(e.Summary, "not_found"):
+		e.kind = ErrNotFound
+	case resp.StatusCode == 409 && strings.Contains(e.Summary, "conflict"):
+		e.kind = ErrConflict
+	}
+	return e
+}
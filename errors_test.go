@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		body    string
+		want    error
+		wantMsg string
+	}{
+		{"not found", 409, nil, `{"error_summary": "path/not_found/.."}`, ErrNotFound, "dropbox API error: path/not_found/.."},
+		{"conflict", 409, nil, `{"error_summary": "path/conflict/file/.."}`, ErrConflict, "dropbox API error: path/conflict/file/.."},
+		{"move target exists", 409, nil, `{"error_summary": "to/conflict/file/.."}`, ErrConflict, ""},
+		{"out of space", 409, nil, `{"error_summary": "path/insufficient_space/.."}`, ErrInsufficientSpace, ""},
+		{"expired token", 401, nil, `{"error_summary": "expired_access_token/"}`, ErrAuth, ""},
+		{"other", 409, nil, `{"error_summary": "path/malformed_path/"}`, nil, ""},
+		{"no summary", 500, nil, `oops`, nil, "dropbox API error (status 500): oops"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			err := newAPIError(resp, []byte(tt.body))
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+			if tt.want == nil && err.Unwrap() != nil {
+				t.Errorf("Unwrap() = %v, want nil", err.Unwrap())
+			}
+			if tt.wantMsg != "" && err.Error() != tt.wantMsg {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestNewAPIError_RateLimited(t *testing.T) {
+	resp := &http.Response{StatusCode: 429, Header: http.Header{"Retry-After": {"7"}}}
+	var rl *ErrRateLimited
+	if err := newAPIError(resp, []byte(`{"error_summary": "too_many_requests/"}`)); !errors.As(err, &rl) {
+		t.Fatalf("errors.As(%v, *ErrRateLimited) = false", err)
+	}
+	if rl.RetryAfter != 7*time.Second {
+		t.Errorf("RetryAfter = %v, want 7s", rl.RetryAfter)
+	}
+
+	// Without the header, the retry_after field of the body is used.
+	resp = &http.Response{StatusCode: 429, Header: http.Header{}}
+	err := newAPIError(resp, []byte(`{"error_summary": "too_many_write_operations/", "error": {"retry_after": 2}}`))
+	if !errors.As(err, &rl) || rl.RetryAfter != 2*time.Second {
+		t.Errorf("got %v, RetryAfter %v; want 2s", err, rl.RetryAfter)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/files/list_revisions":
+			w.WriteHeader(409)
+			w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+		case "/2/files/move_v2":
+			w.WriteHeader(409)
+			w.Write([]byte(`{"error_summary": "to/conflict/file/"}`))
+		case "/2/files/upload":
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error_summary": "invalid_access_token/"}`))
+		}
+	}))
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	if _, err := client.ListRevisions("/missing.md", 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ListRevisions: %v, want ErrNotFound", err)
+	}
+	if _, err := client.Move("/a.md", "/b.md"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Move: %v, want ErrConflict", err)
+	}
+	if err := client.Upload("/a.md", "x"); !errors.Is(err, ErrAuth) {
+		t.Errorf("Upload: %v, want ErrAuth", err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultGCDays is how many days of notes, up to today, gc looks through.
+const defaultGCDays = 30
+
+// expiryPattern matches the marker -expires adds to an entry, an HTML
+// comment that doesn't show when the note is rendered.
+var expiryPattern = regexp.MustCompile(`<!-- expires (\S+) -->`)
+
+// parseExpiry turns an -expires value into the time the entry expires:
+// a duration such as 7d, 2w or 36h from now, or a date (YYYY-MM-DD, the
+// entry then expires at the end of that day).
+func parseExpiry(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return d.AddDate(0, 0, 1), nil
+	}
+	for suffix, days := range map[string]int{"d": 1, "w": 7} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) && n > 0 {
+			return now.AddDate(0, 0, n*days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -expires %q: expected e.g. 7d, 2w, 36h or YYYY-MM-DD", s)
+}
+
+// withExpiry adds the expiry marker to entry text.
+func withExpiry(text string, at time.Time) string {
+	return text + "\n<!-- expires " + at.Format(time.RFC3339) + " -->"
+}
+
+// entryExpiry returns when an entry's text expires, if it carries a marker.
+func entryExpiry(text string) (time.Time, bool) {
+	m := expiryPattern.FindStringSubmatch(text)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, m[1])
+	return t, err == nil
+}
+
+// removeExpired drops the entries of content that expired by now, keeping
+// the rest of the note byte for byte. It returns the new content and how
+// many entries were removed.
+func removeExpired(content string, now time.Time) (string, int) {
+	doc := parseJournal(content)
+	var kept []*journalBlock
+	removed := 0
+	for i, b := range doc.Blocks {
+		if at, ok := entryExpiry(b.Text); !ok || at.After(now) {
+			kept = append(kept, b)
+			continue
+		}
+		removed++
+		// The note keeps ending the way it did when its last entry goes.
+		if i == len(doc.Blocks)-1 && len(kept) > 0 {
+			kept[len(kept)-1].Trailer = b.Trailer
+		}
+	}
+	if removed == 0 {
+		return content, 0
+	}
+	doc.Blocks = kept
+	return doc.String(), removed
+}
+
+// collectGarbage removes expired entries from the notes of the last days
+// days, every part of each. With dryRun it only reports them. It returns
+// the number of entries removed. Rewritten notes are indexed and mirrored
+// as opts configures (see wroteNote).
+func collectGarbage(client *DropboxClient, cfg *Config, opts appendOptions, now time.Time, days int, dryRun bool, stdout, stderr io.Writer) (int, error) {
+	total := 0
+	for i := days - 1; i >= 0; i-- {
+		day := now.AddDate(0, 0, -i)
+		p, err := journalPath(cfg, "", day)
+		if err != nil {
+			return total, err
+		}
+		for n := 1; ; n++ {
+			part := continuationPath(p, n)
+			content, rev, err := client.DownloadRev(part)
+			if err != nil {
+				return total, fmt.Errorf("downloading %s: %w", part, err)
+			}
+			if content == "" {
+				break
+			}
+			updated, removed := removeExpired(content, now)
+			if removed == 0 {
+				continue
+			}
+			if dryRun {
+				fmt.Fprintf(stdout, "%s: %d expired entries\n", part, removed)
+				total += removed
+				continue
+			}
+			newRev, err := client.UploadRev(part, updated, rev)
+			if errors.Is(err, ErrConflict) {
+				fmt.Fprintf(stderr, "warning: %s changed while collecting expired entries; skipped until the next run\n", part)
+				continue
+			}
+			if err != nil {
+				return total, fmt.Errorf("uploading %s: %w", part, err)
+			}
+			wroteNote(client, p, part, updated, updated, newRev, opts)
+			fmt.Fprintf(stdout, "%s: removed %d expired entries\n", part, removed)
+			total += removed
+		}
+	}
+	return total, nil
+}
+
+// runGC implements the `dropbox-appender gc` subcommand, which removes
+// entries appended with -expires once they have expired. It returns the
+// process exit code.
+func runGC(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	days := fs.Int("days", defaultGCDays, "how many days of notes, up to today, to look through")
+	dryRun := fs.Bool("dry-run", false, "only list the notes with expired entries")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *days < 1 {
+		fmt.Fprintln(stderr, "-days must be at least 1")
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if !*dryRun && refuseReadOnly(stderr, "gc") {
+		return 1
+	}
+	client, err := connectClient(cfg, !*dryRun)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	if _, err := collectGarbage(client, cfg, opts, clock.Now(), *days, *dryRun, stdout, stderr); err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	return 0
+}
+
+// gcScheduler runs gc from the daemon at most once per interval.
+type gcScheduler struct {
+	cfg      *Config
+	opts     appendOptions
+	interval time.Duration
+	last     time.Time
+}
+
+// run collects expired entries when the interval has passed since the last
+// run. Failures are reported and retried next time.
+func (g *gcScheduler) run(client *DropboxClient, now time.Time, stderr io.Writer) {
+	if !g.last.IsZero() && now.Sub(g.last) < g.interval {
+		return
+	}
+	if _, err := collectGarbage(client, g.cfg, g.opts, now, defaultGCDays, false, stderr, stderr); err != nil {
+		fmt.Fprintf(stderr, "warning: removing expired entries: %v\n", err)
+		return
+	}
+	g.last = now
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"7d", time.Date(2025, 1, 22, 14, 30, 0, 0, time.UTC)},
+		{"2w", time.Date(2025, 1, 29, 14, 30, 0, 0, time.UTC)},
+		{"36h", time.Date(2025, 1, 17, 2, 30, 0, 0, time.UTC)},
+		{"2025-01-20", time.Date(2025, 1, 21, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got, err := parseExpiry(tt.in, now); err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseExpiry(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "d", "0d", "-3d", "soon", "-1h"} {
+		if _, err := parseExpiry(bad, now); err == nil {
+			t.Errorf("parseExpiry(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	now := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	expired := withExpiry("call the plumber", now.Add(-time.Hour))
+	pending := withExpiry("renew passport", now.Add(time.Hour))
+	content := "# Notes\n\n### 09:00:00\nkeep me\n\n### 10:00:00\n" + expired + "\n\n### 11:00:00\n" + pending + "\n\n### 12:00:00\n" + expired + "\n"
+
+	got, removed := removeExpired(content, now)
+	want := "# Notes\n\n### 09:00:00\nkeep me\n\n### 11:00:00\n" + pending + "\n"
+	if removed != 2 || got != want {
+		t.Errorf("removed %d:\n%q\nwant\n%q", removed, got, want)
+	}
+	if got, removed := removeExpired(want, now); removed != 0 || got != want {
+		t.Errorf("second pass removed %d", removed)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	now := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	stale := "### 09:00:00\n" + withExpiry("temp", now.Add(-time.Minute)) + "\n"
+	files := map[string]string{
+		"/Notes/Journal/2025/01/Note20250118.md":   "### 08:00:00\nkeep\n\n" + stale,
+		"/Notes/Journal/2025/01/Note20250118-2.md": stale,
+		"/Notes/Journal/2025/01/Note20250119.md":   "### 08:00:00\nnothing expires\n",
+	}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	var stdout, stderr bytes.Buffer
+	n, err := collectGarbage(client, &Config{}, appendOptions{}, now, 3, true, &stdout, &stderr)
+	if err != nil || n != 2 || files["/Notes/Journal/2025/01/Note20250118-2.md"] != stale {
+		t.Fatalf("dry run: %d, %v, files changed: %v", n, err, files)
+	}
+
+	n, err = collectGarbage(client, &Config{}, appendOptions{IndexPath: defaultIndexPath()}, now, 3, false, &stdout, &stderr)
+	if err != nil || n != 2 {
+		t.Fatalf("got %d, %v (%s)", n, err, stderr.String())
+	}
+	if got := files["/Notes/Journal/2025/01/Note20250118.md"]; got != "### 08:00:00\nkeep\n" {
+		t.Errorf("part 1 = %q", got)
+	}
+	if got := files["/Notes/Journal/2025/01/Note20250118-2.md"]; strings.Contains(got, "temp") {
+		t.Errorf("part 2 = %q", got)
+	}
+}
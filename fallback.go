@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultNtfyURL = "https://ntfy.sh"
+	defaultGistURL = "https://api.github.com/gists"
+)
+
+// FallbackConfig is the "fallback" section of the config file: a secondary
+// sink that receives entries when Dropbox can't be reached.
+type FallbackConfig struct {
+	Type  string `json:"type"`            // "file", "ntfy" or "gist"
+	Path  string `json:"path,omitempty"`  // file: where entries are appended
+	Topic string `json:"topic,omitempty"` // ntfy: topic to publish to
+	URL   string `json:"url,omitempty"`   // ntfy server or gist API endpoint
+	Token string `json:"token,omitempty"` // ntfy access token or GitHub token
+}
+
+// postFallback sends entry, destined for notePath, to the fallback sink and
+// returns a description of where it went.
+func postFallback(cfg FallbackConfig, notePath, entry string, now time.Time) (string, error) {
+	switch cfg.Type {
+	case "file":
+		p := expandHome(cfg.Path)
+		if p == "" {
+			p = filepath.Join(defaultDataDir(), "fallback.md")
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return "", err
+		}
+		f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if _, err := fmt.Fprintf(f, "<!-- %s -->\n%s\n", notePath, entry); err != nil {
+			return "", err
+		}
+		return p, nil
+
+	case "ntfy":
+		if cfg.Topic == "" {
+			return "", fmt.Errorf("fallback: ntfy requires a topic")
+		}
+		base := cfg.URL
+		if base == "" {
+			base = defaultNtfyURL
+		}
+		req, err := http.NewRequest("POST", base+"/"+cfg.Topic, bytes.NewBufferString(entry))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Title", notePath)
+		if cfg.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		}
+		return "ntfy topic " + cfg.Topic, postFallbackRequest(req)
+
+	case "gist":
+		if cfg.Token == "" {
+			return "", fmt.Errorf("fallback: gist requires a token")
+		}
+		endpoint := cfg.URL
+		if endpoint == "" {
+			endpoint = defaultGistURL
+		}
+		name := fmt.Sprintf("%s-%s", now.Format("20060102-150405"), path.Base(notePath))
+		payload, _ := json.Marshal(map[string]interface{}{
+			"description": "dropbox-appender fallback for " + notePath,
+			"public":      false,
+			"files":       map[string]interface{}{name: map[string]string{"content": entry}},
+		})
+		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Content-Type", "application/json")
+		return "secret gist " + name, postFallbackRequest(req)
+	}
+	return "", fmt.Errorf("fallback: unknown type %q: expected file, ntfy or gist", cfg.Type)
+}
+
+// postFallbackRequest sends req and treats any non-2xx status as an error.
+func postFallbackRequest(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fallback request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("fallback request: status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// unavailable reports whether err means Dropbox couldn't be reached or was
+// failing for the time being: a network error or timeout, rate limiting or
+// a server error. Only then is an entry saved to the fallback; a rejected
+// token or a conflict won't go away by trying later.
+func unavailable(err error) bool {
+	var netErr net.Error
+	var rateLimited *ErrRateLimited
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded), errors.As(err, &rateLimited):
+		return true
+	case errors.As(err, &apiErr):
+		return apiErr.StatusCode >= 500
+	}
+	return false
+}
+
+// saveToFallback is used when an append to Dropbox fails: the entry is
+// queued locally for `flush` and also posted to the configured fallback
+// sink. It reports whether the entry was saved.
+func saveToFallback(stderr io.Writer, cfg FallbackConfig, queueDir string, q queuedEntry, cause error) bool {
+	if err := enqueue(queueDir, q); err != nil {
+		fmt.Fprintf(stderr, "error: %v; queueing entry also failed: %v\n", cause, err)
+		return false
+	}
+	fmt.Fprintf(stderr, "warning: %v\n", cause)
+	if where, err := postFallback(cfg, q.Path, q.Entry, q.QueuedAt); err != nil {
+		fmt.Fprintf(stderr, "warning: %v\n", err)
+	} else {
+		fmt.Fprintf(stderr, "Entry copied to %s\n", where)
+	}
+	fmt.Fprintln(stderr, "Entry queued; run `dropbox-appender flush` once Dropbox is reachable")
+	return true
+}
+
+// runFlush implements the `dropbox-appender flush` subcommand, appending
+// entries queued by -queue or by the fallback to their notes. It returns the
+// process exit code.
+func runFlush(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("flush", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, client, ok := loadClient(stderr, true)
+	if !ok {
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	return runFlushWithClient(stdout, stderr, client, defaultQueueDir(), opts)
+}
+
+// runFlushWithClient is the testable core of the flush subcommand. It fails
+// if any queued entry couldn't be appended.
+func runFlushWithClient(stdout, stderr io.Writer, client *DropboxClient, dir string, opts appendOptions) int {
+	n, err := flushQueue(client, dir, opts, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: reading queue: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Flushed %d entries\n", n)
+	if queue, _ := loadQueue(dir, io.Discard); len(queue) > 0 {
+		fmt.Fprintf(stderr, "%d entries are still queued\n", len(queue))
+		return 1
+	}
+	return 0
+}
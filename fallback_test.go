@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var fallbackNow = time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+
+func TestPostFallback_File(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "fallback.md")
+	where, err := postFallback(FallbackConfig{Type: "file", Path: p}, "/a.md", "### 14:30:45\nhi\n", fallbackNow)
+	if err != nil || where != p {
+		t.Fatalf("got %q, %v", where, err)
+	}
+	data, _ := os.ReadFile(p)
+	if string(data) != "<!-- /a.md -->\n### 14:30:45\nhi\n\n" {
+		t.Errorf("unexpected file content %q", data)
+	}
+}
+
+func TestPostFallback_Ntfy(t *testing.T) {
+	var gotPath, gotTitle, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotTitle, gotBody = r.URL.Path, r.Header.Get("Title"), string(body)
+	}))
+	defer server.Close()
+
+	cfg := FallbackConfig{Type: "ntfy", URL: server.URL, Topic: "journal"}
+	if _, err := postFallback(cfg, "/a.md", "entry\n", fallbackNow); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/journal" || gotTitle != "/a.md" || gotBody != "entry\n" {
+		t.Errorf("unexpected request: %s %s %q", gotPath, gotTitle, gotBody)
+	}
+}
+
+func TestPostFallback_Gist(t *testing.T) {
+	var payload struct {
+		Public bool                         `json:"public"`
+		Files  map[string]map[string]string `json:"files"`
+	}
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(201)
+	}))
+	defer server.Close()
+
+	cfg := FallbackConfig{Type: "gist", URL: server.URL, Token: "gh-token"}
+	if _, err := postFallback(cfg, "/Notes/Note20250115.md", "entry\n", fallbackNow); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	file := payload.Files["20250115-143045-Note20250115.md"]
+	if payload.Public || file["content"] != "entry\n" || auth != "Bearer gh-token" {
+		t.Errorf("unexpected gist request: %+v (auth %q)", payload, auth)
+	}
+}
+
+func TestPostFallback_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(403)
+	}))
+	defer server.Close()
+
+	for _, cfg := range []FallbackConfig{
+		{Type: "pigeon"},
+		{Type: "ntfy"},
+		{Type: "gist"},
+		{Type: "ntfy", Topic: "t", URL: server.URL},
+	} {
+		if _, err := postFallback(cfg, "/a.md", "x", fallbackNow); err == nil {
+			t.Errorf("%+v: expected error", cfg)
+		}
+	}
+}
+
+func TestSaveToFallbackAndFlush(t *testing.T) {
+	dir := t.TempDir()
+	queueDir := filepath.Join(dir, "queue")
+	cfg := FallbackConfig{Type: "file", Path: filepath.Join(dir, "fallback.md")}
+	var stderr bytes.Buffer
+	if !saveToFallback(&stderr, cfg, queueDir, queuedEntry{Path: "/a.md", Entry: "### 14:30:45\nhi\n", QueuedAt: fallbackNow}, errors.New("dropbox down")) {
+		t.Fatalf("expected the entry to be saved (stderr=%q)", stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "run `dropbox-appender flush`") {
+		t.Errorf("expected a flush hint, got %q", stderr.String())
+	}
+
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	var stdout bytes.Buffer
+	if code := runFlushWithClient(&stdout, io.Discard, client, queueDir, appendOptions{}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if files["/a.md"] != "### 14:30:45\nhi\n" || stdout.String() != "Flushed 1 entries\n" {
+		t.Errorf("unexpected flush result %q / %q", files["/a.md"], stdout.String())
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("upload request: %w", &url.Error{Op: "Post", URL: "https://x", Err: errors.New("connection refused")}), true},
+		{fmt.Errorf("verifying account: %w", &APIError{StatusCode: 503}), true},
+		{&APIError{StatusCode: 429, kind: &ErrRateLimited{}}, true},
+		{context.DeadlineExceeded, true},
+		{&APIError{StatusCode: 401, kind: ErrAuth}, false},
+		{fmt.Errorf("uploading journal: %w", ErrConflict), false},
+		{errors.New("no authentication configured, run: dropbox-appender auth"), false},
+	}
+	for _, c := range cases {
+		if got := unavailable(c.err); got != c.want {
+			t.Errorf("unavailable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestRunAppend_FallbackBeforeConnecting(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		status   int // 0 closes the server
+		wantCode int
+	}{
+		{"unreachable", 0, 0},
+		{"rejected token", 401, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("XDG_DATA_HOME", t.TempDir())
+			t.Setenv("DROPBOX_TOKEN", "direct_token")
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(c.status)
+			}))
+			if c.status == 0 {
+				server.Close()
+			} else {
+				defer server.Close()
+			}
+
+			zero := 0
+			fallbackPath := filepath.Join(home, "fallback.md")
+			// A credential other than the authorized one makes the client
+			// verify the account before writing.
+			cfg := &Config{AccountID: "dbid:a", AccountCredential: "stale",
+				Endpoints: map[string]string{"api": server.URL, "content": server.URL},
+				Retry:     &RetryConfig{MaxRetries: &zero},
+				Fallback:  &FallbackConfig{Type: "file", Path: fallbackPath}}
+			os.MkdirAll(filepath.Dir(defaultConfigPath()), 0700)
+			if err := saveConfig(defaultConfigPath(), cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			var stderr bytes.Buffer
+			code := runAppend([]string{"offline entry"}, strings.NewReader(""), io.Discard, &stderr, fixedClock(fallbackNow))
+			if code != c.wantCode {
+				t.Fatalf("exit %d, want %d (stderr=%q)", code, c.wantCode, stderr.String())
+			}
+			data, _ := os.ReadFile(fallbackPath)
+			if saved := strings.Contains(string(data), "offline entry"); saved != (c.wantCode == 0) {
+				t.Errorf("fallback file = %q, stderr = %q", data, stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// foldSummaryWidth is how much of the first line a folded entry's summary
+// shows.
+const foldSummaryWidth = 80
+
+// FoldConfig folds long entries, e.g. captured command output, into a
+// collapsible block so daily notes stay skimmable.
+type FoldConfig struct {
+	// Lines folds entries longer than this many lines; 0 disables folding.
+	Lines int `json:"lines"`
+	// Syntax is "details" (HTML <details>, the default) or "callout" (an
+	// Obsidian callout, > [!note]-).
+	Syntax string `json:"syntax,omitempty"`
+}
+
+// validFoldSyntax checks a fold syntax.
+func validFoldSyntax(syntax string) error {
+	switch syntax {
+	case "", "details", "callout":
+		return nil
+	}
+	return fmt.Errorf("invalid fold syntax %q: expected details or callout", syntax)
+}
+
+// foldText folds text into a collapsible block when it has more than
+// maxLines lines, with its first line as the summary. Shorter text, and
+// any text when maxLines is 0, is returned unchanged.
+func foldText(text string, maxLines int, syntax string) string {
+	if maxLines <= 0 || strings.Count(text, "\n")+1 <= maxLines {
+		return text
+	}
+	summary := foldSummary(text)
+	if syntax == "callout" {
+		lines := strings.Split(text, "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimRight("> "+l, " ")
+		}
+		return "> [!note]- " + summary + "\n" + strings.Join(lines, "\n")
+	}
+	return "<details><summary>" + html.EscapeString(summary) + "</summary>\n\n" + text + "\n\n</details>"
+}
+
+// foldSummary returns the first non-blank line of text, shortened to
+// foldSummaryWidth characters. A code fence line gives way to the line
+// after it.
+func foldSummary(text string) string {
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "```") || strings.HasPrefix(l, "~~~") {
+			continue
+		}
+		if r := []rune(l); len(r) > foldSummaryWidth {
+			l = string(r[:foldSummaryWidth-1]) + "…"
+		}
+		return l
+	}
+	return "…"
+}
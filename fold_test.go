@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFoldText(t *testing.T) {
+	long := "```\n$ make <all>\nok\n```"
+	tests := []struct {
+		name   string
+		text   string
+		lines  int
+		syntax string
+		want   string
+	}{
+		{"disabled", long, 0, "", long},
+		{"short", "one\ntwo", 2, "", "one\ntwo"},
+		{"details", long, 3, "",
+			"<details><summary>$ make &lt;all&gt;</summary>\n\n" + long + "\n\n</details>"},
+		{"callout", "first\n\nsecond", 2, "callout",
+			"> [!note]- first\n> first\n>\n> second"},
+	}
+	for _, tt := range tests {
+		if got := foldText(tt.text, tt.lines, tt.syntax); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFoldSummary_Truncates(t *testing.T) {
+	got := foldSummary("\n" + strings.Repeat("é", 100))
+	if n := len([]rune(got)); n != foldSummaryWidth || !strings.HasSuffix(got, "…") {
+		t.Errorf("got %q (%d runes)", got, n)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// formFields are available to form templates alongside prompt and choose.
+type formFields struct {
+	Date string // YYYY-MM-DD
+	Time string // HH:MM
+}
+
+// defaultFormsDir returns the directory holding form templates, one
+// <name>.md file each, next to the config file.
+func defaultFormsDir() string {
+	return filepath.Join(filepath.Dir(defaultConfigPath()), "forms")
+}
+
+// loadForm returns the template of the named form, from the config's forms
+// map or else from <dir>/<name>.md.
+func loadForm(cfg *Config, dir, name string) (string, error) {
+	if tmpl, ok := cfg.Forms[name]; ok {
+		return tmpl, nil
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid form name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+".md"))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no form named %q (add it to \"forms\" in the config or create %s)",
+			name, filepath.Join(dir, name+".md"))
+	}
+	return string(data), err
+}
+
+// formNames lists the forms defined in the config and in dir.
+func formNames(cfg *Config, dir string) []string {
+	seen := map[string]bool{}
+	for name := range cfg.Forms {
+		seen[name] = true
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+	for _, m := range matches {
+		seen[strings.TrimSuffix(filepath.Base(m), ".md")] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fillForm executes a form template, asking each prompt and choose question
+// on out and reading the answers from in, in template order. The whole
+// template is parsed first so a syntax error is reported before any
+// question is asked.
+func fillForm(text string, in *bufio.Scanner, out io.Writer, now time.Time) (string, error) {
+	readAnswer := func() (string, error) {
+		if !in.Scan() {
+			if err := in.Err(); err != nil {
+				return "", err
+			}
+			return "", errors.New("form cancelled: no more input")
+		}
+		return strings.TrimSpace(in.Text()), nil
+	}
+	funcs := template.FuncMap{
+		"prompt": func(question string) (string, error) {
+			fmt.Fprintf(out, "%s: ", question)
+			return readAnswer()
+		},
+		"choose": func(question string, options ...string) (string, error) {
+			if len(options) == 0 {
+				return "", fmt.Errorf("choose %q has no options", question)
+			}
+			fmt.Fprintf(out, "%s\n", question)
+			for i, o := range options {
+				fmt.Fprintf(out, "  %d) %s\n", i+1, o)
+			}
+			for {
+				fmt.Fprintf(out, "Choice [1-%d]: ", len(options))
+				answer, err := readAnswer()
+				if err != nil {
+					return "", err
+				}
+				if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+					return options[n-1], nil
+				}
+				for _, o := range options {
+					if strings.EqualFold(answer, o) {
+						return o, nil
+					}
+				}
+				fmt.Fprintf(out, "%q is not one of the choices\n", answer)
+			}
+		},
+	}
+	t, err := template.New("form").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid form template: %w", err)
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, formFields{Date: now.Format("2006-01-02"), Time: now.Format("15:04")})
+	if err != nil {
+		var execErr template.ExecError
+		if errors.As(err, &execErr) && errors.Unwrap(execErr.Err) != nil {
+			return "", errors.Unwrap(execErr.Err)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// runForm implements the `dropbox-appender form <name>` subcommand: it asks
+// the questions in the named form template and appends the filled-in result.
+// It returns the process exit code.
+func runForm(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("form", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	list := fs.Bool("list", false, "list the available forms")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if *list {
+		for _, name := range formNames(cfg, defaultFormsDir()) {
+			fmt.Fprintln(stdout, name)
+		}
+		return 0
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: dropbox-appender form [-list] <name>")
+		return 2
+	}
+	tmpl, err := loadForm(cfg, defaultFormsDir(), fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	now := clock.Now()
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	return runFormWithClient(bufio.NewScanner(stdin), stdout, stderr, client, path, tmpl, now, opts)
+}
+
+// runFormWithClient is the testable core of the form subcommand. Nothing is
+// written unless every question was answered.
+func runFormWithClient(in *bufio.Scanner, stdout, stderr io.Writer, client *DropboxClient,
+	path, tmpl string, now time.Time, opts appendOptions) int {
+
+	text, err := fillForm(tmpl, in, stdout, now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if text == "" {
+		fmt.Fprintln(stderr, "error: form produced an empty entry")
+		return 1
+	}
+	written, err := appendToJournal(client, path, formatEntry(now, text, false), opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "error updating journal: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Appended to %s\n", written)
+	return 0
+}
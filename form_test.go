@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFillForm(t *testing.T) {
+	now := time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC)
+	tmpl := `{{.Date}} check-in
+Mood: {{prompt "Mood (1-5)"}}
+Category: {{choose "Category" "Work" "Home"}}`
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{"by number", "4\n2\n", "2025-01-15 check-in\nMood: 4\nCategory: Home", ""},
+		{"by name", "3\nwork\n", "2025-01-15 check-in\nMood: 3\nCategory: Work", ""},
+		{"invalid choice asks again", "3\n7\nHome\n", "2025-01-15 check-in\nMood: 3\nCategory: Home", ""},
+		{"input ends early", "3\n", "", "form cancelled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := fillForm(tmpl, bufio.NewScanner(strings.NewReader(tt.input)), &out, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !strings.Contains(out.String(), "Mood (1-5): ") || !strings.Contains(out.String(), "  2) Home") {
+				t.Errorf("questions not shown:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestFillForm_ParseErrorBeforeQuestions(t *testing.T) {
+	var out bytes.Buffer
+	_, err := fillForm(`{{prompt "A"}} {{if}}`, bufio.NewScanner(strings.NewReader("x\n")), &out, time.Now())
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if out.Len() != 0 {
+		t.Errorf("asked questions before reporting the parse error: %q", out.String())
+	}
+}
+
+func TestLoadForm(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "habits.md"), []byte("Ran: {{prompt \"km\"}}"), 0600)
+	cfg := &Config{Forms: map[string]string{"mood": "Mood: {{prompt \"Mood\"}}"}}
+
+	if got, err := loadForm(cfg, dir, "mood"); err != nil || !strings.HasPrefix(got, "Mood:") {
+		t.Errorf("mood = %q, %v", got, err)
+	}
+	if got, err := loadForm(cfg, dir, "habits"); err != nil || !strings.HasPrefix(got, "Ran:") {
+		t.Errorf("habits = %q, %v", got, err)
+	}
+	for _, name := range []string{"missing", "../config", ""} {
+		if _, err := loadForm(cfg, dir, name); err == nil {
+			t.Errorf("loadForm(%q) should fail", name)
+		}
+	}
+	if got := formNames(cfg, dir); !reflect.DeepEqual(got, []string{"habits", "mood"}) {
+		t.Errorf("formNames = %v", got)
+	}
+}
+
+func TestRunFormWithClient(t *testing.T) {
+	files := map[string]string{"/Journal/2025-01-15.md": "### 08:00:00\nearlier\n"}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	now := time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC)
+
+	var stdout, stderr bytes.Buffer
+	in := bufio.NewScanner(strings.NewReader("5\n"))
+	code := runFormWithClient(in, &stdout, &stderr, client, "/Journal/2025-01-15.md", `Mood: {{prompt "Mood"}}`, now, appendOptions{})
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	want := "### 08:00:00\nearlier\n\n### 09:30:00\nMood: 5\n"
+	if got := files["/Journal/2025-01-15.md"]; got != want {
+		t.Errorf("note = %q, want %q", got, want)
+	}
+
+	// Cancelled forms leave the note untouched.
+	in = bufio.NewScanner(strings.NewReader(""))
+	if code := runFormWithClient(in, &stdout, &stderr, client, "/Journal/2025-01-15.md", `Mood: {{prompt "Mood"}}`, now, appendOptions{}); code != 1 {
+		t.Errorf("cancelled form exit = %d, want 1", code)
+	}
+	if got := files["/Journal/2025-01-15.md"]; got != want {
+		t.Errorf("cancelled form changed the note: %q", got)
+	}
+}
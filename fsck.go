@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// frontMatterLinePattern matches a "key: value" line, a list item or an
+// indented continuation inside YAML front matter.
+var frontMatterLinePattern = regexp.MustCompile(`^(?:[\w-]+:(?:\s.*)?|\s*- .*|\s+\S.*|#(?:[^#].*)?)$`)
+
+// fsckIssue is a structural problem found in a note.
+type fsckIssue struct {
+	Line    int
+	Message string
+	Fixable bool
+}
+
+// lineAt returns the 1-based line number of byte offset off in s.
+func lineAt(s string, off int) int {
+	return strings.Count(s[:off], "\n") + 1
+}
+
+// frontMatterEnd returns the byte offset just past the closing --- of the
+// note's front matter, 0 if there is none, or -1 if it is never closed.
+func frontMatterEnd(content string) int {
+	if !strings.HasPrefix(content, "---\n") {
+		return 0
+	}
+	off := len("---\n")
+	for _, line := range strings.SplitAfter(content[off:], "\n") {
+		off += len(line)
+		if strings.TrimRight(line, "\r\n") == "---" {
+			return off
+		}
+	}
+	return -1
+}
+
+// checkNote validates a daily note: well-formed front matter, entries in
+// time order and unique entry IDs.
+func checkNote(content string) []fsckIssue {
+	var issues []fsckIssue
+
+	body := 0
+	switch end := frontMatterEnd(content); {
+	case end < 0:
+		issues = append(issues, fsckIssue{1, "front matter is never closed with ---", true})
+	case end > 0:
+		body = end
+		fm := content[len("---\n"):end]
+		for i, line := range strings.Split(fm[:strings.LastIndex(fm, "---")], "\n") {
+			if line != "" && !frontMatterLinePattern.MatchString(line) {
+				issues = append(issues, fsckIssue{i + 2, fmt.Sprintf("invalid front matter line %q", line), false})
+			}
+		}
+	}
+
+	prev := ""
+	for _, loc := range entryHeaderPattern.FindAllStringSubmatchIndex(content, -1) {
+		if loc[0] < body {
+			continue
+		}
+		t := content[loc[4]:loc[5]]
+		if t < prev {
+			issues = append(issues, fsckIssue{lineAt(content, loc[0]),
+				fmt.Sprintf("entry at %s is out of order (after %s)", t, prev), true})
+		} else {
+			prev = t
+		}
+	}
+
+	first := map[string]int{}
+	for _, loc := range entryAnchorPattern.FindAllStringSubmatchIndex(content, -1) {
+		id, line := content[loc[2]:loc[3]], lineAt(content, loc[0])
+		if l, ok := first[id]; ok {
+			issues = append(issues, fsckIssue{line, fmt.Sprintf("duplicate entry ID %q (first on line %d)", id, l), true})
+			continue
+		}
+		first[id] = line
+	}
+	return issues
+}
+
+// fixNote repairs what checkNote reports as fixable: it closes unterminated
+// front matter, drops entries that are exact duplicates, gives remaining
+// duplicate IDs a unique suffix and sorts entries by time. Content before the
+// first entry is kept in place.
+func fixNote(content string) string {
+	if frontMatterEnd(content) < 0 {
+		// Close the front matter after its last key line. A "#" line is more
+		// likely a markdown heading than a YAML comment here.
+		lines := strings.SplitAfter(content, "\n")
+		n := 1
+		for n < len(lines) && !strings.HasPrefix(lines[n], "#") &&
+			frontMatterLinePattern.MatchString(strings.TrimRight(lines[n], "\n")) {
+			n++
+		}
+		content = strings.Join(lines[:n], "") + "---\n" + strings.Join(lines[n:], "")
+	}
+
+	locs := entryHeaderPattern.FindAllStringIndex(content[frontMatterEnd(content):], -1)
+	if len(locs) == 0 {
+		return content
+	}
+	offset := frontMatterEnd(content)
+	preamble := content[:offset+locs[0][0]]
+
+	type block struct {
+		time string
+		text string
+	}
+	var blocks []block
+	seen := map[string]bool{}
+	for i, loc := range locs {
+		end := len(content)
+		if i+1 < len(locs) {
+			end = offset + locs[i+1][0]
+		}
+		text := strings.TrimRight(content[offset+loc[0]:end], "\n") + "\n"
+		if seen[text] {
+			continue
+		}
+		seen[text] = true
+		m := entryHeaderPattern.FindStringSubmatch(text)
+		blocks = append(blocks, block{time: m[2], text: text})
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].time < blocks[j].time })
+
+	ids := map[string]int{}
+	result := strings.TrimRight(preamble, "\n")
+	if result != "" {
+		result += "\n"
+	}
+	for _, b := range blocks {
+		text := entryAnchorPattern.ReplaceAllStringFunc(b.text, func(anchor string) string {
+			id := entryAnchorPattern.FindStringSubmatch(anchor)[1]
+			ids[id]++
+			if ids[id] == 1 {
+				return anchor
+			}
+			return fmt.Sprintf(`<a id="%s-%d"></a>`, id, ids[id])
+		})
+		result = appendContent(result, text)
+	}
+	return result
+}
+
+// runFsck implements the `dropbox-appender fsck` subcommand. It returns 0
+// when the note is clean (or was repaired), 1 when problems remain and
+// exitConflict when the note changed while being repaired.
+func runFsck(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	date := fs.String("date", "", "check the note for this date (YYYY-MM-DD) instead of today")
+	fix := fs.Bool("fix", false, "repair fixable problems and upload the result")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	now := clock.Now()
+	if *date != "" {
+		d, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+			return 2
+		}
+		now = d
+	}
+
+	cfg, client, ok := loadClient(stderr, *fix)
+	if !ok {
+		return 1
+	}
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	return runFsckWithClient(stdout, stderr, client, path, *fix, cfg.MergeTool, opts)
+}
+
+// runFsckWithClient is the testable core of the fsck subcommand. Repairs are
+// uploaded only if the note's rev hasn't changed since it was checked; if it
+// has, mergeTool (when set) reconciles the two versions. A repaired note is
+// indexed and mirrored as opts configures (see wroteNote).
+func runFsckWithClient(stdout, stderr io.Writer, client *DropboxClient, path string, fix bool, mergeTool string, opts appendOptions) int {
+	content, rev, err := client.DownloadRev(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading %s: %v\n", path, err)
+		return 1
+	}
+	if content == "" {
+		fmt.Fprintf(stdout, "%s: no note\n", path)
+		return 0
+	}
+
+	issues := checkNote(content)
+	for _, is := range issues {
+		fmt.Fprintf(stdout, "%s:%d: %s\n", path, is.Line, is.Message)
+	}
+	if len(issues) == 0 {
+		fmt.Fprintf(stdout, "%s: ok\n", path)
+		return 0
+	}
+	if !fix {
+		return 1
+	}
+
+	fixed := fixNote(content)
+	if fixed != content {
+		uploaded := fixed
+		newRev, err := client.UploadRev(path, fixed, rev)
+		if errors.Is(err, ErrConflict) && mergeTool != "" {
+			uploaded, newRev, err = mergeAndUpload(client, mergeTool, path, content, fixed, stderr)
+		}
+		if errors.Is(err, ErrConflict) {
+			fmt.Fprintf(stderr, "error: %s changed while it was being repaired; run fsck again\n", path)
+			return exitConflict
+		} else if err != nil {
+			fmt.Fprintf(stderr, "error: uploading %s: %v\n", path, err)
+			return 1
+		}
+		wroteNote(client, path, path, uploaded, uploaded, newRev, opts)
+	}
+	remaining := checkNote(fixed)
+	fmt.Fprintf(stdout, "%s: fixed %d of %d problems\n", path, len(issues)-len(remaining), len(issues))
+	if len(remaining) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckNote_Clean(t *testing.T) {
+	content := "---\ntitle: Wednesday\ntags:\n  - daily\n---\n### 09:00:00\na\n\n### 10:00:00\nb\n"
+	if issues := checkNote(content); len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestCheckNote_Problems(t *testing.T) {
+	content := "---\ntitle: x\nnot yaml at all\n---\n" +
+		"### 1. 10:00:00\n<a id=\"n-1\"></a>\nb\n\n" +
+		"### 2. 09:00:00\n<a id=\"n-1\"></a>\na\n"
+	issues := checkNote(content)
+	if len(issues) != 3 {
+		t.Fatalf("expected 3 issues, got %+v", issues)
+	}
+	if issues[0].Line != 3 || issues[0].Fixable {
+		t.Errorf("expected unfixable front matter issue on line 3, got %+v", issues[0])
+	}
+	if issues[1].Line != 9 || !strings.Contains(issues[1].Message, "out of order") {
+		t.Errorf("expected ordering issue on line 9, got %+v", issues[1])
+	}
+	if issues[2].Line != 10 || !strings.Contains(issues[2].Message, `duplicate entry ID "n-1"`) {
+		t.Errorf("expected duplicate ID issue on line 10, got %+v", issues[2])
+	}
+
+	if issues := checkNote("---\ntitle: x\n### 09:00:00\na\n"); len(issues) != 1 || !issues[0].Fixable {
+		t.Errorf("expected an unterminated front matter issue, got %+v", issues)
+	}
+}
+
+func TestFixNote(t *testing.T) {
+	content := "---\ntitle: x\n# Heading\n\n" +
+		"### 10:00:00\nb\n\n" +
+		"### 09:00:00\na\n\n" +
+		"### 10:00:00\nb\n" +
+		"### 11:00:00\n<a id=\"n-1\"></a>\nc\n\n" +
+		"### 12:00:00\n<a id=\"n-1\"></a>\nd\n"
+	want := "---\ntitle: x\n---\n# Heading\n\n" +
+		"### 09:00:00\na\n\n" +
+		"### 10:00:00\nb\n\n" +
+		"### 11:00:00\n<a id=\"n-1\"></a>\nc\n\n" +
+		"### 12:00:00\n<a id=\"n-1-2\"></a>\nd\n"
+	got := fixNote(content)
+	if got != want {
+		t.Errorf("fixNote:\n got %q\nwant %q", got, want)
+	}
+	if issues := checkNote(got); len(issues) != 0 {
+		t.Errorf("expected the fixed note to be clean, got %+v", issues)
+	}
+}
+
+func TestRunFsckWithClient(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	var uploadArg, uploaded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/upload") {
+			buf := new(bytes.Buffer)
+			buf.ReadFrom(r.Body)
+			uploaded, uploadArg = buf.String(), r.Header.Get("Dropbox-API-Arg")
+			w.Write([]byte(`{"rev":"rev2"}`))
+			return
+		}
+		w.Header().Set("Dropbox-API-Result", `{"rev":"rev1"}`)
+		w.Write([]byte("### 10:00:00\nb\n\n### 09:00:00\na\n"))
+	}))
+	defer server.Close()
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+
+	var stdout, stderr bytes.Buffer
+	if code := runFsckWithClient(&stdout, &stderr, client, path, false, "", appendOptions{}); code != 1 {
+		t.Errorf("expected exit code 1 for a broken note, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), path+":4: entry at 09:00:00 is out of order") || uploaded != "" {
+		t.Errorf("unexpected report %q or upload %q", stdout.String(), uploaded)
+	}
+
+	stdout.Reset()
+	if code := runFsckWithClient(&stdout, &stderr, client, path, true, "", appendOptions{}); code != 0 {
+		t.Fatalf("expected exit code 0 after fixing, got %d (stderr=%q)", code, stderr.String())
+	}
+	if uploaded != "### 09:00:00\na\n\n### 10:00:00\nb\n" || !strings.Contains(uploadArg, `"update":"rev1"`) {
+		t.Errorf("expected rev-protected repair, got %q (%s)", uploaded, uploadArg)
+	}
+	if !strings.Contains(stdout.String(), "fixed 1 of 1 problems") {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
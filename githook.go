@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// postCommitHook is the hook script `git-hook -install` writes. The append
+// runs in the background so a slow network never holds up a commit.
+const postCommitHook = "#!/bin/sh\n# Added by dropbox-appender git-hook -install\ndropbox-appender git-hook >/dev/null 2>&1 &\n"
+
+// commitInfo describes the commit a git-hook entry is about.
+type commitInfo struct {
+	Repo    string // base name of the working tree
+	Branch  string // empty for a detached HEAD
+	SHA     string // abbreviated
+	Subject string
+}
+
+// gitCommitInfo reads the latest commit of the repository containing dir.
+func gitCommitInfo(dir string) (commitInfo, error) {
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return commitInfo{}, err
+	}
+	log, err := git(dir, "log", "-1", "--format=%h%x00%s")
+	if err != nil {
+		return commitInfo{}, err
+	}
+	sha, subject, ok := strings.Cut(strings.TrimRight(log, "\n"), "\x00")
+	if !ok {
+		return commitInfo{}, fmt.Errorf("unexpected git log output %q", log)
+	}
+	info := commitInfo{Repo: filepath.Base(strings.TrimSpace(top)), SHA: sha, Subject: subject}
+	if branch, err := git(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && strings.TrimSpace(branch) != "HEAD" {
+		info.Branch = strings.TrimSpace(branch)
+	}
+	return info, nil
+}
+
+// commitEntryText formats info as a journal entry, e.g.
+// "💻 commit `1a2b3c4` in api (main): Fix login".
+func commitEntryText(info commitInfo) string {
+	where := info.Repo
+	if info.Branch != "" {
+		where += " (" + info.Branch + ")"
+	}
+	return fmt.Sprintf("💻 commit `%s` in %s: %s", info.SHA, where, info.Subject)
+}
+
+// installPostCommitHook writes the post-commit hook of the repository
+// containing dir, refusing to replace a hook that is already there.
+func installPostCommitHook(dir string) (string, error) {
+	hooks, err := git(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	hooksDir := strings.TrimSpace(hooks)
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	hook := filepath.Join(hooksDir, "post-commit")
+	if _, err := os.Stat(hook); err == nil {
+		return "", fmt.Errorf("%s already exists; add `dropbox-appender git-hook &` to it by hand", hook)
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return "", err
+	}
+	return hook, os.WriteFile(hook, []byte(postCommitHook), 0755)
+}
+
+// runGitHook implements `dropbox-appender git-hook`, meant to be run from a
+// post-commit hook: it appends an entry describing the commit just made to
+// today's note, queueing it for the daemon when Dropbox can't be reached.
+// With -install it writes the hook instead. It returns the process exit
+// code.
+func runGitHook(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("git-hook", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("C", ".", "repository to read the commit from")
+	install := fs.Bool("install", false, "install a post-commit hook in the repository that runs git-hook")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected argument %q\n", fs.Arg(0))
+		return 2
+	}
+	if *install {
+		hook, err := installPostCommitHook(*dir)
+		if err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Installed %s\n", hook)
+		return 0
+	}
+
+	info, err := gitCommitInfo(*dir)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	cfg, client, ok := loadClient(stderr, true)
+	if !ok {
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	s := &server{client: client, cfg: cfg, opts: opts, clock: clock, queueDir: defaultQueueDir(), stderr: stderr}
+	return runGitHookWithServer(stdout, stderr, s, info)
+}
+
+// runGitHookWithServer appends the entry for info through s.
+func runGitHookWithServer(stdout, stderr io.Writer, s *server, info commitInfo) int {
+	written, err := s.appendText(commitEntryText(info), "")
+	if err != nil {
+		fmt.Fprintf(stderr, "error: appending commit %s: %v\n", info.SHA, err)
+		return 1
+	}
+	if written == "" {
+		return 0 // queued; appendText has said so
+	}
+	fmt.Fprintf(stdout, tr("Appended to %s\n"), written)
+	return 0
+}
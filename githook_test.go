@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commitRepo creates a repository named api with one commit on branch main.
+func commitRepo(t *testing.T) string {
+	t.Helper()
+	setupGitIdentity(t)
+	repo := filepath.Join(t.TempDir(), "api")
+	os.MkdirAll(repo, 0700)
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"commit", "-q", "--allow-empty", "-m", "Fix login redirect"},
+	} {
+		if _, err := git(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestGitCommitInfo(t *testing.T) {
+	repo := commitRepo(t)
+	info, err := gitCommitInfo(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Repo != "api" || info.Branch != "main" || info.Subject != "Fix login redirect" || len(info.SHA) < 7 {
+		t.Errorf("info = %+v", info)
+	}
+
+	git(repo, "checkout", "-q", "--detach")
+	if info, _ := gitCommitInfo(repo); info.Branch != "" {
+		t.Errorf("detached HEAD branch = %q, want none", info.Branch)
+	}
+	if _, err := gitCommitInfo(t.TempDir()); err == nil {
+		t.Error("expected an error outside a repository")
+	}
+}
+
+func TestCommitEntryText(t *testing.T) {
+	got := commitEntryText(commitInfo{Repo: "api", Branch: "main", SHA: "1a2b3c4", Subject: "Fix login"})
+	if want := "💻 commit `1a2b3c4` in api (main): Fix login"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	got = commitEntryText(commitInfo{Repo: "api", SHA: "1a2b3c4", Subject: "Fix login"})
+	if want := "💻 commit `1a2b3c4` in api: Fix login"; got != want {
+		t.Errorf("detached: got %q, want %q", got, want)
+	}
+}
+
+func TestInstallPostCommitHook(t *testing.T) {
+	repo := commitRepo(t)
+	hook, err := installPostCommitHook(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook != filepath.Join(repo, ".git", "hooks", "post-commit") {
+		t.Errorf("hook = %s", hook)
+	}
+	info, err := os.Stat(hook)
+	if err != nil || info.Mode()&0100 == 0 {
+		t.Fatalf("hook not executable: %v, %v", info, err)
+	}
+	if _, err := installPostCommitHook(repo); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second install: err = %v", err)
+	}
+}
+
+func TestRunGitHookWithServer(t *testing.T) {
+	files := map[string]string{}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	s := &server{
+		client:   &DropboxClient{Token: "tok", BaseURL: srv.URL},
+		cfg:      &Config{PathTemplate: "/Journal/{{.Date}}.md"},
+		clock:    fixedClock(time.Date(2025, 1, 15, 14, 30, 45, 0, time.Local)),
+		queueDir: t.TempDir(),
+		stderr:   &bytes.Buffer{},
+	}
+	var stdout, stderr bytes.Buffer
+	info := commitInfo{Repo: "api", Branch: "main", SHA: "1a2b3c4", Subject: "Fix login"}
+	if code := runGitHookWithServer(&stdout, &stderr, s, info); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	want := "### 14:30:45\n💻 commit `1a2b3c4` in api (main): Fix login\n"
+	if got := files["/Journal/20250115.md"]; got != want {
+		t.Errorf("note = %q, want %q", got, want)
+	}
+}
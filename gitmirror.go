@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultGitMirrorMessage is the commit message used when none is configured.
+const defaultGitMirrorMessage = "Update {{.Path}}"
+
+// GitMirrorConfig is the "git_mirror" section of the config file. When set,
+// each note written is also committed to a local git repository, giving a
+// history independent of Dropbox's revision window.
+type GitMirrorConfig struct {
+	Repo    string `json:"repo"`
+	Message string `json:"message,omitempty"`
+}
+
+// gitMessageFields are the values available to the commit message template.
+type gitMessageFields struct {
+	Path string // Dropbox path of the note
+	Date string // 2006-01-02
+	Time string // 15:04:05
+}
+
+// expandHome replaces a leading ~/ with the user's home directory.
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, p[2:])
+	}
+	return p
+}
+
+// git runs a git command in repo and returns its combined output.
+func git(repo string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// mirrorToGit writes content to the note's path inside the mirror repository
+// (initialising it if needed) and commits it. Nothing is committed when the
+// file is unchanged.
+func mirrorToGit(cfg GitMirrorConfig, notePath, content string, now time.Time) error {
+	repo := expandHome(cfg.Repo)
+	if _, err := os.Stat(filepath.Join(repo, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(repo, 0700); err != nil {
+			return err
+		}
+		if _, err := git(repo, "init", "-q"); err != nil {
+			return err
+		}
+	}
+
+	rel := filepath.FromSlash(strings.TrimPrefix(notePath, "/"))
+	file := filepath.Join(repo, rel)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		return err
+	}
+	if _, err := git(repo, "add", "--", rel); err != nil {
+		return err
+	}
+	if status, err := git(repo, "status", "--porcelain", "--", rel); err != nil || status == "" {
+		return err
+	}
+
+	msg := cfg.Message
+	if msg == "" {
+		msg = defaultGitMirrorMessage
+	}
+	t, err := template.New("message").Parse(msg)
+	if err != nil {
+		return fmt.Errorf("parsing git_mirror message: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, gitMessageFields{
+		Path: notePath,
+		Date: now.Format("2006-01-02"),
+		Time: now.Format("15:04:05"),
+	}); err != nil {
+		return fmt.Errorf("rendering git_mirror message: %w", err)
+	}
+
+	_, err = git(repo, "commit", "-q", "-m", buf.String(), "--", rel)
+	return err
+}
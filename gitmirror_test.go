@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupGitIdentity makes commits work on machines without a git identity.
+func setupGitIdentity(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+}
+
+func TestMirrorToGit(t *testing.T) {
+	setupGitIdentity(t)
+	repo := filepath.Join(t.TempDir(), "mirror")
+	cfg := GitMirrorConfig{Repo: repo, Message: "journal {{.Date}} {{.Time}}"}
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+	notePath := "/Notes/Journal/2025/01/Note20250115.md"
+
+	if err := mirrorToGit(cfg, notePath, "### 14:30:45\nfirst\n", now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(repo, "Notes", "Journal", "2025", "01", "Note20250115.md"))
+	if err != nil || string(data) != "### 14:30:45\nfirst\n" {
+		t.Fatalf("mirror file: %q, %v", data, err)
+	}
+	log, _ := git(repo, "log", "--format=%s")
+	if strings.TrimSpace(log) != "journal 2025-01-15 14:30:45" {
+		t.Errorf("unexpected log: %q", log)
+	}
+
+	// Unchanged content doesn't create an empty commit.
+	if err := mirrorToGit(cfg, notePath, "### 14:30:45\nfirst\n", now); err != nil {
+		t.Fatalf("unexpected error on no-op: %v", err)
+	}
+	if err := mirrorToGit(cfg, notePath, "### 14:30:45\nfirst\n\n### 15:00:00\nsecond\n", now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	log, _ = git(repo, "log", "--format=%s")
+	if n := len(strings.Split(strings.TrimSpace(log), "\n")); n != 2 {
+		t.Errorf("expected 2 commits, got %d:\n%s", n, log)
+	}
+}
+
+func TestAppendToJournal_GitMirror(t *testing.T) {
+	setupGitIdentity(t)
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 09:00:00\nmorning\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	repo := filepath.Join(t.TempDir(), "mirror")
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	opts := appendOptions{GitMirror: &GitMirrorConfig{Repo: repo}}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(repo, "Notes", "Journal", "2025", "01", "Note20250115.md"))
+	if string(data) != files[path] {
+		t.Errorf("mirror out of sync:\n got %q\nwant %q", data, files[path])
+	}
+	log, _ := git(repo, "log", "--format=%s")
+	if strings.TrimSpace(log) != "Update "+path {
+		t.Errorf("unexpected default message: %q", log)
+	}
+}
+
+func TestAppendToJournal_GitMirrorUsesClock(t *testing.T) {
+	setupGitIdentity(t)
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	server := rolloverServer(map[string]string{path: ""})
+	defer server.Close()
+
+	repo := filepath.Join(t.TempDir(), "mirror")
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	opts := appendOptions{
+		GitMirror: &GitMirrorConfig{Repo: repo, Message: "{{.Date}} {{.Time}}"},
+		Clock:     fixedClock(time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)),
+	}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	log, _ := git(repo, "log", "--format=%s")
+	if strings.TrimSpace(log) != "2025-01-15 14:30:45" {
+		t.Errorf("commit not dated by the clock: %q", log)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// globalFlags are the options that apply to every subcommand. They are
+// given before the subcommand name, or before the entry's own flags and
+// text in the default mode, so a subcommand's arguments are never mistaken
+// for them.
+type globalFlags struct {
+	DebugHTTP string // "" when disabled, "-" for stderr, or a log file
+	Verbose   bool
+	Plain     bool
+	ReadOnly  bool
+	Profile   string
+	BWLimit   string
+}
+
+// extractGlobalFlags removes the global flags at the start of args, up to
+// the first argument that isn't one, and returns the rest. Each flag takes
+// one or two dashes, and -profile and -bwlimit take their value as the next
+// argument or after "=". Defaults come from the environment:
+// DROPBOX_APPENDER_DEBUG_HTTP (1 or a file name), DROPBOX_APPENDER_VERBOSE,
+// DROPBOX_APPENDER_PLAIN and DROPBOX_APPENDER_READ_ONLY (1),
+// DROPBOX_APPENDER_PROFILE and DROPBOX_APPENDER_BWLIMIT.
+func extractGlobalFlags(args []string) ([]string, globalFlags, error) {
+	g := globalFlags{
+		DebugHTTP: os.Getenv("DROPBOX_APPENDER_DEBUG_HTTP"),
+		Verbose:   os.Getenv("DROPBOX_APPENDER_VERBOSE") == "1",
+		Plain:     os.Getenv("DROPBOX_APPENDER_PLAIN") == "1",
+		ReadOnly:  os.Getenv("DROPBOX_APPENDER_READ_ONLY") == "1",
+		Profile:   os.Getenv("DROPBOX_APPENDER_PROFILE"),
+		BWLimit:   os.Getenv("DROPBOX_APPENDER_BWLIMIT"),
+	}
+	if g.DebugHTTP == "1" || g.DebugHTTP == "true" {
+		g.DebugHTTP = "-"
+	}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" && args[0] != "--" {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		// needValue takes the value of -name from the next argument when
+		// it wasn't given after "=".
+		needValue := func(what string) error {
+			if hasValue {
+				return nil
+			}
+			if len(args) < 2 {
+				return fmt.Errorf("-%s needs %s", name, what)
+			}
+			args = args[1:]
+			value = args[0]
+			return nil
+		}
+		switch {
+		case name == "debug-http":
+			g.DebugHTTP = "-"
+			if hasValue && value != "" {
+				g.DebugHTTP = value
+			}
+		case name == "verbose" && !hasValue:
+			g.Verbose = true
+		case name == "plain" && !hasValue:
+			g.Plain = true
+		case name == "read-only" && !hasValue:
+			g.ReadOnly = true
+		case name == "profile":
+			if err := needValue("a profile name"); err != nil {
+				return nil, g, err
+			}
+			g.Profile = value
+		case name == "bwlimit":
+			if err := needValue("a rate, e.g. 200k"); err != nil {
+				return nil, g, err
+			}
+			g.BWLimit = value
+		default:
+			return args, g, nil
+		}
+		args = args[1:]
+	}
+	return args, g, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractGlobalFlags(t *testing.T) {
+	for _, env := range []string{"DEBUG_HTTP", "VERBOSE", "PLAIN", "READ_ONLY", "PROFILE", "BWLIMIT"} {
+		t.Setenv("DROPBOX_APPENDER_"+env, "")
+	}
+	cases := []struct {
+		args []string
+		rest []string
+		want globalFlags
+	}{
+		{[]string{"today"}, []string{"today"}, globalFlags{}},
+		{[]string{"--debug-http", "-verbose", "today", "-summary"}, []string{"today", "-summary"},
+			globalFlags{DebugHTTP: "-", Verbose: true}},
+		{[]string{"-debug-http=/tmp/http.log", "--plain", "--read-only", "hello"}, []string{"hello"},
+			globalFlags{DebugHTTP: "/tmp/http.log", Plain: true, ReadOnly: true}},
+		{[]string{"-profile", "work", "--bwlimit=200k", "image", "a.png"}, []string{"image", "a.png"},
+			globalFlags{Profile: "work", BWLimit: "200k"}},
+		// After the subcommand name, or the default mode's first flag or
+		// word, they belong to the command.
+		{[]string{"append", "try", "-plain", "mode"}, []string{"append", "try", "-plain", "mode"}, globalFlags{}},
+		{[]string{"-no-timestamp", "-verbose", "x"}, []string{"-no-timestamp", "-verbose", "x"}, globalFlags{}},
+		{[]string{"--", "-plain"}, []string{"--", "-plain"}, globalFlags{}},
+	}
+	for _, c := range cases {
+		rest, got, err := extractGlobalFlags(c.args)
+		if err != nil || !reflect.DeepEqual(rest, c.rest) || got != c.want {
+			t.Errorf("extractGlobalFlags(%q) = %q, %+v, %v; want %q, %+v", c.args, rest, got, err, c.rest, c.want)
+		}
+	}
+
+	for _, args := range [][]string{{"-profile"}, {"--bwlimit"}} {
+		if _, _, err := extractGlobalFlags(args); err == nil {
+			t.Errorf("extractGlobalFlags(%q): expected an error for the missing value", args)
+		}
+	}
+
+	t.Setenv("DROPBOX_APPENDER_DEBUG_HTTP", "1")
+	t.Setenv("DROPBOX_APPENDER_PROFILE", "home")
+	if _, got, _ := extractGlobalFlags(nil); got.DebugHTTP != "-" || got.Profile != "home" {
+		t.Errorf("expected env vars to set defaults, got %+v", got)
+	}
+}
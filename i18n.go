@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// language is the catalog used by tr, or "" for English. It starts from the
+// locale environment and is overridden by the "language" config field.
+var language = localeLanguage()
+
+// catalogs maps a language to translations of user-facing messages. Each
+// key is the English message exactly as written at the call site, verbs and
+// trailing newline included, so untranslated messages fall back to English
+// and call sites stay readable. Translations must keep the same verbs in
+// the same order.
+var catalogs = map[string]map[string]string{
+	"de": {
+		"error: %v\n":                                          "Fehler: %v\n",
+		"error loading config: %v\n":                           "Fehler beim Laden der Konfiguration: %v\n",
+		"error saving config: %v\n":                            "Fehler beim Speichern der Konfiguration: %v\n",
+		"reading stdin: %v\n":                                  "Fehler beim Lesen der Standardeingabe: %v\n",
+		"app_key and app_secret required.":                     "app_key und app_secret werden benötigt.",
+		"1. Open this URL in your browser:":                    "1. Öffne diese Adresse im Browser:",
+		"2. Enter the authorization code: ":                    "2. Gib den Autorisierungscode ein: ",
+		"no code entered":                                      "kein Code eingegeben",
+		"\nAuthentication successful! Refresh token saved.":    "\nAnmeldung erfolgreich! Refresh-Token gespeichert.",
+		"Appended to %s\n":                                     "An %s angehängt\n",
+		"Queued for %s\n":                                      "Für %s vorgemerkt\n",
+		"error: queueing entry: %v\n":                          "Fehler beim Vormerken des Eintrags: %v\n",
+		"warning: your Dropbox is %.0f%% full (%s of %s)\n":    "Warnung: deine Dropbox ist zu %.0f%% voll (%s von %s)\n",
+		"Restore %s to revision %s from %s (%d bytes)? [y/N] ": "%s auf Version %s vom %s (%d Bytes) zurücksetzen? [y/N] ",
+		"Restored %s to revision %s from %s\n":                 "%s auf Version %s vom %s zurückgesetzt\n",
+		"Aborted.":                                             "Abgebrochen.",
+	},
+	"es": {
+		"error: %v\n":                                          "error: %v\n",
+		"error loading config: %v\n":                           "error al cargar la configuración: %v\n",
+		"error saving config: %v\n":                            "error al guardar la configuración: %v\n",
+		"reading stdin: %v\n":                                  "error al leer la entrada estándar: %v\n",
+		"app_key and app_secret required.":                     "se requieren app_key y app_secret.",
+		"1. Open this URL in your browser:":                    "1. Abre esta dirección en tu navegador:",
+		"2. Enter the authorization code: ":                    "2. Introduce el código de autorización: ",
+		"no code entered":                                      "no se introdujo ningún código",
+		"\nAuthentication successful! Refresh token saved.":    "\n¡Autenticación correcta! Token de actualización guardado.",
+		"Appended to %s\n":                                     "Añadido a %s\n",
+		"Queued for %s\n":                                      "En cola para %s\n",
+		"error: queueing entry: %v\n":                          "error al poner la entrada en cola: %v\n",
+		"warning: your Dropbox is %.0f%% full (%s of %s)\n":    "aviso: tu Dropbox está al %.0f%% (%s de %s)\n",
+		"Restore %s to revision %s from %s (%d bytes)? [y/N] ": "¿Restaurar %s a la revisión %s del %s (%d bytes)? [y/N] ",
+		"Restored %s to revision %s from %s\n":                 "%s restaurado a la revisión %s del %s\n",
+		"Aborted.":                                             "Cancelado.",
+	},
+}
+
+// tr returns the translation of msg for the current language, or msg itself
+// when there is none.
+func tr(msg string) string {
+	if t, ok := catalogs[language][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// setLanguage selects the catalog from a config value such as "de" or
+// "es_ES". An empty value keeps the locale from the environment.
+func setLanguage(lang string) {
+	if lang != "" {
+		language = normalizeLanguage(lang)
+	}
+}
+
+// localeLanguage returns the language of the first set locale variable, in
+// the order the C library consults them.
+func localeLanguage() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			return normalizeLanguage(v)
+		}
+	}
+	return ""
+}
+
+// normalizeLanguage reduces a locale such as "de_DE.UTF-8" to "de". The C
+// and POSIX locales mean English.
+func normalizeLanguage(locale string) string {
+	lang, _, _ := strings.Cut(locale, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	lang, _, _ = strings.Cut(strings.ReplaceAll(lang, "-", "_"), "_")
+	lang = strings.ToLower(lang)
+	if lang == "c" || lang == "posix" || lang == "en" {
+		return ""
+	}
+	return lang
+}
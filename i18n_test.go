@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"testing"
+)
+
+// TestMain pins the tests to English so expectations hold on machines with
+// a non-English locale.
+func TestMain(m *testing.M) {
+	language = ""
+	os.Exit(m.Run())
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	for locale, want := range map[string]string{
+		"de_DE.UTF-8":     "de",
+		"es-ES":           "es",
+		"fr_FR@euro":      "fr",
+		"DE":              "de",
+		"en_US.UTF-8":     "",
+		"C":               "",
+		"POSIX":           "",
+		"pt_BR.ISO8859-1": "pt",
+	} {
+		if got := normalizeLanguage(locale); got != want {
+			t.Errorf("normalizeLanguage(%q) = %q, want %q", locale, got, want)
+		}
+	}
+}
+
+func TestLocaleLanguage(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "es_ES.UTF-8")
+	t.Setenv("LANG", "de_DE.UTF-8")
+	if got := localeLanguage(); got != "es" {
+		t.Errorf("localeLanguage() = %q, want es (LC_MESSAGES before LANG)", got)
+	}
+}
+
+func TestTr(t *testing.T) {
+	t.Cleanup(func() { language = "" })
+
+	setLanguage("de")
+	if got := tr("Appended to %s\n"); got != "An %s angehängt\n" {
+		t.Errorf("tr = %q", got)
+	}
+	if got := tr("no translation for this"); got != "no translation for this" {
+		t.Errorf("untranslated message = %q, want it unchanged", got)
+	}
+	setLanguage("")
+	if language != "de" {
+		t.Errorf("empty config language changed language to %q", language)
+	}
+	setLanguage("xx")
+	if got := tr("Appended to %s\n"); got != "Appended to %s\n" {
+		t.Errorf("unknown language: tr = %q, want English", got)
+	}
+}
+
+// TestCatalogVerbs checks every translation keeps the verbs of its message,
+// in order, so arguments are never shuffled or dropped.
+func TestCatalogVerbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	for lang, catalog := range catalogs {
+		for msg, translated := range catalog {
+			want, got := verbs.FindAllString(msg, -1), verbs.FindAllString(translated, -1)
+			if len(want) != len(got) {
+				t.Errorf("%s: %q has verbs %v, want %v", lang, translated, got, want)
+				continue
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("%s: %q has verbs %v, want %v", lang, translated, got, want)
+					break
+				}
+			}
+		}
+	}
+}
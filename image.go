@@ -5,12 +5,27 @@ import (
 	"fmt"
 	"io"
 	"os/exec"
+	"path"
+	"strings"
 	"time"
 )
 
-// defaultImageFolder is the Dropbox folder where pasted images are stored.
+// defaultImageFolder is the Dropbox folder where pasted images are stored
+// with the default path template; see attachmentFolder.
 const defaultImageFolder = "/Notes/attachments"
 
+// attachmentFolder returns the folder attachments are stored in for notes
+// laid out by the path template tmpl: an attachments folder next to the
+// notes' root folder, e.g. /Notes/attachments for notes under
+// /Notes/Journal, so the attachments of every kind stay in the vault.
+func attachmentFolder(tmpl string) string {
+	root := templateRoot(tmpl)
+	if root == "" {
+		return "/attachments"
+	}
+	return strings.TrimSuffix(path.Dir(root), "/") + "/attachments"
+}
+
 // defaultImageMIME is the clipboard MIME type requested from wl-paste when no
 // -type flag is given. Wayland screenshots are conventionally PNG.
 const defaultImageMIME = "image/png"
@@ -46,12 +61,10 @@ func imageFileName(now time.Time) string {
 	return fmt.Sprintf("image-%s", now.Format("20060102-150405"))
 }
 
-// imageMarkdownLink returns a markdown image link from today's journal entry
-// (at /Notes/Journal/YYYY/MM/NoteYYYYMMDD.md) to the image stored at
-// /Notes/attachments/<name><ext>. The journal is three directories below
-// /Notes, so the link uses ../../../ to reach /Notes.
-func imageMarkdownLink(name, ext string) string {
-	return fmt.Sprintf("![%s](../../../attachments/%s%s)", name, name, ext)
+// imageMarkdownLink returns a markdown image link named name from the note
+// at notePath to the image stored at attPath.
+func imageMarkdownLink(notePath, attPath, name string) string {
+	return fmt.Sprintf("![%s](%s)", name, relativeLink(notePath, attPath))
 }
 
 // wlPasteReader reads image bytes from the Wayland clipboard via wl-paste.
@@ -72,17 +85,17 @@ func (wlPasteReader) ReadImage(mime string) ([]byte, error) {
 // image from the Wayland clipboard, uploads it to the attachments folder, and
 // appends a markdown image link to today's journal entry. It returns the
 // process exit code.
-func runImage(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+func runImage(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
 	fs := flag.NewFlagSet("image", flag.ContinueOnError)
 	fs.SetOutput(stderr)
 	name := fs.String("name", "", "filename (without extension) for the image; defaults to image-YYYYMMDD-HHMMSS")
-	folder := fs.String("folder", defaultImageFolder, "Dropbox folder for image attachments")
+	folder := fs.String("folder", "", "Dropbox folder for image attachments (default: attachments next to the notes' folder)")
 	mime := fs.String("type", defaultImageMIME, "clipboard image MIME type to paste")
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
 
-	return runImageWithReader(args, stdin, stdout, stderr, wlPasteReader{}, time.Now(), *name, *folder, *mime)
+	return runImageWithReader(args, stdin, stdout, stderr, wlPasteReader{}, clock.Now(), *name, *folder, *mime)
 }
 
 // runImageWithReader is the entry point that takes a clipboard reader, used by
@@ -105,19 +118,19 @@ func runImageWithReader(args []string, stdin io.Reader, stdout, stderr io.Writer
 		return 1
 	}
 
-	cfg, err := loadConfig(defaultConfigPath())
-	if err != nil {
-		fmt.Fprintf(stderr, "error loading config: %v\n", err)
+	cfg, client, ok := loadClient(stderr, true)
+	if !ok {
 		return 1
 	}
-	token, err := resolveToken(cfg)
+	opts, err := appendOptionsFromConfig(cfg)
 	if err != nil {
-		fmt.Fprintln(stderr, err)
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
 		return 1
 	}
 
+	client.Progress = stderr
 	return runImageWithClient(stderr,
-		&DropboxClient{Token: token}, now, data, name, folder, mime)
+		client, cfg, now, data, name, folder, mime, opts, cfg.Image)
 }
 
 // clipboardImageReader abstracts reading image bytes from the clipboard so the
@@ -128,29 +141,38 @@ type clipboardImageReader interface {
 
 // runImageWithClient is the testable core of the image subcommand. It uploads
 // the provided image bytes and appends a markdown image link to the journal for
-// the given time, using the provided client. name and folder may be empty to
-// use defaults; if name is empty it is derived from now.
-func runImageWithClient(stderr io.Writer, client *DropboxClient, now time.Time,
-	data []byte, name, folder, mime string) int {
+// the given time, at the path cfg's template gives, using the provided client. name and folder may be empty to
+// use defaults; if name is empty it is derived from now. images, when set,
+// shrinks the image before upload.
+func runImageWithClient(stderr io.Writer, client *DropboxClient, cfg *Config, now time.Time,
+	data []byte, name, folder, mime string, opts appendOptions, images *ImageConfig) int {
 
+	notePath, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
 	if name == "" {
 		name = imageFileName(now)
 	}
+	if folder == "" {
+		folder = attachmentFolder(cfg.PathTemplate)
+	}
 	ext := imageExtForMIME(mime)
 
 	attPath := imageAttachmentPath(folder, name, ext)
-	if err := client.UploadBytes(attPath, data); err != nil {
+	if err := uploadImage(client, attPath, data, images, stderr); err != nil {
 		fmt.Fprintf(stderr, "error uploading image: %v\n", err)
 		return 1
 	}
 
-	journalPath := resolvePath(now)
-	entry := formatEntry(now, imageMarkdownLink(name, ext), false)
-	if err := appendToJournal(client, journalPath, entry); err != nil {
+	entry := formatEntry(now, imageMarkdownLink(notePath, attPath, name), false)
+	notePath, err = appendToJournal(client, notePath, entry, opts)
+	if err != nil {
 		fmt.Fprintf(stderr, "error updating journal: %v\n", err)
 		return 1
 	}
 
-	fmt.Fprintf(stderr, "Saved %s and linked in %s\n", attPath, journalPath)
+	fmt.Fprintf(stderr, "Saved %s and linked in %s\n", attPath, notePath)
 	return 0
 }
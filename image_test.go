@@ -54,7 +54,7 @@ func TestImageFileName(t *testing.T) {
 }
 
 func TestImageMarkdownLink(t *testing.T) {
-	got := imageMarkdownLink("image-20250115-143045", ".png")
+	got := imageMarkdownLink("/Notes/Journal/2025/01/Note20250115.md", "/Notes/attachments/image-20250115-143045.png", "image-20250115-143045")
 	want := "![image-20250115-143045](../../../attachments/image-20250115-143045.png)"
 	if got != want {
 		t.Errorf("got %q, want %q", got, want)
@@ -118,9 +118,9 @@ func TestRunImageWithClient_NewJournal(t *testing.T) {
 	imagePayload := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 0xFF, 0xFE, 0x00, 0x01}
 	var stderr bytes.Buffer
 	code := runImageWithClient(&stderr,
-		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{},
 		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
-		imagePayload, "my-image", "", defaultImageMIME)
+		imagePayload, "my-image", "", defaultImageMIME, appendOptions{}, nil)
 	if code != 0 {
 		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
 	}
@@ -164,9 +164,9 @@ func TestRunImageWithClient_ExistingJournal(t *testing.T) {
 
 	var stderr bytes.Buffer
 	code := runImageWithClient(&stderr,
-		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{},
 		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
-		[]byte("fakepng"), "img2", "", defaultImageMIME)
+		[]byte("fakepng"), "img2", "", defaultImageMIME, appendOptions{}, nil)
 	if code != 0 {
 		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
 	}
@@ -193,9 +193,9 @@ func TestRunImageWithClient_DefaultName(t *testing.T) {
 
 	var stderr bytes.Buffer
 	code := runImageWithClient(&stderr,
-		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{},
 		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
-		[]byte("fakepng"), "", "", defaultImageMIME)
+		[]byte("fakepng"), "", "", defaultImageMIME, appendOptions{}, nil)
 	if code != 0 {
 		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
 	}
@@ -225,9 +225,9 @@ func TestRunImageWithClient_JpegType(t *testing.T) {
 
 	var stderr bytes.Buffer
 	code := runImageWithClient(&stderr,
-		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{},
 		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
-		[]byte("fakejpeg"), "photo", "", "image/jpeg")
+		[]byte("fakejpeg"), "photo", "", "image/jpeg", appendOptions{}, nil)
 	if code != 0 {
 		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
 	}
@@ -238,3 +238,41 @@ func TestRunImageWithClient_JpegType(t *testing.T) {
 		t.Errorf("expected .jpg journal link, got: %q", uploadedJournal)
 	}
 }
+
+// TestRunImageWithClient_PathTemplate verifies the image goes to the
+// attachments folder next to the notes the path template gives, and the link
+// to the note, relative to it.
+func TestRunImageWithClient_PathTemplate(t *testing.T) {
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	var stderr bytes.Buffer
+	code := runImageWithClient(&stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{PathTemplate: "/Daily/{{.Date}}.md"},
+		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
+		[]byte("fakepng"), "img", "", defaultImageMIME, appendOptions{}, nil)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if want := "### 14:30:45\n![img](../attachments/img.png)\n"; files["/Daily/20250115.md"] != want {
+		t.Errorf("got %v, want %q in /Daily/20250115.md", files, want)
+	}
+	if files["/attachments/img.png"] != "fakepng" {
+		t.Errorf("expected the image in /attachments, got %v", files)
+	}
+}
+
+func TestAttachmentFolder(t *testing.T) {
+	for tmpl, want := range map[string]string{
+		"":                                    defaultImageFolder,
+		defaultPathTemplate:                   defaultImageFolder,
+		"/Vault/Daily/{{.Year}}/{{.Date}}.md": "/Vault/attachments",
+		"/Daily/{{.Date}}.md":                 "/attachments",
+		"/{{.Date}}.md":                       "/attachments",
+	} {
+		if got := attachmentFolder(tmpl); got != want {
+			t.Errorf("attachmentFolder(%q) = %q, want %q", tmpl, got, want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+)
+
+// defaultImageQuality is the JPEG quality images are recompressed at when
+// image.quality isn't set.
+const defaultImageQuality = 85
+
+// ImageConfig shrinks image attachments before they are uploaded, to keep
+// the vault small.
+type ImageConfig struct {
+	// MaxDimension scales images down so neither side is longer; 0 keeps
+	// their size.
+	MaxDimension int `json:"max_dimension,omitempty"`
+	// Quality is the JPEG quality (1-100) JPEGs are recompressed at.
+	Quality int `json:"quality,omitempty"`
+	// KeepOriginals also uploads the unchanged image to an originals/
+	// folder next to the attachment.
+	KeepOriginals bool `json:"keep_originals,omitempty"`
+}
+
+// validate checks the image settings.
+func (c *ImageConfig) validate() error {
+	if c.MaxDimension < 0 {
+		return fmt.Errorf("invalid max_dimension %d", c.MaxDimension)
+	}
+	if c.Quality < 0 || c.Quality > 100 {
+		return fmt.Errorf("invalid quality %d: expected 1-100", c.Quality)
+	}
+	return nil
+}
+
+// shrinkImage scales data down to fit c.MaxDimension and recompresses it in
+// its own format: JPEGs at c.Quality, PNGs at the best compression. It
+// returns data unchanged, and false, for formats it can't re-encode (GIF,
+// which may be animated, WebP, BMP) and when the result isn't smaller.
+func shrinkImage(data []byte, c ImageConfig) ([]byte, bool, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return data, false, nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("decoding image: %w", err)
+	}
+	img := toRGBA(src)
+	if format == "jpeg" {
+		// Re-encoding drops the EXIF orientation, so apply it to the pixels.
+		img = orient(img, jpegOrientation(data))
+	}
+	if b := img.Bounds(); c.MaxDimension > 0 && max(b.Dx(), b.Dy()) > c.MaxDimension {
+		scale := float64(c.MaxDimension) / float64(max(b.Dx(), b.Dy()))
+		img = resizeBox(img, max(int(float64(b.Dx())*scale+0.5), 1), max(int(float64(b.Dy())*scale+0.5), 1))
+	}
+
+	var out bytes.Buffer
+	if format == "jpeg" {
+		q := c.Quality
+		if q == 0 {
+			q = defaultImageQuality
+		}
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: q})
+	} else {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&out, img)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("encoding image: %w", err)
+	}
+	if out.Len() >= len(data) {
+		return data, false, nil
+	}
+	return out.Bytes(), true, nil
+}
+
+// toRGBA returns img as an *image.RGBA with its origin at 0,0.
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// resizeBox scales src to w×h by averaging the source pixels each
+// destination pixel covers, which is sharp enough for shrinking.
+func resizeBox(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for i := range sum {
+						sum[i] += int(row[sx*4+i])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			d := dst.Pix[y*dst.Stride+x*4:]
+			for i := range sum {
+				d[i] = uint8((sum[i] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, 1 when it
+// has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker, size := data[i+1], int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || i+2+size > len(data) {
+			break // image data starts; EXIF comes before it
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(seg) > 14 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of EXIF
+// TIFF data.
+func tiffOrientation(t []byte) int {
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(t[4:]))
+	if ifd+2 > len(t) {
+		return 1
+	}
+	n := int(order.Uint16(t[ifd:]))
+	for e := ifd + 2; e+12 <= len(t) && n > 0; e, n = e+12, n-1 {
+		if order.Uint16(t[e:]) == 0x0112 {
+			if o := int(order.Uint16(t[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient turns src upright according to an EXIF orientation.
+func orient(src *image.RGBA, o int) *image.RGBA {
+	if o <= 1 || o > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w // 5-8 swap the axes
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:][:4], src.Pix[y*src.Stride+x*4:][:4])
+		}
+	}
+	return dst
+}
+
+// originalPath returns where the unchanged copy of the attachment at p is
+// kept: an originals/ folder next to it.
+func originalPath(p string) string {
+	return path.Join(path.Dir(p), "originals", path.Base(p))
+}
+
+// uploadImage uploads an image attachment to attPath, shrunk as configured
+// by c (nil uploads it as it is). An image that can't be shrunk is uploaded
+// unchanged, with a warning.
+func uploadImage(client *DropboxClient, attPath string, data []byte, c *ImageConfig, stderr io.Writer) error {
+	if c == nil {
+		return client.UploadBytes(attPath, data)
+	}
+	shrunk, changed, err := shrinkImage(data, *c)
+	if err != nil {
+		fmt.Fprintf(stderr, "warning: uploading %s unchanged: %v\n", attPath, err)
+		return client.UploadBytes(attPath, data)
+	}
+	if changed && c.KeepOriginals {
+		if err := client.UploadBytes(originalPath(attPath), data); err != nil {
+			return fmt.Errorf("uploading the original: %w", err)
+		}
+	}
+	return client.UploadBytes(attPath, shrunk)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testImage returns a w×h image, red on its left half and blue on its right.
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= w/2 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestShrinkImage(t *testing.T) {
+	var src bytes.Buffer
+	(&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&src, testImage(400, 200))
+
+	out, changed, err := shrinkImage(src.Bytes(), ImageConfig{MaxDimension: 100})
+	if err != nil || !changed {
+		t.Fatalf("changed %v, err %v", changed, err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(out))
+	if err != nil || format != "png" {
+		t.Fatalf("format %q, err %v", format, err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Errorf("got %v, want 100x50", b)
+	}
+	if r, _, bl, _ := img.At(10, 10).RGBA(); r>>8 != 255 || bl != 0 {
+		t.Errorf("colors should survive scaling, got %v", img.At(10, 10))
+	}
+
+	// Nothing to gain: a small, already compressed image is kept as it is.
+	var small bytes.Buffer
+	png.Encode(&small, testImage(8, 8))
+	if out, changed, _ := shrinkImage(small.Bytes(), ImageConfig{MaxDimension: 100}); changed || !bytes.Equal(out, small.Bytes()) {
+		t.Error("expected the image to be unchanged")
+	}
+	if out, changed, err := shrinkImage([]byte("GIF89a..."), ImageConfig{MaxDimension: 10}); changed || err != nil || string(out) != "GIF89a..." {
+		t.Error("unknown formats should be passed through")
+	}
+}
+
+func TestShrinkImage_JPEGQuality(t *testing.T) {
+	var src bytes.Buffer
+	jpeg.Encode(&src, testImage(64, 64), &jpeg.Options{Quality: 100})
+	out, changed, err := shrinkImage(src.Bytes(), ImageConfig{Quality: 30})
+	if err != nil || !changed || len(out) >= src.Len() {
+		t.Errorf("changed %v, err %v, %d → %d bytes", changed, err, src.Len(), len(out))
+	}
+}
+
+// exifJPEG returns a JPEG of img carrying an EXIF orientation tag.
+func exifJPEG(img image.Image, orientation byte) []byte {
+	var enc bytes.Buffer
+	jpeg.Encode(&enc, img, nil)
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00")
+	tiff = append(tiff, orientation, 0, 0, 0, 0, 0, 0)
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	seg := append([]byte{0xFF, 0xE1, byte((len(app1) + 2) >> 8), byte(len(app1) + 2)}, app1...)
+	return append(append([]byte{0xFF, 0xD8}, seg...), enc.Bytes()[2:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	data := exifJPEG(testImage(40, 20), 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("orientation %d, want 6", got)
+	}
+	out, _, err := shrinkImage(data, ImageConfig{MaxDimension: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, _ := image.Decode(bytes.NewReader(out))
+	// Rotated a quarter turn clockwise: 20 wide, 40 high, red on top.
+	if b := img.Bounds(); b.Dx() != 10 || b.Dy() != 20 {
+		t.Errorf("got %v, want 10x20", b)
+	}
+	if r, _, _, _ := img.At(5, 2).RGBA(); r>>8 < 200 {
+		t.Errorf("top should be red, got %v", img.At(5, 2))
+	}
+	if got := jpegOrientation([]byte("not a jpeg")); got != 1 {
+		t.Errorf("got %d", got)
+	}
+}
+
+func TestUploadImage_KeepOriginals(t *testing.T) {
+	uploads := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		body.ReadFrom(r.Body)
+		arg := r.Header.Get("Dropbox-API-Arg")
+		path := arg[strings.Index(arg, `"path":"`)+8:]
+		uploads[path[:strings.Index(path, `"`)]] = body.Len()
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var src bytes.Buffer
+	(&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&src, testImage(300, 300))
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	c := &ImageConfig{MaxDimension: 50, KeepOriginals: true}
+	if err := uploadImage(client, "/Notes/attachments/a.png", src.Bytes(), c, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if uploads["/Notes/attachments/originals/a.png"] != src.Len() || uploads["/Notes/attachments/a.png"] >= src.Len() {
+		t.Errorf("uploads: %v (original %d bytes)", uploads, src.Len())
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// importFormats are the export formats `import` understands.
+var importFormats = []string{"dayone-json", "drafts-json", "apple-notes"}
+
+// dayOneEscape matches the backslash escapes Day One adds to punctuation in
+// its markdown export, e.g. "Done\!".
+var dayOneEscape = regexp.MustCompile(`\\([.!\-+()#>])`)
+
+// dayOnePhoto matches Day One's references to photos, which aren't part of
+// the JSON export.
+var dayOnePhoto = regexp.MustCompile(`!\[\]\(dayone-moment:/+[^)]*\)`)
+
+// htmlBreak and htmlTag turn the HTML of exported Apple Notes into text.
+var (
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(?:p|div|li|h[1-6])>`)
+	htmlTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// importLocation returns the named time zone, or loc when it is empty or
+// unknown.
+func importLocation(name string, loc *time.Location) *time.Location {
+	if name == "" {
+		return loc
+	}
+	if l, err := time.LoadLocation(name); err == nil {
+		return l
+	}
+	return loc
+}
+
+// withTags adds tags to imported text as #hashtags on their own line.
+func withTags(text string, tags []string) string {
+	var hashtags []string
+	for _, t := range tags {
+		if t = strings.Join(strings.Fields(t), "-"); t != "" {
+			hashtags = append(hashtags, "#"+strings.TrimPrefix(t, "#"))
+		}
+	}
+	if len(hashtags) == 0 {
+		return text
+	}
+	return text + "\n\n" + strings.Join(hashtags, " ")
+}
+
+// parseDayOne reads a Day One JSON export (Journal.json). Entries keep their
+// creation time in the time zone they were written in.
+func parseDayOne(data []byte, loc *time.Location) ([]datedEntry, error) {
+	var export struct {
+		Entries []struct {
+			CreationDate time.Time `json:"creationDate"`
+			TimeZone     string    `json:"timeZone"`
+			Text         string    `json:"text"`
+			Tags         []string  `json:"tags"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("reading Day One export: %w", err)
+	}
+	var entries []datedEntry
+	for _, e := range export.Entries {
+		text := dayOnePhoto.ReplaceAllString(e.Text, "[photo]")
+		text = strings.TrimSpace(dayOneEscape.ReplaceAllString(text, "$1"))
+		if text == "" {
+			continue
+		}
+		entries = append(entries, datedEntry{
+			When:    e.CreationDate.In(importLocation(e.TimeZone, loc)),
+			HasTime: true,
+			Text:    withTags(text, e.Tags),
+		})
+	}
+	return entries, nil
+}
+
+// parseDrafts reads a Drafts JSON export, an array of drafts.
+func parseDrafts(data []byte, loc *time.Location) ([]datedEntry, error) {
+	var drafts []struct {
+		Content   string    `json:"content"`
+		CreatedAt time.Time `json:"created_at"`
+		Tags      []string  `json:"tags"`
+	}
+	if err := json.Unmarshal(data, &drafts); err != nil {
+		return nil, fmt.Errorf("reading Drafts export: %w", err)
+	}
+	var entries []datedEntry
+	for _, d := range drafts {
+		if text := strings.TrimSpace(d.Content); text != "" {
+			entries = append(entries, datedEntry{When: d.CreatedAt.In(loc), HasTime: true, Text: withTags(text, d.Tags)})
+		}
+	}
+	return entries, nil
+}
+
+// readAppleNotes reads Apple Notes exported as files, one note per .md,
+// .txt or .html file in dir (or the single file dir names), as exporter
+// apps write them. The file name is the title and the modification time,
+// which the exporters set to the note's, is the entry's time.
+func readAppleNotes(dir string) ([]datedEntry, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".md", ".txt", ".html", ".htm":
+			if !d.IsDir() {
+				files = append(files, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var entries []datedEntry
+	for _, p := range files {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		text := toLF(string(data))
+		if ext := strings.ToLower(filepath.Ext(p)); ext == ".html" || ext == ".htm" {
+			text = html.UnescapeString(htmlTag.ReplaceAllString(htmlBreak.ReplaceAllString(text, "$0\n"), ""))
+		}
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		title := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+		if !strings.HasPrefix(text, title) {
+			text = "**" + title + "**\n\n" + text
+		}
+		entries = append(entries, datedEntry{When: info.ModTime().Local(), HasTime: true, Text: text})
+	}
+	return entries, nil
+}
+
+// readImport reads the entries of an export in format from src, a file,
+// "-" for stdin, or for apple-notes a folder.
+func readImport(format, src string, stdin io.Reader) ([]datedEntry, error) {
+	if format == "apple-notes" {
+		return readAppleNotes(src)
+	}
+	var data []byte
+	var err error
+	if src == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if format == "dayone-json" {
+		return parseDayOne(data, time.Local)
+	}
+	return parseDrafts(data, time.Local)
+}
+
+// runImport implements the `dropbox-appender import` subcommand, which
+// migrates the entries of another journaling app's export into the daily
+// notes, each at its original date and time. It returns the process exit
+// code.
+func runImport(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "", "export format: "+strings.Join(importFormats, ", "))
+	dates := addDateFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "list the entries and the notes they would go to without writing")
+	pathTemplate := fs.String("path-template", "", "Go template for the target paths (overrides config)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(stderr, "usage: dropbox-appender import -format %s [-from DATE] [-to DATE] [-dry-run] <file|folder|->\n", strings.Join(importFormats, "|"))
+		return 2
+	}
+	known := false
+	for _, f := range importFormats {
+		known = known || f == *format
+	}
+	if !known {
+		fmt.Fprintf(stderr, "invalid -format %q: expected one of %s\n", *format, strings.Join(importFormats, ", "))
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	all, err := readImport(*format, fs.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	var entries []datedEntry
+	for _, e := range all {
+		if dates.match(e.When.Format("2006-01-02")) {
+			entries = append(entries, e)
+		}
+	}
+	// Exports aren't necessarily in order; notes should be.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].When.Before(entries[j].When) })
+	if len(entries) == 0 {
+		fmt.Fprintf(stderr, "error: no entries to import from %s\n", fs.Arg(0))
+		return 1
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if *dryRun {
+		for _, e := range entries {
+			p, err := journalPath(cfg, *pathTemplate, e.When)
+			if err != nil {
+				fmt.Fprintf(stderr, tr("error: %v\n"), err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "%s  %s  %s\n", e.When.Format("2006-01-02 15:04:05"), p, firstLine(e.Text))
+		}
+		return 0
+	}
+	if refuseReadOnly(stderr, "import") {
+		return 1
+	}
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	return appendByDate(stdout, stderr, client, cfg, *pathTemplate, entries, opts, func(text string) string { return text })
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDayOne(t *testing.T) {
+	data := []byte(`{"metadata": {"version": "1.0"}, "entries": [
+		{"uuid": "A", "creationDate": "2025-01-15T22:30:45Z", "timeZone": "America/New_York",
+		 "text": "Shipped it\\!\n\n![](dayone-moment://F00D)", "tags": ["work", "big news"]},
+		{"uuid": "B", "creationDate": "2025-01-16T08:00:00Z", "timeZone": "Nowhere/Unknown", "text": "  "}
+	]}`)
+	entries, err := parseDayOne(data, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("empty entries should be skipped, got %d", len(entries))
+	}
+	e := entries[0]
+	if got := e.When.Format("2006-01-02 15:04:05 MST"); got != "2025-01-15 17:30:45 EST" {
+		t.Errorf("time %s, want the entry's own time zone", got)
+	}
+	if want := "Shipped it!\n\n[photo]\n\n#work #big-news"; e.Text != want || !e.HasTime {
+		t.Errorf("text %q, want %q", e.Text, want)
+	}
+}
+
+func TestParseDrafts(t *testing.T) {
+	data := []byte(`[{"uuid": "1", "content": "Call Sam\n", "created_at": "2025-01-15T09:00:00Z", "tags": ["todo"]}]`)
+	entries, err := parseDrafts(data, time.UTC)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("got %+v, %v", entries, err)
+	}
+	if entries[0].Text != "Call Sam\n\n#todo" || !entries[0].When.Equal(time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %+v", entries[0])
+	}
+	if _, err := parseDrafts([]byte(`{"entries": []}`), time.UTC); err == nil {
+		t.Error("expected an error for the wrong format")
+	}
+}
+
+func TestReadAppleNotes(t *testing.T) {
+	dir := t.TempDir()
+	when := time.Date(2025, 1, 15, 9, 30, 0, 0, time.Local)
+	for name, content := range map[string]string{
+		"Groceries.html": "<div><b>Groceries</b></div><div>eggs &amp; milk<br></div>",
+		"Ideas.md":       "a garden shed",
+		"photo.jpg":      "not a note",
+	} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0o600)
+		os.Chtimes(p, when, when)
+	}
+	entries, err := readAppleNotes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	if entries[0].Text != "Groceries\neggs & milk" || !entries[0].When.Equal(when) {
+		t.Errorf("html note: got %+v", entries[0])
+	}
+	if entries[1].Text != "**Ideas**\n\na garden shed" {
+		t.Errorf("markdown note: got %q", entries[1].Text)
+	}
+}
+
+func TestRunImport_DryRun(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cfgPath := filepath.Join(home, ".config", "dropbox-appender", "config.json")
+	os.MkdirAll(filepath.Dir(cfgPath), 0o700)
+	os.WriteFile(cfgPath, []byte(`{"path_template": "/Daily/{{.Date}}.md"}`), 0o600)
+
+	export := `[{"content": "second", "created_at": "2025-01-16T09:00:00Z"},
+		{"content": "first", "created_at": "2025-01-15T09:00:00Z"},
+		{"content": "too late", "created_at": "2025-02-01T09:00:00Z"}]`
+	var stdout, stderr bytes.Buffer
+	args := []string{"-format", "drafts-json", "-to", "2025-01-31", "-dry-run", "-"}
+	if code := runImport(args, strings.NewReader(export), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "first") || !strings.Contains(lines[1], "/Daily/") {
+		t.Errorf("got %q", stdout.String())
+	}
+
+	if code := runImport([]string{"-format", "evernote", "x"}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("unknown format: exit %d", code)
+	}
+}
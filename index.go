@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// indexEntry is one journal entry as stored in the local index.
+type indexEntry struct {
+	Date   string   `json:"date"` // 2006-01-02
+	Time   string   `json:"time"`
+	Number int      `json:"number,omitempty"`
+	ID     string   `json:"id,omitempty"` // anchor ID of numbered entries
+	Author string   `json:"author,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Text   string   `json:"text"`
+	Path   string   `json:"path"`
+	Rev    string   `json:"rev,omitempty"`
+}
+
+// indexedNote is the index record for one note file.
+type indexedNote struct {
+	Rev     string       `json:"rev,omitempty"`
+	Entries []indexEntry `json:"entries"`
+}
+
+// localIndex is the on-disk index of every known note, keyed by path. It
+// lets search, stats and tasks answer without network access.
+//
+// The index is saved in full only by reindex and when its log is
+// compacted. Appends record the note they wrote as a line of the log next
+// to it (see indexNote), which costs the size of that note rather than of
+// the whole index; loading replays the log over the saved index.
+type localIndex struct {
+	Notes map[string]*indexedNote `json:"notes"`
+}
+
+// indexLogRecord is a line of the index log: the new record of one note.
+type indexLogRecord struct {
+	Path string       `json:"path"`
+	Note *indexedNote `json:"note"`
+}
+
+// maxIndexLog is the size the index log may grow to before indexNote
+// folds it into the index. It is only compacted once it is also larger
+// than the index, so the cost of rewriting the index is spread over at
+// least as many bytes of appends.
+var maxIndexLog int64 = 1 << 20
+
+// defaultDataDir returns the directory for persistent local data, e.g.
+// ~/.local/share/dropbox-appender, honouring XDG_DATA_HOME.
+func defaultDataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "dropbox-appender")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "dropbox-appender")
+}
+
+// defaultIndexPath returns the location of the local entry index.
+func defaultIndexPath() string {
+	return filepath.Join(defaultDataDir(), "index.json")
+}
+
+// indexLogPath returns the log of notes written since the index at p was
+// last saved.
+func indexLogPath(p string) string {
+	return p + ".log"
+}
+
+// loadIndex reads the index at p and replays its log. A missing index is
+// empty.
+func loadIndex(p string) (*localIndex, error) {
+	idx := &localIndex{Notes: map[string]*indexedNote{}}
+	data, err := os.ReadFile(p)
+	if err == nil {
+		if err := json.Unmarshal(data, idx); err != nil {
+			return nil, fmt.Errorf("parsing index %s (run: dropbox-appender reindex): %w", p, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if idx.Notes == nil {
+		idx.Notes = map[string]*indexedNote{}
+	}
+	log, err := os.ReadFile(indexLogPath(p))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(log), "\n") {
+		var rec indexLogRecord
+		// A line cut short by a crash is skipped; the note is indexed
+		// again when next written or reindexed.
+		if json.Unmarshal([]byte(line), &rec) == nil && rec.Path != "" && rec.Note != nil {
+			idx.Notes[rec.Path] = rec.Note
+		}
+	}
+	return idx, nil
+}
+
+// save writes the index to p atomically, then drops the log it includes.
+func (idx *localIndex) save(p string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(p, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Remove(indexLogPath(p)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// update replaces the index record for the note at notePath with the entries
+// parsed from content.
+func (idx *localIndex) update(notePath, content, rev string, date time.Time) {
+	idx.Notes[notePath] = newIndexedNote(notePath, content, rev, date)
+}
+
+// newIndexedNote parses the entries of the note at notePath, written on
+// date, into its index record.
+func newIndexedNote(notePath, content, rev string, date time.Time) *indexedNote {
+	note := &indexedNote{Rev: rev}
+	for _, e := range parseEntries(content) {
+		note.Entries = append(note.Entries, indexEntry{
+			Date:   date.Format("2006-01-02"),
+			Time:   e.Time,
+			Number: e.Number,
+			ID:     e.ID,
+			Author: e.Author,
+			Tags:   extractTags(e.Text),
+			Text:   e.Text,
+			Path:   notePath,
+			Rev:    rev,
+		})
+	}
+	return note
+}
+
+// entries returns every indexed entry ordered by date and time.
+func (idx *localIndex) entries() []indexEntry {
+	var all []indexEntry
+	for _, n := range idx.Notes {
+		all = append(all, n.Entries...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Date != all[j].Date {
+			return all[i].Date < all[j].Date
+		}
+		if all[i].Time != all[j].Time {
+			return all[i].Time < all[j].Time
+		}
+		return all[i].Path < all[j].Path
+	})
+	return all
+}
+
+// indexNote records a just-written note, the one for date, in the index at
+// indexPath by appending it to the index log, which is folded into the
+// index once it grows past maxIndexLog. Indexing is best effort: the
+// append already succeeded, so failures only warn.
+func indexNote(indexPath, notePath, content, rev string, date time.Time, stderr io.Writer) {
+	if err := logIndexedNote(indexPath, notePath, newIndexedNote(notePath, content, rev, date)); err != nil {
+		fmt.Fprintf(stderr, "warning: updating local index: %v\n", err)
+	}
+}
+
+func logIndexedNote(indexPath, notePath string, note *indexedNote) error {
+	line, err := json.Marshal(indexLogRecord{Path: notePath, Note: note})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(indexLogPath(indexPath), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	logInfo, err := os.Stat(indexLogPath(indexPath))
+	if err != nil || logInfo.Size() <= maxIndexLog {
+		return err
+	}
+	if info, err := os.Stat(indexPath); err == nil && logInfo.Size() <= info.Size() {
+		return nil
+	}
+	idx, err := loadIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	return idx.save(indexPath)
+}
+
+// templateRoot returns the fixed folder a path template writes under, i.e.
+// everything before the first templated path segment.
+func templateRoot(tmpl string) string {
+	if tmpl == "" {
+		tmpl = defaultPathTemplate
+	}
+	if i := strings.Index(tmpl, "{{"); i >= 0 {
+		tmpl = tmpl[:i]
+	}
+	root := tmpl[:strings.LastIndex(tmpl, "/")+1]
+	if root == "/" {
+		return ""
+	}
+	return strings.TrimSuffix(root, "/")
+}
+
+// runReindex implements the `dropbox-appender reindex` subcommand: it walks
+// the journal folder and refreshes the local index, downloading only notes
+// whose revision changed. It returns the process exit code.
+func runReindex(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	full := fs.Bool("full", false, "re-download every note, even if unchanged")
+	noCache := fs.Bool("no-cache", false, "list the folder afresh instead of using the API cache")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, client, ok := loadClient(stderr, false)
+	if !ok {
+		return 1
+	}
+	if *full || *noCache {
+		client.Cache = nil
+	}
+
+	return runReindexWithClient(stdout, stderr, client, cfg.PathTemplate, defaultIndexPath(), *full)
+}
+
+// runReindexWithClient is the testable core of the reindex subcommand. The
+// notes are those under the root of the path template tmpl that it renders
+// for some day; notes that no longer exist remotely are dropped from the
+// index.
+func runReindexWithClient(stdout, stderr io.Writer, client *DropboxClient,
+	tmpl, indexPath string, full bool) int {
+
+	root := templateRoot(tmpl)
+	idx, err := loadIndex(indexPath)
+	if err != nil && !full {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if idx == nil || full {
+		idx = &localIndex{Notes: map[string]*indexedNote{}}
+	}
+
+	files, err := client.ListFolder(root, true, 0)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: listing %s: %v\n", root, err)
+		return 1
+	}
+
+	seen := map[string]bool{}
+	var stale []FolderEntry
+	var staleBytes int64
+	for _, f := range files {
+		if f.Tag != "file" || !strings.HasSuffix(strings.ToLower(f.Name), ".md") {
+			continue
+		}
+		if _, ok := templateDate(tmpl, f.PathDisplay); !ok {
+			continue
+		}
+		seen[f.PathDisplay] = true
+		if note, ok := idx.Notes[f.PathDisplay]; ok && note.Rev == f.Rev && f.Rev != "" {
+			continue
+		}
+		stale = append(stale, f)
+		staleBytes += f.Size
+	}
+
+	p := newProgress(stderr, "Indexing", len(stale), staleBytes)
+	for _, f := range stale {
+		content, err := client.Download(f.PathDisplay)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: downloading %s: %v\n", f.PathDisplay, err)
+			return 1
+		}
+		date, _ := templateDate(tmpl, f.PathDisplay)
+		idx.update(f.PathDisplay, content, f.Rev, date)
+		p.add(1, f.Size)
+	}
+	p.done()
+	fetched := len(stale)
+	for p := range idx.Notes {
+		if !seen[p] {
+			delete(idx.Notes, p)
+		}
+	}
+
+	if err := idx.save(indexPath); err != nil {
+		fmt.Fprintf(stderr, "error: saving index: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Indexed %d notes (%d updated), %d entries\n", len(idx.Notes), fetched, len(idx.entries()))
+	return 0
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateRoot(t *testing.T) {
+	cases := map[string]string{
+		"":                                    "/Notes/Journal",
+		"/Daily/{{.Year}}-{{.Month}}.md":      "/Daily",
+		"/Notes/Log {{.Date}}.md":             "/Notes",
+		"/{{.Year}}/{{.Date}}.md":             "",
+		"/Apps/Obsidian/Vault/D/{{.Date}}.md": "/Apps/Obsidian/Vault/D",
+	}
+	for in, want := range cases {
+		if got := templateRoot(in); got != want {
+			t.Errorf("templateRoot(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIndexUpdateAndRoundTrip(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	idx, err := loadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	date := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	idx.update("/n/Note20250115.md", "### 09:00:00\nstandup #work\n\n### 14:30:45\nlunch\n", "rev1", date)
+	if err := idx.save(indexPath); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	loaded, err := loadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	entries := loaded.entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Date != "2025-01-15" || e.Time != "09:00:00" || e.Text != "standup #work" ||
+		e.Path != "/n/Note20250115.md" || e.Rev != "rev1" || len(e.Tags) != 1 || e.Tags[0] != "work" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestIndexNote_AppendsToLog(t *testing.T) {
+	defer func(n int64) { maxIndexLog = n }(maxIndexLog)
+	maxIndexLog = 1 << 20
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	date := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	idx := &localIndex{Notes: map[string]*indexedNote{}}
+	idx.update("/n/Note20250114.md", "### 08:00:00\nyesterday\n", "r0", date.AddDate(0, 0, -1))
+	idx.save(indexPath)
+	saved, _ := os.ReadFile(indexPath)
+
+	indexNote(indexPath, "/n/Note20250115.md", "### 09:00:00\nfirst\n", "r1", date, io.Discard)
+	indexNote(indexPath, "/n/Note20250115.md", "### 09:00:00\nfirst\n\n### 10:00:00\nsecond\n", "r2", date, io.Discard)
+	if data, _ := os.ReadFile(indexPath); string(data) != string(saved) {
+		t.Error("appends should not rewrite the index")
+	}
+	loaded, err := loadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if n := loaded.Notes["/n/Note20250115.md"]; n == nil || n.Rev != "r2" || len(n.Entries) != 2 {
+		t.Errorf("log not replayed: %+v", n)
+	}
+	if len(loaded.entries()) != 3 {
+		t.Errorf("expected 3 entries, got %+v", loaded.entries())
+	}
+
+	// Once the log outgrows the limit and the index, it is folded in.
+	maxIndexLog = 10
+	indexNote(indexPath, "/n/Note20250116.md", strings.Repeat("### 11:00:00\nlong entry\n\n", 20), "r3", date.AddDate(0, 0, 1), io.Discard)
+	if _, err := os.Stat(indexLogPath(indexPath)); !os.IsNotExist(err) {
+		t.Errorf("expected the log to be compacted, got %v", err)
+	}
+	if loaded, _ := loadIndex(indexPath); len(loaded.Notes) != 3 {
+		t.Errorf("compacted index lost notes: %+v", loaded.Notes)
+	}
+}
+
+func TestLoadIndex_SkipsTornLogLine(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	indexNote(indexPath, "/n/Note20250115.md", "### 09:00:00\nfirst\n", "r1", time.Now(), io.Discard)
+	f, _ := os.OpenFile(indexLogPath(indexPath), os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"path": "/n/Note2025`)
+	f.Close()
+	idx, err := loadIndex(indexPath)
+	if err != nil || len(idx.Notes) != 1 {
+		t.Errorf("got %+v, %v", idx, err)
+	}
+}
+
+func TestAppendToJournal_UpdatesIndex(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 09:00:00\nmorning\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nafternoon #home\n", appendOptions{IndexPath: indexPath}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	idx, _ := loadIndex(indexPath)
+	entries := idx.entries()
+	if len(entries) != 2 || entries[1].Text != "afternoon #home" {
+		t.Errorf("index not updated: %+v", entries)
+	}
+}
+
+func TestRunReindexWithClient_Incremental(t *testing.T) {
+	downloads := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/files/list_folder":
+			w.Write([]byte(`{"entries": [
+				{".tag": "file", "name": "Note20250114.md", "path_display": "/Notes/Journal/2025/01/Note20250114.md", "rev": "a1"},
+				{".tag": "file", "name": "Note20250115.md", "path_display": "/Notes/Journal/2025/01/Note20250115.md", "rev": "b2"},
+				{".tag": "file", "name": "Ideas.md", "path_display": "/Notes/Journal/Ideas.md", "rev": "c3"}
+			], "has_more": false}`))
+		case "/2/files/download":
+			var arg struct {
+				Path string `json:"path"`
+			}
+			json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+			downloads[arg.Path]++
+			w.Write([]byte("### 10:00:00\nnote for " + arg.Path + "\n"))
+		}
+	}))
+	defer server.Close()
+
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	idx := &localIndex{Notes: map[string]*indexedNote{}}
+	idx.update("/Notes/Journal/2025/01/Note20250114.md", "### 08:00:00\ncached\n", "a1",
+		time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC))
+	idx.update("/Notes/Journal/2024/12/Note20241231.md", "### 08:00:00\ndeleted remotely\n", "z9",
+		time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+	idx.save(indexPath)
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if code := runReindexWithClient(io.Discard, io.Discard, client, "", indexPath, false); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	if downloads["/Notes/Journal/2025/01/Note20250114.md"] != 0 {
+		t.Error("unchanged note should not be downloaded")
+	}
+	if downloads["/Notes/Journal/2025/01/Note20250115.md"] != 1 {
+		t.Error("changed note should be downloaded")
+	}
+	loaded, _ := loadIndex(indexPath)
+	if _, ok := loaded.Notes["/Notes/Journal/2024/12/Note20241231.md"]; ok {
+		t.Error("notes missing remotely should be dropped")
+	}
+	entries := loaded.entries()
+	if len(entries) != 2 || entries[0].Text != "cached" || !strings.Contains(entries[1].Text, "Note20250115") {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
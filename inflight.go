@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// inflightOp records an append between its download and upload, so a run
+// that dies in between (a crash, kill -9, a lost laptop battery) doesn't
+// lose the entries: the next run finds the record and finishes the job.
+type inflightOp struct {
+	Path      string    `json:"path"`    // note the entries were written for
+	Entries   []string  `json:"entries"` // as passed to appendEntries
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+	// Set once the new content is ready to upload: the note part written
+	// to, the hash of the downloaded content and of the content being
+	// uploaded, and the entries as they appear in it.
+	Part       string   `json:"part,omitempty"`
+	BaseHash   string   `json:"base_hash,omitempty"`
+	ResultHash string   `json:"result_hash,omitempty"`
+	Written    []string `json:"written,omitempty"`
+
+	file string
+}
+
+// defaultInflightDir returns the directory holding in-flight records.
+func defaultInflightDir() string {
+	return filepath.Join(defaultDataDir(), "inflight")
+}
+
+// beginInflight records that entries are being appended to path. With an
+// empty dir nothing is recorded.
+func beginInflight(dir, path string, entries []string) *inflightOp {
+	op := &inflightOp{Path: path, Entries: entries, PID: os.Getpid(), StartedAt: time.Now()}
+	if dir == "" {
+		return op
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return op
+	}
+	op.file = filepath.Join(dir, fmt.Sprintf("%020d-%d.json", op.StartedAt.UnixNano(), op.PID))
+	op.save()
+	return op
+}
+
+// save writes the record. A record that can't be written only costs the
+// crash protection, so errors are ignored.
+func (op *inflightOp) save() {
+	if op.file == "" {
+		return
+	}
+	if data, err := json.Marshal(op); err == nil {
+		writeFileAtomic(op.file, data, 0600)
+	}
+}
+
+// uploading records the content about to be uploaded to part.
+func (op *inflightOp) uploading(part, base, result string, written []string) {
+	op.Part, op.BaseHash, op.ResultHash, op.Written = part, checksum([]byte(base)), checksum([]byte(result)), written
+	op.save()
+}
+
+// finish removes the record once the append has succeeded or failed in a
+// way the caller reports.
+func (op *inflightOp) finish() {
+	if op.file != "" {
+		os.Remove(op.file)
+	}
+}
+
+// processAlive reports whether the process pid is running, so records of
+// appends still in progress in another run are left alone.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// uploadCompleted reports whether the interrupted upload of op reached
+// Dropbox: the note is exactly what was being uploaded, or (after later
+// appends) still contains every entry that was being written.
+func (op *inflightOp) uploadCompleted(content string) bool {
+	if op.ResultHash == "" {
+		return false // it died before uploading
+	}
+	if checksum([]byte(content)) == op.ResultHash {
+		return true
+	}
+	lf := toLF(content)
+	for _, w := range op.Written {
+		if !strings.Contains(lf, strings.TrimSpace(w)) {
+			return false
+		}
+	}
+	return len(op.Written) > 0
+}
+
+// recoverInflight finishes the appends that earlier runs left in flight:
+// those whose upload landed are dropped, the others are appended again.
+// Records of runs that are still going are skipped.
+func recoverInflight(client *DropboxClient, dir string, opts appendOptions, stderr io.Writer) {
+	if dir == "" {
+		return
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	opts.InflightDir = "" // the records are removed below once done
+	for _, de := range des {
+		if de.IsDir() || filepath.Ext(de.Name()) != ".json" {
+			continue
+		}
+		file := filepath.Join(dir, de.Name())
+		var op inflightOp
+		data, err := os.ReadFile(file)
+		if err == nil {
+			err = json.Unmarshal(data, &op)
+		}
+		if err != nil || op.Path == "" {
+			fmt.Fprintf(stderr, "warning: skipping in-flight record %s: %v\n", de.Name(), err)
+			continue
+		}
+		if op.PID != os.Getpid() && processAlive(op.PID) {
+			continue
+		}
+
+		if op.Part != "" {
+			content, err := client.Download(op.Part)
+			if err != nil {
+				fmt.Fprintf(stderr, "warning: checking interrupted append to %s: %v\n", op.Part, err)
+				continue
+			}
+			if op.uploadCompleted(content) {
+				os.Remove(file)
+				continue
+			}
+		}
+		written, err := appendEntries(client, op.Path, op.Entries, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "warning: completing interrupted append to %s: %v\n", op.Path, err)
+			continue
+		}
+		os.Remove(file)
+		fmt.Fprintf(stderr, "Completed an interrupted append of %d entries to %s\n", len(op.Entries), written)
+	}
+}
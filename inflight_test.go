@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeInflight stores op in dir as if a run had died holding it.
+func writeInflight(t *testing.T, dir string, op inflightOp, name string) {
+	t.Helper()
+	op.file = filepath.Join(dir, name)
+	os.MkdirAll(dir, 0700)
+	op.save()
+}
+
+func TestAppendEntries_InflightRecordRemoved(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	if _, err := appendToJournal(client, "/Journal/20250115.md", "### 09:00:00\nhello\n", appendOptions{InflightDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if des, _ := os.ReadDir(dir); len(des) != 0 {
+		t.Errorf("%d in-flight records left after a successful append", len(des))
+	}
+}
+
+func TestRecoverInflight(t *testing.T) {
+	dir := t.TempDir()
+	landed := "### 09:00:00\nuploaded before the crash\n"
+	files := map[string]string{"/Journal/20250115.md": landed}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	const deadPID = 1 << 30
+
+	// Died after the upload landed: nothing to do.
+	done := inflightOp{Path: "/Journal/20250115.md", Entries: []string{landed}, PID: deadPID}
+	done.Part, done.ResultHash, done.Written = done.Path, checksum([]byte(landed)), []string{landed}
+	writeInflight(t, dir, done, "1.json")
+	// Died before uploading: the entry is appended now.
+	writeInflight(t, dir, inflightOp{Path: "/Journal/20250115.md", Entries: []string{"### 10:00:00\nlost entry\n"}, PID: deadPID}, "2.json")
+	// Another run is still working on this one.
+	writeInflight(t, dir, inflightOp{Path: "/Journal/20250115.md", Entries: []string{"### 11:00:00\nbusy\n"}, PID: os.Getppid()}, "3.json")
+
+	var stderr bytes.Buffer
+	recoverInflight(client, dir, appendOptions{}, &stderr)
+
+	want := landed + "\n### 10:00:00\nlost entry\n"
+	if got := files["/Journal/20250115.md"]; got != want {
+		t.Errorf("note = %q, want %q", got, want)
+	}
+	des, _ := os.ReadDir(dir)
+	if len(des) != 1 || des[0].Name() != "3.json" {
+		t.Errorf("records left: %v", des)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// journalDoc is a note split into its entries without losing anything:
+// String returns exactly the content it was parsed from, whatever the
+// template, separators, line endings or hand edits. Features that rewrite
+// entries in place edit the blocks and write String back, leaving every
+// other byte of the note as it was.
+type journalDoc struct {
+	// Preamble is everything before the first entry header: front matter,
+	// a title, or the whole note when it has no entries.
+	Preamble string
+	Blocks   []*journalBlock
+}
+
+// journalBlock is one entry of a journalDoc. Header, Anchor, Body and
+// Trailer are consecutive raw slices of the note; the parsed fields of
+// journalEntry describe them.
+type journalBlock struct {
+	journalEntry
+	Header string // the ### line, with its line ending
+	Anchor string // the <a id> line of numbered entries, if any
+	// Body runs from after the header (and anchor) to the start of the
+	// separator before the next entry, or to the end of the note.
+	Body string
+	// Trailer is the separator after the body: blank lines and --- rules.
+	Trailer string
+}
+
+// parseJournal splits content into its preamble and entries. Entry headers
+// inside front matter or fenced code blocks don't start entries.
+func parseJournal(content string) *journalDoc {
+	doc := &journalDoc{}
+	starts := entryStarts(content)
+	if len(starts) == 0 {
+		doc.Preamble = content
+		return doc
+	}
+	doc.Preamble = content[:starts[0]]
+	for i, start := range starts {
+		end := len(content)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		doc.Blocks = append(doc.Blocks, parseBlock(content[start:end]))
+	}
+	return doc
+}
+
+// entryStarts returns the offsets of the entry header lines in content.
+func entryStarts(content string) []int {
+	var starts []int
+	off := max(frontMatterEnd(content), 0)
+	fence := ""
+	for _, line := range strings.SplitAfter(content[off:], "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			for len(fence) < len(trimmed) && trimmed[len(fence)] == fence[0] {
+				fence += fence[:1]
+			}
+		case entryHeaderPattern.MatchString(line):
+			starts = append(starts, off)
+		}
+		off += len(line)
+	}
+	return starts
+}
+
+// parseBlock parses the raw text of one entry, starting at its header.
+func parseBlock(raw string) *journalBlock {
+	b := &journalBlock{}
+	b.Header, raw = cutLine(raw)
+	m := entryHeaderPattern.FindStringSubmatch(b.Header)
+	b.Time, b.Author = m[2], m[3]
+	b.Number, _ = strconv.Atoi(m[1])
+
+	// The anchor follows the header, possibly after blank lines.
+	lead := len(raw) - len(strings.TrimLeft(raw, "\r\n"))
+	if line, _ := cutLine(raw[lead:]); entryAnchorPattern.MatchString(strings.TrimRight(line, "\r\n")) {
+		b.Anchor = raw[:lead] + line
+		b.ID = entryAnchorPattern.FindStringSubmatch(strings.TrimRight(line, "\r\n"))[1]
+		raw = raw[lead+len(line):]
+	}
+
+	sep := separatorStart(raw)
+	b.Body, b.Trailer = raw[:sep], raw[sep:]
+	b.Text = strings.TrimSpace(b.Body)
+	return b
+}
+
+// separatorStart returns where the trailing blank lines and --- rules of
+// raw begin. A --- right under text is a setext heading, not a rule, and
+// stays in the body.
+func separatorStart(raw string) int {
+	lines := strings.SplitAfter(raw, "\n")
+	end := len(raw)
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		isRule := line == "---" && i > 0 && strings.TrimSpace(lines[i-1]) == ""
+		if line != "" && !isRule {
+			break
+		}
+		end -= len(lines[i])
+	}
+	return end
+}
+
+// cutLine splits s after its first line ending.
+func cutLine(s string) (line, rest string) {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i+1], s[i+1:]
+	}
+	return s, ""
+}
+
+// String returns the note, including any edits made to its blocks.
+func (d *journalDoc) String() string {
+	var b strings.Builder
+	b.WriteString(d.Preamble)
+	for _, block := range d.Blocks {
+		b.WriteString(block.String())
+	}
+	return b.String()
+}
+
+// entries returns the parsed entries.
+func (d *journalDoc) entries() []journalEntry {
+	entries := make([]journalEntry, len(d.Blocks))
+	for i, b := range d.Blocks {
+		entries[i] = b.journalEntry
+	}
+	return entries
+}
+
+// String returns the raw text of the entry.
+func (b *journalBlock) String() string {
+	return b.Header + b.Anchor + b.Body + b.Trailer
+}
+
+// setText replaces the entry's text, keeping its header, anchor, separator
+// and line endings.
+func (b *journalBlock) setText(text string) {
+	eol := detectLineEnding(b.Header)
+	lead := b.Body[:len(b.Body)-len(strings.TrimLeft(b.Body, " \t\r\n"))]
+	body := strings.TrimSpace(toLF(text))
+	if strings.HasSuffix(b.Body, "\n") || b.Trailer != "" {
+		body += "\n"
+	}
+	b.Body = lead + withLineEnding(body, eol)
+	b.Text = strings.TrimSpace(text)
+}
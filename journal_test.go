@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+)
+
+// journalFixtures are notes as the supported templates and hand edits
+// leave them.
+var journalFixtures = map[string]string{
+	"empty":         "",
+	"no entries":    "# Wednesday\nJust a title.\n",
+	"plain":         "### 09:00:00\nmorning\n\n### 14:30:45\nafternoon\n",
+	"no final EOL":  "### 09:00:00\nmorning",
+	"crlf":          "### 09:00:00\r\nmorning\r\n\r\n### 10:00\r\nshort time\r\n",
+	"numbered":      "### 1. 09:00:00\n<a id=\"note20250115-1\"></a>\nfirst\n\n### 2. 10:00:00\n\n<a id=\"note20250115-2\"></a>\nsecond\n",
+	"attributed":    "### 09:00:00 — alice\nfrom alice\n\n### 3. 10:00:00 — bob\n<a id=\"x-3\"></a>\nfrom bob\n",
+	"rule":          "### 09:00:00\none\n\n---\n\n### 10:00:00\ntwo\n",
+	"front matter":  "---\ntitle: \"### 09:00:00\"\n---\n### 10:00:00\nafter front matter\n",
+	"fences":        "### 09:00:00\n````md\n### 10:00:00\n```\nnot an entry\n````\n\n### 11:00:00\nreal\n",
+	"setext":        "### 09:00:00\nHeading\n---\n",
+	"footer":        "### 09:00:00\nentry\n\n<!-- footer -->\n",
+	"blank entries": "### 09:00:00\n### 10:00:00\n\n\n",
+}
+
+func TestParseJournal_RoundTrip(t *testing.T) {
+	for name, content := range journalFixtures {
+		if got := parseJournal(content).String(); got != content {
+			t.Errorf("%s: round trip changed the note:\n%q\nwant\n%q", name, got, content)
+		}
+	}
+}
+
+func TestParseJournal(t *testing.T) {
+	tests := []struct {
+		fixture string
+		texts   []string
+	}{
+		{"rule", []string{"one", "two"}},
+		{"front matter", []string{"after front matter"}},
+		{"fences", []string{"````md\n### 10:00:00\n```\nnot an entry\n````", "real"}},
+		{"setext", []string{"Heading\n---"}},
+		{"crlf", []string{"morning", "short time"}},
+		{"blank entries", []string{"", ""}},
+	}
+	for _, tt := range tests {
+		doc := parseJournal(journalFixtures[tt.fixture])
+		if len(doc.Blocks) != len(tt.texts) {
+			t.Errorf("%s: %d entries, want %d", tt.fixture, len(doc.Blocks), len(tt.texts))
+			continue
+		}
+		for i, b := range doc.Blocks {
+			if b.Text != tt.texts[i] {
+				t.Errorf("%s: entry %d text = %q, want %q", tt.fixture, i, b.Text, tt.texts[i])
+			}
+		}
+	}
+
+	doc := parseJournal(journalFixtures["numbered"])
+	if b := doc.Blocks[1]; b.Number != 2 || b.ID != "note20250115-2" || b.Time != "10:00:00" || b.Text != "second" {
+		t.Errorf("numbered entry = %+v", b.journalEntry)
+	}
+	doc = parseJournal(journalFixtures["attributed"])
+	if b := doc.Blocks[1]; b.Number != 3 || b.Author != "bob" || b.ID != "x-3" {
+		t.Errorf("attributed entry = %+v", b.journalEntry)
+	}
+}
+
+func TestJournalBlock_SetText(t *testing.T) {
+	tests := []struct {
+		fixture string
+		entry   int
+		want    string
+	}{
+		{"rule", 0, "### 09:00:00\nedited\nline\n\n---\n\n### 10:00:00\ntwo\n"},
+		{"numbered", 1, "### 1. 09:00:00\n<a id=\"note20250115-1\"></a>\nfirst\n\n### 2. 10:00:00\n\n<a id=\"note20250115-2\"></a>\nedited\nline\n"},
+		{"crlf", 0, "### 09:00:00\r\nedited\r\nline\r\n\r\n### 10:00\r\nshort time\r\n"},
+		{"no final EOL", 0, "### 09:00:00\nedited\nline"},
+	}
+	for _, tt := range tests {
+		doc := parseJournal(journalFixtures[tt.fixture])
+		doc.Blocks[tt.entry].setText("  edited\nline\n")
+		if got := doc.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.fixture, got, tt.want)
+		}
+	}
+}
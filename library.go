@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// appendText appends text to today's note the way the default command does:
+// shortcodes are expanded, a timestamp header added and the configured append
+// options applied. It is the entry point for callers other than the CLI, such
+// as the c-shared library. It returns the path written.
+func appendText(cfg *Config, text string, now time.Time) (string, error) {
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		return "", err
+	}
+	return appendTextWithClient(client, cfg, text, now)
+}
+
+// appendTextWithClient is the testable core of appendText.
+func appendTextWithClient(client *DropboxClient, cfg *Config, text string, now time.Time) (string, error) {
+	if text == "" {
+		return "", fmt.Errorf("no input provided")
+	}
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		return "", err
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	entry := formatEntry(now, expandShortcodes(text, cfg.Shortcodes), false)
+	return appendToJournal(client, path, entry, opts)
+}
+
+// configFromJSON parses a config document passed by a library caller and
+// finishes it like loadConfig does. An empty document loads the user's
+// config file instead.
+func configFromJSON(configJSON string) (*Config, error) {
+	if configJSON == "" {
+		return loadConfig(defaultConfigPath(), io.Discard)
+	}
+	var cfg Config
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if err := finishConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
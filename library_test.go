@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigFromJSON(t *testing.T) {
+	cfg, err := configFromJSON(`{"path_template": "/Daily/{{.Date}}.md", "shortcodes": {"ok": "✅"}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PathTemplate != "/Daily/{{.Date}}.md" || cfg.Shortcodes["ok"] != "✅" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if _, err := configFromJSON("{"); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestConfigFromJSON_FinishesLikeLoadConfig(t *testing.T) {
+	t.Setenv("DROPBOX_REFRESH_TOKEN", "from-env")
+	selectedProfile = "work"
+	defer func() { selectedProfile = "" }()
+
+	cfg, err := configFromJSON(`{"refresh_token": "from-json", "profiles": {"work": {"path_template": "/Work/{{.Date}}.md"}}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RefreshToken != "from-env" || cfg.PathTemplate != "/Work/{{.Date}}.md" {
+		t.Errorf("env override or profile not applied: %+v", cfg)
+	}
+	if _, err := configFromJSON(`{"bwlimit": "fast"}`); err == nil {
+		t.Error("expected the HTTP settings to be checked")
+	}
+}
+
+func TestAppendTextWithClient(t *testing.T) {
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	cfg := &Config{PathTemplate: "/Daily/{{.Date}}.md", Shortcodes: map[string]string{"ok": "✅"}}
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+	written, err := appendTextWithClient(client, cfg, "shipped :ok:", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written != "/Daily/20250115.md" || files[written] != "### 14:30:45\nshipped ✅\n" {
+		t.Errorf("unexpected result %s: %q", written, files[written])
+	}
+	if _, err := appendTextWithClient(client, cfg, "", now); err == nil {
+		t.Error("expected error for empty text")
+	}
+}
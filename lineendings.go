@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lineEnding returns the line ending to write a note with for a
+// line_endings policy: "lf", "crlf", or "auto"/"" to keep the style of the
+// existing content.
+func lineEnding(policy, existing string) (string, error) {
+	switch policy {
+	case "", "auto":
+		return detectLineEnding(existing), nil
+	case "lf":
+		return "\n", nil
+	case "crlf":
+		return "\r\n", nil
+	}
+	return "", fmt.Errorf("invalid line_endings %q: expected auto, lf or crlf", policy)
+}
+
+// detectLineEnding reports the style content mostly uses: "\r\n" when more
+// lines end in CRLF than in a bare LF (e.g. a note edited on Windows), else
+// "\n".
+func detectLineEnding(content string) string {
+	crlf := strings.Count(content, "\r\n")
+	if crlf > strings.Count(content, "\n")-crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// toLF converts CRLF line endings to LF, the form notes are edited in.
+func toLF(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// withLineEnding converts LF-only text to use eol.
+func withLineEnding(s, eol string) string {
+	if eol == "\n" {
+		return s
+	}
+	return strings.ReplaceAll(s, "\n", eol)
+}
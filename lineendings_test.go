@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestLineEnding(t *testing.T) {
+	cases := []struct {
+		policy, existing, want string
+	}{
+		{"", "a\nb\n", "\n"},
+		{"auto", "a\r\nb\r\n", "\r\n"},
+		{"auto", "a\r\nb\nc\n", "\n"},
+		{"auto", "", "\n"},
+		{"lf", "a\r\nb\r\n", "\n"},
+		{"crlf", "a\nb\n", "\r\n"},
+	}
+	for _, c := range cases {
+		got, err := lineEnding(c.policy, c.existing)
+		if err != nil || got != c.want {
+			t.Errorf("lineEnding(%q, %q) = %q, %v; want %q", c.policy, c.existing, got, err, c.want)
+		}
+	}
+	if _, err := lineEnding("windows", ""); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestAppendContent_KeepsCRLF(t *testing.T) {
+	got := appendContent("### 09:00:00\r\nmorning\r\n", "### 10:00:00\nlater\n")
+	if want := "### 09:00:00\r\nmorning\r\n\r\n### 10:00:00\r\nlater\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAppendToJournal_LineEndings(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	cases := []struct {
+		policy, existing, want string
+	}{
+		{"", "### 09:00:00\r\nmorning\r\n", "### 09:00:00\r\nmorning\r\n\r\n### 14:30:45\r\nafternoon\r\n"},
+		{"lf", "### 09:00:00\r\nmorning\r\n", "### 09:00:00\nmorning\n\n### 14:30:45\nafternoon\n"},
+		{"crlf", "### 09:00:00\nmorning\n", "### 09:00:00\r\nmorning\r\n\r\n### 14:30:45\r\nafternoon\r\n"},
+	}
+	for _, c := range cases {
+		files := map[string]string{path: c.existing}
+		server := rolloverServer(files)
+		client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+		_, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", appendOptions{LineEndings: c.policy})
+		server.Close()
+		if err != nil {
+			t.Fatalf("policy %q: %v", c.policy, err)
+		}
+		if files[path] != c.want {
+			t.Errorf("policy %q: got %q, want %q", c.policy, files[path], c.want)
+		}
+	}
+}
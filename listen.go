@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// tailnetRanges are the addresses Tailscale assigns to the devices of a
+// tailnet: IPv4 from the CGNAT range and IPv6 from Tailscale's ULA prefix.
+// Carriers use the CGNAT range too, so only addresses from tailnetAddrs are
+// checked against them.
+var tailnetRanges = []*net.IPNet{
+	mustCIDR("100.64.0.0/10"),
+	mustCIDR("fd7a:115c:a1e0::/48"),
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// listenAddr listens on addr as `serve -addr` accepts it: host:port for
+// TCP, unix:/path for a unix domain socket only the user can connect to, or
+// tailscale:port for the machine's tailnet address, which only devices on
+// the tailnet can reach.
+func listenAddr(addr string) (net.Listener, error) {
+	if p, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return listenUnix(p)
+	}
+	if port, ok := strings.CutPrefix(addr, "tailscale:"); ok {
+		addrs, err := tailnetAddrs()
+		if err != nil {
+			return nil, err
+		}
+		ip, err := tailnetIP(addrs)
+		if err != nil {
+			return nil, err
+		}
+		return net.Listen("tcp", net.JoinHostPort(ip.String(), port))
+	}
+	return net.Listen("tcp", addr)
+}
+
+// listenUnix listens on a unix domain socket at p, replacing a stale socket
+// left by a process that didn't shut down cleanly. The socket is created
+// under a 0177 umask, so it is 0600 from the start and other users can't
+// connect, not even in the moment before a chmod.
+func listenUnix(p string) (net.Listener, error) {
+	if info, err := os.Lstat(p); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a socket", p)
+		}
+		if conn, err := net.Dial("unix", p); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", p)
+		}
+		os.Remove(p)
+	}
+	var ln net.Listener
+	err := withUmask(0177, func() (err error) {
+		ln, err = net.Listen("unix", p)
+		return err
+	})
+	return ln, err
+}
+
+// tailnetAddrs returns the machine's tailnet addresses. It asks the
+// tailscale CLI first and otherwise takes the addresses of Tailscale's
+// interface (tailscale0 on Linux, a utun interface on macOS), so an address
+// from a carrier's CGNAT range on another interface isn't mistaken for one.
+func tailnetAddrs() ([]net.Addr, error) {
+	var addrs []net.Addr
+	for _, l := range commandLines("tailscale", "ip") {
+		if ip := net.ParseIP(l); ip != nil {
+			addrs = append(addrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || !tailnetInterface(iface.Name) {
+			continue
+		}
+		a, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, a...)
+	}
+	return addrs, nil
+}
+
+// tailnetInterface reports whether name is one Tailscale uses. utun
+// interfaces also serve other VPNs on macOS, which is why tailnetIP still
+// checks the address ranges.
+func tailnetInterface(name string) bool {
+	return strings.HasPrefix(name, "tailscale") || strings.HasPrefix(name, "utun")
+}
+
+// tailnetIP returns the tailnet address among addrs, preferring IPv4.
+func tailnetIP(addrs []net.Addr) (net.IP, error) {
+	var v6 net.IP
+	for _, a := range addrs {
+		n, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		for _, r := range tailnetRanges {
+			if !r.Contains(n.IP) {
+				continue
+			}
+			if n.IP.To4() != nil {
+				return n.IP, nil
+			}
+			if v6 == nil {
+				v6 = n.IP
+			}
+		}
+	}
+	if v6 != nil {
+		return v6, nil
+	}
+	return nil, errors.New("no Tailscale address found; is tailscaled running and logged in?")
+}
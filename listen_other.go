@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// withUmask runs f. Systems without a umask, such as Windows, don't control
+// access to unix sockets by file mode.
+func withUmask(mask int, f func() error) error {
+	return f()
+}
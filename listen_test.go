@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenAddr_Unix(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "serve.sock")
+	ln, err := listenAddr("unix:" + sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	if info, err := os.Stat(sock); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a 0600 socket, got %v %v", info.Mode(), err)
+	}
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "ok") }))
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) { return net.Dial("unix", sock) },
+	}}
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("body = %q", body)
+	}
+
+	// A socket in use isn't taken over.
+	if _, err := listenAddr("unix:" + sock); err == nil {
+		t.Error("expected an error for a socket in use")
+	}
+}
+
+func TestListenAddr_UnixReplacesStaleSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "serve.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	ln, err = listenAddr("unix:" + sock)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	ln.Close()
+
+	file := filepath.Join(t.TempDir(), "notes.md")
+	os.WriteFile(file, []byte("keep"), 0600)
+	if _, err := listenAddr("unix:" + file); err == nil {
+		t.Error("expected an error for a path that isn't a socket")
+	}
+}
+
+func TestTailnetIP(t *testing.T) {
+	addr := func(s string) net.Addr {
+		ip, n, _ := net.ParseCIDR(s)
+		n.IP = ip
+		return n
+	}
+	ip, err := tailnetIP([]net.Addr{addr("192.168.1.5/24"), addr("fd7a:115c:a1e0::1/128"), addr("100.101.102.103/32")})
+	if err != nil || ip.String() != "100.101.102.103" {
+		t.Errorf("got %v %v, want the tailnet IPv4 address", ip, err)
+	}
+	ip, err = tailnetIP([]net.Addr{addr("10.0.0.2/8"), addr("fd7a:115c:a1e0::1/128")})
+	if err != nil || ip.String() != "fd7a:115c:a1e0::1" {
+		t.Errorf("got %v %v, want the tailnet IPv6 address", ip, err)
+	}
+	if _, err := tailnetIP([]net.Addr{addr("10.0.0.2/8")}); err == nil {
+		t.Error("expected an error without a tailnet address")
+	}
+}
+
+func TestTailnetInterface(t *testing.T) {
+	for name, want := range map[string]bool{"tailscale0": true, "utun3": true, "eth0": false, "wwan0": false, "en0": false} {
+		if got := tailnetInterface(name); got != want {
+			t.Errorf("tailnetInterface(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package main
+
+import (
+	"sync"
+	"syscall"
+)
+
+// umaskMu serializes withUmask, as the umask is shared by the whole process.
+var umaskMu sync.Mutex
+
+// withUmask runs f with the process umask set to mask, so the files f
+// creates never have wider permissions, not even briefly.
+func withUmask(mask int, f func() error) error {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	old := syscall.Umask(mask)
+	defer syscall.Umask(old)
+	return f()
+}
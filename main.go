@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"crypto/rand"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -27,15 +29,71 @@ func formatEntry(now time.Time, text string, noTimestamp bool) string {
 	return fmt.Sprintf("### %s\n%s\n", now.Format("15:04:05"), text)
 }
 
-// readInput reads from remaining CLI args first, then stdin.
-func readInput(args []string) (string, error) {
+// defaultStdinTimeout is how long the default mode waits for input to start
+// on a stdin that is neither a pipe, a file nor a terminal, such as the
+// socket some GUI launchers leave open without ever writing to it. Pipes
+// and files are waited on, so a slow pipeline isn't cut off; launchers that
+// leave an idle pipe opt in with -stdin-timeout.
+const defaultStdinTimeout = 5 * time.Second
+
+// autoStdinTimeout returns the timeout for stdin when -stdin-timeout isn't
+// given: none for pipes, files and terminals, whose input is expected, and
+// defaultStdinTimeout for anything else.
+func autoStdinTimeout(stdin io.Reader) time.Duration {
+	f, ok := stdin.(*os.File)
+	if !ok {
+		return defaultStdinTimeout
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return defaultStdinTimeout
+	}
+	switch mode := stat.Mode(); {
+	case mode&os.ModeNamedPipe != 0, mode.IsRegular(), mode&os.ModeCharDevice != 0:
+		return 0
+	}
+	return defaultStdinTimeout
+}
+
+// inputOptions control where readInputWith looks for the entry text.
+type inputOptions struct {
+	// Stdin reads stdin even when it is a terminal, waiting for EOF (-stdin,
+	// or a lone "-" argument).
+	Stdin bool
+	// Timeout gives up on stdin when no data arrives within it; 0 waits.
+	Timeout time.Duration
+}
+
+// readInput reads from remaining CLI args first, then stdin. An interactive
+// terminal on stdin is treated as no input rather than waited on.
+func readInput(args []string, stdin io.Reader) (string, error) {
+	return readInputWith(args, stdin, inputOptions{})
+}
+
+// readInputWith is readInput with control over stdin: see inputOptions.
+// Once piped input has started it is read to EOF, however long that takes.
+func readInputWith(args []string, stdin io.Reader, opts inputOptions) (string, error) {
+	if len(args) == 1 && args[0] == "-" {
+		args, opts.Stdin = nil, true
+	}
 	if len(args) > 0 {
 		return strings.Join(args, " "), nil
 	}
 
-	stat, _ := os.Stdin.Stat()
-	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		data, err := io.ReadAll(os.Stdin)
+	interactive := false
+	if f, ok := stdin.(*os.File); ok && !opts.Stdin {
+		stat, err := f.Stat()
+		interactive = err == nil && stat.Mode()&os.ModeCharDevice != 0
+	}
+	if !interactive {
+		timeout := opts.Timeout
+		if opts.Stdin {
+			timeout = 0
+		}
+		data, err := readWithTimeout(stdin, timeout)
+		if errors.Is(err, errNoInput) {
+			return "", fmt.Errorf("no input provided: nothing arrived on stdin within %v; pass text as argument, or -stdin to wait for it", timeout)
+		}
 		if err != nil {
 			return "", fmt.Errorf("reading stdin: %w", err)
 		}
@@ -48,12 +106,67 @@ func readInput(args []string) (string, error) {
 	return "", fmt.Errorf("no input provided: pass text as argument or via stdin")
 }
 
-// appendContent combines existing file content with the new entry.
-func appendContent(existing string, entry string) string {
-	if existing == "" {
-		return entry
+// readNullDelimited reads stdin to EOF and splits it on NUL bytes, as
+// written by find -print0 or printf '%s\0'. Blank entries are dropped.
+func readNullDelimited(stdin io.Reader) ([]string, error) {
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("reading stdin: %w", err)
+	}
+	var entries []string
+	for _, part := range strings.Split(string(data), "\x00") {
+		if text := strings.TrimSpace(part); text != "" {
+			entries = append(entries, text)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no input provided: no NUL-delimited entries on stdin")
 	}
-	return existing + "\n" + entry
+	return entries, nil
+}
+
+// errNoInput is returned by readWithTimeout when no data arrived in time.
+var errNoInput = errors.New("no input")
+
+// readWithTimeout reads r to EOF, giving up with errNoInput if the first
+// data (or EOF) doesn't arrive within timeout. A timeout of 0 waits. The
+// reader goroutine of an abandoned read stays blocked until the process
+// exits.
+func readWithTimeout(r io.Reader, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		return io.ReadAll(r)
+	}
+	type chunk struct {
+		data []byte
+		err  error
+	}
+	first := make(chan chunk, 1)
+	go func() {
+		buf := make([]byte, 32*1024)
+		n, err := r.Read(buf)
+		first <- chunk{buf[:n], err}
+	}()
+	select {
+	case c := <-first:
+		if c.err == io.EOF {
+			return c.data, nil
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+		rest, err := io.ReadAll(r)
+		return append(c.data, rest...), err
+	case <-time.After(timeout):
+		return nil, errNoInput
+	}
+}
+
+// appendContent combines existing file content with the new entry,
+// separated by a blank line. The result keeps the line-ending style of
+// existing, so a CRLF note doesn't end up with mixed line endings.
+func appendContent(existing string, entry string) string {
+	eol := detectLineEnding(existing)
+	return withLineEnding(appendWithSeparator(toLF(existing), toLF(entry), "\n"), eol)
 }
 
 // resolveToken gets an access token using the priority chain:
@@ -66,7 +179,7 @@ func resolveToken(cfg *Config) (string, error) {
 	}
 
 	if cfg.RefreshToken != "" && cfg.AppKey != "" && cfg.AppSecret != "" {
-		token, err := refreshAccessToken(defaultTokenURL, cfg.AppKey, cfg.AppSecret, cfg.RefreshToken)
+		token, err := refreshToken(cfg, defaultTokenURL)
 		if err != nil {
 			return "", fmt.Errorf("refresh token invalid, run: dropbox-appender auth\n  (%w)", err)
 		}
@@ -76,107 +189,799 @@ func resolveToken(cfg *Config) (string, error) {
 	return "", fmt.Errorf("no authentication configured, run: dropbox-appender auth")
 }
 
-func runAuth(configPath string) {
-	cfg, err := loadConfig(configPath)
+// newClient builds a DropboxClient for token using the retry policy and
+// download limit from cfg.
+func newClient(cfg *Config, token string) (*DropboxClient, error) {
+	policy, err := retryPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := &DropboxClient{Token: token, Retry: policy, Endpoints: cfg.Endpoints,
+		Notify: cfg.Notify, NotifyPaths: cfg.NotifyPaths}
+	if cfg.MaxDownloadSize != "" {
+		if client.MaxDownload, err = parseSize(cfg.MaxDownloadSize); err != nil {
+			return nil, fmt.Errorf("invalid max_download_size: %w", err)
+		}
+	}
+	if client.Cache, err = newAPICache(cfg); err != nil {
+		return nil, fmt.Errorf("invalid api_cache_ttl: %w", err)
+	}
+	return client, nil
+}
+
+// connectClient resolves cfg's token and returns a client for it. Commands
+// that write set write, which first checks the credentials belong to the
+// account that was authorized (see verifyAccount); reading needs no check.
+func connectClient(cfg *Config, write bool) (*DropboxClient, error) {
+	token, err := resolveToken(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		return nil, err
+	}
+	client, err := newClient(cfg, token)
+	if err != nil {
+		return nil, err
+	}
+	if os.Getenv("DROPBOX_TOKEN") == "" {
+		// Access tokens expire after a few hours, which a daemon or server
+		// outlives.
+		client.Refresh = func() (string, error) { return refreshToken(cfg, defaultTokenURL) }
+	}
+	if write {
+		if err := verifyAccount(client, cfg, false); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// loadClient loads the config and connects with connectClient, for
+// commands that need nothing in between. Failures are reported on stderr.
+func loadClient(stderr io.Writer, write bool) (*Config, *DropboxClient, bool) {
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return nil, nil, false
+	}
+	client, err := connectClient(cfg, write)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return nil, nil, false
+	}
+	return cfg, client, true
+}
+
+func runAuth(configPath string, args []string) {
+	fs := flag.NewFlagSet("auth", flag.ContinueOnError)
+	listen := fs.String("listen", "", "receive the authorization on this address, e.g. 127.0.0.1:53682, instead of pasting the code")
+	redirectURI := fs.String("redirect-uri", "", "redirect URI registered for the app, e.g. http://localhost:8080/callback for a port forwarded over SSH (default http://localhost:PORT/callback with -listen)")
+	noBrowser := fs.Bool("no-browser", false, "only print the authorization URL, don't open a browser")
+	if err := fs.Parse(args); err != nil {
+		os.Exit(2)
+	}
+	flow, err := newAuthFlow(*listen, *redirectURI, *noBrowser)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig(configPath, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, tr("error loading config: %v\n"), err)
 		os.Exit(1)
 	}
 
 	if cfg.AppKey == "" || cfg.AppSecret == "" {
-		fmt.Fprintln(os.Stderr, "app_key and app_secret required.")
+		fmt.Fprintln(os.Stderr, tr("app_key and app_secret required."))
 		fmt.Fprintf(os.Stderr, "Set in %s or via DROPBOX_APP_KEY and DROPBOX_APP_SECRET env vars.\n", configPath)
 		os.Exit(1)
 	}
 
-	fmt.Println("1. Open this URL in your browser:")
-	fmt.Println()
-	fmt.Println("  ", authorizeURL(cfg.AppKey))
-	fmt.Println()
-	fmt.Print("2. Enter the authorization code: ")
-
 	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Scan()
-	code := strings.TrimSpace(scanner.Text())
-	if code == "" {
-		fmt.Fprintln(os.Stderr, "no code entered")
+	code, err := flow.authorize(os.Stdout, scanner, cfg.AppKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	result, err := exchangeCode(defaultTokenURL, cfg.AppKey, cfg.AppSecret, code)
+	result, err := exchangeCode(defaultTokenURL, cfg.AppKey, cfg.AppSecret, code, flow.RedirectURI)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fmt.Fprintf(os.Stderr, tr("error: %v\n"), err)
 		os.Exit(1)
 	}
 
 	cfg.RefreshToken = result.RefreshToken
-	if err := saveConfig(configPath, cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "error saving config: %v\n", err)
+	cfg.AccountID = result.AccountID
+	cfg.AccountCredential = checksum([]byte(result.RefreshToken))
+	err = updateConfigFile(configPath, func(onDisk *Config) error {
+		onDisk.RefreshToken, onDisk.AccountID, onDisk.AccountCredential = cfg.RefreshToken, cfg.AccountID, cfg.AccountCredential
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, tr("error saving config: %v\n"), err)
 		os.Exit(1)
 	}
 
-	fmt.Println("\nAuthentication successful! Refresh token saved.")
+	fmt.Println(tr("\nAuthentication successful! Refresh token saved."))
+
+	client, err := newClient(cfg, result.AccessToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, tr("error loading config: %v\n"), err)
+		os.Exit(1)
+	}
+	if cfg.AppFolder == "" {
+		if name := askAppFolder(scanner, os.Stdout, client); name != "" {
+			cfg.AppFolder = name
+			configureAppFolder(cfg)
+			err := updateConfigFile(configPath, func(onDisk *Config) error {
+				onDisk.AppFolder = name
+				return nil
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, tr("error saving config: %v\n"), err)
+				os.Exit(1)
+			}
+			fmt.Printf("Saved app_folder %q: paths are relative to /Apps/%s.\n", name, name)
+		}
+	}
+
+	if cfg.PathTemplate != "" {
+		return
+	}
+	fmt.Print("\nScan your Dropbox for an existing daily-notes folder? [y/N] ")
+	scanner.Scan()
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(scanner.Text())), "y") {
+		return
+	}
+	if code := runSetupWithClient(scanner, os.Stdout, os.Stderr, client, cfg, configPath, defaultVaultRoots); code != 0 {
+		os.Exit(code)
+	}
 }
 
 // appendToJournal downloads an existing journal file (if any), appends the
 // entry, and re-uploads it. Shared by the default text mode and the sketch
-// subcommand.
-func appendToJournal(client *DropboxClient, path, entry string) error {
-	existing, err := client.Download(path)
+// and image subcommands. It returns the path actually written, which differs
+// from path once the note has rolled over to a continuation file.
+func appendToJournal(client *DropboxClient, path, entry string, opts appendOptions) (string, error) {
+	return appendEntries(client, path, []string{entry}, opts)
+}
+
+// appendEntries appends several entries to the note at path in a single
+// download/upload cycle. Paper documents are appended to through the Paper
+// API instead. With opts.Uploads the upload is left to the batch, and what
+// follows it (indexing, day links, the git mirror) runs once it's
+// committed.
+func appendEntries(client *DropboxClient, path string, entries []string, opts appendOptions) (string, error) {
+	if isPaperDoc(path) {
+		return appendToPaper(client, path, entries, opts)
+	}
+	op := beginInflight(opts.InflightDir, path, entries)
+	finish := op.finish
+	defer func() { finish() }()
+	part, existing, err := activePart(client, path, strings.Join(entries, "\n"), opts.MaxSize)
+	if err != nil {
+		return "", fmt.Errorf("downloading journal: %w", err)
+	}
+	sep, err := separatorText(opts.Separator)
 	if err != nil {
-		return fmt.Errorf("downloading journal: %w", err)
+		return "", err
+	}
+	eol, err := lineEnding(opts.LineEndings, existing)
+	if err != nil {
+		return "", err
+	}
+	// Entries are added to the LF form of the note; eol is applied on upload.
+	content := toLF(existing)
+	var day dayLinks
+	if opts.DayLinks != nil && part == path && existing == "" {
+		var ok bool
+		if day, ok, err = newDayLinks(client, path, opts); err != nil {
+			return "", fmt.Errorf("linking the previous note: %w", err)
+		}
+		if ok {
+			content = day.nav + "\n"
+		}
+	}
+	written := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if opts.Author != "" {
+			entry = attributeEntry(entry, opts.Author)
+		}
+		id := ""
+		if opts.EntryIDs {
+			id = newULID(opts.now(), rand.Reader)
+		}
+		if opts.Number {
+			n := nextEntryNumber(content)
+			if id == "" {
+				id = entryID(part, n)
+			}
+			entry = numberEntry(entry, n, id)
+		} else if id != "" {
+			entry = anchorEntry(entry, id)
+		}
+		if opts.TargetFormat == "paper-md" {
+			entry = paperMarkdown(entry, part)
+		}
+		content = insertInTimeSection(content, entry, sep, opts.Footer, opts.Sections)
+		written = append(written, entry)
+	}
+	if opts.Snapshots > 0 && existing != "" {
+		if err := saveSnapshot(opts.SnapshotDir, part, existing, opts.Snapshots, opts.now()); err != nil {
+			return "", fmt.Errorf("saving snapshot: %w", err)
+		}
+	}
+	data := withLineEnding(content, eol)
+	op.uploading(part, existing, data, written)
+	batch := logEntries(opts.WALPath, path, part, written)
+	// uploaded finishes the append once the upload of data to part has
+	// returned rev or err.
+	uploaded := func(rev string, err error) error {
+		if err != nil {
+			if batch != "" {
+				appendWAL(opts.WALPath, []walRecord{{Batch: batch, At: time.Now(), Failed: true}})
+			}
+			return fmt.Errorf("uploading journal: %w", err)
+		}
+		wroteNote(client, path, part, content, data, rev, opts)
+		if opts.DayLinks != nil && opts.DayLinks.PatchPrevious {
+			if err := day.patchPrevious(client, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: day links: %v\n", err)
+			}
+		}
+		return nil
+	}
+	if opts.Uploads != nil && !opts.Atomic {
+		// The in-flight record stays until the batch is committed.
+		finish = func() {}
+		opts.Uploads.add(part, []byte(data), func(rev string, err error) error {
+			defer op.finish()
+			return uploaded(rev, err)
+		})
+		return part, nil
+	}
+	var rev string
+	if opts.Atomic {
+		rev, err = uploadAtomic(client, part, []byte(data))
+	} else {
+		rev, err = client.upload(part, []byte(data), "overwrite")
+	}
+	if err := uploaded(rev, err); err != nil {
+		return "", err
+	}
+	return part, nil
+}
+
+// wroteNote follows an upload of content, as data, to part of the note at
+// path that returned rev: it clears the today cache, updates the local
+// index and the git mirror and stamps the note properties, as configured
+// in opts. Failures are only warnings; the note has been written.
+func wroteNote(client *DropboxClient, path, part, content, data, rev string, opts appendOptions) {
+	invalidateTodayCache(opts.CachePath)
+	if date, ok := templateDate(opts.PathTemplate, path); ok && opts.IndexPath != "" {
+		indexNote(opts.IndexPath, part, content, rev, date, os.Stderr)
+	}
+	if opts.GitMirror != nil {
+		if err := mirrorToGit(*opts.GitMirror, part, data, opts.now()); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: git mirror: %v\n", err)
+		}
 	}
-	if err := client.Upload(path, appendContent(existing, entry)); err != nil {
-		return fmt.Errorf("uploading journal: %w", err)
+	if opts.NoteProperties {
+		if err := stampNoteProperties(client, part, content, opts.now()); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: note properties: %v\n", err)
+		}
 	}
-	return nil
 }
 
 func main() {
+	clock := systemClock{}
+
+	args, globals, globalsErr := extractGlobalFlags(os.Args[1:])
+	plainOutput, readOnlyFlag = globals.Plain, globals.ReadOnly
+	selectedProfile, bwlimitFlag = globals.Profile, globals.BWLimit
+	os.Args = append(os.Args[:1], args...)
+	var stderr io.Writer = os.Stderr
+	if plainOutput {
+		stderr = newPlainWriter(os.Stderr)
+	} else if isTerminal(os.Stderr) {
+		stderr = newThemeWriter(os.Stderr)
+	}
+	if globalsErr != nil {
+		fmt.Fprintf(stderr, "error: %v\n", globalsErr)
+		os.Exit(2)
+	}
+	if globals.Verbose {
+		enableTiming(stderr)
+	}
+	if globals.DebugHTTP != "" {
+		// The log is written unbuffered, so os.Exit below loses nothing.
+		if _, err := enableHTTPDebug(globals.DebugHTTP, stderr); err != nil {
+			fmt.Fprintf(stderr, "error: -debug-http: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
 	// Check for subcommands before flag parsing.
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "auth":
-			runAuth(defaultConfigPath())
+			runAuth(defaultConfigPath(), os.Args[2:])
 			return
 		case "sketch":
-			os.Exit(runSketch(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+			os.Exit(runSketch(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
 		case "image":
-			os.Exit(runImage(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+			os.Exit(runImage(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "onthisday":
+			os.Exit(runOnThisDay(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "bookmark":
+			os.Exit(runBookmark(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "form":
+			os.Exit(runForm(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "fsck":
+			os.Exit(runFsck(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "flush":
+			os.Exit(runFlush(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "revisions":
+			os.Exit(runRevisions(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "rm":
+			os.Exit(runRm(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "recover":
+			os.Exit(runRecover(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "restore-snapshot":
+			os.Exit(runRestoreSnapshot(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "agenda":
+			os.Exit(runAgenda(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "annotate":
+			os.Exit(runAnnotate(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "reply":
+			os.Exit(runReply(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "pomo":
+			os.Exit(runPomo(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "digest":
+			os.Exit(runDigest(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "dayend":
+			os.Exit(runDayEnd(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "daemon":
+			os.Exit(runDaemon(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "serve":
+			os.Exit(runServe(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "telegram":
+			os.Exit(runTelegram(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "git-hook":
+			os.Exit(runGitHook(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "config":
+			os.Exit(runConfig(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "setup":
+			os.Exit(runSetup(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "today":
+			os.Exit(runToday(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "reindex":
+			os.Exit(runReindex(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "search":
+			os.Exit(runSearch(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "dump":
+			os.Exit(runDump(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "selftest":
+			os.Exit(runSelftest(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "open":
+			os.Exit(runOpen(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "reconcile":
+			os.Exit(runReconcile(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "run":
+			os.Exit(runRun(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "import":
+			os.Exit(runImport(os.Args[2:], os.Stdin, os.Stdout, stderr))
+		case "gc":
+			os.Exit(runGC(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "triage":
+			os.Exit(runTriage(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "stats":
+			os.Exit(runStats(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "dashboard":
+			os.Exit(runDashboard(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
+		case "tasks":
+			os.Exit(runTasks(os.Args[2:], os.Stdin, os.Stdout, stderr, clock))
 		}
 	}
 
-	noTimestamp := flag.Bool("no-timestamp", false, "omit the ### HH:MM:SS header")
-	flag.Parse()
+	os.Exit(runAppend(os.Args[1:], os.Stdin, os.Stdout, stderr, clock))
+}
+
+// runAppend implements the default mode: it appends the text from args or
+// stdin to today's note (or a structured row, or a range replacement,
+// depending on flags). -now overrides clock. It returns the process exit
+// code.
+func runAppend(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("dropbox-appender", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	noTimestamp := fs.Bool("no-timestamp", false, "omit the ### HH:MM:SS header")
+	rangeReplace := fs.String("range-replace", "", "replace the START,END range of today's note with stdin instead of appending")
+	rangeUnit := fs.String("range-unit", "lines", "unit for -range-replace: lines (1-based, inclusive) or bytes (0-based, end-exclusive)")
+	expectedRev := fs.String("rev", "", "with -range-replace, fail unless the remote note is at this revision")
+	maxRetries := fs.Int("max-retries", defaultRetryPolicy().MaxRetries, "retries for transient Dropbox errors (overrides config)")
+	retryBudget := fs.Duration("retry-budget", defaultRetryPolicy().Budget, "total time allowed for retries, e.g. 10s (overrides config)")
+	pathTemplate := fs.String("path-template", "", "Go template for the target path, e.g. /Journal/{{.Year}}/{{.Date}}.md (overrides config)")
+	format := fs.String("format", "markdown", "entry format: markdown, or csv/tsv to append a structured row")
+	fields := fs.String("fields", "", "comma-separated field names for -format csv/tsv; date, time and datetime are filled in")
+	noExpand := fs.Bool("no-expand", false, "don't expand :shortcodes: from the config")
+	number := fs.Bool("number", false, "number entries within the day (### 3. HH:MM:SS) with a deep-link anchor")
+	entryIDs := fs.Bool("entry-ids", false, "give the entry a ULID anchor for `dropbox-appender open -entry`")
+	noPlugins := fs.Bool("no-plugins", false, "skip the configured plugins")
+	wrap := fs.Int("wrap", 0, "hard-wrap entry text at this column, 0 to disable (overrides config)")
+	footer := fs.String("footer", "", "insert the entry above this footer line, e.g. \"## Tomorrow\" (overrides config)")
+	targetFormat := fs.String("target-format", "", "markdown, or paper-md to adjust headings and links for Dropbox Paper import (overrides config)")
+	separator := fs.String("separator", "", "what separates entries: blank, rule, none or a number of blank lines (overrides config)")
+	byDate := fs.Bool("by-date", false, "route each input line prefixed with YYYY-MM-DD[ HH:MM]: to that day's note")
+	queue := fs.Bool("queue", false, "queue the entry locally for `dropbox-appender daemon` instead of uploading now")
+	atomic := fs.Bool("atomic", false, "upload to a temporary file and move it into place so the note is never left truncated")
+	author := fs.String("author", "", "attribute the entry to this author (overrides the author config)")
+	audio := fs.String("attach-audio", "", "upload this audio file as an attachment and link it from the entry")
+	transcribeCmd := fs.String("transcribe-cmd", "", "with -attach-audio, command whose output becomes the entry text, e.g. \"whisper-cli -nt -f\" (overrides config)")
+	ocr := fs.String("ocr", "", "append the text recognized in this image, e.g. a whiteboard photo")
+	ocrCmd := fs.String("ocr-cmd", "", "with -ocr, command that prints the text in $IMAGE (default tesseract; overrides config)")
+	ocrAttach := fs.Bool("ocr-attach", false, "with -ocr, also attach the image and embed it above the text")
+	notify := fs.Bool("notify", false, "let the upload notify the Dropbox desktop and mobile apps; -notify=false mutes it (overrides config)")
+	verify := fs.Bool("verify-account", false, "check the credentials belong to the authorized account before writing")
+	tee := fs.Bool("tee", false, "also write the formatted entry to stdout for piping into other tools")
+	quiet := fs.Bool("quiet", false, "don't print the \"Appended to\" line")
+	showTail := fs.Int("show-tail", 0, "after appending, read back and print the last N lines of the note (overrides config)")
+	forceStdin := fs.Bool("stdin", false, "read the entry from stdin and wait for it, even from a terminal (same as a lone - argument)")
+	stdinNull := fs.Bool("stdin-null", false, "read several NUL-delimited entries from stdin (like xargs -0) and append them in one upload")
+	stdinTimeout := fs.Duration("stdin-timeout", 0, "give up when input on stdin hasn't started within this time, e.g. 5s from a launcher that leaves it open; 0 to wait (default: wait for pipes and files, 5s otherwise)")
+	captureCmds := fs.String("capture-env", "", "run these comma-separated allowed commands, e.g. \"go version,git status -s\", and append their output as code blocks")
+	fold := fs.Int("fold", 0, "fold entries longer than this many lines into a collapsible block, 0 to disable (overrides config)")
+	expires := fs.String("expires", "", "mark the entry to be removed by `dropbox-appender gc` after this long, e.g. 7d, 2w, 36h, or on YYYY-MM-DD")
+	lang := fs.String("lang", "", "fence the entry as code in this language, or auto to detect go, json, diff or sql (overrides config)")
+	flagged := fs.Bool("flag", false, "mark the entry as needing follow-up, for `dropbox-appender triage`")
+	nowFlag := fs.String("now", "", "use this time instead of the current time, e.g. 2025-01-15T14:30:00")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
 
 	configPath := defaultConfigPath()
-	cfg, err := loadConfig(configPath)
+	cfg, err := loadConfig(configPath, stderr)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if err := applyDefaultFlags(fs, cfg.Flags); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *nowFlag != "" {
+		t, err := parseNow(*nowFlag)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		clock = fixedClock(t)
 	}
 
-	token, err := resolveToken(cfg)
+	if refuseReadOnly(stderr, "appending") {
+		return 1
+	}
+
+	// connect sets up client. A plain -queue gets by without it, so it
+	// works without credentials or a network.
+	var client *DropboxClient
+	connect := func() error {
+		var err error
+		// With -verify-account the check is forced below instead.
+		if client, err = connectClient(cfg, !*verify); err != nil {
+			return err
+		}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "max-retries":
+				client.Retry.MaxRetries = *maxRetries
+			case "retry-budget":
+				client.Retry.Budget = *retryBudget
+			case "notify":
+				client.Notify, client.NotifyPaths = *notify, nil
+			}
+		})
+		if *verify {
+			return verifyAccount(client, cfg, true)
+		}
+		return nil
+	}
+	// Only an entry composed without Dropbox can go to the fallback when
+	// Dropbox is unreachable.
+	needsDropbox := *rangeReplace != "" || *format != "markdown" || *ocr != "" || *audio != "" || *byDate
+	var connErr error
+	if !*queue || needsDropbox {
+		connErr = connect()
+		if connErr != nil && (cfg.Fallback == nil || !unavailable(connErr) || needsDropbox) {
+			fmt.Fprintf(stderr, tr("error: %v\n"), connErr)
+			return 1
+		}
+	}
+	wrapWidth := cfg.Wrap
+	tailN := cfg.ShowTail
+	codeLang := cfg.CodeLang
+	var foldLines int
+	var foldSyntax string
+	if cfg.Fold != nil {
+		foldLines, foldSyntax = cfg.Fold.Lines, cfg.Fold.Syntax
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "wrap":
+			wrapWidth = *wrap
+		case "fold":
+			foldLines = *fold
+		case "show-tail":
+			tailN = *showTail
+		case "lang":
+			codeLang = *lang
+		}
+	})
+
+	now := clock.Now()
+	path, err := journalPath(cfg, *pathTemplate, now)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	if *rangeReplace != "" {
+		replacement, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, tr("reading stdin: %v\n"), err)
+			return 1
+		}
+		opts, err := appendOptionsFromConfig(cfg)
+		if err != nil {
+			fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+			return 1
+		}
+		opts.Clock = clock
+		if *pathTemplate != "" {
+			opts.PathTemplate = *pathTemplate
+		}
+		return runRangeReplace(stdout, stderr, client, path,
+			*rangeReplace, *rangeUnit, *expectedRev, string(replacement), cfg.MergeTool, opts)
 	}
 
-	input, err := readInput(flag.Args())
+	if *format != "markdown" {
+		return runRecord(stdin, stdout, stderr, client, path, *format, *fields, fs.Args(), now)
+	}
+
+	if *stdinNull {
+		for name, set := range map[string]bool{"-by-date": *byDate, "-attach-audio": *audio != "", "-ocr": *ocr != "", "-capture-env": *captureCmds != ""} {
+			if set {
+				fmt.Fprintf(stderr, "-stdin-null can't be combined with %s\n", name)
+				return 2
+			}
+		}
+	}
+	if *ocr != "" && *audio != "" {
+		fmt.Fprintln(stderr, "-ocr can't be combined with -attach-audio")
+		return 2
+	}
+	var inputs []string
+	tmpl := cfg.PathTemplate
+	if *pathTemplate != "" {
+		tmpl = *pathTemplate
+	}
+	attachments := attachmentFolder(tmpl)
+	if *ocr != "" {
+		if *ocrCmd == "" {
+			*ocrCmd = cfg.OCRCmd
+		}
+		if *ocrCmd == "" {
+			*ocrCmd = defaultOCRCmd
+		}
+		client.Progress = stderr
+		var input string
+		input, err = ocrEntry(client, path, attachments, *ocr, *ocrCmd, strings.Join(fs.Args(), " "), *ocrAttach, now)
+		inputs = []string{input}
+	} else if *audio != "" {
+		if *transcribeCmd == "" {
+			*transcribeCmd = cfg.TranscribeCmd
+		}
+		client.Progress = stderr
+		var input string
+		input, err = attachAudio(client, path, attachments, *audio, *transcribeCmd, strings.Join(fs.Args(), " "), now)
+		inputs = []string{input}
+	} else if *stdinNull {
+		inputs, err = readNullDelimited(stdin)
+	} else {
+		var input string
+		timeout := autoStdinTimeout(stdin)
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "stdin-timeout" {
+				timeout = *stdinTimeout
+			}
+		})
+		input, err = readInputWith(fs.Args(), stdin, inputOptions{Stdin: *forceStdin, Timeout: timeout})
+		inputs = []string{input}
+	}
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *showTail > 0 && *queue {
+		fmt.Fprintln(stderr, "-show-tail can't be combined with -queue")
+		return 2
+	}
+	if *byDate && *queue {
+		fmt.Fprintln(stderr, "-by-date can't be combined with -queue")
+		return 2
+	}
+	if *byDate && *captureCmds != "" {
+		fmt.Fprintln(stderr, "-by-date can't be combined with -capture-env")
+		return 2
+	}
+	capture, err := parseCaptureCommands(*captureCmds, cfg.CaptureEnvAllow)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	rawInput := inputs[0]
+	var expiresAt time.Time
+	if *expires != "" && *noTimestamp {
+		// Without a header the marker would expire the entry above.
+		fmt.Fprintln(stderr, "-expires can't be combined with -no-timestamp")
+		return 2
+	}
+	if *expires != "" {
+		if expiresAt, err = parseExpiry(*expires, now); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	if err := validCodeLang(*lang); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *flagged && *noTimestamp {
+		// Without a header the marker wouldn't start an entry.
+		fmt.Fprintln(stderr, "-flag can't be combined with -no-timestamp")
+		return 2
+	}
+	// markEntry adds the -flag and -expires markers to entry text.
+	markEntry := func(text string) string {
+		if *flagged {
+			text = withFlag(text)
+		}
+		if expiresAt.IsZero() {
+			return text
+		}
+		return withExpiry(text, expiresAt)
 	}
 
-	now := time.Now()
-	path := resolvePath(now)
-	entry := formatEntry(now, input, *noTimestamp)
+	entries := make([]string, len(inputs))
+	for i, input := range inputs {
+		if !*noExpand {
+			input = expandShortcodes(input, cfg.Shortcodes)
+		}
+		if !*noPlugins && !*byDate {
+			if input, err = applyPlugins(cfg.Plugins, input, path, now); err != nil {
+				fmt.Fprintf(stderr, tr("error: %v\n"), err)
+				return 1
+			}
+		}
+		input = withCapture(fenceCode(input, codeLang), captureEnv(capture))
+		input = foldText(wrapText(input, wrapWidth), foldLines, foldSyntax)
+		entries[i] = formatEntry(now, markEntry(input), *noTimestamp)
+	}
+	entry := strings.Join(entries, "\n")
 
-	client := &DropboxClient{Token: token}
-	if err := appendToJournal(client, path, entry); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+	// With -tee stdout carries only the entry, so status goes to stderr.
+	status := stdout
+	if *tee {
+		status = stderr
+	}
+	if *quiet {
+		status = io.Discard
+	}
+	teeEntry := func() {
+		if *tee {
+			fmt.Fprint(stdout, entry)
+		}
+	}
+
+	// The options of this invocation, kept with the entry if it's queued.
+	qopts := queuedOptions{Author: *author, Number: *number, EntryIDs: *entryIDs, Atomic: *atomic}
+	if *queue {
+		for i, e := range entries {
+			// Distinct times keep the queue files, and so the entries, in order.
+			q := queuedEntry{Path: path, Entry: e, QueuedAt: now.Add(time.Duration(i)), queuedOptions: qopts}
+			if err := enqueue(defaultQueueDir(), q); err != nil {
+				fmt.Fprintf(stderr, tr("error: queueing entry: %v\n"), err)
+				return 1
+			}
+		}
+		teeEntry()
+		printOK(status, fmt.Sprintf(tr("Queued for %s\n"), path))
+		return 0
+	}
+
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	if client != nil {
+		recoverInflight(client, opts.InflightDir, opts, stderr)
+	}
+	opts = qopts.apply(opts)
+	if *pathTemplate != "" {
+		opts.PathTemplate = *pathTemplate
+	}
+	if *separator != "" {
+		if _, err := separatorText(*separator); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		opts.Separator = *separator
+	}
+	if *targetFormat != "" {
+		if err := validTargetFormat(*targetFormat); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		opts.TargetFormat = *targetFormat
+	}
+	if *footer != "" {
+		opts.Footer = strings.TrimSpace(*footer)
+	}
+
+	if *byDate {
+		entries, err := parseDatedLines(rawInput, now.Location())
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		transform := func(text string) string {
+			if !*noExpand {
+				text = expandShortcodes(text, cfg.Shortcodes)
+			}
+			return markEntry(foldText(wrapText(fenceCode(text, codeLang), wrapWidth), foldLines, foldSyntax))
+		}
+		return appendByDate(status, stderr, client, cfg, *pathTemplate, entries, opts, transform)
 	}
 
-	fmt.Printf("Appended to %s\n", path)
+	if cfg.SpaceWarning > 0 && client != nil {
+		checkSpace(client, cfg.SpaceWarning, spaceCachePath(), now, stderr)
+	}
+
+	var written string
+	var interrupted bool
+	if err = connErr; err == nil {
+		interrupted = holdSignals(stderr, func() {
+			written, err = appendEntries(client, path, entries, opts)
+		})
+	}
+	if err != nil && cfg.Fallback != nil && unavailable(err) {
+		if saveToFallback(stderr, *cfg.Fallback, defaultQueueDir(),
+			queuedEntry{Path: path, Entry: entry, QueuedAt: now, queuedOptions: qopts}, err) {
+			teeEntry()
+			return 0
+		}
+		return 1
+	}
+	if code := reportNoSpace(stderr, err); code != 0 {
+		return code
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	teeEntry()
+	printOK(status, appendedMessage(status, written, len(entries)))
+	if tailN > 0 {
+		// Like the status line, but still shown with -quiet, which it overrides.
+		out := stdout
+		if *tee {
+			out = stderr
+		}
+		showNoteTail(out, stderr, client, written, tailN)
+	}
+	if interrupted {
+		return exitInterrupted
+	}
+	return 0
 }
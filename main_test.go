@@ -1,7 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -95,3 +100,190 @@ func TestResolveToken_NoAuth(t *testing.T) {
 		t.Errorf("expected guidance to run auth, got: %v", err)
 	}
 }
+
+func TestReadInput_ArgsWin(t *testing.T) {
+	got, err := readInput([]string{"from", "args"}, strings.NewReader("from stdin"))
+	if err != nil || got != "from args" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestReadInput_Stdin(t *testing.T) {
+	got, err := readInput(nil, strings.NewReader("  piped note\n"))
+	if err != nil || got != "piped note" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := readInput(nil, strings.NewReader("  \n")); err == nil {
+		t.Error("expected error for blank stdin")
+	}
+}
+
+func TestAutoStdinTimeout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	file, err := os.CreateTemp(t.TempDir(), "entry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := os.NewFile(uintptr(fds[0]), "socket")
+	defer sock.Close()
+	defer syscall.Close(fds[1])
+
+	for _, c := range []struct {
+		name  string
+		stdin io.Reader
+		want  time.Duration
+	}{
+		{"pipe", r, 0},
+		{"file", file, 0},
+		{"socket", sock, defaultStdinTimeout},
+		{"other reader", strings.NewReader("x"), defaultStdinTimeout},
+	} {
+		if got := autoStdinTimeout(c.stdin); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestReadInputWith(t *testing.T) {
+	got, err := readInputWith([]string{"-"}, strings.NewReader("from stdin\n"), inputOptions{})
+	if err != nil || got != "from stdin" {
+		t.Errorf("lone -: got %q, %v", got, err)
+	}
+
+	// An open pipe that never delivers anything, as some launchers leave it.
+	r, w := io.Pipe()
+	defer w.Close()
+	_, err = readInputWith(nil, r, inputOptions{Timeout: 20 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "-stdin") {
+		t.Errorf("silent pipe: err = %v", err)
+	}
+
+	// Input that starts late is waited for with -stdin, and read to EOF
+	// once it has started.
+	r, w = io.Pipe()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "slow ")
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "producer")
+		w.Close()
+	}()
+	got, err = readInputWith(nil, r, inputOptions{Stdin: true, Timeout: 10 * time.Millisecond})
+	if err != nil || got != "slow producer" {
+		t.Errorf("-stdin: got %q, %v", got, err)
+	}
+}
+
+func TestReadNullDelimited(t *testing.T) {
+	got, err := readNullDelimited(strings.NewReader("first\x00second\nline\x00\x00  \x00third\n"))
+	if err != nil || !reflect.DeepEqual(got, []string{"first", "second\nline", "third"}) {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := readNullDelimited(strings.NewReader("\x00 \x00")); err == nil {
+		t.Error("expected error for no entries")
+	}
+}
+
+func TestRunAppend_StdinNull(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("DROPBOX_TOKEN", "direct_token")
+	clock := fixedClock(time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC))
+
+	var stdout, stderr bytes.Buffer
+	code := runAppend([]string{"-queue", "-tee", "-stdin-null"}, strings.NewReader("one\x00two"), &stdout, &stderr, clock)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if want := "### 14:30:00\none\n\n### 14:30:00\ntwo\n"; stdout.String() != want {
+		t.Errorf("tee = %q, want %q", stdout.String(), want)
+	}
+
+	if code := runAppend([]string{"-stdin-null", "-by-date"}, strings.NewReader("x"), &stdout, &stderr, clock); code != 2 {
+		t.Errorf("-stdin-null -by-date: exit %d, want 2", code)
+	}
+}
+
+func TestRunAppend_QueueKeepsOptionsWithoutToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dataDir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataDir)
+	t.Setenv("DROPBOX_TOKEN", "")
+	clock := fixedClock(time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC))
+
+	var stderr bytes.Buffer
+	code := runAppend([]string{"-queue", "-author", "alice", "-number", "-atomic", "offline"}, strings.NewReader(""), io.Discard, &stderr, clock)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	queue, err := loadQueue(defaultQueueDir(), io.Discard)
+	if err != nil || len(queue) != 1 {
+		t.Fatalf("expected one queued entry, got %+v, %v", queue, err)
+	}
+	want := queuedOptions{Author: "alice", Number: true, Atomic: true}
+	if queue[0].queuedOptions != want {
+		t.Errorf("queued options = %+v, want %+v", queue[0].queuedOptions, want)
+	}
+}
+
+func TestRunAppend_InvalidNow(t *testing.T) {
+	var stderr bytes.Buffer
+	code := runAppend([]string{"-now", "tomorrow-ish", "note"}, strings.NewReader(""), io.Discard, &stderr, systemClock{})
+	if code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "invalid -now") {
+		t.Errorf("unexpected stderr: %q", stderr.String())
+	}
+}
+
+func TestRunAppend_NoInput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("DROPBOX_TOKEN", "direct_token")
+	var stderr bytes.Buffer
+	code := runAppend(nil, strings.NewReader(""), io.Discard, &stderr, fixedClock(time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)))
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "no input provided") {
+		t.Errorf("unexpected stderr: %q", stderr.String())
+	}
+}
+
+func TestRunAppend_TeeAndQuiet(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("DROPBOX_TOKEN", "direct_token")
+	clock := fixedClock(time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC))
+
+	var stdout, stderr bytes.Buffer
+	code := runAppend([]string{"-queue", "-tee", "pipe me"}, strings.NewReader(""), &stdout, &stderr, clock)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if stdout.String() != "### 14:30:00\npipe me\n" {
+		t.Errorf("expected only the entry on stdout, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "Queued for /Notes/Journal/2025/01/Note20250115.md") {
+		t.Errorf("expected status on stderr, got %q", stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := runAppend([]string{"-queue", "-quiet", "hush"}, strings.NewReader(""), &stdout, &stderr, clock); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Errorf("expected no output with -quiet, got %q / %q", stdout.String(), stderr.String())
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// runMergeTool lets a human reconcile a conflicting write, like git
+// mergetool. base, local and remote are written to temporary files exposed
+// to the command as $BASE, $LOCAL and $REMOTE; $MERGED starts as a copy of
+// local and whatever the tool leaves there is the result. A command that
+// doesn't mention any of them is run as `cmd "$MERGED" "$REMOTE"`, which
+// suits diff tools such as `code --wait --diff` or `vimdiff`. The command
+// runs through sh and must exit 0 for the merge to count.
+func runMergeTool(cmdline, name, base, local, remote string, stdin io.Reader, output io.Writer) (string, error) {
+	dir, err := os.MkdirTemp("", "dropbox-appender-merge-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(path.Base(name), ext)
+	files := map[string]string{"BASE": base, "LOCAL": local, "REMOTE": remote, "MERGED": local}
+	env := os.Environ()
+	for _, role := range []string{"BASE", "LOCAL", "REMOTE", "MERGED"} {
+		p := filepath.Join(dir, fmt.Sprintf("%s.%s%s", stem, role, ext))
+		if role == "MERGED" {
+			p = filepath.Join(dir, stem+ext)
+		}
+		if err := os.WriteFile(p, []byte(files[role]), 0600); err != nil {
+			return "", err
+		}
+		files[role] = p
+		env = append(env, role+"="+p)
+	}
+
+	if !strings.Contains(cmdline, "$") {
+		cmdline += ` "$MERGED" "$REMOTE"`
+	}
+	cmd := exec.Command("sh", "-c", cmdline)
+	cmd.Env = env
+	cmd.Stdin = stdin
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("merge tool failed: %w", err)
+	}
+
+	merged, err := os.ReadFile(files["MERGED"])
+	if err != nil {
+		return "", err
+	}
+	return string(merged), nil
+}
+
+// mergeAndUpload resolves a conflict on the note at path with the configured
+// merge tool: local is the content we meant to write on top of base, and the
+// remote side is downloaded fresh. The result is uploaded against the
+// remote's rev, so a further concurrent change is still reported as
+// ErrConflict rather than overwritten. It returns the merged content and
+// its rev.
+func mergeAndUpload(client *DropboxClient, tool, notePath, base, local string, stderr io.Writer) (string, string, error) {
+	remote, rev, err := client.DownloadRev(notePath)
+	if err != nil {
+		return "", "", fmt.Errorf("downloading remote version: %w", err)
+	}
+	fmt.Fprintf(stderr, "%s changed remotely, launching merge tool\n", notePath)
+	merged, err := runMergeTool(tool, notePath, base, local, remote, os.Stdin, stderr)
+	if err != nil {
+		return "", "", err
+	}
+	if merged == remote {
+		return merged, rev, nil
+	}
+	rev, err = client.UploadRev(notePath, merged, rev)
+	return merged, rev, err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func requireShell(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+}
+
+func TestRunMergeTool(t *testing.T) {
+	requireShell(t)
+	var out bytes.Buffer
+	merged, err := runMergeTool(`cat "$BASE" "$LOCAL" "$REMOTE" > "$MERGED"; basename "$MERGED"`,
+		"/Notes/Note.md", "base\n", "local\n", "remote\n", nil, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged != "base\nlocal\nremote\n" {
+		t.Errorf("unexpected merge result %q", merged)
+	}
+	if strings.TrimSpace(out.String()) != "Note.md" {
+		t.Errorf("expected MERGED to keep the note's name, got %q", out.String())
+	}
+}
+
+func TestRunMergeTool_DefaultArgs(t *testing.T) {
+	requireShell(t)
+	// Without placeholders the tool is given MERGED (pre-filled with local)
+	// and REMOTE as arguments.
+	script := filepath.Join(t.TempDir(), "tool.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\ncat \"$2\" >> \"$1\"\n"), 0700)
+	merged, err := runMergeTool(script, "n.md", "", "local\n", "remote\n", nil, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged != "local\nremote\n" {
+		t.Errorf("expected MERGED and REMOTE appended to the command, got %q", merged)
+	}
+}
+
+func TestRunMergeTool_Failure(t *testing.T) {
+	requireShell(t)
+	if _, err := runMergeTool("exit 1", "n.md", "", "a", "b", nil, io.Discard); err == nil {
+		t.Error("expected a failing tool to abort the merge")
+	}
+}
+
+func TestRunRangeReplace_MergeTool(t *testing.T) {
+	requireShell(t)
+	var uploaded, uploadArg string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/2/files/download"):
+			if strings.Contains(r.Header.Get("Dropbox-API-Arg"), "rev:rev1") {
+				w.Header().Set("Dropbox-API-Result", `{"rev":"rev1"}`)
+				w.Write([]byte("a\nb\n"))
+				return
+			}
+			w.Header().Set("Dropbox-API-Result", `{"rev":"rev2"}`)
+			w.Write([]byte("a\nb\nc\n"))
+		case strings.HasSuffix(r.URL.Path, "/2/files/upload"):
+			uploaded, uploadArg = string(body), r.Header.Get("Dropbox-API-Arg")
+			w.Write([]byte(`{"rev":"rev3"}`))
+		}
+	}))
+	defer server.Close()
+
+	// The "tool" combines our edited first line with the remote's extra line.
+	tool := `{ head -n 2 "$LOCAL"; tail -n 1 "$REMOTE"; } > "$MERGED"`
+	var stdout, stderr bytes.Buffer
+	code := runRangeReplace(&stdout, &stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		"/a.md", "1,1", "lines", "rev1", "A\n", tool, appendOptions{})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if uploaded != "A\nb\nc\n" || !strings.Contains(uploadArg, `"update":"rev2"`) {
+		t.Errorf("expected merged upload against rev2, got %q (%s)", uploaded, uploadArg)
+	}
+	if strings.TrimSpace(stdout.String()) != "rev3" {
+		t.Errorf("expected new rev on stdout, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// mqttKeepAlive is the keep-alive interval sent in CONNECT. The client
+// pings the broker when it has been quiet for half of it.
+const mqttKeepAlive = 60 * time.Second
+
+// MQTT 3.1.1 control packet types, already shifted into the high nibble of
+// the fixed header.
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttPuback     = 0x40
+	mqttSubscribe  = 0x82 // SUBSCRIBE requires flags 0010
+	mqttSuback     = 0x90
+	mqttPingreq    = 0xC0
+	mqttPingresp   = 0xD0
+	mqttDisconnect = 0xE0
+)
+
+// mqttMessage is an application message received on a subscription.
+type mqttMessage struct {
+	Topic   string
+	Payload []byte
+}
+
+// mqttConn is a minimal MQTT 3.1.1 subscriber: it connects, subscribes to
+// one topic filter at QoS 1 and reads messages. It implements only what the
+// serve subcommand needs, so there is no publishing or session resumption.
+type mqttConn struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	keepAlive time.Duration
+	pinged    bool
+}
+
+// dialMQTT connects to broker, a tcp://, mqtt://, ssl://, tls:// or
+// mqtts:// URL. Credentials are taken from the URL's user info.
+func dialMQTT(broker, clientID string, keepAlive time.Duration) (*mqttConn, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker %q: %w", broker, err)
+	}
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+		conn, err = net.DialTimeout("tcp", host, 10*time.Second)
+	case "ssl", "tls", "mqtts":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", host, nil)
+	default:
+		return nil, fmt.Errorf("invalid MQTT broker %q: scheme must be tcp or ssl", broker)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &mqttConn{conn: conn, r: bufio.NewReader(conn), keepAlive: keepAlive}
+	user, pass := "", ""
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	}
+	if err := c.connect(clientID, user, pass); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect sends CONNECT with a clean session and waits for CONNACK.
+func (c *mqttConn) connect(clientID, user, pass string) error {
+	var flags byte = 0x02 // clean session
+	body := mqttString("MQTT")
+	payload := mqttString(clientID)
+	if user != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(user)...)
+		if pass != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(pass)...)
+		}
+	}
+	body = append(body, 4, flags) // protocol level 4 is MQTT 3.1.1
+	body = binary.BigEndian.AppendUint16(body, uint16(c.keepAlive/time.Second))
+	if err := c.write(mqttConnect, append(body, payload...)); err != nil {
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	typ, resp, err := c.readPacket()
+	c.conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return fmt.Errorf("waiting for CONNACK: %w", err)
+	}
+	if typ != mqttConnack || len(resp) != 2 {
+		return fmt.Errorf("expected CONNACK, got packet type %#x", typ)
+	}
+	if code := resp[1]; code != 0 {
+		return fmt.Errorf("broker refused connection: %s", mqttConnackReason(code))
+	}
+	return nil
+}
+
+// subscribe subscribes to filter at QoS 1 and waits for SUBACK.
+func (c *mqttConn) subscribe(filter string) error {
+	body := binary.BigEndian.AppendUint16(nil, 1) // packet identifier
+	body = append(body, mqttString(filter)...)
+	body = append(body, 1)
+	if err := c.write(mqttSubscribe, body); err != nil {
+		return err
+	}
+	for {
+		typ, resp, err := c.readPacket()
+		if err != nil {
+			return fmt.Errorf("waiting for SUBACK: %w", err)
+		}
+		if typ != mqttSuback {
+			continue
+		}
+		if len(resp) < 3 || resp[2] == 0x80 {
+			return fmt.Errorf("broker refused subscription to %q", filter)
+		}
+		return nil
+	}
+}
+
+// next returns the next message, acknowledging QoS 1 deliveries and
+// pinging the broker whenever the connection has been idle for half the
+// keep-alive interval.
+func (c *mqttConn) next() (mqttMessage, error) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.keepAlive / 2))
+		first, err := c.r.ReadByte()
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			if c.pinged {
+				return mqttMessage{}, errors.New("broker stopped responding to pings")
+			}
+			c.pinged = true
+			if err := c.write(mqttPingreq, nil); err != nil {
+				return mqttMessage{}, err
+			}
+			continue
+		}
+		if err != nil {
+			return mqttMessage{}, err
+		}
+		c.conn.SetReadDeadline(time.Time{})
+		c.pinged = false
+
+		body, err := c.readBody()
+		if err != nil {
+			return mqttMessage{}, err
+		}
+		if first&0xF0 != mqttPublish {
+			continue // PINGRESP, or anything else a subscriber can ignore
+		}
+		msg, id, err := parsePublish(first, body)
+		if err != nil {
+			return mqttMessage{}, err
+		}
+		if id != 0 {
+			if err := c.write(mqttPuback, binary.BigEndian.AppendUint16(nil, id)); err != nil {
+				return mqttMessage{}, err
+			}
+		}
+		return msg, nil
+	}
+}
+
+// Close sends DISCONNECT and closes the connection.
+func (c *mqttConn) Close() error {
+	c.write(mqttDisconnect, nil)
+	return c.conn.Close()
+}
+
+// readPacket reads one control packet, returning its type and body.
+func (c *mqttConn) readPacket() (byte, []byte, error) {
+	first, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	body, err := c.readBody()
+	return first & 0xF0, body, err
+}
+
+// readBody reads the remaining length and the rest of a packet whose first
+// byte has been consumed.
+func (c *mqttConn) readBody() ([]byte, error) {
+	var n, shift int
+	for i := 0; ; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if i == 3 && b&0x80 != 0 {
+			return nil, errors.New("malformed MQTT remaining length")
+		}
+		n |= int(b&0x7F) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	_, err := io.ReadFull(c.r, body)
+	return body, err
+}
+
+// write sends a packet with the given first byte and body.
+func (c *mqttConn) write(first byte, body []byte) error {
+	pkt := append([]byte{first}, mqttRemainingLength(len(body))...)
+	_, err := c.conn.Write(append(pkt, body...))
+	return err
+}
+
+// parsePublish decodes a PUBLISH packet. The returned packet identifier is
+// zero for QoS 0 messages, which need no acknowledgement.
+func parsePublish(first byte, body []byte) (mqttMessage, uint16, error) {
+	if len(body) < 2 {
+		return mqttMessage{}, 0, errors.New("malformed MQTT PUBLISH")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+n {
+		return mqttMessage{}, 0, errors.New("malformed MQTT PUBLISH")
+	}
+	msg := mqttMessage{Topic: string(body[2 : 2+n])}
+	rest := body[2+n:]
+	var id uint16
+	if qos := (first >> 1) & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return mqttMessage{}, 0, errors.New("malformed MQTT PUBLISH")
+		}
+		id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+	msg.Payload = rest
+	return msg, id, nil
+}
+
+// mqttString encodes s as a length-prefixed UTF-8 string.
+func mqttString(s string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
+
+// mqttRemainingLength encodes n in MQTT's variable-length format.
+func mqttRemainingLength(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			return out
+		}
+	}
+}
+
+// mqttConnackReason describes a CONNACK return code.
+func mqttConnackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("return code %d", code)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeBroker accepts one MQTT connection, checks the CONNECT and SUBSCRIBE
+// it receives, then publishes payloads on topic at QoS 1 and collects the
+// PUBACK packet identifiers it gets back.
+func fakeBroker(t *testing.T, topic string, payloads []string, acks chan<- uint16) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		c := &mqttConn{conn: nc, r: bufio.NewReader(nc)}
+
+		typ, body, err := c.readPacket()
+		if err != nil || typ != mqttConnect || !bytes.Contains(body, []byte("user")) || !bytes.Contains(body, []byte("secret")) {
+			t.Errorf("CONNECT = %#x %q, %v", typ, body, err)
+			return
+		}
+		c.write(mqttConnack, []byte{0, 0})
+
+		typ, body, err = c.readPacket()
+		if err != nil || typ != mqttSubscribe&0xF0 || !bytes.Contains(body, []byte(topic)) {
+			t.Errorf("SUBSCRIBE = %#x %q, %v", typ, body, err)
+			return
+		}
+		c.write(mqttSuback, []byte{body[0], body[1], 1})
+
+		for i, p := range payloads {
+			pkt := mqttString(topic)
+			pkt = binary.BigEndian.AppendUint16(pkt, uint16(i+1))
+			c.write(mqttPublish|0x02, append(pkt, p...))
+		}
+		for {
+			typ, body, err := c.readPacket()
+			if err != nil {
+				return
+			}
+			if typ == mqttPuback {
+				acks <- binary.BigEndian.Uint16(body)
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestMQTTSubscribe(t *testing.T) {
+	acks := make(chan uint16, 2)
+	addr := fakeBroker(t, "journal/append", []string{`{"text": "door opened"}`, "second"}, acks)
+
+	c, err := dialMQTT("tcp://user:secret@"+addr, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("dialMQTT: %v", err)
+	}
+	defer c.Close()
+	if err := c.subscribe("journal/append"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	for i, want := range []string{`{"text": "door opened"}`, "second"} {
+		msg, err := c.next()
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if msg.Topic != "journal/append" || string(msg.Payload) != want {
+			t.Errorf("message = %q %q, want %q", msg.Topic, msg.Payload, want)
+		}
+		select {
+		case id := <-acks:
+			if id != uint16(i+1) {
+				t.Errorf("PUBACK id = %d, want %d", id, i+1)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no PUBACK")
+		}
+	}
+}
+
+func TestMQTTConnectRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		c := &mqttConn{conn: nc, r: bufio.NewReader(nc)}
+		c.readPacket()
+		c.write(mqttConnack, []byte{0, 5})
+	}()
+
+	_, err = dialMQTT("tcp://"+ln.Addr().String(), "test", time.Minute)
+	if err == nil || err.Error() != "broker refused connection: not authorized" {
+		t.Errorf("err = %v, want not authorized", err)
+	}
+}
+
+func TestMQTTRemainingLength(t *testing.T) {
+	for n, want := range map[int][]byte{0: {0}, 127: {0x7F}, 128: {0x80, 0x01}, 16383: {0xFF, 0x7F}, 321: {0xC1, 0x02}} {
+		if got := mqttRemainingLength(n); !bytes.Equal(got, want) {
+			t.Errorf("mqttRemainingLength(%d) = %x, want %x", n, got, want)
+		}
+		c := &mqttConn{r: bufio.NewReader(bytes.NewReader(append(want, make([]byte, n)...)))}
+		if body, err := c.readBody(); err != nil || len(body) != n {
+			t.Errorf("readBody for %d: len %d, %v", n, len(body), err)
+		}
+	}
+}
+
+func TestDialMQTT_BadScheme(t *testing.T) {
+	if _, err := dialMQTT("http://broker", "x", time.Minute); err == nil {
+		t.Error("expected an error for http://")
+	}
+}
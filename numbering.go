@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// entryAnchorPattern matches the anchor line written under numbered entry
+// headers and captures the entry ID.
+var entryAnchorPattern = regexp.MustCompile(`(?m)^<a id="([^"]+)"></a>\s*$`)
+
+// nonSlugChars are replaced when deriving entry IDs from note names.
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// nextEntryNumber returns the number for a new entry in content: one past the
+// highest existing number, and never less than the count of entries so far,
+// so numbering stays positional when it's switched on mid-day.
+func nextEntryNumber(content string) int {
+	highest := 0
+	matches := entryHeaderPattern.FindAllStringSubmatch(content, -1)
+	for _, m := range matches {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > highest {
+			highest = n
+		}
+	}
+	if len(matches) > highest {
+		highest = len(matches)
+	}
+	return highest + 1
+}
+
+// entryID returns a stable ID for entry n of the note at p, derived from the
+// note's file name so it is unique across days and continuation parts:
+// Note20250115.md entry 3 becomes note20250115-3.
+func entryID(p string, n int) string {
+	name := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	return fmt.Sprintf("%s-%d", slug, n)
+}
+
+// numberEntry rewrites a formatted entry's "### HH:MM:SS" header to
+// "### n. HH:MM:SS" and adds an invisible HTML anchor carrying id, which
+// other notes can deep-link to with Note.md#id. Entries without a header
+// (e.g. -no-timestamp) are returned unchanged.
+func numberEntry(entry string, n int, id string) string {
+	if !strings.HasPrefix(entry, "### ") {
+		return entry
+	}
+	header, rest, _ := strings.Cut(entry, "\n")
+	return anchorEntry(fmt.Sprintf("### %d. %s\n%s", n, strings.TrimPrefix(header, "### "), rest), id)
+}
+
+// anchorEntry adds the anchor carrying id under a formatted entry's header.
+// Entries without a header are returned unchanged.
+func anchorEntry(entry, id string) string {
+	if !strings.HasPrefix(entry, "### ") {
+		return entry
+	}
+	header, rest, _ := strings.Cut(entry, "\n")
+	return fmt.Sprintf("%s\n<a id=\"%s\"></a>\n%s", header, id, rest)
+}
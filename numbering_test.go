@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNextEntryNumber(t *testing.T) {
+	cases := map[string]int{
+		"":                                     1,
+		"### 09:00:00\na\n\n### 10:00:00\nb\n": 3,
+		"### 1. 09:00:00\na\n\n### 2. 10:00:00\nb\n":              3,
+		"### 7. 09:00:00\na\n":                                    8,
+		"### 1. 09:00:00\na\n\n### 10:00:00\nb\n\n### 11:00:00\n": 4,
+	}
+	for content, want := range cases {
+		if got := nextEntryNumber(content); got != want {
+			t.Errorf("nextEntryNumber(%q) = %d, want %d", content, got, want)
+		}
+	}
+}
+
+func TestEntryID(t *testing.T) {
+	if got := entryID("/Notes/Journal/2025/01/Note20250115.md", 3); got != "note20250115-3" {
+		t.Errorf("got %q", got)
+	}
+	if got := entryID("/Daily/2025-01-15 Wed.md", 1); got != "2025-01-15-wed-1" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNumberEntry(t *testing.T) {
+	got := numberEntry("### 14:30:45\nHad a great meeting\n", 3, "note20250115-3")
+	want := "### 3. 14:30:45\n<a id=\"note20250115-3\"></a>\nHad a great meeting\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := numberEntry("plain\n", 1, "x"); got != "plain\n" {
+		t.Errorf("entries without a header should be unchanged, got %q", got)
+	}
+}
+
+func TestAppendToJournal_Numbered(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 1. 09:00:00\n<a id=\"note20250115-1\"></a>\nmorning\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", appendOptions{Number: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(files[path], "\n### 2. 14:30:45\n<a id=\"note20250115-2\"></a>\nafternoon\n") {
+		t.Errorf("unexpected note: %q", files[path])
+	}
+	if n, last := summarizeNote(files[path]); n != 2 || last != "14:30:45" {
+		t.Errorf("summary should understand numbered headers, got %d, %q", n, last)
+	}
+}
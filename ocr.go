@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultOCRCmd recognizes text with tesseract, which writes it to stdout
+// when the output base is "-".
+const defaultOCRCmd = `tesseract "$IMAGE" - 2>/dev/null`
+
+// recognizeText runs cmdline through sh to read the text in the image file
+// and returns it. The file is available as $IMAGE; a command that doesn't
+// use it gets the path as its last argument. Trailing spaces and runs of
+// blank lines, which OCR output is full of, are removed.
+func recognizeText(cmdline, file string) (string, error) {
+	out, err := runFileCommand("ocr", cmdline, "IMAGE", file)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, " \t\r\f")
+		if line == "" {
+			blank = true
+			continue
+		}
+		if blank && len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		blank = false
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// ocrAttachmentPath returns the Dropbox path for an image attached with
+// -ocr-attach: ocr-YYYYMMDD-HHMMSS plus the file's own extension, in folder.
+func ocrAttachmentPath(folder, file string, now time.Time) string {
+	return imageAttachmentPath(folder, "ocr-"+now.Format("20060102-150405"), strings.ToLower(filepath.Ext(file)))
+}
+
+// ocrEntry returns the entry body for an image whose text is recognized
+// with ocrCmd: text, then the image when attach uploads it to folder, then
+// the recognized text.
+func ocrEntry(client *DropboxClient, notePath, folder, file, ocrCmd, text string, attach bool, now time.Time) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading image: %w", err)
+	}
+	recognized, err := recognizeText(ocrCmd, file)
+	if err != nil {
+		return "", err
+	}
+	if recognized == "" {
+		return "", fmt.Errorf("no text recognized in %s", file)
+	}
+
+	var parts []string
+	if text != "" {
+		parts = append(parts, text)
+	}
+	if attach {
+		attPath := ocrAttachmentPath(folder, file, now)
+		if err := client.UploadBytes(attPath, data); err != nil {
+			return "", fmt.Errorf("uploading image: %w", err)
+		}
+		parts = append(parts, fmt.Sprintf("![%s](%s)", path.Base(attPath), relativeLink(notePath, attPath)))
+	}
+	parts = append(parts, recognized)
+	return strings.Join(parts, "\n\n"), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecognizeText(t *testing.T) {
+	requireShell(t)
+	got, err := recognizeText(`printf 'Q3 goals  \n\n\n\n- ship it\f\n' # "$IMAGE"`, "/tmp/board.png")
+	if err != nil || got != "Q3 goals\n\n- ship it" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	got, err = recognizeText("echo text in", "/tmp/board.png")
+	if err != nil || got != "text in /tmp/board.png" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := recognizeText("exit 1", "x"); err == nil {
+		t.Error("expected error from failing command")
+	}
+}
+
+func TestOCREntry(t *testing.T) {
+	requireShell(t)
+	file := filepath.Join(t.TempDir(), "board.JPG")
+	os.WriteFile(file, []byte("IMAGE"), 0600)
+
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	notePath := "/Notes/Journal/2025/01/Note20250115.md"
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+
+	body, err := ocrEntry(client, notePath, "/Notes/attachments", file, "echo ship it #", "Planning", true, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Planning\n\n![ocr-20250115-143045.jpg](../../../attachments/ocr-20250115-143045.jpg)\n\nship it"
+	if body != want {
+		t.Errorf("got %q, want %q", body, want)
+	}
+	if files["/Notes/attachments/ocr-20250115-143045.jpg"] != "IMAGE" {
+		t.Errorf("expected the image to be uploaded, got %v", files)
+	}
+
+	delete(files, "/Notes/attachments/ocr-20250115-143045.jpg")
+	if body, err := ocrEntry(client, notePath, "/Notes/attachments", file, "echo ship it #", "", false, now); err != nil || body != "ship it" {
+		t.Errorf("got %q, %v", body, err)
+	}
+	if len(files) != 0 {
+		t.Errorf("nothing should be uploaded without attach, got %v", files)
+	}
+	if _, err := ocrEntry(client, notePath, "/Notes/attachments", file, "true", "", false, now); err == nil {
+		t.Error("expected an error when no text is recognized")
+	}
+}
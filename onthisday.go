@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// onThisDayHeading marks the section appended by `onthisday -append`. Its
+// presence in today's note means the section was already added.
+const onThisDayHeading = "## 📅 On this day"
+
+// defaultOnThisDayYears is how many previous years onthisday looks back.
+const defaultOnThisDayYears = 10
+
+// pastNote is a previous year's journal for the same calendar date.
+type pastNote struct {
+	Date    time.Time
+	Content string
+}
+
+// onThisDayDates returns the same month and day in each of the previous n
+// years, most recent first. Years where the date doesn't exist (Feb 29 in a
+// non-leap year) are skipped.
+func onThisDayDates(now time.Time, n int) []time.Time {
+	var dates []time.Time
+	for i := 1; i <= n; i++ {
+		d := time.Date(now.Year()-i, now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if d.Month() != now.Month() {
+			continue
+		}
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// fetchPastNotes downloads the journal for each date, at the path cfg's
+// template gives, concurrently and returns the non-empty ones in the order
+// of dates.
+func fetchPastNotes(client *DropboxClient, cfg *Config, dates []time.Time) ([]pastNote, error) {
+	paths := make([]string, len(dates))
+	for i, d := range dates {
+		p, err := journalPath(cfg, "", d)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = p
+	}
+	contents := make([]string, len(dates))
+	errs := make([]error, len(dates))
+
+	var wg sync.WaitGroup
+	for i := range dates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			contents[i], errs[i] = client.Download(paths[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var notes []pastNote
+	for i, d := range dates {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("downloading %s: %w", paths[i], errs[i])
+		}
+		if strings.TrimSpace(contents[i]) != "" {
+			notes = append(notes, pastNote{Date: d, Content: contents[i]})
+		}
+	}
+	return notes, nil
+}
+
+// formatOnThisDay renders past notes as a markdown section, quoting each
+// year's content under a bold date line.
+func formatOnThisDay(notes []pastNote) string {
+	var b strings.Builder
+	b.WriteString(onThisDayHeading + "\n")
+	for _, n := range notes {
+		fmt.Fprintf(&b, "\n**%s**\n\n", n.Date.Format("2006-01-02"))
+		for _, line := range strings.Split(strings.TrimRight(n.Content, "\n"), "\n") {
+			if line == "" {
+				b.WriteString(">\n")
+			} else {
+				b.WriteString("> " + line + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// runOnThisDay implements the `dropbox-appender onthisday` subcommand: it
+// gathers the notes written on today's date in previous years and prints them,
+// or with -append adds them as a section to today's note. It returns the
+// process exit code.
+func runOnThisDay(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("onthisday", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	years := fs.Int("years", defaultOnThisDayYears, "how many previous years to look back")
+	appendSection := fs.Bool("append", false, "append an \"On this day\" section to today's note instead of printing")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, client, ok := loadClient(stderr, *appendSection)
+	if !ok {
+		return 1
+	}
+
+	return runOnThisDayWithClient(stdout, stderr,
+		client, cfg, clock.Now(), *years, *appendSection)
+}
+
+// runOnThisDayWithClient is the testable core of the onthisday subcommand.
+// When appending, the section is skipped if today's note already has one.
+func runOnThisDayWithClient(stdout, stderr io.Writer, client *DropboxClient, cfg *Config,
+	now time.Time, years int, appendSection bool) int {
+
+	notes, err := fetchPastNotes(client, cfg, onThisDayDates(now, years))
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if len(notes) == 0 {
+		fmt.Fprintln(stderr, "No entries on this day in previous years")
+		return 0
+	}
+
+	section := formatOnThisDay(notes)
+	if !appendSection {
+		fmt.Fprint(stdout, section)
+		return 0
+	}
+
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	existing, err := client.Download(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading journal: %v\n", err)
+		return 1
+	}
+	if strings.Contains(existing, onThisDayHeading) {
+		fmt.Fprintf(stderr, "%s already has an \"On this day\" section\n", path)
+		return 0
+	}
+	if err := client.Upload(path, appendContent(existing, section)); err != nil {
+		fmt.Fprintf(stderr, "error: uploading journal: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stderr, "Appended %d past notes to %s\n", len(notes), path)
+	return 0
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnThisDayDates(t *testing.T) {
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	dates := onThisDayDates(now, 3)
+	want := []string{"2024-01-15", "2023-01-15", "2022-01-15"}
+	if len(dates) != len(want) {
+		t.Fatalf("expected %d dates, got %d", len(want), len(dates))
+	}
+	for i, d := range dates {
+		if got := d.Format("2006-01-02"); got != want[i] {
+			t.Errorf("dates[%d] = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestOnThisDayDates_LeapDay(t *testing.T) {
+	now := time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC)
+	dates := onThisDayDates(now, 8)
+	if len(dates) != 2 {
+		t.Fatalf("expected 2 leap years (2020, 2016), got %v", dates)
+	}
+	if dates[0].Year() != 2020 || dates[1].Year() != 2016 {
+		t.Errorf("unexpected years: %v", dates)
+	}
+}
+
+func TestFormatOnThisDay(t *testing.T) {
+	notes := []pastNote{
+		{Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Content: "### 09:00:00\nsnow day\n"},
+	}
+	got := formatOnThisDay(notes)
+	want := "## 📅 On this day\n\n**2024-01-15**\n\n> ### 09:00:00\n> snow day\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// onThisDayServer serves past journals keyed by path, reporting not_found for
+// anything else, and records the last journal upload.
+func onThisDayServer(t *testing.T, files map[string]string, uploaded *string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg struct {
+			Path string `json:"path"`
+		}
+		json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/2/files/download"):
+			content, ok := files[arg.Path]
+			if !ok {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+				return
+			}
+			w.WriteHeader(200)
+			w.Write([]byte(content))
+		case strings.HasSuffix(r.URL.Path, "/2/files/upload"):
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			*uploaded = string(body)
+			mu.Unlock()
+			w.WriteHeader(200)
+			w.Write([]byte(`{}`))
+		}
+	}))
+}
+
+func TestRunOnThisDayWithClient_Print(t *testing.T) {
+	var uploaded string
+	server := onThisDayServer(t, map[string]string{
+		"/Notes/Journal/2024/01/Note20240115.md": "last year\n",
+		"/Notes/Journal/2022/01/Note20220115.md": "three years ago\n",
+	}, &uploaded)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := runOnThisDayWithClient(&stdout, &stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{},
+		time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC), 5, false)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "**2024-01-15**") || !strings.Contains(out, "> three years ago") {
+		t.Errorf("unexpected output: %q", out)
+	}
+	if strings.Index(out, "2024") > strings.Index(out, "2022") {
+		t.Errorf("expected most recent year first: %q", out)
+	}
+	if uploaded != "" {
+		t.Errorf("print mode should not upload, got %q", uploaded)
+	}
+}
+
+func TestRunOnThisDayWithClient_AppendOnce(t *testing.T) {
+	var uploaded string
+	files := map[string]string{
+		"/Notes/Journal/2024/01/Note20240115.md": "last year\n",
+		"/Notes/Journal/2025/01/Note20250115.md": "### 09:00:00\ntoday\n",
+	}
+	server := onThisDayServer(t, files, &uploaded)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	var stderr bytes.Buffer
+	if code := runOnThisDayWithClient(io.Discard, &stderr, client, &Config{}, now, 3, true); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	want := "### 09:00:00\ntoday\n\n## 📅 On this day\n\n**2024-01-15**\n\n> last year\n"
+	if uploaded != want {
+		t.Fatalf("uploaded:\n got %q\nwant %q", uploaded, want)
+	}
+
+	files["/Notes/Journal/2025/01/Note20250115.md"] = uploaded
+	uploaded = ""
+	if code := runOnThisDayWithClient(io.Discard, &stderr, client, &Config{}, now, 3, true); code != 0 {
+		t.Fatalf("expected exit code 0 on rerun, got %d", code)
+	}
+	if uploaded != "" {
+		t.Errorf("section should not be appended twice, got upload %q", uploaded)
+	}
+}
+
+func TestRunOnThisDayWithClient_PathTemplate(t *testing.T) {
+	var uploaded string
+	files := map[string]string{
+		"/Daily/2024-01-15.md": "last year\n",
+		"/Daily/2025-01-15.md": "today\n",
+	}
+	server := onThisDayServer(t, files, &uploaded)
+	defer server.Close()
+
+	cfg := &Config{PathTemplate: "/Daily/{{.Year}}-{{.Month}}-{{.Day}}.md"}
+	var stderr bytes.Buffer
+	code := runOnThisDayWithClient(io.Discard, &stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, cfg,
+		time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC), 3, true)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if want := "today\n\n## 📅 On this day\n\n**2024-01-15**\n\n> last year\n"; uploaded != want {
+		t.Errorf("uploaded:\n got %q\nwant %q", uploaded, want)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// isTerminal reports whether w is an interactive terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// pagerCommand returns the pager command line: $DROPBOX_APPENDER_PAGER,
+// else $PAGER, else less. "" (when set explicitly) or "cat" means none.
+func pagerCommand() string {
+	for _, env := range []string{"DROPBOX_APPENDER_PAGER", "PAGER"} {
+		if v, ok := os.LookupEnv(env); ok {
+			if strings.TrimSpace(v) == "cat" {
+				return ""
+			}
+			return strings.TrimSpace(v)
+		}
+	}
+	return "less"
+}
+
+// pageOutput writes text to stdout, through the pager when stdout is a
+// terminal and paging isn't disabled. With render, Markdown in text is
+// highlighted first. As with git, LESS defaults to FRX so colours show and
+// less exits straight away when the text fits on one screen. If the pager
+// can't be started the text is written directly.
+func pageOutput(stdout io.Writer, text string, render, noPager bool) {
+	if render && colorEnabled() {
+		text = renderMarkdown(text)
+	}
+	pager := pagerCommand()
+	if noPager || pager == "" || !isTerminal(stdout) {
+		io.WriteString(stdout, text)
+		return
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	in, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		io.WriteString(stdout, text)
+		return
+	}
+	// A write error just means the user quit the pager early.
+	io.WriteString(in, text)
+	in.Close()
+	cmd.Wait()
+}
+
+// ANSI escape sequences used by renderMarkdown.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiHeading   = "\x1b[1;36m"
+	ansiTag       = "\x1b[35m"
+	ansiCode      = "\x1b[33m"
+	ansiTaskOpen  = "\x1b[1;33m"
+)
+
+var (
+	renderHeading  = regexp.MustCompile(`^#{1,6} `)
+	renderQuote    = regexp.MustCompile(`^\s*>`)
+	renderOpenTask = regexp.MustCompile(`^(\s*[-*] )(\[ \])`)
+	renderDoneTask = regexp.MustCompile(`^\s*[-*] \[[xX]\] `)
+	renderBold     = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	renderCode     = regexp.MustCompile("`([^`\n]+)`")
+	renderLink     = regexp.MustCompile(`\[([^\]\n]+)\]\(([^)\s]+)\)`)
+)
+
+// renderMarkdown highlights Markdown for a terminal with ANSI escapes:
+// headings, bold, inline and fenced code, links, #tags, blockquotes and
+// task boxes (done tasks are dimmed). Inline ** and ` markers are replaced
+// by the styling and links shown as "text (url)"; everything else is kept.
+// It is line-based and deliberately simple.
+func renderMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+	inFence := false
+	for i, line := range lines {
+		switch {
+		case wrapFence.MatchString(line):
+			inFence = !inFence
+			lines[i] = ansiDim + line + ansiReset
+		case inFence:
+			lines[i] = ansiCode + line + ansiReset
+		case renderHeading.MatchString(line):
+			lines[i] = ansiHeading + line + ansiReset
+		case renderDoneTask.MatchString(line):
+			lines[i] = ansiDim + line + ansiReset
+		case renderQuote.MatchString(line):
+			lines[i] = ansiItalic + renderInline(line) + ansiReset
+		default:
+			line = renderOpenTask.ReplaceAllString(line, "${1}"+ansiTaskOpen+"${2}"+ansiReset)
+			lines[i] = renderInline(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderInline highlights the inline Markdown of one line.
+func renderInline(line string) string {
+	line = renderCode.ReplaceAllString(line, ansiCode+"$1"+"\x1b[39m")
+	line = renderBold.ReplaceAllString(line, ansiBold+"$1"+"\x1b[22m")
+	line = renderLink.ReplaceAllString(line, ansiUnderline+"$1"+"\x1b[24m "+ansiDim+"($2)"+"\x1b[22m")
+	return tagPattern.ReplaceAllStringFunc(line, func(m string) string {
+		i := strings.IndexByte(m, '#')
+		return m[:i] + ansiTag + m[i:] + "\x1b[39m"
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// unsetenv unsets key for the rest of the test; t.Setenv restores it.
+func unsetenv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestPagerCommand(t *testing.T) {
+	cases := []struct {
+		own, pager, want string
+		setOwn, setPager bool
+	}{
+		{want: "less"},
+		{pager: "most", setPager: true, want: "most"},
+		{pager: "cat", setPager: true, want: ""},
+		{own: "less -S", setOwn: true, pager: "most", setPager: true, want: "less -S"},
+		{own: "", setOwn: true, pager: "most", setPager: true, want: ""},
+	}
+	for _, c := range cases {
+		t.Setenv("DROPBOX_APPENDER_PAGER", c.own)
+		t.Setenv("PAGER", c.pager)
+		if !c.setOwn {
+			unsetenv(t, "DROPBOX_APPENDER_PAGER")
+		}
+		if !c.setPager {
+			unsetenv(t, "PAGER")
+		}
+		if got := pagerCommand(); got != c.want {
+			t.Errorf("pagerCommand() with %+v = %q, want %q", c, got, c.want)
+		}
+	}
+}
+
+func TestPageOutput_NotATerminal(t *testing.T) {
+	t.Setenv("PAGER", "false")
+	var buf bytes.Buffer
+	pageOutput(&buf, "## Notes\n", false, false)
+	if buf.String() != "## Notes\n" {
+		t.Errorf("got %q", buf.String())
+	}
+	buf.Reset()
+	pageOutput(&buf, "## Notes\n", true, false)
+	if !strings.Contains(buf.String(), ansiHeading) {
+		t.Errorf("-render output not highlighted: %q", buf.String())
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	in := "## Tomorrow\n- [ ] call **bob** #work\n- [x] done\n> quoted\n```\n# not a heading\n```\nsee [docs](https://go.dev) and `code`"
+	out := renderMarkdown(in)
+
+	for _, want := range []string{
+		ansiHeading + "## Tomorrow" + ansiReset,
+		ansiTaskOpen + "[ ]" + ansiReset,
+		ansiBold + "bob\x1b[22m",
+		ansiTag + "#work\x1b[39m",
+		ansiDim + "- [x] done" + ansiReset,
+		ansiItalic + "> quoted",
+		ansiCode + "# not a heading" + ansiReset,
+		ansiUnderline + "docs\x1b[24m",
+		ansiCode + "code\x1b[39m",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered output missing %q:\n%q", want, out)
+		}
+	}
+	want := strings.NewReplacer("**", "", "`code`", "code", "[docs](https://go.dev)", "docs (https://go.dev)").Replace(in)
+	if stripped := ansiPattern.ReplaceAllString(out, ""); stripped != want {
+		t.Errorf("rendering changed the text:\n%q", stripped)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// paperDocExt is the extension Dropbox gives Paper documents.
+const paperDocExt = ".paper"
+
+// dropboxPreviewURL is prefixed to a Dropbox path to link to it from Paper,
+// which can't follow relative links.
+const dropboxPreviewURL = "https://www.dropbox.com/preview"
+
+var (
+	// paperDeepHeading matches the #### to ###### headings Paper can't show.
+	paperDeepHeading = regexp.MustCompile(`^#{4,6}(\s)`)
+	// mdLinkTarget matches the target of a Markdown link or image.
+	mdLinkTarget = regexp.MustCompile(`(\]\()([^)\s]+)(\))`)
+)
+
+// isPaperDoc reports whether p is a Dropbox Paper document.
+func isPaperDoc(p string) bool {
+	return strings.EqualFold(path.Ext(p), paperDocExt)
+}
+
+// validTargetFormat checks a -target-format or target_format value.
+func validTargetFormat(format string) error {
+	switch format {
+	case "", "markdown", "paper-md":
+		return nil
+	}
+	return fmt.Errorf("invalid target format %q: expected markdown or paper-md", format)
+}
+
+// paperMarkdown adjusts an entry for Markdown that is imported into Dropbox
+// Paper, which only has three heading levels and resolves no relative
+// links: #### and deeper headings become ###, and relative link and image
+// targets become Dropbox preview links for the file they point to from
+// notePath. Code blocks are left alone.
+func paperMarkdown(entry, notePath string) string {
+	lines := strings.Split(entry, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		line = paperDeepHeading.ReplaceAllString(line, "###$1")
+		lines[i] = mdLinkTarget.ReplaceAllStringFunc(line, func(m string) string {
+			parts := mdLinkTarget.FindStringSubmatch(m)
+			return parts[1] + paperLinkTarget(parts[2], notePath) + parts[3]
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// paperLinkTarget returns the absolute form of a relative link target.
+// URLs, anchors and mailto: links are returned unchanged.
+func paperLinkTarget(target, notePath string) string {
+	if strings.HasPrefix(target, "#") {
+		return target
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return target
+	}
+	abs := u.Path
+	if !strings.HasPrefix(abs, "/") {
+		abs = path.Join(path.Dir(notePath), abs)
+	}
+	return dropboxPreviewURL + (&url.URL{Path: abs}).EscapedPath()
+}
+
+// Export returns a Dropbox Paper document as Markdown with files/export.
+// A missing document is empty.
+func (c *DropboxClient) Export(p string) (string, error) {
+	resp, body, err := c.content("/2/files/export", map[string]string{"path": p, "export_format": "markdown"}, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("export request: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		apiErr := newAPIError(resp, body)
+		if errors.Is(apiErr, ErrNotFound) {
+			return "", nil
+		}
+		return "", apiErr
+	}
+	return string(body), nil
+}
+
+// PaperAppend adds Markdown to the end of a Paper document with
+// files/paper/update, creating the document when it doesn't exist.
+func (c *DropboxClient) PaperAppend(p, markdown string) error {
+	err := c.paperCall("/2/files/paper/update", map[string]string{
+		"path":              p,
+		"import_format":     "markdown",
+		"doc_update_policy": "append",
+	}, markdown)
+	if errors.Is(err, ErrNotFound) {
+		err = c.paperCall("/2/files/paper/create", map[string]string{
+			"path":          p,
+			"import_format": "markdown",
+		}, markdown)
+	}
+	return err
+}
+
+// paperCall sends markdown to a Paper content endpoint.
+func (c *DropboxClient) paperCall(endpoint string, arg interface{}, markdown string) error {
+	defer invalidateAPICache()
+	resp, body, err := c.content(endpoint, arg, []byte(markdown), nil)
+	if err != nil {
+		return fmt.Errorf("paper request: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return newAPIError(resp, body)
+	}
+	return nil
+}
+
+// appendToPaper appends entries to the Paper document at p. Paper keeps
+// its own structure, so of the append options only the author applies;
+// the document is exported first to decide whether a blank line is needed
+// before the new entries.
+func appendToPaper(client *DropboxClient, p string, entries []string, opts appendOptions) (string, error) {
+	existing, err := client.Export(p)
+	if err != nil {
+		return "", fmt.Errorf("exporting paper doc: %w", err)
+	}
+	var b strings.Builder
+	if strings.TrimSpace(existing) != "" && !strings.HasSuffix(existing, "\n\n") {
+		b.WriteString("\n")
+	}
+	for i, entry := range entries {
+		if opts.Author != "" {
+			entry = attributeEntry(entry, opts.Author)
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(paperMarkdown(entry, p))
+	}
+	if err := client.PaperAppend(p, b.String()); err != nil {
+		return "", fmt.Errorf("appending to paper doc: %w", err)
+	}
+	invalidateTodayCache(opts.CachePath)
+	return p, nil
+}
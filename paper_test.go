@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPaperMarkdown(t *testing.T) {
+	note := "/Notes/Journal/2025/01/Note20250115.md"
+	in := "### 14:30:45\n#### Details\n![shot](../../../attachments/shot.png) and [site](https://example.com) and [top](#top)\n" +
+		"```\n#### not a heading\n[x](rel.md)\n```\n[abs](/Notes/Other%20File.md)"
+	want := "### 14:30:45\n### Details\n![shot](https://www.dropbox.com/preview/Notes/attachments/shot.png) and [site](https://example.com) and [top](#top)\n" +
+		"```\n#### not a heading\n[x](rel.md)\n```\n[abs](https://www.dropbox.com/preview/Notes/Other%20File.md)"
+	if got := paperMarkdown(in, note); got != want {
+		t.Errorf("paperMarkdown =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestValidTargetFormat(t *testing.T) {
+	for _, f := range []string{"", "markdown", "paper-md"} {
+		if err := validTargetFormat(f); err != nil {
+			t.Errorf("validTargetFormat(%q) = %v", f, err)
+		}
+	}
+	if validTargetFormat("docx") == nil {
+		t.Error("expected an error for docx")
+	}
+}
+
+// paperServer fakes the Paper endpoints for one document, which starts as
+// doc ("" for none).
+func paperServer(t *testing.T, doc *string, exists *bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg map[string]string
+		json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+		body, _ := io.ReadAll(r.Body)
+		notFound := func() {
+			w.WriteHeader(409)
+			w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+		}
+		switch r.URL.Path {
+		case "/2/files/export":
+			if arg["export_format"] != "markdown" {
+				t.Errorf("export_format = %q", arg["export_format"])
+			}
+			if !*exists {
+				notFound()
+				return
+			}
+			w.Write([]byte(*doc))
+		case "/2/files/paper/update":
+			if arg["doc_update_policy"] != "append" || arg["import_format"] != "markdown" {
+				t.Errorf("update arg = %v", arg)
+			}
+			if !*exists {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/.."}`))
+				return
+			}
+			*doc += string(body)
+			w.Write([]byte(`{"paper_revision": 2}`))
+		case "/2/files/paper/create":
+			*doc, *exists = string(body), true
+			w.Write([]byte(`{"paper_revision": 1}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAppendEntries_PaperDoc(t *testing.T) {
+	doc, exists := "", false
+	srv := paperServer(t, &doc, &exists)
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	p := "/Journal/2025-01-15.paper"
+
+	if _, err := appendToJournal(client, p, "### 09:00:00\nfirst\n", appendOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if doc != "### 09:00:00\nfirst\n" {
+		t.Errorf("created doc = %q", doc)
+	}
+	if _, err := appendToJournal(client, p, "### 10:00:00\n##### deep\n", appendOptions{Author: "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "### 09:00:00\nfirst\n\n### 10:00:00 — ann\n### deep\n"; doc != want {
+		t.Errorf("doc = %q, want %q", doc, want)
+	}
+
+	content, err := client.Download(p)
+	if err != nil || !strings.Contains(content, "deep") {
+		t.Errorf("Download of paper doc = %q, %v", content, err)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// defaultPathTemplate reproduces the built-in journal layout of resolvePath.
+const defaultPathTemplate = "/Notes/Journal/{{.Year}}/{{.Month}}/Note{{.Date}}.md"
+
+// pathFields are the values available to a path template.
+type pathFields struct {
+	Year        string // 2006
+	Month       string // 01
+	Day         string // 02
+	Date        string // 20060102
+	Weekday     string // Mon
+	WeekdayName string // Monday
+	MonthName   string // Jan
+	MonthFull   string // January
+	ISOYear     string // year the ISO week belongs to, e.g. 2025 for 2024-12-30
+	ISOWeek     string // 01-53
+	Quarter     string // 1-4
+	DayOfYear   string // 001-366
+	Time        time.Time
+}
+
+func newPathFields(now time.Time) pathFields {
+	isoYear, isoWeek := now.ISOWeek()
+	return pathFields{
+		Year:        now.Format("2006"),
+		Month:       now.Format("01"),
+		Day:         now.Format("02"),
+		Date:        now.Format("20060102"),
+		Weekday:     now.Format("Mon"),
+		WeekdayName: now.Format("Monday"),
+		MonthName:   now.Format("Jan"),
+		MonthFull:   now.Format("January"),
+		ISOYear:     strconv.Itoa(isoYear),
+		ISOWeek:     fmt.Sprintf("%02d", isoWeek),
+		Quarter:     strconv.Itoa((int(now.Month())-1)/3 + 1),
+		DayOfYear:   fmt.Sprintf("%03d", now.YearDay()),
+		Time:        now,
+	}
+}
+
+// pathFuncs are the functions available to a path template.
+var pathFuncs = template.FuncMap{
+	"ordinal": ordinal,
+}
+
+// ordinal formats n with its English ordinal suffix: 1st, 2nd, 3rd, 11th,
+// 22nd. n may be a number or a numeric string such as .Day ("05" is 5th).
+func ordinal(n interface{}) (string, error) {
+	var i int
+	switch v := n.(type) {
+	case int:
+		i = v
+	case string:
+		var err error
+		if i, err = strconv.Atoi(v); err != nil {
+			return "", fmt.Errorf("ordinal: %q is not a number", v)
+		}
+	default:
+		return "", fmt.Errorf("ordinal: unsupported type %T", n)
+	}
+	suffix := "th"
+	if i%100 < 11 || i%100 > 13 {
+		switch i % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(i) + suffix, nil
+}
+
+// renderPathTemplate resolves a Go text/template path such as
+// "/Journal/{{.Year}}/{{.Date}}.md" for the given time. An empty template
+// uses the default journal layout. For app folder apps the path is made
+// relative to the app folder; see scopeToAppFolder.
+func renderPathTemplate(tmpl string, now time.Time) (string, error) {
+	if tmpl == "" {
+		return resolvePath(now), nil
+	}
+	t, err := template.New("path").Funcs(pathFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parsing path template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, newPathFields(now)); err != nil {
+		return "", fmt.Errorf("rendering path template: %w", err)
+	}
+	return scopeToAppFolder(buf.String())
+}
+
+// journalPath resolves today's note from the -path-template flag if set,
+// falling back to the config's path_template and then the default layout.
+func journalPath(cfg *Config, flagTemplate string, now time.Time) (string, error) {
+	if flagTemplate != "" {
+		return renderPathTemplate(flagTemplate, now)
+	}
+	return renderPathTemplate(cfg.PathTemplate, now)
+}
+
+// pathFieldPatterns match what each field of a path template renders as.
+var pathFieldPatterns = map[string]string{
+	"Year":        `\d{4}`,
+	"Month":       `\d{2}`,
+	"Day":         `\d{2}`,
+	"Date":        `\d{8}`,
+	"Weekday":     `[A-Za-z]{3}`,
+	"WeekdayName": `[A-Za-z]+`,
+	"MonthName":   `[A-Za-z]{3}`,
+	"MonthFull":   `[A-Za-z]+`,
+	"ISOYear":     `\d{4}`,
+	"ISOWeek":     `\d{2}`,
+	"Quarter":     `\d`,
+	"DayOfYear":   `\d{3}`,
+}
+
+// pathMatcher reads the fields back out of paths a path template renders.
+type pathMatcher struct {
+	re     *regexp.Regexp
+	fields []string // the field each group of re captures
+	layout string   // layout of a {{.Time.Format}} field
+}
+
+var (
+	pathMatchersMu sync.Mutex
+	pathMatchers   = map[string]*pathMatcher{}
+)
+
+// templateDate returns the day whose note the path template tmpl renders
+// as p, or a continuation part of it. Only the end of p is matched, so an
+// app folder prefix doesn't matter, and case is ignored like Dropbox does.
+// It reports false for paths the template doesn't produce, and for
+// templates that don't pin down the date.
+func templateDate(tmpl, p string) (time.Time, bool) {
+	pattern := tmpl
+	if pattern == "" {
+		pattern = defaultPathTemplate
+	}
+	m := compilePathMatcher(pattern)
+	if m == nil {
+		return time.Time{}, false
+	}
+	paths := []string{p}
+	if base := continuationBase.ReplaceAllString(p, "${1}${2}"); base != p {
+		paths = append(paths, base)
+	}
+	for _, p := range paths {
+		groups := m.re.FindStringSubmatch(p)
+		if groups == nil {
+			continue
+		}
+		values := map[string]string{}
+		for i, f := range m.fields {
+			if _, ok := values[f]; !ok {
+				values[f] = groups[i+1]
+			}
+		}
+		d, ok := dateFromFields(values, m.layout)
+		if !ok {
+			continue
+		}
+		if rendered, err := renderPathTemplate(tmpl, d); err == nil && strings.EqualFold(rendered, p) {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// continuationBase matches the -n a continuation part adds to its note's
+// path; see continuationPath.
+var continuationBase = regexp.MustCompile(`^(.*)-\d+(\.[^./]+)$`)
+
+// compilePathMatcher builds the matcher for tmpl, or returns nil when the
+// template uses constructs whose output can't be matched.
+func compilePathMatcher(tmpl string) *pathMatcher {
+	pathMatchersMu.Lock()
+	defer pathMatchersMu.Unlock()
+	if m, ok := pathMatchers[tmpl]; ok {
+		return m
+	}
+	m := newPathMatcher(tmpl)
+	pathMatchers[tmpl] = m
+	return m
+}
+
+func newPathMatcher(tmpl string) *pathMatcher {
+	t, err := template.New("path").Funcs(pathFuncs).Parse(tmpl)
+	if err != nil {
+		return nil
+	}
+	m := &pathMatcher{}
+	var expr strings.Builder
+	expr.WriteString("(?i)")
+	for _, n := range t.Tree.Root.Nodes {
+		switch n := n.(type) {
+		case *parse.TextNode:
+			expr.WriteString(regexp.QuoteMeta(string(n.Text)))
+		case *parse.ActionNode:
+			field, pattern := actionPattern(n.Pipe)
+			if field == "" {
+				expr.WriteString("(?:" + pattern + ")")
+				continue
+			}
+			if field == "Time" {
+				m.layout = pattern
+				pattern = ".+?"
+			}
+			m.fields = append(m.fields, field)
+			expr.WriteString("(" + pattern + ")")
+		default:
+			return nil
+		}
+	}
+	expr.WriteString("$")
+	m.re = regexp.MustCompile(expr.String())
+	return m
+}
+
+// actionPattern returns the field an action renders and the pattern its
+// output matches: {{.Day}}, {{ordinal .Day}}, {{.Day | ordinal}} or
+// {{.Time.Format "layout"}}, for which the pattern is the layout. Other
+// actions match anything and capture no field.
+func actionPattern(pipe *parse.PipeNode) (string, string) {
+	field := func(n parse.Node) string {
+		if f, ok := n.(*parse.FieldNode); ok && len(f.Ident) == 1 {
+			if _, ok := pathFieldPatterns[f.Ident[0]]; ok {
+				return f.Ident[0]
+			}
+		}
+		return ""
+	}
+	isOrdinal := func(n parse.Node) bool {
+		id, ok := n.(*parse.IdentifierNode)
+		return ok && id.Ident == "ordinal"
+	}
+	const ordinalPattern = `\d+(?:st|nd|rd|th)`
+	cmds := pipe.Cmds
+	switch {
+	case len(cmds) == 1 && len(cmds[0].Args) == 1 && field(cmds[0].Args[0]) != "":
+		f := field(cmds[0].Args[0])
+		return f, pathFieldPatterns[f]
+	case len(cmds) == 1 && len(cmds[0].Args) == 2 && isOrdinal(cmds[0].Args[0]) && field(cmds[0].Args[1]) != "":
+		return field(cmds[0].Args[1]), ordinalPattern
+	case len(cmds) == 2 && len(cmds[0].Args) == 1 && field(cmds[0].Args[0]) != "" &&
+		len(cmds[1].Args) == 1 && isOrdinal(cmds[1].Args[0]):
+		return field(cmds[0].Args[0]), ordinalPattern
+	case len(cmds) == 1 && len(cmds[0].Args) == 2:
+		f, ok := cmds[0].Args[0].(*parse.FieldNode)
+		layout, isString := cmds[0].Args[1].(*parse.StringNode)
+		if ok && isString && len(f.Ident) == 2 && f.Ident[0] == "Time" && f.Ident[1] == "Format" {
+			return "Time", layout.Text
+		}
+	}
+	return "", ".+?"
+}
+
+// dateFromFields works out the date from the fields read from a path:
+// its Date, Time, Year with a month and Day or with DayOfYear, or ISOYear
+// with ISOWeek and a weekday.
+func dateFromFields(v map[string]string, layout string) (time.Time, bool) {
+	num := func(s string) int {
+		n, _ := strconv.Atoi(strings.TrimRight(s, "stndrh"))
+		return n
+	}
+	parse := func(layout, s string) (time.Time, bool) {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		return t, err == nil
+	}
+	if s, ok := v["Date"]; ok {
+		return parse("20060102", s)
+	}
+	if s, ok := v["Time"]; ok {
+		if t, ok := parse(layout, s); ok && t.Year() > 0 {
+			return t, true
+		}
+	}
+	var month time.Month
+	for f, layout := range map[string]string{"Month": "01", "MonthName": "Jan", "MonthFull": "January"} {
+		if s, ok := v[f]; ok {
+			if t, ok := parse(layout, s); ok {
+				month = t.Month()
+			}
+		}
+	}
+	year, hasYear := v["Year"]
+	switch {
+	case hasYear && month != 0 && v["Day"] != "":
+		return time.Date(num(year), month, num(v["Day"]), 0, 0, 0, 0, time.Local), true
+	case hasYear && v["DayOfYear"] != "":
+		return time.Date(num(year), 1, num(v["DayOfYear"]), 0, 0, 0, 0, time.Local), true
+	case v["ISOYear"] != "" && v["ISOWeek"] != "":
+		name := v["Weekday"]
+		if name == "" {
+			name = v["WeekdayName"]
+		}
+		weekday := -1
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(name, d.String()) || strings.EqualFold(name, d.String()[:3]) {
+				weekday = int(d)
+			}
+		}
+		if weekday < 0 {
+			return time.Time{}, false
+		}
+		// Week 1 is the one with January 4th in it; weeks start on Monday.
+		jan4 := time.Date(num(v["ISOYear"]), 1, 4, 0, 0, 0, 0, time.Local)
+		monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+		return monday.AddDate(0, 0, (num(v["ISOWeek"])-1)*7+(weekday+6)%7), true
+	}
+	return time.Time{}, false
+}
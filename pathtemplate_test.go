@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderPathTemplate(t *testing.T) {
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	got, err := renderPathTemplate("/Logs/{{.Year}}/{{.Month}}-{{.Day}}.md", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "/Logs/2025/01-15.md" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRenderPathTemplate_DefaultMatchesResolvePath(t *testing.T) {
+	now := time.Date(2025, 12, 3, 9, 0, 0, 0, time.UTC)
+	got, err := renderPathTemplate(defaultPathTemplate, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != resolvePath(now) {
+		t.Errorf("default template %q differs from resolvePath %q", got, resolvePath(now))
+	}
+	if empty, _ := renderPathTemplate("", now); empty != resolvePath(now) {
+		t.Errorf("empty template should use resolvePath, got %q", empty)
+	}
+}
+
+func TestRenderPathTemplate_Invalid(t *testing.T) {
+	now := time.Now()
+	if _, err := renderPathTemplate("/{{.Year", now); err == nil {
+		t.Error("expected parse error")
+	}
+	if _, err := renderPathTemplate("/{{.Nope}}.md", now); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+func TestJournalPath_FlagOverridesConfig(t *testing.T) {
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	cfg := &Config{PathTemplate: "/cfg/{{.Date}}.md"}
+
+	got, _ := journalPath(cfg, "", now)
+	if got != "/cfg/20250115.md" {
+		t.Errorf("expected config template, got %q", got)
+	}
+	got, _ = journalPath(cfg, "/flag/{{.Date}}.md", now)
+	if got != "/flag/20250115.md" {
+		t.Errorf("expected flag template, got %q", got)
+	}
+}
+
+func TestRenderPathTemplate_DerivedFields(t *testing.T) {
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	got, err := renderPathTemplate("/Journal/{{.Year}}/Q{{.Quarter}}/W{{.ISOWeek}}/{{.Weekday}}-{{.MonthName}}-{{.Day}}.md", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "/Journal/2025/Q1/W03/Wed-Jan-15.md" {
+		t.Errorf("got %q", got)
+	}
+
+	got, _ = renderPathTemplate("/{{.WeekdayName}} {{.MonthFull}} {{ordinal .Day}}, day {{.DayOfYear}}.md", now)
+	if got != "/Wednesday January 15th, day 015.md" {
+		t.Errorf("got %q", got)
+	}
+
+	// ISO week 1 of 2025 starts on Monday 2024-12-30.
+	got, _ = renderPathTemplate("/{{.ISOYear}}-W{{.ISOWeek}}/Q{{.Quarter}}.md", time.Date(2024, 12, 30, 9, 0, 0, 0, time.UTC))
+	if got != "/2025-W01/Q4.md" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	cases := map[interface{}]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th",
+		21: "21st", 22: "22nd", 101: "101st", 111: "111th", "05": "5th", "23": "23rd"}
+	for in, want := range cases {
+		if got, err := ordinal(in); err != nil || got != want {
+			t.Errorf("ordinal(%v) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ordinal("x"); err == nil {
+		t.Error("expected error for a non-numeric string")
+	}
+}
+
+func TestTemplateDate(t *testing.T) {
+	cases := []struct{ tmpl, path, want string }{
+		{"", "/Notes/Journal/2025/01/Note20250115.md", "2025-01-15"},
+		{"", "/notes/journal/2025/01/Note20250115-2.md", "2025-01-15"},
+		{"/Daily/{{.Year}}/{{.Weekday}}-{{.MonthName}}-{{.Day}}.md", "/Daily/2025/Wed-Jan-15.md", "2025-01-15"},
+		{"/Daily/{{.Year}}/{{.MonthFull}} {{ordinal .Day}}.md", "/Daily/2025/January 2nd.md", "2025-01-02"},
+		{"/Daily/{{.Year}}/{{.MonthFull}} {{.Day | ordinal}}.md", "/Daily/2025/March 3rd.md", "2025-03-03"},
+		{"/Log/{{.Year}}/day {{.DayOfYear}}.md", "/Log/2024/day 366.md", "2024-12-31"},
+		{"/Weekly/{{.ISOYear}}-W{{.ISOWeek}}/{{.WeekdayName}}.md", "/Weekly/2025-W01/Monday.md", "2024-12-30"},
+		{`/J/{{.Time.Format "2006/Jan/02"}}.md`, "/J/2025/Jan/15.md", "2025-01-15"},
+	}
+	for _, c := range cases {
+		d, ok := templateDate(c.tmpl, c.path)
+		if !ok || d.Format("2006-01-02") != c.want {
+			t.Errorf("templateDate(%q, %q) = %v, %v; want %s", c.tmpl, c.path, d, ok, c.want)
+		}
+	}
+
+	for _, c := range []struct{ tmpl, path string }{
+		{"", "/Notes/Ideas.md"},
+		{"", "/Other/Notes/Journal/2025/01/Note20250115.md"},
+		{"/Daily/{{.Year}}/{{.Weekday}}-{{.MonthName}}-{{.Day}}.md", "/Daily/2025/Thu-Jan-15.md"},
+		{"/Daily/{{.MonthName}}-{{.Day}}.md", "/Daily/Jan-15.md"},
+	} {
+		if d, ok := templateDate(c.tmpl, c.path); ok {
+			t.Errorf("templateDate(%q, %q) = %v; want no date", c.tmpl, c.path, d)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// crockford is the base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ObsidianConfig locates the Obsidian vault the notes live in.
+type ObsidianConfig struct {
+	// Vault is the vault's name as Obsidian shows it.
+	Vault string `json:"vault"`
+	// Root is the Dropbox folder the vault is synced to, e.g.
+	// /Apps/Obsidian/Journal; note paths are made relative to it.
+	Root string `json:"root,omitempty"`
+}
+
+// newULID returns a ULID for t: 48 bits of milliseconds and 80 random bits
+// from r, in 26 characters of Crockford base32. ULIDs sort by time, so
+// entry IDs do too.
+func newULID(t time.Time, r io.Reader) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	io.ReadFull(r, b[6:])
+	n := new(big.Int).SetBytes(b[:])
+	out := make([]byte, 26)
+	mask := big.NewInt(31)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 5)
+	}
+	return string(out)
+}
+
+// findEntryByID returns the indexed entry whose anchor ID is id, or starts
+// with it when that is unambiguous, so a ULID can be shortened.
+func findEntryByID(entries []indexEntry, id string) (indexEntry, error) {
+	var found []indexEntry
+	for _, e := range entries {
+		if e.ID == "" {
+			continue
+		}
+		if strings.EqualFold(e.ID, id) {
+			return e, nil
+		}
+		if strings.HasPrefix(strings.ToUpper(e.ID), strings.ToUpper(id)) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return indexEntry{}, fmt.Errorf("no entry %s in the local index; run: dropbox-appender reindex", id)
+	case 1:
+		return found[0], nil
+	}
+	return indexEntry{}, fmt.Errorf("%d entries start with %s; give more of the ID", len(found), id)
+}
+
+// dropboxWebURL returns the Dropbox website's preview of the file at p.
+func dropboxWebURL(p string) string {
+	dir := (&url.URL{Path: path.Dir(p)}).EscapedPath()
+	if dir == "/" {
+		dir = ""
+	}
+	return "https://www.dropbox.com/home" + dir + "?preview=" + url.QueryEscape(path.Base(p))
+}
+
+// obsidianURL returns the obsidian:// URI opening the note at p in the
+// vault, at heading when it isn't empty.
+func obsidianURL(o ObsidianConfig, p, heading string) (string, error) {
+	rel := p
+	if o.Root != "" {
+		root := strings.TrimSuffix(o.Root, "/") + "/"
+		if !strings.HasPrefix(strings.ToLower(p), strings.ToLower(root)) {
+			return "", fmt.Errorf("%s is outside the Obsidian vault root %s", p, o.Root)
+		}
+		rel = p[len(root):]
+	}
+	file := strings.TrimSuffix(strings.TrimPrefix(rel, "/"), ".md")
+	if heading != "" {
+		file += "#" + heading
+	}
+	q := url.Values{"vault": {o.Vault}, "file": {file}}
+	return "obsidian://open?" + strings.ReplaceAll(q.Encode(), "+", "%20"), nil
+}
+
+// entryHeading returns the text of an indexed entry's ### header, which
+// Obsidian links to.
+func entryHeading(e indexEntry) string {
+	h := e.Time
+	if e.Number > 0 {
+		h = fmt.Sprintf("%d. %s", e.Number, h)
+	}
+	if e.Author != "" {
+		h += " — " + e.Author
+	}
+	return h
+}
+
+// runOpen implements the `dropbox-appender open` subcommand, which opens
+// today's note, another day's, or the note holding an entry, on the
+// Dropbox website or in Obsidian. It returns the process exit code.
+func runOpen(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("open", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	date := fs.String("date", "", "open the note of this day (YYYY-MM-DD) instead of today's")
+	entry := fs.String("entry", "", "open the note holding the entry with this ID, or a unique prefix of it")
+	app := fs.String("app", "", "dropbox or obsidian (default obsidian when configured, else dropbox)")
+	printOnly := fs.Bool("print", false, "only print the URL")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *date != "" && *entry != "" {
+		fmt.Fprintln(stderr, "-date can't be combined with -entry")
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if *app == "" {
+		*app = "dropbox"
+		if cfg.Obsidian != nil {
+			*app = "obsidian"
+		}
+	}
+	if *app != "dropbox" && *app != "obsidian" {
+		fmt.Fprintf(stderr, "invalid -app %q: expected dropbox or obsidian\n", *app)
+		return 2
+	}
+	if *app == "obsidian" && (cfg.Obsidian == nil || cfg.Obsidian.Vault == "") {
+		fmt.Fprintln(stderr, "-app obsidian needs the obsidian.vault config")
+		return 2
+	}
+
+	var notePath, heading string
+	switch {
+	case *entry != "":
+		entries, ok := loadIndexedEntries(stderr)
+		if !ok {
+			return 1
+		}
+		e, err := findEntryByID(entries, *entry)
+		if err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		notePath, heading = e.Path, entryHeading(e)
+	default:
+		day := clock.Now()
+		if *date != "" {
+			if day, err = time.ParseInLocation("2006-01-02", *date, day.Location()); err != nil {
+				fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+				return 2
+			}
+		}
+		if notePath, err = journalPath(cfg, "", day); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+	}
+
+	u := dropboxWebURL(dropboxPath(notePath))
+	if *app == "obsidian" {
+		vault := *cfg.Obsidian
+		if root, err := scopeToAppFolder(vault.Root); err == nil && root != "" {
+			vault.Root = dropboxPath(root)
+		}
+		if u, err = obsidianURL(vault, dropboxPath(notePath), heading); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+	}
+	fmt.Fprintln(stdout, u)
+	if *printOnly {
+		return 0
+	}
+	if err := openBrowser(u); err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), fmt.Errorf("opening the URL: %w", err))
+		return 1
+	}
+	return 0
+}
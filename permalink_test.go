@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewULID(t *testing.T) {
+	// The example from the ULID spec's timestamp.
+	got := newULID(time.UnixMilli(1469918176385), bytes.NewReader(make([]byte, 10)))
+	if got != "01ARYZ6S410000000000000000" {
+		t.Errorf("got %q", got)
+	}
+	a := newULID(time.UnixMilli(1000), rand.Reader)
+	b := newULID(time.UnixMilli(2000), rand.Reader)
+	if len(a) != 26 || a >= b {
+		t.Errorf("ULIDs should be 26 characters and sort by time: %q, %q", a, b)
+	}
+}
+
+func TestAppendToJournal_EntryIDs(t *testing.T) {
+	path := "/Journal/20250115.md"
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", appendOptions{EntryIDs: true}); err != nil {
+		t.Fatal(err)
+	}
+	blocks := parseJournal(files[path]).Blocks
+	if len(blocks) != 1 || len(blocks[0].ID) != 26 || blocks[0].Text != "afternoon" {
+		t.Errorf("unexpected note: %q", files[path])
+	}
+}
+
+func TestFindEntryByID(t *testing.T) {
+	entries := []indexEntry{
+		{ID: "01ARYZ6S41AAAA", Path: "/a.md"},
+		{ID: "01ARYZ6S41BBBB", Path: "/b.md"},
+		{Path: "/c.md"},
+	}
+	if e, err := findEntryByID(entries, "01aryz6s41b"); err != nil || e.Path != "/b.md" {
+		t.Errorf("prefix: got %+v, %v", e, err)
+	}
+	if _, err := findEntryByID(entries, "01ARYZ"); err == nil {
+		t.Error("expected an error for an ambiguous prefix")
+	}
+	if _, err := findEntryByID(entries, "nope"); err == nil {
+		t.Error("expected an error for an unknown ID")
+	}
+}
+
+func TestNoteURLs(t *testing.T) {
+	if got := dropboxWebURL("/Notes/Journal 2025/Note20250115.md"); got != "https://www.dropbox.com/home/Notes/Journal%202025?preview=Note20250115.md" {
+		t.Errorf("dropbox: got %q", got)
+	}
+	o := ObsidianConfig{Vault: "My Vault", Root: "/Apps/Obsidian/My Vault"}
+	got, err := obsidianURL(o, "/Apps/Obsidian/My Vault/Daily/2025-01-15.md", "3. 14:30:45")
+	if want := "obsidian://open?file=Daily%2F2025-01-15%233.%2014%3A30%3A45&vault=My%20Vault"; err != nil || got != want {
+		t.Errorf("obsidian: got %q, %v, want %q", got, err, want)
+	}
+	if _, err := obsidianURL(o, "/Notes/a.md", ""); err == nil {
+		t.Error("expected an error for a note outside the vault")
+	}
+}
+
+func TestRunOpen_Print(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	cfgPath := filepath.Join(home, ".config", "dropbox-appender", "config.json")
+	os.MkdirAll(filepath.Dir(cfgPath), 0o700)
+	os.WriteFile(cfgPath, []byte(`{"path_template": "/Daily/{{.Date}}.md", "obsidian": {"vault": "Notes", "root": "/Daily"}}`), 0o600)
+
+	idx, _ := loadIndex(defaultIndexPath())
+	idx.update("/Daily/20250114.md", "### 09:00:00\n<a id=\"01JHAAAA\"></a>\nstandup\n", "r1", time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC))
+	idx.save(defaultIndexPath())
+
+	clock := fixedClock(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-print"}, "obsidian://open?file=20250115&vault=Notes\n"},
+		{[]string{"-print", "-app", "dropbox", "-date", "2025-01-10"}, "https://www.dropbox.com/home/Daily?preview=20250110.md\n"},
+		{[]string{"-print", "-entry", "01jha"}, "obsidian://open?file=20250114%2309%3A00%3A00&vault=Notes\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runOpen(tt.args, nil, &stdout, &stderr, clock); code != 0 {
+			t.Fatalf("%v: exit %d: %s", tt.args, code, stderr.String())
+		}
+		if stdout.String() != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, stdout.String(), tt.want)
+		}
+	}
+
+	var stderr bytes.Buffer
+	if code := runOpen([]string{"-date", "x", "-entry", "y"}, nil, &bytes.Buffer{}, &stderr, clock); code != 2 || !strings.Contains(stderr.String(), "can't be combined") {
+		t.Errorf("exit %d: %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// plainOutput is set by -plain (or DROPBOX_APPENDER_PLAIN=1): no colors,
+// no redrawn progress bars, and diagnostics on stderr start with ERROR: or
+// WARNING:, so output reads well with a screen reader and is easy to match
+// in scripts.
+var plainOutput bool
+
+// plainPrefixes map the starts of diagnostic lines to their plain prefix.
+var plainPrefixes = []struct{ from, to string }{
+	{"error loading config: ", "ERROR: loading config: "},
+	{"error: ", "ERROR: "},
+	{"Error: ", "ERROR: "},
+	{"warning: ", "WARNING: "},
+	{"Warning: ", "WARNING: "},
+}
+
+// colorEnabled reports whether output may use ANSI colors: not with -plain,
+// nor when NO_COLOR is set (https://no-color.org).
+func colorEnabled() bool {
+	return !plainOutput && os.Getenv("NO_COLOR") == ""
+}
+
+// okFormat prefixes a success message's format with OK: in plain mode.
+func okFormat(format string) string {
+	if plainOutput {
+		return "OK: " + format
+	}
+	return format
+}
+
+// plainWriter rewrites the start of each diagnostic line written through it
+// to the plain prefixes. Lines are rewritten as they pass, without
+// buffering, so nothing is lost when the process exits.
+type plainWriter struct {
+	w         io.Writer
+	lineStart bool
+}
+
+func newPlainWriter(w io.Writer) *plainWriter {
+	return &plainWriter{w: w, lineStart: true}
+}
+
+func (p *plainWriter) Write(b []byte) (int, error) {
+	var out bytes.Buffer
+	for rest := b; len(rest) > 0; {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+		if p.lineStart {
+			for _, pre := range plainPrefixes {
+				if bytes.HasPrefix(line, []byte(pre.from)) {
+					line = append([]byte(pre.to), line[len(pre.from):]...)
+					break
+				}
+			}
+		}
+		out.Write(line)
+		p.lineStart = line[len(line)-1] == '\n'
+	}
+	if _, err := p.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
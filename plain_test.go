@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestPlainWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newPlainWriter(&buf)
+	fmt.Fprintf(w, "error: uploading journal: %v\n", "boom")
+	fmt.Fprint(w, "warning: git mirror")
+	fmt.Fprint(w, " failed; error: not a prefix here\n")
+	fmt.Fprint(w, "error loading config: bad json\nSaved /a.png\n")
+	want := "ERROR: uploading journal: boom\n" +
+		"WARNING: git mirror failed; error: not a prefix here\n" +
+		"ERROR: loading config: bad json\nSaved /a.png\n"
+	if buf.String() != want {
+		t.Errorf("plain output:\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if !colorEnabled() {
+		t.Error("colors disabled by default")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled() {
+		t.Error("colors enabled with NO_COLOR")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPluginRuntime runs a WASI module with no access to files,
+	// network or environment unless the runtime is told otherwise.
+	defaultPluginRuntime = "wasmtime run"
+	// defaultPluginTimeout bounds how long one plugin may run.
+	defaultPluginTimeout = 5 * time.Second
+)
+
+// PluginConfig is one entry of the "plugins" config list: a WebAssembly
+// (WASI) module that transforms each entry before it is appended.
+//
+// The module is run by an external runtime rather than embedded, keeping
+// the tool free of dependencies. Runtime is a command line run through sh
+// with the module path in $MODULE (appended as the last argument when not
+// used), e.g. "wasmtime run", "wazero run" or "wasmer run".
+type PluginConfig struct {
+	Module  string `json:"module"`
+	Runtime string `json:"runtime,omitempty"` // default "wasmtime run"
+	Timeout string `json:"timeout,omitempty"` // default 5s
+}
+
+// pluginInput is the JSON a plugin reads from stdin. It writes the
+// transformed entry text to stdout; a non-zero exit rejects the entry.
+type pluginInput struct {
+	Entry string `json:"entry"`
+	Path  string `json:"path"` // the note the entry is for
+	Date  string `json:"date"` // YYYY-MM-DD
+	Time  string `json:"time"` // HH:MM:SS
+}
+
+// runPlugin passes in to the plugin and returns its trimmed output.
+func runPlugin(p PluginConfig, in pluginInput) (string, error) {
+	timeout := defaultPluginTimeout
+	if p.Timeout != "" {
+		d, err := time.ParseDuration(p.Timeout)
+		if err != nil {
+			return "", fmt.Errorf("plugin %s: invalid timeout %q: %w", p.Module, p.Timeout, err)
+		}
+		timeout = d
+	}
+	runtime := p.Runtime
+	if runtime == "" {
+		runtime = defaultPluginRuntime
+	}
+	if !strings.Contains(runtime, "$MODULE") {
+		runtime += ` "$MODULE"`
+	}
+	input, err := json.Marshal(in)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", runtime)
+	cmd.Env = append(os.Environ(), "MODULE="+expandHome(p.Module))
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("plugin %s: timed out after %v", p.Module, timeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("plugin %s failed: %w: %s", p.Module, err, strings.TrimSpace(stderr.String()))
+	}
+	text := strings.TrimSpace(string(out))
+	if text == "" {
+		return "", fmt.Errorf("plugin %s returned an empty entry", p.Module)
+	}
+	return text, nil
+}
+
+// applyPlugins runs entry through each plugin in turn, each seeing the
+// previous one's output.
+func applyPlugins(plugins []PluginConfig, entry, notePath string, now time.Time) (string, error) {
+	for _, p := range plugins {
+		in := pluginInput{Entry: entry, Path: notePath, Date: now.Format("2006-01-02"), Time: now.Format("15:04:05")}
+		out, err := runPlugin(p, in)
+		if err != nil {
+			return "", err
+		}
+		entry = out
+	}
+	return entry, nil
+}
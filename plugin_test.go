@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRuntime writes a shell script standing in for a WASM runtime: it
+// upper-cases the entry from the JSON on stdin and reports the module and
+// note path it was given.
+func fakeRuntime(t *testing.T) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "runtime.sh")
+	body := `input=$(cat)
+entry=$(printf '%s' "$input" | sed 's/.*"entry":"\([^"]*\)".*/\1/' | tr a-z A-Z)
+path=$(printf '%s' "$input" | sed 's/.*"path":"\([^"]*\)".*/\1/')
+printf '%s (%s via %s)\n' "$entry" "$path" "$(basename "$1")"
+`
+	if err := os.WriteFile(script, []byte(body), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return "sh " + script
+}
+
+func TestApplyPlugins(t *testing.T) {
+	requireShell(t)
+	runtime := fakeRuntime(t)
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+
+	got, err := applyPlugins([]PluginConfig{{Module: "/plugins/upper.wasm", Runtime: runtime}}, "hello", "/J/2025-01-15.md", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "HELLO (/J/2025-01-15.md via upper.wasm)" {
+		t.Errorf("got %q", got)
+	}
+
+	// Plugins chain, each seeing the previous output.
+	chain := []PluginConfig{{Module: "a.wasm", Runtime: runtime}, {Module: "b.wasm", Runtime: runtime}}
+	got, err = applyPlugins(chain, "hi", "/J/x.md", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "HI (/J/X.MD VIA A.WASM) (/J/x.md via b.wasm)" {
+		t.Errorf("chained got %q", got)
+	}
+
+	if got, err := applyPlugins(nil, "as is", "/J/x.md", now); err != nil || got != "as is" {
+		t.Errorf("no plugins: %q, %v", got, err)
+	}
+}
+
+func TestRunPlugin_Errors(t *testing.T) {
+	requireShell(t)
+	in := pluginInput{Entry: "x"}
+	cases := []struct {
+		name    string
+		plugin  PluginConfig
+		wantErr string
+	}{
+		{"failure", PluginConfig{Module: "m.wasm", Runtime: "echo rejected >&2; exit 3 #"}, "rejected"},
+		{"empty output", PluginConfig{Module: "m.wasm", Runtime: "true"}, "empty entry"},
+		{"timeout", PluginConfig{Module: "m.wasm", Runtime: "exec sleep 5 #", Timeout: "50ms"}, "timed out"},
+		{"bad timeout", PluginConfig{Module: "m.wasm", Runtime: "cat", Timeout: "soon"}, "invalid timeout"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := runPlugin(c.plugin, in)
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pomoPattern matches the first line of a pomodoro marker entry, e.g.
+// "🍅 pomo start: write report", capturing the kind and the task.
+var pomoPattern = regexp.MustCompile(`^(?:\S+ )?pomo (start|break|done)(?::\s*(.*?))?\s*$`)
+
+// pomoIcons prefix the marker entries.
+var pomoIcons = map[string]string{"start": "🍅", "break": "☕", "done": "✅"}
+
+// pomoMark is a pomodoro marker entry.
+type pomoMark struct {
+	Kind string // start, break or done
+	Task string
+	At   time.Time
+}
+
+// parsePomoMark returns the marker in an entry's text, if it is one. A done
+// marker's task excludes its tally.
+func parsePomoMark(text string, at time.Time) (pomoMark, bool) {
+	first, _, _ := strings.Cut(text, "\n")
+	m := pomoPattern.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return pomoMark{}, false
+	}
+	task, _, _ := strings.Cut(m[2], " — ")
+	return pomoMark{Kind: m[1], Task: task, At: at}, true
+}
+
+// tallyPomodoros counts the pomodoros in marks: each start closed by a
+// break or done is one, and the time between them is focus time. A start
+// that is followed by another start was abandoned and doesn't count. task
+// is the task of the last start.
+func tallyPomodoros(marks []pomoMark) (count int, focus time.Duration, task string) {
+	var open time.Time
+	for _, m := range marks {
+		switch m.Kind {
+		case "start":
+			open = m.At
+			if m.Task != "" {
+				task = m.Task
+			}
+		case "break", "done":
+			if !open.IsZero() {
+				count++
+				focus += m.At.Sub(open)
+				open = time.Time{}
+			}
+		}
+	}
+	return count, focus, task
+}
+
+// currentSession returns the marks of the session in progress: those after
+// the last done.
+func currentSession(marks []pomoMark) []pomoMark {
+	for i := len(marks) - 1; i >= 0; i-- {
+		if marks[i].Kind == "done" {
+			return marks[i+1:]
+		}
+	}
+	return marks
+}
+
+// noteMarks returns the pomodoro markers in note content, dated on day.
+func noteMarks(content string, day time.Time) []pomoMark {
+	var marks []pomoMark
+	for _, e := range parseEntries(content) {
+		if m, ok := parsePomoMark(e.Text, entryTime(day, e.Time)); ok {
+			marks = append(marks, m)
+		}
+	}
+	return marks
+}
+
+// entryTime combines day's date with an entry header time (HH:MM:SS or
+// HH:MM).
+func entryTime(day time.Time, clock string) time.Time {
+	t, err := time.Parse("15:04:05", clock)
+	if err != nil {
+		t, _ = time.Parse("15:04", clock)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, day.Location())
+}
+
+// formatFocus formats d in hours and minutes, e.g. "1h15m" or "25m".
+func formatFocus(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// pomoText returns the text of a marker entry. For done it ends with the
+// session's tally.
+func pomoText(kind, task string, count int, focus time.Duration) string {
+	text := pomoIcons[kind] + " pomo " + kind
+	if task != "" {
+		text += ": " + task
+	}
+	if kind == "done" {
+		plural := "s"
+		if count == 1 {
+			plural = ""
+		}
+		sep := " — "
+		if task == "" {
+			sep = ": "
+		}
+		text += fmt.Sprintf("%s%d pomodoro%s, %s focused", sep, count, plural, formatFocus(focus))
+	}
+	return text
+}
+
+// runPomo implements `dropbox-appender pomo start|break|done [task]`, which
+// appends pomodoro markers to today's note; done closes the session with a
+// tally of its pomodoros. It returns the process exit code.
+func runPomo(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("pomo", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	kind := fs.Arg(0)
+	if _, ok := pomoIcons[kind]; !ok {
+		fmt.Fprintln(stderr, "usage: dropbox-appender pomo start|break|done [task]")
+		return 2
+	}
+	task := strings.TrimSpace(strings.Join(fs.Args()[1:], " "))
+	if kind == "start" && task == "" {
+		fmt.Fprintln(stderr, "usage: dropbox-appender pomo start <task>")
+		return 2
+	}
+
+	cfg, client, ok := loadClient(stderr, true)
+	if !ok {
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	return runPomoWithClient(stdout, stderr, client, cfg, opts, clock.Now(), kind, task)
+}
+
+// runPomoWithClient appends the kind marker for task at now.
+func runPomoWithClient(stdout, stderr io.Writer, client *DropboxClient, cfg *Config, opts appendOptions,
+	now time.Time, kind, task string) int {
+
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	var count int
+	var focus time.Duration
+	if kind == "done" {
+		content, err := client.Download(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: downloading journal: %v\n", err)
+			return 1
+		}
+		session := append(currentSession(noteMarks(content, now)), pomoMark{Kind: "done", At: now})
+		var started string
+		count, focus, started = tallyPomodoros(session)
+		if count == 0 {
+			fmt.Fprintln(stderr, "error: no pomodoro in progress; begin one with `dropbox-appender pomo start <task>`")
+			return 1
+		}
+		if task == "" {
+			task = started
+		}
+	}
+
+	text := pomoText(kind, task, count, focus)
+	written, err := appendToJournal(client, path, formatEntry(now, text, false), opts)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s (%s)\n", text, written)
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePomoMark(t *testing.T) {
+	at := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		text     string
+		wantOK   bool
+		wantKind string
+		wantTask string
+	}{
+		{"🍅 pomo start: write report", true, "start", "write report"},
+		{"☕ pomo break", true, "break", ""},
+		{"✅ pomo done: write report — 2 pomodoros, 50m focused", true, "done", "write report"},
+		{"pomo start: typed by hand\nmore text", true, "start", "typed by hand"},
+		{"talked about the pomo start time", false, "", ""},
+	}
+	for _, tt := range tests {
+		m, ok := parsePomoMark(tt.text, at)
+		if ok != tt.wantOK || m.Kind != tt.wantKind || m.Task != tt.wantTask {
+			t.Errorf("parsePomoMark(%q) = %+v, %v", tt.text, m, ok)
+		}
+	}
+}
+
+func TestTallyPomodoros(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2025, 1, 15, h, m, 0, 0, time.UTC) }
+	marks := []pomoMark{
+		{"start", "abandoned", at(8, 0)},
+		{"start", "report", at(9, 0)},
+		{"break", "", at(9, 25)},
+		{"break", "", at(9, 30)}, // a second break closes nothing
+		{"start", "", at(9, 35)},
+		{"done", "", at(10, 0)},
+	}
+	count, focus, task := tallyPomodoros(marks)
+	if count != 2 || focus != 50*time.Minute || task != "report" {
+		t.Errorf("tally = %d, %v, %q", count, focus, task)
+	}
+	if got := currentSession(append(marks, pomoMark{"start", "next", at(11, 0)})); len(got) != 1 || got[0].Task != "next" {
+		t.Errorf("currentSession = %+v", got)
+	}
+}
+
+func TestPomoText(t *testing.T) {
+	if got := pomoText("start", "report", 0, 0); got != "🍅 pomo start: report" {
+		t.Errorf("start = %q", got)
+	}
+	if got := pomoText("done", "report", 1, 25*time.Minute); got != "✅ pomo done: report — 1 pomodoro, 25m focused" {
+		t.Errorf("done = %q", got)
+	}
+	if got := formatFocus(95 * time.Minute); got != "1h35m" {
+		t.Errorf("formatFocus = %q", got)
+	}
+}
+
+func TestRunPomoWithClient(t *testing.T) {
+	files := map[string]string{}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	cfg := &Config{PathTemplate: "/Journal/{{.Date}}.md"}
+	at := func(h, m int) time.Time { return time.Date(2025, 1, 15, h, m, 0, 0, time.Local) }
+
+	var stdout, stderr bytes.Buffer
+	if code := runPomoWithClient(&stdout, &stderr, client, cfg, appendOptions{}, at(9, 0), "done", ""); code != 1 {
+		t.Errorf("done without a session: exit %d", code)
+	}
+	steps := []struct {
+		at   time.Time
+		kind string
+		task string
+	}{
+		{at(9, 0), "start", "write report"},
+		{at(9, 25), "break", ""},
+		{at(9, 30), "start", "write report"},
+		{at(9, 55), "done", ""},
+	}
+	for _, s := range steps {
+		if code := runPomoWithClient(&stdout, &stderr, client, cfg, appendOptions{}, s.at, s.kind, s.task); code != 0 {
+			t.Fatalf("%s: exit %d: %s", s.kind, code, stderr.String())
+		}
+	}
+	note := files["/Journal/20250115.md"]
+	if !strings.HasSuffix(note, "### 09:55:00\n✅ pomo done: write report — 2 pomodoros, 50m focused\n") {
+		t.Errorf("note = %q", note)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// selectedProfile is set by -profile (or DROPBOX_APPENDER_PROFILE) and
+// overrides the profile rules.
+var selectedProfile string
+
+// ProfileRule selects a profile when every condition it sets matches this
+// machine. Conditions are glob patterns.
+type ProfileRule struct {
+	Profile  string `json:"profile"`
+	Hostname string `json:"hostname,omitempty"`
+	// SSID matches the Wi-Fi network currently joined.
+	SSID string `json:"ssid,omitempty"`
+	// Interface matches the name of a network interface that is up, e.g.
+	// "wg*" or "tun0" to tell whether a VPN is connected.
+	Interface string `json:"interface,omitempty"`
+}
+
+// machineEnv is what profile rules are matched against. SSID and interfaces
+// are looked up only when a rule asks for them.
+type machineEnv struct {
+	hostname   func() string
+	ssid       func() string
+	interfaces func() []string
+}
+
+// currentEnv returns the environment of this machine.
+func currentEnv() machineEnv {
+	return machineEnv{
+		hostname: func() string {
+			h, _ := os.Hostname()
+			return h
+		},
+		ssid:       currentSSID,
+		interfaces: upInterfaces,
+	}
+}
+
+// matches reports whether every condition of r holds in env.
+func (r ProfileRule) matches(env machineEnv) bool {
+	if r.Hostname == "" && r.SSID == "" && r.Interface == "" {
+		return false
+	}
+	if r.Hostname != "" && !globMatch(r.Hostname, env.hostname()) {
+		return false
+	}
+	if r.SSID != "" && !globMatch(r.SSID, env.ssid()) {
+		return false
+	}
+	if r.Interface != "" {
+		up := false
+		for _, name := range env.interfaces() {
+			if globMatch(r.Interface, name) {
+				up = true
+				break
+			}
+		}
+		if !up {
+			return false
+		}
+	}
+	return true
+}
+
+// globMatch matches s against the glob pattern, ignoring case.
+func globMatch(pattern, s string) bool {
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(s))
+	return ok && s != ""
+}
+
+// selectProfile returns the profile to use and why: the one selected
+// explicitly, else that of the first matching rule, else none.
+func selectProfile(explicit string, rules []ProfileRule, env machineEnv) (string, string) {
+	if explicit != "" {
+		return explicit, "selected with -profile"
+	}
+	for i, r := range rules {
+		if r.matches(env) {
+			return r.Profile, fmt.Sprintf("profile_rules[%d] matched", i)
+		}
+	}
+	return "", ""
+}
+
+// applyProfile overlays the settings of the selected profile on cfg. A
+// profile holds config keys, which replace those of the file.
+func applyProfile(cfg *Config, env machineEnv) error {
+	name, _ := selectProfile(selectedProfile, cfg.ProfileRules, env)
+	if name == "" {
+		return nil
+	}
+	raw, ok := cfg.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	cfg.profile = name
+	return nil
+}
+
+// applyDefaultFlags parses defaults, the flags config of the selected
+// profile or the top level, into fs, skipping flags already set on the
+// command line so those win. Defaults set this way count as set, like
+// flags given on the command line.
+func applyDefaultFlags(fs *flag.FlagSet, defaults []string) error {
+	if len(defaults) == 0 {
+		return nil
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	d := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	d.SetOutput(io.Discard)
+	fs.VisitAll(func(f *flag.Flag) {
+		d.Var(defaultFlag{f.Value, fs, f.Name, explicit[f.Name]}, f.Name, f.Usage)
+	})
+	if err := d.Parse(defaults); err != nil {
+		return fmt.Errorf("flags config: %w", err)
+	}
+	if d.NArg() > 0 {
+		return fmt.Errorf("flags config: %q is not a flag", d.Arg(0))
+	}
+	return nil
+}
+
+// defaultFlag sets a flag of fs from the flags config, unless it was set
+// on the command line.
+type defaultFlag struct {
+	flag.Value
+	fs       *flag.FlagSet
+	name     string
+	explicit bool
+}
+
+func (f defaultFlag) Set(s string) error {
+	if f.explicit {
+		return nil
+	}
+	return f.fs.Set(f.name, s)
+}
+
+// IsBoolFlag lets boolean flags be given without a value.
+func (f defaultFlag) IsBoolFlag() bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// currentSSID returns the Wi-Fi network joined, or "" when there is none
+// or it can't be told.
+func currentSSID() string {
+	var lines []string
+	switch runtime.GOOS {
+	case "darwin":
+		lines = commandLines("networksetup", "-getairportnetwork", "en0")
+		if len(lines) > 0 {
+			if _, ssid, ok := strings.Cut(lines[0], "Network: "); ok {
+				return ssid
+			}
+		}
+	case "linux":
+		if lines = commandLines("iwgetid", "-r"); len(lines) > 0 {
+			return lines[0]
+		}
+		for _, l := range commandLines("nmcli", "-t", "-f", "active,ssid", "dev", "wifi") {
+			if ssid, ok := strings.CutPrefix(l, "yes:"); ok {
+				return ssid
+			}
+		}
+	}
+	return ""
+}
+
+// commandLines runs a command briefly and returns its non-empty output
+// lines, or nil if it fails.
+func commandLines(name string, args ...string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, l := range strings.Split(string(out), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// upInterfaces returns the names of the network interfaces that are up.
+func upInterfaces() []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp != 0 {
+			names = append(names, iface.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeEnv is a machine called host on Wi-Fi ssid with interfaces up.
+func fakeEnv(host, ssid string, interfaces ...string) machineEnv {
+	return machineEnv{
+		hostname:   func() string { return host },
+		ssid:       func() string { return ssid },
+		interfaces: func() []string { return interfaces },
+	}
+}
+
+func TestSelectProfile(t *testing.T) {
+	rules := []ProfileRule{
+		{Profile: "work", SSID: "Corp*", Interface: "wg*"},
+		{Profile: "work", Hostname: "WORK-*"},
+		{Profile: "home", SSID: "home"},
+	}
+	tests := []struct {
+		name     string
+		explicit string
+		env      machineEnv
+		want     string
+	}{
+		{"office on VPN", "", fakeEnv("laptop", "CorpNet", "lo", "wg0"), "work"},
+		{"office without VPN", "", fakeEnv("laptop", "CorpNet", "lo"), ""},
+		{"work hostname", "", fakeEnv("work-1234", "", "lo"), "work"},
+		{"home", "", fakeEnv("laptop", "Home", "lo"), "home"},
+		{"explicit wins", "home", fakeEnv("work-1234", "", "lo"), "home"},
+	}
+	for _, tt := range tests {
+		if got, _ := selectProfile(tt.explicit, rules, tt.env); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_Profile(t *testing.T) {
+	host, _ := os.Hostname()
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"path_template": "/Journal/{{.Date}}.md", "wrap": 80,
+		"profiles": {"work": {"path_template": "/Work/{{.Date}}.md"}},
+		"profile_rules": [{"profile": "work", "hostname": "`+host+`"}]}`), 0600)
+
+	cfg, err := loadConfig(path, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PathTemplate != "/Work/{{.Date}}.md" || cfg.Wrap != 80 || cfg.profile != "work" {
+		t.Errorf("path_template %q, wrap %d, profile %q", cfg.PathTemplate, cfg.Wrap, cfg.profile)
+	}
+
+	selectedProfile = "nope"
+	defer func() { selectedProfile = "" }()
+	if _, err := loadConfig(path, io.Discard); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+func TestRunConfigProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"profiles": {"work": {}}, "profile_rules": [{"profile": "work", "ssid": "Corp*"}]}`), 0600)
+
+	var stdout, stderr bytes.Buffer
+	if code := runConfigProfile(&stdout, &stderr, path, fakeEnv("h", "CorpNet")); code != 0 || stdout.String() != "work (profile_rules[0] matched)\n" {
+		t.Errorf("exit %d, %q %q", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := runConfigProfile(&stdout, &stderr, path, fakeEnv("h", "cafe")); code != 0 || stdout.String() != "no profile\n" {
+		t.Errorf("exit %d, %q", code, stdout.String())
+	}
+}
+
+func TestApplyDefaultFlags(t *testing.T) {
+	fs := flag.NewFlagSet("dropbox-appender", flag.ContinueOnError)
+	author := fs.String("author", "", "")
+	separator := fs.String("separator", "", "")
+	noTimestamp := fs.Bool("no-timestamp", false, "")
+	if err := fs.Parse([]string{"-separator", "none", "entry"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyDefaultFlags(fs, []string{"-author", "alice", "-separator=rule", "-no-timestamp"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *author != "alice" || *separator != "none" || !*noTimestamp {
+		t.Errorf("got author %q, separator %q, no-timestamp %v", *author, *separator, *noTimestamp)
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["author"] || !set["no-timestamp"] {
+		t.Errorf("defaults should count as set, got %v", set)
+	}
+	if fs.Arg(0) != "entry" {
+		t.Errorf("arguments changed: %v", fs.Args())
+	}
+
+	if err := applyDefaultFlags(fs, []string{"-bogus"}); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+	if err := applyDefaultFlags(fs, []string{"work"}); err == nil {
+		t.Error("expected an error for a non-flag argument")
+	}
+}
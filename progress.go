@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressRedraw is how often the bar is redrawn on a terminal.
+	progressRedraw = 100 * time.Millisecond
+	// progressLogInterval is how often a progress line is logged when
+	// stderr isn't a terminal.
+	progressLogInterval = 10 * time.Second
+	// progressMinUpload is the smallest upload that reports progress.
+	progressMinUpload = 1 << 20
+	// progressBarWidth is the number of cells in the bar.
+	progressBarWidth = 24
+)
+
+// progress reports how far a long transfer has got: files done, bytes
+// transferred and an ETA. On a terminal it redraws a bar in place; otherwise
+// it logs a line every progressLogInterval, so short transfers stay quiet
+// in logs.
+type progress struct {
+	w          io.Writer
+	tty        bool
+	label      string
+	totalFiles int
+	totalBytes int64
+	now        func() time.Time
+
+	mu     sync.Mutex
+	files  int
+	bytes  int64
+	start  time.Time
+	last   time.Time // last draw or log line
+	logged bool
+}
+
+// newProgress starts reporting to w. totalFiles or totalBytes may be 0 when
+// unknown; they are then left out.
+func newProgress(w io.Writer, label string, totalFiles int, totalBytes int64) *progress {
+	now := time.Now()
+	return &progress{w: w, tty: isTerminal(w) && !plainOutput, label: label, totalFiles: totalFiles,
+		totalBytes: totalBytes, now: time.Now, start: now, last: now}
+}
+
+// add records files more files and n more bytes done.
+func (p *progress) add(files int, n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.files += files
+	p.bytes += n
+	t := p.now()
+	interval := progressLogInterval
+	if p.tty {
+		interval = progressRedraw
+	}
+	if t.Sub(p.last) < interval {
+		return
+	}
+	p.last = t
+	p.report(t)
+}
+
+// resetBytes forgets the bytes counted so far, for a transfer that starts
+// over after a retry.
+func (p *progress) resetBytes() {
+	p.mu.Lock()
+	p.bytes = 0
+	p.mu.Unlock()
+}
+
+// done writes the final state: it ends the bar on a terminal, and logs a
+// last line if any were logged before.
+func (p *progress) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		p.report(p.now())
+		fmt.Fprintln(p.w)
+	} else if p.logged {
+		p.report(p.now())
+	}
+}
+
+// report writes the current state, as a bar redrawn over the previous one
+// on a terminal and as a log line otherwise.
+func (p *progress) report(t time.Time) {
+	status := p.status(t)
+	if !p.tty {
+		fmt.Fprintf(p.w, "%s: %s\n", p.label, status)
+		p.logged = true
+		return
+	}
+	fmt.Fprintf(p.w, "\r\033[K%s %s %s", p.label, progressBar(p.fraction()), status)
+}
+
+// fraction returns how much of the transfer is done, by bytes when their
+// total is known and else by files.
+func (p *progress) fraction() float64 {
+	switch {
+	case p.totalBytes > 0:
+		return min(float64(p.bytes)/float64(p.totalBytes), 1)
+	case p.totalFiles > 0:
+		return min(float64(p.files)/float64(p.totalFiles), 1)
+	}
+	return 0
+}
+
+// status describes the progress, e.g. "3/10 files, 1.2 MB/4.0 MB, ETA 12s".
+func (p *progress) status(t time.Time) string {
+	var parts []string
+	if p.totalFiles > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d files", p.files, p.totalFiles))
+	}
+	if p.totalBytes > 0 {
+		parts = append(parts, formatBytes(p.bytes)+"/"+formatBytes(p.totalBytes))
+	} else if p.bytes > 0 {
+		parts = append(parts, formatBytes(p.bytes))
+	}
+	if f := p.fraction(); f > 0 && f < 1 {
+		elapsed := t.Sub(p.start)
+		eta := time.Duration(float64(elapsed) * (1 - f) / f)
+		parts = append(parts, "ETA "+eta.Round(time.Second).String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// progressBar renders fraction as [#####.....].
+func progressBar(fraction float64) string {
+	n := int(fraction * progressBarWidth)
+	return "[" + strings.Repeat("#", n) + strings.Repeat(".", progressBarWidth-n) + "]"
+}
+
+// progressReader counts the bytes read through it.
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (r progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.add(0, int64(n))
+	return n, err
+}
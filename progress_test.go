@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressLog(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(&buf, "Indexing", 4, 4096)
+	clock := p.start
+	p.now = func() time.Time { return clock }
+
+	clock = clock.Add(time.Second)
+	p.add(1, 1024)
+	if buf.Len() != 0 {
+		t.Fatalf("logged before the interval: %q", buf.String())
+	}
+	clock = clock.Add(progressLogInterval)
+	p.add(1, 1024)
+	if got := buf.String(); got != "Indexing: 2/4 files, 2.0 KB/4.0 KB, ETA 11s\n" {
+		t.Errorf("log line = %q", got)
+	}
+	p.add(2, 2048)
+	p.done()
+	if !strings.HasSuffix(buf.String(), "Indexing: 4/4 files, 4.0 KB/4.0 KB\n") {
+		t.Errorf("final line = %q", buf.String())
+	}
+}
+
+func TestProgressQuiet(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(&buf, "Uploading", 1, 10)
+	io.Copy(io.Discard, progressReader{strings.NewReader("0123456789"), p})
+	p.done()
+	if buf.Len() != 0 {
+		t.Errorf("a quick transfer logged %q", buf.String())
+	}
+	if p.bytes != 10 {
+		t.Errorf("counted %d bytes", p.bytes)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	if got := progressBar(0.5); got != "["+strings.Repeat("#", 12)+strings.Repeat(".", 12)+"]" {
+		t.Errorf("progressBar(0.5) = %q", got)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// noteStatsTemplateName names the property template stamped on notes when
+// note_properties is set.
+const noteStatsTemplateName = "dropbox-appender note stats"
+
+// version is the release version, set with -ldflags "-X main.version=...";
+// otherwise the module version from the build info is used.
+var version = ""
+
+// appenderVersion returns the version recorded in notes' properties.
+func appenderVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// propertyField is a name/value pair of a file property group.
+type propertyField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// templateField declares a string field of a property template.
+type templateField struct {
+	Name        string
+	Description string
+}
+
+// noteStatsFields are the fields of the note stats template.
+var noteStatsFields = []templateField{
+	{"entry_count", "Number of timestamped entries in the note"},
+	{"last_append_at", "When dropbox-appender last appended, RFC 3339"},
+	{"appender_version", "Version of dropbox-appender that last appended"},
+}
+
+// propertyTemplateIDs is the on-disk record of template IDs, so appends
+// don't look templates up every time. Scope identifies the account they
+// belong to; IDs recorded for another account are ignored.
+type propertyTemplateIDs struct {
+	Scope string            `json:"scope"`
+	IDs   map[string]string `json:"ids"`
+}
+
+// propertyTemplatesPath returns the file recording template IDs.
+func propertyTemplatesPath() string {
+	return filepath.Join(defaultCacheDir(), "property-templates.json")
+}
+
+// propertyScope identifies the account the client acts for.
+func (c *DropboxClient) propertyScope() string {
+	if c.Cache != nil {
+		return c.Cache.Scope
+	}
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	return checksum([]byte(c.Token))
+}
+
+// loadTemplateIDs returns the recorded template IDs for the client's
+// account.
+func (c *DropboxClient) loadTemplateIDs() propertyTemplateIDs {
+	ids := propertyTemplateIDs{Scope: c.propertyScope(), IDs: map[string]string{}}
+	data, err := os.ReadFile(propertyTemplatesPath())
+	if err != nil {
+		return ids
+	}
+	var saved propertyTemplateIDs
+	if json.Unmarshal(data, &saved) == nil && saved.Scope == ids.Scope && saved.IDs != nil {
+		ids.IDs = saved.IDs
+	}
+	return ids
+}
+
+// forgetPropertyTemplates drops the recorded template IDs, e.g. after a
+// template was deleted in Dropbox.
+func forgetPropertyTemplates() {
+	os.Remove(propertyTemplatesPath())
+}
+
+// PropertyTemplate returns the ID of the user property template called
+// name, looking it up with file_properties/templates/list_for_user and
+// creating it with fields when the account has none.
+func (c *DropboxClient) PropertyTemplate(name, description string, fields []templateField) (string, error) {
+	ids := c.loadTemplateIDs()
+	if id, ok := ids.IDs[name]; ok {
+		return id, nil
+	}
+	id, err := c.findPropertyTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		if id, err = c.addPropertyTemplate(name, description, fields); err != nil {
+			return "", fmt.Errorf("creating the %q template: %w", name, err)
+		}
+	}
+	ids.IDs[name] = id
+	if data, err := json.Marshal(ids); err == nil {
+		os.MkdirAll(defaultCacheDir(), 0700)
+		writeFileAtomic(propertyTemplatesPath(), data, 0600)
+	}
+	return id, nil
+}
+
+// findPropertyTemplate returns the ID of the template called name, or ""
+// if there is none.
+func (c *DropboxClient) findPropertyTemplate(name string) (string, error) {
+	body, err := c.rpc("/2/file_properties/templates/list_for_user", nil)
+	if err != nil {
+		return "", err
+	}
+	var list struct {
+		TemplateIDs []string `json:"template_ids"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return "", fmt.Errorf("parsing list_for_user response: %w", err)
+	}
+	for _, id := range list.TemplateIDs {
+		body, err := c.rpc("/2/file_properties/templates/get_for_user", map[string]string{"template_id": id})
+		if err != nil {
+			return "", err
+		}
+		var t struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(body, &t) == nil && t.Name == name {
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+// addPropertyTemplate creates a template of string fields with
+// file_properties/templates/add_for_user and returns its ID.
+func (c *DropboxClient) addPropertyTemplate(name, description string, fields []templateField) (string, error) {
+	defs := make([]map[string]string, len(fields))
+	for i, f := range fields {
+		defs[i] = map[string]string{"name": f.Name, "description": f.Description, "type": "string"}
+	}
+	body, err := c.rpc("/2/file_properties/templates/add_for_user", map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"fields":      defs,
+	})
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		TemplateID string `json:"template_id"`
+	}
+	json.Unmarshal(body, &created)
+	return created.TemplateID, nil
+}
+
+// SetProperties replaces the template's property group on the file at path
+// with file_properties/properties/overlay_file, adding it if missing.
+func (c *DropboxClient) SetProperties(path, templateID string, fields []propertyField) error {
+	_, err := c.rpc("/2/file_properties/properties/overlay_file", map[string]interface{}{
+		"path": path,
+		"property_groups": []map[string]interface{}{
+			{"template_id": templateID, "fields": fields},
+		},
+	})
+	return err
+}
+
+// stampNoteProperties records the note's entry count, the append time and
+// this tool's version as properties of the note at path.
+func stampNoteProperties(client *DropboxClient, path, content string, now time.Time) error {
+	id, err := client.PropertyTemplate(noteStatsTemplateName, "Statistics of a dropbox-appender note", noteStatsFields)
+	if err != nil {
+		return err
+	}
+	err = client.SetProperties(path, id, []propertyField{
+		{"entry_count", strconv.Itoa(len(parseEntries(content)))},
+		{"last_append_at", now.Format(time.RFC3339)},
+		{"appender_version", appenderVersion()},
+	})
+	if err != nil {
+		// The template may have been removed; look it up afresh next time.
+		forgetPropertyTemplates()
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStampNoteProperties(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	props := map[string][]propertyField{}
+	srv := propertiesServer(t, props)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+
+	content := "### 09:00:00\nplanning\n\n### 14:30:45\nshipped\n"
+	if err := stampNoteProperties(client, "/Journal/20250115.md", content, now); err != nil {
+		t.Fatal(err)
+	}
+	got := props["/Journal/20250115.md"]
+	want := []propertyField{
+		{"entry_count", "2"},
+		{"last_append_at", "2025-01-15T14:30:45Z"},
+		{"appender_version", appenderVersion()},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("properties = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("property %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// The template ID is remembered for the account.
+	if ids := client.loadTemplateIDs(); ids.IDs[noteStatsTemplateName] != "ptid:1" {
+		t.Errorf("recorded IDs = %+v", ids)
+	}
+	other := &DropboxClient{Token: "other"}
+	if ids := other.loadTemplateIDs(); len(ids.IDs) != 0 {
+		t.Errorf("another account sees %+v", ids.IDs)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dateFilter restricts queries to an inclusive YYYY-MM-DD range; empty bounds
+// are open.
+type dateFilter struct {
+	From, To string
+}
+
+// addDateFlags registers -from and -to on fs.
+func addDateFlags(fs *flag.FlagSet) *dateFilter {
+	f := &dateFilter{}
+	fs.StringVar(&f.From, "from", "", "only entries on or after this date (YYYY-MM-DD)")
+	fs.StringVar(&f.To, "to", "", "only entries on or before this date (YYYY-MM-DD)")
+	return f
+}
+
+// validate checks that the bounds are well-formed dates.
+func (f dateFilter) validate() error {
+	for _, d := range []string{f.From, f.To} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return fmt.Errorf("invalid date %q: expected YYYY-MM-DD", d)
+		}
+	}
+	return nil
+}
+
+func (f dateFilter) match(date string) bool {
+	return (f.From == "" || date >= f.From) && (f.To == "" || date <= f.To)
+}
+
+// loadIndexedEntries loads the local index for a query subcommand, telling
+// the user to run reindex when it's empty.
+func loadIndexedEntries(stderr io.Writer) ([]indexEntry, bool) {
+	idx, err := loadIndex(defaultIndexPath())
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return nil, false
+	}
+	entries := idx.entries()
+	if len(entries) == 0 {
+		fmt.Fprintln(stderr, "local index is empty, run: dropbox-appender reindex")
+	}
+	return entries, true
+}
+
+// entryFilter narrows a query by tag, author and date. Empty fields match
+// everything.
+type entryFilter struct {
+	Tag    string
+	Author string
+	Dates  dateFilter
+}
+
+func (f entryFilter) match(e indexEntry) bool {
+	return f.Dates.match(e.Date) &&
+		(f.Tag == "" || hasTag(e, f.Tag)) &&
+		(f.Author == "" || strings.EqualFold(e.Author, f.Author))
+}
+
+// searchEntries returns the entries whose text contains every term
+// (case-insensitively) and that match filter.
+func searchEntries(entries []indexEntry, terms []string, filter entryFilter) []indexEntry {
+	var found []indexEntry
+	for _, e := range entries {
+		if !filter.match(e) {
+			continue
+		}
+		text := strings.ToLower(e.Text)
+		ok := true
+		for _, t := range terms {
+			if !strings.Contains(text, strings.ToLower(t)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func hasTag(e indexEntry, tag string) bool {
+	tag = strings.TrimPrefix(tag, "#")
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstLine returns the first line of text.
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
+
+// runSearch implements the `dropbox-appender search` subcommand, querying the
+// local index without network access, or Dropbox's search with -remote. It
+// returns the process exit code.
+func runSearch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	tag := fs.String("tag", "", "only entries with this #tag")
+	author := fs.String("author", "", "only entries by this author")
+	full := fs.Bool("full", false, "print whole entries instead of their first line")
+	render := fs.Bool("render", false, "highlight Markdown for the terminal")
+	noPager := fs.Bool("no-pager", false, "don't page output on a terminal")
+	remote := fs.Bool("remote", false, "search in Dropbox with its full-text search instead of the local index")
+	dates := addDateFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	filter := entryFilter{Tag: *tag, Author: *author, Dates: *dates}
+	var out strings.Builder
+
+	if *remote {
+		cfg, client, ok := loadClient(stderr, false)
+		if !ok {
+			return 1
+		}
+		if code := runRemoteSearch(&out, stderr, client, cfg.PathTemplate, fs.Args(), filter, *full); code != 0 {
+			return code
+		}
+		pageOutput(stdout, out.String(), *render, *noPager)
+		return 0
+	}
+
+	entries, ok := loadIndexedEntries(stderr)
+	if !ok {
+		return 1
+	}
+	printSearchResults(&out, searchEntries(entries, fs.Args(), filter), *full)
+	pageOutput(stdout, out.String(), *render, *noPager)
+	return 0
+}
+
+// printSearchResults writes one line per entry, or whole entries when full.
+// Attributed entries show their author after the time.
+func printSearchResults(w io.Writer, entries []indexEntry, full bool) {
+	for _, e := range entries {
+		stamp := e.Date + " " + e.Time
+		if e.Author != "" {
+			stamp += " " + e.Author
+		}
+		if full {
+			fmt.Fprintf(w, "%s  %s\n%s\n\n", stamp, e.Path, e.Text)
+			continue
+		}
+		fmt.Fprintf(w, "%s  %s\n", stamp, firstLine(e.Text))
+	}
+}
+
+// tagCount is a tag and how many entries carry it.
+type tagCount struct {
+	Tag   string
+	Count int
+}
+
+// authorCount is an author and how many entries they wrote.
+type authorCount struct {
+	Author string
+	Count  int
+}
+
+// dayCount is a date and a count for it.
+type dayCount struct {
+	Date  string
+	Count int
+}
+
+// journalStats summarises indexed entries.
+type journalStats struct {
+	Entries       int
+	Days          int
+	Words         int
+	First, Last   string
+	CurrentStreak int
+	LongestStreak int
+	TopTags       []tagCount
+	Authors       []authorCount // attributed entries only, most active first
+	Pomodoros     int
+	PomodoroDays  []dayCount // days with pomodoros, oldest first
+}
+
+// dayAggregate sums up the entries of one day, the unit stats are cached in;
+// see cachedStats.
+type dayAggregate struct {
+	Entries   int            `json:"entries"`
+	Words     int            `json:"words"`
+	Tags      map[string]int `json:"tags,omitempty"`
+	Authors   map[string]int `json:"authors,omitempty"`
+	Pomodoros int            `json:"pomodoros,omitempty"`
+}
+
+// aggregateDays aggregates entries by date.
+func aggregateDays(entries []indexEntry) map[string]*dayAggregate {
+	days := map[string]*dayAggregate{}
+	marks := map[string][]pomoMark{}
+	for _, e := range entries {
+		day := days[e.Date]
+		if day == nil {
+			day = &dayAggregate{}
+			days[e.Date] = day
+		}
+		day.Entries++
+		if d, err := time.Parse("2006-01-02", e.Date); err == nil {
+			if m, ok := parsePomoMark(e.Text, entryTime(d, e.Time)); ok {
+				marks[e.Date] = append(marks[e.Date], m)
+			}
+		}
+		if e.Author != "" {
+			if day.Authors == nil {
+				day.Authors = map[string]int{}
+			}
+			day.Authors[e.Author]++
+		}
+		day.Words += len(strings.Fields(e.Text))
+		for _, t := range e.Tags {
+			if day.Tags == nil {
+				day.Tags = map[string]int{}
+			}
+			day.Tags[t]++
+		}
+	}
+	for d, m := range marks {
+		days[d].Pomodoros, _, _ = tallyPomodoros(m)
+	}
+	return days
+}
+
+// computeStats aggregates entries. The current streak counts consecutive
+// days ending today, or yesterday if nothing has been written yet today.
+func computeStats(entries []indexEntry, today time.Time) journalStats {
+	return summarizeStats(aggregateDays(entries), today)
+}
+
+// summarizeStats computes the stats of the aggregated days.
+func summarizeStats(byDay map[string]*dayAggregate, today time.Time) journalStats {
+	var s journalStats
+	days := map[string]bool{}
+	tags := map[string]int{}
+	authors := map[string]int{}
+	var dates []string
+	for d, day := range byDay {
+		days[d] = true
+		dates = append(dates, d)
+		s.Entries += day.Entries
+		s.Words += day.Words
+		for t, n := range day.Tags {
+			tags[t] += n
+		}
+		for a, n := range day.Authors {
+			authors[a] += n
+		}
+	}
+	sort.Strings(dates)
+	s.Days = len(dates)
+	for _, d := range dates {
+		if n := byDay[d].Pomodoros; n > 0 {
+			s.Pomodoros += n
+			s.PomodoroDays = append(s.PomodoroDays, dayCount{d, n})
+		}
+	}
+	if len(dates) > 0 {
+		s.First, s.Last = dates[0], dates[len(dates)-1]
+	}
+
+	run := 0
+	var prev time.Time
+	for _, d := range dates {
+		t, _ := time.Parse("2006-01-02", d)
+		if run > 0 && t.Sub(prev) == 24*time.Hour {
+			run++
+		} else {
+			run = 1
+		}
+		if run > s.LongestStreak {
+			s.LongestStreak = run
+		}
+		prev = t
+	}
+	day := today.Format("2006-01-02")
+	if !days[day] {
+		day = today.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	for days[day] {
+		s.CurrentStreak++
+		t, _ := time.Parse("2006-01-02", day)
+		day = t.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+
+	for t, n := range tags {
+		s.TopTags = append(s.TopTags, tagCount{t, n})
+	}
+	sort.Slice(s.TopTags, func(i, j int) bool {
+		if s.TopTags[i].Count != s.TopTags[j].Count {
+			return s.TopTags[i].Count > s.TopTags[j].Count
+		}
+		return s.TopTags[i].Tag < s.TopTags[j].Tag
+	})
+	if len(s.TopTags) > 5 {
+		s.TopTags = s.TopTags[:5]
+	}
+
+	for a, n := range authors {
+		s.Authors = append(s.Authors, authorCount{a, n})
+	}
+	sort.Slice(s.Authors, func(i, j int) bool {
+		if s.Authors[i].Count != s.Authors[j].Count {
+			return s.Authors[i].Count > s.Authors[j].Count
+		}
+		return s.Authors[i].Author < s.Authors[j].Author
+	})
+	return s
+}
+
+// printStats writes s as aligned label/value lines.
+func printStats(w io.Writer, s journalStats) {
+	fmt.Fprintf(w, "Entries:        %d\n", s.Entries)
+	fmt.Fprintf(w, "Days journaled: %d", s.Days)
+	if s.Days > 0 {
+		fmt.Fprintf(w, " (%s to %s)", s.First, s.Last)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Words:          %d\n", s.Words)
+	fmt.Fprintf(w, "Current streak: %d days\n", s.CurrentStreak)
+	fmt.Fprintf(w, "Longest streak: %d days\n", s.LongestStreak)
+	if len(s.TopTags) > 0 {
+		var parts []string
+		for _, t := range s.TopTags {
+			parts = append(parts, fmt.Sprintf("#%s (%d)", t.Tag, t.Count))
+		}
+		fmt.Fprintf(w, "Top tags:       %s\n", strings.Join(parts, ", "))
+	}
+	if len(s.Authors) > 0 {
+		var parts []string
+		for _, a := range s.Authors {
+			parts = append(parts, fmt.Sprintf("%s (%d)", a.Author, a.Count))
+		}
+		fmt.Fprintf(w, "Authors:        %s\n", strings.Join(parts, ", "))
+	}
+	if s.Pomodoros > 0 {
+		fmt.Fprintf(w, "Pomodoros:      %d on %d days\n", s.Pomodoros, len(s.PomodoroDays))
+		recent := s.PomodoroDays[max(len(s.PomodoroDays)-maxPomodoroDays, 0):]
+		for _, d := range recent {
+			fmt.Fprintf(w, "  %s  %s %d\n", d.Date, strings.Repeat("🍅", min(d.Count, 12)), d.Count)
+		}
+	}
+}
+
+// maxPomodoroDays is how many recent days stats lists pomodoros for.
+const maxPomodoroDays = 7
+
+// runStats implements the `dropbox-appender stats` subcommand from the local
+// index. It returns the process exit code.
+func runStats(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	author := fs.String("author", "", "only entries by this author")
+	dates := addDateFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	if *author != "" {
+		entries, ok := loadIndexedEntries(stderr)
+		if !ok {
+			return 1
+		}
+		filter := entryFilter{Author: *author, Dates: *dates}
+		printStats(stdout, computeStats(searchEntries(entries, nil, filter), clock.Now()))
+		return 0
+	}
+	// Without an author filter, the per-day aggregates are cached by month.
+	idx, err := loadIndex(defaultIndexPath())
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if len(idx.Notes) == 0 {
+		fmt.Fprintln(stderr, "local index is empty, run: dropbox-appender reindex")
+	}
+	printStats(stdout, cachedStats(idx, defaultStatsCachePath(), *dates, clock.Now(), stderr))
+	return 0
+}
+
+// journalTask is a markdown task item found in an entry.
+type journalTask struct {
+	Date string
+	Done bool
+	Text string
+	Path string
+}
+
+// findTasks returns the task items in entries, open ones only unless all.
+func findTasks(entries []indexEntry, all bool) []journalTask {
+	var tasks []journalTask
+	for _, e := range entries {
+		for _, m := range taskPattern.FindAllStringSubmatch(e.Text, -1) {
+			done := m[1] != " "
+			if done && !all {
+				continue
+			}
+			tasks = append(tasks, journalTask{Date: e.Date, Done: done, Text: m[2], Path: e.Path})
+		}
+	}
+	return tasks
+}
+
+// printTasks lists tasks one per line. Open tasks are numbered for `tasks
+// complete`; completed ones (shown with -all) aren't, so the numbers are the
+// same with and without -all.
+func printTasks(w io.Writer, tasks []journalTask) {
+	n := 0
+	for _, t := range tasks {
+		if t.Done {
+			fmt.Fprintf(w, "     %s  [x] %s\n", t.Date, t.Text)
+			continue
+		}
+		n++
+		fmt.Fprintf(w, "%3d  %s  [ ] %s\n", n, t.Date, t.Text)
+	}
+}
+
+// runTasks implements the `dropbox-appender tasks` subcommand, listing
+// "- [ ]" items from the local index, numbered for `tasks complete`. The
+// export and complete subcommands live in tasks.go. It returns the process
+// exit code.
+func runTasks(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runTasksExport(args[1:], stdout, stderr, clock.Now())
+		case "complete":
+			return runTasksComplete(args[1:], stdout, stderr, clock.Now())
+		}
+	}
+	fs := flag.NewFlagSet("tasks", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	all := fs.Bool("all", false, "include completed tasks")
+	dates := addDateFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	entries, ok := loadIndexedEntries(stderr)
+	if !ok {
+		return 1
+	}
+	printTasks(stdout, findTasks(searchEntries(entries, nil, entryFilter{Dates: *dates}), *all))
+	return 0
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var queryEntries = []indexEntry{
+	{Date: "2025-01-13", Time: "09:00:00", Text: "planning #work\n- [ ] write spec\n- [x] book room", Tags: []string{"work"}},
+	{Date: "2025-01-14", Time: "18:00:00", Text: "Dinner with family #home", Tags: []string{"home"}},
+	{Date: "2025-01-15", Time: "10:00:00", Author: "bob", Text: "Spec review went well #work", Tags: []string{"work"}},
+	{Date: "2025-01-15", Time: "16:00:00", Author: "alice", Text: "- [ ] send spec to team"},
+}
+
+func TestSearchEntries(t *testing.T) {
+	found := searchEntries(queryEntries, []string{"SPEC"}, entryFilter{})
+	if len(found) != 3 {
+		t.Errorf("expected 3 matches for spec, got %d", len(found))
+	}
+	found = searchEntries(queryEntries, []string{"spec", "review"}, entryFilter{Tag: "#work"})
+	if len(found) != 1 || found[0].Time != "10:00:00" {
+		t.Errorf("expected the review entry, got %+v", found)
+	}
+	found = searchEntries(queryEntries, nil, entryFilter{Dates: dateFilter{From: "2025-01-14", To: "2025-01-14"}})
+	if len(found) != 1 || found[0].Date != "2025-01-14" {
+		t.Errorf("expected one entry on 2025-01-14, got %+v", found)
+	}
+}
+
+func TestSearchEntries_Author(t *testing.T) {
+	found := searchEntries(queryEntries, []string{"spec"}, entryFilter{Author: "Alice"})
+	if len(found) != 1 || found[0].Time != "16:00:00" {
+		t.Errorf("expected alice's entry, got %+v", found)
+	}
+
+	var buf bytes.Buffer
+	printSearchResults(&buf, found, false)
+	if buf.String() != "2025-01-15 16:00:00 alice  - [ ] send spec to team\n" {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestDateFilterValidate(t *testing.T) {
+	if err := (dateFilter{From: "2025-1-5"}).validate(); err == nil {
+		t.Error("expected error for malformed date")
+	}
+}
+
+func TestPrintSearchResults(t *testing.T) {
+	var buf bytes.Buffer
+	printSearchResults(&buf, queryEntries[:1], false)
+	if buf.String() != "2025-01-13 09:00:00  planning #work\n" {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	s := computeStats(queryEntries, time.Date(2025, 1, 16, 8, 0, 0, 0, time.UTC))
+	if s.Entries != 4 || s.Days != 3 || s.First != "2025-01-13" || s.Last != "2025-01-15" {
+		t.Errorf("unexpected totals: %+v", s)
+	}
+	if s.LongestStreak != 3 || s.CurrentStreak != 3 {
+		t.Errorf("expected 3-day streaks (current counts from yesterday), got %+v", s)
+	}
+	if len(s.TopTags) != 2 || s.TopTags[0] != (tagCount{"work", 2}) {
+		t.Errorf("unexpected top tags: %+v", s.TopTags)
+	}
+	if len(s.Authors) != 2 || s.Authors[0] != (authorCount{"alice", 1}) {
+		t.Errorf("unexpected authors: %+v", s.Authors)
+	}
+
+	s = computeStats(queryEntries, time.Date(2025, 1, 20, 8, 0, 0, 0, time.UTC))
+	if s.CurrentStreak != 0 {
+		t.Errorf("expected broken streak, got %d", s.CurrentStreak)
+	}
+}
+
+func TestPrintStats(t *testing.T) {
+	var buf bytes.Buffer
+	printStats(&buf, computeStats(queryEntries, time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC)))
+	out := buf.String()
+	for _, want := range []string{"Entries:        4", "(2025-01-13 to 2025-01-15)", "#work (2)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestComputeStats_Pomodoros(t *testing.T) {
+	entries := []indexEntry{
+		{Date: "2025-01-14", Time: "09:00:00", Text: "🍅 pomo start: spec"},
+		{Date: "2025-01-14", Time: "09:25:00", Text: "✅ pomo done: spec — 1 pomodoro, 25m focused"},
+		{Date: "2025-01-15", Time: "09:00:00", Text: "🍅 pomo start: review"},
+		{Date: "2025-01-15", Time: "09:25:00", Text: "☕ pomo break"},
+		{Date: "2025-01-15", Time: "09:30:00", Text: "🍅 pomo start: review"},
+		{Date: "2025-01-15", Time: "09:55:00", Text: "☕ pomo break"},
+		{Date: "2025-01-15", Time: "10:00:00", Text: "🍅 pomo start: review"}, // still running
+	}
+	s := computeStats(entries, time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	want := []dayCount{{"2025-01-14", 1}, {"2025-01-15", 2}}
+	if s.Pomodoros != 3 || len(s.PomodoroDays) != 2 || s.PomodoroDays[0] != want[0] || s.PomodoroDays[1] != want[1] {
+		t.Errorf("pomodoros = %d, %+v", s.Pomodoros, s.PomodoroDays)
+	}
+	var buf bytes.Buffer
+	printStats(&buf, s)
+	if !strings.Contains(buf.String(), "Pomodoros:      3 on 2 days\n  2025-01-14  🍅 1\n  2025-01-15  🍅🍅 2\n") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestFindTasks(t *testing.T) {
+	open := findTasks(queryEntries, false)
+	if len(open) != 2 || open[0].Text != "write spec" || open[1].Text != "send spec to team" {
+		t.Errorf("unexpected open tasks: %+v", open)
+	}
+	if all := findTasks(queryEntries, true); len(all) != 3 || !all[1].Done {
+		t.Errorf("unexpected tasks with -all: %+v", all)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// exitConflict is the exit code used when a rev-checked write is rejected
+// because the remote file changed. Editor plugins use it to tell "re-sync and
+// retry" apart from other failures.
+const exitConflict = 3
+
+// parseRange parses a -range-replace spec of the form "START,END".
+func parseRange(spec string) (int, int, error) {
+	startStr, endStr, ok := strings.Cut(spec, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range %q: expected START,END", spec)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(startStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range start %q", startStr)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(endStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range end %q", endStr)
+	}
+	return start, end, nil
+}
+
+// spliceLines replaces lines start..end (1-based, inclusive, like a vim
+// :start,end range) of content with replacement. end may be start-1 to insert
+// before line start without removing anything, and start may be one past the
+// last line to append. A non-empty replacement always ends with a newline.
+func spliceLines(content string, start, end int, replacement string) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if start < 1 || start > len(lines)+1 || end < start-1 || end > len(lines) {
+		return "", fmt.Errorf("line range %d,%d out of bounds (file has %d lines)", start, end, len(lines))
+	}
+	if replacement != "" && !strings.HasSuffix(replacement, "\n") {
+		replacement += "\n"
+	}
+	return strings.Join(lines[:start-1], "") + replacement + strings.Join(lines[end:], ""), nil
+}
+
+// spliceBytes replaces the half-open byte range [start, end) of content with
+// replacement.
+func spliceBytes(content string, start, end int, replacement string) (string, error) {
+	if start < 0 || end < start || end > len(content) {
+		return "", fmt.Errorf("byte range %d,%d out of bounds (file has %d bytes)", start, end, len(content))
+	}
+	return content[:start] + replacement + content[end:], nil
+}
+
+// spliceRange applies spliceLines or spliceBytes according to unit.
+func spliceRange(content, unit string, start, end int, replacement string) (string, error) {
+	switch unit {
+	case "lines":
+		return spliceLines(content, start, end, replacement)
+	case "bytes":
+		return spliceBytes(content, start, end, replacement)
+	}
+	return "", fmt.Errorf("invalid range unit %q: expected lines or bytes", unit)
+}
+
+// runRangeReplace downloads the file at path, replaces the range described by
+// spec (in "lines" or "bytes" units) with replacement, and uploads the result
+// only if the remote file is unchanged. If expectedRev is set, the download
+// must also match it, so a plugin can guarantee its range refers to the
+// version it last saw. The new revision is printed to stdout. It returns the
+// process exit code.
+//
+// When mergeTool is set, a conflict launches it (see runMergeTool) with the
+// edit applied to the version it was meant for and the current remote
+// version, and the merged result is uploaded instead of failing. The index
+// and mirrors are updated as opts configures (see wroteNote).
+func runRangeReplace(stdout, stderr io.Writer, client *DropboxClient,
+	path, spec, unit, expectedRev, replacement, mergeTool string, opts appendOptions) int {
+
+	start, end, err := parseRange(spec)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	content, rev, err := client.DownloadRev(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading journal: %v\n", err)
+		return 1
+	}
+	if expectedRev != "" && expectedRev != rev {
+		if mergeTool == "" {
+			fmt.Fprintf(stderr, "error: %v (expected rev %s, remote is %s)\n", ErrConflict, expectedRev, rev)
+			return exitConflict
+		}
+		// Apply the edit to the version the caller saw; that is our side
+		// of the merge.
+		if content, _, err = client.DownloadRev("rev:" + expectedRev); err != nil {
+			fmt.Fprintf(stderr, "error: downloading rev %s: %v\n", expectedRev, err)
+			return 1
+		}
+	}
+
+	updated, err := spliceRange(content, unit, start, end, replacement)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 2
+	}
+
+	var newRev string
+	if expectedRev != "" && expectedRev != rev {
+		err = ErrConflict
+	} else {
+		newRev, err = client.UploadRev(path, updated, rev)
+	}
+	if errors.Is(err, ErrConflict) && mergeTool != "" {
+		updated, newRev, err = mergeAndUpload(client, mergeTool, path, content, updated, stderr)
+	}
+	if errors.Is(err, ErrConflict) {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return exitConflict
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: uploading journal: %v\n", err)
+		return 1
+	}
+	wroteNote(client, path, path, updated, updated, newRev, opts)
+
+	fmt.Fprintln(stdout, newRev)
+	return 0
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	start, end, err := parseRange("10,12")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if start != 10 || end != 12 {
+		t.Errorf("got %d,%d, want 10,12", start, end)
+	}
+}
+
+func TestParseRange_Invalid(t *testing.T) {
+	for _, spec := range []string{"10", "a,2", "1,b", ""} {
+		if _, _, err := parseRange(spec); err == nil {
+			t.Errorf("parseRange(%q): expected error", spec)
+		}
+	}
+}
+
+func TestSpliceLines(t *testing.T) {
+	content := "one\ntwo\nthree\nfour\n"
+	cases := []struct {
+		start, end  int
+		replacement string
+		want        string
+	}{
+		{2, 3, "TWO\nTHREE\n", "one\nTWO\nTHREE\nfour\n"},
+		{2, 2, "TWO", "one\nTWO\nthree\nfour\n"},
+		{2, 3, "", "one\nfour\n"},
+		{3, 2, "inserted\n", "one\ntwo\ninserted\nthree\nfour\n"},
+		{5, 4, "five\n", "one\ntwo\nthree\nfour\nfive\n"},
+	}
+	for _, c := range cases {
+		got, err := spliceLines(content, c.start, c.end, c.replacement)
+		if err != nil {
+			t.Errorf("spliceLines(%d,%d): unexpected error: %v", c.start, c.end, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("spliceLines(%d,%d):\n got %q\nwant %q", c.start, c.end, got, c.want)
+		}
+	}
+}
+
+func TestSpliceLines_OutOfBounds(t *testing.T) {
+	for _, r := range [][2]int{{0, 1}, {2, 5}, {6, 5}, {3, 1}} {
+		if _, err := spliceLines("a\nb\nc\n", r[0], r[1], "x"); err == nil {
+			t.Errorf("spliceLines(%d,%d): expected error", r[0], r[1])
+		}
+	}
+}
+
+func TestSpliceBytes(t *testing.T) {
+	got, err := spliceBytes("hello world", 6, 11, "there")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "hello there" {
+		t.Errorf("got %q, want %q", got, "hello there")
+	}
+	if _, err := spliceBytes("abc", 2, 4, ""); err == nil {
+		t.Error("expected error for out-of-bounds range")
+	}
+}
+
+func TestDownloadRev(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Dropbox-API-Result", `{"name":"Note.md","rev":"015abc"}`)
+		w.WriteHeader(200)
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	content, rev, err := client.DownloadRev("/Notes/Note.md")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "content" || rev != "015abc" {
+		t.Errorf("got (%q, %q), want (content, 015abc)", content, rev)
+	}
+}
+
+func TestUploadRev_Modes(t *testing.T) {
+	var modes []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg map[string]interface{}
+		json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+		modes = append(modes, arg["mode"])
+		w.WriteHeader(200)
+		w.Write([]byte(`{"rev":"new-rev"}`))
+	}))
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := client.UploadRev("/a.md", "x", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rev, err := client.UploadRev("/a.md", "x", "old-rev")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rev != "new-rev" {
+		t.Errorf("expected new-rev, got %q", rev)
+	}
+
+	if modes[0] != "add" {
+		t.Errorf("expected add mode without rev, got %v", modes[0])
+	}
+	update, ok := modes[1].(map[string]interface{})
+	if !ok || update[".tag"] != "update" || update["update"] != "old-rev" {
+		t.Errorf("expected update mode with rev, got %v", modes[1])
+	}
+}
+
+func TestUploadRev_Conflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error_summary": "path/conflict/file/.."}`))
+	}))
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	_, err := client.UploadRev("/a.md", "x", "old-rev")
+	if err != ErrConflict {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestRunRangeReplace(t *testing.T) {
+	var uploaded string
+	var uploadArg string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/2/files/download"):
+			w.Header().Set("Dropbox-API-Result", `{"rev":"rev1"}`)
+			w.WriteHeader(200)
+			w.Write([]byte("### 09:00:00\nmorning note\n"))
+		case strings.HasSuffix(r.URL.Path, "/2/files/upload"):
+			uploaded = string(body)
+			uploadArg = r.Header.Get("Dropbox-API-Arg")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"rev":"rev2"}`))
+		}
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := runRangeReplace(&stdout, &stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		"/Notes/Journal/2025/01/Note20250115.md", "2,2", "lines", "rev1", "edited note\n", "", appendOptions{})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if uploaded != "### 09:00:00\nedited note\n" {
+		t.Errorf("uploaded content: %q", uploaded)
+	}
+	if !strings.Contains(uploadArg, `"update":"rev1"`) {
+		t.Errorf("expected rev-checked upload, got arg: %q", uploadArg)
+	}
+	if strings.TrimSpace(stdout.String()) != "rev2" {
+		t.Errorf("expected new rev on stdout, got %q", stdout.String())
+	}
+}
+
+func TestRunRangeReplace_StaleRev(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/2/files/upload") {
+			t.Error("upload should not happen when the expected rev is stale")
+		}
+		w.Header().Set("Dropbox-API-Result", `{"rev":"rev9"}`)
+		w.WriteHeader(200)
+		w.Write([]byte("a\n"))
+	}))
+	defer server.Close()
+
+	var stderr bytes.Buffer
+	code := runRangeReplace(io.Discard, &stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		"/a.md", "1,1", "lines", "rev1", "b\n", "", appendOptions{})
+	if code != exitConflict {
+		t.Errorf("expected exit code %d, got %d", exitConflict, code)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// readOnlyFlag is set by -read-only on the command line; readOnly is in
+// effect when it or the read_only config is set. configureReadOnly sets
+// readOnly.
+var readOnlyFlag, readOnly bool
+
+// readEndpoints are the Dropbox endpoints allowed in read-only mode. Every
+// other endpoint is refused, so an endpoint added later is blocked until
+// it is listed here.
+var readEndpoints = map[string]bool{
+	"/2/files/download":                          true,
+	"/2/files/export":                            true,
+	"/2/files/get_metadata":                      true,
+	"/2/files/list_folder":                       true,
+	"/2/files/list_folder/continue":              true,
+	"/2/files/list_revisions":                    true,
+	"/2/files/search_v2":                         true,
+	"/2/files/search/continue_v2":                true,
+	"/2/users/get_current_account":               true,
+	"/2/users/get_space_usage":                   true,
+	"/2/file_properties/templates/get_for_user":  true,
+	"/2/file_properties/templates/list_for_user": true,
+}
+
+// configureReadOnly sets readOnly from -read-only or the read_only config.
+func configureReadOnly(cfg *Config) {
+	readOnly = readOnlyFlag || cfg.ReadOnly
+}
+
+// checkEndpoint refuses endpoints that may write while in read-only mode.
+func checkEndpoint(endpoint string) error {
+	if readOnly && !readEndpoints[endpoint] {
+		return fmt.Errorf("%s: %w", endpoint, ErrReadOnly)
+	}
+	return nil
+}
+
+// refuseReadOnly reports, and returns true, when a command that only
+// writes is run in read-only mode, so it stops before doing anything
+// locally (queueing an entry, running a command) that couldn't be
+// completed.
+func refuseReadOnly(stderr io.Writer, what string) bool {
+	if !readOnly {
+		return false
+	}
+	fmt.Fprintf(stderr, tr("error: %v\n"), fmt.Errorf("%s: %w", what, ErrReadOnly))
+	return true
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly_RefusesWrites(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		w.Write([]byte(`{"entries": [], "has_more": false}`))
+	}))
+	defer server.Close()
+	readOnly = true
+	defer func() { readOnly = false }()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	writes := map[string]func() error{
+		"upload":     func() error { return client.Upload("/a.md", "x") },
+		"upload rev": func() error { _, err := client.UploadRev("/a.md", "x", "rev"); return err },
+		"delete":     func() error { return client.Delete("/a.md") },
+		"move":       func() error { _, err := client.Move("/a.md", "/b.md"); return err },
+		"restore":    func() error { _, err := client.Restore("/a.md", "rev"); return err },
+		"append": func() error {
+			_, err := appendToJournal(client, "/a.md", "### 09:00:00\nx\n", appendOptions{})
+			return err
+		},
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s: got %v, want ErrReadOnly", name, err)
+		}
+	}
+	for _, p := range requests {
+		if !readEndpoints[p] {
+			t.Errorf("%s reached the server", p)
+		}
+	}
+	if _, err := client.ListFolder("/", false, 0); err != nil {
+		t.Errorf("reads should still work: %v", err)
+	}
+}
+
+func TestRunAppend_ReadOnlyConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	cfgPath := filepath.Join(home, ".config", "dropbox-appender", "config.json")
+	os.MkdirAll(filepath.Dir(cfgPath), 0o700)
+	os.WriteFile(cfgPath, []byte(`{"read_only": true, "access_token": "x"}`), 0o600)
+	defer func() { readOnly = false }()
+
+	var stderr bytes.Buffer
+	if code := runAppend([]string{"-queue", "hello"}, nil, &bytes.Buffer{}, &stderr, systemClock{}); code != 1 {
+		t.Errorf("exit %d", code)
+	}
+	if !strings.Contains(stderr.String(), "read-only") {
+		t.Errorf("stderr %q", stderr.String())
+	}
+	if entries, _ := os.ReadDir(defaultQueueDir()); len(entries) != 0 {
+		t.Error("nothing should be queued in read-only mode")
+	}
+}
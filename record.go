@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// recordDelimiter returns the field separator for a structured -format.
+func recordDelimiter(format string) (rune, error) {
+	switch format {
+	case "csv":
+		return ',', nil
+	case "tsv":
+		return '\t', nil
+	default:
+		return 0, fmt.Errorf("unknown format %q: expected markdown, csv or tsv", format)
+	}
+}
+
+// recordPath swaps the extension of a resolved note path for the record
+// format's, so the default template yields NoteYYYYMMDD.csv. Templates that
+// already end in the right extension are left alone.
+func recordPath(p, format string) string {
+	ext := "." + format
+	if strings.HasSuffix(p, ext) {
+		return p
+	}
+	return strings.TrimSuffix(p, path.Ext(p)) + ext
+}
+
+// buildRecord fills fields in order. The date, time and datetime fields are
+// taken from now; every other field consumes the next value.
+func buildRecord(fields, values []string, now time.Time) ([]string, error) {
+	record := make([]string, len(fields))
+	next := 0
+	for i, f := range fields {
+		switch f {
+		case "date":
+			record[i] = now.Format("2006-01-02")
+		case "time":
+			record[i] = now.Format("15:04:05")
+		case "datetime":
+			record[i] = now.Format(time.RFC3339)
+		default:
+			if next >= len(values) {
+				return nil, fmt.Errorf("missing value for field %q", f)
+			}
+			record[i] = values[next]
+			next++
+		}
+	}
+	if next < len(values) {
+		return nil, fmt.Errorf("too many values: %d fields need %d, got %d", len(fields), next, len(values))
+	}
+	return record, nil
+}
+
+// formatRecord encodes one row, quoting fields as needed.
+func formatRecord(record []string, comma rune) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+	w.Write(record)
+	w.Flush()
+	return buf.String()
+}
+
+// appendRecord adds a row to existing file content, writing the header first
+// when the file is new. Unlike appendContent, rows are not separated by a
+// blank line.
+func appendRecord(existing string, header, record []string, comma rune) string {
+	if existing == "" {
+		return formatRecord(header, comma) + formatRecord(record, comma)
+	}
+	if !strings.HasSuffix(existing, "\n") {
+		existing += "\n"
+	}
+	return existing + formatRecord(record, comma)
+}
+
+// parseRecordInput splits piped input into values using the format's
+// delimiter, so scripts can send "acme,30,fixed bug" on stdin.
+func parseRecordInput(input string, comma rune) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(input))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	values, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("parsing input: %w", err)
+	}
+	return values, nil
+}
+
+// runRecord appends a structured row built from values (or, when there are
+// none, from a delimited line on stdin) to the record file for notePath. It
+// returns the process exit code.
+func runRecord(stdin io.Reader, stdout, stderr io.Writer, client *DropboxClient, notePath, format, fieldSpec string,
+	values []string, now time.Time) int {
+
+	comma, err := recordDelimiter(format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if fieldSpec == "" {
+		fmt.Fprintf(stderr, "-fields is required with -format %s\n", format)
+		return 2
+	}
+	fields := strings.Split(fieldSpec, ",")
+
+	if len(values) == 0 {
+		input, err := readInput(nil, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if values, err = parseRecordInput(input, comma); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	record, err := buildRecord(fields, values, now)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	p := recordPath(notePath, format)
+	existing, err := client.Download(p)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading %s: %v\n", p, err)
+		return 1
+	}
+	if err := client.Upload(p, appendRecord(existing, fields, record, comma)); err != nil {
+		fmt.Fprintf(stderr, "error: uploading %s: %v\n", p, err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Appended to %s\n", p)
+	return 0
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordPath(t *testing.T) {
+	cases := map[[2]string]string{
+		{"/Notes/Journal/2025/01/Note20250115.md", "csv"}: "/Notes/Journal/2025/01/Note20250115.csv",
+		{"/Logs/time.csv", "csv"}:                         "/Logs/time.csv",
+		{"/Logs/time", "tsv"}:                             "/Logs/time.tsv",
+	}
+	for in, want := range cases {
+		if got := recordPath(in[0], in[1]); got != want {
+			t.Errorf("recordPath(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+func TestBuildRecord(t *testing.T) {
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC)
+	got, err := buildRecord([]string{"time", "project", "minutes", "note"},
+		[]string{"acme", "30", "fixed bug"}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"14:30:45", "acme", "30", "fixed bug"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuildRecord_ValueCountMismatch(t *testing.T) {
+	now := time.Now()
+	if _, err := buildRecord([]string{"date", "a", "b"}, []string{"1"}, now); err == nil {
+		t.Error("expected error for missing value")
+	}
+	if _, err := buildRecord([]string{"a"}, []string{"1", "2"}, now); err == nil {
+		t.Error("expected error for extra value")
+	}
+}
+
+func TestFormatRecord_Escaping(t *testing.T) {
+	got := formatRecord([]string{"a,b", `say "hi"`, "plain"}, ',')
+	want := "\"a,b\",\"say \"\"hi\"\"\",plain\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := formatRecord([]string{"a b", "c\td"}, '\t'); got != "a b\t\"c\td\"\n" {
+		t.Errorf("tsv escaping: got %q", got)
+	}
+}
+
+func TestAppendRecord(t *testing.T) {
+	header := []string{"time", "note"}
+	got := appendRecord("", header, []string{"09:00:00", "first"}, ',')
+	if got != "time,note\n09:00:00,first\n" {
+		t.Errorf("new file: got %q", got)
+	}
+	got = appendRecord("time,note\n09:00:00,first", header, []string{"10:00:00", "second"}, ',')
+	if got != "time,note\n09:00:00,first\n10:00:00,second\n" {
+		t.Errorf("existing file: got %q", got)
+	}
+}
+
+func TestRunRecord(t *testing.T) {
+	var uploaded, uploadArg string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/2/files/download"):
+			w.WriteHeader(200)
+			w.Write([]byte("time,project,minutes,note\n09:00:00,acme,15,standup\n"))
+		case strings.HasSuffix(r.URL.Path, "/2/files/upload"):
+			uploaded = string(body)
+			uploadArg = r.Header.Get("Dropbox-API-Arg")
+			w.WriteHeader(200)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := runRecord(strings.NewReader(""), &stdout, &stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		"/Notes/Journal/2025/01/Note20250115.md", "csv", "time,project,minutes,note",
+		[]string{"acme", "30", "fixed bug, finally"},
+		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC))
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	want := "time,project,minutes,note\n09:00:00,acme,15,standup\n14:30:45,acme,30,\"fixed bug, finally\"\n"
+	if uploaded != want {
+		t.Errorf("uploaded:\n got %q\nwant %q", uploaded, want)
+	}
+	if !strings.Contains(uploadArg, "Note20250115.csv") {
+		t.Errorf("expected .csv target, got arg %q", uploadArg)
+	}
+}
+
+func TestRunRecord_RequiresFields(t *testing.T) {
+	var stderr bytes.Buffer
+	code := runRecord(strings.NewReader(""), io.Discard, &stderr, &DropboxClient{}, "/a.md", "csv", "", []string{"x"}, time.Now())
+	if code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxRemoteSearchResults caps the notes a remote search returns.
+const maxRemoteSearchResults = 1000
+
+// remoteNote is a note matched by files/search_v2.
+type remoteNote struct {
+	Date string // 2006-01-02
+	Path string
+	Rev  string
+}
+
+// SearchNotes returns the daily notes of the path template tmpl whose
+// content or name matches query, using Dropbox's full-text search
+// (files/search_v2), oldest first. Other files are skipped.
+func (c *DropboxClient) SearchNotes(tmpl, query string) ([]remoteNote, error) {
+	root := templateRoot(tmpl)
+	var page struct {
+		Matches []struct {
+			Metadata struct {
+				Metadata FolderEntry `json:"metadata"`
+			} `json:"metadata"`
+		} `json:"matches"`
+		Cursor  string `json:"cursor"`
+		HasMore bool   `json:"has_more"`
+	}
+	options := map[string]interface{}{
+		"max_results":     100,
+		"file_extensions": []string{"md"},
+		"file_status":     "active",
+	}
+	if root != "" && root != "/" {
+		options["path"] = strings.TrimSuffix(root, "/")
+	}
+	body, err := c.rpc("/2/files/search_v2", map[string]interface{}{"query": query, "options": options})
+
+	var notes []remoteNote
+	for {
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("parsing search_v2 response: %w", err)
+		}
+		for _, m := range page.Matches {
+			f := m.Metadata.Metadata
+			date, ok := templateDate(tmpl, f.PathDisplay)
+			if f.Tag != "file" || !ok {
+				continue
+			}
+			notes = append(notes, remoteNote{Date: date.Format("2006-01-02"), Path: f.PathDisplay, Rev: f.Rev})
+		}
+		if !page.HasMore || page.Cursor == "" || len(notes) >= maxRemoteSearchResults {
+			break
+		}
+		body, err = c.rpc("/2/files/search/continue_v2", map[string]string{"cursor": page.Cursor})
+		page.Matches = nil
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Date < notes[j].Date })
+	return notes, nil
+}
+
+// runRemoteSearch searches the journal with Dropbox's full-text search
+// instead of the local index. By default it only lists the matching notes,
+// which needs no downloads; when entries are needed (full, or a tag or
+// author filter) the matching notes are downloaded and searched like the
+// index.
+func runRemoteSearch(out, stderr io.Writer, client *DropboxClient, tmpl string, terms []string,
+	filter entryFilter, full bool) int {
+
+	if len(terms) == 0 {
+		fmt.Fprintln(stderr, "-remote needs search terms")
+		return 2
+	}
+	notes, err := client.SearchNotes(tmpl, strings.Join(terms, " "))
+	if err != nil {
+		fmt.Fprintf(stderr, "error: searching Dropbox: %v\n", err)
+		return 1
+	}
+	var matched []remoteNote
+	for _, n := range notes {
+		if filter.Dates.match(n.Date) {
+			matched = append(matched, n)
+		}
+	}
+
+	if !full && filter.Tag == "" && filter.Author == "" {
+		for _, n := range matched {
+			fmt.Fprintf(out, "%s  %s\n", n.Date, n.Path)
+		}
+		return 0
+	}
+	idx := &localIndex{Notes: map[string]*indexedNote{}}
+	for _, n := range matched {
+		content, err := client.Download(n.Path)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: downloading %s: %v\n", n.Path, err)
+			return 1
+		}
+		date, _ := time.Parse("2006-01-02", n.Date)
+		idx.update(n.Path, content, n.Rev, date)
+	}
+	printSearchResults(out, searchEntries(idx.entries(), terms, filter), full)
+	return 0
+}
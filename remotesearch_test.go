@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// searchServer fakes files/search_v2 over files, one match per page, and
+// serves downloads of them.
+func searchServer(t *testing.T, files map[string]string) *httptest.Server {
+	download := rolloverServer(files)
+	t.Cleanup(download.Close)
+	var matches []string
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg struct {
+			Query  string `json:"query"`
+			Cursor string `json:"cursor"`
+		}
+		switch r.URL.Path {
+		case "/2/files/search_v2":
+			json.NewDecoder(r.Body).Decode(&arg)
+			matches = nil
+			for p, content := range files {
+				if strings.Contains(content, arg.Query) {
+					matches = append(matches, p)
+				}
+			}
+			arg.Cursor = "0"
+		case "/2/files/search/continue_v2":
+			json.NewDecoder(r.Body).Decode(&arg)
+		default:
+			download.Config.Handler.ServeHTTP(w, r)
+			return
+		}
+		var i int
+		fmt.Sscan(arg.Cursor, &i)
+		if i >= len(matches) {
+			w.Write([]byte(`{"matches":[],"has_more":false}`))
+			return
+		}
+		fmt.Fprintf(w, `{"matches":[{"metadata":{".tag":"metadata","metadata":{".tag":"file","path_display":%q,"rev":"r"}}}],"cursor":"%d","has_more":%v}`,
+			matches[i], i+1, i+1 < len(matches))
+	}))
+}
+
+func TestRunRemoteSearch(t *testing.T) {
+	files := map[string]string{
+		"/Journal/20250113.md": "### 09:00:00\nspec review #work\n\n### 10:00:00\nlunch\n",
+		"/Journal/20250115.md": "### 14:00:00\nsecond spec review\n",
+		"/Journal/20250116.md": "### 14:00:00\nnothing here\n",
+	}
+	srv := searchServer(t, files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	var out, stderr bytes.Buffer
+	if code := runRemoteSearch(&out, &stderr, client, "/Journal/{{.Date}}.md", []string{"spec review"}, entryFilter{}, false); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if want := "2025-01-13  /Journal/20250113.md\n2025-01-15  /Journal/20250115.md\n"; out.String() != want {
+		t.Errorf("notes:\n%s", out.String())
+	}
+
+	// A tag filter needs the entries, so the notes are downloaded.
+	out.Reset()
+	if code := runRemoteSearch(&out, &stderr, client, "/Journal/{{.Date}}.md", []string{"spec review"}, entryFilter{Tag: "work"}, false); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if want := "2025-01-13 09:00:00  spec review #work\n"; out.String() != want {
+		t.Errorf("entries:\n%s", out.String())
+	}
+
+	if code := runRemoteSearch(&out, &stderr, client, "/Journal/{{.Date}}.md", nil, entryFilter{}, false); code != 2 {
+		t.Errorf("no terms: exit %d", code)
+	}
+}
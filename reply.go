@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxReplyAttempts bounds how often a reply is retried when the note
+// changes between download and upload.
+const maxReplyAttempts = 3
+
+// entryIDDate matches the date in an entry ID such as note20250115-3.
+var entryIDDate = regexp.MustCompile(`(\d{8})-\d+$`)
+
+// findEntry returns the byte range of the entry to in content. to is an
+// entry ID from an anchor (note20250115-3) or an entry number: the number
+// in a numbered header, or else the entry's position in the note.
+func findEntry(content, to string) (start, end int, err error) {
+	locs := entryHeaderPattern.FindAllStringSubmatchIndex(content, -1)
+	n, numErr := strconv.Atoi(to)
+	for i, loc := range locs {
+		end := len(content)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		if numErr != nil {
+			body := strings.TrimLeft(content[loc[1]:end], "\n")
+			if m := entryAnchorPattern.FindStringSubmatch(body); m != nil && strings.HasPrefix(body, m[0]) && m[1] == to {
+				return loc[0], end, nil
+			}
+			continue
+		}
+		number := i + 1
+		if loc[2] >= 0 {
+			number, _ = strconv.Atoi(content[loc[2]:loc[3]])
+		}
+		if number == n {
+			return loc[0], end, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no entry %s", to)
+}
+
+// formatReply renders text as a quoted reply block written at now.
+func formatReply(now time.Time, text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	lines[0] = "↪ " + now.Format("15:04") + " " + lines[0]
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// insertReply adds reply at the end of the entry to in content, after any
+// earlier replies and before the blank lines separating it from the next
+// entry. A quote block needs a blank line before it, and consecutive
+// replies are kept apart by an empty quote line.
+func insertReply(content, to, reply string) (string, error) {
+	start, end, err := findEntry(content, to)
+	if err != nil {
+		return "", err
+	}
+	ins := end
+	for ins > start && (content[ins-1] == '\n' || content[ins-1] == ' ') {
+		ins--
+	}
+	before := content[:ins] + "\n"
+	if lastLine := before[strings.LastIndex(before[:len(before)-1], "\n")+1:]; strings.HasPrefix(lastLine, ">") {
+		before += ">\n"
+	} else {
+		before += "\n"
+	}
+	after := content[ins:]
+	if strings.HasPrefix(after, "\n") {
+		after = after[1:]
+	}
+	return before + reply + after, nil
+}
+
+// runReply implements `dropbox-appender reply -to <entry> "text"`, which
+// adds a quoted follow-up under an earlier entry instead of at the end of
+// the note. The note is the one the entry ID is dated with, unless -date or
+// -path is given. It returns the process exit code.
+func runReply(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("reply", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	to := fs.String("to", "", "entry to reply to: its anchor ID (e.g. note20250115-3) or number")
+	date := fs.String("date", "", "the entry is in the note for this date (YYYY-MM-DD)")
+	notePath := fs.String("path", "", "the entry is in this Dropbox file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	text := strings.Join(fs.Args(), " ")
+	if *to == "" || strings.TrimSpace(text) == "" {
+		fmt.Fprintln(stderr, `usage: dropbox-appender reply -to <entry-id> [-date YYYY-MM-DD | -path PATH] "text"`)
+		return 2
+	}
+	now := clock.Now()
+	noteDate := now
+	if m := entryIDDate.FindStringSubmatch(*to); m != nil {
+		if d, err := time.ParseInLocation("20060102", m[1], time.Local); err == nil {
+			noteDate = d
+		}
+	}
+	if *date != "" {
+		d, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+			return 2
+		}
+		noteDate = d
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	p := *notePath
+	if _, numErr := strconv.Atoi(*to); p == "" && *date == "" && numErr != nil && entryIDDate.FindStringSubmatch(*to) == nil {
+		// ULID entry IDs carry no date, but the local index knows their note.
+		if idx, err := loadIndex(defaultIndexPath()); err == nil {
+			if e, err := findEntryByID(idx.entries(), *to); err == nil {
+				p, *to = e.Path, e.ID
+			}
+		}
+	}
+	if p == "" {
+		if p, err = journalPath(cfg, "", noteDate); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+	}
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	return runReplyWithClient(stdout, stderr, client, p, *to, formatReply(now, expandShortcodes(text, cfg.Shortcodes)), opts)
+}
+
+// runReplyWithClient inserts reply under entry to of the note at notePath,
+// uploading against the downloaded revision so a concurrent append isn't
+// overwritten; on a conflict it starts over with the new version. The reply
+// is logged, indexed and mirrored like an appended entry, per opts.
+func runReplyWithClient(stdout, stderr io.Writer, client *DropboxClient, notePath, to, reply string, opts appendOptions) int {
+	for attempt := 1; ; attempt++ {
+		existing, rev, err := client.DownloadRev(notePath)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: downloading journal: %v\n", err)
+			return 1
+		}
+		if existing == "" {
+			fmt.Fprintf(stderr, "error: there is no note at %s\n", notePath)
+			return 1
+		}
+		eol, err := lineEnding(opts.LineEndings, existing)
+		if err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		updated, err := insertReply(toLF(existing), to, reply)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v in %s\n", err, notePath)
+			return 1
+		}
+		data := withLineEnding(updated, eol)
+		batch := logEntries(opts.WALPath, notePath, notePath, []string{reply})
+		newRev, err := client.UploadRev(notePath, data, rev)
+		if err != nil && batch != "" {
+			appendWAL(opts.WALPath, []walRecord{{Batch: batch, At: time.Now(), Failed: true}})
+		}
+		if errors.Is(err, ErrConflict) && attempt < maxReplyAttempts {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "error: uploading journal: %v\n", err)
+			return 1
+		}
+		wroteNote(client, notePath, notePath, updated, data, newRev, opts)
+		fmt.Fprintf(stdout, "Replied to %s in %s\n", to, notePath)
+		return 0
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const replyNote = "### 1. 09:00:00\n<a id=\"note20250115-1\"></a>\nplanning\n\n" +
+	"### 2. 10:00:00\n<a id=\"note20250115-2\"></a>\nspec review\n"
+
+func TestInsertReply(t *testing.T) {
+	reply := formatReply(time.Date(2025, 1, 15, 15, 2, 0, 0, time.UTC), "moved to Friday")
+	if reply != "> ↪ 15:02 moved to Friday\n" {
+		t.Fatalf("formatReply = %q", reply)
+	}
+
+	got, err := insertReply(replyNote, "note20250115-1", reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "### 1. 09:00:00\n<a id=\"note20250115-1\"></a>\nplanning\n\n> ↪ 15:02 moved to Friday\n\n" +
+		"### 2. 10:00:00\n<a id=\"note20250115-2\"></a>\nspec review\n"
+	if got != want {
+		t.Errorf("reply to first entry:\n got %q\nwant %q", got, want)
+	}
+
+	// A second reply to the same entry goes under the first one.
+	got, _ = insertReply(got, "1", "> ↪ 16:00 done\n")
+	want = "### 1. 09:00:00\n<a id=\"note20250115-1\"></a>\nplanning\n\n> ↪ 15:02 moved to Friday\n>\n> ↪ 16:00 done\n\n" +
+		"### 2. 10:00:00\n<a id=\"note20250115-2\"></a>\nspec review\n"
+	if got != want {
+		t.Errorf("second reply:\n got %q\nwant %q", got, want)
+	}
+
+	// The last entry, addressed by number.
+	got, _ = insertReply(replyNote, "2", reply)
+	if want := replyNote + "\n> ↪ 15:02 moved to Friday\n"; got != want {
+		t.Errorf("reply to last entry:\n got %q\nwant %q", got, want)
+	}
+
+	if _, err := insertReply(replyNote, "note20250115-9", reply); err == nil {
+		t.Error("expected an error for an unknown entry")
+	}
+}
+
+func TestFindEntry_Unnumbered(t *testing.T) {
+	content := "### 09:00:00\nfirst\n\n### 10:00:00\nsecond\n"
+	start, end, err := findEntry(content, "2")
+	if err != nil || content[start:end] != "### 10:00:00\nsecond\n" {
+		t.Errorf("findEntry = %q, %v", content[start:end], err)
+	}
+}
+
+func TestRunReplyWithClient(t *testing.T) {
+	files := map[string]string{"/Journal/20250115.md": replyNote}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	var stdout, stderr bytes.Buffer
+	if code := runReplyWithClient(&stdout, &stderr, client, "/Journal/20250115.md", "note20250115-2", "> ↪ 15:02 ok\n", appendOptions{}); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if want := replyNote + "\n> ↪ 15:02 ok\n"; files["/Journal/20250115.md"] != want {
+		t.Errorf("note = %q", files["/Journal/20250115.md"])
+	}
+	if code := runReplyWithClient(&stdout, &stderr, client, "/Journal/20250116.md", "1", "> x\n", appendOptions{}); code != 1 {
+		t.Errorf("missing note: exit %d", code)
+	}
+}
+
+func TestRunReplyWithClient_LogsIndexesAndMirrors(t *testing.T) {
+	setupGitIdentity(t)
+	notePath := "/Journal/20250115.md"
+	files := map[string]string{notePath: replyNote}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	dir := t.TempDir()
+	opts := appendOptions{
+		PathTemplate: `/Journal/{{.Time.Format "20060102"}}.md`,
+		IndexPath:    filepath.Join(dir, "index.json"),
+		WALPath:      filepath.Join(dir, "wal.jsonl"),
+		GitMirror:    &GitMirrorConfig{Repo: filepath.Join(dir, "mirror")},
+	}
+	var stderr bytes.Buffer
+	if code := runReplyWithClient(io.Discard, &stderr, client, notePath, "note20250115-2", "> ↪ 15:02 ok\n", opts); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if records, err := readWAL(opts.WALPath); err != nil || len(records) != 1 || records[0].Entry != "> ↪ 15:02 ok\n" {
+		t.Errorf("write-ahead log = %+v, %v", records, err)
+	}
+	if idx, err := loadIndex(opts.IndexPath); err != nil || idx.Notes[notePath] == nil {
+		t.Errorf("note not indexed: %v", err)
+	}
+	mirrored, _ := os.ReadFile(filepath.Join(dir, "mirror", "Journal", "20250115.md"))
+	if string(mirrored) != files[notePath] {
+		t.Errorf("mirror out of sync: %q", mirrored)
+	}
+}
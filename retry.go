@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls which failed Dropbox requests are retried. The zero
+// value disables retries.
+type RetryPolicy struct {
+	MaxRetries int           // retries after the first attempt; 0 disables retrying
+	Budget     time.Duration // time after which no further retry is started; 0 means unbounded
+	Timeout    time.Duration // limit for each attempt; 0 means none, so large transfers can take as long as they need
+	BaseDelay  time.Duration // backoff before the first retry, doubled each time
+	Statuses   []int         // HTTP statuses that are retried
+	Errors     []string      // Dropbox error_summary substrings that are retried
+}
+
+// RetryConfig is the "retry" section of the config file. Unset fields fall
+// back to defaultRetryPolicy.
+type RetryConfig struct {
+	MaxRetries *int     `json:"max_retries,omitempty"`
+	Budget     string   `json:"budget,omitempty"`
+	Timeout    string   `json:"request_timeout,omitempty"`
+	Statuses   []int    `json:"statuses,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// defaultRetryPolicy retries rate limiting and transient server errors,
+// including Dropbox's too_many_write_operations lock contention.
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		Budget:     30 * time.Second,
+		BaseDelay:  500 * time.Millisecond,
+		Statuses:   []int{429, 500, 502, 503, 504},
+		Errors:     []string{"too_many_write_operations", "too_many_requests"},
+	}
+}
+
+// retryPolicy returns the default policy with any overrides from cfg applied.
+func retryPolicy(cfg *Config) (RetryPolicy, error) {
+	p := defaultRetryPolicy()
+	if cfg.Retry == nil {
+		return p, nil
+	}
+	if cfg.Retry.MaxRetries != nil {
+		p.MaxRetries = *cfg.Retry.MaxRetries
+	}
+	if cfg.Retry.Budget != "" {
+		d, err := time.ParseDuration(cfg.Retry.Budget)
+		if err != nil {
+			return p, fmt.Errorf("invalid retry budget %q: %w", cfg.Retry.Budget, err)
+		}
+		p.Budget = d
+	}
+	if cfg.Retry.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Retry.Timeout)
+		if err != nil {
+			return p, fmt.Errorf("invalid retry request_timeout %q: %w", cfg.Retry.Timeout, err)
+		}
+		p.Timeout = d
+	}
+	if cfg.Retry.Statuses != nil {
+		p.Statuses = cfg.Retry.Statuses
+	}
+	if cfg.Retry.Errors != nil {
+		p.Errors = cfg.Retry.Errors
+	}
+	return p, nil
+}
+
+// retryable reports whether a request that produced resp/body/err should be
+// tried again. Network errors are always considered transient.
+func (p RetryPolicy) retryable(resp *http.Response, body []byte, err error) bool {
+	if err != nil {
+		return true
+	}
+	for _, s := range p.Statuses {
+		if resp.StatusCode == s {
+			return true
+		}
+	}
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			ErrorSummary string `json:"error_summary"`
+		}
+		json.Unmarshal(body, &apiErr)
+		for _, e := range p.Errors {
+			if apiErr.ErrorSummary != "" && strings.Contains(apiErr.ErrorSummary, e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before retry number attempt (0-based),
+// honouring a Retry-After header when the server sends one.
+func (p RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return p.BaseDelay << attempt
+}
+
+// notSent reports whether the network error err happened before the
+// request reached Dropbox, i.e. while resolving or connecting, so sending
+// it again can't repeat a write.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) || errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// do sends the request built by newRequest, retrying according to c.Retry.
+// newRequest is called once per attempt so request bodies can be replayed.
+// The response body is read and closed; it is returned alongside the response.
+// The budget only stops further retries, as an upload or download can take
+// longer than it; Timeout, when set, cuts off each attempt, so a hung
+// connection can't block forever. A request rejected with 401 is
+// sent once more after refreshing the access token (see Refresh). Unless
+// replay is set, a request that failed on the network after it was sent
+// isn't retried, as Dropbox may have carried it out.
+func (c *DropboxClient) do(replay bool, newRequest func() (*http.Request, error)) (*http.Response, []byte, error) {
+	start := time.Now()
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating request: %w", err)
+		}
+		cancel := func() {}
+		if c.Retry.Timeout > 0 {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(req.Context(), c.Retry.Timeout)
+			req = req.WithContext(ctx)
+		}
+
+		var body []byte
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		if err == nil && resp.StatusCode == 401 && !refreshed && c.refreshToken(req) {
+			refreshed = true
+			attempt--
+			continue
+		}
+		if err != nil && !replay && !notSent(err) {
+			return resp, body, err
+		}
+		if attempt >= c.Retry.MaxRetries || !c.Retry.retryable(resp, body, err) {
+			return resp, body, err
+		}
+		wait := c.Retry.delay(attempt, resp)
+		if c.Retry.Budget > 0 && time.Since(start)+wait > c.Retry.Budget {
+			return resp, body, err
+		}
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Defaults(t *testing.T) {
+	p, err := retryPolicy(&Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.MaxRetries != 3 || p.Budget != 30*time.Second {
+		t.Errorf("unexpected defaults: %+v", p)
+	}
+}
+
+func TestRetryPolicy_ConfigOverrides(t *testing.T) {
+	zero := 0
+	cfg := &Config{Retry: &RetryConfig{
+		MaxRetries: &zero,
+		Budget:     "5s",
+		Timeout:    "2m",
+		Statuses:   []int{503},
+		Errors:     []string{"locked"},
+	}}
+	p, err := retryPolicy(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.MaxRetries != 0 || p.Budget != 5*time.Second || p.Timeout != 2*time.Minute {
+		t.Errorf("overrides not applied: %+v", p)
+	}
+	if len(p.Statuses) != 1 || p.Statuses[0] != 503 || len(p.Errors) != 1 || p.Errors[0] != "locked" {
+		t.Errorf("lists not replaced: %+v", p)
+	}
+}
+
+func TestRetryPolicy_InvalidBudget(t *testing.T) {
+	if _, err := retryPolicy(&Config{Retry: &RetryConfig{Budget: "soon"}}); err == nil {
+		t.Fatal("expected error for invalid budget")
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	p := defaultRetryPolicy()
+	cases := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{429, `{"error_summary": "too_many_requests/"}`, true},
+		{503, ``, true},
+		{409, `{"error_summary": "path/too_many_write_operations/"}`, true},
+		{409, `{"error_summary": "path/not_found/"}`, false},
+		{400, `bad request`, false},
+		{200, `{}`, false},
+	}
+	for _, c := range cases {
+		resp := &http.Response{StatusCode: c.status, Header: http.Header{}}
+		if got := p.retryable(resp, []byte(c.body), nil); got != c.want {
+			t.Errorf("retryable(%d, %s) = %v, want %v", c.status, c.body, got, c.want)
+		}
+	}
+}
+
+func TestRetryDelay_RetryAfter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	resp := &http.Response{Header: http.Header{"Retry-After": {"2"}}}
+	if got := p.delay(0, resp); got != 2*time.Second {
+		t.Errorf("expected Retry-After to win, got %v", got)
+	}
+	if got := p.delay(2, &http.Response{Header: http.Header{}}); got != 400*time.Millisecond {
+		t.Errorf("expected exponential backoff, got %v", got)
+	}
+}
+
+func TestDownload_RetriesTransientErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(503)
+			return
+		}
+		w.WriteHeader(200)
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	policy := defaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL, Retry: policy}
+	content, err := client.Download("/a.md")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "content" || calls != 3 {
+		t.Errorf("expected success on third attempt, got %q after %d calls", content, calls)
+	}
+}
+
+func TestUpload_RetryLimits(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error_summary": "path/too_many_write_operations/"}`))
+	}))
+	defer server.Close()
+
+	policy := defaultRetryPolicy()
+	policy.MaxRetries = 2
+	policy.BaseDelay = time.Millisecond
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL, Retry: policy}
+	if err := client.Upload("/a.md", "x"); err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+
+	calls = 0
+	policy.MaxRetries = 10
+	policy.BaseDelay = 50 * time.Millisecond
+	policy.Budget = 60 * time.Millisecond
+	client.Retry = policy
+	client.Upload("/a.md", "x")
+	if calls > 2 {
+		t.Errorf("expected the budget to stop retries early, got %d attempts", calls)
+	}
+}
+
+func TestDownload_TimeoutCutsOffHungRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	policy := defaultRetryPolicy()
+	policy.Budget = 100 * time.Millisecond
+	policy.Timeout = 100 * time.Millisecond
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL, Retry: policy}
+	start := time.Now()
+	if _, err := client.Download("/a.md"); err == nil {
+		t.Fatal("expected the hung request to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request ran %v past a 100ms timeout", elapsed)
+	}
+}
+
+func TestDownload_BudgetDoesNotCutOffSlowRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	policy := defaultRetryPolicy()
+	policy.Budget = 50 * time.Millisecond
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL, Retry: policy}
+	if content, err := client.Download("/a.md"); err != nil || content != "content" {
+		t.Errorf("got %q %v, want a request slower than the budget to finish", content, err)
+	}
+}
+
+func TestNoRetriesByDefaultOnZeroPolicy(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	client.Download("/a.md")
+	if calls != 1 {
+		t.Errorf("expected a single attempt, got %d", calls)
+	}
+}
+
+// commitOnceServer stores the first upload and then fails with fail; later
+// uploads are refused as conflicts, as the rev they carry is out of date.
+func commitOnceServer(t *testing.T, fail func(w http.ResponseWriter)) (*httptest.Server, *int) {
+	uploads := 0
+	var stored []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/files/upload":
+			uploads++
+			if stored != nil {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/conflict/file/"}`))
+				return
+			}
+			stored, _ = io.ReadAll(r.Body)
+			fail(w)
+		case "/2/files/get_metadata":
+			fmt.Fprintf(w, `{"rev": "r2", "content_hash": %q}`, contentHash(stored))
+		default:
+			t.Errorf("unexpected call %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &uploads
+}
+
+func TestUploadRev_LostResponseIsNotRetried(t *testing.T) {
+	server, uploads := commitOnceServer(t, func(w http.ResponseWriter) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	})
+	policy := defaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL, Retry: policy}
+	rev, err := client.UploadRev("/a.md", "entry", "r1")
+	if err != nil || rev != "r2" {
+		t.Errorf("got %q %v, want the write found by its content", rev, err)
+	}
+	if *uploads != 1 {
+		t.Errorf("expected a single upload, got %d", *uploads)
+	}
+}
+
+func TestUploadRev_ConflictOnRetryChecksContent(t *testing.T) {
+	server, uploads := commitOnceServer(t, func(w http.ResponseWriter) { w.WriteHeader(503) })
+	policy := defaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL, Retry: policy}
+	rev, err := client.UploadRev("/a.md", "entry", "r1")
+	if err != nil || rev != "r2" {
+		t.Errorf("got %q %v, want the earlier attempt's write", rev, err)
+	}
+	if *uploads != 2 {
+		t.Errorf("expected one retry, got %d uploads", *uploads)
+	}
+
+	// Other content at the path is still a conflict.
+	if _, err := client.UploadRev("/a.md", "another entry", "r1"); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	// The SHA-256 of the SHA-256 of "abc", its only block.
+	inner := sha256.Sum256([]byte("abc"))
+	want := sha256.Sum256(inner[:])
+	if got := contentHash([]byte("abc")); got != hex.EncodeToString(want[:]) {
+		t.Errorf("contentHash = %s", got)
+	}
+	if contentHash(make([]byte, 4<<20+1)) == contentHash(make([]byte, 4<<20)) {
+		t.Error("expected a second block to change the hash")
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultRevisionLimit is how many revisions `revisions` lists by default.
+const defaultRevisionLimit = 10
+
+// fileRevision is one entry of a files/list_revisions response.
+type fileRevision struct {
+	Rev            string    `json:"rev"`
+	Size           int64     `json:"size"`
+	ServerModified time.Time `json:"server_modified"`
+}
+
+// ListRevisions returns up to limit revisions of the file at path, newest
+// first, with files/list_revisions.
+func (c *DropboxClient) ListRevisions(path string, limit int) ([]fileRevision, error) {
+	body, err := c.rpc("/2/files/list_revisions", map[string]interface{}{
+		"path":  path,
+		"mode":  "path",
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Entries []fileRevision `json:"entries"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parsing list_revisions response: %w", err)
+	}
+	return result.Entries, nil
+}
+
+// Restore reverts the file at path to rev with files/restore and returns the
+// rev of the newly written version.
+func (c *DropboxClient) Restore(path, rev string) (string, error) {
+	body, err := c.rpc("/2/files/restore", map[string]string{
+		"path": path,
+		"rev":  rev,
+	})
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Rev string `json:"rev"`
+	}
+	json.Unmarshal(body, &result)
+	return result.Rev, nil
+}
+
+// runRevisions implements `dropbox-appender revisions`, which lists the
+// Dropbox revisions of a note, and `revisions restore <rev>`, which reverts
+// the note to one of them. It returns the process exit code.
+func runRevisions(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	restore := len(args) > 0 && args[0] == "restore"
+	if restore {
+		args = args[1:]
+	}
+	fs := flag.NewFlagSet("revisions", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	date := fs.String("date", "", "use the note for this date (YYYY-MM-DD) instead of today")
+	notePath := fs.String("path", "", "use this Dropbox path instead of a dated note")
+	limit := fs.Int("n", defaultRevisionLimit, "number of revisions to list")
+	yes := fs.Bool("yes", false, "restore without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	rest := fs.Args()
+	if !restore && len(rest) > 0 && rest[0] == "restore" {
+		restore, rest = true, rest[1:]
+	}
+	var rev string
+	switch {
+	case restore && len(rest) == 1:
+		rev = rest[0]
+	case restore:
+		fmt.Fprintln(stderr, "usage: dropbox-appender revisions restore [-date YYYY-MM-DD | -path PATH] [-yes] <rev>")
+		return 2
+	case len(rest) > 0:
+		fmt.Fprintf(stderr, "unexpected argument %q\n", rest[0])
+		return 2
+	}
+
+	now := clock.Now()
+	if *date != "" {
+		d, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+			return 2
+		}
+		now = d
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	p := *notePath
+	if p == "" {
+		if p, err = journalPath(cfg, "", now); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+	}
+	client, err := connectClient(cfg, restore)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if restore {
+		return runRevisionsRestoreWithClient(bufio.NewScanner(stdin), stdout, stderr, client, p, rev, *yes)
+	}
+	return runRevisionsListWithClient(stdout, stderr, client, p, *limit)
+}
+
+// runRevisionsListWithClient prints the revisions of notePath, newest first.
+func runRevisionsListWithClient(stdout, stderr io.Writer, client *DropboxClient, notePath string, limit int) int {
+	revs, err := client.ListRevisions(notePath, limit)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: listing revisions of %s: %v\n", notePath, err)
+		return 1
+	}
+	if len(revs) == 0 {
+		fmt.Fprintf(stderr, "no revisions of %s\n", notePath)
+		return 0
+	}
+	for i, r := range revs {
+		line := fmt.Sprintf("%s  %s  %d bytes", r.ServerModified.Local().Format("2006-01-02 15:04:05"), r.Rev, r.Size)
+		if i == 0 {
+			line += "  (current)"
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	return 0
+}
+
+// runRevisionsRestoreWithClient reverts notePath to rev after confirming with
+// the user (unless yes is set). rev must be one of the note's revisions, so a
+// rev copied from another day's listing is caught before anything is written.
+func runRevisionsRestoreWithClient(in *bufio.Scanner, stdout, stderr io.Writer,
+	client *DropboxClient, notePath, rev string, yes bool) int {
+
+	revs, err := client.ListRevisions(notePath, 100)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: listing revisions of %s: %v\n", notePath, err)
+		return 1
+	}
+	var target *fileRevision
+	for i := range revs {
+		if revs[i].Rev == rev {
+			target = &revs[i]
+			break
+		}
+	}
+	if target == nil {
+		fmt.Fprintf(stderr, "error: %s is not a revision of %s\n", rev, notePath)
+		return 1
+	}
+	if target == &revs[0] {
+		fmt.Fprintf(stdout, "%s is already at revision %s\n", notePath, rev)
+		return 0
+	}
+
+	when := target.ServerModified.Local().Format("2006-01-02 15:04:05")
+	if !yes {
+		fmt.Fprintf(stdout, tr("Restore %s to revision %s from %s (%d bytes)? [y/N] "), notePath, rev, when, target.Size)
+		in.Scan()
+		if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(in.Text())), "y") {
+			fmt.Fprintln(stdout, tr("Aborted."))
+			return 1
+		}
+	}
+
+	if _, err := client.Restore(notePath, rev); err != nil {
+		fmt.Fprintf(stderr, "error: restoring %s: %v\n", notePath, err)
+		return 1
+	}
+	invalidateTodayCache(todayCachePath())
+	fmt.Fprintf(stdout, tr("Restored %s to revision %s from %s\n"), notePath, rev, when)
+	return 0
+}
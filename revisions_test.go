@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// revisionsServer fakes list_revisions and restore for a single note.
+func revisionsServer(t *testing.T, restored *string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&arg)
+		switch r.URL.Path {
+		case "/2/files/list_revisions":
+			if arg["path"] != "/Journal/2025-01-15.md" {
+				t.Errorf("list_revisions path = %v", arg["path"])
+			}
+			io.WriteString(w, `{"is_deleted":false,"entries":[
+				{"rev":"a3","size":300,"server_modified":"2025-01-15T18:00:00Z"},
+				{"rev":"a2","size":200,"server_modified":"2025-01-15T12:00:00Z"},
+				{"rev":"a1","size":100,"server_modified":"2025-01-15T09:00:00Z"}]}`)
+		case "/2/files/restore":
+			*restored = arg["rev"].(string)
+			io.WriteString(w, `{"rev":"a4"}`)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunRevisionsList(t *testing.T) {
+	var restored string
+	srv := revisionsServer(t, &restored)
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	var stdout, stderr bytes.Buffer
+	if code := runRevisionsListWithClient(&stdout, &stderr, client, "/Journal/2025-01-15.md", 10); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines:\n%s", len(lines), stdout.String())
+	}
+	if !strings.Contains(lines[0], "a3") || !strings.Contains(lines[0], "300 bytes") || !strings.HasSuffix(lines[0], "(current)") {
+		t.Errorf("first line = %q", lines[0])
+	}
+	if strings.Contains(lines[1], "(current)") {
+		t.Errorf("only the newest revision should be marked current: %q", lines[1])
+	}
+}
+
+func TestRunRevisionsRestore(t *testing.T) {
+	tests := []struct {
+		name         string
+		rev          string
+		answer       string
+		yes          bool
+		wantCode     int
+		wantRestored string
+	}{
+		{"confirmed", "a1", "y\n", false, 0, "a1"},
+		{"declined", "a1", "n\n", false, 1, ""},
+		{"no answer", "a1", "", false, 1, ""},
+		{"yes flag", "a2", "", true, 0, "a2"},
+		{"unknown rev", "zz", "y\n", false, 1, ""},
+		{"already current", "a3", "", false, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var restored string
+			srv := revisionsServer(t, &restored)
+			client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+			var stdout, stderr bytes.Buffer
+			in := bufio.NewScanner(strings.NewReader(tt.answer))
+			code := runRevisionsRestoreWithClient(in, &stdout, &stderr, client, "/Journal/2025-01-15.md", tt.rev, tt.yes)
+			if code != tt.wantCode {
+				t.Fatalf("exit %d, want %d; stdout: %s stderr: %s", code, tt.wantCode, stdout.String(), stderr.String())
+			}
+			if restored != tt.wantRestored {
+				t.Errorf("restored rev = %q, want %q", restored, tt.wantRestored)
+			}
+			if tt.yes && strings.Contains(stdout.String(), "[y/N]") {
+				t.Errorf("-yes should not prompt: %s", stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// appendOptions tunes how appendToJournal writes an entry. The zero value
+// appends to the note as-is.
+type appendOptions struct {
+	// MaxSize rolls entries over to continuation files (Note20250115-2.md, ...)
+	// once a note would grow beyond this many bytes. 0 disables rollover.
+	MaxSize int64
+	// Number numbers entries within the note (### 3. HH:MM:SS) and adds a
+	// stable anchor for deep links.
+	Number bool
+	// EntryIDs gives each entry a ULID anchor, a permalink that stays valid
+	// however the note is later edited; see `open -entry`.
+	EntryIDs bool
+	// IndexPath is the local index updated after each append; empty skips
+	// indexing.
+	IndexPath string
+	// CachePath is the cached today summary dropped after each append;
+	// empty leaves it alone.
+	CachePath string
+	// GitMirror, when set, commits each written note to a local git
+	// repository after upload.
+	GitMirror *GitMirrorConfig
+	// Author, when set, is added to each entry header.
+	Author string
+	// Atomic stages the upload in a temporary file and moves it into place.
+	Atomic bool
+	// Separator is the policy for what goes between entries; see
+	// separatorText.
+	Separator string
+	// LineEndings is the line_endings policy; see lineEnding.
+	LineEndings string
+	// Footer is a line that, when present, is kept last in the note: entries
+	// are inserted above it. See insertBeforeFooter.
+	Footer string
+	// Snapshots is how many pre-upload copies of each note to keep in
+	// SnapshotDir; 0 disables snapshots.
+	Snapshots   int
+	SnapshotDir string
+	// TargetFormat is "paper-md" to adjust entries for Dropbox Paper; see
+	// paperMarkdown.
+	TargetFormat string
+	// InflightDir records appends while they are in progress, so one cut
+	// short by a crash is completed by the next run; empty disables it.
+	// See recoverInflight.
+	InflightDir string
+	// WALPath is the write-ahead log each entry is recorded in before it is
+	// uploaded; empty disables it. See runReconcile.
+	WALPath string
+	// NoteProperties stamps each written note with the note stats file
+	// properties; see stampNoteProperties.
+	NoteProperties bool
+	// DayLinks starts each new daily note with links to the previous and
+	// next day's notes, found through PathTemplate; see newDayLinks.
+	DayLinks     *DayLinksConfig
+	PathTemplate string
+	// Sections groups entries under time-of-day headings; see
+	// insertInTimeSection.
+	Sections []TimeSection
+	// Uploads, when set, collects the upload of each note to be committed
+	// with the others of a bulk operation; see uploadBatch. Atomic uploads
+	// are made right away.
+	Uploads *uploadBatch
+	// Clock dates what an append records besides the entry itself, such as
+	// entry IDs, snapshots and git commits; nil uses the system clock.
+	Clock Clock
+}
+
+// now returns the time of opts.Clock.
+func (opts appendOptions) now() time.Time {
+	if opts.Clock == nil {
+		return time.Now()
+	}
+	return opts.Clock.Now()
+}
+
+// appendOptionsFromConfig builds the append options configured in cfg.
+func appendOptionsFromConfig(cfg *Config) (appendOptions, error) {
+	var opts appendOptions
+	if cfg.MaxNoteSize != "" {
+		n, err := parseSize(cfg.MaxNoteSize)
+		if err != nil {
+			return opts, fmt.Errorf("invalid max_note_size: %w", err)
+		}
+		opts.MaxSize = n
+	}
+	opts.Number = cfg.NumberEntries
+	opts.EntryIDs = cfg.EntryIDs
+	opts.IndexPath = defaultIndexPath()
+	opts.CachePath = todayCachePath()
+	opts.InflightDir = defaultInflightDir()
+	if cfg.WAL == nil || *cfg.WAL {
+		opts.WALPath = defaultWALPath()
+	}
+	if _, err := separatorText(cfg.Separator); err != nil {
+		return opts, err
+	}
+	opts.Separator = cfg.Separator
+	opts.Footer = strings.TrimSpace(cfg.Footer)
+	if _, err := lineEnding(cfg.LineEndings, ""); err != nil {
+		return opts, err
+	}
+	opts.LineEndings = cfg.LineEndings
+	if err := validTargetFormat(cfg.TargetFormat); err != nil {
+		return opts, err
+	}
+	opts.TargetFormat = cfg.TargetFormat
+	if cfg.Snapshots > 0 {
+		opts.Snapshots, opts.SnapshotDir = cfg.Snapshots, defaultSnapshotDir()
+	}
+	if cfg.Author != nil {
+		opts.Author = *cfg.Author
+		if opts.Author == "" {
+			opts.Author = defaultAuthor()
+		}
+	}
+	if cfg.GitMirror != nil && cfg.GitMirror.Repo != "" {
+		opts.GitMirror = cfg.GitMirror
+	}
+	opts.NoteProperties = cfg.NoteProperties
+	opts.DayLinks, opts.PathTemplate = cfg.DayLinks, cfg.PathTemplate
+	if err := validateSections(cfg.Sections); err != nil {
+		return opts, fmt.Errorf("invalid sections: %w", err)
+	}
+	opts.Sections = cfg.Sections
+	return opts, nil
+}
+
+// parseSize parses a byte size such as "500000", "256KB", "256k" or "1MB".
+// Units are powers of 1024.
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"KB", 1 << 10}, {"K", 1 << 10}, {"MB", 1 << 20}, {"M", 1 << 20}, {"GB", 1 << 30}, {"G", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// continuationPath returns part n of a daily note. Part 1 is the note itself;
+// later parts add -n before the extension.
+func continuationPath(p string, n int) string {
+	if n <= 1 {
+		return p
+	}
+	ext := path.Ext(p)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(p, ext), n, ext)
+}
+
+// noteLink returns a markdown link to a sibling note in the same folder.
+func noteLink(p string) string {
+	name := path.Base(p)
+	return fmt.Sprintf("[%s](%s)", strings.TrimSuffix(name, path.Ext(name)), url.PathEscape(name))
+}
+
+// activePart finds the part of the note at p that entry should be appended to,
+// following the continuation chain while parts are full. When a new part is
+// needed, the last full part gets a forward link and the new part starts with
+// a link back. It returns the part's path and current content.
+func activePart(client *DropboxClient, p, entry string, maxSize int64) (string, string, error) {
+	existing, err := client.Download(p)
+	if err != nil {
+		return "", "", err
+	}
+	if maxSize <= 0 {
+		return p, existing, nil
+	}
+
+	part, n := p, 1
+	for existing != "" && int64(len(appendContent(existing, entry))) > maxSize {
+		next := continuationPath(p, n+1)
+		nextContent, err := client.Download(next)
+		if err != nil {
+			return "", "", err
+		}
+		if nextContent == "" {
+			if err := client.Upload(part, appendContent(existing, "→ Continued in "+noteLink(next)+"\n")); err != nil {
+				return "", "", err
+			}
+			return next, "← Continued from " + noteLink(part) + "\n", nil
+		}
+		part, existing, n = next, nextContent, n+1
+	}
+	return part, existing, nil
+}
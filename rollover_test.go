@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"500000": 500000,
+		"256KB":  256 << 10,
+		"256k":   256 << 10,
+		"1MB":    1 << 20,
+		"2 mb":   2 << 20,
+		"10B":    10,
+	}
+	for in, want := range cases {
+		got, err := parseSize(in)
+		if err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "lots", "-5", "1.5MB"} {
+		if _, err := parseSize(bad); err == nil {
+			t.Errorf("parseSize(%q): expected error", bad)
+		}
+	}
+}
+
+func TestContinuationPath(t *testing.T) {
+	p := "/Notes/Journal/2025/01/Note20250115.md"
+	if got := continuationPath(p, 1); got != p {
+		t.Errorf("part 1: got %q", got)
+	}
+	if got := continuationPath(p, 3); got != "/Notes/Journal/2025/01/Note20250115-3.md" {
+		t.Errorf("part 3: got %q", got)
+	}
+}
+
+func TestNoteLink(t *testing.T) {
+	if got := noteLink("/Daily/2025-01-15 notes.md"); got != "[2025-01-15 notes](2025-01-15%20notes.md)" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// rolloverServer is an in-memory Dropbox holding files by path.
+func rolloverServer(files map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg struct {
+			Path string `json:"path"`
+		}
+		json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/2/files/download"):
+			content, ok := files[arg.Path]
+			if !ok {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+				return
+			}
+			w.Write([]byte(content))
+		case strings.HasSuffix(r.URL.Path, "/2/files/upload"):
+			body, _ := io.ReadAll(r.Body)
+			files[arg.Path] = string(body)
+			w.Write([]byte(`{}`))
+		}
+	}))
+}
+
+func TestAppendToJournal_RollsOverWhenFull(t *testing.T) {
+	base := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{base: strings.Repeat("x", 90) + "\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	written, err := appendToJournal(client, base, "### 14:30:45\nnew entry\n", appendOptions{MaxSize: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	part2 := "/Notes/Journal/2025/01/Note20250115-2.md"
+	if written != part2 {
+		t.Errorf("expected entry in %s, got %s", part2, written)
+	}
+	if !strings.HasSuffix(files[base], "→ Continued in [Note20250115-2](Note20250115-2.md)\n") {
+		t.Errorf("expected forward link in part 1, got %q", files[base])
+	}
+	want := "← Continued from [Note20250115](Note20250115.md)\n\n### 14:30:45\nnew entry\n"
+	if files[part2] != want {
+		t.Errorf("part 2:\n got %q\nwant %q", files[part2], want)
+	}
+
+	// The next entry follows the chain straight to part 2.
+	written, err = appendToJournal(client, base, "### 15:00:00\nmore\n", appendOptions{MaxSize: 100})
+	if err != nil || written != part2 {
+		t.Fatalf("expected second entry in part 2, got %s (err=%v)", written, err)
+	}
+	if strings.Count(files[base], "Continued in") != 1 {
+		t.Errorf("forward link should only be added once: %q", files[base])
+	}
+}
+
+func TestAppendToJournal_NoRolloverByDefault(t *testing.T) {
+	base := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{base: strings.Repeat("x", 5000)}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	written, err := appendToJournal(client, base, "entry\n", appendOptions{})
+	if err != nil || written != base {
+		t.Fatalf("expected append to %s, got %s (err=%v)", base, written, err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRunTail is how many lines of a command's output `run` keeps in
+// the entry.
+const defaultRunTail = 40
+
+// maxRunOutput caps how much output `run` holds in memory; only the end of
+// longer output is kept.
+const maxRunOutput = 1 << 20
+
+// runResult is what `run` records about a command.
+type runResult struct {
+	Output   string // combined stdout and stderr, as far as kept
+	Code     int    // exit status; -1 when killed by a signal
+	Duration time.Duration
+	Err      error // why the command couldn't be started, if it wasn't
+}
+
+// tailBuffer keeps the last max bytes written to it. Writes may come from
+// the stdout and stderr copiers at once.
+type tailBuffer struct {
+	mu      sync.Mutex
+	buf     []byte
+	max     int
+	dropped bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = b.buf[over:]
+		b.dropped = true
+	}
+	return len(p), nil
+}
+
+// String returns what was kept, starting at a whole line once the
+// beginning was dropped.
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := string(b.buf)
+	if b.dropped {
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	return s
+}
+
+// runCommand runs args without a shell, with stdin passed through. Its
+// output is captured and, with echo, also shown as it arrives.
+func runCommand(args []string, stdin io.Reader, stdout, stderr io.Writer, echo bool) runResult {
+	out := &tailBuffer{max: maxRunOutput}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stdout, cmd.Stderr = io.Writer(out), io.Writer(out)
+	if echo {
+		cmd.Stdout, cmd.Stderr = io.MultiWriter(stdout, out), io.MultiWriter(stderr, out)
+	}
+	start := time.Now()
+	err := cmd.Run()
+	res := runResult{Duration: time.Since(start), Output: out.String()}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		res.Code = exitErr.ExitCode()
+	case err != nil:
+		res.Code, res.Err = 127, err
+	}
+	return res
+}
+
+// shellQuote quotes args for display the way a POSIX shell would need them.
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a != "" && !strings.ContainsAny(a, " \t\n'\"\\$`*?[]#~=%|&;<>(){}!") {
+			quoted[i] = a
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// runDuration rounds d for display: milliseconds below a second, tenths of
+// a second below a minute, whole seconds above.
+func runDuration(d time.Duration) time.Duration {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond)
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
+
+// formatRun renders a command's run as entry text: an optional note, the
+// status line and the last tail lines of output (all with tail 0).
+func formatRun(cmdline string, res runResult, tail int, note string, noOutput bool) string {
+	var status string
+	switch {
+	case res.Err != nil:
+		status = fmt.Sprintf("✗ `%s` failed to start: %v", cmdline, res.Err)
+	case res.Code == 0:
+		status = fmt.Sprintf("✓ `%s` exited 0 after %v", cmdline, runDuration(res.Duration))
+	case res.Code < 0:
+		status = fmt.Sprintf("✗ `%s` was killed after %v", cmdline, runDuration(res.Duration))
+	default:
+		status = fmt.Sprintf("✗ `%s` exited %d after %v", cmdline, res.Code, runDuration(res.Duration))
+	}
+	text := status
+	if note = strings.TrimSpace(note); note != "" {
+		text = note + "\n\n" + status
+	}
+	if noOutput || res.Err != nil {
+		return text
+	}
+
+	output := strings.TrimRight(toLF(res.Output), "\n")
+	lines := strings.Split(output, "\n")
+	if tail > 0 && len(lines) > tail {
+		output = fmt.Sprintf("[… %d lines omitted]\n", len(lines)-tail) + strings.Join(lines[len(lines)-tail:], "\n")
+	}
+	return withCapture(text, formatCapture(cmdline, output))
+}
+
+// runRun implements the `dropbox-appender run -- <cmd...>` subcommand, a lab
+// notebook for ops work: it runs the command, showing its output as usual,
+// and appends its status, duration and output to today's note. It exits
+// with the command's status.
+func runRun(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	tail := fs.Int("tail", defaultRunTail, "keep this many of the last lines of output, 0 for all")
+	note := fs.String("m", "", "a note to add above the command")
+	noOutput := fs.Bool("no-output", false, "record only the command and its status")
+	quiet := fs.Bool("quiet", false, "don't show the command's output while it runs")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 || *tail < 0 {
+		fmt.Fprintln(stderr, "usage: dropbox-appender run [-m note] [-tail N] [-no-output] [-quiet] -- command [args...]")
+		return 2
+	}
+
+	// Check the config before running the command, so a run isn't lost to
+	// a missing token.
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if refuseReadOnly(stderr, "run") {
+		return 1
+	}
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	now := clock.Now()
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	res := runCommand(fs.Args(), stdin, stdout, stderr, !*quiet)
+	if res.Err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), res.Err)
+	}
+	text := formatRun(shellQuote(fs.Args()), res, *tail, *note, *noOutput)
+	if cfg.Fold != nil {
+		text = foldText(text, cfg.Fold.Lines, cfg.Fold.Syntax)
+	}
+	code := runAppendRun(stderr, client, path, formatEntry(now, text, false), opts)
+	if res.Code != 0 {
+		return res.Code & 0xff
+	}
+	return code
+}
+
+// runAppendRun appends the entry for a run, reporting on stderr since
+// stdout carries the command's output.
+func runAppendRun(stderr io.Writer, client *DropboxClient, path, entry string, opts appendOptions) int {
+	written, err := appendToJournal(client, path, entry, opts)
+	if code := reportNoSpace(stderr, err); code != 0 {
+		return code
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	printOK(stderr, appendedMessage(stderr, written, 1))
+	return 0
+}
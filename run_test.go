@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	res := runCommand([]string{"sh", "-c", "echo out; echo err >&2; exit 3"}, nil, &stdout, &stderr, true)
+	// stdout and stderr are separate pipes, so their order isn't fixed.
+	if res.Code != 3 || res.Err != nil || !strings.Contains(res.Output, "out\n") || !strings.Contains(res.Output, "err\n") {
+		t.Errorf("got %+v", res)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("output should be shown as it runs: %q, %q", stdout.String(), stderr.String())
+	}
+
+	res = runCommand([]string{"/nonexistent/command"}, nil, &stdout, &stderr, false)
+	if res.Code != 127 || res.Err == nil {
+		t.Errorf("missing command: got %+v", res)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 10}
+	b.Write([]byte("one\ntwo\nthree\n"))
+	if got := b.String(); got != "three\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	got := shellQuote([]string{"kubectl", "get", "pods", "-l", "app=web", "it's", ""})
+	if want := `kubectl get pods -l 'app=web' 'it'\''s' ''`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestFormatRun(t *testing.T) {
+	res := runResult{Output: "1\n2\n3\n4\n", Code: 0, Duration: 1234 * time.Millisecond}
+	got := formatRun("make deploy", res, 2, "rolling out v2", false)
+	want := "rolling out v2\n\n✓ `make deploy` exited 0 after 1.2s\n\n```console\n$ make deploy\n[… 2 lines omitted]\n3\n4\n```\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	res = runResult{Output: "boom\n", Code: 2, Duration: 5 * time.Millisecond}
+	if got := formatRun("false", res, 0, "", true); got != "✗ `false` exited 2 after 5ms" {
+		t.Errorf("-no-output: got %q", got)
+	}
+}
+
+func TestRunAppendRun(t *testing.T) {
+	path := "/Journal/20250115.md"
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	entry := formatEntry(time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC), "✓ `true` exited 0 after 1ms", false)
+	var stderr bytes.Buffer
+	if code := runAppendRun(&stderr, client, path, entry, appendOptions{}); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if files[path] != "### 09:00:00\n✓ `true` exited 0 after 1ms\n" || !strings.Contains(stderr.String(), "Appended to "+path) {
+		t.Errorf("note %q, stderr %q", files[path], stderr.String())
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeSection is a part of the day entries are grouped under, from its
+// start time until the next section's.
+type TimeSection struct {
+	// Heading is the text of the section's "## " heading, e.g. "Morning".
+	Heading string `json:"heading"`
+	// From is when the section starts, as HH:MM.
+	From string `json:"from"`
+}
+
+// validateSections checks that sections have headings and start times in
+// ascending order.
+func validateSections(sections []TimeSection) error {
+	prev := ""
+	for i, s := range sections {
+		if strings.TrimSpace(s.Heading) == "" {
+			return fmt.Errorf("section %d has no heading", i+1)
+		}
+		if _, err := time.Parse("15:04", s.From); err != nil || len(s.From) != 5 {
+			return fmt.Errorf("invalid from %q for section %q: expected HH:MM", s.From, s.Heading)
+		}
+		if s.From <= prev {
+			return fmt.Errorf("section %q must start after %s", s.Heading, prev)
+		}
+		prev = s.From
+	}
+	return nil
+}
+
+// sectionFor returns the index of the section an entry written at hhmm
+// (HH:MM or later, e.g. HH:MM:SS) belongs to. Times before the first
+// section belong to the last one, so a late evening runs past midnight.
+func sectionFor(sections []TimeSection, hhmm string) int {
+	at := len(sections) - 1
+	for i, s := range sections {
+		if hhmm[:5] >= s.From {
+			at = i
+		}
+	}
+	return at
+}
+
+// sectionOrder returns a key that sorts entry times in the order they were
+// written within the day's sections: times before the first section come
+// after everything else.
+func sectionOrder(sections []TimeSection, t string) string {
+	if t < sections[0].From {
+		return "~" + t
+	}
+	return t
+}
+
+// noteLine is a line of a note and its offset.
+type noteLine struct {
+	off  int
+	text string
+}
+
+// noteLines splits content into lines, leaving out front matter and fenced
+// code blocks, where headings don't count.
+func noteLines(content string) []noteLine {
+	var lines []noteLine
+	off := max(frontMatterEnd(content), 0)
+	fence := ""
+	for _, line := range strings.SplitAfter(content[off:], "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		default:
+			lines = append(lines, noteLine{off, strings.TrimRight(line, " \t\r\n")})
+		}
+		off += len(line)
+	}
+	return lines
+}
+
+// insertInTimeSection adds entry to content under the "## " heading of the
+// section its time falls in, creating the heading, in section order, when
+// the note doesn't have it yet. Within a section entries are kept in time
+// order, so a backdated entry goes before later ones. The footer marker
+// stays last as with insertBeforeFooter. Entries without a time are
+// appended as usual.
+func insertInTimeSection(content, entry, sep, footer string, sections []TimeSection) string {
+	var t string
+	if m := entryHeaderPattern.FindStringSubmatch(firstLine(entry)); m != nil {
+		t = m[2]
+	}
+	if t == "" || len(sections) == 0 {
+		return insertBeforeFooter(content, entry, sep, footer)
+	}
+	target := sectionFor(sections, t)
+
+	// Where each section's heading is, and where the footer starts.
+	headings := make([]int, len(sections))
+	for i := range headings {
+		headings[i] = -1
+	}
+	footerAt := len(content)
+	for _, l := range noteLines(content) {
+		for i, s := range sections {
+			if l.text == "## "+s.Heading && headings[i] < 0 {
+				headings[i] = l.off
+			}
+		}
+		if footer != "" && l.text == footer {
+			footerAt = l.off
+		}
+	}
+
+	// The section ends at the next heading of a section after it, or the
+	// footer.
+	end := footerAt
+	for i := target + 1; i < len(sections); i++ {
+		if headings[i] >= 0 && headings[i] < end {
+			end = headings[i]
+			break
+		}
+	}
+
+	if headings[target] < 0 {
+		block := "## " + sections[target].Heading + "\n\n" + entry
+		return joinAt(content, end, block, "\n", "\n")
+	}
+
+	// Before the first entry in the section written later than this one.
+	order := sectionOrder(sections, t)
+	start := headings[target]
+	for _, s := range entryStarts(content[start:end]) {
+		m := entryHeaderPattern.FindStringSubmatch(firstLine(content[start+s:]))
+		if m != nil && sectionOrder(sections, m[2]) > order {
+			return joinAt(content, start+s, entry, sep, sep)
+		}
+	}
+	before := sep
+	if strings.TrimRight(content[start:end], "\n") == "## "+sections[target].Heading {
+		before = "\n" // right below the heading
+	}
+	return joinAt(content, end, entry, before, "\n")
+}
+
+// joinAt inserts block into content at offset at, with sepBefore between it
+// and what precedes it and sepAfter between it and what follows, which is
+// separated by one blank line at most. The result ends with one newline.
+func joinAt(content string, at int, block, sepBefore, sepAfter string) string {
+	head, tail := content[:at], strings.TrimLeft(content[at:], "\n")
+	if strings.Contains(sepBefore, "---") {
+		// The entry before already ends with the rule.
+		head = strings.TrimSuffix(strings.TrimRight(head, "\n"), "\n---")
+	}
+	out := appendWithSeparator(head, block, sepBefore)
+	if strings.TrimSpace(tail) == "" {
+		return out
+	}
+	return out + sepAfter + strings.TrimRight(tail, "\n") + "\n"
+}
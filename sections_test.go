@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+var daySections = []TimeSection{
+	{Heading: "Morning", From: "05:00"},
+	{Heading: "Afternoon", From: "12:00"},
+	{Heading: "Evening", From: "18:00"},
+}
+
+func TestInsertInTimeSection(t *testing.T) {
+	content := ""
+	for _, e := range []string{
+		"### 09:00:00\ncoffee\n",
+		"### 19:30:00\ndinner\n",
+		"### 13:00:00\nlunch\n",
+		"### 08:15:00\nbackdated run\n",
+		"### 01:10:00\nstill up\n",
+	} {
+		content = insertInTimeSection(content, e, "\n", "", daySections)
+	}
+	want := "## Morning\n\n### 08:15:00\nbackdated run\n\n### 09:00:00\ncoffee\n\n" +
+		"## Afternoon\n\n### 13:00:00\nlunch\n\n" +
+		"## Evening\n\n### 19:30:00\ndinner\n\n### 01:10:00\nstill up\n"
+	if content != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestInsertInTimeSection_FooterAndPreamble(t *testing.T) {
+	content := "# Wednesday\n\n## Tomorrow\n- call Bob\n"
+	content = insertInTimeSection(content, "### 14:00:00\nmeeting\n", "\n", "## Tomorrow", daySections)
+	content = insertInTimeSection(content, "### 10:00:00\nstandup\n", "\n", "## Tomorrow", daySections)
+	want := "# Wednesday\n\n## Morning\n\n### 10:00:00\nstandup\n\n## Afternoon\n\n### 14:00:00\nmeeting\n\n## Tomorrow\n- call Bob\n"
+	if content != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestInsertInTimeSection_RuleSeparator(t *testing.T) {
+	sep, _ := separatorText("rule")
+	content := insertInTimeSection("", "### 09:00:00\nfirst\n", sep, "", daySections)
+	content = insertInTimeSection(content, "### 10:00:00\nthird\n", sep, "", daySections)
+	content = insertInTimeSection(content, "### 09:30:00\nsecond\n", sep, "", daySections)
+	want := "## Morning\n\n### 09:00:00\nfirst\n\n---\n\n### 09:30:00\nsecond\n\n---\n\n### 10:00:00\nthird\n"
+	if content != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestInsertInTimeSection_UntimedEntries(t *testing.T) {
+	got := insertInTimeSection("## Morning\n\n### 09:00:00\ncoffee\n", "a note without a time\n", "\n", "", daySections)
+	if want := "## Morning\n\n### 09:00:00\ncoffee\n\na note without a time\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateSections(t *testing.T) {
+	if err := validateSections(daySections); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range [][]TimeSection{
+		{{Heading: "Morning", From: "5:00"}},
+		{{Heading: "", From: "05:00"}},
+		{{Heading: "Evening", From: "18:00"}, {Heading: "Morning", From: "05:00"}},
+	} {
+		if err := validateSections(bad); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
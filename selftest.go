@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// selftestTokenEnv holds the token selftest -live runs with, for an app
+// limited to its own app folder.
+const selftestTokenEnv = "DROPBOX_SELFTEST_TOKEN"
+
+// selftestCheck is one step of the live self-test. Steps run in order and
+// stop at the first failure, since each builds on the one before.
+type selftestCheck struct {
+	name string
+	run  func(*selftestState) error
+}
+
+// selftestState is what the steps share: the note they work on and the
+// revisions they have seen.
+type selftestState struct {
+	client   *DropboxClient
+	path     string
+	original string
+	firstRev string
+}
+
+// selftestChecks exercise the calls appending relies on: creating a note,
+// appending to it, detecting a conflicting write and restoring a revision.
+var selftestChecks = []selftestCheck{
+	{"account", func(s *selftestState) error {
+		_, err := s.client.CurrentAccount()
+		return err
+	}},
+	{"create", func(s *selftestState) error {
+		s.original = "### 00:00:00\nselftest note\n"
+		rev, err := s.client.UploadRev(s.path, s.original, "")
+		s.firstRev = rev
+		if err == nil && rev == "" {
+			err = errors.New("upload returned no revision")
+		}
+		return err
+	}},
+	{"append", func(s *selftestState) error {
+		if _, err := appendToJournal(s.client, s.path, "### 00:00:01\nappended\n", appendOptions{}); err != nil {
+			return err
+		}
+		return s.expect(s.original + "\n### 00:00:01\nappended\n")
+	}},
+	{"conflict", func(s *selftestState) error {
+		_, err := s.client.UploadRev(s.path, "clobbered\n", s.firstRev)
+		if !errors.Is(err, ErrConflict) {
+			return fmt.Errorf("upload at a stale revision: got %v, want a conflict", err)
+		}
+		return nil
+	}},
+	{"revisions", func(s *selftestState) error {
+		revs, err := s.client.ListRevisions(s.path, 10)
+		if err == nil && len(revs) < 2 {
+			err = fmt.Errorf("%d revisions, want at least 2", len(revs))
+		}
+		return err
+	}},
+	{"restore", func(s *selftestState) error {
+		if _, err := s.client.Restore(s.path, s.firstRev); err != nil {
+			return err
+		}
+		return s.expect(s.original)
+	}},
+}
+
+// expect checks the note's content.
+func (s *selftestState) expect(want string) error {
+	got, err := s.client.Download(s.path)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("note is %q, want %q", got, want)
+	}
+	return nil
+}
+
+// runSelftest implements the `dropbox-appender selftest -live` subcommand,
+// which runs the checks against real Dropbox in a scratch folder and
+// removes it afterwards. It isn't listed in the usage. It returns the
+// process exit code.
+func runSelftest(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	live := fs.Bool("live", false, "run the checks against Dropbox")
+	token := fs.String("token", os.Getenv(selftestTokenEnv), "access token for an app folder app (default $"+selftestTokenEnv+")")
+	useConfig := fs.Bool("use-config", false, "use the configured credentials instead of -token")
+	keep := fs.Bool("keep", false, "leave the scratch folder in place for inspection")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !*live {
+		fmt.Fprintln(stderr, "usage: dropbox-appender selftest -live [-token TOKEN | -use-config] [-keep]")
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if *useConfig {
+		if *token, err = resolveToken(cfg); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	} else if *token == "" {
+		fmt.Fprintf(stderr, "selftest -live needs an access token: pass -token, set $%s or use -use-config\n", selftestTokenEnv)
+		return 2
+	}
+	policy, err := retryPolicy(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	// No API cache: every call should reach Dropbox.
+	client := &DropboxClient{Token: *token, Retry: policy, Endpoints: cfg.Endpoints}
+	root := fmt.Sprintf("/dropbox-appender-selftest-%d", time.Now().UnixNano())
+	return runSelftestWithClient(stdout, stderr, client, root, *keep)
+}
+
+// runSelftestWithClient runs the checks in root, then deletes root unless
+// keep.
+func runSelftestWithClient(stdout, stderr io.Writer, client *DropboxClient, root string, keep bool) int {
+	s := &selftestState{client: client, path: root + "/note.md"}
+	code := 0
+	for _, c := range selftestChecks {
+		start := time.Now()
+		if err := c.run(s); err != nil {
+			fmt.Fprintf(stdout, "FAIL %-10s %v\n", c.name, err)
+			code = 1
+			break
+		}
+		fmt.Fprintf(stdout, "ok   %-10s %v\n", c.name, time.Since(start).Round(time.Millisecond))
+	}
+
+	if keep {
+		fmt.Fprintf(stderr, "Left %s in place\n", root)
+		return code
+	}
+	if err := client.Delete(root); err != nil {
+		fmt.Fprintf(stderr, "warning: removing %s: %v\n", root, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "ok   %-10s %s removed\n", "cleanup", strings.TrimPrefix(root, "/"))
+	return code
+}
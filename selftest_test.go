@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// versionedServer fakes the Dropbox calls selftest makes, keeping every
+// revision of each file.
+func versionedServer(t *testing.T, brokenConflicts bool) (*httptest.Server, map[string][]string) {
+	history := map[string][]string{} // path -> contents, oldest first
+	revOf := func(p string) string { return fmt.Sprintf("r%d", len(history[p])) }
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg struct {
+			Path string          `json:"path"`
+			Rev  string          `json:"rev"`
+			Mode json.RawMessage `json:"mode"`
+		}
+		if h := r.Header.Get("Dropbox-API-Arg"); h != "" {
+			json.Unmarshal([]byte(h), &arg)
+		} else {
+			json.NewDecoder(r.Body).Decode(&arg)
+		}
+		versions := history[arg.Path]
+		switch r.URL.Path {
+		case "/2/users/get_current_account":
+			w.Write([]byte(`{"account_id": "dbid:1"}`))
+		case "/2/files/download":
+			if len(versions) == 0 {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+				return
+			}
+			w.Header().Set("Dropbox-API-Result", fmt.Sprintf(`{"rev": %q}`, revOf(arg.Path)))
+			w.Write([]byte(versions[len(versions)-1]))
+		case "/2/files/upload":
+			var mode struct {
+				Update string `json:"update"`
+			}
+			json.Unmarshal(arg.Mode, &mode)
+			if mode.Update != "" && mode.Update != revOf(arg.Path) && !brokenConflicts {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/conflict/file/"}`))
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			history[arg.Path] = append(versions, string(body))
+			fmt.Fprintf(w, `{"rev": %q}`, revOf(arg.Path))
+		case "/2/files/get_metadata":
+			if len(versions) == 0 {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+				return
+			}
+			fmt.Fprintf(w, `{"rev": %q, "content_hash": %q}`, revOf(arg.Path), contentHash([]byte(versions[len(versions)-1])))
+		case "/2/files/list_revisions":
+			var entries []string
+			for i := range versions {
+				entries = append(entries, fmt.Sprintf(`{"rev": "r%d"}`, i+1))
+			}
+			fmt.Fprintf(w, `{"entries": [%s]}`, strings.Join(entries, ","))
+		case "/2/files/restore":
+			var i int
+			fmt.Sscanf(arg.Rev, "r%d", &i)
+			history[arg.Path] = append(versions, versions[i-1])
+			fmt.Fprintf(w, `{"rev": %q}`, revOf(arg.Path))
+		case "/2/files/delete_v2":
+			for p := range history {
+				if strings.HasPrefix(p, arg.Path+"/") {
+					delete(history, p)
+				}
+			}
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected call %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, history
+}
+
+func TestRunSelftestWithClient(t *testing.T) {
+	srv, history := versionedServer(t, false)
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	var stdout, stderr bytes.Buffer
+	if code := runSelftestWithClient(&stdout, &stderr, client, "/selftest", false); code != 0 {
+		t.Fatalf("exit %d:\n%s%s", code, stdout.String(), stderr.String())
+	}
+	for _, c := range append(selftestChecks, selftestCheck{name: "cleanup"}) {
+		if !strings.Contains(stdout.String(), "ok   "+c.name) {
+			t.Errorf("no ok line for %s:\n%s", c.name, stdout.String())
+		}
+	}
+	if len(history) != 0 {
+		t.Errorf("scratch files left: %v", history)
+	}
+}
+
+func TestRunSelftestWithClient_Failure(t *testing.T) {
+	srv, history := versionedServer(t, true)
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	var stdout, stderr bytes.Buffer
+	if code := runSelftestWithClient(&stdout, &stderr, client, "/selftest", true); code != 1 {
+		t.Fatalf("exit %d:\n%s", code, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "FAIL conflict") || strings.Contains(stdout.String(), "revisions") {
+		t.Errorf("output:\n%s", stdout.String())
+	}
+	if len(history) == 0 {
+		t.Error("-keep removed the scratch folder")
+	}
+}
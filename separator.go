@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// separatorText returns what goes between the end of existing content and a
+// new entry for a separator policy:
+//
+//	"" or "blank"  one blank line (the default)
+//	"rule"         a --- horizontal rule surrounded by blank lines
+//	"none"         nothing; the entry starts on the next line
+//	"N"            N blank lines
+func separatorText(policy string) (string, error) {
+	switch policy {
+	case "", "blank":
+		return "\n", nil
+	case "rule":
+		// The blank line before --- stops it turning the previous line into
+		// a setext heading.
+		return "\n---\n\n", nil
+	case "none":
+		return "", nil
+	}
+	n, err := strconv.Atoi(policy)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid separator %q: expected blank, rule, none or a number of blank lines", policy)
+	}
+	return strings.Repeat("\n", n), nil
+}
+
+// appendWithSeparator joins existing content and entry with sep. Trailing
+// newlines are normalised so the result always ends with exactly one.
+func appendWithSeparator(existing, entry, sep string) string {
+	entry = strings.TrimRight(entry, "\n") + "\n"
+	existing = strings.TrimRight(existing, "\n")
+	if existing == "" {
+		return entry
+	}
+	return existing + "\n" + sep + entry
+}
+
+// insertBeforeFooter adds entry to content like appendWithSeparator, but
+// ahead of a trailing footer: the last line reading exactly marker (e.g.
+// "## Tomorrow") and everything after it stay at the end of the note, one
+// blank line below the new entry. Without marker, or when the note has no
+// such line, the entry goes at the end.
+func insertBeforeFooter(content, entry, sep, marker string) string {
+	if marker == "" {
+		return appendWithSeparator(content, entry, sep)
+	}
+	at := -1
+	for i := 0; i < len(content); {
+		end := strings.IndexByte(content[i:], '\n')
+		if end < 0 {
+			end = len(content) - i
+		}
+		if strings.TrimRight(content[i:i+end], " \t\r") == marker {
+			at = i
+		}
+		i += end + 1
+	}
+	if at < 0 {
+		return appendWithSeparator(content, entry, sep)
+	}
+	footer := strings.TrimRight(content[at:], "\n") + "\n"
+	return appendWithSeparator(content[:at], entry, sep) + "\n" + footer
+}
@@ -0,0 +1,93 @@
+package main
+
+import "testing"
+
+func TestSeparatorText(t *testing.T) {
+	cases := map[string]string{"": "\n", "blank": "\n", "rule": "\n---\n\n", "none": "", "2": "\n\n", "0": ""}
+	for policy, want := range cases {
+		got, err := separatorText(policy)
+		if err != nil || got != want {
+			t.Errorf("separatorText(%q) = %q, %v; want %q", policy, got, err, want)
+		}
+	}
+	for _, policy := range []string{"dashes", "-1"} {
+		if _, err := separatorText(policy); err == nil {
+			t.Errorf("separatorText(%q): expected error", policy)
+		}
+	}
+}
+
+func TestAppendWithSeparator(t *testing.T) {
+	cases := []struct {
+		existing, entry, sep, want string
+	}{
+		{"a\n", "b\n", "\n", "a\n\nb\n"},
+		{"a", "b", "\n", "a\n\nb\n"},
+		{"a\n\n\n", "b\n\n", "\n", "a\n\nb\n"},
+		{"a\n", "b\n", "", "a\nb\n"},
+		{"a\n", "b\n", "\n---\n\n", "a\n\n---\n\nb\n"},
+		{"", "b", "\n---\n\n", "b\n"},
+		{"\n\n", "b\n", "\n", "b\n"},
+	}
+	for _, c := range cases {
+		if got := appendWithSeparator(c.existing, c.entry, c.sep); got != c.want {
+			t.Errorf("appendWithSeparator(%q, %q, %q) = %q, want %q", c.existing, c.entry, c.sep, got, c.want)
+		}
+	}
+}
+
+func TestAppendToJournal_Separator(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 09:00:00\nmorning\n\n\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", appendOptions{Separator: "rule"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "### 09:00:00\nmorning\n\n---\n\n### 14:30:45\nafternoon\n"; files[path] != want {
+		t.Errorf("got %q, want %q", files[path], want)
+	}
+}
+
+func TestInsertBeforeFooter(t *testing.T) {
+	const marker = "## Tomorrow"
+	cases := []struct {
+		content, want string
+	}{
+		// No footer: appended at the end.
+		{"a\n", "a\n\nb\n"},
+		// Footer kept last, with everything below it.
+		{"a\n\n## Tomorrow\n- plan\n", "a\n\nb\n\n## Tomorrow\n- plan\n"},
+		// A note that is only the footer.
+		{"## Tomorrow\n", "b\n\n## Tomorrow\n"},
+		// The last occurrence counts; partial matches don't.
+		{"## Tomorrow\nx\n## Tomorrow's plan\n## Tomorrow  \n", "## Tomorrow\nx\n## Tomorrow's plan\n\nb\n\n## Tomorrow  \n"},
+	}
+	for _, c := range cases {
+		if got := insertBeforeFooter(c.content, "b\n", "\n", marker); got != c.want {
+			t.Errorf("insertBeforeFooter(%q) = %q, want %q", c.content, got, c.want)
+		}
+	}
+	if got := insertBeforeFooter("## Tomorrow\n", "b\n", "\n", ""); got != "## Tomorrow\n\nb\n" {
+		t.Errorf("without a marker got %q", got)
+	}
+}
+
+func TestAppendToJournal_Footer(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 09:00:00\nmorning\n\n## Tomorrow\n- [ ] gym\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	entries := []string{"### 14:30:45\nafternoon\n", "### 18:00:00\nevening\n"}
+	if _, err := appendEntries(client, path, entries, appendOptions{Footer: "## Tomorrow"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "### 09:00:00\nmorning\n\n### 14:30:45\nafternoon\n\n### 18:00:00\nevening\n\n## Tomorrow\n- [ ] gym\n"
+	if files[path] != want {
+		t.Errorf("got %q, want %q", files[path], want)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMQTTEntry(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		wantText   string
+		wantAuthor string
+		wantErr    bool
+	}{
+		{"text field", `{"text": "Workout finished", "author": "watch"}`, "Workout finished", "watch", false},
+		{"message and extra fields", `{"message": "Door opened", "entity": "binary_sensor.front", "state": true}`,
+			"Door opened\n- entity: binary_sensor.front\n- state: true", "", false},
+		{"fields only", `{"temperature": 21.5}`, "home/event\n- temperature: 21.5", "", false},
+		{"plain text", "remember the milk", "remember the milk", "", false},
+		{"bare value", "on", "home/event: on", "", false},
+		{"empty", "  ", "", "", true},
+		{"bad json", `{"text": `, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, author, err := mqttEntry(mqttMessage{Topic: "home/event", Payload: []byte(tt.payload)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if text != tt.wantText || author != tt.wantAuthor {
+				t.Errorf("got %q by %q, want %q by %q", text, author, tt.wantText, tt.wantAuthor)
+			}
+		})
+	}
+}
+
+func TestServerAppendText(t *testing.T) {
+	files := map[string]string{}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.Local)
+	s := &server{
+		client:   &DropboxClient{Token: "tok", BaseURL: srv.URL},
+		cfg:      &Config{PathTemplate: "/Journal/{{.Date}}.md"},
+		opts:     appendOptions{Author: "configured"},
+		clock:    fixedClock(now),
+		queueDir: t.TempDir(),
+		stderr:   &bytes.Buffer{},
+	}
+
+	written, err := s.appendText("Door opened", "home-assistant")
+	if err != nil {
+		t.Fatalf("appendText: %v", err)
+	}
+	if want := "### 14:30:45 — home-assistant\nDoor opened\n"; files[written] != want {
+		t.Errorf("note = %q, want %q", files[written], want)
+	}
+}
+
+func TestServerAppendText_QueuesWhenOffline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	var stderr bytes.Buffer
+	s := &server{
+		client:   &DropboxClient{Token: "tok", BaseURL: srv.URL},
+		cfg:      &Config{PathTemplate: "/Journal/{{.Date}}.md"},
+		clock:    fixedClock(time.Date(2025, 1, 15, 9, 0, 0, 0, time.Local)),
+		queueDir: t.TempDir(),
+		stderr:   &stderr,
+	}
+
+	written, err := s.appendText("Door opened", "")
+	if err != nil || written != "" {
+		t.Fatalf("appendText = %q, %v; want it queued", written, err)
+	}
+	queue, _ := loadQueue(s.queueDir, &stderr)
+	if len(queue) != 1 || !strings.Contains(queue[0].Entry, "Door opened") {
+		t.Errorf("queue = %+v, want the entry", queue)
+	}
+}
+
+func TestServerAppendText_ReportsRejections(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error_summary": "invalid_access_token/"}`))
+	}))
+	defer srv.Close()
+	var stderr bytes.Buffer
+	s := &server{
+		client:   &DropboxClient{Token: "tok", BaseURL: srv.URL},
+		cfg:      &Config{PathTemplate: "/Journal/{{.Date}}.md"},
+		clock:    fixedClock(time.Date(2025, 1, 15, 9, 0, 0, 0, time.Local)),
+		queueDir: t.TempDir(),
+		stderr:   &stderr,
+	}
+
+	if _, err := s.appendText("Door opened", ""); !errors.Is(err, ErrAuth) {
+		t.Fatalf("appendText error = %v, want ErrAuth", err)
+	}
+	if queue, _ := loadQueue(s.queueDir, &stderr); len(queue) != 0 {
+		t.Errorf("queue = %+v, want a rejected entry not to be queued", queue)
+	}
+}
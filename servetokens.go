@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// serveMaxSkew is how far the timestamp of a signed /append request may be
+// from the server's clock; signatures are remembered for as long to reject
+// replays.
+const serveMaxSkew = 5 * time.Minute
+
+// serveToken is a client allowed to use `serve`'s /append endpoint. Its
+// secret is either sent as a bearer API key or used to sign requests.
+type serveToken struct {
+	Name    string    `json:"name"`
+	Secret  string    `json:"secret"`
+	Created time.Time `json:"created"`
+}
+
+// defaultServeTokensPath returns the file `serve tokens` manages.
+func defaultServeTokensPath() string {
+	return filepath.Join(defaultDataDir(), "serve-tokens.json")
+}
+
+// loadServeTokens reads the clients in p; a missing file has none.
+func loadServeTokens(p string) ([]serveToken, error) {
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []serveToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", p, err)
+	}
+	return tokens, nil
+}
+
+// saveServeTokens writes tokens to p, readable only by the user since it
+// holds the secrets.
+func saveServeTokens(p string, tokens []serveToken) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(p, append(data, '\n'), 0600)
+}
+
+// signServeRequest returns the X-Appender-Signature of a request: an
+// HMAC-SHA256 with the client's secret over the timestamp, method, path and
+// body.
+func signServeRequest(secret, ts, method, path string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v1:%s:%s:%s:", ts, method, path)
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// replayGuard remembers the signatures accepted within serveMaxSkew, so a
+// captured request can't be sent again. The zero value is ready to use.
+type replayGuard struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// first records sig at now and reports whether it wasn't seen before.
+func (g *replayGuard) first(sig string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seen == nil {
+		g.seen = map[string]time.Time{}
+	}
+	for s, at := range g.seen {
+		if now.Sub(at) > 2*serveMaxSkew {
+			delete(g.seen, s)
+		}
+	}
+	if _, ok := g.seen[sig]; ok {
+		return false
+	}
+	g.seen[sig] = now
+	return true
+}
+
+// authenticateServe returns the name of the client a request comes from:
+// one sending its API key as "Authorization: Bearer <key>", or one signing
+// the request with X-Appender-Client, X-Appender-Timestamp and
+// X-Appender-Signature.
+func authenticateServe(tokens []serveToken, r *http.Request, body []byte, now time.Time, replays *replayGuard) (string, error) {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(t.Secret)) == 1 {
+				return t.Name, nil
+			}
+		}
+		return "", errors.New("unknown API key")
+	}
+
+	name := r.Header.Get("X-Appender-Client")
+	if name == "" {
+		return "", errors.New("no API key or signature")
+	}
+	var client *serveToken
+	for i := range tokens {
+		if tokens[i].Name == name {
+			client = &tokens[i]
+		}
+	}
+	if client == nil {
+		return "", fmt.Errorf("unknown client %q", name)
+	}
+	ts := r.Header.Get("X-Appender-Timestamp")
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", errors.New("missing or invalid X-Appender-Timestamp")
+	}
+	if d := now.Sub(time.Unix(secs, 0)); d > serveMaxSkew || d < -serveMaxSkew {
+		return "", fmt.Errorf("request timestamp is %v away from now", d.Round(time.Second))
+	}
+	sig := r.Header.Get("X-Appender-Signature")
+	if !hmac.Equal([]byte(sig), []byte(signServeRequest(client.Secret, ts, r.Method, r.URL.Path, body))) {
+		return "", errors.New("signature mismatch")
+	}
+	if !replays.first(sig, now) {
+		return "", errors.New("replayed request")
+	}
+	return name, nil
+}
+
+// handleAppend serves /append for the clients added with `serve tokens`.
+// The body is the entry text, or a JSON object with "text" and "author";
+// entries are attributed to the client unless an author is given. The
+// clients are read on each request, so a revoked one is refused at once.
+func (s *server) handleAppend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	tokens, err := loadServeTokens(s.tokensPath)
+	if err != nil {
+		fmt.Fprintf(s.stderr, "error: %v\n", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	client, err := authenticateServe(tokens, r, body, s.clock.Now(), &s.replays)
+	if err != nil {
+		fmt.Fprintf(s.stderr, "warning: rejected /append request from %s: %v\n", r.RemoteAddr, err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	text, author := strings.TrimSpace(string(body)), client
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			Text   string `json:"text"`
+			Author string `json:"author"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		text = strings.TrimSpace(req.Text)
+		if req.Author != "" {
+			author = req.Author
+		}
+	}
+	if text == "" {
+		http.Error(w, "empty entry", http.StatusBadRequest)
+		return
+	}
+	written, err := s.appendText(text, author)
+	if err != nil {
+		fmt.Fprintf(s.stderr, "error: appending /append entry from %s: %v\n", client, err)
+		http.Error(w, "the entry could not be saved", http.StatusBadGateway)
+		return
+	}
+	if written != "" {
+		fmt.Fprintf(s.stderr, "Appended /append entry from %s to %s\n", client, written)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"path": written, "queued": written == ""})
+}
+
+// runServeTokens implements `dropbox-appender serve tokens`, which manages
+// the clients allowed to use /append: add prints a new client's secret,
+// list shows the clients and revoke removes one.
+func runServeTokens(args []string, stdout, stderr io.Writer, clock Clock) int {
+	usage := func() int {
+		fmt.Fprintln(stderr, "usage: dropbox-appender serve tokens add <name> | list | revoke <name>")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+	p := defaultServeTokensPath()
+	tokens, err := loadServeTokens(p)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	find := func(name string) int {
+		for i, t := range tokens {
+			if t.Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 2 || strings.TrimSpace(args[1]) == "" {
+			return usage()
+		}
+		name := args[1]
+		if find(name) >= 0 {
+			fmt.Fprintf(stderr, "error: client %q already exists; revoke it first\n", name)
+			return 1
+		}
+		var b [32]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		secret := hex.EncodeToString(b[:])
+		tokens = append(tokens, serveToken{Name: name, Secret: secret, Created: clock.Now().UTC()})
+		if err := saveServeTokens(p, tokens); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		fmt.Fprintln(stdout, secret)
+		fmt.Fprintf(stderr, "Added client %s; the secret isn't shown again.\n", name)
+	case "list":
+		if len(args) != 1 {
+			return usage()
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		for _, t := range tokens {
+			fmt.Fprintf(tw, "%s\t%s…\t%s\n", t.Name, t.Secret[:min(8, len(t.Secret))], t.Created.Local().Format("2006-01-02 15:04"))
+		}
+		tw.Flush()
+	case "revoke":
+		if len(args) != 2 {
+			return usage()
+		}
+		i := find(args[1])
+		if i < 0 {
+			fmt.Fprintf(stderr, "error: no client %q\n", args[1])
+			return 1
+		}
+		tokens = append(tokens[:i], tokens[i+1:]...)
+		if err := saveServeTokens(p, tokens); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		fmt.Fprintf(stderr, "Revoked client %s\n", args[1])
+	default:
+		return usage()
+	}
+	return 0
+}
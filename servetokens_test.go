@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleAppend(t *testing.T) {
+	files := map[string]string{}
+	dropbox := rolloverServer(files)
+	defer dropbox.Close()
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.Local)
+	tokensPath := filepath.Join(t.TempDir(), "serve-tokens.json")
+	if err := saveServeTokens(tokensPath, []serveToken{{Name: "phone", Secret: "s3cret"}}); err != nil {
+		t.Fatal(err)
+	}
+	s := &server{
+		client:     &DropboxClient{Token: "tok", BaseURL: dropbox.URL},
+		cfg:        &Config{PathTemplate: "/Journal/{{.Date}}.md"},
+		clock:      fixedClock(now),
+		queueDir:   t.TempDir(),
+		stderr:     &bytes.Buffer{},
+		tokensPath: tokensPath,
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	post := func(body string, sign func(*http.Request)) int {
+		req, _ := http.NewRequest("POST", srv.URL+"/append", strings.NewReader(body))
+		sign(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	bearer := func(key string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+key) }
+	}
+	signed := func(secret, body string, at time.Time) func(*http.Request) {
+		return func(r *http.Request) {
+			ts := strconv.FormatInt(at.Unix(), 10)
+			r.Header.Set("X-Appender-Client", "phone")
+			r.Header.Set("X-Appender-Timestamp", ts)
+			r.Header.Set("X-Appender-Signature", signServeRequest(secret, ts, "POST", "/append", []byte(body)))
+		}
+	}
+
+	if code := post("from the API key", bearer("s3cret")); code != 200 {
+		t.Errorf("API key: status %d", code)
+	}
+	if code := post("signed", signed("s3cret", "signed", now)); code != 200 {
+		t.Errorf("signed request: status %d", code)
+	}
+	want := "### 14:30:45 — phone\nfrom the API key\n\n### 14:30:45 — phone\nsigned\n"
+	if files["/Journal/20250115.md"] != want {
+		t.Errorf("note = %q, want %q", files["/Journal/20250115.md"], want)
+	}
+
+	for name, code := range map[string]int{
+		"wrong key":   post("x", bearer("guess")),
+		"no auth":     post("x", func(*http.Request) {}),
+		"bad secret":  post("x", signed("guess", "x", now)),
+		"tampered":    post("tampered", signed("s3cret", "x", now)),
+		"stale":       post("x", signed("s3cret", "x", now.Add(-10*time.Minute))),
+		"replayed":    post("signed", signed("s3cret", "signed", now)),
+		"empty entry": post("", bearer("s3cret")),
+	} {
+		want := http.StatusUnauthorized
+		if name == "empty entry" {
+			want = http.StatusBadRequest
+		}
+		if code != want {
+			t.Errorf("%s: status %d, want %d", name, code, want)
+		}
+	}
+
+	// Revoking a client takes effect without a restart.
+	if err := saveServeTokens(tokensPath, nil); err != nil {
+		t.Fatal(err)
+	}
+	if code := post("revoked", bearer("s3cret")); code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status %d", code)
+	}
+}
+
+func TestServerHandler_AppendDisabledWithoutTokens(t *testing.T) {
+	s := &server{cfg: &Config{}}
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest("POST", "/append", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestRunServeTokens(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	clock := fixedClock(time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC))
+
+	var stdout, stderr bytes.Buffer
+	if code := runServeTokens([]string{"add", "phone"}, &stdout, &stderr, clock); code != 0 {
+		t.Fatalf("add: exit %d: %s", code, stderr.String())
+	}
+	secret := strings.TrimSpace(stdout.String())
+	if len(secret) != 64 {
+		t.Errorf("expected a 64 character secret, got %q", secret)
+	}
+	if code := runServeTokens([]string{"add", "phone"}, &stdout, &stderr, clock); code != 1 {
+		t.Errorf("adding a client twice: exit %d", code)
+	}
+
+	stdout.Reset()
+	runServeTokens([]string{"list"}, &stdout, &stderr, clock)
+	if !strings.HasPrefix(stdout.String(), "phone  "+secret[:8]+"…") || strings.Contains(stdout.String(), secret) {
+		t.Errorf("list = %q", stdout.String())
+	}
+
+	if code := runServeTokens([]string{"revoke", "phone"}, &stdout, &stderr, clock); code != 0 {
+		t.Errorf("revoke: exit %d", code)
+	}
+	tokens, _ := loadServeTokens(defaultServeTokensPath())
+	if len(tokens) != 0 {
+		t.Errorf("expected no clients after revoking, got %v", tokens)
+	}
+	if code := runServeTokens([]string{"revoke", "phone"}, &stdout, &stderr, clock); code != 1 {
+		t.Errorf("revoking an unknown client: exit %d", code)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultVaultRoots are the Dropbox folders scanned for existing daily notes:
+// the Obsidian app folder, a plain notes folder, and a top-level vault.
+var defaultVaultRoots = []string{"/Apps/Obsidian", "/Notes", "/Obsidian"}
+
+// maxScanEntries caps how many entries are listed per root, so a huge vault
+// doesn't turn setup into thousands of API calls.
+const maxScanEntries = 20000
+
+// dateNamePatterns are the date formats recognised in daily-note filenames,
+// most specific first, with the path template each one maps to.
+var dateNamePatterns = []struct {
+	re     *regexp.Regexp
+	layout string
+	tmpl   string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}`), "2006-01-02", "{{.Year}}-{{.Month}}-{{.Day}}"},
+	{regexp.MustCompile(`\d{4}_\d{2}_\d{2}`), "2006_01_02", "{{.Year}}_{{.Month}}_{{.Day}}"},
+	{regexp.MustCompile(`\d{8}`), "20060102", "{{.Date}}"},
+}
+
+// layoutCandidate is a path template inferred from existing notes.
+type layoutCandidate struct {
+	Template string
+	Count    int
+	Example  string
+}
+
+// inferPathTemplate turns the path of an existing daily note into a path
+// template, e.g. /Notes/Journal/2025/01/Note20250115.md becomes
+// /Notes/Journal/{{.Year}}/{{.Month}}/Note{{.Date}}.md. Directory segments
+// matching the note's year and month are templated too.
+func inferPathTemplate(p string) (string, bool) {
+	if !strings.HasSuffix(strings.ToLower(p), ".md") {
+		return "", false
+	}
+	dir, name := path.Split(p)
+
+	for _, pat := range dateNamePatterns {
+		loc := pat.re.FindStringIndex(name)
+		if loc == nil {
+			continue
+		}
+		date, err := time.Parse(pat.layout, name[loc[0]:loc[1]])
+		if err != nil {
+			continue
+		}
+		name = name[:loc[0]] + pat.tmpl + name[loc[1]:]
+
+		segs := strings.Split(strings.TrimSuffix(dir, "/"), "/")
+		for i, seg := range segs {
+			switch {
+			case seg == date.Format("2006"):
+				segs[i] = "{{.Year}}"
+			case seg == date.Format("01") && i > 0 && segs[i-1] == "{{.Year}}":
+				segs[i] = "{{.Month}}"
+			case seg == date.Format("2006-01"):
+				segs[i] = "{{.Year}}-{{.Month}}"
+			}
+		}
+		return strings.Join(segs, "/") + "/" + name, true
+	}
+	return "", false
+}
+
+// scanLayouts lists each root recursively and groups the daily notes found by
+// inferred path template, most common first.
+func scanLayouts(client *DropboxClient, roots []string) ([]layoutCandidate, error) {
+	byTemplate := map[string]*layoutCandidate{}
+	for _, root := range roots {
+		entries, err := client.ListFolder(root, true, maxScanEntries)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", root, err)
+		}
+		for _, e := range entries {
+			if e.Tag != "file" {
+				continue
+			}
+			tmpl, ok := inferPathTemplate(e.PathDisplay)
+			if !ok {
+				continue
+			}
+			c := byTemplate[tmpl]
+			if c == nil {
+				c = &layoutCandidate{Template: tmpl, Example: e.PathDisplay}
+				byTemplate[tmpl] = c
+			}
+			c.Count++
+		}
+	}
+
+	var candidates []layoutCandidate
+	for _, c := range byTemplate {
+		candidates = append(candidates, *c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Count != candidates[j].Count {
+			return candidates[i].Count > candidates[j].Count
+		}
+		return candidates[i].Template < candidates[j].Template
+	})
+	return candidates, nil
+}
+
+// runSetup implements the `dropbox-appender setup` subcommand: it scans
+// Dropbox for an existing daily-notes layout and saves the chosen path
+// template to the config. It returns the process exit code.
+func runSetup(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("setup", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	root := fs.String("root", "", "scan this Dropbox folder instead of the common vault locations")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	configPath := defaultConfigPath()
+	cfg, err := loadConfig(configPath, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	client, err := connectClient(cfg, false)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	roots := defaultVaultRoots
+	if *root != "" {
+		roots = []string{*root}
+	}
+	return runSetupWithClient(bufio.NewScanner(stdin), stdout, stderr, client, cfg, configPath, roots)
+}
+
+// runSetupWithClient is the testable core of the setup wizard. It reads the
+// user's choice from in and saves the selected template to configPath.
+func runSetupWithClient(in *bufio.Scanner, stdout, stderr io.Writer,
+	client *DropboxClient, cfg *Config, configPath string, roots []string) int {
+
+	fmt.Fprintf(stdout, "Scanning %s for daily notes...\n", strings.Join(roots, ", "))
+	candidates, err := scanLayouts(client, roots)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if len(candidates) == 0 {
+		fmt.Fprintf(stdout, "No daily notes found; keeping the default layout %s\n", defaultPathTemplate)
+		return 0
+	}
+
+	fmt.Fprintln(stdout, "Found these daily-note layouts:")
+	for i, c := range candidates {
+		fmt.Fprintf(stdout, "  %d) %s  (%d notes, e.g. %s)\n", i+1, c.Template, c.Count, c.Example)
+	}
+	fmt.Fprintf(stdout, "Pick a layout [1-%d], or press Enter to keep the default: ", len(candidates))
+
+	in.Scan()
+	answer := strings.TrimSpace(in.Text())
+	if answer == "" {
+		fmt.Fprintln(stdout, "Keeping the default layout.")
+		return 0
+	}
+	n, err := strconv.Atoi(answer)
+	if err != nil || n < 1 || n > len(candidates) {
+		fmt.Fprintf(stderr, "invalid choice %q\n", answer)
+		return 1
+	}
+
+	cfg.PathTemplate = candidates[n-1].Template
+	// Saved to the profile in use, if any, as its template would win.
+	err = updateConfigFile(configPath, func(onDisk *Config) error {
+		if cfg.profile != "" {
+			return onDisk.setProfileKey(cfg.profile, "path_template", cfg.PathTemplate)
+		}
+		onDisk.PathTemplate = cfg.PathTemplate
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "error saving config: %v\n", err)
+		return 1
+	}
+	if cfg.profile != "" {
+		fmt.Fprintf(stdout, "Saved path_template %s to profile %s\n", cfg.PathTemplate, cfg.profile)
+		return 0
+	}
+	fmt.Fprintf(stdout, "Saved path_template %s\n", cfg.PathTemplate)
+	return 0
+}
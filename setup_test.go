@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInferPathTemplate(t *testing.T) {
+	cases := map[string]string{
+		"/Notes/Journal/2025/01/Note20250115.md":     "/Notes/Journal/{{.Year}}/{{.Month}}/Note{{.Date}}.md",
+		"/Apps/Obsidian/Vault/Daily/2025-01-15.md":   "/Apps/Obsidian/Vault/Daily/{{.Year}}-{{.Month}}-{{.Day}}.md",
+		"/Obsidian/Journal/2025-01/2025_01_15.md":    "/Obsidian/Journal/{{.Year}}-{{.Month}}/{{.Year}}_{{.Month}}_{{.Day}}.md",
+		"/Notes/Daily/2024/Daily 2024-03-09 note.md": "/Notes/Daily/{{.Year}}/Daily {{.Year}}-{{.Month}}-{{.Day}} note.md",
+	}
+	for in, want := range cases {
+		got, ok := inferPathTemplate(in)
+		if !ok || got != want {
+			t.Errorf("inferPathTemplate(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+}
+
+func TestInferPathTemplate_NotADailyNote(t *testing.T) {
+	for _, p := range []string{"/Notes/Ideas.md", "/Notes/2025-01-15.png", "/Notes/2025-13-45.md"} {
+		if got, ok := inferPathTemplate(p); ok {
+			t.Errorf("inferPathTemplate(%q) = %q, expected no match", p, got)
+		}
+	}
+}
+
+// listFolderServer serves a single list_folder page per root, paging the
+// /Notes listing across a list_folder/continue call.
+func listFolderServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&arg)
+		switch r.URL.Path {
+		case "/2/files/list_folder":
+			if arg["path"] != "/Notes" {
+				w.WriteHeader(409)
+				w.Write([]byte(`{"error_summary": "path/not_found/.."}`))
+				return
+			}
+			w.Write([]byte(`{"entries": [
+				{".tag": "folder", "path_display": "/Notes/Journal"},
+				{".tag": "file", "path_display": "/Notes/Journal/2025/01/Note20250115.md"},
+				{".tag": "file", "path_display": "/Notes/Journal/2025/01/Note20250116.md"},
+				{".tag": "file", "path_display": "/Notes/Ideas.md"}
+			], "cursor": "c1", "has_more": true}`))
+		case "/2/files/list_folder/continue":
+			w.Write([]byte(`{"entries": [
+				{".tag": "file", "path_display": "/Notes/Journal/2024/12/Note20241231.md"},
+				{".tag": "file", "path_display": "/Notes/Daily/2024-12-30.md"}
+			], "cursor": "c2", "has_more": false}`))
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+	}))
+}
+
+func TestScanLayouts(t *testing.T) {
+	server := listFolderServer(t)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	candidates, err := scanLayouts(client, defaultVaultRoots)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 layouts, got %+v", candidates)
+	}
+	if candidates[0].Template != defaultPathTemplate || candidates[0].Count != 3 {
+		t.Errorf("expected the journal layout first with 3 notes, got %+v", candidates[0])
+	}
+	if candidates[1].Template != "/Notes/Daily/{{.Year}}-{{.Month}}-{{.Day}}.md" {
+		t.Errorf("unexpected second layout: %+v", candidates[1])
+	}
+}
+
+func TestRunSetupWithClient_SavesChoice(t *testing.T) {
+	server := listFolderServer(t)
+	defer server.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(configPath, []byte(`{"app_key": "k"}`), 0600)
+	// The secret came from the environment, so it isn't saved.
+	cfg := &Config{AppKey: "k", AppSecret: "from-env"}
+	var stdout, stderr bytes.Buffer
+	code := runSetupWithClient(bufio.NewScanner(strings.NewReader("2\n")), &stdout, &stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, cfg, configPath, []string{"/Notes"})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if onDisk, _ := readConfigFile(configPath); onDisk.AppSecret != "" {
+		t.Errorf("expected the environment's secret not to be saved, got %q", onDisk.AppSecret)
+	}
+
+	saved, err := loadConfig(configPath, io.Discard)
+	if err != nil {
+		t.Fatalf("loading saved config: %v", err)
+	}
+	if saved.PathTemplate != "/Notes/Daily/{{.Year}}-{{.Month}}-{{.Day}}.md" || saved.AppKey != "k" {
+		t.Errorf("unexpected saved config: %+v", saved)
+	}
+}
+
+func TestRunSetupWithClient_SavesToProfileInUse(t *testing.T) {
+	server := listFolderServer(t)
+	defer server.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(configPath, []byte(`{"app_key": "k", "profiles": {"work": {"path_template": "/Work/{{.Date}}.md", "author": "me"}}}`), 0600)
+	cfg := &Config{AppKey: "k", PathTemplate: "/Work/{{.Date}}.md", profile: "work"}
+	code := runSetupWithClient(bufio.NewScanner(strings.NewReader("2\n")), io.Discard, io.Discard,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, cfg, configPath, []string{"/Notes"})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	onDisk, err := readConfigFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onDisk.PathTemplate != "" {
+		t.Errorf("expected the top level to keep no template, got %q", onDisk.PathTemplate)
+	}
+	var profile map[string]string
+	json.Unmarshal(onDisk.Profiles["work"], &profile)
+	if profile["path_template"] != "/Notes/Daily/{{.Year}}-{{.Month}}-{{.Day}}.md" || profile["author"] != "me" {
+		t.Errorf("profile = %v, want the new template and its other keys", profile)
+	}
+}
+
+func TestRunSetupWithClient_KeepDefault(t *testing.T) {
+	server := listFolderServer(t)
+	defer server.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	code := runSetupWithClient(bufio.NewScanner(strings.NewReader("\n")), io.Discard, io.Discard,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{}, configPath, []string{"/Notes"})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if cfg, _ := loadConfig(configPath, io.Discard); cfg.PathTemplate != "" {
+		t.Errorf("expected no template saved, got %q", cfg.PathTemplate)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "regexp"
+
+// shortcodePattern matches a :name: shortcode.
+var shortcodePattern = regexp.MustCompile(`:([A-Za-z0-9_+-]+):`)
+
+// expandShortcodes replaces each :name: in text with codes[name]. Unknown
+// shortcodes are left untouched, so ordinary colons and emoji codes that
+// the note app renders itself pass through.
+func expandShortcodes(text string, codes map[string]string) string {
+	if len(codes) == 0 {
+		return text
+	}
+	return shortcodePattern.ReplaceAllStringFunc(text, func(m string) string {
+		if v, ok := codes[m[1:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestExpandShortcodes(t *testing.T) {
+	codes := map[string]string{
+		"mtg":  "📅 Meeting",
+		"todo": "- [ ]",
+	}
+	cases := map[string]string{
+		":mtg: with design team":  "📅 Meeting with design team",
+		":todo: call back :mtg:":  "- [ ] call back 📅 Meeting",
+		"unknown :smile: stays":   "unknown :smile: stays",
+		"at 14:30:45 nothing":     "at 14:30:45 nothing",
+		"adjacent :todo::mtg: ok": "adjacent - [ ]📅 Meeting ok",
+	}
+	for in, want := range cases {
+		if got := expandShortcodes(in, codes); got != want {
+			t.Errorf("expandShortcodes(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExpandShortcodes_NoCodes(t *testing.T) {
+	if got := expandShortcodes(":mtg: x", nil); got != ":mtg: x" {
+		t.Errorf("got %q", got)
+	}
+}
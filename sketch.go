@@ -8,7 +8,8 @@ import (
 	"time"
 )
 
-// defaultSketchFolder is the Dropbox folder where .excalidraw files are stored.
+// defaultSketchFolder is the Dropbox folder where .excalidraw files are stored
+// with the default path template.
 const defaultSketchFolder = "/Notes/attachments/Excalidraw"
 
 // sketchAttachmentPath returns the Dropbox path for a sketch file.
@@ -25,19 +26,17 @@ func sketchFileName(now time.Time) string {
 	return fmt.Sprintf("sketch-%s", now.Format("20060102-150405"))
 }
 
-// sketchMarkdownLink returns a plain markdown link from today's journal entry
-// (at /Notes/Journal/YYYY/MM/NoteYYYYMMDD.md) to the sketch stored at
-// /Notes/attachments/Excalidraw/<name>.excalidraw. The journal is three
-// directories below /Notes, so the link uses ../../../ to reach /Notes.
-func sketchMarkdownLink(name string) string {
-	return fmt.Sprintf("[%s](../../../attachments/Excalidraw/%s.excalidraw)", name, name)
+// sketchMarkdownLink returns a plain markdown link named name from the note
+// at notePath to the sketch stored at attPath.
+func sketchMarkdownLink(notePath, attPath, name string) string {
+	return fmt.Sprintf("[%s](%s)", name, relativeLink(notePath, attPath))
 }
 
 // runSketch implements the `dropbox-appender sketch` subcommand: it reads an
 // .excalidraw JSON document from stdin, uploads it to the attachments folder,
 // and appends a markdown link to today's journal entry. It returns the process
 // exit code.
-func runSketch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+func runSketch(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
 	data, err := io.ReadAll(stdin)
 	if err != nil {
 		fmt.Fprintf(stderr, "error reading stdin: %v\n", err)
@@ -51,40 +50,48 @@ func runSketch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	fs := flag.NewFlagSet("sketch", flag.ContinueOnError)
 	fs.SetOutput(stderr)
 	name := fs.String("name", "", "filename (without extension) for the sketch; defaults to sketch-YYYYMMDD-HHMMSS")
-	folder := fs.String("folder", defaultSketchFolder, "Dropbox folder for sketch attachments")
+	folder := fs.String("folder", "", "Dropbox folder for sketch attachments (default: Excalidraw in the attachments folder)")
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
 
-	cfg, err := loadConfig(defaultConfigPath())
-	if err != nil {
-		fmt.Fprintf(stderr, "error loading config: %v\n", err)
+	cfg, client, ok := loadClient(stderr, true)
+	if !ok {
 		return 1
 	}
-	token, err := resolveToken(cfg)
+	opts, err := appendOptionsFromConfig(cfg)
 	if err != nil {
-		fmt.Fprintln(stderr, err)
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
 		return 1
 	}
+	opts.Clock = clock
 
 	return runSketchWithClient(args, stdin, stdout, stderr,
-		&DropboxClient{Token: token}, time.Now(), string(data), *name, *folder)
+		client, cfg, clock.Now(), string(data), *name, *folder, opts)
 }
 
 // runSketchWithClient is the testable core of the sketch subcommand. It uploads
 // the provided sketch payload and appends a markdown link to the journal for the
-// given time, using the provided client. name and folder may be empty to use
+// given time, at the path cfg's template gives, using the provided client. name and folder may be empty to use
 // defaults; if name is empty it is derived from now.
 func runSketchWithClient(args []string, stdin io.Reader, stdout, stderr io.Writer,
-	client *DropboxClient, now time.Time, payload, name, folder string) int {
+	client *DropboxClient, cfg *Config, now time.Time, payload, name, folder string, opts appendOptions) int {
 
 	_ = args
 	_ = stdin
 	_ = stdout
 
+	notePath, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
 	if name == "" {
 		name = sketchFileName(now)
 	}
+	if folder == "" {
+		folder = attachmentFolder(cfg.PathTemplate) + "/Excalidraw"
+	}
 
 	attPath := sketchAttachmentPath(folder, name)
 	if err := client.Upload(attPath, payload); err != nil {
@@ -92,13 +99,13 @@ func runSketchWithClient(args []string, stdin io.Reader, stdout, stderr io.Write
 		return 1
 	}
 
-	journalPath := resolvePath(now)
-	entry := formatEntry(now, sketchMarkdownLink(name), false)
-	if err := appendToJournal(client, journalPath, entry); err != nil {
+	entry := formatEntry(now, sketchMarkdownLink(notePath, attPath, name), false)
+	notePath, err = appendToJournal(client, notePath, entry, opts)
+	if err != nil {
 		fmt.Fprintf(stderr, "error updating journal: %v\n", err)
 		return 1
 	}
 
-	fmt.Fprintf(stderr, "Saved %s and linked in %s\n", attPath, journalPath)
+	fmt.Fprintf(stderr, "Saved %s and linked in %s\n", attPath, notePath)
 	return 0
 }
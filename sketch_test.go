@@ -36,7 +36,7 @@ func TestSketchFileName(t *testing.T) {
 }
 
 func TestSketchMarkdownLink(t *testing.T) {
-	got := sketchMarkdownLink("sketch-20250115-143045")
+	got := sketchMarkdownLink("/Notes/Journal/2025/01/Note20250115.md", "/Notes/attachments/Excalidraw/sketch-20250115-143045.excalidraw", "sketch-20250115-143045")
 	want := "[sketch-20250115-143045](../../../attachments/Excalidraw/sketch-20250115-143045.excalidraw)"
 	if got != want {
 		t.Errorf("got %q, want %q", got, want)
@@ -45,7 +45,7 @@ func TestSketchMarkdownLink(t *testing.T) {
 
 func TestRunSketch_EmptyStdin(t *testing.T) {
 	var stderr bytes.Buffer
-	code := runSketch([]string{}, strings.NewReader(""), io.Discard, &stderr)
+	code := runSketch([]string{}, strings.NewReader(""), io.Discard, &stderr, systemClock{})
 	if code != 1 {
 		t.Errorf("expected exit code 1 for empty stdin, got %d", code)
 	}
@@ -88,9 +88,9 @@ func TestRunSketchWithClient_NewJournal(t *testing.T) {
 	var stderr bytes.Buffer
 	code := runSketchWithClient(
 		nil, strings.NewReader(""), io.Discard, &stderr,
-		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{},
 		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
-		sketchPayload, "my-sketch", "",
+		sketchPayload, "my-sketch", "", appendOptions{},
 	)
 	if code != 0 {
 		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
@@ -136,9 +136,9 @@ func TestRunSketchWithClient_ExistingJournal(t *testing.T) {
 	var stderr bytes.Buffer
 	code := runSketchWithClient(
 		nil, strings.NewReader(""), io.Discard, &stderr,
-		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{},
 		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
-		`{"type":"excalidraw"}`, "s2", "",
+		`{"type":"excalidraw"}`, "s2", "", appendOptions{},
 	)
 	if code != 0 {
 		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
@@ -167,9 +167,9 @@ func TestRunSketchWithClient_DefaultName(t *testing.T) {
 	var stderr bytes.Buffer
 	code := runSketchWithClient(
 		nil, strings.NewReader(""), io.Discard, &stderr,
-		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{},
 		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
-		`{"type":"excalidraw"}`, "", "",
+		`{"type":"excalidraw"}`, "", "", appendOptions{},
 	)
 	if code != 0 {
 		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
@@ -178,3 +178,25 @@ func TestRunSketchWithClient_DefaultName(t *testing.T) {
 		t.Errorf("expected default-named upload, got arg: %q", uploadedSketchArg)
 	}
 }
+
+// TestRunSketchWithClient_PathTemplate verifies the link goes to the note the
+// path template gives, relative to it.
+func TestRunSketchWithClient_PathTemplate(t *testing.T) {
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	var stderr bytes.Buffer
+	code := runSketchWithClient(
+		nil, strings.NewReader(""), io.Discard, &stderr,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL}, &Config{PathTemplate: "/Notes/Daily/{{.Year}}/{{.Date}}.md"},
+		time.Date(2025, 1, 15, 14, 30, 45, 0, time.UTC),
+		`{"type":"excalidraw"}`, "s", "", appendOptions{},
+	)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if want := "### 14:30:45\n[s](../../attachments/Excalidraw/s.excalidraw)\n"; files["/Notes/Daily/2025/20250115.md"] != want {
+		t.Errorf("got %v, want %q in /Notes/Daily/2025/20250115.md", files, want)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackMaxSkew is how old a Slack request timestamp may be before the
+// request is rejected as a possible replay.
+const slackMaxSkew = 5 * time.Minute
+
+// verifySlackSignature checks the X-Slack-Signature of a request body
+// against secret, as described in Slack's "Verifying requests" guide.
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid X-Slack-Request-Timestamp")
+	}
+	if d := now.Sub(time.Unix(secs, 0)); d > slackMaxSkew || d < -slackMaxSkew {
+		return fmt.Errorf("request timestamp is %v away from now", d.Round(time.Second))
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(want)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// handleSlack serves a Slack slash command: the command's text is appended
+// as an entry attributed to the Slack user, and the reply is only shown to
+// them (an ephemeral message).
+func (s *server) handleSlack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := verifySlackSignature(s.cfg.SlackSigningSecret, r.Header, body, s.clock.Now()); err != nil {
+		fmt.Fprintf(s.stderr, "warning: rejected /slack request: %v\n", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form body", http.StatusBadRequest)
+		return
+	}
+
+	text := strings.TrimSpace(form.Get("text"))
+	if text == "" {
+		slackReply(w, fmt.Sprintf("Usage: %s <entry text>", form.Get("command")))
+		return
+	}
+	author := form.Get("user_name")
+	if author == "" {
+		author = form.Get("user_id")
+	}
+	written, err := s.appendText(text, author)
+	switch {
+	case err != nil:
+		fmt.Fprintf(s.stderr, "error: appending /slack entry from %s: %v\n", author, err)
+		slackReply(w, "Sorry, the entry could not be saved.")
+	case written == "":
+		slackReply(w, "Dropbox is unreachable, so the entry was queued and will be added shortly.")
+	default:
+		fmt.Fprintf(s.stderr, "Appended /slack entry from %s to %s\n", author, written)
+		slackReply(w, "Added to the journal.")
+	}
+}
+
+// slackReply writes an ephemeral slash-command response.
+func slackReply(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"response_type": "ephemeral", "text": text})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signSlack sets the headers Slack sends with a signed request.
+func signSlack(req *http.Request, secret, body string, ts time.Time) {
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", stamp, body)
+	req.Header.Set("X-Slack-Request-Timestamp", stamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte("text=hello")
+	req := httptest.NewRequest("POST", "/slack", nil)
+	signSlack(req, "secret", string(body), now)
+
+	if err := verifySlackSignature("secret", req.Header, body, now.Add(time.Minute)); err != nil {
+		t.Errorf("valid request: %v", err)
+	}
+	if err := verifySlackSignature("other", req.Header, body, now); err == nil {
+		t.Error("wrong secret accepted")
+	}
+	if err := verifySlackSignature("secret", req.Header, []byte("text=tampered"), now); err == nil {
+		t.Error("tampered body accepted")
+	}
+	if err := verifySlackSignature("secret", req.Header, body, now.Add(10*time.Minute)); err == nil {
+		t.Error("stale timestamp accepted")
+	}
+}
+
+func TestHandleSlack(t *testing.T) {
+	files := map[string]string{}
+	dropbox := rolloverServer(files)
+	defer dropbox.Close()
+	now := time.Date(2025, 1, 15, 14, 30, 45, 0, time.Local)
+	s := &server{
+		client:   &DropboxClient{Token: "tok", BaseURL: dropbox.URL},
+		cfg:      &Config{PathTemplate: "/Journal/{{.Date}}.md", SlackSigningSecret: "secret"},
+		clock:    fixedClock(now),
+		queueDir: t.TempDir(),
+		stderr:   &bytes.Buffer{},
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	post := func(form url.Values, secret string) (*http.Response, string) {
+		body := form.Encode()
+		req, _ := http.NewRequest("POST", srv.URL+"/slack", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		signSlack(req, secret, body, now)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var reply struct {
+			ResponseType string `json:"response_type"`
+			Text         string `json:"text"`
+		}
+		json.NewDecoder(resp.Body).Decode(&reply)
+		if resp.StatusCode == 200 && reply.ResponseType != "ephemeral" {
+			t.Errorf("response_type = %q, want ephemeral", reply.ResponseType)
+		}
+		return resp, reply.Text
+	}
+
+	resp, text := post(url.Values{"command": {"/journal"}, "text": {"Shipped the release"}, "user_name": {"alice"}}, "secret")
+	if resp.StatusCode != 200 || text != "Added to the journal." {
+		t.Errorf("reply = %d %q", resp.StatusCode, text)
+	}
+	if want := "### 14:30:45 — alice\nShipped the release\n"; files["/Journal/20250115.md"] != want {
+		t.Errorf("note = %q, want %q", files["/Journal/20250115.md"], want)
+	}
+
+	if _, text := post(url.Values{"command": {"/journal"}}, "secret"); text != "Usage: /journal <entry text>" {
+		t.Errorf("empty text reply = %q", text)
+	}
+	if resp, _ := post(url.Values{"text": {"forged"}}, "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("forged request status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestServerHandler_SlackDisabledWithoutSecret(t *testing.T) {
+	s := &server{cfg: &Config{}}
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest("POST", "/slack", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotStampLayout names snapshot files so they sort chronologically.
+const snapshotStampLayout = "20060102T150405.000000000"
+
+// defaultSnapshotDir returns the directory holding pre-upload snapshots.
+func defaultSnapshotDir() string {
+	return filepath.Join(defaultDataDir(), "snapshots")
+}
+
+// noteSnapshotDir returns the folder for notePath's snapshots, mirroring the
+// Dropbox path so they are easy to find by hand.
+func noteSnapshotDir(dir, notePath string) string {
+	return filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(notePath, "/")))
+}
+
+// saveSnapshot stores content as the newest snapshot of notePath and prunes
+// all but the keep most recent.
+func saveSnapshot(dir, notePath, content string, keep int, now time.Time) error {
+	folder := noteSnapshotDir(dir, notePath)
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return err
+	}
+	name := now.UTC().Format(snapshotStampLayout) + filepath.Ext(notePath)
+	if err := writeFileAtomic(filepath.Join(folder, name), []byte(content), 0600); err != nil {
+		return err
+	}
+
+	snaps, err := listSnapshots(dir, notePath)
+	if err != nil {
+		return err
+	}
+	for _, s := range snaps[min(keep, len(snaps)):] {
+		os.Remove(s)
+	}
+	return nil
+}
+
+// listSnapshots returns the snapshot files of notePath, newest first.
+func listSnapshots(dir, notePath string) ([]string, error) {
+	des, err := os.ReadDir(noteSnapshotDir(dir, notePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []string
+	for _, de := range des {
+		if !de.IsDir() && !strings.HasPrefix(de.Name(), ".") {
+			snaps = append(snaps, filepath.Join(noteSnapshotDir(dir, notePath), de.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(snaps)))
+	return snaps, nil
+}
+
+// snapshotTime parses the time a snapshot file was taken from its name.
+func snapshotTime(file string) (time.Time, error) {
+	stamp := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	return time.ParseInLocation(snapshotStampLayout, stamp, time.UTC)
+}
+
+// runRestoreSnapshot implements the `dropbox-appender restore-snapshot`
+// subcommand, listing or restoring local snapshots of a note. It returns the
+// process exit code.
+func runRestoreSnapshot(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("restore-snapshot", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	date := fs.String("date", "", "restore the note for this date (YYYY-MM-DD) instead of today")
+	notePath := fs.String("path", "", "restore this Dropbox path instead of a dated note")
+	list := fs.Bool("list", false, "list the available snapshots instead of restoring")
+	n := fs.Int("n", 1, "which snapshot to restore, 1 being the most recent")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	now := clock.Now()
+	if *date != "" {
+		d, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+			return 2
+		}
+		now = d
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	p := *notePath
+	if p == "" {
+		if p, err = journalPath(cfg, "", now); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+	}
+	if *list {
+		return listSnapshotsCmd(stdout, stderr, defaultSnapshotDir(), p)
+	}
+
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	return runRestoreSnapshotWithClient(stdout, stderr, client, defaultSnapshotDir(), p, *n, clock.Now())
+}
+
+// listSnapshotsCmd prints notePath's snapshots, numbered for -n.
+func listSnapshotsCmd(stdout, stderr io.Writer, dir, notePath string) int {
+	snaps, err := listSnapshots(dir, notePath)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if len(snaps) == 0 {
+		fmt.Fprintf(stderr, "no snapshots of %s\n", notePath)
+		return 0
+	}
+	for i, s := range snaps {
+		info, _ := os.Stat(s)
+		var size int64
+		if info != nil {
+			size = info.Size()
+		}
+		t, _ := snapshotTime(s)
+		fmt.Fprintf(stdout, "%d  %s  %d bytes\n", i+1, t.Local().Format("2006-01-02 15:04:05"), size)
+	}
+	return 0
+}
+
+// runRestoreSnapshotWithClient uploads snapshot n (1 = newest) of notePath
+// over the remote note. The remote content is snapshotted first, so a
+// restore can itself be undone.
+func runRestoreSnapshotWithClient(stdout, stderr io.Writer, client *DropboxClient,
+	dir, notePath string, n int, now time.Time) int {
+
+	snaps, err := listSnapshots(dir, notePath)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	if n < 1 || n > len(snaps) {
+		fmt.Fprintf(stderr, "error: %s has %d snapshots, can't restore number %d\n", notePath, len(snaps), n)
+		return 1
+	}
+	data, err := os.ReadFile(snaps[n-1])
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	current, err := client.Download(notePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading %s: %v\n", notePath, err)
+		return 1
+	}
+	if current != "" {
+		if err := saveSnapshot(dir, notePath, current, len(snaps)+1, now); err != nil {
+			fmt.Fprintf(stderr, "error: saving snapshot of current content: %v\n", err)
+			return 1
+		}
+	}
+	if err := client.Upload(notePath, string(data)); err != nil {
+		fmt.Fprintf(stderr, "error: uploading %s: %v\n", notePath, err)
+		return 1
+	}
+	invalidateTodayCache(todayCachePath())
+
+	t, _ := snapshotTime(snaps[n-1])
+	fmt.Fprintf(stdout, "Restored %s from snapshot of %s\n", notePath, t.Local().Format("2006-01-02 15:04:05"))
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveSnapshot_RingBuffer(t *testing.T) {
+	dir := t.TempDir()
+	note := "/Notes/Journal/2025/01/Note20250115.md"
+	start := time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := saveSnapshot(dir, note, strings.Repeat("x", i+1), 3, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	snaps, err := listSnapshots(dir, note)
+	if err != nil || len(snaps) != 3 {
+		t.Fatalf("expected 3 snapshots, got %v, %v", snaps, err)
+	}
+	if data, _ := os.ReadFile(snaps[0]); string(data) != "xxxxx" {
+		t.Errorf("expected newest first, got %q", data)
+	}
+	if filepath.Dir(snaps[0]) != filepath.Join(dir, "Notes", "Journal", "2025", "01", "Note20250115.md") {
+		t.Errorf("unexpected snapshot folder %s", snaps[0])
+	}
+	if ts, err := snapshotTime(snaps[2]); err != nil || !ts.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("snapshotTime = %v, %v", ts, err)
+	}
+}
+
+func TestAppendToJournal_Snapshot(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "### 09:00:00\nmorning\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	opts := appendOptions{Snapshots: 2, SnapshotDir: dir}
+	if _, err := appendToJournal(client, path, "### 14:30:45\nafternoon\n", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	snaps, _ := listSnapshots(dir, path)
+	if len(snaps) != 1 {
+		t.Fatalf("expected 1 snapshot, got %v", snaps)
+	}
+	if data, _ := os.ReadFile(snaps[0]); string(data) != "### 09:00:00\nmorning\n" {
+		t.Errorf("snapshot should hold the pre-upload content, got %q", data)
+	}
+}
+
+func TestRunRestoreSnapshotWithClient(t *testing.T) {
+	path := "/Notes/Journal/2025/01/Note20250115.md"
+	files := map[string]string{path: "clobbered\n"}
+	server := rolloverServer(files)
+	defer server.Close()
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+
+	dir := t.TempDir()
+	start := time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC)
+	saveSnapshot(dir, path, "older\n", 5, start)
+	saveSnapshot(dir, path, "good\n", 5, start.Add(time.Minute))
+
+	var stdout, stderr bytes.Buffer
+	if code := runRestoreSnapshotWithClient(&stdout, &stderr, client, dir, path, 1, start.Add(time.Hour)); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, stderr.String())
+	}
+	if files[path] != "good\n" {
+		t.Errorf("expected newest snapshot restored, got %q", files[path])
+	}
+	snaps, _ := listSnapshots(dir, path)
+	if data, _ := os.ReadFile(snaps[0]); len(snaps) != 3 || string(data) != "clobbered\n" {
+		t.Errorf("expected the replaced content to be snapshotted, got %v", snaps)
+	}
+
+	if code := runRestoreSnapshotWithClient(io.Discard, &stderr, client, dir, path, 9, start); code != 1 {
+		t.Errorf("expected failure for a missing snapshot, got %d", code)
+	}
+
+	stdout.Reset()
+	listSnapshotsCmd(&stdout, &stderr, dir, path)
+	if lines := strings.Split(strings.TrimSpace(stdout.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "1  ") {
+		t.Errorf("unexpected listing %q", stdout.String())
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// spaceCacheTTL is how long a fetched space usage is trusted, keeping
+	// the pre-flight check to one API call an hour.
+	spaceCacheTTL = time.Hour
+	// exitNoSpace is the exit code when an upload failed because the
+	// Dropbox account is full.
+	exitNoSpace = 4
+)
+
+// spaceUsage is the account's storage use, as cached between runs.
+type spaceUsage struct {
+	Used      int64     `json:"used"`
+	Allocated int64     `json:"allocated"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// percent returns how full the account is, or 0 when the allocation is
+// unknown.
+func (s spaceUsage) percent() float64 {
+	if s.Allocated <= 0 {
+		return 0
+	}
+	return float64(s.Used) * 100 / float64(s.Allocated)
+}
+
+// SpaceUsage returns the account's storage use with users/get_space_usage.
+// For team accounts the team's shared allocation is reported.
+func (c *DropboxClient) SpaceUsage() (spaceUsage, error) {
+	body, err := c.rpc("/2/users/get_space_usage", nil)
+	if err != nil {
+		return spaceUsage{}, err
+	}
+	var result struct {
+		Used       int64 `json:"used"`
+		Allocation struct {
+			Tag       string `json:".tag"`
+			Used      int64  `json:"used"`
+			Allocated int64  `json:"allocated"`
+		} `json:"allocation"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return spaceUsage{}, fmt.Errorf("parsing get_space_usage response: %w", err)
+	}
+	s := spaceUsage{Used: result.Used, Allocated: result.Allocation.Allocated}
+	if result.Allocation.Tag == "team" {
+		s.Used = result.Allocation.Used
+	}
+	return s, nil
+}
+
+// spaceCachePath returns the file caching the last space usage.
+func spaceCachePath() string {
+	return filepath.Join(defaultCacheDir(), "space.json")
+}
+
+// checkSpace warns on stderr when the account is at least threshold percent
+// full. The usage is cached in cachePath for spaceCacheTTL. It is a best
+// effort pre-flight: failing to fetch the usage never blocks an append.
+func checkSpace(client *DropboxClient, threshold float64, cachePath string, now time.Time, stderr io.Writer) {
+	var s spaceUsage
+	data, err := os.ReadFile(cachePath)
+	if err != nil || json.Unmarshal(data, &s) != nil || now.Sub(s.FetchedAt) > spaceCacheTTL || now.Before(s.FetchedAt) {
+		if s, err = client.SpaceUsage(); err != nil {
+			return
+		}
+		s.FetchedAt = now
+		if data, err := json.Marshal(s); err == nil {
+			os.MkdirAll(filepath.Dir(cachePath), 0700)
+			writeFileAtomic(cachePath, data, 0600)
+		}
+	}
+	if p := s.percent(); p >= threshold {
+		fmt.Fprintf(stderr, tr("warning: your Dropbox is %.0f%% full (%s of %s)\n"), p, formatBytes(s.Used), formatBytes(s.Allocated))
+	}
+}
+
+// formatBytes formats n with a binary unit, e.g. "1.9 GB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// reportNoSpace explains an upload rejected with insufficient_space and
+// returns exitNoSpace, or returns 0 when err is something else.
+func reportNoSpace(stderr io.Writer, err error) int {
+	if !errors.Is(err, ErrInsufficientSpace) {
+		return 0
+	}
+	fmt.Fprintf(stderr, tr("error: %v\n"), err)
+	fmt.Fprintln(stderr, "Your Dropbox is full, so the entry was not saved. Free up space (old attachments")
+	fmt.Fprintln(stderr, "and large files are the usual culprits), or capture with -queue and run")
+	fmt.Fprintln(stderr, "dropbox-appender flush once there is room.")
+	return exitNoSpace
+}
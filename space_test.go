@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func spaceServer(t *testing.T, used, allocated int64, calls *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2/users/get_space_usage" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		*calls++
+		fmt.Fprintf(w, `{"used": %d, "allocation": {".tag": "individual", "allocated": %d}}`, used, allocated)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheckSpace(t *testing.T) {
+	var calls int
+	srv := spaceServer(t, 1900<<20, 2000<<20, &calls)
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	cache := filepath.Join(t.TempDir(), "space.json")
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	var stderr bytes.Buffer
+	checkSpace(client, 90, cache, now, &stderr)
+	if want := "warning: your Dropbox is 95% full (1.9 GB of 2.0 GB)\n"; stderr.String() != want {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+
+	// Within the TTL the cached usage is used; below the threshold nothing
+	// is printed.
+	stderr.Reset()
+	checkSpace(client, 96, cache, now.Add(30*time.Minute), &stderr)
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (cached)", calls)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("stderr = %q, want nothing below threshold", stderr.String())
+	}
+
+	checkSpace(client, 96, cache, now.Add(2*time.Hour), &stderr)
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 after the cache expired", calls)
+	}
+}
+
+func TestCheckSpace_Unavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	var stderr bytes.Buffer
+	checkSpace(client, 1, filepath.Join(t.TempDir(), "space.json"), time.Now(), &stderr)
+	if stderr.Len() != 0 {
+		t.Errorf("stderr = %q, want a failed check to stay silent", stderr.String())
+	}
+}
+
+func TestReportNoSpace(t *testing.T) {
+	resp := &http.Response{StatusCode: 409, Header: http.Header{}}
+	err := fmt.Errorf("uploading: %w", newAPIError(resp, []byte(`{"error_summary": "path/insufficient_space/"}`)))
+
+	var stderr bytes.Buffer
+	if code := reportNoSpace(&stderr, err); code != exitNoSpace {
+		t.Errorf("code = %d, want %d", code, exitNoSpace)
+	}
+	if !strings.Contains(stderr.String(), "Free up space") {
+		t.Errorf("stderr = %q, want a hint", stderr.String())
+	}
+	if code := reportNoSpace(&stderr, ErrConflict); code != 0 {
+		t.Errorf("code = %d for another error, want 0", code)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 1536: "1.5 KB", 3 << 30: "3.0 GB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
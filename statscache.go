@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// statsCache holds the per-day aggregates of each month, so `stats` on a
+// multi-year journal only aggregates the months that changed.
+type statsCache struct {
+	Months map[string]*cachedMonth `json:"months"` // keyed by 2006-01
+}
+
+// cachedMonth is the aggregates of a month's notes. Key fingerprints the
+// notes' paths and revisions: when a note is appended to, edited elsewhere
+// and reindexed, or removed, the key changes and the month is aggregated
+// again.
+type cachedMonth struct {
+	Key  string                   `json:"key"`
+	Days map[string]*dayAggregate `json:"days"`
+}
+
+// defaultStatsCachePath returns the location of the stats cache.
+func defaultStatsCachePath() string {
+	return filepath.Join(defaultDataDir(), "stats-cache.json")
+}
+
+// loadStatsCache reads the cache at p. A missing or unreadable cache is
+// empty: it only saves work.
+func loadStatsCache(p string) *statsCache {
+	c := &statsCache{}
+	if data, err := os.ReadFile(p); err == nil {
+		json.Unmarshal(data, c)
+	}
+	if c.Months == nil {
+		c.Months = map[string]*cachedMonth{}
+	}
+	return c
+}
+
+// save writes the cache to p atomically.
+func (c *statsCache) save(p string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data, 0600)
+}
+
+// monthKey fingerprints the notes of a month by path, revision and entry
+// count; the count catches notes indexed without a revision.
+func monthKey(paths []string, notes map[string]*indexedNote) string {
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s\x00%s\x00%d\n", p, notes[p].Rev, len(notes[p].Entries))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedStats computes the stats of the indexed entries within dates from
+// cachedDays.
+func cachedStats(idx *localIndex, cachePath string, dates dateFilter, today time.Time, stderr io.Writer) journalStats {
+	return summarizeStats(cachedDays(idx, cachePath, dates, stderr), today)
+}
+
+// cachedDays aggregates the indexed entries within dates by day, reusing the
+// aggregates in the cache at cachePath for months whose notes haven't
+// changed, and updating it for those that have.
+func cachedDays(idx *localIndex, cachePath string, dates dateFilter, stderr io.Writer) map[string]*dayAggregate {
+	months := map[string][]string{}
+	for p, n := range idx.Notes {
+		if len(n.Entries) > 0 {
+			m := n.Entries[0].Date[:min(7, len(n.Entries[0].Date))]
+			months[m] = append(months[m], p)
+		}
+	}
+
+	cache := loadStatsCache(cachePath)
+	dirty := false
+	for m := range cache.Months {
+		if _, ok := months[m]; !ok {
+			delete(cache.Months, m)
+			dirty = true
+		}
+	}
+	byDay := map[string]*dayAggregate{}
+	for m, paths := range months {
+		key := monthKey(paths, idx.Notes)
+		cm := cache.Months[m]
+		if cm == nil || cm.Key != key {
+			var entries []indexEntry
+			for _, p := range paths {
+				entries = append(entries, idx.Notes[p].Entries...)
+			}
+			cm = &cachedMonth{Key: key, Days: aggregateDays(entries)}
+			cache.Months[m] = cm
+			dirty = true
+		}
+		for d, day := range cm.Days {
+			if dates.match(d) {
+				byDay[d] = day
+			}
+		}
+	}
+	if dirty {
+		if err := cache.save(cachePath); err != nil {
+			fmt.Fprintf(stderr, "warning: saving stats cache: %v\n", err)
+		}
+	}
+	return byDay
+}
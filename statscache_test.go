@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func statsIndex() *localIndex {
+	idx := &localIndex{Notes: map[string]*indexedNote{}}
+	for _, e := range queryEntries {
+		p := "/Notes/Journal/2025/01/Note" + e.Date[:4] + e.Date[5:7] + e.Date[8:] + ".md"
+		if idx.Notes[p] == nil {
+			idx.Notes[p] = &indexedNote{Rev: "r1"}
+		}
+		e.Path = p
+		idx.Notes[p].Entries = append(idx.Notes[p].Entries, e)
+	}
+	idx.Notes["/Notes/Journal/2024/12/Note20241231.md"] = &indexedNote{Rev: "r1", Entries: []indexEntry{
+		{Date: "2024-12-31", Time: "23:00:00", Text: "last entry of the year #home", Tags: []string{"home"}},
+	}}
+	return idx
+}
+
+func TestCachedStats(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "stats-cache.json")
+	today := time.Date(2025, 1, 16, 8, 0, 0, 0, time.UTC)
+	idx := statsIndex()
+	var stderr bytes.Buffer
+
+	got := cachedStats(idx, cachePath, dateFilter{}, today, &stderr)
+	if want := computeStats(idx.entries(), today); !reflect.DeepEqual(got, want) {
+		t.Errorf("cached stats differ from computed:\n got %+v\nwant %+v", got, want)
+	}
+	cache := loadStatsCache(cachePath)
+	if len(cache.Months) != 2 {
+		t.Fatalf("expected 2 cached months, got %d", len(cache.Months))
+	}
+
+	// Unchanged months are served from the cache.
+	day := cache.Months["2025-01"].Days["2025-01-14"]
+	want := got.Words - day.Words + 1000
+	day.Words = 1000
+	cache.save(cachePath)
+	if s := cachedStats(idx, cachePath, dateFilter{}, today, &stderr); s.Words != want {
+		t.Errorf("expected the cached words to be used (%d), got %d", want, s.Words)
+	}
+
+	// A note with a new revision invalidates its month.
+	idx.Notes["/Notes/Journal/2025/01/Note20250114.md"].Rev = "r2"
+	fresh := computeStats(idx.entries(), today)
+	if s := cachedStats(idx, cachePath, dateFilter{}, today, &stderr); s.Words != fresh.Words {
+		t.Errorf("expected %d words after the edit, got %d", fresh.Words, s.Words)
+	}
+
+	// Date filters apply to the cached days.
+	s := cachedStats(idx, cachePath, dateFilter{From: "2025-01-14", To: "2025-01-15"}, today, &stderr)
+	if s.Entries != 3 || s.First != "2025-01-14" {
+		t.Errorf("unexpected filtered stats: %+v", s)
+	}
+
+	// Months no longer in the index are dropped.
+	delete(idx.Notes, "/Notes/Journal/2024/12/Note20241231.md")
+	cachedStats(idx, cachePath, dateFilter{}, today, &stderr)
+	if _, ok := loadStatsCache(cachePath).Months["2024-12"]; ok {
+		t.Error("expected the removed month to be dropped from the cache")
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("unexpected warnings: %s", stderr.String())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor systemd passes sockets on.
+const listenFDsStart = 3
+
+// listenFDs returns how many sockets systemd passed to the process with the
+// given pid, and their names from FileDescriptorName=, according to the
+// LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES variables read with getenv.
+// Sockets meant for another process (e.g. a parent shell) don't count.
+func listenFDs(getenv func(string) string, pid int) (int, []string, error) {
+	if getenv("LISTEN_PID") == "" {
+		return 0, nil, nil
+	}
+	if p, err := strconv.Atoi(getenv("LISTEN_PID")); err != nil || p != pid {
+		return 0, nil, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return 0, nil, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(getenv("LISTEN_FDNAMES"), ":")
+	if len(names) != n {
+		names = make([]string, n)
+	}
+	return n, names, nil
+}
+
+// activatedListeners returns the sockets systemd socket activation passed
+// in, none when the process wasn't socket activated. The variables are
+// cleared so commands run from the process don't pick them up.
+func activatedListeners() ([]net.Listener, error) {
+	n, names, err := listenFDs(os.Getenv, os.Getpid())
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFDsStart + i
+		syscall.CloseOnExec(fd)
+		name := names[i]
+		if name == "" {
+			name = "LISTEN_FD_" + strconv.Itoa(fd)
+		}
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		f.Close() // FileListener has its own copy
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket %s from systemd: %w", name, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// sdNotify sends state, e.g. "READY=1", to the service manager when it
+// asked for notifications with NOTIFY_SOCKET (Type=notify); otherwise it
+// does nothing.
+func sdNotify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	if strings.HasPrefix(addr, "@") {
+		addr = "\x00" + addr[1:] // abstract socket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("notifying systemd: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("notifying systemd: %w", err)
+	}
+	return nil
+}
+
+// watchdogInterval returns how often to ping systemd's watchdog, half of
+// WatchdogSec=, or 0 when the watchdog isn't enabled for this process.
+func watchdogInterval(getenv func(string) string, pid int) time.Duration {
+	usec, err := strconv.ParseInt(getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if p := getenv("WATCHDOG_PID"); p != "" && p != strconv.Itoa(pid) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// serviceReady tells systemd the service is up and keeps its watchdog fed
+// until stop is closed. Outside systemd it does nothing; callers report
+// STOPPING=1 themselves once they begin shutting down.
+func serviceReady(stop <-chan struct{}) {
+	if err := sdNotify("READY=1"); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	interval := watchdogInterval(os.Getenv, os.Getpid())
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				sdNotify("WATCHDOG=1")
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
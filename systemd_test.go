@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func envMap(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func TestListenFDs(t *testing.T) {
+	n, names, err := listenFDs(envMap(map[string]string{
+		"LISTEN_PID": "42", "LISTEN_FDS": "2", "LISTEN_FDNAMES": "http:admin",
+	}), 42)
+	if err != nil || n != 2 || names[0] != "http" || names[1] != "admin" {
+		t.Errorf("got %d %q %v", n, names, err)
+	}
+
+	// Sockets passed to another process aren't ours.
+	if n, _, _ := listenFDs(envMap(map[string]string{"LISTEN_PID": "41", "LISTEN_FDS": "1"}), 42); n != 0 {
+		t.Errorf("expected no sockets for another pid, got %d", n)
+	}
+	if n, _, _ := listenFDs(envMap(nil), 42); n != 0 {
+		t.Errorf("expected no sockets without socket activation, got %d", n)
+	}
+	if _, _, err := listenFDs(envMap(map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "x"}), 42); err == nil {
+		t.Error("expected an error for an invalid LISTEN_FDS")
+	}
+
+	// Names that don't match the count are ignored.
+	n, names, _ = listenFDs(envMap(map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "2", "LISTEN_FDNAMES": "http"}), 42)
+	if n != 2 || len(names) != 2 || names[0] != "" {
+		t.Errorf("got %d %q", n, names)
+	}
+}
+
+func TestSDNotify(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", sock)
+
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "READY=1" {
+		t.Errorf("got %q %v", buf[:n], err)
+	}
+}
+
+func TestSDNotify_OutsideSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("expected no error without NOTIFY_SOCKET, got %v", err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	if d := watchdogInterval(envMap(map[string]string{"WATCHDOG_USEC": "30000000"}), 42); d != 15*time.Second {
+		t.Errorf("expected half of WatchdogSec, got %v", d)
+	}
+	if d := watchdogInterval(envMap(map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": "41"}), 42); d != 0 {
+		t.Errorf("expected no watchdog for another pid, got %v", d)
+	}
+	if d := watchdogInterval(envMap(nil), 42); d != 0 {
+		t.Errorf("expected no watchdog when disabled, got %v", d)
+	}
+}
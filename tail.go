@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// tailWindow is the first number of bytes fetched by tailLines; it doubles
+// until the window holds enough lines.
+const tailWindow = 4 << 10
+
+// contentRangeTotal returns the complete length from a 206 response's
+// Content-Range header ("bytes 0-99/1234"), or -1 when it is unknown.
+func contentRangeTotal(resp *http.Response) int64 {
+	cr := resp.Header.Get("Content-Range")
+	_, total, ok := strings.Cut(cr, "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// DownloadTail fetches at most the last n bytes of a file with an HTTP
+// Range request, returning them and the file's full size. A missing file
+// is empty. When the tail starts mid-file it is trimmed to begin at a line
+// boundary, so it never starts with a partial line or UTF-8 sequence.
+func (c *DropboxClient) DownloadTail(path string, n int64) (string, int64, error) {
+	resp, body, err := c.download(path, fmt.Sprintf("bytes=-%d", n))
+	if err != nil {
+		return "", 0, fmt.Errorf("download request: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored the range and sent the whole file.
+		return string(body), int64(len(body)), nil
+	case http.StatusPartialContent:
+		size := contentRangeTotal(resp)
+		if size < 0 {
+			return "", 0, errors.New("download request: missing Content-Range in partial response")
+		}
+		tail := string(body)
+		if size > int64(len(body)) {
+			if i := strings.IndexByte(tail, '\n'); i >= 0 {
+				tail = tail[i+1:]
+			} else {
+				tail = ""
+			}
+		}
+		return tail, size, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		return "", 0, nil // an empty file
+	}
+	apiErr := newAPIError(resp, body)
+	if errors.Is(apiErr, ErrNotFound) {
+		return "", 0, nil
+	}
+	return "", 0, apiErr
+}
+
+// tailLines returns the last n lines of the file at path, fetching a
+// growing window from its end instead of downloading a large note in full.
+func tailLines(client *DropboxClient, path string, n int) (string, error) {
+	for window := int64(tailWindow); ; window *= 2 {
+		tail, size, err := client.DownloadTail(path, window)
+		if err != nil {
+			return "", err
+		}
+		lines := strings.SplitAfter(strings.TrimSuffix(tail, "\n"), "\n")
+		if len(lines) >= n || window >= size {
+			if len(lines) > n {
+				lines = lines[len(lines)-n:]
+			}
+			out := strings.Join(lines, "")
+			if out != "" && !strings.HasSuffix(out, "\n") {
+				out += "\n"
+			}
+			return out, nil
+		}
+	}
+}
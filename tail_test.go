@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rangeServer serves content from files/download, honouring "bytes=-N" and
+// "bytes=A-B" ranges, and records the ranges requested.
+func rangeServer(t *testing.T, content string, ranges *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		*ranges = append(*ranges, rng)
+		w.Header().Set("Dropbox-API-Result", `{"rev": "r1"}`)
+		if rng == "" {
+			w.Write([]byte(content))
+			return
+		}
+		spec := strings.TrimPrefix(rng, "bytes=")
+		size := len(content)
+		start, end := 0, size-1
+		if n, ok := strings.CutPrefix(spec, "-"); ok {
+			k, _ := strconv.Atoi(n)
+			start = max(size-k, 0)
+		} else {
+			a, b, _ := strings.Cut(spec, "-")
+			start, _ = strconv.Atoi(a)
+			e, _ := strconv.Atoi(b)
+			end = min(e, size-1)
+		}
+		if size == 0 || start >= size {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[start : end+1]))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownloadTail(t *testing.T) {
+	var ranges []string
+	srv := rangeServer(t, "first line\nsecond line\nthird\n", &ranges)
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	tail, size, err := client.DownloadTail("/note.md", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last 10 bytes are "ine\nthird\n"; the partial line is dropped.
+	if tail != "third\n" || size != 29 {
+		t.Errorf("DownloadTail = %q, %d; want %q, 29", tail, size, "third\n")
+	}
+	if ranges[0] != "bytes=-10" {
+		t.Errorf("Range = %q", ranges[0])
+	}
+
+	tail, _, _ = client.DownloadTail("/note.md", 1000)
+	if tail != "first line\nsecond line\nthird\n" {
+		t.Errorf("whole-file tail = %q", tail)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	var b strings.Builder
+	for i := 1; i <= 2000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	var ranges []string
+	srv := rangeServer(t, b.String(), &ranges)
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	got, err := tailLines(client, "/note.md", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "line 1998\nline 1999\nline 2000\n" {
+		t.Errorf("tailLines = %q", got)
+	}
+	if len(ranges) != 1 {
+		t.Errorf("requests = %v, want one small ranged fetch", ranges)
+	}
+
+	// More lines than a window holds: the window grows until the whole
+	// file has been fetched.
+	ranges = nil
+	got, _ = tailLines(client, "/note.md", 5000)
+	if got != b.String() || len(ranges) < 2 {
+		t.Errorf("tailLines(5000): %d bytes in %d requests", len(got), len(ranges))
+	}
+}
+
+func TestDownloadRev_MaxDownload(t *testing.T) {
+	var ranges []string
+	srv := rangeServer(t, strings.Repeat("x", 100), &ranges)
+
+	small := &DropboxClient{Token: "tok", BaseURL: srv.URL, MaxDownload: 50}
+	if _, _, err := small.DownloadRev("/big.md"); err == nil || !strings.Contains(err.Error(), "max_download_size") {
+		t.Errorf("err = %v, want max_download_size error", err)
+	}
+	if ranges[0] != "bytes=0-50" {
+		t.Errorf("Range = %q, want bytes=0-50", ranges[0])
+	}
+
+	big := &DropboxClient{Token: "tok", BaseURL: srv.URL, MaxDownload: 1000}
+	content, rev, err := big.DownloadRev("/big.md")
+	if err != nil || len(content) != 100 || rev != "r1" {
+		t.Errorf("DownloadRev = %d bytes, %q, %v", len(content), rev, err)
+	}
+
+	empty := rangeServer(t, "", &ranges)
+	client := &DropboxClient{Token: "tok", BaseURL: empty.URL, MaxDownload: 1000}
+	if content, rev, err := client.DownloadRev("/empty.md"); err != nil || content != "" || rev != "r1" {
+		t.Errorf("empty file: %q, %q, %v", content, rev, err)
+	}
+}
+
+func TestShowNoteTail(t *testing.T) {
+	var ranges []string
+	srv := rangeServer(t, "### 09:00:00\nmorning\n\n### 14:30:45\nafternoon\n", &ranges)
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+
+	var stdout, stderr bytes.Buffer
+	showNoteTail(&stdout, &stderr, client, "/note.md", 2)
+	if stdout.String() != "### 14:30:45\nafternoon\n" || stderr.Len() != 0 {
+		t.Errorf("stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+
+	srv.Close()
+	stdout.Reset()
+	showNoteTail(&stdout, &stderr, client, "/note.md", 2)
+	if stdout.Len() != 0 || !strings.Contains(stderr.String(), "warning: reading back /note.md") {
+		t.Errorf("a failed read-back should only warn: stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultExportDays is how far back `tasks export` looks without -from.
+const defaultExportDays = 14
+
+var (
+	// dueDatePattern matches a due date in task text, in the Obsidian Tasks
+	// (📅 2025-01-20) or todo.txt (due:2025-01-20) style.
+	dueDatePattern = regexp.MustCompile(`\s*(?:📅\s*|\bdue:)(\d{4}-\d{2}-\d{2})`)
+	// doneDatePattern matches the completion date `tasks complete` adds.
+	doneDatePattern = regexp.MustCompile(`\s*✅\s*(\d{4}-\d{2}-\d{2})`)
+)
+
+// taskDates splits the due and completion dates off task text.
+func taskDates(text string) (clean, due, done string) {
+	if m := dueDatePattern.FindStringSubmatch(text); m != nil {
+		due = m[1]
+	}
+	if m := doneDatePattern.FindStringSubmatch(text); m != nil {
+		done = m[1]
+	}
+	clean = doneDatePattern.ReplaceAllString(dueDatePattern.ReplaceAllString(text, ""), "")
+	return strings.TrimSpace(clean), due, done
+}
+
+// writeTodoistCSV writes tasks in Todoist's CSV import format.
+func writeTodoistCSV(w io.Writer, tasks []journalTask) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"TYPE", "CONTENT", "DESCRIPTION", "PRIORITY", "INDENT", "AUTHOR", "RESPONSIBLE", "DATE", "DATE_LANG", "TIMEZONE"})
+	for _, t := range tasks {
+		text, due, _ := taskDates(t.Text)
+		cw.Write([]string{"task", text, "From journal " + t.Date, "1", "1", "", "", due, "en", ""})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// taskwarriorTask is one task in Taskwarrior's JSON import format.
+type taskwarriorTask struct {
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Entry       string   `json:"entry"`
+	Due         string   `json:"due,omitempty"`
+	End         string   `json:"end,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// taskwarriorDate converts a YYYY-MM-DD date to Taskwarrior's format.
+func taskwarriorDate(date string) string {
+	if date == "" {
+		return ""
+	}
+	return strings.ReplaceAll(date, "-", "") + "T000000Z"
+}
+
+// writeTaskwarriorJSON writes tasks as a JSON array for `task import`.
+func writeTaskwarriorJSON(w io.Writer, tasks []journalTask) error {
+	out := make([]taskwarriorTask, 0, len(tasks))
+	for _, t := range tasks {
+		text, due, done := taskDates(t.Text)
+		tw := taskwarriorTask{Description: text, Status: "pending", Entry: taskwarriorDate(t.Date), Due: taskwarriorDate(due)}
+		if t.Done {
+			tw.Status = "completed"
+			if done == "" {
+				done = t.Date
+			}
+			tw.End = taskwarriorDate(done)
+		}
+		for _, m := range tagPattern.FindAllStringSubmatch(text, -1) {
+			tw.Tags = append(tw.Tags, m[1])
+		}
+		out = append(out, tw)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// runTasksExport implements `dropbox-appender tasks export`.
+func runTasksExport(args []string, stdout, stderr io.Writer, now time.Time) int {
+	fs := flag.NewFlagSet("tasks export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "todoist-csv", "output format: todoist-csv or taskwarrior-json")
+	all := fs.Bool("all", false, "include completed tasks")
+	days := fs.Int("days", defaultExportDays, "export tasks from the last N days when -from isn't set")
+	dates := addDateFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if dates.From == "" {
+		dates.From = now.AddDate(0, 0, -*days).Format("2006-01-02")
+	}
+
+	var write func(io.Writer, []journalTask) error
+	switch *format {
+	case "todoist-csv":
+		write = writeTodoistCSV
+	case "taskwarrior-json":
+		write = writeTaskwarriorJSON
+	default:
+		fmt.Fprintf(stderr, "unknown -format %q: expected todoist-csv or taskwarrior-json\n", *format)
+		return 2
+	}
+
+	entries, ok := loadIndexedEntries(stderr)
+	if !ok {
+		return 1
+	}
+	if err := write(stdout, findTasks(searchEntries(entries, nil, entryFilter{Dates: *dates}), *all)); err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	return 0
+}
+
+// runTasksComplete implements `dropbox-appender tasks complete <n>`, which
+// checks off the nth open task as numbered by `tasks` with the same -from
+// and -to.
+func runTasksComplete(args []string, stdout, stderr io.Writer, now time.Time) int {
+	fs := flag.NewFlagSet("tasks complete", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dates := addDateFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	n, err := strconv.Atoi(fs.Arg(0))
+	if fs.NArg() != 1 || err != nil {
+		fmt.Fprintln(stderr, "usage: dropbox-appender tasks complete [-from DATE] [-to DATE] <n>")
+		return 2
+	}
+
+	entries, ok := loadIndexedEntries(stderr)
+	if !ok {
+		return 1
+	}
+	tasks := findTasks(searchEntries(entries, nil, entryFilter{Dates: *dates}), false)
+	if n < 1 || n > len(tasks) {
+		fmt.Fprintf(stderr, "error: no open task %d (there are %d)\n", n, len(tasks))
+		return 1
+	}
+
+	cfg, client, ok := loadClient(stderr, true)
+	if !ok {
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = fixedClock(now)
+	return runTasksCompleteWithClient(stdout, stderr, client, tasks, n, opts, now)
+}
+
+// runTasksCompleteWithClient checks off tasks[n-1] in its remote note,
+// stamping it with today's date. Identical open tasks earlier in the same
+// note are skipped so the one the user numbered is changed. The upload is
+// rev-protected, and the index and mirrors are updated afterwards as opts
+// configures (see wroteNote).
+func runTasksCompleteWithClient(stdout, stderr io.Writer, client *DropboxClient,
+	tasks []journalTask, n int, opts appendOptions, now time.Time) int {
+
+	task := tasks[n-1]
+	skip := 0
+	for _, t := range tasks[:n-1] {
+		if t.Path == task.Path && t.Text == task.Text {
+			skip++
+		}
+	}
+
+	content, rev, err := client.DownloadRev(task.Path)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading %s: %v\n", task.Path, err)
+		return 1
+	}
+	line := regexp.MustCompile(`(?m)^(\s*[-*] )\[ \] (` + regexp.QuoteMeta(task.Text) + `)$`)
+	locs := line.FindAllStringSubmatchIndex(content, -1)
+	if skip >= len(locs) {
+		fmt.Fprintf(stderr, "error: task %q is no longer open in %s; run: dropbox-appender reindex\n", task.Text, task.Path)
+		return 1
+	}
+	loc := locs[skip]
+	updated := content[:loc[0]] + content[loc[2]:loc[3]] + "[x] " + content[loc[4]:loc[5]] +
+		" ✅ " + now.Format("2006-01-02") + content[loc[1]:]
+
+	newRev, err := client.UploadRev(task.Path, updated, rev)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: uploading %s: %v\n", task.Path, err)
+		return 1
+	}
+	wroteNote(client, task.Path, task.Path, updated, updated, newRev, opts)
+	fmt.Fprintf(stdout, "Completed: %s\n", task.Text)
+	return 0
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskDates(t *testing.T) {
+	tests := []struct {
+		text, clean, due, done string
+	}{
+		{"write spec", "write spec", "", ""},
+		{"write spec 📅 2025-01-20", "write spec", "2025-01-20", ""},
+		{"write spec due:2025-01-20 #work", "write spec #work", "2025-01-20", ""},
+		{"write spec 📅 2025-01-20 ✅ 2025-01-18", "write spec", "2025-01-20", "2025-01-18"},
+	}
+	for _, tt := range tests {
+		clean, due, done := taskDates(tt.text)
+		if clean != tt.clean || due != tt.due || done != tt.done {
+			t.Errorf("taskDates(%q) = %q, %q, %q; want %q, %q, %q", tt.text, clean, due, done, tt.clean, tt.due, tt.done)
+		}
+	}
+}
+
+var exportTasks = []journalTask{
+	{Date: "2025-01-13", Text: "write spec 📅 2025-01-20 #work"},
+	{Date: "2025-01-14", Done: true, Text: "book room ✅ 2025-01-15"},
+}
+
+func TestWriteTodoistCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTodoistCSV(&buf, exportTasks[:1]); err != nil {
+		t.Fatal(err)
+	}
+	want := "TYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE\n" +
+		"task,write spec #work,From journal 2025-01-13,1,1,,,2025-01-20,en,\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteTaskwarriorJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTaskwarriorJSON(&buf, exportTasks); err != nil {
+		t.Fatal(err)
+	}
+	var got []taskwarriorTask
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d tasks", len(got))
+	}
+	open := got[0]
+	if open.Description != "write spec #work" || open.Status != "pending" || open.Entry != "20250113T000000Z" ||
+		open.Due != "20250120T000000Z" || len(open.Tags) != 1 || open.Tags[0] != "work" {
+		t.Errorf("open task = %+v", open)
+	}
+	done := got[1]
+	if done.Status != "completed" || done.End != "20250115T000000Z" || done.Description != "book room" {
+		t.Errorf("completed task = %+v", done)
+	}
+}
+
+func TestPrintTasks(t *testing.T) {
+	var buf bytes.Buffer
+	printTasks(&buf, findTasks(queryEntries, true))
+	want := "  1  2025-01-13  [ ] write spec\n" +
+		"     2025-01-13  [x] book room\n" +
+		"  2  2025-01-15  [ ] send spec to team\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestRunTasksCompleteWithClient(t *testing.T) {
+	notePath := "/Journal/2025-01-15.md"
+	files := map[string]string{notePath: "### 09:00:00\n- [ ] call bob\n- [x] old\n\n### 10:00:00\n- [ ] call bob\n"}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	tasks := []journalTask{
+		{Date: "2025-01-15", Text: "call bob", Path: notePath},
+		{Date: "2025-01-15", Text: "call bob", Path: notePath},
+	}
+	setupGitIdentity(t)
+	dir := t.TempDir()
+	opts := appendOptions{
+		PathTemplate: `/Journal/{{.Time.Format "2006-01-02"}}.md`,
+		IndexPath:    filepath.Join(dir, "index.json"),
+		GitMirror:    &GitMirrorConfig{Repo: filepath.Join(dir, "mirror")},
+	}
+	now := time.Date(2025, 1, 16, 8, 0, 0, 0, time.UTC)
+
+	var stdout, stderr bytes.Buffer
+	if code := runTasksCompleteWithClient(&stdout, &stderr, client, tasks, 2, opts, now); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	want := "### 09:00:00\n- [ ] call bob\n- [x] old\n\n### 10:00:00\n- [x] call bob ✅ 2025-01-16\n"
+	if files[notePath] != want {
+		t.Errorf("note = %q, want %q", files[notePath], want)
+	}
+	if mirrored, _ := os.ReadFile(filepath.Join(dir, "mirror", "Journal", "2025-01-15.md")); string(mirrored) != want {
+		t.Errorf("mirror out of sync: %q", mirrored)
+	}
+
+	idx, err := loadIndex(opts.IndexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open := findTasks(idx.entries(), false); len(open) != 1 {
+		t.Errorf("index has %d open tasks after completing one, want 1", len(open))
+	}
+
+	// Once both are done, completing a stale number fails without writing.
+	files[notePath] = strings.ReplaceAll(files[notePath], "[ ]", "[x]")
+	before := files[notePath]
+	if code := runTasksCompleteWithClient(&stdout, &stderr, client, tasks, 1, opts, now); code != 1 {
+		t.Errorf("exit %d, want 1 for a task no longer open", code)
+	}
+	if files[notePath] != before {
+		t.Error("note changed although the task wasn't found")
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultTelegramAPI is the Telegram Bot API endpoint.
+const defaultTelegramAPI = "https://api.telegram.org"
+
+// telegramPollTimeout is how long a getUpdates long poll waits for a message.
+const telegramPollTimeout = 50 * time.Second
+
+// telegramBot is a client for the parts of the Telegram Bot API the
+// telegram subcommand uses.
+type telegramBot struct {
+	Token   string
+	BaseURL string // overridden in tests
+	Client  *http.Client
+}
+
+// telegramUpdate is one incoming update from getUpdates.
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text    string `json:"text"`
+	Caption string `json:"caption"`
+	Photo   []struct {
+		FileID string `json:"file_id"`
+	} `json:"photo"`
+}
+
+// call invokes a Bot API method and decodes its result into out.
+func (b *telegramBot) call(ctx context.Context, method string, params url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", b.baseURL()+"/bot"+b.Token+"/"+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		// The URL holds the token; keep it out of error messages.
+		return fmt.Errorf("telegram %s: %v", method, redactToken(err, b.Token))
+	}
+	defer resp.Body.Close()
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram %s: status %d", method, resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram %s: %s", method, result.Description)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Result, out)
+}
+
+func (b *telegramBot) baseURL() string {
+	if b.BaseURL != "" {
+		return b.BaseURL
+	}
+	return defaultTelegramAPI
+}
+
+// updates long-polls for updates after offset.
+func (b *telegramBot) updates(ctx context.Context, offset int64) ([]telegramUpdate, error) {
+	params := url.Values{
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"timeout":         {strconv.Itoa(int(telegramPollTimeout / time.Second))},
+		"allowed_updates": {`["message"]`},
+	}
+	var updates []telegramUpdate
+	err := b.call(ctx, "getUpdates", params, &updates)
+	return updates, err
+}
+
+// downloadFile returns the contents of the file with the given file_id.
+func (b *telegramBot) downloadFile(fileID string) ([]byte, error) {
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := b.call(context.Background(), "getFile", url.Values{"file_id": {fileID}}, &file); err != nil {
+		return nil, err
+	}
+	resp, err := b.Client.Get(b.baseURL() + "/file/bot" + b.Token + "/" + file.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("downloading photo: %v", redactToken(err, b.Token))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading photo: status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// reply sends text to chat as a reply to message.
+func (b *telegramBot) reply(chat, message int64, text string) error {
+	return b.call(context.Background(), "sendMessage", url.Values{
+		"chat_id":             {strconv.FormatInt(chat, 10)},
+		"reply_to_message_id": {strconv.FormatInt(message, 10)},
+		"text":                {text},
+	}, nil)
+}
+
+// redactToken removes the bot token from err's message.
+func redactToken(err error, token string) string {
+	return strings.ReplaceAll(err.Error(), token, "<token>")
+}
+
+// telegramPhotoPath returns the path in folder for a photo received at now.
+func telegramPhotoPath(folder string, now time.Time) string {
+	return fmt.Sprintf("%s/telegram-%s.jpg", folder, now.Format("20060102-150405"))
+}
+
+// handleTelegram appends msg to today's note, uploading its largest photo
+// as an attachment linked from the entry. Messages from chats not in chats
+// are ignored. It returns the reply to send, or "" for none.
+func (s *server) handleTelegram(bot *telegramBot, msg *telegramMessage, chats []int64) string {
+	if !slices.Contains(chats, msg.Chat.ID) {
+		fmt.Fprintf(s.stderr, "warning: ignoring message from chat %d, which is not in telegram_chats\n", msg.Chat.ID)
+		return ""
+	}
+	text := strings.TrimSpace(msg.Text)
+	if text == "" {
+		text = strings.TrimSpace(msg.Caption)
+	}
+	if len(msg.Photo) > 0 {
+		// Telegram lists the sizes of a photo smallest first.
+		data, err := bot.downloadFile(msg.Photo[len(msg.Photo)-1].FileID)
+		if err != nil {
+			fmt.Fprintf(s.stderr, tr("error: %v\n"), err)
+			return "Sorry, the photo could not be downloaded."
+		}
+		now := s.clock.Now()
+		notePath, err := journalPath(s.cfg, "", now)
+		if err != nil {
+			fmt.Fprintf(s.stderr, tr("error: %v\n"), err)
+			return "Sorry, the entry could not be saved."
+		}
+		attPath := telegramPhotoPath(attachmentFolder(s.cfg.PathTemplate), now)
+		if err := uploadImage(s.client, attPath, data, s.cfg.Image, s.stderr); err != nil {
+			fmt.Fprintf(s.stderr, "error uploading photo: %v\n", err)
+			return "Sorry, the photo could not be saved."
+		}
+		link := fmt.Sprintf("![%s](%s)", path.Base(attPath), relativeLink(notePath, attPath))
+		text = strings.TrimSpace(text + "\n\n" + link)
+	}
+	if text == "" {
+		return "Only text and photos can be added to the journal."
+	}
+
+	written, err := s.appendText(text, "")
+	switch {
+	case err != nil:
+		fmt.Fprintf(s.stderr, "error: appending telegram message: %v\n", err)
+		return "Sorry, the entry could not be saved."
+	case written == "":
+		return "Dropbox is unreachable, so the entry was queued."
+	}
+	fmt.Fprintf(s.stderr, "Appended telegram message to %s\n", written)
+	return "Added to the journal."
+}
+
+// pollTelegram handles updates until stop is closed, backing off while
+// Telegram can't be reached.
+func (s *server) pollTelegram(bot *telegramBot, chats []int64, stop <-chan struct{}) {
+	// Cancelling ctx ends a long poll in progress as soon as stop closes.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var offset int64
+	backoff := time.Second
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		updates, err := bot.updates(ctx, offset)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(s.stderr, "warning: %v; retrying in %v\n", err, backoff)
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxServeBackoff)
+			continue
+		}
+		backoff = time.Second
+		for _, u := range updates {
+			// Asking for offset update_id+1 confirms the update, so
+			// Telegram doesn't deliver it again.
+			offset = u.UpdateID + 1
+			if u.Message == nil {
+				continue
+			}
+			if reply := s.handleTelegram(bot, u.Message, chats); reply != "" {
+				if err := bot.reply(u.Message.Chat.ID, u.Message.MessageID, reply); err != nil {
+					fmt.Fprintf(s.stderr, "warning: %v\n", err)
+				}
+			}
+		}
+	}
+}
+
+// parseChatIDs parses a comma-separated list of Telegram chat IDs.
+func parseChatIDs(s string) ([]int64, error) {
+	var ids []int64
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat ID %q", f)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// runTelegram implements the `dropbox-appender telegram` subcommand,
+// appending messages sent to a Telegram bot from allowed chats until
+// interrupted. It returns the process exit code.
+func runTelegram(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("telegram", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	botToken := fs.String("bot-token", "", "Telegram bot token from @BotFather (overrides config and TELEGRAM_BOT_TOKEN)")
+	chatFlag := fs.String("chats", "", "comma-separated chat IDs allowed to write to the journal (overrides config)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if refuseReadOnly(stderr, "telegram") {
+		return 1
+	}
+	if *botToken != "" {
+		cfg.TelegramBotToken = *botToken
+	}
+	chats := cfg.TelegramChats
+	if *chatFlag != "" {
+		if chats, err = parseChatIDs(*chatFlag); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	if cfg.TelegramBotToken == "" {
+		fmt.Fprintln(stderr, "error: no bot token; pass -bot-token or set telegram_bot_token")
+		return 2
+	}
+	if len(chats) == 0 {
+		fmt.Fprintln(stderr, "warning: no chats allowed; pass -chats or set telegram_chats (a message to the bot logs its chat ID)")
+	}
+
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+
+	s := &server{client: client, cfg: cfg, opts: opts, clock: clock, queueDir: defaultQueueDir(), stderr: stderr}
+	bot := &telegramBot{Token: cfg.TelegramBotToken, Client: &http.Client{Timeout: telegramPollTimeout + 15*time.Second}}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	stop := make(chan struct{})
+	go func() {
+		<-sig
+		close(stop)
+	}()
+	fmt.Fprintln(stderr, "Waiting for Telegram messages (Ctrl-C to stop)")
+	s.pollTelegram(bot, chats, stop)
+	return 0
+}
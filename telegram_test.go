@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// telegramServer fakes the Bot API: getUpdates returns updates once, photos
+// are served from /file, and replies are recorded.
+func telegramServer(t *testing.T, updates []telegramUpdate, replies chan<- string) *httptest.Server {
+	t.Helper()
+	served := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/botTOKEN") {
+			t.Errorf("request without the bot token: %s", r.URL.Path)
+		}
+		r.ParseForm()
+		var result any
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			result = []telegramUpdate{}
+			if !served {
+				result, served = updates, true
+			}
+		case strings.HasSuffix(r.URL.Path, "/getFile"):
+			result = map[string]string{"file_path": "photos/" + r.Form.Get("file_id") + ".jpg"}
+		case strings.HasPrefix(r.URL.Path, "/file/"):
+			w.Write([]byte("JPEG:" + r.URL.Path))
+			return
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			replies <- r.Form.Get("chat_id") + ": " + r.Form.Get("text")
+			result = map[string]any{}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func telegramMsg(id, chat int64, text string, photos ...string) telegramUpdate {
+	m := &telegramMessage{MessageID: id, Text: text}
+	m.Chat.ID = chat
+	for _, p := range photos {
+		m.Photo = append(m.Photo, struct {
+			FileID string `json:"file_id"`
+		}{p})
+	}
+	if len(photos) > 0 {
+		m.Caption, m.Text = text, ""
+	}
+	return telegramUpdate{UpdateID: id, Message: m}
+}
+
+func TestPollTelegram(t *testing.T) {
+	replies := make(chan string, 10)
+	api := telegramServer(t, []telegramUpdate{
+		telegramMsg(1, 42, "Walked the dog"),
+		telegramMsg(2, 99, "spam"),
+		telegramMsg(3, 42, "Sunset", "small", "large"),
+	}, replies)
+	files := map[string]string{}
+	dropbox := rolloverServer(files)
+	defer dropbox.Close()
+
+	now := time.Date(2025, 1, 15, 19, 0, 0, 0, time.Local)
+	var stderr bytes.Buffer
+	s := &server{
+		client:   &DropboxClient{Token: "tok", BaseURL: dropbox.URL},
+		cfg:      &Config{},
+		clock:    fixedClock(now),
+		queueDir: t.TempDir(),
+		stderr:   &stderr,
+	}
+	bot := &telegramBot{Token: "TOKEN", BaseURL: api.URL, Client: api.Client()}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.pollTelegram(bot, []int64{42}, stop)
+		close(done)
+	}()
+	var got []string
+	for len(got) < 2 {
+		select {
+		case r := <-replies:
+			got = append(got, r)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("replies = %v, want 2", got)
+		}
+	}
+	close(stop)
+	<-done
+
+	note := files["/Notes/Journal/2025/01/Note20250115.md"]
+	if !strings.Contains(note, "Walked the dog") || strings.Contains(note, "spam") {
+		t.Errorf("note = %q, want only the allowed chat's messages", note)
+	}
+	if want := "Sunset\n\n![telegram-20250115-190000.jpg](../../../attachments/telegram-20250115-190000.jpg)"; !strings.Contains(note, want) {
+		t.Errorf("note = %q, want photo entry %q", note, want)
+	}
+	if got := files["/Notes/attachments/telegram-20250115-190000.jpg"]; !strings.HasSuffix(got, "/photos/large.jpg") {
+		t.Errorf("attachment = %q, want the largest photo size", got)
+	}
+	if got[0] != "42: Added to the journal." {
+		t.Errorf("replies = %v", got)
+	}
+	if strings.Contains(stderr.String(), "TOKEN") {
+		t.Errorf("stderr leaks the bot token: %s", stderr.String())
+	}
+}
+
+func TestParseChatIDs(t *testing.T) {
+	ids, err := parseChatIDs("42, -1001234567890,")
+	if err != nil || len(ids) != 2 || ids[0] != 42 || ids[1] != -1001234567890 {
+		t.Errorf("parseChatIDs = %v, %v", ids, err)
+	}
+	if _, err := parseChatIDs("42,abc"); err == nil {
+		t.Error("expected an error for abc")
+	}
+}
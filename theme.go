@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// ThemeConfig styles the status lines on a terminal: a symbol and a color
+// for success and failure, and the text of the "Appended to" line. None of
+// it applies when the output isn't a terminal or with -plain, so logs and
+// scripts keep seeing the plain messages.
+type ThemeConfig struct {
+	OKSymbol   string `json:"ok_symbol,omitempty"`   // e.g. "✓" or "📝"
+	FailSymbol string `json:"fail_symbol,omitempty"` // e.g. "✗"
+	// OKColor and FailColor are one of themeColors, e.g. "green".
+	OKColor   string `json:"ok_color,omitempty"`
+	FailColor string `json:"fail_color,omitempty"`
+	// Appended is a text/template for the line printed after an append,
+	// with .Path (the note written to) and .Count (the number of entries).
+	Appended string `json:"appended,omitempty"`
+}
+
+// themeColors are the color names a theme may use.
+var themeColors = map[string]string{
+	"none":    "",
+	"bold":    ansiBold,
+	"red":     "\x1b[31m",
+	"green":   "\x1b[32m",
+	"yellow":  "\x1b[33m",
+	"blue":    "\x1b[34m",
+	"magenta": "\x1b[35m",
+	"cyan":    "\x1b[36m",
+}
+
+// activeTheme is the theme of the loaded config; nil keeps the plain
+// messages.
+var activeTheme *ThemeConfig
+
+// setTheme selects the theme from the config.
+func setTheme(t *ThemeConfig) {
+	activeTheme = t
+}
+
+// validate checks the color names and the Appended template.
+func (t *ThemeConfig) validate() error {
+	for _, c := range []string{t.OKColor, t.FailColor} {
+		if _, ok := themeColors[c]; c != "" && !ok {
+			return fmt.Errorf("unknown color %q (use none, bold, red, green, yellow, blue, magenta or cyan)", c)
+		}
+	}
+	if t.Appended != "" {
+		if _, err := template.New("appended").Parse(t.Appended); err != nil {
+			return fmt.Errorf("parsing appended template: %w", err)
+		}
+	}
+	return nil
+}
+
+// themed reports whether the theme applies to output written to w.
+func themed(w io.Writer) bool {
+	return activeTheme != nil && !plainOutput && isTerminal(w)
+}
+
+// style prefixes msg with symbol and colors it, keeping a trailing newline
+// outside the color.
+func style(msg, symbol, color string) string {
+	body, nl := strings.CutSuffix(msg, "\n")
+	if code := themeColors[color]; code != "" && colorEnabled() {
+		body = code + body + ansiReset
+	}
+	if symbol != "" {
+		body = symbol + " " + body
+	}
+	if nl {
+		body += "\n"
+	}
+	return body
+}
+
+// printOK writes a success message to w, styled by the theme when w is a
+// terminal and prefixed with OK: in plain mode.
+func printOK(w io.Writer, msg string) {
+	if themed(w) {
+		msg = style(msg, activeTheme.OKSymbol, activeTheme.OKColor)
+	} else {
+		msg = okFormat(msg)
+	}
+	fmt.Fprint(w, msg)
+}
+
+// appendedMessage returns the line reporting count entries appended to
+// path, from the theme's template when it applies to w.
+func appendedMessage(w io.Writer, path string, count int) string {
+	if themed(w) && activeTheme.Appended != "" {
+		if t, err := template.New("appended").Parse(activeTheme.Appended); err == nil {
+			var buf bytes.Buffer
+			data := struct {
+				Path  string
+				Count int
+			}{path, count}
+			if t.Execute(&buf, data) == nil {
+				return strings.TrimRight(buf.String(), "\n") + "\n"
+			}
+		}
+	}
+	if count > 1 {
+		return fmt.Sprintf("Appended %d entries to %s\n", count, path)
+	}
+	return fmt.Sprintf(tr("Appended to %s\n"), path)
+}
+
+// themeWriter styles error lines written through it with the theme's
+// failure symbol and color. The theme is looked up on each write, since
+// it is only known once a subcommand has loaded the config.
+type themeWriter struct {
+	w         io.Writer
+	lineStart bool
+}
+
+func newThemeWriter(w io.Writer) *themeWriter {
+	return &themeWriter{w: w, lineStart: true}
+}
+
+func (t *themeWriter) Write(b []byte) (int, error) {
+	if activeTheme == nil || plainOutput {
+		return t.w.Write(b)
+	}
+	var out bytes.Buffer
+	for rest := b; len(rest) > 0; {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+		if t.lineStart && isErrorLine(line) {
+			out.WriteString(style(string(line), activeTheme.FailSymbol, activeTheme.FailColor))
+		} else {
+			out.Write(line)
+		}
+		t.lineStart = line[len(line)-1] == '\n'
+	}
+	if _, err := t.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// isErrorLine reports whether line is an error message.
+func isErrorLine(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("error")) || bytes.HasPrefix(line, []byte("Error"))
+}
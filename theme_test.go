@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStyle(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if got := style("Appended to /a.md\n", "✓", "green"); got != "✓ \x1b[32mAppended to /a.md\x1b[0m\n" {
+		t.Errorf("got %q", got)
+	}
+	t.Setenv("NO_COLOR", "1")
+	if got := style("Appended to /a.md\n", "✓", "green"); got != "✓ Appended to /a.md\n" {
+		t.Errorf("NO_COLOR: got %q", got)
+	}
+}
+
+func TestThemeValidate(t *testing.T) {
+	if err := (&ThemeConfig{OKColor: "green", Appended: "{{.Count}} → {{.Path}}"}).validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (&ThemeConfig{FailColor: "chartreuse"}).validate(); err == nil {
+		t.Error("expected an error for an unknown color")
+	}
+	if err := (&ThemeConfig{Appended: "{{.Path"}).validate(); err == nil {
+		t.Error("expected an error for a broken template")
+	}
+}
+
+func TestAppendedMessage_NotATerminal(t *testing.T) {
+	setTheme(&ThemeConfig{OKSymbol: "✓", Appended: "saved {{.Path}}"})
+	defer setTheme(nil)
+
+	// A buffer isn't a terminal, so the theme doesn't apply.
+	var buf bytes.Buffer
+	printOK(&buf, appendedMessage(&buf, "/a.md", 1))
+	printOK(&buf, appendedMessage(&buf, "/a.md", 3))
+	if want := "Appended to /a.md\nAppended 3 entries to /a.md\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestThemeWriter(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	var buf bytes.Buffer
+	w := newThemeWriter(&buf)
+
+	w.Write([]byte("error: before the config\n"))
+	setTheme(&ThemeConfig{FailSymbol: "✗", FailColor: "red"})
+	defer setTheme(nil)
+	w.Write([]byte("warning: w\nerror: "))
+	w.Write([]byte("split\n"))
+
+	if want := "error: before the config\nwarning: w\n✗ error: split\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// timingTransport reports how long each request took on w, and where the
+// time went: a new connection shows its DNS, connect and TLS handshake
+// times, a reused one the setup time it saved, estimated from the new
+// connections seen so far.
+type timingTransport struct {
+	base http.RoundTripper
+	w    io.Writer
+
+	mu       sync.Mutex
+	setup    time.Duration // total setup time of new connections
+	newConns int
+}
+
+// connTiming collects the httptrace events of one request.
+type connTiming struct {
+	reused                 bool
+	resumed                bool
+	dnsStart, connectStart time.Time
+	tlsStart               time.Time
+	dns, connect, tls      time.Duration
+}
+
+func (c *connTiming) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn:           func(info httptrace.GotConnInfo) { c.reused = info.Reused },
+		DNSStart:          func(httptrace.DNSStartInfo) { c.dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { c.dns = time.Since(c.dnsStart) },
+		ConnectStart:      func(string, string) { c.connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { c.connect = time.Since(c.connectStart) },
+		TLSHandshakeStart: func() { c.tlsStart = time.Now() },
+		TLSHandshakeDone: func(state tls.ConnectionState, _ error) {
+			c.tls = time.Since(c.tlsStart)
+			c.resumed = state.DidResume
+		},
+	}
+}
+
+func (t *timingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var c connTiming
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), c.trace()))
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	status := "error"
+	if err == nil {
+		status = fmt.Sprint(resp.StatusCode)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var detail string
+	switch {
+	case c.reused && t.newConns > 0:
+		detail = fmt.Sprintf("reused connection, saved ~%v", ms(t.setup/time.Duration(t.newConns)))
+	case c.reused:
+		detail = "reused connection"
+	default:
+		setup := c.dns + c.connect + c.tls
+		t.setup += setup
+		t.newConns++
+		detail = fmt.Sprintf("new connection: dns %v, connect %v, tls %v", ms(c.dns), ms(c.connect), ms(c.tls))
+		if c.resumed {
+			detail += " (resumed)"
+		}
+	}
+	fmt.Fprintf(t.w, "http: %s %s%s %s in %v (%s)\n", req.Method, req.URL.Host, req.URL.Path, status, ms(elapsed), detail)
+	return resp, err
+}
+
+// ms rounds d for display.
+func ms(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
+
+// enableTiming reports the timing of every Dropbox and OAuth request on w.
+func enableTiming(w io.Writer) {
+	wrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		return &timingTransport{base: base, w: w}
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTimingTransport(t *testing.T) {
+	var conns int32
+	srv := tlsServer(t, &conns)
+	tr := newSharedTransport()
+	trustServer(tr, srv)
+
+	var log bytes.Buffer
+	client := &http.Client{Transport: &timingTransport{base: tr, w: &log}}
+	get(t, client, srv.URL+"/2/files/download")
+	get(t, client, srv.URL+"/2/files/upload")
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines:\n%s", len(lines), log.String())
+	}
+	if !strings.Contains(lines[0], "/2/files/download 200") || !strings.Contains(lines[0], "new connection: dns") {
+		t.Errorf("first line = %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "/2/files/upload 200") || !strings.Contains(lines[1], "reused connection, saved ~") {
+		t.Errorf("second line = %q", lines[1])
+	}
+}
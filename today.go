@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultSummaryTTL is how long `today -summary` trusts its cached result.
+const defaultSummaryTTL = 5 * time.Minute
+
+// entryHeaderPattern matches the ### HH:MM:SS header written by formatEntry,
+// optionally numbered (### 3. HH:MM:SS) and attributed (### HH:MM:SS — alice).
+// Group 1 is the number, group 2 the time, group 3 the author.
+var entryHeaderPattern = regexp.MustCompile(`(?m)^### (?:(\d+)\. )?(\d{2}:\d{2}(?::\d{2})?)(?: — (\S.*?))?\s*$`)
+
+// todaySummary is the cached result of summarising a note.
+type todaySummary struct {
+	Path      string    `json:"path"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Entries   int       `json:"entries"`
+	Last      string    `json:"last,omitempty"`
+}
+
+// defaultCacheDir returns the directory for local caches, e.g.
+// ~/.cache/dropbox-appender.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "dropbox-appender")
+}
+
+// todayCachePath returns the file holding the cached today summary.
+func todayCachePath() string {
+	return filepath.Join(defaultCacheDir(), "today.json")
+}
+
+// summarizeNote counts the timestamped entries in content and returns the
+// time of the last one.
+func summarizeNote(content string) (int, string) {
+	matches := entryHeaderPattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return 0, ""
+	}
+	return len(matches), matches[len(matches)-1][2]
+}
+
+// formatSummary renders a one-line summary suitable for a shell prompt.
+func formatSummary(s todaySummary) string {
+	switch s.Entries {
+	case 0:
+		return "no entries today"
+	case 1:
+		return fmt.Sprintf("1 entry, last %s", s.Last)
+	default:
+		return fmt.Sprintf("%d entries, last %s", s.Entries, s.Last)
+	}
+}
+
+// readSummaryCache returns the cached summary for path if it is younger than
+// ttl.
+func readSummaryCache(cachePath, path string, ttl time.Duration, now time.Time) (todaySummary, bool) {
+	var s todaySummary
+	data, err := os.ReadFile(cachePath)
+	if err != nil || json.Unmarshal(data, &s) != nil {
+		return s, false
+	}
+	if s.Path != path || now.Sub(s.FetchedAt) > ttl || now.Before(s.FetchedAt) {
+		return s, false
+	}
+	return s, true
+}
+
+// writeSummaryCache stores s for later runs. Failures only cost a refetch,
+// so they are ignored.
+func writeSummaryCache(cachePath string, s todaySummary) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(cachePath), 0700)
+	writeFileAtomic(cachePath, data, 0600)
+}
+
+// invalidateTodayCache drops the summary cached at cachePath after this tool
+// writes to the journal, so the prompt reflects the new entry straight away.
+// An empty cachePath does nothing.
+func invalidateTodayCache(cachePath string) {
+	if cachePath != "" {
+		os.Remove(cachePath)
+	}
+}
+
+// runToday implements the `dropbox-appender today` subcommand: it prints
+// today's note, or with -summary a one-line summary for shell prompts and
+// status bars. It returns the process exit code.
+func runToday(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("today", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	summary := fs.Bool("summary", false, "print a one-line summary (entry count, last entry time)")
+	ttl := fs.Duration("ttl", defaultSummaryTTL, "how long a cached summary is reused")
+	render := fs.Bool("render", false, "highlight Markdown for the terminal")
+	noPager := fs.Bool("no-pager", false, "don't page output on a terminal")
+	tail := fs.Int("tail", 0, "print only the last N lines, fetching just the end of the note")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	now := clock.Now()
+	path, err := journalPath(cfg, "", now)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	// A fresh cached summary needs no token, keeping prompt rendering fast
+	// and offline-friendly.
+	if *summary {
+		if s, ok := readSummaryCache(todayCachePath(), path, *ttl, now); ok {
+			fmt.Fprintln(stdout, formatSummary(s))
+			return 0
+		}
+	}
+
+	client, err := connectClient(cfg, false)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+
+	if *summary {
+		return runTodayWithClient(stdout, stderr, client, path, true, todayCachePath(), now)
+	}
+	var note strings.Builder
+	var code int
+	if *tail > 0 {
+		code = runTodayTail(&note, stderr, client, path, *tail)
+	} else {
+		code = runTodayWithClient(&note, stderr, client, path, false, todayCachePath(), now)
+	}
+	pageOutput(stdout, note.String(), *render, *noPager)
+	return code
+}
+
+// runTodayTail prints the last n lines of the note at path.
+func runTodayTail(stdout, stderr io.Writer, client *DropboxClient, path string, n int) int {
+	tail, err := tailLines(client, path, n)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading journal: %v\n", err)
+		return 1
+	}
+	fmt.Fprint(stdout, tail)
+	return 0
+}
+
+// showNoteTail prints the last n lines of the note at path as read back
+// from Dropbox, so a just-appended entry can be checked. Failing to read
+// them back only warns: the append itself succeeded.
+func showNoteTail(stdout, stderr io.Writer, client *DropboxClient, path string, n int) {
+	tail, err := tailLines(client, path, n)
+	if err != nil {
+		fmt.Fprintf(stderr, "warning: reading back %s: %v\n", path, err)
+		return
+	}
+	fmt.Fprint(stdout, tail)
+}
+
+// runTodayWithClient is the testable core of the today subcommand. The
+// summary is written to cachePath for later runs.
+func runTodayWithClient(stdout, stderr io.Writer, client *DropboxClient,
+	path string, summary bool, cachePath string, now time.Time) int {
+
+	content, err := client.Download(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: downloading journal: %v\n", err)
+		return 1
+	}
+
+	if !summary {
+		fmt.Fprint(stdout, content)
+		return 0
+	}
+
+	s := todaySummary{Path: path, FetchedAt: now}
+	s.Entries, s.Last = summarizeNote(content)
+	writeSummaryCache(cachePath, s)
+	fmt.Fprintln(stdout, formatSummary(s))
+	return 0
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeNote(t *testing.T) {
+	content := "### 09:00:00\nmorning\n\n### 14:30:45\nafternoon\n#### not a header\n"
+	n, last := summarizeNote(content)
+	if n != 2 || last != "14:30:45" {
+		t.Errorf("got %d, %q; want 2, 14:30:45", n, last)
+	}
+	if n, last := summarizeNote(""); n != 0 || last != "" {
+		t.Errorf("empty note: got %d, %q", n, last)
+	}
+}
+
+func TestFormatSummary(t *testing.T) {
+	cases := map[int]string{
+		0: "no entries today",
+		1: "1 entry, last 09:00:00",
+		3: "3 entries, last 09:00:00",
+	}
+	for n, want := range cases {
+		if got := formatSummary(todaySummary{Entries: n, Last: "09:00:00"}); got != want {
+			t.Errorf("formatSummary(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSummaryCache(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "today.json")
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	writeSummaryCache(cachePath, todaySummary{Path: "/a.md", FetchedAt: now, Entries: 2, Last: "14:00:00"})
+
+	if s, ok := readSummaryCache(cachePath, "/a.md", time.Minute, now.Add(30*time.Second)); !ok || s.Entries != 2 {
+		t.Errorf("expected fresh cache hit, got %+v, %v", s, ok)
+	}
+	if _, ok := readSummaryCache(cachePath, "/a.md", time.Minute, now.Add(2*time.Minute)); ok {
+		t.Error("expected expired cache to miss")
+	}
+	if _, ok := readSummaryCache(cachePath, "/b.md", time.Minute, now); ok {
+		t.Error("expected cache for another day's note to miss")
+	}
+}
+
+func TestAppendToJournal_InvalidatesCache(t *testing.T) {
+	server := rolloverServer(map[string]string{})
+	defer server.Close()
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	cachePath := filepath.Join(t.TempDir(), "today.json")
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	writeSummaryCache(cachePath, todaySummary{Path: "/a.md", FetchedAt: now, Entries: 2})
+
+	if _, err := appendToJournal(client, "/a.md", "### 14:30:00\nnew\n", appendOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := readSummaryCache(cachePath, "/a.md", time.Minute, now); !ok {
+		t.Error("a cache not in the options should be left alone")
+	}
+	if _, err := appendToJournal(client, "/a.md", "### 14:31:00\nnewer\n", appendOptions{CachePath: cachePath}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := readSummaryCache(cachePath, "/a.md", time.Minute, now); ok {
+		t.Error("expected the cache to be dropped after appending")
+	}
+}
+
+func TestRunTodayWithClient_Summary(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("### 09:00:00\nmorning\n\n### 11:15:00\nlater\n"))
+	}))
+	defer server.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "today.json")
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+	var stdout bytes.Buffer
+	code := runTodayWithClient(&stdout, io.Discard,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		"/Notes/Journal/2025/01/Note20250115.md", true, cachePath, now)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if strings.TrimSpace(stdout.String()) != "2 entries, last 11:15:00" {
+		t.Errorf("unexpected summary: %q", stdout.String())
+	}
+	if _, ok := readSummaryCache(cachePath, "/Notes/Journal/2025/01/Note20250115.md", time.Minute, now); !ok {
+		t.Error("expected summary to be cached")
+	}
+}
+
+func TestRunTodayWithClient_PrintsNote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("### 09:00:00\nmorning\n"))
+	}))
+	defer server.Close()
+
+	var stdout bytes.Buffer
+	runTodayWithClient(&stdout, io.Discard,
+		&DropboxClient{Token: "test-token", BaseURL: server.URL},
+		"/a.md", false, filepath.Join(t.TempDir(), "today.json"), time.Now())
+	if stdout.String() != "### 09:00:00\nmorning\n" {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// sharedTransport carries every Dropbox and OAuth request. One transport per
+// process means the token refresh, metadata, download and upload calls of a
+// run share a single keep-alive (HTTP/2 where offered) connection per host
+// instead of each paying for a TCP and TLS handshake.
+var sharedTransport = newSharedTransport()
+
+// httpClient sends every Dropbox and OAuth request. -debug-http and -verbose
+// wrap its transport; sharedTransport is always at the bottom, under the
+// -bwlimit rate limit.
+var httpClient = &http.Client{Transport: bwLimitTransport{sharedTransport}}
+
+func newSharedTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ForceAttemptHTTP2 = true
+	t.MaxIdleConnsPerHost = 4
+	t.IdleConnTimeout = 90 * time.Second
+	t.TLSClientConfig = &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(16)}
+	return t
+}
+
+// wrapTransport layers a RoundTripper such as the -debug-http logger on top
+// of httpClient's current transport.
+func wrapTransport(wrap func(base http.RoundTripper) http.RoundTripper) {
+	httpClient.Transport = wrap(httpClient.Transport)
+}
+
+// configureHTTP applies the HTTP settings in cfg to sharedTransport. It runs
+// from loadConfig, before any request is made, so the OAuth token exchange
+// is covered too.
+func configureHTTP(cfg *Config) error {
+	if err := applyClientCert(cfg); err != nil {
+		return err
+	}
+	if cfg.TLSSessionCache {
+		sharedTransport.TLSClientConfig.ClientSessionCache = newFileSessionCache(tlsSessionCachePath())
+	}
+	return configureBandwidth(cfg)
+}
+
+// tlsSessionCachePath returns the file persisting TLS session tickets.
+func tlsSessionCachePath() string {
+	return filepath.Join(defaultCacheDir(), "tls-sessions.json")
+}
+
+// fileSessionCache is a tls.ClientSessionCache that also keeps session
+// tickets in a file, so the next run can resume the TLS session (one round
+// trip fewer) instead of doing a full handshake. The file holds resumption
+// secrets and is written 0600.
+type fileSessionCache struct {
+	path string
+	mu   sync.Mutex
+	mem  tls.ClientSessionCache
+}
+
+// savedSession is a serialized tls.ClientSessionState.
+type savedSession struct {
+	Ticket []byte `json:"ticket"`
+	State  []byte `json:"state"`
+}
+
+func newFileSessionCache(path string) *fileSessionCache {
+	return &fileSessionCache{path: path, mem: tls.NewLRUClientSessionCache(16)}
+}
+
+func (c *fileSessionCache) load() map[string]savedSession {
+	sessions := map[string]savedSession{}
+	if data, err := os.ReadFile(c.path); err == nil {
+		json.Unmarshal(data, &sessions)
+	}
+	return sessions
+}
+
+func (c *fileSessionCache) Get(key string) (*tls.ClientSessionState, bool) {
+	if cs, ok := c.mem.Get(key); ok && cs != nil {
+		return cs, true
+	}
+	c.mu.Lock()
+	saved, ok := c.load()[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	state, err := tls.ParseSessionState(saved.State)
+	if err != nil {
+		return nil, false
+	}
+	cs, err := tls.NewResumptionState(saved.Ticket, state)
+	if err != nil {
+		return nil, false
+	}
+	c.mem.Put(key, cs)
+	return cs, true
+}
+
+// Put stores cs, or forgets key when cs is nil. Failing to persist a ticket
+// only costs a full handshake next run, so errors are ignored.
+func (c *fileSessionCache) Put(key string, cs *tls.ClientSessionState) {
+	c.mem.Put(key, cs)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sessions := c.load()
+	if cs == nil {
+		delete(sessions, key)
+	} else {
+		ticket, state, err := cs.ResumptionState()
+		if err != nil || state == nil {
+			return
+		}
+		stateBytes, err := state.Bytes()
+		if err != nil {
+			return
+		}
+		sessions[key] = savedSession{Ticket: ticket, State: stateBytes}
+	}
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(c.path), 0700) == nil {
+		writeFileAtomic(c.path, data, 0600)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// tlsServer starts a TLS test server counting the connections it accepts.
+func tlsServer(t *testing.T, conns *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(conns, 1)
+		}
+	}
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// trustServer makes tr trust srv's certificate.
+func trustServer(tr *http.Transport, srv *httptest.Server) {
+	tr.TLSClientConfig.RootCAs = srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+}
+
+func get(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func TestSharedTransport_ReusesConnection(t *testing.T) {
+	var conns int32
+	srv := tlsServer(t, &conns)
+	tr := newSharedTransport()
+	trustServer(tr, srv)
+	client := &http.Client{Transport: tr}
+
+	var proto string
+	for i := 0; i < 3; i++ {
+		proto = get(t, client, srv.URL).Proto
+	}
+	if conns != 1 {
+		t.Errorf("opened %d connections for 3 requests, want 1", conns)
+	}
+	if proto != "HTTP/2.0" {
+		t.Errorf("protocol = %s, want HTTP/2.0", proto)
+	}
+}
+
+func TestFileSessionCache_ResumesAcrossRuns(t *testing.T) {
+	var conns int32
+	srv := tlsServer(t, &conns)
+	path := filepath.Join(t.TempDir(), "tls-sessions.json")
+
+	// Each "run" gets a fresh transport and cache, sharing only the file.
+	run := func() bool {
+		tr := newSharedTransport()
+		trustServer(tr, srv)
+		tr.TLSClientConfig.ClientSessionCache = newFileSessionCache(path)
+		resp := get(t, &http.Client{Transport: tr}, srv.URL)
+		tr.CloseIdleConnections()
+		return resp.TLS.DidResume
+	}
+	if run() {
+		t.Error("first run resumed a session it never had")
+	}
+	if !run() {
+		t.Error("second run did not resume the saved TLS session")
+	}
+}
+
+func TestFileSessionCache_Forget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tls-sessions.json")
+	c := newFileSessionCache(path)
+	c.Put("example.com:443", nil)
+	if _, ok := c.Get("example.com:443"); ok {
+		t.Error("Get after deleting should miss")
+	}
+	if _, ok := newFileSessionCache(path).Get("missing"); ok {
+		t.Error("Get of an unknown key should miss")
+	}
+	var _ tls.ClientSessionCache = c
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// noteHistory is what files/list_revisions says about a path: whether the
+// file there is deleted, when, and its revisions, newest first.
+type noteHistory struct {
+	IsDeleted     bool           `json:"is_deleted"`
+	ServerDeleted time.Time      `json:"server_deleted"`
+	Entries       []fileRevision `json:"entries"`
+}
+
+// History returns the deletion state and latest revision of path. A path
+// that never held a file is ErrNotFound.
+func (c *DropboxClient) History(path string) (noteHistory, error) {
+	body, err := c.rpc("/2/files/list_revisions", map[string]interface{}{
+		"path":  path,
+		"mode":  "path",
+		"limit": 1,
+	})
+	if err != nil {
+		return noteHistory{}, err
+	}
+	var h noteHistory
+	if err := json.Unmarshal(body, &h); err != nil {
+		return noteHistory{}, fmt.Errorf("parsing list_revisions response: %w", err)
+	}
+	return h, nil
+}
+
+// runRm implements `dropbox-appender rm`, which deletes a day's note after
+// confirmation. Dropbox keeps deleted files for 30 days, so `recover` can
+// bring it back. It returns the process exit code.
+func runRm(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	client, p, yes, code := trashArgs("rm", args, stderr, clock)
+	if client == nil {
+		return code
+	}
+	return runRmWithClient(bufio.NewScanner(stdin), stdout, stderr, client, p, yes)
+}
+
+// runRecover implements `dropbox-appender recover`, which restores a deleted
+// note to its last revision. It returns the process exit code.
+func runRecover(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	client, p, yes, code := trashArgs("recover", args, stderr, clock)
+	if client == nil {
+		return code
+	}
+	return runRecoverWithClient(bufio.NewScanner(stdin), stdout, stderr, client, p, yes)
+}
+
+// trashArgs parses the flags shared by rm and recover and resolves the
+// note's path. On failure client is nil and code is the exit code.
+func trashArgs(name string, args []string, stderr io.Writer, clock Clock) (client *DropboxClient, p string, yes bool, code int) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	date := fs.String("date", "", "use the note for this date (YYYY-MM-DD) instead of today")
+	notePath := fs.String("path", "", "use this Dropbox path instead of a dated note")
+	yesFlag := fs.Bool("yes", false, "don't ask for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return nil, "", false, 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected argument %q\n", fs.Arg(0))
+		return nil, "", false, 2
+	}
+	now := clock.Now()
+	if *date != "" {
+		d, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -date %q: expected YYYY-MM-DD\n", *date)
+			return nil, "", false, 2
+		}
+		now = d
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return nil, "", false, 1
+	}
+	p = *notePath
+	if p == "" {
+		if p, err = journalPath(cfg, "", now); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return nil, "", false, 1
+		}
+	}
+	client, err = connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return nil, "", false, 1
+	}
+	return client, p, *yesFlag, 0
+}
+
+// confirm asks question on stdout and reports whether the answer was yes.
+func confirm(in *bufio.Scanner, stdout io.Writer, question string) bool {
+	fmt.Fprint(stdout, question+" [y/N] ")
+	in.Scan()
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(in.Text())), "y") {
+		return true
+	}
+	fmt.Fprintln(stdout, tr("Aborted."))
+	return false
+}
+
+// runRmWithClient deletes notePath after confirming with the user (unless
+// yes is set).
+func runRmWithClient(in *bufio.Scanner, stdout, stderr io.Writer, client *DropboxClient, notePath string, yes bool) int {
+	h, err := client.History(notePath)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(stderr, "error: looking up %s: %v\n", notePath, err)
+		return 1
+	}
+	if err != nil || h.IsDeleted || len(h.Entries) == 0 {
+		fmt.Fprintf(stderr, "error: there is no note at %s\n", notePath)
+		return 1
+	}
+	cur := h.Entries[0]
+	when := cur.ServerModified.Local().Format("2006-01-02 15:04:05")
+	if !yes && !confirm(in, stdout, fmt.Sprintf("Delete %s (%d bytes, last changed %s)?", notePath, cur.Size, when)) {
+		return 1
+	}
+	if _, err := client.rpc("/2/files/delete_v2", map[string]string{"path": notePath}); err != nil {
+		fmt.Fprintf(stderr, "error: deleting %s: %v\n", notePath, err)
+		return 1
+	}
+	invalidateTodayCache(todayCachePath())
+	fmt.Fprintf(stdout, "Deleted %s (`dropbox-appender recover` brings it back within 30 days)\n", notePath)
+	return 0
+}
+
+// runRecoverWithClient restores the deleted notePath to its last revision
+// after confirming with the user (unless yes is set). A note that still
+// exists is left alone; `revisions restore` handles those.
+func runRecoverWithClient(in *bufio.Scanner, stdout, stderr io.Writer, client *DropboxClient, notePath string, yes bool) int {
+	h, err := client.History(notePath)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(stderr, "error: looking up %s: %v\n", notePath, err)
+		return 1
+	}
+	if err != nil || len(h.Entries) == 0 {
+		fmt.Fprintf(stderr, "error: there is no deleted note at %s\n", notePath)
+		return 1
+	}
+	if !h.IsDeleted {
+		fmt.Fprintf(stderr, "error: %s is not deleted; use `dropbox-appender revisions` to go back to an earlier version\n", notePath)
+		return 1
+	}
+	last := h.Entries[0]
+	deleted := h.ServerDeleted.Local().Format("2006-01-02 15:04:05")
+	if !yes && !confirm(in, stdout, fmt.Sprintf("Recover %s (%d bytes, deleted %s)?", notePath, last.Size, deleted)) {
+		return 1
+	}
+	if _, err := client.Restore(notePath, last.Rev); err != nil {
+		fmt.Fprintf(stderr, "error: recovering %s: %v\n", notePath, err)
+		return 1
+	}
+	invalidateTodayCache(todayCachePath())
+	fmt.Fprintf(stdout, "Recovered %s (%d bytes)\n", notePath, last.Size)
+	return 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// trashServer fakes a single note that can be deleted and restored. state
+// is "exists", "deleted" or "missing" (never existed).
+func trashServer(t *testing.T, state *string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&arg)
+		if arg["path"] != "/Journal/2025-01-15.md" {
+			t.Errorf("%s path = %v", r.URL.Path, arg["path"])
+		}
+		switch r.URL.Path {
+		case "/2/files/list_revisions":
+			switch *state {
+			case "missing":
+				w.WriteHeader(409)
+				io.WriteString(w, `{"error_summary": "path/not_found/"}`)
+			case "deleted":
+				io.WriteString(w, `{"is_deleted":true,"server_deleted":"2025-01-16T08:00:00Z",
+					"entries":[{"rev":"a2","size":200,"server_modified":"2025-01-15T12:00:00Z"}]}`)
+			default:
+				io.WriteString(w, `{"is_deleted":false,
+					"entries":[{"rev":"a2","size":200,"server_modified":"2025-01-15T12:00:00Z"}]}`)
+			}
+		case "/2/files/delete_v2":
+			*state = "deleted"
+			io.WriteString(w, `{"metadata":{}}`)
+		case "/2/files/restore":
+			if arg["rev"] != "a2" {
+				t.Errorf("restore rev = %v, want a2", arg["rev"])
+			}
+			*state = "exists"
+			io.WriteString(w, `{"rev":"a3"}`)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunRm(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     string
+		answer    string
+		yes       bool
+		wantCode  int
+		wantState string
+	}{
+		{"confirmed", "exists", "y\n", false, 0, "deleted"},
+		{"declined", "exists", "n\n", false, 1, "exists"},
+		{"yes flag", "exists", "", true, 0, "deleted"},
+		{"already deleted", "deleted", "y\n", false, 1, "deleted"},
+		{"missing", "missing", "y\n", false, 1, "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			client := &DropboxClient{Token: "tok", BaseURL: trashServer(t, &state).URL}
+			var stdout, stderr bytes.Buffer
+			in := bufio.NewScanner(strings.NewReader(tt.answer))
+			code := runRmWithClient(in, &stdout, &stderr, client, "/Journal/2025-01-15.md", tt.yes)
+			if code != tt.wantCode {
+				t.Fatalf("exit %d, want %d; stdout: %s stderr: %s", code, tt.wantCode, stdout.String(), stderr.String())
+			}
+			if state != tt.wantState {
+				t.Errorf("state = %q, want %q", state, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestRunRecover(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     string
+		answer    string
+		yes       bool
+		wantCode  int
+		wantState string
+		wantErr   string
+	}{
+		{"confirmed", "deleted", "y\n", false, 0, "exists", ""},
+		{"declined", "deleted", "\n", false, 1, "deleted", ""},
+		{"yes flag", "deleted", "", true, 0, "exists", ""},
+		{"not deleted", "exists", "y\n", false, 1, "exists", "revisions"},
+		{"missing", "missing", "y\n", false, 1, "missing", "no deleted note"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			client := &DropboxClient{Token: "tok", BaseURL: trashServer(t, &state).URL}
+			var stdout, stderr bytes.Buffer
+			in := bufio.NewScanner(strings.NewReader(tt.answer))
+			code := runRecoverWithClient(in, &stdout, &stderr, client, "/Journal/2025-01-15.md", tt.yes)
+			if code != tt.wantCode {
+				t.Fatalf("exit %d, want %d; stdout: %s stderr: %s", code, tt.wantCode, stdout.String(), stderr.String())
+			}
+			if state != tt.wantState {
+				t.Errorf("state = %q, want %q", state, tt.wantState)
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("stderr = %q, want it to mention %q", stderr.String(), tt.wantErr)
+			}
+		})
+	}
+}
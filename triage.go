@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// defaultTriageDays is how many days of flagged entries, up to today,
+// triage looks through.
+const defaultTriageDays = 14
+
+// flagMarker starts the text of entries appended with -flag, so they stand
+// out in the note as needing follow-up.
+const flagMarker = "🚩 "
+
+// withFlag marks entry text as needing follow-up.
+func withFlag(text string) string {
+	return flagMarker + text
+}
+
+// entryFlagged reports whether an entry's text carries the flag marker.
+func entryFlagged(text string) bool {
+	return strings.HasPrefix(text, flagMarker)
+}
+
+// findFlagged returns the flagged entries.
+func findFlagged(entries []indexEntry) []indexEntry {
+	var flagged []indexEntry
+	for _, e := range entries {
+		if entryFlagged(e.Text) {
+			flagged = append(flagged, e)
+		}
+	}
+	return flagged
+}
+
+// unflagText removes the flag marker from entry text.
+func unflagText(text string) string {
+	return strings.TrimPrefix(text, flagMarker)
+}
+
+// flagToTask turns a flagged entry's first line into an open task item,
+// keeping the rest of the text below it.
+func flagToTask(text string) string {
+	return "- [ ] " + unflagText(text)
+}
+
+// editEntry applies edit to the text of entry e in its remote note and
+// updates the index and mirrors as opts configures (see wroteNote). The
+// entry is found by time and text, so an entry changed since it was indexed
+// isn't touched. The upload is rev-protected.
+func editEntry(client *DropboxClient, e indexEntry, opts appendOptions, edit func(string) string) error {
+	content, rev, err := client.DownloadRev(e.Path)
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", e.Path, err)
+	}
+	doc := parseJournal(content)
+	var block *journalBlock
+	for _, b := range doc.Blocks {
+		if b.Time == e.Time && b.Text == e.Text {
+			block = b
+			break
+		}
+	}
+	if block == nil {
+		return fmt.Errorf("the %s entry of %s has changed; run: dropbox-appender reindex", e.Time, e.Path)
+	}
+	block.setText(edit(block.Text))
+	updated := doc.String()
+	newRev, err := client.UploadRev(e.Path, updated, rev)
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", e.Path, err)
+	}
+	wroteNote(client, e.Path, e.Path, updated, updated, newRev, opts)
+	return nil
+}
+
+// runTriage implements the `dropbox-appender triage` subcommand, which goes
+// through the entries appended with -flag and offers to unflag them or turn
+// them into tasks. It returns the process exit code.
+func runTriage(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("triage", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	days := fs.Int("days", defaultTriageDays, "look through the last N days when -from isn't set")
+	list := fs.Bool("list", false, "only list the flagged entries")
+	dates := addDateFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *days < 1 {
+		fmt.Fprintln(stderr, "-days must be at least 1")
+		return 2
+	}
+	if dates.From == "" {
+		dates.From = clock.Now().AddDate(0, 0, 1-*days).Format("2006-01-02")
+	}
+
+	entries, ok := loadIndexedEntries(stderr)
+	if !ok {
+		return 1
+	}
+	flagged := findFlagged(searchEntries(entries, nil, entryFilter{Dates: *dates}))
+	if len(flagged) == 0 {
+		fmt.Fprintln(stdout, "No flagged entries.")
+		return 0
+	}
+	if *list {
+		printFlagged(stdout, flagged)
+		return 0
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if refuseReadOnly(stderr, "triage") {
+		return 1
+	}
+	client, err := connectClient(cfg, true)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	opts, err := appendOptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	opts.Clock = clock
+	return runTriageWithClient(stdin, stdout, stderr, client, flagged, opts)
+}
+
+// printFlagged lists flagged entries with the first line of their text.
+func printFlagged(w io.Writer, flagged []indexEntry) {
+	for i, e := range flagged {
+		fmt.Fprintf(w, "%3d  %s %s  %s\n", i+1, e.Date, e.Time, firstLine(unflagText(e.Text)))
+	}
+}
+
+// runTriageWithClient asks what to do with each flagged entry, reading one
+// answer per line from stdin: u to unflag it, t to turn it into a task, s
+// or an empty line to leave it flagged, q to stop. Entries that can't be
+// changed are reported and skipped.
+func runTriageWithClient(stdin io.Reader, stdout, stderr io.Writer, client *DropboxClient,
+	flagged []indexEntry, opts appendOptions) int {
+
+	scanner := bufio.NewScanner(stdin)
+	failed := false
+	for i := 0; i < len(flagged); i++ {
+		e := flagged[i]
+		fmt.Fprintf(stdout, "\n[%d/%d] %s %s\n%s\n", i+1, len(flagged), e.Date, e.Time, unflagText(e.Text))
+		fmt.Fprint(stdout, "(u)nflag, make a (t)ask, (s)kip, (q)uit? ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			break
+		}
+		var edit func(string) string
+		var done string
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "u":
+			edit, done = unflagText, "Unflagged"
+		case "t":
+			edit, done = flagToTask, "Added a task"
+		case "", "s":
+			continue
+		case "q":
+			return exitCode(failed)
+		default:
+			fmt.Fprintln(stderr, "answer u, t, s or q")
+			i--
+			continue
+		}
+		if err := editEntry(client, e, opts, edit); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(stdout, "%s in %s\n", done, e.Path)
+	}
+	return exitCode(failed)
+}
+
+// exitCode returns 1 when something failed, else 0.
+func exitCode(failed bool) int {
+	if failed {
+		return 1
+	}
+	return 0
+}
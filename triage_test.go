@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindFlagged(t *testing.T) {
+	entries := []indexEntry{
+		{Time: "09:00:00", Text: withFlag("call the bank")},
+		{Time: "10:00:00", Text: "nothing to follow up"},
+		{Time: "11:00:00", Text: "mentions 🚩 later on"},
+	}
+	got := findFlagged(entries)
+	if len(got) != 1 || got[0].Time != "09:00:00" {
+		t.Errorf("flagged = %+v, want the 09:00:00 entry", got)
+	}
+	if got := flagToTask(withFlag("call the bank\nabout the card")); got != "- [ ] call the bank\nabout the card" {
+		t.Errorf("flagToTask = %q", got)
+	}
+}
+
+func TestRunTriageWithClient(t *testing.T) {
+	notePath := "/Journal/2025-01-15.md"
+	files := map[string]string{notePath: "### 09:00:00\n🚩 call the bank\n\n### 10:00:00\nlunch\n\n" +
+		"### 11:00:00\n🚩 renew passport\n\n### 12:00:00\n🚩 book dentist\n"}
+	srv := rolloverServer(files)
+	defer srv.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: srv.URL}
+	setupGitIdentity(t)
+	dir := t.TempDir()
+	opts := appendOptions{
+		PathTemplate: `/Journal/{{.Time.Format "2006-01-02"}}.md`,
+		IndexPath:    filepath.Join(dir, "index.json"),
+		GitMirror:    &GitMirrorConfig{Repo: filepath.Join(dir, "mirror")},
+	}
+	idx := &localIndex{Notes: map[string]*indexedNote{}}
+	idx.update(notePath, files[notePath], "r1", time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC))
+	flagged := findFlagged(idx.entries())
+	if len(flagged) != 3 {
+		t.Fatalf("expected 3 flagged entries, got %d", len(flagged))
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runTriageWithClient(strings.NewReader("u\nwhat\nt\n\n"), &stdout, &stderr, client, flagged, opts)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	want := "### 09:00:00\ncall the bank\n\n### 10:00:00\nlunch\n\n" +
+		"### 11:00:00\n- [ ] renew passport\n\n### 12:00:00\n🚩 book dentist\n"
+	if files[notePath] != want {
+		t.Errorf("note = %q, want %q", files[notePath], want)
+	}
+	if mirrored, _ := os.ReadFile(filepath.Join(dir, "mirror", "Journal", "2025-01-15.md")); string(mirrored) != want {
+		t.Errorf("mirror out of sync: %q", mirrored)
+	}
+	if !strings.Contains(stderr.String(), "answer u, t, s or q") {
+		t.Errorf("expected the bad answer to be reported, got %q", stderr.String())
+	}
+
+	idx, err := loadIndex(opts.IndexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(findFlagged(idx.entries())); n != 1 {
+		t.Errorf("index has %d flagged entries after triage, want 1", n)
+	}
+	if n := len(findTasks(idx.entries(), false)); n != 1 {
+		t.Errorf("index has %d open tasks after triage, want 1", n)
+	}
+
+	// An entry edited since it was indexed is left alone.
+	stale := []indexEntry{{Date: "2025-01-15", Time: "12:00:00", Text: withFlag("book the dentist"), Path: notePath}}
+	before := files[notePath]
+	if code := runTriageWithClient(strings.NewReader("u\n"), &stdout, &stderr, client, stale, opts); code != 1 {
+		t.Errorf("exit %d, want 1 for a changed entry", code)
+	}
+	if files[notePath] != before {
+		t.Error("note changed although the entry wasn't found")
+	}
+}
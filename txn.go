@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// transaction makes the writes of one command to several files, such as
+// the notes of an import with -by-date, all-or-nothing on a best-effort
+// basis. While it is attached to a client, the first write to each file
+// records the file's revision beforehand; if the command fails part way,
+// rollback puts every file written back to that revision, or deletes it
+// when it didn't exist, and restores the local index as it was.
+type transaction struct {
+	client    *DropboxClient
+	indexPath string
+	cachePath string
+	index     []byte // the local index before the transaction, nil if none
+	indexLog  []byte // and its log
+	files     []*txnFile
+	byPath    map[string]*txnFile
+}
+
+// txnFile is a file written within a transaction.
+type txnFile struct {
+	Path    string
+	PrevRev string // "" when the file didn't exist
+	Rev     string // last written by the transaction, "" once deleted
+}
+
+// beginTransaction attaches a new transaction to client. indexPath is the
+// local index, saved so a rollback can restore it, and cachePath the today
+// summary it drops; "" skips either.
+func beginTransaction(client *DropboxClient, indexPath, cachePath string) *transaction {
+	t := &transaction{client: client, indexPath: indexPath, cachePath: cachePath, byPath: map[string]*txnFile{}}
+	if indexPath != "" {
+		t.index, _ = os.ReadFile(indexPath)
+		t.indexLog, _ = os.ReadFile(indexLogPath(indexPath))
+	}
+	client.Txn = t
+	return t
+}
+
+// before records the revision of p ahead of the transaction's first write
+// to it.
+func (t *transaction) before(p string) error {
+	if t.byPath[p] != nil {
+		return nil
+	}
+	rev, err := t.client.currentRev(p)
+	if err != nil {
+		return fmt.Errorf("recording the revision of %s: %w", p, err)
+	}
+	f := &txnFile{Path: p, PrevRev: rev, Rev: rev}
+	t.files = append(t.files, f)
+	t.byPath[p] = f
+	return nil
+}
+
+// wrote records that p is now at rev, "" when it was deleted.
+func (t *transaction) wrote(p, rev string) {
+	if f := t.byPath[p]; f != nil {
+		f.Rev = rev
+	}
+}
+
+// commit keeps the writes and detaches the transaction from its client.
+func (t *transaction) commit() {
+	t.client.Txn = nil
+}
+
+// rollback detaches the transaction and undoes its writes, newest first.
+// A file changed by someone else since the transaction wrote it is left
+// alone rather than overwritten. It returns the paths put back and the
+// errors of those that couldn't be.
+func (t *transaction) rollback() ([]string, error) {
+	t.client.Txn = nil
+	var restored []string
+	var errs []error
+	for i := len(t.files) - 1; i >= 0; i-- {
+		f := t.files[i]
+		if f.Rev == f.PrevRev {
+			continue
+		}
+		cur, err := t.client.currentRev(f.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+			continue
+		}
+		if cur != f.Rev {
+			errs = append(errs, fmt.Errorf("%s changed since it was written; left as it is", f.Path))
+			continue
+		}
+		if f.PrevRev == "" {
+			err = t.client.Delete(f.Path)
+		} else {
+			_, err = t.client.Restore(f.Path, f.PrevRev)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+			continue
+		}
+		restored = append(restored, f.Path)
+	}
+	if t.indexPath != "" {
+		err := restoreFile(t.indexPath, t.index)
+		if err == nil {
+			err = restoreFile(indexLogPath(t.indexPath), t.indexLog)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("restoring the local index: %w", err))
+		}
+	}
+	invalidateTodayCache(t.cachePath)
+	return restored, errors.Join(errs...)
+}
+
+// restoreFile puts data back at p, or removes p when data is nil because
+// there was no file.
+func restoreFile(p string, data []byte) error {
+	if data == nil {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return writeFileAtomic(p, data, 0600)
+}
+
+// currentRev returns the revision of the file at p, or "" if it doesn't
+// exist, bypassing the API cache.
+func (c *DropboxClient) currentRev(p string) (string, error) {
+	fresh := *c
+	fresh.Cache = nil
+	body, err := fresh.rpc("/2/files/get_metadata", map[string]string{"path": p})
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var meta struct {
+		Rev string `json:"rev"`
+	}
+	json.Unmarshal(body, &meta)
+	return meta.Rev, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// revServer is an in-memory Dropbox keeping every revision of each file.
+// Uploads to paths in fail are refused.
+type revServer struct {
+	revs  map[string][]string // path -> contents, oldest first
+	fail  map[string]bool
+	calls []string
+}
+
+func (s *revServer) current(p string) (string, bool) {
+	r := s.revs[p]
+	if len(r) == 0 || r[len(r)-1] == "\x00deleted" {
+		return "", false
+	}
+	return r[len(r)-1], true
+}
+
+func (s *revServer) rev(p string) string {
+	return fmt.Sprintf("%s@%d", p, len(s.revs[p]))
+}
+
+func (s *revServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var arg struct {
+		Path string `json:"path"`
+		Rev  string `json:"rev"`
+	}
+	if h := r.Header.Get("Dropbox-API-Arg"); h != "" {
+		json.Unmarshal([]byte(h), &arg)
+	} else {
+		json.NewDecoder(r.Body).Decode(&arg)
+	}
+	endpoint := strings.TrimPrefix(r.URL.Path, "/2/files/")
+	s.calls = append(s.calls, endpoint+" "+arg.Path)
+	notFound := func() {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+	}
+	switch endpoint {
+	case "download":
+		content, ok := s.current(arg.Path)
+		if !ok {
+			notFound()
+			return
+		}
+		w.Header().Set("Dropbox-API-Result", fmt.Sprintf(`{"rev": %q}`, s.rev(arg.Path)))
+		w.Write([]byte(content))
+	case "get_metadata":
+		if _, ok := s.current(arg.Path); !ok {
+			notFound()
+			return
+		}
+		fmt.Fprintf(w, `{"rev": %q}`, s.rev(arg.Path))
+	case "upload":
+		if s.fail[arg.Path] {
+			w.WriteHeader(507)
+			w.Write([]byte(`{"error_summary": "path/insufficient_space/"}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		s.revs[arg.Path] = append(s.revs[arg.Path], string(body))
+		fmt.Fprintf(w, `{"rev": %q}`, s.rev(arg.Path))
+	case "delete_v2":
+		if _, ok := s.current(arg.Path); !ok {
+			notFound()
+			return
+		}
+		s.revs[arg.Path] = append(s.revs[arg.Path], "\x00deleted")
+		w.Write([]byte(`{}`))
+	case "restore":
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(arg.Rev, arg.Path+"@"), "%d", &n)
+		s.revs[arg.Path] = append(s.revs[arg.Path], s.revs[arg.Path][n-1])
+		fmt.Fprintf(w, `{"rev": %q}`, s.rev(arg.Path))
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+func TestAppendByDate_RollsBack(t *testing.T) {
+	srv := &revServer{
+		revs: map[string][]string{"/J/20250112.md": {"### 08:00:00\nearlier\n"}},
+		fail: map[string]bool{"/J/20250114.md": true},
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: ts.URL}
+	cfg := &Config{PathTemplate: "/J/{{.Date}}.md"}
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	os.WriteFile(indexPath, []byte(`{"notes": {}}`), 0600)
+
+	entries, _ := parseDatedLines("2025-01-12 09:00: one\n2025-01-13 10:00: two\n2025-01-14 11:00: three", time.Local)
+	var stdout, stderr bytes.Buffer
+	opts := appendOptions{IndexPath: indexPath}
+	if code := appendByDate(&stdout, &stderr, client, cfg, "", entries, opts, func(s string) string { return s }); code != 1 {
+		t.Fatalf("code = %d, want 1", code)
+	}
+
+	if got, _ := srv.current("/J/20250112.md"); got != "### 08:00:00\nearlier\n" {
+		t.Errorf("12th not rolled back: %q", got)
+	}
+	if got, ok := srv.current("/J/20250113.md"); ok {
+		t.Errorf("13th should have been deleted, has %q", got)
+	}
+	if data, _ := os.ReadFile(indexPath); string(data) != `{"notes": {}}` {
+		t.Errorf("index not restored: %s", data)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("nothing should be reported as appended, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "Rolled back /J/20250113.md, /J/20250112.md") {
+		t.Errorf("expected the rollback to be reported, got %q", stderr.String())
+	}
+}
+
+func TestTransaction_LeavesFilesChangedElsewhere(t *testing.T) {
+	srv := &revServer{revs: map[string][]string{"/J/a.md": {"a1"}}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	client := &DropboxClient{Token: "tok", BaseURL: ts.URL}
+
+	txn := beginTransaction(client, "", "")
+	client.Upload("/J/a.md", "a2")
+	client.Upload("/J/b.md", "b1")
+	txn.commit()
+	if client.Txn != nil {
+		t.Fatal("commit should detach the transaction")
+	}
+
+	txn = beginTransaction(client, "", "")
+	client.Upload("/J/a.md", "a3")
+	client.Upload("/J/b.md", "b2")
+	srv.revs["/J/b.md"] = append(srv.revs["/J/b.md"], "edited elsewhere")
+	restored, err := txn.rollback()
+	if len(restored) != 1 || restored[0] != "/J/a.md" {
+		t.Errorf("restored %v, want [/J/a.md]", restored)
+	}
+	if err == nil || !strings.Contains(err.Error(), "/J/b.md changed since it was written") {
+		t.Errorf("expected b.md to be reported, got %v", err)
+	}
+	if got, _ := srv.current("/J/a.md"); got != "a2" {
+		t.Errorf("a.md = %q, want a2", got)
+	}
+	if got, _ := srv.current("/J/b.md"); got != "edited elsewhere" {
+		t.Errorf("b.md = %q, want the edit kept", got)
+	}
+}
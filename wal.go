@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// walRecord is one line of the write-ahead log: an entry about to be
+// uploaded, or the note that the upload of a batch failed.
+type walRecord struct {
+	Batch string    `json:"batch"` // ULID shared by the entries of one upload
+	At    time.Time `json:"at"`
+	// Path is the note the entry was appended to and Part the file it was
+	// written to, which differs once the note has rolled over.
+	Path  string `json:"path,omitempty"`
+	Part  string `json:"part,omitempty"`
+	Entry string `json:"entry,omitempty"` // as written, LF line endings
+	// Failed marks a batch whose upload failed and was reported as such.
+	Failed bool `json:"failed,omitempty"`
+}
+
+// defaultWALPath returns the location of the write-ahead log.
+func defaultWALPath() string {
+	return filepath.Join(defaultDataDir(), "wal.jsonl")
+}
+
+// appendWAL adds records to the log at p. The log is a safety net, so
+// callers only warn when it can't be written.
+func appendWAL(p string, records []walRecord) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// logEntries records the entries about to be uploaded to part of the note
+// at path in the log at walPath, and returns the batch ID, which is empty
+// when nothing was logged.
+func logEntries(walPath, path, part string, written []string) string {
+	if walPath == "" {
+		return ""
+	}
+	now := time.Now()
+	batch := newULID(now, rand.Reader)
+	records := make([]walRecord, len(written))
+	for i, w := range written {
+		records[i] = walRecord{Batch: batch, At: now, Path: path, Part: part, Entry: w}
+	}
+	if err := appendWAL(walPath, records); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: write-ahead log: %v\n", err)
+		return ""
+	}
+	return batch
+}
+
+// readWAL returns the entries recorded in the log at p, leaving out those
+// of failed batches. A line cut short by a crash is skipped. A missing log
+// is empty.
+func readWAL(p string) ([]walRecord, error) {
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []walRecord
+	failed := map[string]bool{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 64<<20)
+	for sc.Scan() {
+		var r walRecord
+		if json.Unmarshal(sc.Bytes(), &r) != nil {
+			continue
+		}
+		if r.Failed {
+			failed[r.Batch] = true
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", p, err)
+	}
+	kept := records[:0]
+	for _, r := range records {
+		if !failed[r.Batch] {
+			kept = append(kept, r)
+		}
+	}
+	return kept, nil
+}
+
+// walMissing returns the records whose entry isn't in content, the text of
+// every part of their note. Entries that have since expired were removed on
+// purpose and don't count.
+func walMissing(records []walRecord, content string, now time.Time) []walRecord {
+	lf := toLF(content)
+	var missing []walRecord
+	for _, r := range records {
+		if at, ok := entryExpiry(r.Entry); ok && !at.After(now) {
+			continue
+		}
+		if !strings.Contains(lf, strings.TrimSpace(r.Entry)) {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// summary returns the first line of the entry's text, below its header.
+func (r walRecord) summary() string {
+	if blocks := parseJournal(r.Entry).Blocks; len(blocks) > 0 {
+		return firstLine(blocks[0].Text)
+	}
+	return firstLine(strings.TrimSpace(r.Entry))
+}
+
+// downloadNoteParts returns the content of every part of the note at p,
+// one after the other.
+func downloadNoteParts(client *DropboxClient, p string) (string, error) {
+	var all strings.Builder
+	for n := 1; ; n++ {
+		part := continuationPath(p, n)
+		content, err := client.Download(part)
+		if err != nil {
+			return "", fmt.Errorf("downloading %s: %w", part, err)
+		}
+		if content == "" {
+			return all.String(), nil
+		}
+		all.WriteString(content)
+		all.WriteString("\n")
+	}
+}
+
+// runReconcile implements the `dropbox-appender reconcile` subcommand,
+// which checks the entries in the write-ahead log against the notes in
+// Dropbox and reports, or with -repair appends again, those that are
+// missing. It returns the process exit code.
+func runReconcile(args []string, stdin io.Reader, stdout, stderr io.Writer, clock Clock) int {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dates := addDateFlags(fs)
+	repair := fs.Bool("repair", false, "append the missing entries to their notes again")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := dates.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	cfg, err := loadConfig(defaultConfigPath(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+		return 1
+	}
+	if *repair && refuseReadOnly(stderr, "reconcile -repair") {
+		return 1
+	}
+	records, err := readWAL(defaultWALPath())
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	client, err := connectClient(cfg, *repair)
+	if err != nil {
+		fmt.Fprintf(stderr, tr("error: %v\n"), err)
+		return 1
+	}
+	var opts appendOptions
+	if *repair {
+		if opts, err = appendOptionsFromConfig(cfg); err != nil {
+			fmt.Fprintf(stderr, tr("error loading config: %v\n"), err)
+			return 1
+		}
+		opts.Clock = clock
+	}
+	return runReconcileWithClient(stdout, stderr, client, records, *dates, *repair, opts, clock.Now())
+}
+
+// runReconcileWithClient reconciles the records dated within dates. It
+// exits 1 when entries are missing and weren't repaired.
+func runReconcileWithClient(stdout, stderr io.Writer, client *DropboxClient, records []walRecord,
+	dates dateFilter, repair bool, opts appendOptions, now time.Time) int {
+	var paths []string
+	byPath := map[string][]walRecord{}
+	for _, r := range records {
+		if !dates.match(r.At.Local().Format("2006-01-02")) {
+			continue
+		}
+		if _, ok := byPath[r.Path]; !ok {
+			paths = append(paths, r.Path)
+		}
+		byPath[r.Path] = append(byPath[r.Path], r)
+	}
+
+	// The logged entries already carry their number, anchor and author.
+	opts.Number, opts.EntryIDs, opts.Author, opts.TargetFormat = false, false, "", ""
+	checked, missing := 0, 0
+	for _, p := range paths {
+		content, err := downloadNoteParts(client, p)
+		if err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		checked += len(byPath[p])
+		lost := walMissing(byPath[p], content, now)
+		if len(lost) == 0 {
+			continue
+		}
+		for _, r := range lost {
+			fmt.Fprintf(stdout, "%s: missing entry from %s: %s\n", p, r.At.Local().Format("2006-01-02 15:04:05"), r.summary())
+		}
+		if !repair {
+			missing += len(lost)
+			continue
+		}
+		entries := make([]string, len(lost))
+		for i, r := range lost {
+			entries[i] = r.Entry
+		}
+		if _, err := appendEntries(client, p, entries, opts); err != nil {
+			fmt.Fprintf(stderr, tr("error: %v\n"), err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s: appended %d missing entries again\n", p, len(lost))
+	}
+	if missing > 0 {
+		fmt.Fprintf(stdout, "%d of %d logged entries missing; run with -repair to append them again\n", missing, checked)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%d logged entries checked\n", checked)
+	return 0
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendEntries_WAL(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "wal.jsonl")
+	path := "/Journal/20250115.md"
+	files := map[string]string{}
+	server := rolloverServer(files)
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	opts := appendOptions{WALPath: walPath, Author: "alice"}
+	if _, err := appendEntries(client, path, []string{"### 09:00:00\none\n", "### 09:00:00\ntwo\n"}, opts); err != nil {
+		t.Fatal(err)
+	}
+	records, err := readWAL(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Batch != records[1].Batch || records[1].Path != path ||
+		records[1].Entry != "### 09:00:00 — alice\ntwo\n" {
+		t.Errorf("unexpected records: %+v", records)
+	}
+}
+
+func TestAppendEntries_WALFailedUpload(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "wal.jsonl")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/download") {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error_summary": "path/not_found/"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error_summary": "bad request"}`))
+	}))
+	defer server.Close()
+
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+	if _, err := appendEntries(client, "/a.md", []string{"### 09:00:00\none\n"}, appendOptions{WALPath: walPath}); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	if data, _ := os.ReadFile(walPath); !strings.Contains(string(data), `"failed":true`) {
+		t.Errorf("expected the batch to be marked failed: %s", data)
+	}
+	if records, err := readWAL(walPath); err != nil || len(records) != 0 {
+		t.Errorf("entries of a failed upload should be left out, got %+v, %v", records, err)
+	}
+}
+
+func TestReadWAL_TruncatedLine(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "wal.jsonl")
+	appendWAL(p, []walRecord{{Batch: "b1", Path: "/a.md", Entry: "one"}})
+	f, _ := os.OpenFile(p, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"batch":"b2","path":"/a.md","ent`)
+	f.Close()
+	records, err := readWAL(p)
+	if err != nil || len(records) != 1 || records[0].Entry != "one" {
+		t.Errorf("got %+v, %v", records, err)
+	}
+	if records, err := readWAL(filepath.Join(t.TempDir(), "none")); err != nil || records != nil {
+		t.Errorf("missing log: got %+v, %v", records, err)
+	}
+}
+
+func TestRunReconcile(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.Local)
+	files := map[string]string{
+		"/Journal/20250115.md":   "### 09:00:00\nkept\n",
+		"/Journal/20250115-2.md": "### 10:00:00\nrolled over\n",
+	}
+	server := rolloverServer(files)
+	defer server.Close()
+	client := &DropboxClient{Token: "test-token", BaseURL: server.URL}
+
+	records := []walRecord{
+		{At: now, Path: "/Journal/20250115.md", Entry: "### 09:00:00\nkept\n"},
+		{At: now, Path: "/Journal/20250115.md", Entry: "### 10:00:00\nrolled over\n"},
+		{At: now, Path: "/Journal/20250115.md", Entry: "### 11:00:00\nlost to a conflict\n"},
+		{At: now, Path: "/Journal/20250115.md", Entry: withExpiry("### 11:30:00\ntemporary", now.Add(-time.Hour)) + "\n"},
+		{At: now.AddDate(0, 0, -3), Path: "/Journal/20250112.md", Entry: "### 08:00:00\nout of range\n"},
+	}
+	dates := dateFilter{From: "2025-01-15"}
+
+	var stdout, stderr bytes.Buffer
+	if code := runReconcileWithClient(&stdout, &stderr, client, records, dates, false, appendOptions{}, now); code != 1 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if want := "/Journal/20250115.md: missing entry from 2025-01-15 12:00:00: lost to a conflict\n" +
+		"1 of 4 logged entries missing; run with -repair to append them again\n"; stdout.String() != want {
+		t.Errorf("got %q, want %q", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	opts := appendOptions{Number: true}
+	if code := runReconcileWithClient(&stdout, &stderr, client, records, dates, true, opts, now); code != 0 {
+		t.Fatalf("repair: exit %d: %s", code, stderr.String())
+	}
+	if !strings.HasSuffix(files["/Journal/20250115.md"], "\n### 11:00:00\nlost to a conflict\n") {
+		t.Errorf("repair should append the entry as logged: %q", files["/Journal/20250115.md"])
+	}
+	stdout.Reset()
+	if code := runReconcileWithClient(&stdout, &stderr, client, records, dates, false, appendOptions{}, now); code != 0 {
+		t.Errorf("after repair: exit %d: %s", code, stdout.String())
+	}
+}
+
+func TestWALMissing_CRLF(t *testing.T) {
+	r := walRecord{Entry: "### 09:00:00\none\ntwo\n"}
+	if got := walMissing([]walRecord{r}, "### 09:00:00\r\none\r\ntwo\r\n", time.Now()); len(got) != 0 {
+		t.Errorf("CRLF notes should match, got %+v", got)
+	}
+	if got := walMissing([]walRecord{r}, "", time.Now()); len(got) != 1 {
+		t.Error("expected the entry to be missing")
+	}
+}
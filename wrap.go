@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// wrapListItem matches a list item's marker, including a task box, and
+	// captures it so continuation lines can hang under the text.
+	wrapListItem = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?)\S`)
+	// wrapQuote matches a blockquote prefix ("> ", "> > ").
+	wrapQuote = regexp.MustCompile(`^(\s*(?:>\s?)+)`)
+	// wrapFence matches the opening or closing line of a fenced code block.
+	wrapFence = regexp.MustCompile("^\\s*(```|~~~)")
+	// wrapBlockStart matches words that would start a new Markdown block if
+	// a wrap put them at the beginning of a line.
+	wrapBlockStart = regexp.MustCompile(`^(?:[-*+>]|#{1,6}|\d+[.)]|=+|-+|\|)$`)
+)
+
+// wrapText hard-wraps text at width columns for reading in plain terminals
+// and mail. Only long lines are broken, at spaces; lines are never joined.
+// Markdown structure survives: list items and blockquotes keep their
+// indent or prefix on continuation lines, while code blocks, headings,
+// tables and lines ending in a hard break ("  ") are left alone. Words
+// longer than width, like URLs, are not split. width <= 0 disables wrapping.
+func wrapText(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	var out []string
+	inFence := false
+	for _, line := range lines {
+		if wrapFence.MatchString(line) {
+			inFence = !inFence
+			out = append(out, line)
+			continue
+		}
+		if inFence || !wrappable(line, width) {
+			out = append(out, line)
+			continue
+		}
+
+		first, rest := "", ""
+		if m := wrapListItem.FindStringSubmatch(line); m != nil {
+			first = m[1]
+			rest = strings.Repeat(" ", utf8.RuneCountInString(first))
+		} else if m := wrapQuote.FindStringSubmatch(line); m != nil {
+			first, rest = m[1], m[1]
+		} else {
+			first = line[:len(line)-len(strings.TrimLeft(line, " "))]
+			rest = first
+		}
+		out = append(out, wrapWords(strings.Fields(line[len(first):]), first, rest, width)...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// wrappable reports whether line is too long and not a structure that
+// must stay on one line.
+func wrappable(line string, width int) bool {
+	if utf8.RuneCountInString(line) <= width || strings.HasSuffix(line, "  ") {
+		return false
+	}
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "|") || strings.HasPrefix(trimmed, "<") {
+		return false
+	}
+	// Indented code, unless it is a nested list item.
+	if (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")) && !wrapListItem.MatchString(line) {
+		return false
+	}
+	return true
+}
+
+// wrapWords lays words out in lines of at most width columns, the first
+// starting with first and the others with rest.
+func wrapWords(words []string, first, rest string, width int) []string {
+	var lines []string
+	cur, curLen := first, utf8.RuneCountInString(first)
+	empty := true
+	for _, w := range words {
+		wl := utf8.RuneCountInString(w)
+		if !empty && curLen+1+wl > width && !wrapBlockStart.MatchString(w) {
+			lines = append(lines, cur)
+			cur, curLen, empty = rest, utf8.RuneCountInString(rest), true
+		}
+		if !empty {
+			cur += " "
+			curLen++
+		}
+		cur += w
+		curLen += wl
+		empty = false
+	}
+	return append(lines, cur)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	cases := []struct {
+		name, text, want string
+	}{
+		{"short line", "hello world", "hello world"},
+		{"paragraph", "the quick brown fox jumps over the lazy dog",
+			"the quick brown\nfox jumps over\nthe lazy dog"},
+		{"list item hangs", "- the quick brown fox jumps over",
+			"- the quick brown\n  fox jumps over"},
+		{"task item hangs", "- [ ] the quick brown fox jumps",
+			"- [ ] the quick\n      brown fox\n      jumps"},
+		{"numbered item", "10. the quick brown fox jumps",
+			"10. the quick\n    brown fox\n    jumps"},
+		{"quote", "> the quick brown fox jumps over",
+			"> the quick brown\n> fox jumps over"},
+		{"fenced code untouched", "```\nthe quick brown fox jumps over the lazy dog\n```",
+			"```\nthe quick brown fox jumps over the lazy dog\n```"},
+		{"heading untouched", "## the quick brown fox jumps over", "## the quick brown fox jumps over"},
+		{"table untouched", "| the quick | brown fox | jumps over |", "| the quick | brown fox | jumps over |"},
+		{"hard break untouched", "the quick brown fox jumps over  ", "the quick brown fox jumps over  "},
+		{"long word not split", "see https://example.com/a/very/long/path ok",
+			"see\nhttps://example.com/a/very/long/path\nok"},
+		{"no accidental list", "the quick browns - fox",
+			"the quick browns -\nfox"},
+		{"runes not bytes", "ééééé ééééé ééééé ééééé", "ééééé ééééé ééééé\nééééé"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := wrapText(c.text, 17)
+			if got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+			for _, line := range strings.Split(got, "\n") {
+				if strings.HasPrefix(strings.TrimSpace(line), "#") && !strings.HasPrefix(c.text, "#") {
+					t.Errorf("wrap produced a heading line %q", line)
+				}
+			}
+		})
+	}
+	if got := wrapText("the quick brown fox", 0); got != "the quick brown fox" {
+		t.Errorf("width 0 should not wrap, got %q", got)
+	}
+}